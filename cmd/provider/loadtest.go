@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/provider"
+)
+
+// defaultLoadTestKeys is the image mix used when no keys are given
+const defaultLoadTestKeys = "docker.io/library/nginx:latest|[]||,docker.io/library/alpine:latest|[]||"
+
+// runLoadTest runs the loadtest subcommand against a running provider
+func runLoadTest(args []string) {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	url := fs.String("url", "https://localhost:8090/verify", "Provider /verify endpoint")
+	keys := fs.String("keys", defaultLoadTestKeys, "Comma-separated provider keys (image|secrets|identity|issuer) to draw from; repeat a key to weight it")
+	keysPerRequest := fs.Int("keys-per-request", 5, "Number of keys in each ProviderRequest")
+	concurrency := fs.Int("concurrency", 10, "Number of requests in flight at once")
+	requests := fs.Int("requests", 0, "Total requests to send (0 runs for -duration)")
+	duration := fs.Duration("duration", 30*time.Second, "How long to run when -requests is 0")
+	timeout := fs.Duration("timeout", 30*time.Second, "Per-request timeout")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification")
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Load testing %s (concurrency: %d, keys per request: %d)", *url, *concurrency, *keysPerRequest)
+	report, err := provider.RunLoadTest(ctx, provider.LoadTestConfig{
+		URL:                *url,
+		Keys:               strings.Split(*keys, ","),
+		KeysPerRequest:     *keysPerRequest,
+		Concurrency:        *concurrency,
+		Requests:           *requests,
+		Duration:           *duration,
+		Timeout:            *timeout,
+		InsecureSkipVerify: *insecure,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fmt.Print(report)
+}
@@ -1,36 +1,305 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/yourusername/sbom-gatekeeper-provider/pkg/provider"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		runLoadTest(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "warmup" {
+		runWarmup(os.Args[2:])
+		return
+	}
+
 	// Parse command-line flags
 	port := flag.String("port", getEnv("PORT", "8090"), "Server port")
 	timeout := flag.Duration("timeout", getEnvDuration("TIMEOUT", 30*time.Second), "Verification timeout")
 	tlsCert := flag.String("tls-cert", getEnv("TLS_CERT", ""), "Path to TLS certificate")
 	tlsKey := flag.String("tls-key", getEnv("TLS_KEY", ""), "Path to TLS private key")
+	cacheTTL := flag.Duration("cache-ttl", getEnvDuration("CACHE_TTL", 0), "How long verification results are cached (0 disables caching)")
+	cacheSnapshot := flag.String("cache-snapshot", getEnv("CACHE_SNAPSHOT", ""), "Where verified digest results are shared so new replicas start warm: file:<path> or configmap:<name> (empty disables)")
+	cacheSnapshotInterval := flag.Duration("cache-snapshot-interval", getEnvDuration("CACHE_SNAPSHOT_INTERVAL", time.Minute), "How often the cache is exported to the snapshot")
+	cacheTTLJitter := flag.Float64("cache-ttl-jitter", getEnvFloat("CACHE_TTL_JITTER", provider.DefaultCacheTTLJitter), "Fraction of CACHE_TTL each cached result expires early by at most, so results cached together do not all expire at once (0 disables jitter)")
+	cacheRefreshAhead := flag.Float64("cache-refresh-ahead", getEnvFloat("CACHE_REFRESH_AHEAD", 0), "Re-verify cached results in the background when requested within this last fraction of their TTL (0 disables background refresh)")
+	digestCacheTTL := flag.Duration("digest-cache-ttl", getEnvDuration("DIGEST_CACHE_TTL", provider.DefaultDigestCacheTTL), "How long tags resolved by /resolve are cached (0 disables caching)")
+	digestMode := flag.String("digest-mode", getEnv("DIGEST_MODE", provider.DigestModeAllow), "How /verify treats images referenced by tag only: allow, require (fail with ERR_MUTABLE_TAG) or resolve (verify the digest the tag points to)")
+	denyImagePatterns := flag.String("deny-image-patterns", getEnv("DENY_IMAGE_PATTERNS", ""), "Comma-separated image reference patterns failed with ERR_IMAGE_DENIED before verification, e.g. *:latest (* also matches /)")
+	allowedRegistries := flag.String("allowed-registries", getEnv("ALLOWED_REGISTRIES", ""), "Comma-separated registry hosts images must come from, as glob patterns; others fail with ERR_IMAGE_DENIED before verification (empty allows every registry)")
+	asyncMode := flag.Bool("async", getEnvBool("ASYNC_MODE", false), "Return a pending value for uncached images and verify them in the background")
+	asyncWorkers := flag.Int("async-workers", getEnvInt("ASYNC_WORKERS", 4), "Number of background verification workers in async mode")
+	maxConcurrent := flag.Int("max-concurrent-verifications", getEnvInt("MAX_CONCURRENT_VERIFICATIONS", 0), "Limit on synchronous verifications in flight, shared between request classes by weight (0 disables)")
+	classWeights := flag.String("request-class-weights", getEnv("REQUEST_CLASS_WEIGHTS", ""), "Comma-separated class=weight overrides of the admission=8,audit=1,batch=1 scheduling weights")
+	memoryLimit := flag.Int("memory-limit", getEnvInt("MEMORY_LIMIT", 0), "Memory in bytes load shedding thresholds are relative to (0 uses the container memory limit, negative disables shedding)")
+	memoryThresholds := flag.String("memory-shed-thresholds", getEnv("MEMORY_SHED_THRESHOLDS", ""), "Comma-separated class=percent overrides of the batch=80,audit=90 memory usage above which verifications are shed")
+	maxPinDuration := flag.Duration("max-pin-duration", getEnvDuration("MAX_PIN_DURATION", 0), "Longest window accepted by the /pins result pinning endpoint, authenticated with the admin token (0 disables pinning)")
+	pinStore := flag.String("pin-store", getEnv("PIN_STORE", ""), "Where pins are shared between replicas: configmap:<name> (empty keeps pins in each replica)")
+	valueSchema := flag.String("value-schema", getEnv("VALUE_SCHEMA", provider.ValueSchemaV1), "Default schema version of item values (v1 or v2)")
+	valueSchemaProviders := flag.String("value-schema-providers", getEnv("VALUE_SCHEMA_PROVIDERS", ""), "Comma-separated provider=version value schemas for Gatekeeper Providers whose URL carries ?provider=<name>")
+	inspectToken := flag.String("inspect-token", getEnv("INSPECT_TOKEN", ""), "Bearer token required by the /inspect endpoint (empty disables it)")
+	expiryCheckInterval := flag.Duration("expiry-check-interval", getEnvDuration("EXPIRY_CHECK_INTERVAL", provider.DefaultExpiryCheckInterval), "How often trust material and TLS certificate expiry is checked (0 disables)")
+	expiryWarning := flag.Duration("expiry-warning", getEnvDuration("EXPIRY_WARNING", provider.DefaultExpiryWarning), "How long before trust material expires warnings are logged")
+	exceptionsFile := flag.String("exceptions-file", getEnv("EXCEPTIONS_FILE", ""), "JSON file of policy exceptions turning specific violations into warnings until they expire (empty disables)")
+	shadowPolicyFile := flag.String("shadow-policy-file", getEnv("SHADOW_POLICY_FILE", ""), "JSON shadow signer policy verified alongside the active one, logging and counting differing outcomes without affecting responses (empty disables)")
+	upstreamProviders := flag.String("upstream-providers", getEnv("UPSTREAM_PROVIDERS", ""), "Comma-separated source=url mappings of image repositories to other external data providers the images are also checked by")
+	upstreamCABundle := flag.String("upstream-ca-bundle", getEnv("UPSTREAM_CA_BUNDLE", ""), "PEM file of the CAs upstream provider certificates are verified against (empty uses the system roots)")
+	windowsFile := flag.String("windows-file", getEnv("WINDOWS_FILE", ""), "JSON file of verification windows, e.g. release freezes, that deny unverified images or tighten policies while in force (empty disables)")
+	logSamplingWindow := flag.Duration("log-sampling-window", getEnvDuration("LOG_SAMPLING_WINDOW", provider.DefaultLogSamplingWindow), "How long identical per-image error log lines are summarized for instead of logged on every request (0 disables sampling)")
+	leakCheckInterval := flag.Duration("leak-check-interval", getEnvDuration("LEAK_CHECK_INTERVAL", 5*time.Minute), "How often goroutines and open fds are sampled for leaks (0 disables)")
+	auditInterval := flag.Duration("audit-interval", getEnvDuration("AUDIT_INTERVAL", 0), "How often the images of running pods are verified in the background as audit traffic (0 disables)")
+	auditKeySettings := flag.String("audit-key-settings", getEnv("AUDIT_KEY_SETTINGS", ""), "JSON key settings of the constraint audited images are keyed with, e.g. {\"certIdentityRegexp\":\"...\"}")
+	auditReplicas := flag.Int("audit-replicas", getEnvInt("AUDIT_REPLICAS", 1), "Number of replicas running images are sharded across by digest for the audit")
+	auditReplicaIndex := flag.Int("audit-replica-index", getEnvInt("AUDIT_REPLICA_INDEX", -1), "Audit shard of this replica (negative uses the ordinal of a StatefulSet pod's hostname)")
+	enableChaos := flag.Bool("enable-chaos", getEnvBool("ENABLE_CHAOS", false), "Expose the /chaos failure injection endpoint, authenticated with the admin token (staging only)")
+	adminToken := flag.String("admin-token", getEnv("ADMIN_TOKEN", ""), "Bearer token required by the /chaos and /pins admin endpoints (empty disables them)")
+
+	useReferrers := flag.Bool("use-referrers", getEnvBool("USE_REFERRERS_API", false), "Discover attestations through the OCI 1.1 referrers API")
+	kubeQPS := flag.Float64("kube-api-qps", getEnvFloat("KUBE_API_QPS", provider.DefaultKubeQPS), "Sustained requests per second to the Kubernetes API server when fetching pull secrets")
+	kubeBurst := flag.Int("kube-api-burst", getEnvInt("KUBE_API_BURST", provider.DefaultKubeBurst), "Burst of requests allowed to the Kubernetes API server above kube-api-qps")
+	secretFetchTimeout := flag.Duration("secret-fetch-timeout", getEnvDuration("SECRET_FETCH_TIMEOUT", provider.DefaultSecretFetchTimeout), "Timeout for reading a request's imagePullSecrets from the API server")
+	pullSecretNamespaces := flag.String("pull-secret-namespaces", getEnv("PULL_SECRET_NAMESPACES", ""), "Comma-separated namespace patterns keys may read imagePullSecrets from besides the provider's own (empty allows any)")
+	trustedRoots := flag.String("trusted-roots", getEnv("TRUSTED_ROOTS", provider.TrustedRootPublicGood), "Comma-separated Sigstore trusted roots tried in order (public-good, staging, custom, tuf:<mirror> or file:<path>)")
+	tufRoot := flag.String("tuf-root", getEnv("TUF_ROOT", ""), "Path of the TUF root.json trusted for tuf:<mirror> trusted roots, e.g. mounted from a ConfigMap")
+	trustedRootRefresh := flag.Duration("trusted-root-refresh-interval", getEnvDuration("TRUSTED_ROOT_REFRESH_INTERVAL", provider.DefaultTrustedRootRefreshInterval), "How often trusted roots are re-fetched, evicting cached results when they change (0 disables refreshing)")
+	attestationSources := flag.String("attestation-sources", getEnv("ATTESTATION_SOURCES", ""), "Comma-separated order attestation sources are tried in: referrers, tag, repository, rekor, buildx (empty derives it from the enabled features)")
+	registrySources := flag.String("attestation-sources-by-registry", getEnv("ATTESTATION_SOURCES_BY_REGISTRY", ""), "Semicolon-separated per-registry source orders, e.g. ghcr.io=referrers,tag;quay.io=tag,rekor")
+	registryFlavors := flag.String("registry-flavors", getEnv("REGISTRY_FLAVORS", ""), "Comma-separated registry=flavor overrides of how referrers are discovered: generic, artifactory or quay (others are detected by host name)")
+	originRepos := flag.String("origin-repositories", getEnv("ORIGIN_REPOSITORIES", ""), "Comma-separated image=origin mappings of the repositories images are copied to (or prefixes ending in /*) to the repository they were attested in")
+	attestationRepos := flag.String("attestation-repositories", getEnv("ATTESTATION_REPOSITORIES", ""), "Comma-separated source=target mappings of image repositories (or prefixes ending in /*) to the repository holding their attestations")
+	fulcioRoots := flag.String("fulcio-roots", getEnv("SIGSTORE_ROOT_FILE", ""), "PEM bundle of the Fulcio root and intermediate certificates of a self-hosted Sigstore, for the custom trusted root")
+	rekorPublicKeys := flag.String("rekor-public-keys", getEnv("SIGSTORE_REKOR_PUBLIC_KEY", ""), "Comma-separated PEM files of the Rekor public keys of a self-hosted Sigstore, for the custom trusted root")
+	ctLogPublicKeys := flag.String("ct-log-public-keys", getEnv("SIGSTORE_CT_LOG_PUBLIC_KEY_FILE", ""), "Comma-separated PEM files of the certificate transparency log public keys of a self-hosted Sigstore, for the custom trusted root")
+	verifySCT := flag.Bool("verify-sct", getEnvBool("VERIFY_SCT", false), "Require signing certificates to carry an SCT from a trusted certificate transparency log")
+	rekorURL := flag.String("rekor-url", getEnv("REKOR_URL", provider.DefaultRekorURL), "Rekor transparency log URL")
+	rekorQPS := flag.Float64("rekor-qps", getEnvFloat("REKOR_QPS", provider.DefaultRekorQPS), "Sustained calls per second to Rekor, which also back off when Rekor answers 429")
+	rekorBurst := flag.Int("rekor-burst", getEnvInt("REKOR_BURST", provider.DefaultRekorBurst), "Burst of calls allowed to Rekor above rekor-qps")
+	rekorSearch := flag.Bool("rekor-search-fallback", getEnvBool("REKOR_SEARCH_FALLBACK", false), "Search Rekor by image digest when the registry holds no attestations")
+	attachedSBOMFallback := flag.Bool("attached-sbom-fallback", getEnvBool("ATTACHED_SBOM_FALLBACK", false), "Read the SBOM cosign attach sbom stored on the .sbom tag when no attestation yields one")
+	offlineTlog := flag.Bool("offline-tlog", getEnvBool("OFFLINE_TLOG", false), "Verify transparency log inclusion from the bundle embedded in each attestation only, never contacting Rekor")
+	maxDecompressedPayload := flag.Int("max-decompressed-payload", getEnvInt("MAX_DECOMPRESSED_PAYLOAD", provider.DefaultMaxDecompressedPayload), "Maximum size in bytes of a gzip or zstd compressed attestation payload once decompressed; larger ones are rejected")
+	maxAttestations := flag.Int("max-attestations", getEnvInt("MAX_ATTESTATIONS", provider.DefaultMaxAttestations), "Attestations verified per image and source, newest first; older ones are skipped with a warning")
+	sbomCompleteness := flag.Bool("sbom-completeness", getEnvBool("SBOM_COMPLETENESS", false), "Score how complete each SBOM looks for its image (fetches the image manifest)")
+	componentEvidence := flag.Bool("component-evidence", getEnvBool("COMPONENT_EVIDENCE", false), "Add the evidence and pedigree of CycloneDX components to their packages")
+	vulnerabilityScans := flag.Bool("vulnerability-scans", getEnvBool("VULNERABILITY_SCANS", false), "Add the newest verified cosign vulnerability scan attestation (predicate type vuln) of each image to its SBOM")
+	vexStatements := flag.Bool("vex-statements", getEnvBool("VEX_STATEMENTS", false), "Add the statements of the verified OpenVEX attestations of each image to its SBOM")
+	enforceDSSESignatures := flag.Bool("enforce-dsse-signatures", getEnvBool("ENFORCE_DSSE_SIGNATURES", false), "Drop verified attestations whose DSSE envelope signature does not verify once more with the key they were verified with, instead of only logging them")
+	spdxFilesSummary := flag.Bool("spdx-files-summary", getEnvBool("SPDX_FILES_SUMMARY", false), "Add the file count and the licenses seen in the files of SPDX SBOMs, without the file entries")
+	publicKey := flag.String("public-key", getEnv("COSIGN_PUBLIC_KEY", ""), "Cosign public key (PEM, path to a PEM file, KMS key URI or k8s://<namespace>/<name> Secret) attestations must be signed with instead of keyless certificates (empty verifies keyless)")
+	kmsKeyCacheTTL := flag.Duration("kms-key-cache-ttl", getEnvDuration("KMS_KEY_CACHE_TTL", provider.DefaultKMSKeyCacheTTL), "How long public keys fetched from a KMS are reused before being fetched again")
+	repositoryPolicyTag := flag.String("repository-policy-tag", getEnv("REPOSITORY_POLICY_TAG", ""), "Tag under which image repositories publish a signed policy.json declaring the signers of their images, for keyless constraints without identities (empty disables)")
+	repositoryPolicySignerIdentity := flag.String("repository-policy-signer", getEnv("REPOSITORY_POLICY_SIGNER", ""), "Certificate identity repository policies must be signed by")
+	repositoryPolicySignerRegexp := flag.String("repository-policy-signer-regexp", getEnv("REPOSITORY_POLICY_SIGNER_REGEXP", ""), "Certificate identity pattern repository policies must be signed by, instead of an exact identity")
+	repositoryPolicyIssuer := flag.String("repository-policy-issuer", getEnv("REPOSITORY_POLICY_ISSUER", ""), "OIDC issuer that must have certified the repository policy signer")
+	repositoryPolicyTTL := flag.Duration("repository-policy-ttl", getEnvDuration("REPOSITORY_POLICY_TTL", provider.DefaultRepositoryPolicyTTL), "How long a discovered repository policy is reused before being fetched again")
+	licenseAliasesFile := flag.String("license-aliases-file", getEnv("LICENSE_ALIASES_FILE", ""), "JSON file mapping license strings SBOM generators emit to SPDX identifiers, extending the built-in aliases")
+	predicateTypes := flag.String("predicate-types", getEnv("PREDICATE_TYPES", ""), "Comma-separated SBOM formats (spdx, cyclonedx) or predicate types accepted (empty accepts both formats)")
+	platform := flag.String("platform", getEnv("PLATFORM", ""), "os/arch[/variant] platform whose manifest is verified when an image is an index, e.g. linux/amd64 (empty verifies the reference as given)")
+	requireImageSignature := flag.Bool("require-image-signature", getEnvBool("REQUIRE_IMAGE_SIGNATURE", false), "Also require a cosign signature of each image by an accepted signer, reported alongside its SBOM")
+	unsignedBuildxSBOMs := flag.String("unsigned-buildx-sboms", getEnv("UNSIGNED_BUILDX_SBOMS", provider.UnsignedSBOMsFail), "Handling of BuildKit and attached SBOMs without a covering cosign signature: fail, or inventory to return them flagged unverified")
+	sbomCollection := flag.String("sbom-collection", getEnv("SBOM_COLLECTION", provider.SBOMCollectionFirst), "SBOMs returned when an image has several: first, all to also list each SBOM, or merge to deduplicate their packages")
+	publishKey := flag.String("sbom-publish-key", getEnv("SBOM_PUBLISH_KEY", ""), "Cosign private key verified unified SBOMs are signed with and pushed back to the registry as referrers (empty disables)")
+	publishKeyPassword := getEnv("SBOM_PUBLISH_KEY_PASSWORD", "")
+	catalogURL := flag.String("catalog-url", getEnv("CATALOG_URL", ""), "Internal image catalog consulted after verification to confirm the repository is registered to a team (empty disables)")
+	catalogToken := getEnv("CATALOG_TOKEN", "")
+	catalogTimeout := flag.Duration("catalog-timeout", getEnvDuration("CATALOG_TIMEOUT", provider.DefaultCatalogTimeout), "Timeout for an image catalog lookup")
+	maxClockSkew := flag.Duration("max-clock-skew", getEnvDuration("MAX_CLOCK_SKEW", provider.DefaultMaxClockSkew), "Tolerated node clock skew against the transparency log (0 disables the check)")
+	maxSBOMAge := flag.Duration("max-sbom-age", getEnvDuration("MAX_SBOM_AGE", 0), "Fail SBOMs older than this (0 disables the check)")
+	sbomAgeSource := flag.String("sbom-age-source", getEnv("SBOM_AGE_SOURCE", provider.SBOMAgeSourceCreated), "Timestamp SBOM ages are measured from: created (the SBOM document), rekor (transparency log integration) or certificate (signing certificate notBefore)")
+	rekorCertValidityTolerance := flag.Duration("rekor-search-cert-validity-tolerance", getEnvDuration("REKOR_SEARCH_CERT_VALIDITY_TOLERANCE", 0), "Tolerance applied to the certificate validity windows of attestations found by searching Rekor")
+	printOpenAPI := flag.Bool("print-openapi", false, "Print the OpenAPI spec for the provider API and exit")
 
 	flag.Parse()
 
+	if *printOpenAPI {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(provider.OpenAPISpec()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	repositoryPolicySigner := provider.AllowedIdentity{
+		CertIdentity:       *repositoryPolicySignerIdentity,
+		CertIdentityRegexp: *repositoryPolicySignerRegexp,
+		CertOidcIssuer:     *repositoryPolicyIssuer,
+	}
+
 	// Create attestation verifier
-	verifier, err := provider.NewAttestationVerifier()
+	verifier, err := provider.NewAttestationVerifier(provider.VerifierConfig{
+		UseReferrers:               *useReferrers,
+		KubeQPS:                    float32(*kubeQPS),
+		KubeBurst:                  *kubeBurst,
+		SecretFetchTimeout:         *secretFetchTimeout,
+		PullSecretNamespaces:       strings.Split(*pullSecretNamespaces, ","),
+		TrustedRoots:               strings.Split(*trustedRoots, ","),
+		TrustedRootRefreshInterval: *trustedRootRefresh,
+		TUFRoot:                    *tufRoot,
+		CustomTrustedRoot: provider.CustomTrustedRoot{
+			FulcioRoots:     *fulcioRoots,
+			RekorPublicKeys: strings.Split(*rekorPublicKeys, ","),
+			CTLogPublicKeys: strings.Split(*ctLogPublicKeys, ","),
+		},
+		VerifySCT:                  *verifySCT,
+		AttestationSources:         strings.Split(*attestationSources, ","),
+		RegistryAttestationSources: strings.Split(*registrySources, ";"),
+		RegistryFlavors:            strings.Split(*registryFlavors, ","),
+		AttestationRepositories:    strings.Split(*attestationRepos, ","),
+		OriginRepositories:         strings.Split(*originRepos, ","),
+		RekorURL:                   *rekorURL,
+		RekorQPS:                   *rekorQPS,
+		RekorBurst:                 *rekorBurst,
+		RekorSearchFallback:        *rekorSearch,
+		AttachedSBOMFallback:       *attachedSBOMFallback,
+		OfflineTlog:                *offlineTlog,
+		MaxAttestations:            *maxAttestations,
+		MaxDecompressedPayload:     int64(*maxDecompressedPayload),
+		SBOMCompleteness:           *sbomCompleteness,
+		ComponentEvidence:          *componentEvidence,
+		SPDXFilesSummary:           *spdxFilesSummary,
+		VulnerabilityScans:         *vulnerabilityScans,
+		VEXStatements:              *vexStatements,
+		EnforceDSSESignatures:      *enforceDSSESignatures,
+		PublicKey:                  *publicKey,
+		KMSKeyCacheTTL:             *kmsKeyCacheTTL,
+		RepositoryPolicyTag:        *repositoryPolicyTag,
+		RepositoryPolicySigner:     repositoryPolicySigner,
+		RepositoryPolicyTTL:        *repositoryPolicyTTL,
+		LicenseAliasesFile:         *licenseAliasesFile,
+		PredicateTypes:             strings.Split(*predicateTypes, ","),
+		Platform:                   *platform,
+		RequireImageSignature:      *requireImageSignature,
+		UnsignedBuildxSBOMs:        *unsignedBuildxSBOMs,
+		SBOMCollection:             *sbomCollection,
+		PublishKey:                 *publishKey,
+		PublishKeyPassword:         publishKeyPassword,
+		CatalogURL:                 *catalogURL,
+		CatalogToken:               catalogToken,
+		CatalogTimeout:             *catalogTimeout,
+		MaxClockSkew:               *maxClockSkew,
+		RekorCertValidityTolerance: *rekorCertValidityTolerance,
+		MaxSBOMAge:                 *maxSBOMAge,
+		SBOMAgeSource:              *sbomAgeSource,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Create and start server
-	server := provider.NewServer(*port, verifier, *timeout, *tlsCert, *tlsKey)
+	server := provider.NewServer(provider.ServerConfig{
+		Port:                       *port,
+		Timeout:                    *timeout,
+		TLSCert:                    *tlsCert,
+		TLSKey:                     *tlsKey,
+		CacheTTL:                   *cacheTTL,
+		CacheTTLJitter:             *cacheTTLJitter,
+		CacheRefreshAhead:          *cacheRefreshAhead,
+		CacheSnapshot:              *cacheSnapshot,
+		CacheSnapshotInterval:      *cacheSnapshotInterval,
+		DigestCacheTTL:             *digestCacheTTL,
+		DigestMode:                 *digestMode,
+		DenyImagePatterns:          strings.Split(*denyImagePatterns, ","),
+		AllowedRegistries:          strings.Split(*allowedRegistries, ","),
+		AsyncMode:                  *asyncMode,
+		AsyncWorkers:               *asyncWorkers,
+		MaxConcurrentVerifications: *maxConcurrent,
+		RequestClassWeights:        strings.Split(*classWeights, ","),
+		MemoryLimit:                int64(*memoryLimit),
+		MemoryShedThresholds:       strings.Split(*memoryThresholds, ","),
+		MaxPinDuration:             *maxPinDuration,
+		PinStore:                   *pinStore,
+		InspectToken:               *inspectToken,
+		ValueSchema:                *valueSchema,
+		ValueSchemaProviders:       strings.Split(*valueSchemaProviders, ","),
+		ExpiryCheckInterval:        *expiryCheckInterval,
+		ExpiryWarning:              *expiryWarning,
+		ExceptionsFile:             *exceptionsFile,
+		WindowsFile:                *windowsFile,
+		ShadowPolicyFile:           *shadowPolicyFile,
+		UpstreamProviders:          strings.Split(*upstreamProviders, ","),
+		UpstreamCABundle:           *upstreamCABundle,
+		LeakCheckInterval:          *leakCheckInterval,
+		LogSamplingWindow:          *logSamplingWindow,
+		EnableChaos:                *enableChaos,
+		AdminToken:                 *adminToken,
+		AuditInterval:              *auditInterval,
+		AuditKeySettings:           *auditKeySettings,
+		AuditReplicas:              *auditReplicas,
+		AuditReplicaIndex:          *auditReplicaIndex,
+	}, verifier)
 
 	log.Printf("Configuration:")
 	log.Printf("  Port: %s", *port)
 	log.Printf("  TLS Enabled: %v", *tlsCert != "" && *tlsKey != "")
 	log.Printf("  Timeout: %v", *timeout)
+	log.Printf("  Cache TTL: %v", *cacheTTL)
+	log.Printf("  Cache TTL Jitter: %v (refresh ahead: %v)", *cacheTTLJitter, *cacheRefreshAhead)
+	log.Printf("  Cache Snapshot: %q (interval: %v)", *cacheSnapshot, *cacheSnapshotInterval)
+	log.Printf("  Digest Cache TTL: %v", *digestCacheTTL)
+	log.Printf("  Digest Mode: %s", *digestMode)
+	log.Printf("  Deny Image Patterns: %v", *denyImagePatterns)
+	log.Printf("  Allowed Registries: %v", *allowedRegistries)
+	log.Printf("  Async Mode: %v (workers: %d)", *asyncMode, *asyncWorkers)
+	log.Printf("  Max Concurrent Verifications: %d (class weights: %q)", *maxConcurrent, *classWeights)
+	log.Printf("  Memory Load Shedding: limit %d bytes (thresholds: %q)", *memoryLimit, *memoryThresholds)
+	log.Printf("  Max Pin Duration: %v (store: %q)", *maxPinDuration, *pinStore)
+	log.Printf("  Inspect Endpoint: %v", *inspectToken != "")
+	log.Printf("  Value Schema: %s (by provider: %s)", *valueSchema, *valueSchemaProviders)
+	log.Printf("  Expiry Check Interval: %v (warning: %v)", *expiryCheckInterval, *expiryWarning)
+	log.Printf("  Exceptions File: %q", *exceptionsFile)
+	log.Printf("  Windows File: %q", *windowsFile)
+	log.Printf("  Shadow Policy File: %q", *shadowPolicyFile)
+	log.Printf("  Upstream Providers: %q (CA bundle: %q)", *upstreamProviders, *upstreamCABundle)
+	log.Printf("  Leak Check Interval: %v", *leakCheckInterval)
+	log.Printf("  Log Sampling Window: %v", *logSamplingWindow)
+	log.Printf("  Chaos Endpoint: %v", *enableChaos)
+	log.Printf("  Admin Endpoints: %v", *adminToken != "")
+	log.Printf("  Audit Interval: %v (replicas: %d, replica index: %d, key settings: %q)", *auditInterval, *auditReplicas, *auditReplicaIndex, *auditKeySettings)
+	log.Printf("  Referrers API: %v", *useReferrers)
+	log.Printf("  Kubernetes API: %v QPS, %d burst (secret fetch timeout: %v)", *kubeQPS, *kubeBurst, *secretFetchTimeout)
+	log.Printf("  Pull Secret Namespaces: %q", *pullSecretNamespaces)
+	log.Printf("  Trusted Roots: %s (refresh interval: %v)", *trustedRoots, *trustedRootRefresh)
+	log.Printf("  TUF Root: %q", *tufRoot)
+	log.Printf("  Attestation Sources: %q (by registry: %q)", *attestationSources, *registrySources)
+	log.Printf("  Registry Flavors: %q", *registryFlavors)
+	log.Printf("  Attestation Repositories: %q", *attestationRepos)
+	log.Printf("  Origin Repositories: %q", *originRepos)
+	log.Printf("  Custom Trusted Root: Fulcio %q, Rekor %q, CT logs %q (verify SCT: %v)", *fulcioRoots, *rekorPublicKeys, *ctLogPublicKeys, *verifySCT)
+	log.Printf("  Rekor URL: %s (search fallback: %v)", *rekorURL, *rekorSearch)
+	log.Printf("  Attached SBOM fallback: %v", *attachedSBOMFallback)
+	log.Printf("  Rekor Rate Limit: %v QPS, %d burst", *rekorQPS, *rekorBurst)
+	log.Printf("  Offline Transparency Log Verification: %v", *offlineTlog)
+	log.Printf("  Max Attestations: %d", *maxAttestations)
+	log.Printf("  Max Decompressed Payload: %d", *maxDecompressedPayload)
+	log.Printf("  SBOM Completeness: %v", *sbomCompleteness)
+	log.Printf("  Component Evidence: %v", *componentEvidence)
+	log.Printf("  SPDX Files Summary: %v", *spdxFilesSummary)
+	log.Printf("  Vulnerability Scans: %v", *vulnerabilityScans)
+	log.Printf("  VEX Statements: %v", *vexStatements)
+	log.Printf("  Enforce DSSE Signatures: %v", *enforceDSSESignatures)
+	log.Printf("  Public Key Verification: %v", *publicKey != "")
+	log.Printf("  KMS Key Cache TTL: %v", *kmsKeyCacheTTL)
+	log.Printf("  Repository Policy Tag: %q (signer: %q, pattern: %q, issuer: %q, TTL: %v)", *repositoryPolicyTag, *repositoryPolicySignerIdentity, *repositoryPolicySignerRegexp, *repositoryPolicyIssuer, *repositoryPolicyTTL)
+	log.Printf("  License Aliases File: %q", *licenseAliasesFile)
+	log.Printf("  Predicate Types: %q", *predicateTypes)
+	log.Printf("  Platform: %q", *platform)
+	log.Printf("  Require Image Signature: %v", *requireImageSignature)
+	log.Printf("  Unsigned BuildKit SBOMs: %s", *unsignedBuildxSBOMs)
+	log.Printf("  SBOM Collection: %s", *sbomCollection)
+	log.Printf("  SBOM Publishing: %v", *publishKey != "")
+	log.Printf("  Image Catalog: %q (timeout: %v)", *catalogURL, *catalogTimeout)
+	log.Printf("  Max Clock Skew: %v (Rekor search cert validity tolerance: %v)", *maxClockSkew, *rekorCertValidityTolerance)
+	log.Printf("  Max SBOM Age: %v (from: %s)", *maxSBOMAge, *sbomAgeSource)
 
 	if err := server.Start(); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -54,3 +323,33 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getEnvBool gets a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat gets a float environment variable or returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvInt gets an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/provider"
+)
+
+// runWarmup runs the warmup subcommand, verifying the images of manifests on a running
+// provider before they are applied
+func runWarmup(args []string) {
+	fs := flag.NewFlagSet("warmup", flag.ExitOnError)
+	url := fs.String("url", "https://localhost:8090/warmup", "Provider /warmup endpoint")
+	files := fs.String("f", "-", "Comma-separated manifest files to warm (- reads stdin)")
+	certIdentity := fs.String("cert-identity", "", "certIdentity of the constraint")
+	certIdentityRegexp := fs.String("cert-identity-regexp", "", "certIdentityRegexp of the constraint")
+	certOidcIssuer := fs.String("cert-oidc-issuer", "", "certOidcIssuer of the constraint")
+	identities := fs.String("identities", "", "identities of the constraint, as a JSON list")
+	certExtensions := fs.String("cert-extensions", "", "certExtensions of the constraint, as a JSON object")
+	annotations := fs.String("annotations", "", "annotations of the constraint, as a JSON object")
+	predicateTypes := fs.String("predicate-types", "", "Comma-separated predicateTypes of the constraint")
+	platform := fs.String("platform", "", "platform of the constraint, as os/arch[/variant]")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Timeout for the whole warmup")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification")
+	list := fs.Bool("list", false, "Only print the images found in the manifests")
+	jsonOutput := fs.Bool("json", false, "Print the response as JSON")
+	fs.Parse(args)
+
+	var manifests []string
+	for _, file := range strings.Split(*files, ",") {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+		manifests = append(manifests, string(data))
+	}
+	// Documents of different files must not run together
+	joined := strings.Join(manifests, "\n---\n")
+
+	if *list {
+		images, err := provider.ExtractImages([]byte(joined))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, image := range images {
+			fmt.Println(image)
+		}
+		return
+	}
+
+	req := provider.WarmupRequest{
+		Manifests: joined,
+		KeySettings: provider.KeySettings{
+			CertIdentity:       *certIdentity,
+			CertIdentityRegexp: *certIdentityRegexp,
+			CertOidcIssuer:     *certOidcIssuer,
+		},
+	}
+	if *identities != "" {
+		if err := json.Unmarshal([]byte(*identities), &req.Identities); err != nil {
+			log.Fatalf("Invalid -identities: %v", err)
+		}
+	}
+	if *certExtensions != "" {
+		if err := json.Unmarshal([]byte(*certExtensions), &req.CertExtensions); err != nil {
+			log.Fatalf("Invalid -cert-extensions: %v", err)
+		}
+	}
+	if *annotations != "" {
+		if err := json.Unmarshal([]byte(*annotations), &req.Annotations); err != nil {
+			log.Fatalf("Invalid -annotations: %v", err)
+		}
+	}
+
+	if *predicateTypes != "" {
+		req.PredicateTypes = strings.Split(*predicateTypes, ",")
+	}
+	req.Platform = *platform
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	response, err := provider.RequestWarmup(ctx, provider.WarmupClientConfig{
+		URL:                *url,
+		Request:            req,
+		Timeout:            *timeout,
+		InsecureSkipVerify: *insecure,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(response); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		fmt.Print(response)
+	}
+	if response.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,363 @@
+// Package client calls the API of the SBOM Gatekeeper provider, for internal tools and tests
+// verifying images without hand-rolling the external data protocol.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/provider"
+)
+
+// Defaults of the client settings
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 500 * time.Millisecond
+	DefaultBatchSize    = 50
+)
+
+// Config configures a Client
+type Config struct {
+	// URL is the base URL of the provider, e.g. https://sbom-provider.gatekeeper-system:8443
+	URL string
+	// Timeout bounds each attempt of a request (0 uses DefaultTimeout)
+	Timeout time.Duration
+
+	// CAFile is a PEM bundle of the CAs the provider certificate is verified against, the
+	// system roots when empty
+	CAFile string
+	// CertFile and KeyFile are the client certificate presented for mTLS, e.g. to a proxy in
+	// front of the provider
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name the provider certificate is verified for
+	ServerName string
+	// InsecureSkipVerify disables TLS verification, e.g. for self-signed provider certificates
+	InsecureSkipVerify bool
+	// HTTPClient replaces the client built from the TLS settings and Timeout
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a request failing with a network error, 429 or 5xx gateway
+	// status is retried (0 uses DefaultMaxRetries, negative disables retries)
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled for each retry and overridden
+	// by a Retry-After header (0 uses DefaultRetryBackoff)
+	RetryBackoff time.Duration
+
+	// BatchSize is the number of keys sent per request by VerifyBatch (0 uses DefaultBatchSize)
+	BatchSize int
+	// Class is the request class Verify and VerifyImages are tagged with, the provider's
+	// default (admission) when empty; VerifyBatch always uses provider.RequestClassBatch
+	Class string
+	// Debug requests verification traces, logged by the provider
+	Debug bool
+}
+
+// Client calls a provider. It is safe for concurrent use.
+type Client struct {
+	base       *url.URL
+	http       *http.Client
+	maxRetries int
+	backoff    time.Duration
+	batchSize  int
+	class      string
+	debug      bool
+}
+
+// New creates a client of the provider at cfg.URL
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url %q: expected an http or https URL", cfg.URL)
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		}
+	}
+
+	c := &Client{
+		base:       base,
+		http:       httpClient,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+		batchSize:  cfg.BatchSize,
+		class:      cfg.Class,
+		debug:      cfg.Debug,
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = DefaultMaxRetries
+	}
+	if c.backoff <= 0 {
+		c.backoff = DefaultRetryBackoff
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = DefaultBatchSize
+	}
+	return c, nil
+}
+
+// tlsConfig builds the TLS settings of cfg
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Result is the outcome of verifying a single key
+type Result struct {
+	Key     string
+	SBOM    *provider.UnifiedSBOM // Verified SBOM, nil on error or while pending
+	Pending bool                  // Verification is still running (async mode)
+	Code    string                // Error code, e.g. provider.ErrCodeNoAttestations, empty for uncoded errors
+	Error   string                // Error of the item as returned by the provider
+}
+
+// Err returns the error of the result, nil when the image verified or is pending
+func (r Result) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return &ItemError{Key: r.Key, Code: r.Code, Message: r.Error}
+}
+
+// ItemError is the error the provider returned for a key
+type ItemError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+func (e *ItemError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// Verify sends keys to /verify in a single request and returns the provider response as is
+func (c *Client) Verify(ctx context.Context, keys []string) (*provider.ProviderResponse, error) {
+	return c.verify(ctx, keys, c.class)
+}
+
+// VerifyImages verifies keys in a single request and returns their results in the order of
+// keys. Keys the provider returns no item for, e.g. empty keys, get an error result.
+func (c *Client) VerifyImages(ctx context.Context, keys ...string) ([]Result, error) {
+	resp, err := c.verify(ctx, keys, c.class)
+	if err != nil {
+		return nil, err
+	}
+	return results(keys, resp.Response.Items)
+}
+
+// VerifyBatch verifies keys as batch class traffic, BatchSize keys per request, so bulk tools
+// yield to admission requests on a busy provider. Results are in the order of keys; the first
+// request failing as a whole aborts the batch.
+func (c *Client) VerifyBatch(ctx context.Context, keys []string) ([]Result, error) {
+	all := make([]Result, 0, len(keys))
+	for start := 0; start < len(keys); start += c.batchSize {
+		chunk := keys[start:min(start+c.batchSize, len(keys))]
+		resp, err := c.verify(ctx, chunk, provider.RequestClassBatch)
+		if err != nil {
+			return all, fmt.Errorf("keys %d to %d: %w", start, start+len(chunk)-1, err)
+		}
+		res, err := results(chunk, resp.Response.Items)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, res...)
+	}
+	return all, nil
+}
+
+// Warmup verifies and caches the images of manifests through /warmup
+func (c *Client) Warmup(ctx context.Context, req provider.WarmupRequest) (*provider.WarmupResponse, error) {
+	var resp provider.WarmupResponse
+	if err := c.post(ctx, "/warmup", req, provider.RequestClassBatch, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// verify sends a /verify request for keys tagged with class
+func (c *Client) verify(ctx context.Context, keys []string, class string) (*provider.ProviderResponse, error) {
+	req := provider.ProviderRequest{
+		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
+		Kind:       "ProviderRequest",
+		Request:    provider.Request{Keys: keys},
+	}
+	var resp provider.ProviderResponse
+	if err := c.post(ctx, "/verify", req, class, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Response.SystemError != "" {
+		return nil, fmt.Errorf("provider system error: %s", resp.Response.SystemError)
+	}
+	return &resp, nil
+}
+
+// results decodes the items of a response into the results of keys
+func results(keys []string, items []provider.Item) ([]Result, error) {
+	byKey := make(map[string]provider.Item, len(items))
+	for _, item := range items {
+		byKey[item.Key] = item
+	}
+
+	res := make([]Result, 0, len(keys))
+	for _, key := range keys {
+		item, ok := byKey[key]
+		if !ok {
+			res = append(res, Result{Key: key, Error: "no item returned for the key"})
+			continue
+		}
+		result, err := decodeItem(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+// decodeItem decodes an item of the v1 value schema
+func decodeItem(item provider.Item) (Result, error) {
+	result := Result{Key: item.Key}
+	if item.Error != "" {
+		result.Error = item.Error
+		if code, _, ok := strings.Cut(item.Error, ": "); ok && strings.HasPrefix(code, "ERR_") {
+			result.Code = code
+		}
+		return result, nil
+	}
+
+	var pending provider.PendingValue
+	if err := json.Unmarshal([]byte(item.Value), &pending); err == nil && pending.Status == provider.StatusPending {
+		result.Pending = true
+		return result, nil
+	}
+	result.SBOM = &provider.UnifiedSBOM{}
+	if err := json.Unmarshal([]byte(item.Value), result.SBOM); err != nil {
+		return Result{}, fmt.Errorf("invalid value for %s: %w", item.Key, err)
+	}
+	return result, nil
+}
+
+// post sends body as JSON to path and decodes the response into out, retrying transient failures
+func (c *Client) post(ctx context.Context, path string, body any, class string, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := c.base.JoinPath(path).String()
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, endpoint, payload, class, out)
+		if err == nil || retryAfter < 0 || attempt >= c.maxRetries {
+			return err
+		}
+		if retryAfter == 0 {
+			retryAfter = c.backoff << attempt
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// attempt sends a single request. It returns how long to wait before retrying a failure: zero
+// for the default backoff, negative when the failure is not worth retrying.
+func (c *Client) attempt(ctx context.Context, endpoint string, payload []byte, class string, out any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(provider.ValueSchemaHeader, provider.ValueSchemaV1)
+	if class != "" {
+		req.Header.Set(provider.RequestClassHeader, class)
+	}
+	if c.debug {
+		req.Header.Set(provider.DebugHeader, "true")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("%s failed with status %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(message)))
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return retryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return -1, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return -1, fmt.Errorf("invalid %s response: %w", req.URL.Path, err)
+	}
+	return 0, nil
+}
+
+// retryAfter parses a Retry-After header given in seconds, zero when absent or a date
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
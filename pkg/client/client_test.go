@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/provider"
+)
+
+// fakeProvider answers /verify requests with an SBOM for every key but "missing", which fails
+// with ERR_NO_ATTESTATIONS, and "slow", which is pending
+func fakeProvider(t *testing.T, classes chan<- string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if classes != nil {
+			classes <- r.Header.Get(provider.RequestClassHeader)
+		}
+		var req provider.ProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Invalid request: %v", err)
+		}
+		var items []provider.Item
+		for _, key := range req.Request.Keys {
+			switch key {
+			case "missing":
+				items = append(items, provider.Item{Key: key, Error: provider.ErrCodeNoAttestations + ": Failed to verify attestation or extract SBOM: no attestations"})
+			case "slow":
+				items = append(items, provider.Item{Key: key, Value: `{"status":"pending"}`})
+			default:
+				items = append(items, provider.Item{Key: key, Value: `{"format":"spdx","packages":[{"name":"zlib","versionInfo":"1.3"}]}`})
+			}
+		}
+		json.NewEncoder(w).Encode(provider.ProviderResponse{Kind: "ProviderResponse", Response: provider.Response{Items: items}})
+	}
+}
+
+func TestVerifyImages(t *testing.T) {
+	srv := httptest.NewServer(fakeProvider(t, nil))
+	defer srv.Close()
+
+	c, err := New(Config{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	results, err := c.VerifyImages(context.Background(), "nginx", "missing", "slow")
+	if err != nil || len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v, %v", results, err)
+	}
+	if r := results[0]; r.Err() != nil || r.SBOM == nil || r.SBOM.Format != "spdx" || len(r.SBOM.Packages) != 1 {
+		t.Errorf("Expected the SBOM of nginx, got %+v", r)
+	}
+	var itemErr *ItemError
+	if r := results[1]; r.Code != provider.ErrCodeNoAttestations || !errors.As(r.Err(), &itemErr) || itemErr.Key != "missing" {
+		t.Errorf("Expected %s for missing, got %+v", provider.ErrCodeNoAttestations, r)
+	}
+	if r := results[2]; !r.Pending || r.SBOM != nil || r.Err() != nil {
+		t.Errorf("Expected slow to be pending, got %+v", r)
+	}
+
+	if _, err := New(Config{URL: "ftp://provider"}); err == nil {
+		t.Error("Expected a non-HTTP URL to be rejected")
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	classes := make(chan string, 10)
+	srv := httptest.NewServer(fakeProvider(t, classes))
+	defer srv.Close()
+
+	c, err := New(Config{URL: srv.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	keys := []string{"a", "b", "missing", "d", "e"}
+	results, err := c.VerifyBatch(context.Background(), keys)
+	if err != nil || len(results) != len(keys) {
+		t.Fatalf("Expected %d results, got %d, %v", len(keys), len(results), err)
+	}
+	for i, r := range results {
+		if r.Key != keys[i] {
+			t.Errorf("Expected result %d for %s, got %s", i, keys[i], r.Key)
+		}
+	}
+	close(classes)
+	requests := 0
+	for class := range classes {
+		requests++
+		if class != provider.RequestClassBatch {
+			t.Errorf("Expected batch class requests, got %q", class)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests of at most 2 keys, got %d", requests)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	verify := fakeProvider(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		default:
+			verify(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := New(Config{URL: srv.URL, RetryBackoff: time.Millisecond})
+	if results, err := c.VerifyImages(context.Background(), "nginx"); err != nil || results[0].SBOM == nil {
+		t.Errorf("Expected the request to succeed after retries, got %+v, %v", results, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+
+	calls.Store(0)
+	c, _ = New(Config{URL: srv.URL, MaxRetries: -1})
+	if _, err := c.VerifyImages(context.Background(), "nginx"); err == nil || calls.Load() != 1 {
+		t.Errorf("Expected a single failed attempt without retries, got %d attempts, %v", calls.Load(), err)
+	}
+
+	badRequest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+	}))
+	defer badRequest.Close()
+	calls.Store(0)
+	c, _ = New(Config{URL: badRequest.URL, RetryBackoff: time.Millisecond})
+	if _, err := c.VerifyImages(context.Background(), "nginx"); err == nil || calls.Load() != 1 {
+		t.Errorf("Expected client errors not to be retried, got %d attempts, %v", calls.Load(), err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(fakeProvider(t, nil))
+	defer srv.Close()
+
+	if c, _ := New(Config{URL: srv.URL, MaxRetries: -1}); c != nil {
+		if _, err := c.VerifyImages(context.Background(), "nginx"); err == nil {
+			t.Error("Expected the self-signed certificate to be rejected without its CA")
+		}
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+	c, err := New(Config{URL: srv.URL, CAFile: caFile, ServerName: "example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if results, err := c.VerifyImages(context.Background(), "nginx"); err != nil || results[0].SBOM == nil {
+		t.Errorf("Expected the provider certificate to verify against the CA, got %+v, %v", results, err)
+	}
+
+	if _, err := New(Config{URL: srv.URL, CertFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("Expected a missing client certificate to fail")
+	}
+}
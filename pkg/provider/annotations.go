@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/providerkey"
+)
+
+// maxSignedAnnotations bounds the annotations a key may require
+const maxSignedAnnotations = providerkey.MaxAnnotations
+
+// parseSignedAnnotations parses the JSON object of the annotations key option into the form
+// cosign compares signature payloads against. Values must be strings, as cosign sign -a
+// only signs strings.
+func parseSignedAnnotations(raw string) (map[string]interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var annotations map[string]string
+	if err := json.Unmarshal([]byte(raw), &annotations); err != nil {
+		return nil, fmt.Errorf("invalid annotations %q: %w", raw, err)
+	}
+	if len(annotations) > maxSignedAnnotations {
+		return nil, fmt.Errorf("invalid annotations: %d entries, at most %d are allowed", len(annotations), maxSignedAnnotations)
+	}
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{}, len(annotations))
+	for k, v := range annotations {
+		if k == "" {
+			return nil, fmt.Errorf("invalid annotations: empty annotation name")
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// describeAnnotations renders required annotations as sorted key=value pairs for messages
+func describeAnnotations(annotations map[string]interface{}) string {
+	pairs := make([]string, 0, len(annotations))
+	for k, v := range annotations {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// checkSignedAnnotations requires ref to carry a cosign signature, made by a signer checkOpts
+// accepts, whose signed payload holds the annotations the verification of ctx requires.
+// Attestations carry no signed annotations, so they are taken from the image signature
+// created with cosign sign -a.
+func (v *AttestationVerifier) checkSignedAnnotations(ctx context.Context, ref name.Reference, keychain authn.Keychain, checkOpts *cosign.CheckOpts) error {
+	annotations, err := parseSignedAnnotations(signedAnnotations(ctx))
+	if err != nil || annotations == nil {
+		return err
+	}
+
+	opts := *checkOpts
+	opts.Annotations = annotations
+	opts.ClaimVerifier = cosign.SimpleClaimVerifier
+	opts.ExperimentalOCI11 = false
+	if extensions := requiredCertExtensions(ctx); extensions != nil {
+		opts.ClaimVerifier = extensions.claimVerifier(opts.ClaimVerifier)
+	}
+
+	err = v.verifyWithTrustedRoots(&opts, func(opts *cosign.CheckOpts) error {
+		_, _, err := cosign.VerifyImageSignatures(ctx, ref, opts)
+		return err
+	})
+	if err != nil {
+		if _, ok := registryAuthStatus(err); ok {
+			return classifyRegistryAuthError(err, ref, keychain)
+		}
+		return newVerificationError(ErrCodeAnnotationMismatch, "no signature of %s by an accepted signer carries the annotations %s: %w", ref, describeAnnotations(annotations), err)
+	}
+	tracef(ctx, "signed annotations verified: %s", describeAnnotations(annotations))
+	return nil
+}
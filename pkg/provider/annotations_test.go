@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ocimutate "github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+)
+
+func TestParseSignedAnnotations(t *testing.T) {
+	annotations, err := parseSignedAnnotations(`{"env":"prod","buildId":"1234"}`)
+	if err != nil {
+		t.Fatalf("Expected valid annotations, got %v", err)
+	}
+	if len(annotations) != 2 || annotations["env"] != "prod" || annotations["buildId"] != "1234" {
+		t.Errorf("Expected env and buildId, got %v", annotations)
+	}
+	if got := describeAnnotations(annotations); got != "buildId=1234, env=prod" {
+		t.Errorf("Expected sorted pairs, got %q", got)
+	}
+
+	for _, raw := range []string{"", "{}"} {
+		if annotations, err := parseSignedAnnotations(raw); err != nil || annotations != nil {
+			t.Errorf("Expected no annotations for %q, got %v, %v", raw, annotations, err)
+		}
+	}
+
+	many := make([]string, maxSignedAnnotations+1)
+	for i := range many {
+		many[i] = `"k` + strings.Repeat("x", i) + `":"v"`
+	}
+	for name, raw := range map[string]string{
+		"non-string value": `{"buildId":1234}`,
+		"empty name":       `{"":"prod"}`,
+		"too many":         "{" + strings.Join(many, ",") + "}",
+		"not json":         `env=prod`,
+	} {
+		if _, err := parseSignedAnnotations(raw); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestAnnotationsKeyOption(t *testing.T) {
+	settings := KeySettings{Annotations: map[string]string{"env": "prod,eu|1"}}
+	key := settings.key("ghcr.io/org/app:v1", nil)
+	imageRef, opts := splitKeyOptions(key)
+	if opts.annotations != `{"env":"prod,eu|1"}` {
+		t.Errorf("Expected the annotations to round-trip, got %q from %q", opts.annotations, key)
+	}
+	if got := opts.resultKey(imageRef); got != key {
+		t.Errorf("Expected the result key %q, got %q", key, got)
+	}
+	if got := signedAnnotations(withKeyOptions(context.Background(), opts)); got != opts.annotations {
+		t.Errorf("Expected the annotations in the context, got %q", got)
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey(key) == verifier.PolicyHashForKey("ghcr.io/org/app:v1|[]||") {
+		t.Error("Expected required annotations to change the policy hash")
+	}
+}
+
+// pushSignedImage pushes a random image to repo with a cosign signature by signer whose
+// payload carries annotations
+func pushSignedImage(t *testing.T, repo string, signer signature.SignerVerifier, annotations map[string]interface{}) name.Digest {
+	t.Helper()
+	h, err := name.NewDigest(repo + "@" + pushRandomImage(t, repo+":v1"))
+	if err != nil {
+		t.Fatalf("Failed to parse digest: %v", err)
+	}
+	if annotations == nil {
+		return h
+	}
+
+	p, err := (&payload.Cosign{Image: h, Annotations: annotations}).MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	sig, err := signer.SignMessage(bytes.NewReader(p))
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	ociSig, err := static.NewSignature(p, base64.StdEncoding.EncodeToString(sig))
+	if err != nil {
+		t.Fatalf("Failed to create signature: %v", err)
+	}
+	se, err := ociremote.SignedEntity(h)
+	if err != nil {
+		t.Fatalf("Failed to read image: %v", err)
+	}
+	se, err = ocimutate.AttachSignatureToEntity(se, ociSig)
+	if err != nil {
+		t.Fatalf("Failed to attach signature: %v", err)
+	}
+	if err := ociremote.WriteSignatures(h.Context(), se); err != nil {
+		t.Fatalf("Failed to push signature: %v", err)
+	}
+	return h
+}
+
+// staticKeyCheckOpts returns a verifier and check options verifying signatures by signer.
+// Signatures by a static key without transparency log checks need no trust material.
+func staticKeyCheckOpts(t *testing.T, signer signature.Verifier) (*AttestationVerifier, *cosign.CheckOpts) {
+	t.Helper()
+	verifier := &AttestationVerifier{trustedRoots: []namedTrustedRoot{{name: TrustedRootCustom}}}
+	return verifier, &cosign.CheckOpts{ClaimVerifier: cosign.IntotoSubjectClaimVerifier, SigVerifier: signer, IgnoreTlog: true, IgnoreSCT: true}
+}
+
+func TestCheckSignedAnnotations(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+
+	signed := pushSignedImage(t, host+"/test/signed", signer, map[string]interface{}{"env": "prod", "buildId": "1234"})
+	unsigned := pushSignedImage(t, host+"/test/unsigned", signer, nil)
+
+	check := func(ref name.Digest, annotations string) error {
+		ctx := withKeyOptions(context.Background(), keyOptions{annotations: annotations})
+		return verifier.checkSignedAnnotations(ctx, ref, authn.DefaultKeychain, checkOpts)
+	}
+
+	if err := check(signed, `{"env":"prod"}`); err != nil {
+		t.Errorf("Expected a subset of the signed annotations to pass, got %v", err)
+	}
+	if err := check(signed, `{"env":"prod","buildId":"1234"}`); err != nil {
+		t.Errorf("Expected the signed annotations to pass, got %v", err)
+	}
+	if err := check(signed, `{"env":"staging"}`); ErrorCode(err) != ErrCodeAnnotationMismatch {
+		t.Errorf("Expected %s for a different value, got %v", ErrCodeAnnotationMismatch, err)
+	}
+	if err := check(unsigned, `{"env":"prod"}`); ErrorCode(err) != ErrCodeAnnotationMismatch {
+		t.Errorf("Expected %s for an unsigned image, got %v", ErrCodeAnnotationMismatch, err)
+	}
+	if err := check(unsigned, ""); err != nil {
+		t.Errorf("Expected no check without required annotations, got %v", err)
+	}
+}
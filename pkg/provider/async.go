@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"log"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// asyncVerifier performs verifications in the background using a workqueue.
+// Results are written to the shared result cache so that subsequent requests
+// for the same key are answered without blocking the admission webhook.
+type asyncVerifier struct {
+	queue  workqueue.TypedInterface[string]
+	verify func(key, class string) Item
+	lookup func(key string) (Item, bool)
+	store  func(key string, item Item)
+	logf   func(format string, args ...interface{}) // Logs failed verifications
+
+	mu      sync.Mutex
+	classes map[string]string // Request class of each waiting key
+}
+
+// newAsyncVerifier creates an async verifier. verify performs the actual verification as
+// traffic of the class the key was enqueued with, store persists its result into the cache
+// and lookup reads it back.
+func newAsyncVerifier(verify func(key, class string) Item, lookup func(key string) (Item, bool), store func(key string, item Item)) *asyncVerifier {
+	return &asyncVerifier{
+		queue: workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{
+			Name: "sbom-verification",
+		}),
+		verify:  verify,
+		lookup:  lookup,
+		store:   store,
+		logf:    log.Printf,
+		classes: make(map[string]string),
+	}
+}
+
+// Run starts the given number of workers. It returns immediately.
+func (a *asyncVerifier) Run(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for a.processNextItem() {
+			}
+		}()
+	}
+}
+
+// Enqueue schedules key for background verification as class traffic. Keys already waiting
+// in the queue are deduplicated by the workqueue, and verified as admission traffic once an
+// admission request asked for them.
+func (a *asyncVerifier) Enqueue(key, class string) {
+	a.mu.Lock()
+	if _, ok := a.classes[key]; !ok || class == RequestClassAdmission {
+		a.classes[key] = class
+	}
+	a.mu.Unlock()
+	a.queue.Add(key)
+}
+
+// ShutDown stops the workers once in-flight items are done
+func (a *asyncVerifier) ShutDown() {
+	a.queue.ShutDown()
+}
+
+// processNextItem verifies a single queued key, returning false when the queue is shut down
+func (a *asyncVerifier) processNextItem() bool {
+	key, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer a.queue.Done(key)
+
+	a.mu.Lock()
+	class := a.classes[key]
+	delete(a.classes, key)
+	a.mu.Unlock()
+
+	// The key may have been re-added while a previous verification was running
+	if _, ok := a.lookup(key); ok {
+		return true
+	}
+
+	item := a.verify(key, class)
+	a.store(key, item)
+	if item.Error != "" {
+		a.logf("Async verification failed for %s: %s", key, item.Error)
+	}
+	return true
+}
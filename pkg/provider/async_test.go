@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncVerifierStoresResult(t *testing.T) {
+	cache := newResultCache()
+	var calls int32
+
+	verify := func(key, class string) Item {
+		atomic.AddInt32(&calls, 1)
+		return Item{Key: key, Value: `{"format":"spdx"}`}
+	}
+
+	async := newAsyncVerifier(verify, cache.Get, func(key string, item Item) {
+		cache.Set(key, item, time.Minute)
+	})
+	async.Run(1)
+	defer async.ShutDown()
+
+	async.Enqueue("image:tag", RequestClassAdmission)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if item, ok := cache.Get("image:tag"); ok {
+			if item.Value != `{"format":"spdx"}` {
+				t.Errorf("Unexpected cached value: %s", item.Value)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for async verification")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Already cached keys must not be verified again
+	async.Enqueue("image:tag", RequestClassAdmission)
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 verification, got %d", n)
+	}
+}
+
+func TestAsyncVerifierClass(t *testing.T) {
+	classes := make(chan string, 2)
+	async := newAsyncVerifier(func(key, class string) Item {
+		classes <- key + "=" + class
+		return Item{Key: key, Value: "{}"}
+	}, func(string) (Item, bool) { return Item{}, false }, func(string, Item) {})
+
+	// Keys waiting for a worker are upgraded once an admission request asks for them
+	async.Enqueue("a", RequestClassAudit)
+	async.Enqueue("a", RequestClassAdmission)
+	async.Enqueue("a", RequestClassBatch)
+	async.Enqueue("b", RequestClassAudit)
+	async.Run(1)
+	defer async.ShutDown()
+
+	for _, want := range []string{"a=" + RequestClassAdmission, "b=" + RequestClassAudit} {
+		select {
+		case got := <-classes:
+			if got != want {
+				t.Errorf("Expected %s verified, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for async verification")
+		}
+	}
+}
+
+func TestAsyncFailuresCachedBriefly(t *testing.T) {
+	server := NewServer(ServerConfig{Timeout: time.Second, CacheTTL: time.Hour, AsyncMode: true, MemoryLimit: -1}, &AttestationVerifier{})
+	server.async.Run(1)
+	defer server.async.ShutDown()
+
+	key := "not a valid reference|[]||"
+	if item := server.resolveItem(key, RequestClassAdmission); item.Value != pendingValue {
+		t.Fatalf("Expected a pending item, got %+v", item)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if item, ok := server.cachedItem(key); ok {
+			if item.Error == "" {
+				t.Fatalf("Expected a failed verification, got %+v", item)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for async verification")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.cache.mu.RLock()
+	expiresAt := server.cache.entries[server.cacheKey(key)].expiresAt
+	server.cache.mu.RUnlock()
+	if time.Until(expiresAt) > asyncFailureTTL {
+		t.Errorf("Expected the failure cached for at most %v, expires in %v", asyncFailureTTL, time.Until(expiresAt))
+	}
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+)
+
+// sbomDocumentPredicateTypes maps the media types JSON SBOM documents are stored with, by cosign
+// attach sbom or as OCI artifacts, to the predicate type they are read as
+var sbomDocumentPredicateTypes = map[string]string{
+	"text/spdx+json":                 "https://spdx.dev/Document",
+	"application/spdx+json":          "https://spdx.dev/Document",
+	"application/vnd.cyclonedx+json": "https://cyclonedx.org/bom",
+}
+
+// attachedSBOMSubjectAnnotation is the signature annotation naming the image digest an attached
+// SBOM belongs to. The SBOM manifest does not reference the image, so without it a signed SBOM of
+// any image could be copied to the .sbom tag of another.
+const attachedSBOMSubjectAnnotation = "dev.sbom-provider.subject"
+
+// sbomDocumentStatement wraps an SBOM document stored outside of an attestation in an unsigned
+// in-toto statement, so it goes through the same predicate type, parsing and age checks as
+// attested SBOMs
+type sbomDocumentStatement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// attachedSBOM returns the SBOM cosign attach sbom stored for ref on the legacy
+// sha256-<digest>.sbom tag, as a single statement. The tag is mutable and the SBOM unsigned, so
+// it is only trusted when a cosign signature by an accepted signer covers its manifest, as
+// cosign sign --attachment sbom creates, and names the image digest in its
+// attachedSBOMSubjectAnnotation; otherwise it fails the source, or is returned flagged
+// unverified when unsigned SBOMs are kept for inventory (see UnsignedSBOMsInventory).
+func (v *AttestationVerifier) attachedSBOM(ctx context.Context, ref name.Reference, checkOpts *cosign.CheckOpts, keychain authn.Keychain) ([]verifiedAttestation, error) {
+	digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, classifyRegistryAuthError(err, ref, keychain)
+	}
+	tag, err := ociremote.SBOMTag(ref.Context().Digest(digest.String()), checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := remote.Get(tag, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: no SBOM attached on %s", errNoAttestations, tag)
+		}
+		return nil, classifyRegistryAuthError(err, tag, keychain)
+	}
+	sbomRef := tag.Context().Digest(desc.Digest.String())
+	tracef(ctx, "attached SBOM of %s: %s", digest, sbomRef.DigestStr())
+
+	opts := *checkOpts
+	opts.Annotations = map[string]interface{}{attachedSBOMSubjectAnnotation: digest.String()}
+	signedAt, err := v.verifyManifestSignature(ctx, []name.Digest{sbomRef}, &opts)
+	unverified := false
+	if err != nil {
+		err = fmt.Errorf("attached SBOM %s is not signed for %s by an accepted signer: %w", sbomRef.DigestStr(), digest, err)
+		if _, ok := registryAuthStatus(err); ok || v.unsignedSBOMMode(ctx) != UnsignedSBOMsInventory {
+			return nil, err
+		}
+		log.Printf("Warning: returning the unsigned attached SBOM of %s for inventory: %v", digest, err)
+		unverified = true
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("attached SBOM %s: %w", sbomRef.DigestStr(), err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("attached SBOM %s: %w", sbomRef.DigestStr(), err)
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("attached SBOM %s has %d layers, expected one", sbomRef.DigestStr(), len(layers))
+	}
+	mediaType, err := layers[0].MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("attached SBOM %s: %w", sbomRef.DigestStr(), err)
+	}
+	predicateType, ok := sbomDocumentPredicateTypes[string(mediaType)]
+	if !ok {
+		return nil, fmt.Errorf("attached SBOM %s has unsupported media type %s (only SPDX and CycloneDX JSON are read)", sbomRef.DigestStr(), mediaType)
+	}
+
+	doc, err := v.readSBOMDocument(layers[0])
+	if err != nil {
+		return nil, fmt.Errorf("attached SBOM %s: %w", sbomRef.DigestStr(), err)
+	}
+	payload, err := sbomDocumentPayload(predicateType, doc)
+	if err != nil {
+		return nil, err
+	}
+	return []verifiedAttestation{{payload: payload, signedAt: signedAt, unverified: unverified}}, nil
+}
+
+// readSBOMDocument reads the JSON SBOM document stored as layer, bounded by the decompressed
+// payload limit. SBOM documents are stored uncompressed, the raw blob is the document.
+func (v *AttestationVerifier) readSBOMDocument(layer v1.Layer) ([]byte, error) {
+	blob, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch SBOM document: %w", err)
+	}
+	defer blob.Close()
+	limit := v.maxDecompressedPayload()
+	doc, err := io.ReadAll(io.LimitReader(blob, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM document: %w", err)
+	}
+	if int64(len(doc)) > limit {
+		return nil, fmt.Errorf("SBOM document exceeds %d bytes", limit)
+	}
+	if !json.Valid(doc) {
+		return nil, errors.New("SBOM document is not valid JSON")
+	}
+	return doc, nil
+}
+
+// sbomDocumentPayload returns the statement wrapping doc as a predicate of predicateType
+func sbomDocumentPayload(predicateType string, doc []byte) ([]byte, error) {
+	return json.Marshal(sbomDocumentStatement{Type: "https://in-toto.io/Statement/v1", PredicateType: predicateType, Predicate: doc})
+}
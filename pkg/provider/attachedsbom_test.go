@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrstatic "github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestAttachedSBOM(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+
+	img, _ := random.Image(256, 1)
+	image, _ := name.ParseReference(host + "/test/app:v1")
+	if err := remote.Write(image, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	digest, _ := img.Digest()
+	if _, err := verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain); !errors.Is(err, errNoAttestations) {
+		t.Errorf("Expected no attached SBOM, got %v", err)
+	}
+
+	// cosign attach sbom pushes the document as the single layer of the sha256-<digest>.sbom tag
+	attach := func(to v1.Hash, doc string, mediaType types.MediaType) name.Digest {
+		t.Helper()
+		sbom, err := mutate.AppendLayers(empty.Image, ggcrstatic.NewLayer([]byte(doc), mediaType))
+		if err != nil {
+			t.Fatalf("Failed to create SBOM artifact: %v", err)
+		}
+		tag := image.Context().Tag(strings.Replace(to.String(), ":", "-", 1) + ".sbom")
+		if err := remote.Write(tag, sbom); err != nil {
+			t.Fatalf("Failed to attach SBOM: %v", err)
+		}
+		sbomDigest, _ := sbom.Digest()
+		return image.Context().Digest(sbomDigest.String())
+	}
+	sbomRef := attach(digest, `{"spdxVersion": "SPDX-2.3", "packages": [{"name": "busybox", "versionInfo": "1.36.1"}]}`, "text/spdx+json")
+
+	if _, err := verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("Expected an unsigned attached SBOM to be rejected, got %v", err)
+	}
+	inventory := &AttestationVerifier{trustedRoots: verifier.trustedRoots, unsignedBuildxSBOMs: UnsignedSBOMsInventory}
+	atts, err := inventory.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain)
+	if err != nil || len(atts) != 1 || !atts[0].unverified {
+		t.Fatalf("Expected the unsigned SBOM flagged unverified for inventory, got %+v, %v", atts, err)
+	}
+
+	// A signature that does not name the image vouches for the document only
+	signDigest(t, sbomRef, signer)
+	if _, err := verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain); err == nil || !strings.Contains(err.Error(), "not signed for") {
+		t.Errorf("Expected an attached SBOM signed without the image digest to be rejected, got %v", err)
+	}
+
+	signDigestWithAnnotations(t, sbomRef, signer, map[string]interface{}{attachedSBOMSubjectAnnotation: digest.String()})
+	atts, err = verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain)
+	if err != nil || len(atts) != 1 || atts[0].unverified {
+		t.Fatalf("Expected the signed attached SBOM, got %+v, %v", atts, err)
+	}
+	sbom, err := verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || sbom.Format != "spdx" || len(sbom.Packages) != 1 || sbom.Unverified {
+		t.Errorf("Expected the SPDX SBOM of the attachment, got %+v, %v", sbom, err)
+	}
+
+	xml := attach(digest, `<bom xmlns="http://cyclonedx.org/schema/bom/1.5"/>`, "application/vnd.cyclonedx+xml")
+	signDigestWithAnnotations(t, xml, signer, map[string]interface{}{attachedSBOMSubjectAnnotation: digest.String()})
+	if _, err := verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain); err == nil || !strings.Contains(err.Error(), "unsupported media type") {
+		t.Errorf("Expected an XML SBOM to be rejected, got %v", err)
+	}
+}
+
+func TestAttachedSBOMOfAnotherImage(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+
+	image := pushSignedImage(t, host+"/test/app", signer, nil)
+	other := pushSignedImage(t, host+"/test/app", signer, nil)
+
+	// The other image's SBOM, signed for it, is copied to this image's .sbom tag
+	sbom, err := mutate.AppendLayers(empty.Image, ggcrstatic.NewLayer([]byte(`{"spdxVersion": "SPDX-2.3", "packages": []}`), "text/spdx+json"))
+	if err != nil {
+		t.Fatalf("Failed to create SBOM artifact: %v", err)
+	}
+	for _, d := range []name.Digest{image, other} {
+		if err := remote.Write(d.Context().Tag(strings.Replace(d.DigestStr(), ":", "-", 1)+".sbom"), sbom); err != nil {
+			t.Fatalf("Failed to attach SBOM: %v", err)
+		}
+	}
+	sbomDigest, _ := sbom.Digest()
+	signDigestWithAnnotations(t, image.Context().Digest(sbomDigest.String()), signer, map[string]interface{}{attachedSBOMSubjectAnnotation: other.DigestStr()})
+
+	if _, err := verifier.attachedSBOM(context.Background(), other, checkOpts, authn.DefaultKeychain); err != nil {
+		t.Fatalf("Expected the SBOM of the image it is signed for, got %v", err)
+	}
+	if _, err := verifier.attachedSBOM(context.Background(), image, checkOpts, authn.DefaultKeychain); err == nil || !strings.Contains(err.Error(), "not signed for") {
+		t.Errorf("Expected the SBOM of another image to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	sgbundle "github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// DefaultMaxAttestations bounds how many attestations are verified per image and source
+const DefaultMaxAttestations = 20
+
+// annotationCreated is the OCI annotation recording when a referrer was created
+const annotationCreated = "org.opencontainers.image.created"
+
+// attestationCapHits counts verifications that skipped attestations over the cap, by source
+var attestationCapHits = struct {
+	mu       sync.Mutex
+	bySource map[string]int64
+}{bySource: make(map[string]int64)}
+
+// maxAttestations returns the attestation cap of the verifier
+func (v *AttestationVerifier) maxAttestations() int {
+	if v.attestationCap <= 0 {
+		return DefaultMaxAttestations
+	}
+	return v.attestationCap
+}
+
+// capAttestations returns how many of n attestations, ordered newest first, are verified,
+// recording a cap hit when some are skipped
+func (v *AttestationVerifier) capAttestations(ctx context.Context, source, image string, n int) int {
+	limit := v.maxAttestations()
+	if n <= limit {
+		return n
+	}
+
+	attestationCapHits.mu.Lock()
+	attestationCapHits.bySource[source]++
+	attestationCapHits.mu.Unlock()
+
+	log.Printf("Warning: %d attestations found for %s through %s, only verifying the newest %d", n, image, source, limit)
+	tracef(ctx, "attestation source %s: capped %d attestations to the newest %d", source, n, limit)
+	return limit
+}
+
+// writeAttestationCapMetrics writes the attestation cap counter in Prometheus text format
+func writeAttestationCapMetrics(w io.Writer) {
+	const name = "sbom_provider_attestation_cap_hits_total"
+	fmt.Fprintf(w, "# HELP %s Verifications that skipped attestations over MAX_ATTESTATIONS, by source.\n# TYPE %s counter\n", name, name)
+
+	attestationCapHits.mu.Lock()
+	defer attestationCapHits.mu.Unlock()
+	sources := make([]string, 0, len(attestationCapHits.bySource))
+	for source := range attestationCapHits.bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		fmt.Fprintf(w, "%s{source=%q} %d\n", name, source, attestationCapHits.bySource[source])
+	}
+}
+
+// cappedSignatures serves a subset of the attestations stored under a legacy tag
+type cappedSignatures struct {
+	oci.Signatures
+	sigs []oci.Signature
+}
+
+// Get returns the retained attestations
+func (c *cappedSignatures) Get() ([]oci.Signature, error) {
+	return c.sigs, nil
+}
+
+// newestSignatures orders attestations newest first: by transparency log integration time,
+// and by position in the tag (attestations are appended) when not logged
+func newestSignatures(sigs []oci.Signature) []oci.Signature {
+	integrated := func(sig oci.Signature) int64 {
+		if bundle, err := sig.Bundle(); err == nil && bundle != nil {
+			return bundle.Payload.IntegratedTime
+		}
+		return 0
+	}
+
+	ordered := make([]oci.Signature, len(sigs))
+	for i, sig := range sigs {
+		ordered[len(sigs)-1-i] = sig
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return integrated(ordered[i]) > integrated(ordered[j]) })
+	return ordered
+}
+
+// tagAttestations fetches the attestations stored under the legacy tag of ref, keeping the
+// newest ones up to the attestation cap
+func (v *AttestationVerifier) tagAttestations(ctx context.Context, source string, ref name.Reference, checkOpts *cosign.CheckOpts) (oci.Signatures, v1.Hash, error) {
+	digest, err := ociremote.ResolveDigest(ref, checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	h, err := v1.NewHash(digest.Identifier())
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	tag, err := ociremote.AttestationTag(digest, checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	atts, err := ociremote.Signatures(tag, checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	sigs, err := atts.Get()
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+
+	if len(sigs) == 0 {
+		return nil, v1.Hash{}, errNoAttestations
+	}
+	sigs = newestSignatures(sigs)
+	return &cappedSignatures{Signatures: atts, sigs: sigs[:v.capAttestations(ctx, source, ref.String(), len(sigs))]}, h, nil
+}
+
+// bundleIntegratedTime returns when a Sigstore bundle was integrated in the transparency log,
+// or the zero time for bundles without a log entry
+func bundleIntegratedTime(bundle *sgbundle.Bundle) time.Time {
+	var newest time.Time
+	for _, entry := range bundle.GetVerificationMaterial().GetTlogEntries() {
+		if t := time.Unix(entry.GetIntegratedTime(), 0); entry.GetIntegratedTime() > 0 && t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// referrerBundles fetches the Sigstore bundles attached to ref as OCI referrers, newest first
+// by transparency log integration time, else by creation annotation (falling back to the
+// reverse index order), up to the attestation cap. Referrers whose artifact type is an SBOM
+// document are returned apart, newest first, for referrerSBOMArtifacts.
+func (v *AttestationVerifier) referrerBundles(ctx context.Context, ref name.Reference, checkOpts *cosign.CheckOpts) ([]*sgbundle.Bundle, []v1.Descriptor, v1.Hash, error) {
+	digest, err := ociremote.ResolveDigest(ref, checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return nil, nil, v1.Hash{}, err
+	}
+	h, err := v1.NewHash(digest.Identifier())
+	if err != nil {
+		return nil, nil, v1.Hash{}, err
+	}
+
+	index, err := v.referrers(ctx, digest, checkOpts)
+	if err != nil {
+		return nil, nil, v1.Hash{}, err
+	}
+	manifests := make([]v1.Descriptor, len(index.Manifests))
+	for i, desc := range index.Manifests {
+		manifests[len(index.Manifests)-1-i] = desc
+	}
+	created := func(desc v1.Descriptor) time.Time {
+		t, _ := time.Parse(time.RFC3339, desc.Annotations[annotationCreated])
+		return t
+	}
+	sort.SliceStable(manifests, func(i, j int) bool { return created(manifests[i]).After(created(manifests[j])) })
+
+	var bundles []*sgbundle.Bundle
+	var artifacts []v1.Descriptor
+	times := make(map[*sgbundle.Bundle]time.Time)
+	for _, desc := range manifests {
+		if _, ok := sbomDocumentPredicateTypes[desc.ArtifactType]; ok {
+			artifacts = append(artifacts, desc)
+			continue
+		}
+		bundle, err := ociremote.Bundle(digest.Context().Digest(desc.Digest.String()), checkOpts.RegistryClientOpts...)
+		if err != nil {
+			// Other referrers, e.g. signatures or published SBOMs, are not bundles
+			continue
+		}
+		bundles = append(bundles, bundle)
+		if times[bundle] = bundleIntegratedTime(bundle); times[bundle].IsZero() {
+			times[bundle] = created(desc)
+		}
+	}
+	if len(bundles) == 0 && len(artifacts) == 0 {
+		return nil, nil, v1.Hash{}, fmt.Errorf("%w: no valid bundles exist in registry", errNoAttestations)
+	}
+	sort.SliceStable(bundles, func(i, j int) bool { return times[bundles[i]].After(times[bundles[j]]) })
+	return bundles[:v.capAttestations(ctx, AttestationSourceReferrers, ref.String(), len(bundles))], artifacts, h, nil
+}
+
+// verifyBundles verifies Sigstore bundles against checkOpts and returns them, for extraction
+// to unwrap their DSSE envelopes
+func verifyBundles(ctx context.Context, bundles []*sgbundle.Bundle, h v1.Hash, checkOpts *cosign.CheckOpts) ([]verifiedAttestation, error) {
+	digestBytes, err := hex.DecodeString(h.Hex)
+	if err != nil {
+		return nil, err
+	}
+	artifact := verify.WithArtifactDigest(h.Algorithm, digestBytes)
+
+	var atts []verifiedAttestation
+	var errs []error
+	for _, bundle := range bundles {
+		if err := verifyBundleIdentities(ctx, checkOpts, artifact, bundle); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := checkBundleExtensions(ctx, bundle); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := bundle.Content.(*protobundle.Bundle_DsseEnvelope); !ok {
+			errs = append(errs, fmt.Errorf("bundle does not contain a DSSE envelope"))
+			continue
+		}
+		payload, err := bundle.MarshalJSON()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("marshaling bundle: %w", err))
+			continue
+		}
+		att := verifiedAttestation{payload: payload, signedAt: bundleIntegratedTime(bundle), verifier: checkOpts.SigVerifier}
+		if content, err := bundle.VerificationContent(); err == nil {
+			att.verifier = certVerifier(content.Certificate(), checkOpts.SigVerifier)
+			if cert := content.Certificate(); cert != nil {
+				att.certNotBefore = cert.NotBefore
+			}
+		}
+		atts = append(atts, att)
+	}
+
+	if len(atts) == 0 {
+		return nil, fmt.Errorf("no matching attestations: %w", errors.Join(errs...))
+	}
+	for _, err := range errs {
+		recordPartialFailures(ctx, 1, err)
+	}
+	return atts, nil
+}
+
+// verifyBundleIdentities verifies bundle against checkOpts, accepting a signer matching any of
+// its identities. cosign only verifies bundles against a single identity, so they are tried
+// one at a time; the failure for the first identity is reported, as the certificate and thus
+// the mismatch are the same for all of them.
+func verifyBundleIdentities(ctx context.Context, checkOpts *cosign.CheckOpts, artifact verify.ArtifactPolicyOption, bundle *sgbundle.Bundle) error {
+	if len(checkOpts.Identities) <= 1 {
+		_, err := cosign.VerifyNewBundle(ctx, checkOpts, artifact, bundle)
+		return err
+	}
+
+	var firstErr error
+	for _, identity := range checkOpts.Identities {
+		opts := *checkOpts
+		opts.Identities = []cosign.Identity{identity}
+		_, err := cosign.VerifyNewBundle(ctx, &opts, artifact, bundle)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrstatic "github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	sgbundle "github.com/sigstore/sigstore-go/pkg/bundle"
+)
+
+func TestNewestSignatures(t *testing.T) {
+	newSig := func(payload string, integrated int64) oci.Signature {
+		var opts []static.Option
+		if integrated > 0 {
+			opts = append(opts, static.WithBundle(&bundle.RekorBundle{Payload: bundle.RekorPayload{IntegratedTime: integrated}}))
+		}
+		sig, err := static.NewAttestation([]byte(payload), opts...)
+		if err != nil {
+			t.Fatalf("Failed to create attestation: %v", err)
+		}
+		return sig
+	}
+
+	// Logged attestations are ordered by integration time, the others by position in the tag
+	sigs := newestSignatures([]oci.Signature{newSig("a", 100), newSig("b", 0), newSig("c", 300), newSig("d", 0), newSig("e", 200)})
+	var got []string
+	for _, sig := range sigs {
+		payload, err := sig.Payload()
+		if err != nil {
+			t.Fatalf("Failed to read payload: %v", err)
+		}
+		got = append(got, string(payload))
+	}
+	if strings.Join(got, "") != "ceadb" {
+		t.Errorf("Expected newest first order ceadb, got %s", strings.Join(got, ""))
+	}
+}
+
+func TestBundleIntegratedTime(t *testing.T) {
+	newBundle := func(times ...int64) *sgbundle.Bundle {
+		var entries []*protorekor.TransparencyLogEntry
+		for _, integrated := range times {
+			entries = append(entries, &protorekor.TransparencyLogEntry{IntegratedTime: integrated})
+		}
+		return &sgbundle.Bundle{Bundle: &protobundle.Bundle{VerificationMaterial: &protobundle.VerificationMaterial{TlogEntries: entries}}}
+	}
+
+	if got := bundleIntegratedTime(newBundle(100, 300, 200)); got.Unix() != 300 {
+		t.Errorf("Expected the latest integration time, got %v", got)
+	}
+	if got := bundleIntegratedTime(newBundle()); !got.IsZero() {
+		t.Errorf("Expected no integration time without log entries, got %v", got)
+	}
+	if got := bundleIntegratedTime(&sgbundle.Bundle{Bundle: &protobundle.Bundle{}}); !got.IsZero() {
+		t.Errorf("Expected no integration time without verification material, got %v", got)
+	}
+}
+
+func TestTagAttestationsCap(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+
+	// Three attestations appended to the legacy tag, oldest first
+	att := empty.Image
+	for _, payload := range []string{"first", "second", "third"} {
+		att, err = mutate.AppendLayers(att, ggcrstatic.NewLayer([]byte(payload), types.MediaType("application/vnd.dsse.envelope.v1+json")))
+		if err != nil {
+			t.Fatalf("Failed to create attestation: %v", err)
+		}
+	}
+	attTag := ref.Context().Tag(strings.Replace(digest.String(), ":", "-", 1) + ".att")
+	if err := remote.Write(attTag, att); err != nil {
+		t.Fatalf("Failed to push attestations: %v", err)
+	}
+
+	attestationCapHits.mu.Lock()
+	before := attestationCapHits.bySource[AttestationSourceTag]
+	attestationCapHits.mu.Unlock()
+
+	verifier := &AttestationVerifier{attestationCap: 2}
+	checkOpts := &cosign.CheckOpts{RegistryClientOpts: []ociremote.Option{ociremote.WithRemoteOptions(remote.WithContext(context.Background()))}}
+	atts, h, err := verifier.tagAttestations(context.Background(), AttestationSourceTag, ref, checkOpts)
+	if err != nil {
+		t.Fatalf("Failed to fetch attestations: %v", err)
+	}
+	if h.String() != digest.String() {
+		t.Errorf("Expected digest %s, got %s", digest, h)
+	}
+
+	sigs, err := atts.Get()
+	if err != nil {
+		t.Fatalf("Failed to get attestations: %v", err)
+	}
+	if len(sigs) != 2 {
+		t.Fatalf("Expected the cap to keep 2 attestations, got %d", len(sigs))
+	}
+	for i, want := range []string{"third", "second"} {
+		if payload, err := sigs[i].Payload(); err != nil || string(payload) != want {
+			t.Errorf("Expected attestation %d to be %q, got %q (%v)", i, want, payload, err)
+		}
+	}
+
+	attestationCapHits.mu.Lock()
+	hits := attestationCapHits.bySource[AttestationSourceTag] - before
+	attestationCapHits.mu.Unlock()
+	if hits != 1 {
+		t.Errorf("Expected 1 cap hit, got %d", hits)
+	}
+
+	var buf bytes.Buffer
+	writeAttestationCapMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_attestation_cap_hits_total{source="tag"}`) {
+		t.Errorf("Expected the cap hit in metrics, got:\n%s", buf.String())
+	}
+
+	// Under the cap every attestation is kept
+	verifier.attestationCap = 0
+	atts, _, err = verifier.tagAttestations(context.Background(), AttestationSourceTag, ref, checkOpts)
+	if err != nil {
+		t.Fatalf("Failed to fetch attestations: %v", err)
+	}
+	if sigs, _ := atts.Get(); len(sigs) != 3 {
+		t.Errorf("Expected all 3 attestations under the default cap, got %d", len(sigs))
+	}
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// attestationRepoWildcard suffixes a mapping source matching every repository below it
+const attestationRepoWildcard = "/*"
+
+// attestationRepository redirects attestation lookups for matching image repositories to a
+// separate repository, like COSIGN_REPOSITORY does for the cosign CLI
+type attestationRepository struct {
+	source   string // Repository name, or registry/namespace prefix for wildcard mappings
+	wildcard bool
+	target   name.Repository
+}
+
+// parseAttestationRepositories parses "source=target" mappings. A source is a repository
+// (e.g. "ghcr.io/org/app") or a prefix ending in "/*" (e.g. "ghcr.io/org/*" or "ghcr.io/*").
+func parseAttestationRepositories(specs []string) ([]attestationRepository, error) {
+	return parseRepositoryMappings(specs, "attestation repository")
+}
+
+// parseRepositoryMappings parses "source=target" repository mappings, most specific first,
+// naming them what in errors
+func parseRepositoryMappings(specs []string, what string) ([]attestationRepository, error) {
+	var mappings []attestationRepository
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		source, target, ok := strings.Cut(spec, "=")
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid %s mapping %q (expected source=target)", what, spec)
+		}
+
+		targetRepo, err := name.NewRepository(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", what, target, err)
+		}
+
+		pattern, wildcard, err := parseRepositoryPattern(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s source %q: %w", what, source, err)
+		}
+		mappings = append(mappings, attestationRepository{source: pattern, wildcard: wildcard, target: targetRepo})
+	}
+
+	// Most specific first: exact repositories, then longer prefixes
+	sort.SliceStable(mappings, func(i, j int) bool {
+		if mappings[i].wildcard != mappings[j].wildcard {
+			return !mappings[i].wildcard
+		}
+		return len(mappings[i].source) > len(mappings[j].source)
+	})
+	return mappings, nil
+}
+
+// parseRepositoryPattern parses a repository (e.g. "ghcr.io/org/app") or a prefix ending in "/*"
+// (e.g. "ghcr.io/org/*" or "ghcr.io/*"), returning the normalized repository or prefix
+func parseRepositoryPattern(pattern string) (string, bool, error) {
+	prefix, wildcard := strings.CutSuffix(pattern, attestationRepoWildcard)
+	if !wildcard {
+		repo, err := name.NewRepository(pattern)
+		if err != nil {
+			return "", false, err
+		}
+		return repo.Name(), false, nil
+	}
+	if !strings.Contains(prefix, "/") {
+		registry, err := name.NewRegistry(prefix)
+		if err != nil {
+			return "", false, err
+		}
+		return registry.Name(), true, nil
+	}
+	repo, err := name.NewRepository(prefix)
+	if err != nil {
+		return "", false, err
+	}
+	return repo.Name(), true, nil
+}
+
+// matches reports whether the mapping applies to repo
+func (m attestationRepository) matches(repo name.Repository) bool {
+	if !m.wildcard {
+		return repo.Name() == m.source
+	}
+	return strings.HasPrefix(repo.Name(), m.source+"/")
+}
+
+// String renders the mapping in its configuration form
+func (m attestationRepository) String() string {
+	if m.wildcard {
+		return m.source + attestationRepoWildcard + "=" + m.target.Name()
+	}
+	return m.source + "=" + m.target.Name()
+}
+
+// attestationRepositoryFor returns the repository holding the attestations of images in repo,
+// if it is mapped to one
+func (v *AttestationVerifier) attestationRepositoryFor(repo name.Repository) (name.Repository, bool) {
+	return mappedRepository(v.attestationRepos, repo)
+}
+
+// mappedRepository returns the target of the most specific of mappings matching repo
+func mappedRepository(mappings []attestationRepository, repo name.Repository) (name.Repository, bool) {
+	for _, m := range mappings {
+		if m.matches(repo) {
+			return m.target, true
+		}
+	}
+	return name.Repository{}, false
+}
+
+// attestationReposPolicy renders the mappings for the verification policy hash
+func (v *AttestationVerifier) attestationReposPolicy() string {
+	return repositoryMappingsPolicy(v.attestationRepos)
+}
+
+// repositoryMappingsPolicy renders mappings in their configuration form
+func repositoryMappingsPolicy(mappings []attestationRepository) string {
+	specs := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		specs = append(specs, m.String())
+	}
+	return strings.Join(specs, ",")
+}
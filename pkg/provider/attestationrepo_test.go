@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestAttestationRepositoryFor(t *testing.T) {
+	mappings, err := parseAttestationRepositories([]string{
+		"ghcr.io/*=ghcr.io/shared/attestations",
+		"ghcr.io/org/*=ghcr.io/org/attestations",
+		"ghcr.io/org/app=ghcr.io/org/app-attestations",
+		"nginx=registry.example.com/mirror/nginx-sigs",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse mappings: %v", err)
+	}
+	verifier := &AttestationVerifier{attestationRepos: mappings}
+
+	tests := []struct {
+		repo string
+		want string // "" when unmapped
+	}{
+		{"ghcr.io/org/app", "ghcr.io/org/app-attestations"},
+		{"ghcr.io/org/other", "ghcr.io/org/attestations"},
+		{"ghcr.io/org/team/svc", "ghcr.io/org/attestations"},
+		{"ghcr.io/elsewhere/app", "ghcr.io/shared/attestations"},
+		{"ghcr.io/orgx/app", "ghcr.io/shared/attestations"},
+		{"index.docker.io/library/nginx", "registry.example.com/mirror/nginx-sigs"},
+		{"quay.io/org/app", ""},
+	}
+
+	for _, tt := range tests {
+		repo, err := name.NewRepository(tt.repo)
+		if err != nil {
+			t.Fatalf("Failed to parse repository %s: %v", tt.repo, err)
+		}
+		target, ok := verifier.attestationRepositoryFor(repo)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Expected %s to be unmapped, got %s", tt.repo, target)
+			}
+			continue
+		}
+		if !ok || target.Name() != tt.want {
+			t.Errorf("Expected %s to map to %s, got %s (mapped: %v)", tt.repo, tt.want, target, ok)
+		}
+	}
+}
+
+func TestParseAttestationRepositoriesInvalid(t *testing.T) {
+	for _, spec := range []string{"ghcr.io/org/app", "=ghcr.io/sigs", "ghcr.io/org/app=", "ghcr.io/org/app=UPPER/case"} {
+		if _, err := parseAttestationRepositories([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestAttestationRepositoriesPolicyHash(t *testing.T) {
+	unmapped := &AttestationVerifier{}
+	mappings, err := parseAttestationRepositories([]string{"ghcr.io/org/*=ghcr.io/org/attestations"})
+	if err != nil {
+		t.Fatalf("Failed to parse mappings: %v", err)
+	}
+	mapped := &AttestationVerifier{attestationRepos: mappings}
+
+	if mapped.PolicyHashFor("", "") == unmapped.PolicyHashFor("", "") {
+		t.Error("Expected attestation repository mappings to change the policy hash")
+	}
+	if got := mapped.attestationReposPolicy(); got != "ghcr.io/org/*=ghcr.io/org/attestations" {
+		t.Errorf("Expected mapping 'ghcr.io/org/*=ghcr.io/org/attestations', got '%s'", got)
+	}
+}
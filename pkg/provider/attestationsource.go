@@ -0,0 +1,323 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// Attestation sources, tried in the configured order until one yields a verified SBOM
+const (
+	// AttestationSourceReferrers discovers attestations through the OCI 1.1 referrers API
+	AttestationSourceReferrers = "referrers"
+	// AttestationSourceTag reads cosign's legacy sha256-<digest>.att tag next to the image
+	AttestationSourceTag = "tag"
+	// AttestationSourceRepository reads the legacy tag in the repository mapped by AttestationRepositories
+	AttestationSourceRepository = "repository"
+	// AttestationSourceRekor searches the Rekor transparency log by image digest
+	AttestationSourceRekor = "rekor"
+	// AttestationSourceBuildx reads the attestation manifest docker buildx --attest adds to the
+	// image index, trusted through a cosign signature of that manifest
+	AttestationSourceBuildx = "buildx"
+	// AttestationSourceOrigin reads the attestations of the same digest in the repository the
+	// image was copied from, mapped by OriginRepositories
+	AttestationSourceOrigin = "origin"
+	// AttestationSourceAttached reads the SBOM cosign attach sbom stores on the legacy
+	// sha256-<digest>.sbom tag, trusted through a cosign signature of that SBOM
+	AttestationSourceAttached = "attached"
+)
+
+// verifiedAttestation is a verified in-toto statement, as stored (possibly DSSE-wrapped)
+type verifiedAttestation struct {
+	payload       []byte
+	signedAt      time.Time          // Transparency log integration time, zero when not logged
+	certNotBefore time.Time          // Start of the signing certificate's validity, zero when key-signed
+	unverified    bool               // Unsigned BuildKit or attached SBOM kept for inventory, see UnsignedSBOMsInventory
+	verifier      signature.Verifier // Key the statement was verified with, nil when unknown, see checkDSSESignature
+}
+
+// signatureAttestations returns the payloads of verified attestations, their log and
+// certificate times and the keys they were verified with: their certificate's, or keyVerifier
+// for key-signed ones
+func signatureAttestations(sigs []oci.Signature, keyVerifier signature.Verifier) []verifiedAttestation {
+	atts := make([]verifiedAttestation, 0, len(sigs))
+	for _, sig := range sigs {
+		payload, err := sig.Payload()
+		if err != nil {
+			continue
+		}
+		att := verifiedAttestation{payload: payload, verifier: keyVerifier}
+		if cert, err := sig.Cert(); err == nil {
+			att.verifier = certVerifier(cert, keyVerifier)
+			if cert != nil {
+				att.certNotBefore = cert.NotBefore
+			}
+		}
+		if bundle, err := sig.Bundle(); err == nil && bundle != nil {
+			att.signedAt = time.Unix(bundle.Payload.IntegratedTime, 0)
+		}
+		atts = append(atts, att)
+	}
+	return atts
+}
+
+// errSourceNotApplicable is returned by sources that do not apply to an image, which are skipped
+var errSourceNotApplicable = errors.New("source not applicable")
+
+// errNoAttestations is returned by sources that found no attestation for an image at all, as
+// opposed to attestations that failed verification
+var errNoAttestations = errors.New("no attestations found")
+
+// parseAttestationSources validates a source order, dropping empty and duplicate entries
+func parseAttestationSources(sources []string) ([]string, error) {
+	var order []string
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		src = strings.TrimSpace(src)
+		if src == "" || seen[src] {
+			continue
+		}
+		switch src {
+		case AttestationSourceReferrers, AttestationSourceTag, AttestationSourceRepository, AttestationSourceRekor, AttestationSourceBuildx, AttestationSourceOrigin, AttestationSourceAttached:
+		default:
+			return nil, fmt.Errorf("unknown attestation source %q (expected %s, %s, %s, %s, %s, %s or %s)", src,
+				AttestationSourceReferrers, AttestationSourceTag, AttestationSourceRepository, AttestationSourceRekor, AttestationSourceBuildx, AttestationSourceOrigin, AttestationSourceAttached)
+		}
+		seen[src] = true
+		order = append(order, src)
+	}
+	return order, nil
+}
+
+// parseRegistryAttestationSources parses per-registry source orders given as
+// "registry=source,source" entries, keyed by normalized registry
+func parseRegistryAttestationSources(specs []string) (map[string][]string, error) {
+	orders := make(map[string][]string)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		registry, list, ok := strings.Cut(spec, "=")
+		if !ok || registry == "" {
+			return nil, fmt.Errorf("invalid registry attestation sources %q (expected registry=source,source)", spec)
+		}
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry %q: %w", registry, err)
+		}
+		order, err := parseAttestationSources(strings.Split(list, ","))
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %w", registry, err)
+		}
+		if len(order) == 0 {
+			return nil, fmt.Errorf("registry %s: no attestation sources", registry)
+		}
+		orders[reg.RegistryStr()] = order
+	}
+	return orders, nil
+}
+
+// defaultAttestationSources is the source order used when none is configured, matching the
+// enabled features: referrers (with UseReferrers), the mapped repository, the legacy tag, the
+// origin repository, Rekor (with RekorSearchFallback) and attached SBOMs (with
+// AttachedSBOMFallback)
+func defaultAttestationSources(useReferrers, mappedRepositories, originRepositories, rekorSearch, attachedSBOMs bool) []string {
+	var order []string
+	if useReferrers {
+		order = append(order, AttestationSourceReferrers)
+	}
+	if mappedRepositories {
+		order = append(order, AttestationSourceRepository)
+	}
+	order = append(order, AttestationSourceTag)
+	if originRepositories {
+		order = append(order, AttestationSourceOrigin)
+	}
+	if rekorSearch {
+		order = append(order, AttestationSourceRekor)
+	}
+	if attachedSBOMs {
+		order = append(order, AttestationSourceAttached)
+	}
+	return order
+}
+
+// attestationSourcesFor returns the source order for images hosted on registry
+func (v *AttestationVerifier) attestationSourcesFor(registry string) []string {
+	if order, ok := v.registrySources[registry]; ok {
+		return order
+	}
+	return v.attestationSources
+}
+
+// usesAttestationSource reports whether source appears in any configured source order
+func (v *AttestationVerifier) usesAttestationSource(source string) bool {
+	orders := [][]string{v.attestationSources}
+	for _, order := range v.registrySources {
+		orders = append(orders, order)
+	}
+	for _, order := range orders {
+		for _, src := range order {
+			if src == source {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attestationSourcesPolicy renders explicitly configured source orders for the verification
+// policy hash. Default orders follow other policy fields and render empty.
+func (v *AttestationVerifier) attestationSourcesPolicy() string {
+	if !v.explicitSources {
+		return ""
+	}
+
+	registries := make([]string, 0, len(v.registrySources))
+	for registry := range v.registrySources {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	parts := []string{strings.Join(v.attestationSources, ",")}
+	for _, registry := range registries {
+		parts = append(parts, registry+"="+strings.Join(v.registrySources[registry], ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// fetchAttestations returns the verified in-toto statements of ref from a single source
+func (v *AttestationVerifier) fetchAttestations(ctx context.Context, source string, ref name.Reference, checkOpts *cosign.CheckOpts, keychain authn.Keychain) ([]verifiedAttestation, error) {
+	opts := *checkOpts
+	opts.RegistryClientOpts = append([]ociremote.Option(nil), checkOpts.RegistryClientOpts...)
+
+	switch source {
+	case AttestationSourceReferrers:
+		opts.ExperimentalOCI11 = true
+		opts.NewBundleFormat = true
+		return v.registryAttestations(ctx, source, ref, &opts)
+
+	case AttestationSourceTag:
+		return v.registryAttestations(ctx, source, ref, &opts)
+
+	case AttestationSourceRepository:
+		repo, ok := v.attestationRepositoryFor(ref.Context())
+		if !ok {
+			return nil, errSourceNotApplicable
+		}
+		tracef(ctx, "attestations for %s are stored in %s", ref.Context(), repo)
+		opts.RegistryClientOpts = append(opts.RegistryClientOpts, ociremote.WithTargetRepository(repo))
+		return v.registryAttestations(ctx, source, ref, &opts)
+
+	case AttestationSourceRekor:
+		if v.rekorClient == nil {
+			return nil, errSourceNotApplicable
+		}
+		// The registry may have lost the attestations (e.g. stripped on mirror), try the log
+		digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+		if err != nil {
+			return nil, classifyRegistryAuthError(err, ref, keychain)
+		}
+
+		var atts []verifiedAttestation
+		err = v.verifyWithTrustedRoots(&opts, func(opts *cosign.CheckOpts) error {
+			var err error
+			atts, err = v.searchRekorAttestations(ctx, digest, opts)
+			return err
+		})
+		return atts, err
+
+	case AttestationSourceBuildx:
+		return v.buildxAttestations(ctx, ref, &opts, keychain)
+
+	case AttestationSourceOrigin:
+		return v.originAttestations(ctx, ref, checkOpts, keychain)
+
+	case AttestationSourceAttached:
+		return v.attachedSBOM(ctx, ref, &opts, keychain)
+	}
+	return nil, fmt.Errorf("unknown attestation source %q", source)
+}
+
+// registryAttestations verifies the newest attestations of ref stored in a registry, up to the
+// attestation cap, against each cached trusted root (fetched at startup)
+func (v *AttestationVerifier) registryAttestations(ctx context.Context, source string, ref name.Reference, checkOpts *cosign.CheckOpts) ([]verifiedAttestation, error) {
+	var verified []verifiedAttestation
+	collect := func(atts []verifiedAttestation) error {
+		// Entries logged "in the future" indicate the node clock is behind
+		for _, att := range atts {
+			if !att.signedAt.IsZero() {
+				if err := v.checkIntegratedTime(att.signedAt); err != nil {
+					return err
+				}
+			}
+		}
+		verified = atts
+		return nil
+	}
+
+	if checkOpts.NewBundleFormat {
+		bundles, artifacts, h, err := v.referrerBundles(ctx, ref, checkOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(bundles) > 0 {
+			err = v.verifyWithTrustedRoots(checkOpts, func(opts *cosign.CheckOpts) error {
+				atts, err := verifyBundles(ctx, bundles, h, opts)
+				if err != nil {
+					return err
+				}
+				return collect(atts)
+			})
+			if err != nil && len(artifacts) == 0 {
+				return nil, err
+			}
+		}
+		if len(artifacts) == 0 {
+			return verified, nil
+		}
+
+		// SBOM artifacts come after the attestations, which are preferred
+		sboms, artifactErr := v.referrerSBOMArtifacts(ctx, ref.Context().Digest(h.String()), artifacts, checkOpts)
+		if artifactErr != nil && len(verified) == 0 {
+			if err != nil {
+				return nil, fmt.Errorf("%w; SBOM artifacts: %v", err, artifactErr)
+			}
+			return nil, artifactErr
+		}
+		if err != nil {
+			tracef(ctx, "referrer bundles failed, using SBOM artifacts: %v", err)
+			recordPartialFailures(ctx, len(bundles), err)
+		}
+		return append(verified, sboms...), nil
+	}
+
+	atts, h, err := v.tagAttestations(ctx, source, ref, checkOpts)
+	if err != nil {
+		return nil, err
+	}
+	err = v.verifyWithTrustedRoots(checkOpts, func(opts *cosign.CheckOpts) error {
+		sigs, _, err := cosign.VerifyImageAttestation(ctx, atts, h, opts)
+		if err != nil {
+			return err
+		}
+		if err := collect(signatureAttestations(sigs, opts.SigVerifier)); err != nil {
+			return err
+		}
+		recordFailedSignatureAttestations(ctx, atts, sigs, h, opts)
+		return nil
+	})
+	return verified, err
+}
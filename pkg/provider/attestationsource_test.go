@@ -0,0 +1,363 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	ocimutate "github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+)
+
+func TestParseAttestationSources(t *testing.T) {
+	order, err := parseAttestationSources([]string{" tag", "rekor", "", "tag", "referrers"})
+	if err != nil {
+		t.Fatalf("Failed to parse sources: %v", err)
+	}
+	if want := []string{"tag", "rekor", "referrers"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected %v, got %v", want, order)
+	}
+
+	if _, err := parseAttestationSources([]string{"tag", "bundles"}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+}
+
+func TestParseRegistryAttestationSources(t *testing.T) {
+	orders, err := parseRegistryAttestationSources([]string{"ghcr.io=referrers,tag", " docker.io=rekor ", ""})
+	if err != nil {
+		t.Fatalf("Failed to parse registry sources: %v", err)
+	}
+	if want := []string{"referrers", "tag"}; !reflect.DeepEqual(orders["ghcr.io"], want) {
+		t.Errorf("Expected ghcr.io order %v, got %v", want, orders["ghcr.io"])
+	}
+	if want := []string{"rekor"}; !reflect.DeepEqual(orders["index.docker.io"], want) {
+		t.Errorf("Expected index.docker.io order %v, got %v", want, orders["index.docker.io"])
+	}
+
+	for _, spec := range []string{"ghcr.io", "ghcr.io=", "=tag", "ghcr.io=unknown"} {
+		if _, err := parseRegistryAttestationSources([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestDefaultAttestationSources(t *testing.T) {
+	tests := []struct {
+		referrers, mapped, origin, rekor, attached bool
+		want                                       []string
+	}{
+		{false, false, false, false, false, []string{"tag"}},
+		{true, false, false, false, false, []string{"referrers", "tag"}},
+		{true, true, true, true, true, []string{"referrers", "repository", "tag", "origin", "rekor", "attached"}},
+		{false, false, false, true, false, []string{"tag", "rekor"}},
+		{false, false, true, false, false, []string{"tag", "origin"}},
+		{false, false, false, false, true, []string{"tag", "attached"}},
+	}
+
+	for _, tt := range tests {
+		got := defaultAttestationSources(tt.referrers, tt.mapped, tt.origin, tt.rekor, tt.attached)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("defaultAttestationSources(%v, %v, %v, %v, %v): expected %v, got %v", tt.referrers, tt.mapped, tt.origin, tt.rekor, tt.attached, tt.want, got)
+		}
+	}
+}
+
+func TestAttestationSourcesFor(t *testing.T) {
+	verifier := &AttestationVerifier{
+		attestationSources: []string{"tag"},
+		registrySources:    map[string][]string{"ghcr.io": {"referrers", "rekor"}},
+	}
+
+	if got := verifier.attestationSourcesFor("ghcr.io"); !reflect.DeepEqual(got, []string{"referrers", "rekor"}) {
+		t.Errorf("Expected the ghcr.io override, got %v", got)
+	}
+	if got := verifier.attestationSourcesFor("quay.io"); !reflect.DeepEqual(got, []string{"tag"}) {
+		t.Errorf("Expected the default order, got %v", got)
+	}
+	if !verifier.usesAttestationSource(AttestationSourceRekor) {
+		t.Error("Expected rekor to be used through the ghcr.io override")
+	}
+	if verifier.usesAttestationSource(AttestationSourceRepository) {
+		t.Error("Expected repository not to be used")
+	}
+}
+
+func TestAttestationSourcesPolicy(t *testing.T) {
+	derived := &AttestationVerifier{attestationSources: []string{"tag"}}
+	if got := derived.attestationSourcesPolicy(); got != "" {
+		t.Errorf("Expected derived orders to render empty, got '%s'", got)
+	}
+
+	explicit := &AttestationVerifier{
+		attestationSources: []string{"tag"},
+		registrySources:    map[string][]string{"quay.io": {"rekor"}, "ghcr.io": {"referrers", "tag"}},
+		explicitSources:    true,
+	}
+	if got, want := explicit.attestationSourcesPolicy(), "tag;ghcr.io=referrers,tag;quay.io=rekor"; got != want {
+		t.Errorf("Expected '%s', got '%s'", want, got)
+	}
+	if explicit.PolicyHashFor("", "") == derived.PolicyHashFor("", "") {
+		t.Error("Expected explicit source orders to change the policy hash")
+	}
+}
+
+func TestFetchAttestationsNotApplicable(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	ref, err := name.ParseReference("ghcr.io/org/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+
+	for _, source := range []string{AttestationSourceRepository, AttestationSourceRekor, AttestationSourceOrigin} {
+		_, err := verifier.fetchAttestations(context.Background(), source, ref, &cosign.CheckOpts{}, nil)
+		if !errors.Is(err, errSourceNotApplicable) {
+			t.Errorf("Expected %s to be skipped, got %v", source, err)
+		}
+	}
+}
+
+func TestJoinSourceErrors(t *testing.T) {
+	plain := fmt.Errorf("referrers: not found")
+	coded := fmt.Errorf("tag: %w", newVerificationError(ErrCodeRegistryAuth, "401 Unauthorized"))
+
+	err := joinSourceErrors([]error{plain, coded})
+	if ErrorCode(err) != ErrCodeRegistryAuth {
+		t.Errorf("Expected code %s, got '%s'", ErrCodeRegistryAuth, ErrorCode(err))
+	}
+	if !strings.Contains(err.Error(), "referrers: not found") || !strings.Contains(err.Error(), "tag: 401 Unauthorized") {
+		t.Errorf("Expected all source errors in the message, got '%s'", err)
+	}
+
+	if err := joinSourceErrors([]error{plain}); err != plain {
+		t.Errorf("Expected a single error to be returned as is, got '%v'", err)
+	}
+}
+
+// signedEntryTimestamp signs payload the way Rekor signs the SET of a log entry
+func signedEntryTimestamp(t *testing.T, key *ecdsa.PrivateKey, payload bundle.RekorPayload) []byte {
+	t.Helper()
+	// Map keys marshal sorted, which is the canonical JSON the SET is verified over
+	contents, err := json.Marshal(map[string]interface{}{
+		"body":           payload.Body,
+		"integratedTime": payload.IntegratedTime,
+		"logIndex":       payload.LogIndex,
+		"logID":          payload.LogID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	hash := sha256.Sum256(contents)
+	set, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign entry: %v", err)
+	}
+	return set
+}
+
+// rekorBundle returns a bundle for a hashedrekord entry of digest signed by signingKey, logged
+// by rekorKey
+func rekorBundle(t *testing.T, rekorKey, signingKey *ecdsa.PrivateKey, digest string) *bundle.RekorBundle {
+	t.Helper()
+	pubPEM, err := cryptoutils.MarshalPublicKeyToPEM(signingKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	digestBytes, err := hex.DecodeString(digest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, signingKey, digestBytes)
+	if err != nil {
+		t.Fatalf("Failed to sign digest: %v", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": digest}},
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString(sig),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(pubPEM)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+	der, err := cryptoutils.MarshalPublicKeyToDER(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	logID := sha256.Sum256(der)
+	payload := bundle.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: time.Now().Add(-time.Minute).Unix(),
+		LogIndex:       42,
+		LogID:          hex.EncodeToString(logID[:]),
+	}
+	return &bundle.RekorBundle{SignedEntryTimestamp: signedEntryTimestamp(t, rekorKey, payload), Payload: payload}
+}
+
+// pushSignedAttestation pushes an image under repo with an SBOM attestation signed by signer,
+// embedding the bundle newBundle returns for the image digest unless newBundle is nil
+func pushSignedAttestation(t *testing.T, repo string, signer signature.SignerVerifier, newBundle func(digest string) *bundle.RekorBundle) name.Digest {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	tag, err := name.ParseReference(repo + ":v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+	ref := tag.Context().Digest(h.String())
+
+	statement, err := json.Marshal(map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://spdx.dev/Document",
+		"subject":       []map[string]interface{}{{"name": ref.Context().String(), "digest": map[string]string{"sha256": h.Hex}}},
+		"predicate":     map[string]interface{}{"spdxVersion": "SPDX-2.3", "name": "test"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal statement: %v", err)
+	}
+	envelope, err := dsse.WrapSigner(signer, "application/vnd.in-toto+json").SignMessage(bytes.NewReader(statement))
+	if err != nil {
+		t.Fatalf("Failed to sign attestation: %v", err)
+	}
+	var opts []static.Option
+	if newBundle != nil {
+		opts = append(opts, static.WithBundle(newBundle(h.Hex)))
+	}
+	att, err := static.NewAttestation(envelope, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create attestation: %v", err)
+	}
+	se, err := ociremote.SignedEntity(ref)
+	if err != nil {
+		t.Fatalf("Failed to read image: %v", err)
+	}
+	se, err = ocimutate.AttachAttestationToEntity(se, att)
+	if err != nil {
+		t.Fatalf("Failed to attach attestation: %v", err)
+	}
+	if err := ociremote.WriteAttestations(ref.Context(), se); err != nil {
+		t.Fatalf("Failed to push attestation: %v", err)
+	}
+	return ref
+}
+
+func TestOfflineTlogVerification(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Rekor key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Rekor key: %v", err)
+	}
+
+	// The Rekor key is trusted through a custom trusted root, as air-gapped clusters would
+	rekorPEM, err := cryptoutils.MarshalPublicKeyToPEM(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	rekorPub := filepath.Join(t.TempDir(), "rekor.pub")
+	if err := os.WriteFile(rekorPub, rekorPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write Rekor key: %v", err)
+	}
+	trustedRoot, err := loadCustomTrustedRoot(&CustomTrustedRoot{RekorPublicKeys: []string{rekorPub}, RekorURL: "https://rekor.invalid"})
+	if err != nil {
+		t.Fatalf("Failed to load trusted root: %v", err)
+	}
+
+	bundled := pushSignedAttestation(t, host+"/test/bundled", signer, func(digest string) *bundle.RekorBundle {
+		return rekorBundle(t, rekorKey, signingKey, digest)
+	})
+	unbundled := pushSignedAttestation(t, host+"/test/unbundled", signer, nil)
+	forged := pushSignedAttestation(t, host+"/test/forged", signer, func(digest string) *bundle.RekorBundle {
+		return rekorBundle(t, otherKey, signingKey, digest)
+	})
+
+	verify := func(ref name.Digest, offline bool) ([]verifiedAttestation, error) {
+		verifier := &AttestationVerifier{
+			trustedRoots: []namedTrustedRoot{{name: TrustedRootCustom, material: trustedRoot}},
+			offlineTlog:  offline,
+		}
+		checkOpts := &cosign.CheckOpts{
+			ClaimVerifier: cosign.IntotoSubjectClaimVerifier,
+			SigVerifier:   signer,
+			IgnoreSCT:     true,
+			Offline:       verifier.offlineTlog,
+			RekorClient:   verifier.rekorClient,
+		}
+		atts, err := verifier.registryAttestations(context.Background(), AttestationSourceTag, ref, checkOpts)
+		return atts, verifier.classifyFetchError(err, ref, nil)
+	}
+
+	atts, err := verify(bundled, true)
+	if err != nil {
+		t.Fatalf("Expected the bundled attestation to verify offline, got %v", err)
+	}
+	if len(atts) != 1 || atts[0].signedAt.IsZero() {
+		t.Errorf("Expected 1 attestation signed at its bundle's integrated time, got %v", atts)
+	}
+
+	if _, err := verify(unbundled, true); ErrorCode(err) != ErrCodeNoTlogBundle {
+		t.Errorf("Expected %s for an attestation without a bundle, got %v", ErrCodeNoTlogBundle, err)
+	}
+	if _, err := verify(unbundled, false); err == nil || ErrorCode(err) != "" {
+		t.Errorf("Expected an uncoded error without a Rekor client online, got %v", err)
+	}
+	if _, err := verify(forged, true); err == nil || ErrorCode(err) != "" {
+		t.Errorf("Expected a SET by an untrusted log to fail, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// auditPageSize is how many pods a single list call of an audit pass returns, so large
+// clusters are listed in chunks rather than in one response
+const auditPageSize = 500
+
+// auditConcurrency is how many images of an audit pass are verified at once. Verification
+// slots are granted by the audit scheduler, so this only bounds the goroutines waiting for one.
+const auditConcurrency = 4
+
+// auditImage is an image of a running container, keyed like an admission request for its pod
+type auditImage struct {
+	key    string
+	digest string // Digest the image is sharded by
+}
+
+// auditor verifies the images of running pods in the background as audit traffic, caching
+// the results and reporting the images that fail. With several replicas each one verifies
+// only the images whose digest hashes to its shard, so large clusters are audited in parallel
+// without verifying an image twice. A nil auditor never audits.
+type auditor struct {
+	interval time.Duration
+	replicas int // Replicas the images are sharded across
+	index    int // Shard of this replica, from 0 to replicas-1
+	settings KeySettings
+	list     func(ctx context.Context, opts metav1.ListOptions) (*corev1.PodList, error)
+	check    func(key string) Item
+	logf     func(format string, args ...interface{})
+
+	passes, passFailures         atomic.Int64
+	verified, failed, otherShard atomic.Int64 // Images of the last completed pass
+	lastPass                     atomic.Int64 // Unix time of the last completed pass
+}
+
+// newAuditor creates an auditor listing pods with client every interval and verifying the
+// images of shard index of replicas under settings, the JSON KeySettings of the constraint.
+// A negative index is read from the ordinal of a StatefulSet pod's hostname.
+func newAuditor(interval time.Duration, replicas, index int, settings string, client kubernetes.Interface) (*auditor, error) {
+	if client == nil {
+		return nil, fmt.Errorf("kubernetes client not available")
+	}
+	replicas = max(replicas, 1)
+	if index < 0 {
+		if replicas == 1 {
+			index = 0
+		} else {
+			hostname, _ := os.Hostname()
+			ordinal, ok := statefulSetOrdinal(hostname)
+			if !ok {
+				return nil, fmt.Errorf("no audit replica index set and hostname %q has no StatefulSet ordinal", hostname)
+			}
+			index = ordinal
+		}
+	}
+	if index >= replicas {
+		return nil, fmt.Errorf("audit replica index %d is not below the %d audit replicas", index, replicas)
+	}
+
+	a := &auditor{
+		interval: interval,
+		replicas: replicas,
+		index:    index,
+		list: func(ctx context.Context, opts metav1.ListOptions) (*corev1.PodList, error) {
+			return client.CoreV1().Pods("").List(ctx, opts)
+		},
+		logf: log.Printf,
+	}
+	if settings != "" {
+		dec := json.NewDecoder(strings.NewReader(settings))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&a.settings); err != nil {
+			return nil, fmt.Errorf("invalid audit key settings %q: %w", settings, err)
+		}
+	}
+	return a, nil
+}
+
+// statefulSetOrdinal returns the ordinal StatefulSets suffix the names of their pods with
+func statefulSetOrdinal(hostname string) (int, bool) {
+	i := strings.LastIndex(hostname, "-")
+	if i < 0 {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(hostname[i+1:])
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return ordinal, true
+}
+
+// auditShard returns the shard of replicas digest belongs to. The hash only depends on the
+// digest, so every replica agrees on the owner of an image without coordinating.
+func auditShard(digest string, replicas int) int {
+	h := fnv.New32a()
+	h.Write([]byte(digest))
+	return int(h.Sum32() % uint32(max(replicas, 1)))
+}
+
+// Run audits every interval until ctx is done, starting with a pass right away
+func (a *auditor) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.audit(ctx); err != nil && ctx.Err() == nil {
+			a.passFailures.Add(1)
+			log.Printf("Warning: audit failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// audit verifies the running images of this replica's shard once
+func (a *auditor) audit(ctx context.Context) error {
+	images, err := a.images(ctx)
+	if err != nil {
+		return err
+	}
+
+	var own []auditImage
+	for _, img := range images {
+		if auditShard(img.digest, a.replicas) == a.index {
+			own = append(own, img)
+		}
+	}
+
+	var verified, failed atomic.Int64
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, auditConcurrency)
+	for _, img := range own {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if item := a.check(img.key); item.Error != "" {
+				failed.Add(1)
+				a.logf("Audit of %s failed: %s", strings.SplitN(img.key, "|", 2)[0], item.Error)
+			} else {
+				verified.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.verified.Store(verified.Load())
+	a.failed.Store(failed.Load())
+	a.otherShard.Store(int64(len(images) - len(own)))
+	a.lastPass.Store(time.Now().Unix())
+	a.passes.Add(1)
+	log.Printf("Audited %d running images of shard %d/%d (%d failed, %d left to other replicas)",
+		len(own), a.index, a.replicas, failed.Load(), len(images)-len(own))
+	return nil
+}
+
+// images lists the running pods page by page and returns the keys of their images, without
+// duplicates. Containers whose image digest is not reported yet are skipped.
+func (a *auditor) images(ctx context.Context) ([]auditImage, error) {
+	var images []auditImage
+	seen := make(map[string]bool)
+	opts := metav1.ListOptions{FieldSelector: "status.phase=Running", Limit: auditPageSize}
+	for {
+		pods, err := a.list(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		for i := range pods.Items {
+			for _, img := range a.podImages(&pods.Items[i]) {
+				if !seen[img.key] {
+					seen[img.key] = true
+					images = append(images, img)
+				}
+			}
+		}
+		if pods.Continue == "" {
+			return images, nil
+		}
+		opts.Continue = pods.Continue
+	}
+}
+
+// podImages returns the images of the containers of pod, keyed like the policy template keys
+// an admission request for it: with the pod's pull secrets and, when it has some, its namespace
+func (a *auditor) podImages(pod *corev1.Pod) []auditImage {
+	var secrets []string
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		secrets = append(secrets, ref.Name)
+	}
+
+	imageIDs := make(map[string]string)
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses} {
+		for _, status := range statuses {
+			imageIDs[status.Name] = status.ImageID
+		}
+	}
+
+	var images []auditImage
+	add := func(container, image string) {
+		ref, digest, ok := runningImageRef(image, imageIDs[container])
+		if !ok {
+			return
+		}
+		key := a.settings.key(ref, secrets)
+		if len(secrets) > 0 {
+			if strings.Count(key, "|") > 3 {
+				key += ",namespace=" + pod.Namespace
+			} else {
+				key += "|namespace=" + pod.Namespace
+			}
+		}
+		images = append(images, auditImage{key: key, digest: digest})
+	}
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Name, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Name, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		add(c.Name, c.Image)
+	}
+	return images
+}
+
+// runningImageRef returns the reference a running container image is verified under and its
+// digest. Images referenced by digest are kept as written, matching the keys of admission
+// requests; tags are pinned to the digest the kubelet pulled, as DIGEST_MODE=resolve does.
+func runningImageRef(image, imageID string) (string, string, bool) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", "", false
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return image, d.DigestStr(), true
+	}
+
+	// Image IDs are reported as "<repository>@<digest>", sometimes with a runtime scheme
+	i := strings.LastIndex(imageID, "@")
+	if i < 0 {
+		return "", "", false
+	}
+	digest := imageID[i+1:]
+	if _, err := v1.NewHash(digest); err != nil {
+		return "", "", false
+	}
+	return ref.Context().Digest(digest).String(), digest, true
+}
+
+// writeAuditMetrics writes the outcome of the last audit pass in Prometheus text format
+func (a *auditor) writeAuditMetrics(w io.Writer) {
+	if a == nil {
+		return
+	}
+
+	const images = "sbom_provider_audit_images"
+	fmt.Fprintf(w, "# HELP %s Running images of the last audit pass, by result.\n# TYPE %s gauge\n", images, images)
+	fmt.Fprintf(w, "%s{result=\"verified\"} %d\n", images, a.verified.Load())
+	fmt.Fprintf(w, "%s{result=\"failed\"} %d\n", images, a.failed.Load())
+	fmt.Fprintf(w, "%s{result=\"other_shard\"} %d\n", images, a.otherShard.Load())
+
+	const passes = "sbom_provider_audit_passes_total"
+	fmt.Fprintf(w, "# HELP %s Audit passes, by result.\n# TYPE %s counter\n", passes, passes)
+	fmt.Fprintf(w, "%s{result=\"completed\"} %d\n", passes, a.passes.Load())
+	fmt.Fprintf(w, "%s{result=\"failed\"} %d\n", passes, a.passFailures.Load())
+
+	const last = "sbom_provider_audit_last_pass_timestamp_seconds"
+	fmt.Fprintf(w, "# HELP %s When the last audit pass completed.\n# TYPE %s gauge\n", last, last)
+	fmt.Fprintf(w, "%s %d\n", last, a.lastPass.Load())
+
+	const shard = "sbom_provider_audit_shard"
+	fmt.Fprintf(w, "# HELP %s Audit shard of this replica, out of replicas.\n# TYPE %s gauge\n", shard, shard)
+	fmt.Fprintf(w, "%s{replicas=\"%d\"} %d\n", shard, a.replicas, a.index)
+}
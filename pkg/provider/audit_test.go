@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// auditTestPod returns a running pod with a container per image, each reported pulled at digest
+func auditTestPod(namespace, name string, secrets []string, images map[string]string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for _, secret := range secrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+	for image, digest := range images {
+		container := fmt.Sprintf("c%d", len(pod.Spec.Containers))
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container, Image: image})
+		imageID := ""
+		if digest != "" {
+			imageID = "docker.io/library/app@" + digest
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{Name: container, ImageID: imageID})
+	}
+	return pod
+}
+
+func TestRunningImageRef(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	pinned := "sha256:" + strings.Repeat("b", 64)
+	for _, tt := range []struct {
+		image, imageID, ref, digest string
+	}{
+		{"ghcr.io/org/app:v1", "ghcr.io/org/app@" + digest, "ghcr.io/org/app@" + digest, digest},
+		{"nginx", "docker-pullable://nginx@" + digest, "index.docker.io/library/nginx@" + digest, digest},
+		{"ghcr.io/org/app@" + pinned, "ghcr.io/org/app@" + digest, "ghcr.io/org/app@" + pinned, pinned},
+		{"ghcr.io/org/app:v1", "sha256:" + strings.Repeat("c", 64), "", ""},
+		{"ghcr.io/org/app:v1", "", "", ""},
+		{"not a reference", "ghcr.io/org/app@" + digest, "", ""},
+	} {
+		ref, d, ok := runningImageRef(tt.image, tt.imageID)
+		if ref != tt.ref || d != tt.digest || ok != (tt.ref != "") {
+			t.Errorf("runningImageRef(%q, %q): expected %q %q, got %q %q %v", tt.image, tt.imageID, tt.ref, tt.digest, ref, d, ok)
+		}
+	}
+}
+
+func TestStatefulSetOrdinal(t *testing.T) {
+	for hostname, want := range map[string]int{"sbom-provider-0": 0, "sbom-provider-12": 12, "sbom-provider-7d9f-x2k4p": -1, "provider": -1} {
+		ordinal, ok := statefulSetOrdinal(hostname)
+		if ok != (want >= 0) || (ok && ordinal != want) {
+			t.Errorf("statefulSetOrdinal(%q): expected %d, got %d %v", hostname, want, ordinal, ok)
+		}
+	}
+}
+
+func TestNewAuditor(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if _, err := newAuditor(time.Minute, 1, -1, "", nil); err == nil {
+		t.Error("Expected an error without a Kubernetes client")
+	}
+	if _, err := newAuditor(time.Minute, 2, 2, "", client); err == nil {
+		t.Error("Expected an error for a replica index beyond the replicas")
+	}
+	if _, err := newAuditor(time.Minute, 1, -1, `{"certIdentty": "x"}`, client); err == nil {
+		t.Error("Expected an error for unknown key settings")
+	}
+	a, err := newAuditor(time.Minute, 1, -1, `{"certOidcIssuer": "https://token.actions.githubusercontent.com"}`, client)
+	if err != nil || a.index != 0 || a.settings.CertOidcIssuer == "" {
+		t.Errorf("Expected a single replica to audit every image with the settings, got %+v, %v", a, err)
+	}
+}
+
+func TestAuditorShardsImages(t *testing.T) {
+	var pods []*corev1.Pod
+	for i := 0; i < 20; i++ {
+		digest := fmt.Sprintf("sha256:%064x", i)
+		pods = append(pods, auditTestPod("team", fmt.Sprintf("app-%d", i), nil, map[string]string{fmt.Sprintf("ghcr.io/org/app%d:v1", i): digest}))
+	}
+	// Private images are keyed with the pull secrets and namespace, images without a digest yet are skipped
+	pods = append(pods, auditTestPod("payments", "private", []string{"regcred"}, map[string]string{
+		"registry.internal/pay:v2": "sha256:" + strings.Repeat("d", 64),
+		"registry.internal/new:v1": "",
+	}))
+	client := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		if _, err := client.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create pod: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	audited := make(map[string]int)
+	var auditors []*auditor
+	for index := 0; index < 3; index++ {
+		a, err := newAuditor(time.Minute, 3, index, `{"certIdentityRegexp": "^https://github.com/org/"}`, client)
+		if err != nil {
+			t.Fatalf("Failed to create auditor: %v", err)
+		}
+		a.check = func(key string) Item {
+			mu.Lock()
+			defer mu.Unlock()
+			audited[key]++
+			if strings.HasPrefix(key, "ghcr.io/org/app3@") {
+				return Item{Key: key, Error: "ERR_NO_ATTESTATION: no attestation"}
+			}
+			return Item{Key: key, Value: "{}"}
+		}
+		a.logf = t.Logf
+		if err := a.audit(context.Background()); err != nil {
+			t.Fatalf("Audit failed: %v", err)
+		}
+		auditors = append(auditors, a)
+	}
+
+	if len(audited) != 21 {
+		t.Errorf("Expected 21 images audited, got %d: %v", len(audited), audited)
+	}
+	for key, n := range audited {
+		if n != 1 {
+			t.Errorf("Expected %s audited by a single replica, got %d", key, n)
+		}
+		if !strings.Contains(key, "|identityRegexp=") {
+			t.Errorf("Expected %s keyed with the audit key settings", key)
+		}
+	}
+	private := `registry.internal/pay@sha256:` + strings.Repeat("d", 64) + `|["regcred"]|||identityRegexp=%5Ehttps%3A%2F%2Fgithub.com%2Forg%2F,namespace=payments`
+	if audited[private] != 1 {
+		t.Errorf("Expected the private image keyed with its pull secrets and namespace, got %v", audited)
+	}
+
+	var verified, failed, other int64
+	for _, a := range auditors {
+		verified += a.verified.Load()
+		failed += a.failed.Load()
+		other += a.otherShard.Load()
+	}
+	if verified != 20 || failed != 1 || other != 2*21 {
+		t.Errorf("Expected 20 verified, 1 failed and 42 left to other shards, got %d, %d and %d", verified, failed, other)
+	}
+
+	var buf bytes.Buffer
+	auditors[0].writeAuditMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_audit_passes_total{result="completed"} 1`) || !strings.Contains(buf.String(), `sbom_provider_audit_shard{replicas="3"} 0`) {
+		t.Errorf("Expected the audit metrics, got %q", buf.String())
+	}
+}
@@ -0,0 +1,257 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// Annotations buildx sets on the attestation manifests it adds to an image index
+const (
+	buildxReferenceType   = "vnd.docker.reference.type"
+	buildxReferenceDigest = "vnd.docker.reference.digest"
+	buildxAttestationType = "attestation-manifest"
+	buildxPredicateType   = "in-toto.io/predicate-type"
+)
+
+// Handling of BuildKit SBOMs found without a signature covering them
+const (
+	// UnsignedSBOMsFail fails the buildx source, as for any unverified attestation
+	UnsignedSBOMsFail = "fail"
+	// UnsignedSBOMsInventory returns the SBOM flagged unverified, for inventory and audit
+	UnsignedSBOMsInventory = "inventory"
+)
+
+// parseUnsignedSBOMs validates the handling of unsigned BuildKit SBOMs, empty for the default
+func parseUnsignedSBOMs(mode string) (string, error) {
+	switch mode {
+	case "", UnsignedSBOMsFail, UnsignedSBOMsInventory:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid unsigned BuildKit SBOM handling %q (expected %s or %s)", mode, UnsignedSBOMsFail, UnsignedSBOMsInventory)
+}
+
+// unsignedSBOMMode returns how the verification of ctx handles unsigned BuildKit SBOMs: the
+// key's handling, else the provider's
+func (v *AttestationVerifier) unsignedSBOMMode(ctx context.Context) string {
+	if mode := unsignedSBOMs(ctx); mode != "" {
+		return mode
+	}
+	if v.unsignedBuildxSBOMs != "" {
+		return v.unsignedBuildxSBOMs
+	}
+	return UnsignedSBOMsFail
+}
+
+// unsignedSBOMsPolicy describes the handling of unsigned BuildKit SBOMs under opts for the
+// policy hash, empty when they fail
+func (v *AttestationVerifier) unsignedSBOMsPolicy(opts keyOptions) string {
+	mode := v.unsignedBuildxSBOMs
+	if opts.unsignedSBOMs != "" {
+		mode = opts.unsignedSBOMs
+	}
+	if mode == UnsignedSBOMsFail {
+		return ""
+	}
+	return mode
+}
+
+// buildxStatementMediaType is the media type of the in-toto statement layers of an attestation
+// manifest
+const buildxStatementMediaType = "application/vnd.in-toto+json"
+
+// maxBuildxStatementSize bounds an in-toto statement read from an attestation manifest
+const maxBuildxStatementSize = 32 << 20
+
+// imageIndexContextKey carries the image index the verified manifest was selected from
+type imageIndexContextKey struct{}
+
+// withImageIndex returns a context recording that the verified manifest was selected from index
+func withImageIndex(ctx context.Context, index name.Reference) context.Context {
+	return context.WithValue(ctx, imageIndexContextKey{}, index)
+}
+
+// imageIndex returns the image index the verified manifest of ctx was selected from, nil when
+// the reference was verified as given
+func imageIndex(ctx context.Context) name.Reference {
+	index, _ := ctx.Value(imageIndexContextKey{}).(name.Reference)
+	return index
+}
+
+// buildxAttestations returns the SBOM statements BuildKit (docker buildx --attest) stored for
+// ref as an attestation manifest in its image index. The statements are unsigned, so they are
+// only trusted when the attestation manifest or the index carries a cosign signature checkOpts
+// accepts, e.g. from cosign sign on the index, with or without --recursive.
+func (v *AttestationVerifier) buildxAttestations(ctx context.Context, ref name.Reference, checkOpts *cosign.CheckOpts, keychain authn.Keychain) ([]verifiedAttestation, error) {
+	indexRef := imageIndex(ctx)
+	if indexRef == nil {
+		indexRef = ref
+	}
+	desc, err := remote.Get(indexRef, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, classifyRegistryAuthError(err, indexRef, keychain)
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, errSourceNotApplicable
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image index: %w", err)
+	}
+
+	image, err := buildxImageDigest(ref, indexRef, manifest)
+	if err != nil {
+		return nil, err
+	}
+	var attRef name.Digest
+	for _, m := range manifest.Manifests {
+		if m.Annotations[buildxReferenceType] == buildxAttestationType && m.Annotations[buildxReferenceDigest] == image.String() {
+			attRef = indexRef.Context().Digest(m.Digest.String())
+			break
+		}
+	}
+	if attRef.DigestStr() == "" {
+		return nil, fmt.Errorf("%w: image index %s has no attestation manifest for %s", errNoAttestations, indexRef, image)
+	}
+	tracef(ctx, "attestation manifest of %s: %s", image, attRef.DigestStr())
+
+	// The index digest covers the attestation manifest, so a signature of either vouches for it
+	signedAt, err := v.verifyManifestSignature(ctx, []name.Digest{attRef, indexRef.Context().Digest(desc.Digest.String())}, checkOpts)
+	unverified := false
+	if err != nil {
+		err = fmt.Errorf("neither the attestation manifest nor the image index is signed by an accepted signer: %w", err)
+		if _, ok := registryAuthStatus(err); ok || v.unsignedSBOMMode(ctx) != UnsignedSBOMsInventory {
+			return nil, err
+		}
+		log.Printf("Warning: returning the unsigned BuildKit SBOM of %s for inventory: %v", image, err)
+		unverified = true
+	}
+
+	img, err := remote.Image(attRef, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attestation manifest %s: %w", attRef.DigestStr(), err)
+	}
+	attManifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attestation manifest %s: %w", attRef.DigestStr(), err)
+	}
+
+	var atts []verifiedAttestation
+	for i, layer := range attManifest.Layers {
+		if layer.MediaType != buildxStatementMediaType {
+			continue
+		}
+		// Skip provenance and other statements without downloading them
+		if predicateType, ok := layer.Annotations[buildxPredicateType]; ok && sbomFormat(predicateType) == "" {
+			tracef(ctx, "buildx statement %d: %s is not an SBOM predicate", i, predicateType)
+			continue
+		}
+		blob, err := img.LayerByDigest(layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("attestation manifest %s: %w", attRef.DigestStr(), err)
+		}
+		payload, err := readBuildxStatement(blob)
+		if err != nil {
+			return nil, fmt.Errorf("attestation manifest %s: %w", attRef.DigestStr(), err)
+		}
+		// The signature covers the statements, the subject binds them to the image
+		if !statementHasSubjectDigest(payload, image) {
+			tracef(ctx, "buildx statement %d does not name %s as its subject", i, image)
+			continue
+		}
+		atts = append(atts, verifiedAttestation{payload: payload, signedAt: signedAt, unverified: unverified})
+	}
+	return atts[:v.capAttestations(ctx, AttestationSourceBuildx, ref.String(), len(atts))], nil
+}
+
+// buildxImageDigest returns the digest of the image whose attestation manifest is read: ref's
+// when it was selected from the index, else that of the only platform image of the index
+func buildxImageDigest(ref, indexRef name.Reference, manifest *v1.IndexManifest) (v1.Hash, error) {
+	if digest, ok := ref.(name.Digest); ok && ref != indexRef {
+		return v1.NewHash(digest.DigestStr())
+	}
+
+	var images []v1.Hash
+	for _, m := range manifest.Manifests {
+		if m.Annotations[buildxReferenceType] == buildxAttestationType {
+			continue
+		}
+		images = append(images, m.Digest)
+	}
+	switch len(images) {
+	case 0:
+		return v1.Hash{}, fmt.Errorf("%w: image index %s lists no images", errNoAttestations, indexRef)
+	case 1:
+		return images[0], nil
+	}
+	return v1.Hash{}, newVerificationError(ErrCodePlatform, "image index %s holds attestation manifests for %d images, select a platform to verify", indexRef, len(images))
+}
+
+// verifyManifestSignature requires a cosign signature, by a signer checkOpts accepts, of one of
+// refs (e.g. the attestation manifest, then the index listing it), returning the newest log
+// integration time of the verified signatures
+func (v *AttestationVerifier) verifyManifestSignature(ctx context.Context, refs []name.Digest, checkOpts *cosign.CheckOpts) (time.Time, error) {
+	opts := *checkOpts
+	opts.ClaimVerifier = cosign.SimpleClaimVerifier
+	opts.ExperimentalOCI11 = false
+	opts.NewBundleFormat = false
+	if extensions := requiredCertExtensions(ctx); extensions != nil {
+		opts.ClaimVerifier = extensions.claimVerifier(opts.ClaimVerifier)
+	}
+
+	var errs []error
+	for _, ref := range refs {
+		var signedAt time.Time
+		err := v.verifyWithTrustedRoots(&opts, func(opts *cosign.CheckOpts) error {
+			sigs, _, err := cosign.VerifyImageSignatures(ctx, ref, opts)
+			if err != nil {
+				return err
+			}
+			for _, att := range signatureAttestations(sigs, opts.SigVerifier) {
+				if att.signedAt.After(signedAt) {
+					signedAt = att.signedAt
+				}
+			}
+			if !signedAt.IsZero() {
+				return v.checkIntegratedTime(signedAt)
+			}
+			return nil
+		})
+		if err == nil {
+			tracef(ctx, "manifest %s carries an accepted signature", ref.DigestStr())
+			return signedAt, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ref.DigestStr(), err))
+	}
+	return time.Time{}, errors.Join(errs...)
+}
+
+// readBuildxStatement reads an in-toto statement layer, bounded by maxBuildxStatementSize
+func readBuildxStatement(layer v1.Layer) ([]byte, error) {
+	blob, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch statement: %w", err)
+	}
+	defer blob.Close()
+	payload, err := io.ReadAll(io.LimitReader(blob, maxBuildxStatementSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statement: %w", err)
+	}
+	if len(payload) > maxBuildxStatementSize {
+		return nil, fmt.Errorf("statement exceeds %d bytes", maxBuildxStatementSize)
+	}
+	return payload, nil
+}
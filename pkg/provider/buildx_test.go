@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrstatic "github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	ocimutate "github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+)
+
+// buildxAttestationManifest returns an attestation manifest holding an SPDX statement about
+// subject, as buildx --sbom=true creates it
+func buildxAttestationManifest(t *testing.T, subject v1.Hash) v1.Image {
+	t.Helper()
+	statement := fmt.Sprintf(`{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://spdx.dev/Document",
+		"subject": [{"name": "app", "digest": {%q: %q}}],
+		"predicate": {"spdxVersion": "SPDX-2.3", "packages": [{"name": "busybox", "versionInfo": "1.36.1"}]}}`, subject.Algorithm, subject.Hex)
+	att, err := mutate.AppendLayers(empty.Image, ggcrstatic.NewLayer([]byte(statement), types.MediaType(buildxStatementMediaType)))
+	if err != nil {
+		t.Fatalf("Failed to create attestation manifest: %v", err)
+	}
+	return att
+}
+
+// signDigest attaches a cosign signature of h by signer
+func signDigest(t *testing.T, h name.Digest, signer signature.SignerVerifier) {
+	t.Helper()
+	signDigestWithAnnotations(t, h, signer, nil)
+}
+
+// signDigestWithAnnotations attaches a cosign signature of h by signer whose payload carries annotations
+func signDigestWithAnnotations(t *testing.T, h name.Digest, signer signature.SignerVerifier, annotations map[string]interface{}) {
+	t.Helper()
+	p, err := (&payload.Cosign{Image: h, Annotations: annotations}).MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	sig, err := signer.SignMessage(bytes.NewReader(p))
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	ociSig, err := static.NewSignature(p, base64.StdEncoding.EncodeToString(sig))
+	if err != nil {
+		t.Fatalf("Failed to create signature: %v", err)
+	}
+	se, err := ociremote.SignedEntity(h)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if se, err = ocimutate.AttachSignatureToEntity(se, ociSig); err != nil {
+		t.Fatalf("Failed to attach signature: %v", err)
+	}
+	if err := ociremote.WriteSignatures(h.Context(), se); err != nil {
+		t.Fatalf("Failed to push signature: %v", err)
+	}
+}
+
+func TestBuildxAttestations(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+
+	amd64, _ := random.Image(256, 1)
+	arm64, _ := random.Image(256, 1)
+	amd64Digest, _ := amd64.Digest()
+	arm64Digest, _ := arm64.Digest()
+	attestation := func(subject v1.Hash) mutate.IndexAddendum {
+		return mutate.IndexAddendum{Add: buildxAttestationManifest(t, subject), Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{buildxReferenceType: buildxAttestationType, buildxReferenceDigest: subject.String()},
+		}}
+	}
+	push := func(tag string, index v1.ImageIndex) name.Reference {
+		ref, _ := name.ParseReference(host + "/test/app:" + tag)
+		if err := remote.WriteIndex(ref, index); err != nil {
+			t.Fatalf("Failed to push index: %v", err)
+		}
+		return ref
+	}
+
+	single := push("single", mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		attestation(amd64Digest),
+	))
+	if _, err := verifier.buildxAttestations(context.Background(), single, checkOpts, authn.DefaultKeychain); err == nil || !strings.Contains(err.Error(), "neither") {
+		t.Errorf("Expected an unsigned attestation manifest to be rejected, got %v", err)
+	}
+
+	singleIndex, _ := remote.Index(single)
+	manifest, _ := singleIndex.IndexManifest()
+	signDigest(t, single.Context().Digest(manifest.Manifests[1].Digest.String()), signer)
+	atts, err := verifier.buildxAttestations(context.Background(), single, checkOpts, authn.DefaultKeychain)
+	if err != nil || len(atts) != 1 {
+		t.Fatalf("Expected the signed statement, got %d, %v", len(atts), err)
+	}
+	sbom, err := verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || sbom.Format != "spdx" || len(sbom.Packages) != 1 {
+		t.Errorf("Expected the SPDX SBOM of the statement, got %+v, %v", sbom, err)
+	}
+
+	// A signature of the index covers its attestation manifest; provenance statements are skipped
+	provenance, err := mutate.Append(buildxAttestationManifest(t, arm64Digest), mutate.Addendum{
+		Layer:       ggcrstatic.NewLayer([]byte(`{"predicateType": "https://slsa.dev/provenance/v0.2"}`), types.MediaType(buildxStatementMediaType)),
+		Annotations: map[string]string{buildxPredicateType: "https://slsa.dev/provenance/v0.2"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add provenance: %v", err)
+	}
+	signedIndex := push("signed-index", mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		mutate.IndexAddendum{Add: provenance, Descriptor: v1.Descriptor{
+			Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{buildxReferenceType: buildxAttestationType, buildxReferenceDigest: arm64Digest.String()},
+		}},
+	))
+	indexDigest, _ := remote.Head(signedIndex)
+	signDigest(t, signedIndex.Context().Digest(indexDigest.Digest.String()), signer)
+	if atts, err := verifier.buildxAttestations(context.Background(), signedIndex, checkOpts, authn.DefaultKeychain); err != nil || len(atts) != 1 {
+		t.Errorf("Expected the SBOM statement trusted through the index signature, got %d, %v", len(atts), err)
+	}
+
+	multi := push("multi", mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		attestation(amd64Digest),
+	))
+	if _, err := verifier.buildxAttestations(context.Background(), multi, checkOpts, authn.DefaultKeychain); ErrorCode(err) != ErrCodePlatform {
+		t.Errorf("Expected %s without a platform selected, got %v", ErrCodePlatform, err)
+	}
+	ctx := withImageIndex(context.Background(), multi)
+	if _, err := verifier.buildxAttestations(ctx, multi.Context().Digest(arm64Digest.String()), checkOpts, authn.DefaultKeychain); !errors.Is(err, errNoAttestations) {
+		t.Errorf("Expected no attestations for the arm64 image, got %v", err)
+	}
+
+	image, _ := name.ParseReference(host + "/test/app:image")
+	if err := remote.Write(image, amd64); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	if _, err := verifier.buildxAttestations(context.Background(), image, checkOpts, authn.DefaultKeychain); !errors.Is(err, errSourceNotApplicable) {
+		t.Errorf("Expected the source not to apply to a single image, got %v", err)
+	}
+}
+
+func TestUnsignedBuildxSBOMs(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+	verifier.unsignedBuildxSBOMs = UnsignedSBOMsInventory
+
+	img, _ := random.Image(256, 1)
+	digest, _ := img.Digest()
+	ref, _ := name.ParseReference(host + "/test/app:v1")
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: buildxAttestationManifest(t, digest), Descriptor: v1.Descriptor{
+			Annotations: map[string]string{buildxReferenceType: buildxAttestationType, buildxReferenceDigest: digest.String()},
+		}},
+	)
+	if err := remote.WriteIndex(ref, index); err != nil {
+		t.Fatalf("Failed to push index: %v", err)
+	}
+
+	atts, err := verifier.buildxAttestations(context.Background(), ref, checkOpts, authn.DefaultKeychain)
+	if err != nil || len(atts) != 1 || !atts[0].unverified {
+		t.Fatalf("Expected the unsigned statement flagged unverified, got %+v, %v", atts, err)
+	}
+	sbom, err := verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || !sbom.Unverified {
+		t.Errorf("Expected an unverified SBOM, got %+v, %v", sbom, err)
+	}
+
+	// The key's handling takes precedence over the provider's
+	ctx := withKeyOptions(context.Background(), keyOptions{unsignedSBOMs: UnsignedSBOMsFail})
+	if _, err := verifier.buildxAttestations(ctx, ref, checkOpts, authn.DefaultKeychain); err == nil {
+		t.Error("Expected the key to fail unsigned SBOMs")
+	}
+
+	var codes []string
+	for _, v := range evaluateSBOM(sbom, &SARIFRequest{}) {
+		codes = append(codes, v.Code)
+	}
+	if strings.Join(codes, ",") != ViolationUnverifiedSBOM {
+		t.Errorf("Expected %s, got %v", ViolationUnverifiedSBOM, codes)
+	}
+	inventory := &SARIFRequest{KeySettings: KeySettings{UnsignedBuildxSBOMs: UnsignedSBOMsInventory}}
+	if violations := evaluateSBOM(sbom, inventory); len(violations) != 0 {
+		t.Errorf("Expected no violations for an inventory request, got %+v", violations)
+	}
+}
+
+func TestParseUnsignedSBOMs(t *testing.T) {
+	for _, mode := range []string{"", UnsignedSBOMsFail, UnsignedSBOMsInventory} {
+		if _, err := parseUnsignedSBOMs(mode); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", mode, err)
+		}
+	}
+	if _, err := parseUnsignedSBOMs("allow"); err == nil {
+		t.Error("Expected an error for an unknown handling")
+	}
+	if _, opts := splitKeyOptions("ghcr.io/org/app:v1|[]|||unsigned=allow"); opts.unsignedSBOMs != UnsignedSBOMsFail {
+		t.Errorf("Expected an invalid unsigned option to fail unsigned SBOMs, got %q", opts.unsignedSBOMs)
+	}
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey("ghcr.io/org/app:v1|[]|||unsigned=inventory") == verifier.PolicyHashForKey("ghcr.io/org/app:v1|[]||") {
+		t.Error("Expected inventory handling to change the policy hash")
+	}
+}
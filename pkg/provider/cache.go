@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cacheSweepInterval is how often expired entries are swept from a result cache
+const cacheSweepInterval = time.Minute
+
+// cacheEntry is a single cached verification result
+type cacheEntry struct {
+	item       Item
+	expiresAt  time.Time
+	policyHash string // Policy hash the result was verified under
+}
+
+// resultCache is an in-memory TTL cache of verification results keyed by provider key.
+// A nil cache is valid and never holds any entries.
+type resultCache struct {
+	mu         sync.RWMutex
+	entries    map[string]cacheEntry
+	policyHash string  // Current policy hash, entries verified under another one are stale
+	jitter     float64 // Fraction of the TTL entries expire early by at most, see jitteredTTL
+}
+
+// newResultCache creates an empty result cache
+func newResultCache() *resultCache {
+	return &resultCache{
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Get returns the cached item for key if present and not expired
+func (c *resultCache) Get(key string) (Item, bool) {
+	if c == nil {
+		return Item{}, false
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	stale := entry.policyHash != c.policyHash
+	c.mu.RUnlock()
+
+	// Entries verified under a previous policy are left for SetPolicyHash to evict
+	if !ok || stale {
+		return Item{}, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		c.mu.Lock()
+		// Re-check under the write lock in case the entry was refreshed meanwhile
+		if current, ok := c.entries[key]; ok && time.Now().After(current.expiresAt) {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return Item{}, false
+	}
+
+	return entry.item, true
+}
+
+// ExpiresAt returns when the cached item for key expires, if it is present and current
+func (c *resultCache) ExpiresAt(key string) (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.policyHash != c.policyHash || time.Now().After(entry.expiresAt) {
+		return time.Time{}, false
+	}
+	return entry.expiresAt, true
+}
+
+// Set stores item for key for the given TTL, shortened by the cache's jitter. Non-positive
+// TTLs are ignored.
+func (c *resultCache) Set(key string, item Item, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{
+		item:       item,
+		expiresAt:  time.Now().Add(jitteredTTL(ttl, c.jitter)),
+		policyHash: c.policyHash,
+	}
+	c.mu.Unlock()
+}
+
+// SetPolicyHash makes hash the current policy hash and evicts entries verified under
+// any other one, returning how many were evicted
+func (c *resultCache) SetPolicyHash(hash string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.policyHash = hash
+	evicted := 0
+	for key, entry := range c.entries {
+		if entry.policyHash != hash {
+			delete(c.entries, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Evict removes the entries whose key passes filter, returning how many were evicted
+func (c *resultCache) Evict(filter func(key string) bool) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := 0
+	for key := range c.entries {
+		if filter(key) {
+			delete(c.entries, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Sweep removes the expired entries, returning how many were removed. Get only removes the
+// expired entry it reads, so without sweeping every key ever cached would stay in memory.
+func (c *resultCache) Sweep() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	swept := 0
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+			swept++
+		}
+	}
+	return swept
+}
+
+// Run sweeps the expired entries every interval until ctx is done
+func (c *resultCache) Run(ctx context.Context, interval time.Duration) {
+	if c == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.Sweep()
+	}
+}
+
+// Len returns the number of entries currently held, including expired ones not yet evicted
+func (c *resultCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// cacheSnapshotEntry is a cached result as exported to a snapshot
+type cacheSnapshotEntry struct {
+	Key        string    `json:"key"`
+	Item       Item      `json:"item"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	PolicyHash string    `json:"policyHash"`
+}
+
+// Snapshot returns the live, successful entries whose key passes filter
+func (c *resultCache) Snapshot(filter func(key string) bool) []cacheSnapshotEntry {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	var entries []cacheSnapshotEntry
+	for key, entry := range c.entries {
+		if entry.item.Error != "" || entry.item.Value == pendingValue || entry.policyHash != c.policyHash ||
+			now.After(entry.expiresAt) || !filter(key) {
+			continue
+		}
+		entries = append(entries, cacheSnapshotEntry{
+			Key:        key,
+			Item:       entry.item,
+			ExpiresAt:  entry.expiresAt,
+			PolicyHash: entry.policyHash,
+		})
+	}
+	return entries
+}
+
+// Restore loads snapshot entries that are still live and were verified under the current
+// policy, keeping existing entries. It returns how many entries were restored.
+func (c *resultCache) Restore(entries []cacheSnapshotEntry) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	restored := 0
+	for _, e := range entries {
+		if e.PolicyHash != c.policyHash || now.After(e.ExpiresAt) {
+			continue
+		}
+		if _, ok := c.entries[e.Key]; ok {
+			continue
+		}
+		c.entries[e.Key] = cacheEntry{
+			item:       e.Item,
+			expiresAt:  e.ExpiresAt,
+			policyHash: e.PolicyHash,
+		}
+		restored++
+	}
+	return restored
+}
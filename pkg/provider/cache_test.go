@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResultCacheGetSet(t *testing.T) {
+	cache := newResultCache()
+
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("Expected cache miss for unknown key")
+	}
+
+	cache.Set("image:tag", Item{Key: "image:tag", Value: "{}"}, time.Minute)
+
+	item, ok := cache.Get("image:tag")
+	if !ok {
+		t.Fatal("Expected cache hit")
+	}
+
+	if item.Value != "{}" {
+		t.Errorf("Expected value '{}', got '%s'", item.Value)
+	}
+}
+
+func TestResultCacheExpiry(t *testing.T) {
+	cache := newResultCache()
+	cache.Set("image:tag", Item{Key: "image:tag"}, time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.Get("image:tag"); ok {
+		t.Error("Expected expired entry to be a miss")
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Expected expired entry to be evicted, got %d entries", cache.Len())
+	}
+}
+
+func TestResultCacheSweep(t *testing.T) {
+	cache := newResultCache()
+	cache.Set("expired", Item{Key: "expired"}, time.Millisecond)
+	cache.Set("live", Item{Key: "live"}, time.Minute)
+
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired entries are removed without being read again
+	if swept := cache.Sweep(); swept != 1 || cache.Len() != 1 {
+		t.Errorf("Expected 1 expired entry swept and 1 left, got %d swept and %d left", swept, cache.Len())
+	}
+	if _, ok := cache.Get("live"); !ok {
+		t.Error("Expected the live entry to survive the sweep")
+	}
+
+	cache.Set("expired", Item{Key: "expired"}, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Run(ctx, time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected the sweeper to remove the expired entry, got %d entries", cache.Len())
+	}
+}
+
+func TestResultCacheDisabled(t *testing.T) {
+	cache := newResultCache()
+	cache.Set("image:tag", Item{Key: "image:tag"}, 0)
+
+	if _, ok := cache.Get("image:tag"); ok {
+		t.Error("Expected zero TTL to skip caching")
+	}
+
+	var nilCache *resultCache
+	nilCache.Set("image:tag", Item{Key: "image:tag"}, time.Minute)
+	if _, ok := nilCache.Get("image:tag"); ok {
+		t.Error("Expected nil cache to always miss")
+	}
+}
+
+func TestResultCachePolicyHash(t *testing.T) {
+	cache := newResultCache()
+	cache.SetPolicyHash("old")
+	cache.Set("image:tag", Item{Key: "image:tag"}, time.Minute)
+
+	if evicted := cache.SetPolicyHash("old"); evicted != 0 {
+		t.Errorf("Expected unchanged policy hash to keep entries, evicted %d", evicted)
+	}
+
+	if evicted := cache.SetPolicyHash("new"); evicted != 1 {
+		t.Errorf("Expected 1 entry evicted, got %d", evicted)
+	}
+
+	if _, ok := cache.Get("image:tag"); ok {
+		t.Error("Expected entry verified under the old policy to be a miss")
+	}
+
+	cache.Set("image:tag", Item{Key: "image:tag"}, time.Minute)
+	if _, ok := cache.Get("image:tag"); !ok {
+		t.Error("Expected entry verified under the new policy to be a hit")
+	}
+}
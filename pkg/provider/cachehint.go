@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cacheHint returns how long Gatekeeper may cache the item resolved for a provider key.
+// Only successful results of digest references are cacheable: a tag may move, and unlike
+// its own cache the provider cannot evict Gatekeeper's when trust material changes, so the
+// hint never outlives the provider's cache entry for the key.
+func (s *Server) cacheHint(key string, item Item, debug bool) time.Duration {
+	imageRef, opts, err := parseKey(key)
+	imageRef = opts.resultKey(imageRef)
+	if err != nil || debug || opts.debug || item.Error != "" || item.Value == pendingValue || keyDigest(imageRef) == "" {
+		return 0
+	}
+
+	expiresAt, ok := s.cache.ExpiresAt(s.cacheKey(imageRef))
+	if !ok {
+		return 0
+	}
+	return time.Until(expiresAt)
+}
+
+// responseCacheHint returns how long all items of a response may be cached, 0 if any may not
+func (s *Server) responseCacheHint(keys []string, items []Item, debug bool) time.Duration {
+	if len(items) == 0 || len(items) != len(keys) {
+		return 0
+	}
+
+	var hint time.Duration
+	for i, item := range items {
+		ttl := s.cacheHint(keys[i], item, debug)
+		if ttl < time.Second {
+			return 0
+		}
+		if i == 0 || ttl < hint {
+			hint = ttl
+		}
+	}
+	return hint
+}
+
+// setCacheHeaders advertises the cache hint of a response to HTTP clients
+func setCacheHeaders(w http.ResponseWriter, hint time.Duration) {
+	if hint < time.Second {
+		w.Header().Set("Cache-Control", "no-store")
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(hint/time.Second)))
+}
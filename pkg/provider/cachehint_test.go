@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheHint(t *testing.T) {
+	server := &Server{cache: newResultCache()}
+
+	digestKey := "ghcr.io/org/app@" + testDigest + "|[]||"
+	tagKey := "ghcr.io/org/app:v1|[]||"
+	verified := Item{Value: `{"format":"spdx","packages":[]}`}
+	server.cache.Set(server.cacheKey(digestKey), verified, time.Minute)
+	server.cache.Set(server.cacheKey(tagKey), verified, time.Minute)
+
+	if hint := server.cacheHint(digestKey, verified, false); hint <= 50*time.Second || hint > time.Minute {
+		t.Errorf("Expected the remaining cache TTL for a digest key, got %v", hint)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		item  Item
+		debug bool
+	}{
+		{"tag reference", tagKey, verified, false},
+		{"error", digestKey, Item{Error: "boom"}, false},
+		{"pending", digestKey, Item{Value: pendingValue}, false},
+		{"debug request", digestKey, verified, true},
+		{"debug key", digestKey + "|debug=true", verified, false},
+		{"uncached", "ghcr.io/org/other@" + testDigest + "|[]||", verified, false},
+	}
+	for _, tt := range tests {
+		if hint := server.cacheHint(tt.key, tt.item, tt.debug); hint != 0 {
+			t.Errorf("%s: expected no cache hint, got %v", tt.name, hint)
+		}
+	}
+}
+
+func TestResponseCacheHint(t *testing.T) {
+	server := &Server{cache: newResultCache()}
+
+	short := "ghcr.io/org/a@" + testDigest
+	long := "ghcr.io/org/b@" + testDigest
+	verified := Item{Value: `{"format":"spdx","packages":[]}`}
+	server.cache.Set(server.cacheKey(short), verified, 30*time.Second)
+	server.cache.Set(server.cacheKey(long), verified, time.Hour)
+
+	hint := server.responseCacheHint([]string{long, short}, []Item{verified, verified}, false)
+	if hint <= 20*time.Second || hint > 30*time.Second {
+		t.Errorf("Expected the shortest item hint, got %v", hint)
+	}
+
+	if hint := server.responseCacheHint([]string{long, "ghcr.io/org/b:v1"}, []Item{verified, verified}, false); hint != 0 {
+		t.Errorf("Expected no hint when an item is not cacheable, got %v", hint)
+	}
+	if hint := server.responseCacheHint(nil, nil, false); hint != 0 {
+		t.Errorf("Expected no hint for an empty response, got %v", hint)
+	}
+
+	w := httptest.NewRecorder()
+	setCacheHeaders(w, 90*time.Second)
+	if got := w.Header().Get("Cache-Control"); got != "max-age=90" {
+		t.Errorf("Expected 'max-age=90', got '%s'", got)
+	}
+	w = httptest.NewRecorder()
+	setCacheHeaders(w, 0)
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Expected 'no-store', got '%s'", got)
+	}
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// DefaultCacheTTLJitter is the fraction of CacheTTL cached results expire early by, at most
+const DefaultCacheTTLJitter = 0.1
+
+// cacheRefreshWorkers is the number of background workers refreshing cached results
+const cacheRefreshWorkers = 2
+
+// jitteredTTL shortens ttl by a random fraction of it up to jitter, so results cached together,
+// e.g. during a mass rollout, do not all expire at once
+func jitteredTTL(ttl time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(rand.Float64()*min(jitter, 1)*float64(ttl))
+}
+
+// cacheRefresher re-verifies cached results in the background once a request hits them close
+// to their expiry, so keys in use keep being answered from the cache rather than re-verified
+// inline by the admission request that finds them expired. Failed refreshes leave the cached
+// result to expire. A nil refresher never refreshes.
+type cacheRefresher struct {
+	window  time.Duration // Remaining lifetime below which a hit triggers a refresh
+	queue   workqueue.TypedInterface[string]
+	verify  func(key string) Item
+	expires func(key string) (time.Time, bool)
+	store   func(key string, item Item)
+
+	refreshed, failed atomic.Int64
+}
+
+// newCacheRefresher creates a refresher of results cached for ttl, refreshing them within the
+// last ahead fraction of their ttl, or nil when ahead or ttl is not positive
+func newCacheRefresher(ahead float64, ttl time.Duration, verify func(key string) Item, expires func(key string) (time.Time, bool), store func(key string, item Item)) *cacheRefresher {
+	if ahead <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &cacheRefresher{
+		window: time.Duration(min(ahead, 1) * float64(ttl)),
+		queue: workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{
+			Name: "sbom-cache-refresh",
+		}),
+		verify:  verify,
+		expires: expires,
+		store:   store,
+	}
+}
+
+// Touch schedules a refresh of key when its cached result is about to expire. Keys already
+// waiting are deduplicated by the workqueue.
+func (r *cacheRefresher) Touch(key string) {
+	if r != nil && r.due(key) {
+		r.queue.Add(key)
+	}
+}
+
+// due reports whether the cached result of key expires within the refresh window
+func (r *cacheRefresher) due(key string) bool {
+	expiresAt, ok := r.expires(key)
+	return ok && time.Until(expiresAt) < r.window
+}
+
+// Run starts the refresh workers. It returns immediately.
+func (r *cacheRefresher) Run(workers int) {
+	if r == nil {
+		return
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			for r.processNextItem() {
+			}
+		}()
+	}
+}
+
+// ShutDown stops the workers once in-flight refreshes are done
+func (r *cacheRefresher) ShutDown() {
+	if r != nil {
+		r.queue.ShutDown()
+	}
+}
+
+// processNextItem refreshes a single queued key, returning false when the queue is shut down
+func (r *cacheRefresher) processNextItem() bool {
+	key, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(key)
+
+	// The result may have been refreshed, or dropped, while the key was waiting
+	if !r.due(key) {
+		return true
+	}
+
+	item := r.verify(key)
+	if item.Error != "" {
+		r.failed.Add(1)
+		log.Printf("Cache refresh failed for %s, keeping the cached result until it expires: %s", key, item.Error)
+		return true
+	}
+	r.store(key, item)
+	r.refreshed.Add(1)
+	return true
+}
+
+// writeCacheRefreshMetrics writes the cache refresh counters in Prometheus text format
+func (r *cacheRefresher) writeCacheRefreshMetrics(w io.Writer) {
+	if r == nil {
+		return
+	}
+
+	const name = "sbom_provider_cache_refreshes_total"
+	fmt.Fprintf(w, "# HELP %s Cached results re-verified in the background before expiring, by result.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{result=\"refreshed\"} %d\n", name, r.refreshed.Load())
+	fmt.Fprintf(w, "%s{result=\"failed\"} %d\n", name, r.failed.Load())
+}
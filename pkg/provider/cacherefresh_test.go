@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJitteredTTL(t *testing.T) {
+	if got := jitteredTTL(time.Hour, 0); got != time.Hour {
+		t.Errorf("Expected no jitter, got %v", got)
+	}
+
+	cache := newResultCache()
+	cache.jitter = 0.5
+	expiries := make(map[time.Time]bool)
+	for i := 0; i < 50; i++ {
+		key := strings.Repeat("k", i+1)
+		cache.Set(key, Item{Key: key}, time.Hour)
+		expiresAt, ok := cache.ExpiresAt(key)
+		if remaining := time.Until(expiresAt); !ok || remaining > time.Hour || remaining < 30*time.Minute-time.Second {
+			t.Fatalf("Expected an expiry between 30 and 60 minutes away, got %v", remaining)
+		}
+		expiries[expiresAt] = true
+	}
+	if len(expiries) < 40 {
+		t.Errorf("Expected spread expiries, got %d distinct of 50", len(expiries))
+	}
+}
+
+func TestCacheRefresher(t *testing.T) {
+	if newCacheRefresher(0, time.Hour, nil, nil, nil) != nil || newCacheRefresher(0.2, 0, nil, nil, nil) != nil {
+		t.Error("Expected refresh to be disabled")
+	}
+	var disabled *cacheRefresher
+	disabled.Touch("nginx")
+	disabled.Run(1)
+	disabled.ShutDown()
+
+	cache := newResultCache()
+	var mu sync.Mutex
+	verified := make(map[string]int)
+	fail := false
+	refresher := newCacheRefresher(0.2, time.Hour, func(key string) Item {
+		mu.Lock()
+		defer mu.Unlock()
+		verified[key]++
+		if fail {
+			return Item{Key: key, Error: "ERR_REGISTRY_AUTH: 401"}
+		}
+		return Item{Key: key, Value: "refreshed"}
+	}, cache.ExpiresAt, func(key string, item Item) {
+		cache.Set(key, item, time.Hour)
+	})
+	refresher.Run(1)
+	defer refresher.ShutDown()
+
+	cache.Set("fresh", Item{Key: "fresh", Value: "cached"}, time.Hour)
+	cache.Set("expiring", Item{Key: "expiring", Value: "cached"}, 5*time.Minute)
+	cache.Set("failing", Item{Key: "failing", Value: "cached"}, 5*time.Minute)
+	refresher.Touch("fresh")
+	refresher.Touch("expiring")
+	refresher.Touch("missing")
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the refresh")
+			}
+		}
+	}
+	waitFor(func() bool { return refresher.refreshed.Load() == 1 })
+
+	if item, _ := cache.Get("expiring"); item.Value != "refreshed" {
+		t.Errorf("Expected the expiring result to be refreshed, got %+v", item)
+	}
+	if expiresAt, _ := cache.ExpiresAt("expiring"); time.Until(expiresAt) < 50*time.Minute {
+		t.Errorf("Expected the refreshed result to get a full TTL, expires in %v", time.Until(expiresAt))
+	}
+	if item, _ := cache.Get("fresh"); item.Value != "cached" {
+		t.Errorf("Expected the fresh result to be kept, got %+v", item)
+	}
+
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	refresher.Touch("failing")
+	waitFor(func() bool { return refresher.failed.Load() == 1 })
+	if item, ok := cache.Get("failing"); !ok || item.Value != "cached" {
+		t.Errorf("Expected a failed refresh to keep the cached result, got %+v", item)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if verified["fresh"] != 0 || verified["missing"] != 0 || verified["expiring"] != 1 {
+		t.Errorf("Expected only due keys to be verified, got %v", verified)
+	}
+
+	var buf bytes.Buffer
+	refresher.writeCacheRefreshMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_cache_refreshes_total{result="refreshed"} 1`) || !strings.Contains(buf.String(), `sbom_provider_cache_refreshes_total{result="failed"} 1`) {
+		t.Errorf("Unexpected metrics:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultCatalogTimeout bounds a single image catalog lookup
+const DefaultCatalogTimeout = 5 * time.Second
+
+// maxCatalogResponseSize caps catalog responses, which only carry ownership metadata
+const maxCatalogResponseSize = 1 << 20
+
+// ImageOwnership is the registration of an image repository in the internal catalog
+type ImageOwnership struct {
+	Registered bool              `json:"registered"`
+	Team       string            `json:"team,omitempty"`
+	Owners     []string          `json:"owners,omitempty"`   // Contacts, e.g. emails or chat handles
+	Metadata   map[string]string `json:"metadata,omitempty"` // Additional catalog attributes, e.g. cost center or tier
+}
+
+// EntitlementChecker confirms that a verified image is registered to a team. It is consulted
+// after SBOM verification, and its ownership metadata is returned for policies to use.
+type EntitlementChecker interface {
+	// CheckEntitlement returns the ownership of repository, with Registered false for
+	// unknown repositories. Errors mean the registration could not be determined.
+	CheckEntitlement(ctx context.Context, repository, digest string) (*ImageOwnership, error)
+}
+
+// httpCatalog looks images up in an HTTP catalog or CMDB:
+// GET <url>?repository=<repository>&digest=<digest> answers 200 with an ImageOwnership
+// document for registered repositories and 404 for unknown ones.
+type httpCatalog struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newHTTPCatalog creates a catalog client for rawURL, authenticating with a bearer token if set
+func newHTTPCatalog(rawURL, token string, timeout time.Duration) (*httpCatalog, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid catalog URL %q", rawURL)
+	}
+	if timeout <= 0 {
+		timeout = DefaultCatalogTimeout
+	}
+	return &httpCatalog{url: rawURL, token: token, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// CheckEntitlement looks repository up in the catalog
+func (c *httpCatalog) CheckEntitlement(ctx context.Context, repository, digest string) (*ImageOwnership, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("repository", repository)
+	query.Set("digest", digest)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("catalog lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return &ImageOwnership{Registered: false}, nil
+	default:
+		return nil, fmt.Errorf("catalog lookup failed: %s", resp.Status)
+	}
+
+	var ownership ImageOwnership
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCatalogResponseSize)).Decode(&ownership); err != nil {
+		return nil, fmt.Errorf("invalid catalog response: %w", err)
+	}
+	// A catalog entry means the repository is registered, even if the document omits the flag
+	ownership.Registered = true
+	return &ownership, nil
+}
+
+// SetEntitlementChecker replaces the entitlement check run after SBOM verification (nil disables it).
+// It changes the policy hash, so call it before the verifier serves requests.
+func (v *AttestationVerifier) SetEntitlementChecker(checker EntitlementChecker) {
+	v.entitlements = checker
+}
+
+// entitlementsPolicy renders the entitlement check for the verification policy hash
+func (v *AttestationVerifier) entitlementsPolicy() string {
+	switch c := v.entitlements.(type) {
+	case nil:
+		return ""
+	case *httpCatalog:
+		return c.url
+	default:
+		return fmt.Sprintf("%T", c)
+	}
+}
+
+// checkEntitlement attaches the catalog ownership of the verified image to unified
+func (v *AttestationVerifier) checkEntitlement(ctx context.Context, repository, digest string, unified *UnifiedSBOM) error {
+	if v.entitlements == nil {
+		return nil
+	}
+
+	ownership, err := v.entitlements.CheckEntitlement(ctx, repository, digest)
+	if err != nil {
+		return &VerificationError{Code: ErrCodeCatalog, Err: err}
+	}
+	tracef(ctx, "catalog: %s registered=%v team=%q", repository, ownership.Registered, ownership.Team)
+	unified.Ownership = ownership
+	return nil
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPCatalog(t *testing.T) {
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("repository") {
+		case "ghcr.io/org/app":
+			if r.URL.Query().Get("digest") != testDigest {
+				t.Errorf("Expected the image digest in the query, got %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"team": "payments", "owners": ["payments@example.com"], "metadata": {"tier": "1"}}`))
+		case "ghcr.io/org/broken":
+			w.Write([]byte(`not json`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer catalog.Close()
+
+	c, err := newHTTPCatalog(catalog.URL+"/v1/images?source=gatekeeper", "s3cret", 0)
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	ctx := context.Background()
+
+	ownership, err := c.CheckEntitlement(ctx, "ghcr.io/org/app", testDigest)
+	if err != nil {
+		t.Fatalf("Failed to look image up: %v", err)
+	}
+	if !ownership.Registered || ownership.Team != "payments" || len(ownership.Owners) != 1 || ownership.Metadata["tier"] != "1" {
+		t.Errorf("Unexpected ownership %+v", ownership)
+	}
+
+	ownership, err = c.CheckEntitlement(ctx, "ghcr.io/org/unknown", testDigest)
+	if err != nil || ownership.Registered {
+		t.Errorf("Expected an unregistered repository, got %+v (%v)", ownership, err)
+	}
+
+	if _, err := c.CheckEntitlement(ctx, "ghcr.io/org/broken", testDigest); err == nil {
+		t.Error("Expected an error for an invalid catalog response")
+	}
+
+	unauthorized, err := newHTTPCatalog(catalog.URL, "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	if _, err := unauthorized.CheckEntitlement(ctx, "ghcr.io/org/app", testDigest); err == nil {
+		t.Error("Expected an error when the catalog rejects the request")
+	}
+
+	if _, err := newHTTPCatalog("catalog.internal/images", "", 0); err == nil {
+		t.Error("Expected an error for a URL without scheme")
+	}
+}
+
+// staticEntitlements answers every lookup with the same result
+type staticEntitlements struct {
+	ownership *ImageOwnership
+	err       error
+}
+
+func (s staticEntitlements) CheckEntitlement(context.Context, string, string) (*ImageOwnership, error) {
+	return s.ownership, s.err
+}
+
+func TestCheckEntitlement(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	hash := verifier.PolicyHashFor("", "")
+	unified := &UnifiedSBOM{}
+	if err := verifier.checkEntitlement(context.Background(), "ghcr.io/org/app", testDigest, unified); err != nil || unified.Ownership != nil {
+		t.Errorf("Expected no entitlement check by default, got %+v (%v)", unified.Ownership, err)
+	}
+
+	verifier.SetEntitlementChecker(staticEntitlements{ownership: &ImageOwnership{Registered: true, Team: "payments"}})
+	if verifier.PolicyHashFor("", "") == hash {
+		t.Error("Expected the entitlement check to change the policy hash")
+	}
+	if err := verifier.checkEntitlement(context.Background(), "ghcr.io/org/app", testDigest, unified); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if unified.Ownership == nil || unified.Ownership.Team != "payments" {
+		t.Errorf("Expected the ownership to be attached, got %+v", unified.Ownership)
+	}
+
+	verifier.SetEntitlementChecker(staticEntitlements{err: errors.New("catalog unavailable")})
+	if code := ErrorCode(verifier.checkEntitlement(context.Background(), "ghcr.io/org/app", testDigest, &UnifiedSBOM{})); code != ErrCodeCatalog {
+		t.Errorf("Expected %s, got '%s'", ErrCodeCatalog, code)
+	}
+}
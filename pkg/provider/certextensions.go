@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	sgbundle "github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+)
+
+// certExtensionMismatchMarker prefixes the error of a certificate lacking a required extension value
+const certExtensionMismatchMarker = "certificate extension mismatch"
+
+// CertExtensions are the Fulcio certificate extensions the signing certificate must carry, set
+// per constraint. Values are compared exactly; empty fields are not checked.
+type CertExtensions struct {
+	GithubWorkflowRepository string `json:"githubWorkflowRepository,omitempty"` // OID 1.3.6.1.4.1.57264.1.5, e.g. "myorg/app"
+	GithubWorkflowRef        string `json:"githubWorkflowRef,omitempty"`        // OID 1.3.6.1.4.1.57264.1.6, e.g. "refs/heads/main"
+	GithubWorkflowTrigger    string `json:"githubWorkflowTrigger,omitempty"`    // OID 1.3.6.1.4.1.57264.1.2, e.g. "push"
+	RunnerEnvironment        string `json:"runnerEnvironment,omitempty"`        // OID 1.3.6.1.4.1.57264.1.11, "github-hosted" or "self-hosted"
+	BuildSignerURI           string `json:"buildSignerURI,omitempty"`           // OID 1.3.6.1.4.1.57264.1.9
+}
+
+// parseCertExtensions parses the JSON object of the certExtensions key option. Unknown fields
+// are rejected, as a misspelled constraint would otherwise go unchecked.
+func parseCertExtensions(raw string) (*CertExtensions, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var extensions CertExtensions
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&extensions); err != nil {
+		return nil, fmt.Errorf("invalid certExtensions %q: %w", raw, err)
+	}
+	if extensions == (CertExtensions{}) {
+		return nil, nil
+	}
+	return &extensions, nil
+}
+
+// check returns an error unless cert carries every required extension value
+func (e *CertExtensions) check(cert *x509.Certificate) error {
+	if cert == nil {
+		return fmt.Errorf("%s: attestation has no signing certificate", certExtensionMismatchMarker)
+	}
+	actual, err := certificate.ParseExtensions(cert.Extensions)
+	if err != nil {
+		return fmt.Errorf("%s: %w", certExtensionMismatchMarker, err)
+	}
+
+	for _, ext := range []struct{ name, want, got string }{
+		{"githubWorkflowRepository", e.GithubWorkflowRepository, actual.GithubWorkflowRepository},
+		{"githubWorkflowRef", e.GithubWorkflowRef, actual.GithubWorkflowRef},
+		{"githubWorkflowTrigger", e.GithubWorkflowTrigger, actual.GithubWorkflowTrigger},
+		{"runnerEnvironment", e.RunnerEnvironment, actual.RunnerEnvironment},
+		{"buildSignerURI", e.BuildSignerURI, actual.BuildSignerURI},
+	} {
+		if ext.want != "" && ext.got != ext.want {
+			return fmt.Errorf("%s: %s is %q, expected %q", certExtensionMismatchMarker, ext.name, ext.got, ext.want)
+		}
+	}
+	return nil
+}
+
+// claimVerifier wraps a cosign claim verifier to also check the extensions of the signing
+// certificate, which cosign has already verified when it calls the claim verifier
+func (e *CertExtensions) claimVerifier(next func(oci.Signature, v1.Hash, map[string]interface{}) error) func(oci.Signature, v1.Hash, map[string]interface{}) error {
+	return func(sig oci.Signature, h v1.Hash, annotations map[string]interface{}) error {
+		if next != nil {
+			if err := next(sig, h, annotations); err != nil {
+				return err
+			}
+		}
+		cert, err := sig.Cert()
+		if err != nil {
+			return err
+		}
+		return e.check(cert)
+	}
+}
+
+// checkBundleExtensions checks the signing certificate of a verified bundle against the
+// extensions the verification of ctx requires. cosign does not call the claim verifier for
+// bundles.
+func checkBundleExtensions(ctx context.Context, bundle *sgbundle.Bundle) error {
+	extensions := requiredCertExtensions(ctx)
+	if extensions == nil {
+		return nil
+	}
+	content, err := bundle.VerificationContent()
+	if err != nil {
+		return err
+	}
+	return extensions.check(content.Certificate())
+}
+
+// requiredCertExtensionsContextKey carries the extensions a keyless verification requires, from
+// the key or the repository policy
+type requiredCertExtensionsContextKey struct{}
+
+// withRequiredCertExtensions returns a context requiring extensions of signing certificates
+func withRequiredCertExtensions(ctx context.Context, extensions *CertExtensions) context.Context {
+	return context.WithValue(ctx, requiredCertExtensionsContextKey{}, extensions)
+}
+
+// requiredCertExtensions returns the extensions the verification of ctx requires, nil for none
+func requiredCertExtensions(ctx context.Context) *CertExtensions {
+	extensions, _ := ctx.Value(requiredCertExtensionsContextKey{}).(*CertExtensions)
+	return extensions
+}
+
+// certExtensionsFor returns the certificate extensions the verification of ctx requires, if any
+func certExtensionsFor(ctx context.Context) (*CertExtensions, error) {
+	return parseCertExtensions(certExtensions(ctx))
+}
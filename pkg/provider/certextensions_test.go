@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+)
+
+// newExtensionsCert creates a certificate carrying Fulcio extensions as Fulcio encodes them:
+// the deprecated GitHub workflow extensions as raw strings, the others DER-encoded
+func newExtensionsCert(t *testing.T, ref, runnerEnvironment string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	runner, err := asn1.Marshal(runnerEnvironment)
+	if err != nil {
+		t.Fatalf("Failed to encode extension: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sigstore"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+		ExtraExtensions: []pkix.Extension{
+			{Id: certificate.OIDGitHubWorkflowRepository, Value: []byte("myorg/app")},
+			{Id: certificate.OIDGitHubWorkflowRef, Value: []byte(ref)},
+			{Id: certificate.OIDRunnerEnvironment, Value: runner},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestParseCertExtensions(t *testing.T) {
+	extensions, err := parseCertExtensions(`{"githubWorkflowRef":"refs/heads/main","runnerEnvironment":"github-hosted"}`)
+	if err != nil {
+		t.Fatalf("Failed to parse extensions: %v", err)
+	}
+	if extensions == nil || extensions.GithubWorkflowRef != "refs/heads/main" || extensions.RunnerEnvironment != "github-hosted" {
+		t.Errorf("Expected the parsed extensions, got %+v", extensions)
+	}
+
+	for _, raw := range []string{"", "{}"} {
+		if extensions, err := parseCertExtensions(raw); err != nil || extensions != nil {
+			t.Errorf("Expected no extensions for %q, got %+v, %v", raw, extensions, err)
+		}
+	}
+
+	// A misspelled field would leave the constraint unchecked
+	if _, err := parseCertExtensions(`{"githubWorkflowRefs":"refs/heads/main"}`); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestCertExtensionsCheck(t *testing.T) {
+	extensions := &CertExtensions{GithubWorkflowRepository: "myorg/app", GithubWorkflowRef: "refs/heads/main", RunnerEnvironment: "github-hosted"}
+
+	if err := extensions.check(newExtensionsCert(t, "refs/heads/main", "github-hosted")); err != nil {
+		t.Errorf("Expected the certificate to match, got %v", err)
+	}
+
+	err := extensions.check(newExtensionsCert(t, "refs/heads/feature", "github-hosted"))
+	if err == nil || !strings.Contains(err.Error(), `githubWorkflowRef is "refs/heads/feature", expected "refs/heads/main"`) {
+		t.Errorf("Expected a ref mismatch, got %v", err)
+	}
+	if err := extensions.check(newExtensionsCert(t, "refs/heads/main", "self-hosted")); err == nil {
+		t.Error("Expected a runner environment mismatch")
+	}
+
+	// Extensions missing from the certificate do not match
+	if err := (&CertExtensions{BuildSignerURI: "https://github.com/myorg/app/.github/workflows/build.yml@refs/heads/main"}).check(newExtensionsCert(t, "refs/heads/main", "github-hosted")); err == nil {
+		t.Error("Expected a missing extension to fail")
+	}
+	if err := extensions.check(nil); err == nil {
+		t.Error("Expected an error without a certificate")
+	}
+}
+
+func TestClassifyCertExtensionError(t *testing.T) {
+	err := classifyCertExtensionError(errors.New("no matching attestations: " + certExtensionMismatchMarker + `: runnerEnvironment is "self-hosted", expected "github-hosted"`))
+	if ErrorCode(err) != ErrCodeCertExtension {
+		t.Errorf("Expected %s, got %v", ErrCodeCertExtension, err)
+	}
+	if err := classifyCertExtensionError(errors.New("signature mismatch")); ErrorCode(err) != "" {
+		t.Errorf("Expected no code, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault types supported by the failure injector
+const (
+	FaultRegistryError = "registry-error"
+	FaultMalformedSBOM = "malformed-sbom"
+)
+
+// FaultRule describes a failure to inject for images matching ImagePattern.
+// ImagePattern uses path.Match glob syntax against the image reference, e.g. "ghcr.io/myorg/*".
+type FaultRule struct {
+	ImagePattern string `json:"imagePattern"`
+	Latency      string `json:"latency,omitempty"` // Added delay, e.g. "2s"
+	Fault        string `json:"fault,omitempty"`   // "registry-error", "malformed-sbom" or empty for latency only
+
+	latency time.Duration
+}
+
+// faultInjector holds the active failure injection rules.
+// A nil injector never injects anything.
+type faultInjector struct {
+	mu    sync.RWMutex
+	rules []FaultRule
+}
+
+// newFaultInjector creates an empty fault injector
+func newFaultInjector() *faultInjector {
+	return &faultInjector{}
+}
+
+// validate checks the rule and parses its latency
+func (r *FaultRule) validate() error {
+	if r.ImagePattern == "" {
+		return fmt.Errorf("imagePattern is required")
+	}
+	if _, err := path.Match(r.ImagePattern, ""); err != nil {
+		return fmt.Errorf("invalid imagePattern: %w", err)
+	}
+
+	switch r.Fault {
+	case "", FaultRegistryError, FaultMalformedSBOM:
+	default:
+		return fmt.Errorf("unknown fault type %q", r.Fault)
+	}
+
+	if r.Latency != "" {
+		latency, err := time.ParseDuration(r.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid latency: %w", err)
+		}
+		r.latency = latency
+	}
+
+	if r.Fault == "" && r.latency == 0 {
+		return fmt.Errorf("rule must set a fault or a latency")
+	}
+
+	return nil
+}
+
+// SetRules replaces the active rules after validating them
+func (f *faultInjector) SetRules(rules []FaultRule) error {
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	f.mu.Lock()
+	f.rules = rules
+	f.mu.Unlock()
+	return nil
+}
+
+// Rules returns a copy of the active rules
+func (f *faultInjector) Rules() []FaultRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]FaultRule{}, f.rules...)
+}
+
+// match returns the first rule matching image, if any
+func (f *faultInjector) match(image string) (FaultRule, bool) {
+	if f == nil {
+		return FaultRule{}, false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, rule := range f.rules {
+		if ok, _ := path.Match(rule.ImagePattern, image); ok {
+			return rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+// inject applies the matching rule for image. It returns an item and true when the
+// rule replaces the verification result, or false when verification should proceed.
+func (f *faultInjector) inject(ctx context.Context, key, image string) (Item, bool) {
+	rule, ok := f.match(image)
+	if !ok {
+		return Item{}, false
+	}
+
+	log.Printf("Injecting fault for %s (pattern: %s, fault: %q, latency: %v)", image, rule.ImagePattern, rule.Fault, rule.latency)
+	tracef(ctx, "injecting fault (pattern: %s, fault: %q, latency: %v)", rule.ImagePattern, rule.Fault, rule.latency)
+
+	if rule.latency > 0 {
+		select {
+		case <-time.After(rule.latency):
+		case <-ctx.Done():
+			return Item{
+				Key:   key,
+				Error: fmt.Sprintf("Failed to verify attestation or extract SBOM: injected latency: %v", ctx.Err()),
+			}, true
+		}
+	}
+
+	switch rule.Fault {
+	case FaultRegistryError:
+		return Item{
+			Key:   key,
+			Error: "Failed to verify attestation or extract SBOM: injected registry error: GET https://registry/v2/: UNAVAILABLE",
+		}, true
+	case FaultMalformedSBOM:
+		return Item{
+			Key:   key,
+			Value: `{"format":"spdx","packages":[{"name":`,
+		}, true
+	}
+
+	return Item{}, false
+}
+
+// injectFault applies the failure injection rule matching imageRef, bounded by the server
+// timeout. It returns the injected item and true when the rule replaces verification.
+func (s *Server) injectFault(ctx context.Context, imageRef string) (Item, bool) {
+	if s.faults == nil {
+		return Item{}, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.faults.inject(ctx, imageRef, strings.SplitN(imageRef, "|", 2)[0])
+}
+
+// authorizedAdmin checks the bearer token of a request to an admin endpoint
+func (s *Server) authorizedAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// handleChaos manages failure injection rules.
+// GET lists the rules, PUT replaces them with a JSON array, DELETE clears them, all with
+// "Authorization: Bearer <ADMIN_TOKEN>".
+func (s *Server) handleChaos(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizedAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sbom-provider"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var rules []FaultRule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, "Invalid rules format", http.StatusBadRequest)
+			return
+		}
+		if err := s.faults.SetRules(rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failure injection rules updated (%d rules)", len(rules))
+	case http.MethodDelete:
+		s.faults.SetRules(nil)
+		log.Printf("Failure injection rules cleared")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.faults.Rules())
+}
@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectorRegistryError(t *testing.T) {
+	faults := newFaultInjector()
+	if err := faults.SetRules([]FaultRule{{ImagePattern: "ghcr.io/myorg/*", Fault: FaultRegistryError}}); err != nil {
+		t.Fatalf("Failed to set rules: %v", err)
+	}
+
+	item, injected := faults.inject(context.Background(), "ghcr.io/myorg/app:v1|[]||", "ghcr.io/myorg/app:v1")
+	if !injected {
+		t.Fatal("Expected fault to be injected")
+	}
+
+	if !strings.Contains(item.Error, "injected registry error") {
+		t.Errorf("Expected injected registry error, got '%s'", item.Error)
+	}
+
+	if _, injected := faults.inject(context.Background(), "docker.io/library/nginx", "docker.io/library/nginx"); injected {
+		t.Error("Expected no fault for non-matching image")
+	}
+}
+
+func TestFaultInjectorMalformedSBOM(t *testing.T) {
+	faults := newFaultInjector()
+	if err := faults.SetRules([]FaultRule{{ImagePattern: "*", Fault: FaultMalformedSBOM}}); err != nil {
+		t.Fatalf("Failed to set rules: %v", err)
+	}
+
+	item, injected := faults.inject(context.Background(), "nginx", "nginx")
+	if !injected {
+		t.Fatal("Expected fault to be injected")
+	}
+
+	var sbom UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &sbom); err == nil {
+		t.Error("Expected malformed SBOM value")
+	}
+}
+
+func TestFaultInjectorLatencyTimeout(t *testing.T) {
+	faults := newFaultInjector()
+	if err := faults.SetRules([]FaultRule{{ImagePattern: "*", Latency: "1s"}}); err != nil {
+		t.Fatalf("Failed to set rules: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	item, injected := faults.inject(ctx, "nginx", "nginx")
+	if !injected {
+		t.Fatal("Expected timeout to be reported")
+	}
+
+	if !strings.Contains(item.Error, "injected latency") {
+		t.Errorf("Expected injected latency error, got '%s'", item.Error)
+	}
+}
+
+func TestFaultInjectorInvalidRules(t *testing.T) {
+	faults := newFaultInjector()
+
+	invalid := [][]FaultRule{
+		{{Fault: FaultRegistryError}},
+		{{ImagePattern: "*", Fault: "explode"}},
+		{{ImagePattern: "*", Latency: "soon"}},
+		{{ImagePattern: "*"}},
+	}
+
+	for _, rules := range invalid {
+		if err := faults.SetRules(rules); err == nil {
+			t.Errorf("Expected error for rules %+v", rules)
+		}
+	}
+}
+
+func TestHandleChaos(t *testing.T) {
+	server := &Server{
+		port:       "8090",
+		timeout:    30 * time.Second,
+		faults:     newFaultInjector(),
+		adminToken: "secret",
+	}
+
+	body := []byte(`[{"imagePattern": "localhost:5000/*", "fault": "registry-error"}]`)
+	req := httptest.NewRequest(http.MethodPut, "/chaos", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	server.handleChaos(w, req)
+	if w.Code != http.StatusUnauthorized || len(server.faults.Rules()) != 0 {
+		t.Fatalf("Expected status 401 without the admin token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/chaos", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	server.handleChaos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	item := server.resolveItem("localhost:5000/test:latest", RequestClassAdmission)
+	if !strings.Contains(item.Error, "injected registry error") {
+		t.Errorf("Expected injected registry error, got '%s'", item.Error)
+	}
+
+	// Debug keys get the injected fault too, with their trace ID
+	item = server.traceImageRef("localhost:5000/test:latest", RequestClassAdmission)
+	if !strings.Contains(item.Error, "injected registry error") || !strings.Contains(item.Error, "trace ID") {
+		t.Errorf("Expected injected registry error in the debug trace, got '%s'", item.Error)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/chaos", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	server.handleChaos(w, req)
+
+	if len(server.faults.Rules()) != 0 {
+		t.Errorf("Expected rules to be cleared, got %d", len(server.faults.Rules()))
+	}
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxClockSkew is the default tolerated difference between the node clock and trusted time sources
+const DefaultMaxClockSkew = time.Minute
+
+// timeFailureMarkers identify cosign/sigstore errors caused by certificate validity windows
+var timeFailureMarkers = []string{
+	"certificate expired before signatures were entered in log",
+	"certificate was issued after signatures were entered in log",
+	"integrated time outside certificate validity",
+	"certificate has expired or is not yet valid",
+	"expected a signed timestamp to verify an expired certificate",
+}
+
+// clockMonitor tracks the skew between the node clock and a reference HTTP server's Date header
+type clockMonitor struct {
+	url     string
+	maxSkew time.Duration
+	skew    atomic.Int64 // last measured skew in nanoseconds (reference minus local)
+	checked atomic.Bool
+}
+
+// newClockMonitor creates a monitor that measures skew against url
+func newClockMonitor(url string, maxSkew time.Duration) *clockMonitor {
+	return &clockMonitor{url: url, maxSkew: maxSkew}
+}
+
+// Check measures the current clock skew and logs a warning when it exceeds the tolerance
+func (c *clockMonitor) Check(ctx context.Context) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach time reference: %w", err)
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	refTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("time reference returned no usable Date header: %w", err)
+	}
+
+	// Date has second precision, compare against the midpoint of the round trip
+	skew := refTime.Sub(start.Add(elapsed / 2)).Truncate(time.Second)
+	c.skew.Store(int64(skew))
+	c.checked.Store(true)
+
+	if c.Exceeded() {
+		log.Printf("Warning: node clock differs from %s by %v (max tolerated: %v), time-based verification checks may fail", c.url, skew, c.maxSkew)
+	}
+	return skew, nil
+}
+
+// Skew returns the last measured skew and whether a measurement exists
+func (c *clockMonitor) Skew() (time.Duration, bool) {
+	if c == nil || !c.checked.Load() {
+		return 0, false
+	}
+	return time.Duration(c.skew.Load()), true
+}
+
+// Exceeded reports whether the last measured skew is beyond the tolerance
+func (c *clockMonitor) Exceeded() bool {
+	skew, ok := c.Skew()
+	if !ok {
+		return false
+	}
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew > c.maxSkew
+}
+
+// classifyTimeError attaches ERR_CLOCK_SKEW or ERR_CERT_VALIDITY to time-related verification failures
+func (v *AttestationVerifier) classifyTimeError(err error) error {
+	if err == nil || ErrorCode(err) != "" || !isTimeFailure(err) {
+		return err
+	}
+
+	if v.clock.Exceeded() {
+		skew, _ := v.clock.Skew()
+		return &VerificationError{
+			Code: ErrCodeClockSkew,
+			Err:  fmt.Errorf("%w (node clock skew %v exceeds %v)", err, skew, v.maxClockSkew),
+		}
+	}
+	return &VerificationError{Code: ErrCodeCertValidity, Err: err}
+}
+
+// isTimeFailure reports whether err stems from a certificate validity window check
+func isTimeFailure(err error) bool {
+	msg := err.Error()
+	for _, marker := range timeFailureMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIntegratedTime rejects log entries integrated in the future relative to the node clock
+func (v *AttestationVerifier) checkIntegratedTime(integratedTime time.Time) error {
+	if v.maxClockSkew <= 0 {
+		return nil
+	}
+	if ahead := integratedTime.Sub(time.Now()); ahead > v.maxClockSkew {
+		return newVerificationError(ErrCodeClockSkew,
+			"log entry integrated at %s is %v ahead of the node clock (max tolerated skew: %v)",
+			integratedTime.UTC().Format(time.RFC3339), ahead.Truncate(time.Second), v.maxClockSkew)
+	}
+	return nil
+}
+
+// checkCertValidity verifies t lies within the certificate validity window of a Rekor search
+// entry, widened by the configured tolerance. cosign checks the certificates of every other
+// source itself, without tolerance.
+func (v *AttestationVerifier) checkCertValidity(cert *x509.Certificate, t time.Time) error {
+	tolerance := v.rekorCertTolerance
+	if t.After(cert.NotAfter.Add(tolerance)) || t.Before(cert.NotBefore.Add(-tolerance)) {
+		return newVerificationError(ErrCodeCertValidity,
+			"signing time %s outside certificate validity %s - %s (tolerance: %v)",
+			t.UTC().Format(time.RFC3339), cert.NotBefore.UTC().Format(time.RFC3339),
+			cert.NotAfter.UTC().Format(time.RFC3339), tolerance)
+	}
+	return nil
+}
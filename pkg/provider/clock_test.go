@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClockMonitorCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-10*time.Minute).UTC().Format(http.TimeFormat))
+	}))
+	defer ts.Close()
+
+	clock := newClockMonitor(ts.URL, time.Minute)
+	if clock.Exceeded() {
+		t.Error("Expected no skew before the first measurement")
+	}
+
+	skew, err := clock.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to check clock: %v", err)
+	}
+
+	if skew > -9*time.Minute || skew < -11*time.Minute {
+		t.Errorf("Expected skew around -10m, got %v", skew)
+	}
+
+	if !clock.Exceeded() {
+		t.Error("Expected skew to exceed tolerance")
+	}
+}
+
+func TestCheckIntegratedTime(t *testing.T) {
+	verifier := &AttestationVerifier{maxClockSkew: time.Minute}
+
+	if err := verifier.checkIntegratedTime(time.Now().Add(30 * time.Second)); err != nil {
+		t.Errorf("Expected entry within tolerance to pass, got: %v", err)
+	}
+
+	err := verifier.checkIntegratedTime(time.Now().Add(10 * time.Minute))
+	if ErrorCode(err) != ErrCodeClockSkew {
+		t.Errorf("Expected %s, got: %v", ErrCodeClockSkew, err)
+	}
+}
+
+func TestCheckCertValidityTolerance(t *testing.T) {
+	now := time.Now()
+	cert := &x509.Certificate{
+		NotBefore: now,
+		NotAfter:  now.Add(10 * time.Minute),
+	}
+
+	strict := &AttestationVerifier{}
+	err := strict.checkCertValidity(cert, now.Add(-30*time.Second))
+	if ErrorCode(err) != ErrCodeCertValidity {
+		t.Errorf("Expected %s, got: %v", ErrCodeCertValidity, err)
+	}
+
+	tolerant := &AttestationVerifier{rekorCertTolerance: time.Minute}
+	if err := tolerant.checkCertValidity(cert, now.Add(-30*time.Second)); err != nil {
+		t.Errorf("Expected signing time within tolerance to pass, got: %v", err)
+	}
+}
+
+func TestClassifyTimeError(t *testing.T) {
+	timeErr := errors.New("checking expiry on certificate with bundle: certificate expired before signatures were entered in log: a is before b")
+
+	verifier := &AttestationVerifier{maxClockSkew: time.Minute}
+	if code := ErrorCode(verifier.classifyTimeError(timeErr)); code != ErrCodeCertValidity {
+		t.Errorf("Expected %s without skew, got '%s'", ErrCodeCertValidity, code)
+	}
+
+	verifier.clock = newClockMonitor("", time.Minute)
+	verifier.clock.skew.Store(int64(5 * time.Minute))
+	verifier.clock.checked.Store(true)
+	if code := ErrorCode(verifier.classifyTimeError(timeErr)); code != ErrCodeClockSkew {
+		t.Errorf("Expected %s with skew, got '%s'", ErrCodeClockSkew, code)
+	}
+
+	other := errors.New("no matching attestations")
+	if code := ErrorCode(verifier.classifyTimeError(other)); code != "" {
+		t.Errorf("Expected no code for unrelated error, got '%s'", code)
+	}
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// SBOMCompleteness is a heuristic confidence score of how complete an SBOM looks for its
+// image, so suspiciously thin SBOMs can be flagged for review
+type SBOMCompleteness struct {
+	Score      float64 `json:"score"`      // 0 (suspiciously thin) to 1 (looks complete)
+	Packages   int     `json:"packages"`   // Packages in the SBOM
+	Layers     int     `json:"layers"`     // Image layers
+	ImageSize  int64   `json:"imageSize"`  // Compressed image size in bytes
+	OSDetected bool    `json:"osDetected"` // Whether the SBOM lists operating system packages
+}
+
+// Weights of the completeness score components
+const (
+	completenessDensityWeight = 0.5 // Packages found relative to the image size
+	completenessOSWeight      = 0.3 // Operating system packages detected
+	completenessVersionWeight = 0.2 // Share of packages with a version
+)
+
+// expectedPackagesPerMiB is the package density at which an SBOM scores full density marks.
+// Real images range widely; the goal is to single out SBOMs that list next to nothing.
+const expectedPackagesPerMiB = 1.0
+
+// minExpectedPackages keeps tiny images (e.g. distroless static) from needing zero packages
+const minExpectedPackages = 5
+
+// osPurlTypes are package URL types of operating system package managers
+var osPurlTypes = []string{"pkg:deb/", "pkg:apk/", "pkg:rpm/", "pkg:alpm/"}
+
+// scoreCompleteness computes the completeness of sbom for an image of the given layers and size
+func scoreCompleteness(sbom *UnifiedSBOM, layers int, size int64) *SBOMCompleteness {
+	c := &SBOMCompleteness{
+		Packages:   len(sbom.Packages),
+		Layers:     layers,
+		ImageSize:  size,
+		OSDetected: sbom.osDetected,
+	}
+
+	versioned := 0
+	for _, pkg := range sbom.Packages {
+		if pkg.Version != "" {
+			versioned++
+		}
+		for _, prefix := range osPurlTypes {
+			if strings.HasPrefix(pkg.PURL, prefix) {
+				c.OSDetected = true
+			}
+		}
+	}
+
+	expected := math.Max(minExpectedPackages, float64(size)/(1<<20)*expectedPackagesPerMiB)
+	density := math.Min(1, float64(c.Packages)/expected)
+
+	score := completenessDensityWeight * density
+	if c.OSDetected {
+		score += completenessOSWeight
+	}
+	if c.Packages > 0 {
+		score += completenessVersionWeight * float64(versioned) / float64(c.Packages)
+	}
+	c.Score = math.Round(score*100) / 100
+	return c
+}
+
+// imageLayers returns the number of layers and compressed size of the image ref points to
+func (v *AttestationVerifier) imageLayers(ctx context.Context, ref name.Reference, keychain authn.Keychain) (int, int64, error) {
+	img, err := remote.Image(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch image manifest: %w", err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read image manifest: %w", err)
+	}
+
+	var size int64
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return len(manifest.Layers), size, nil
+}
+
+// completenessBuckets are the upper bounds of the completeness score histogram
+var completenessBuckets = []float64{0.25, 0.5, 0.75, 1}
+
+// completenessHistogram counts scored SBOMs for /metrics
+var completenessHistogram = struct {
+	buckets [4]atomic.Int64 // Cumulative counts per completenessBuckets entry
+	count   atomic.Int64
+	sum     atomic.Int64 // Sum of scores in hundredths
+}{}
+
+// observeCompleteness records a completeness score
+func observeCompleteness(c *SBOMCompleteness) {
+	for i, le := range completenessBuckets {
+		if c.Score <= le {
+			completenessHistogram.buckets[i].Add(1)
+		}
+	}
+	completenessHistogram.count.Add(1)
+	completenessHistogram.sum.Add(int64(math.Round(c.Score * 100)))
+}
+
+// writeCompletenessMetrics writes the completeness score histogram in Prometheus text format
+func writeCompletenessMetrics(w http.ResponseWriter) {
+	const name = "sbom_provider_sbom_completeness_score"
+	fmt.Fprintf(w, "# HELP %s Heuristic completeness score of verified SBOMs.\n# TYPE %s histogram\n", name, name)
+	for i, le := range completenessBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, le, completenessHistogram.buckets[i].Load())
+	}
+	count := completenessHistogram.count.Load()
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, float64(completenessHistogram.sum.Load())/100)
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestScoreCompleteness(t *testing.T) {
+	full := &UnifiedSBOM{Packages: make([]UnifiedPackage, 0, 50)}
+	for i := 0; i < 50; i++ {
+		full.Packages = append(full.Packages, UnifiedPackage{Name: "pkg", Version: "1.0", PURL: "pkg:deb/debian/pkg@1.0"})
+	}
+	c := scoreCompleteness(full, 3, 30<<20)
+	if c.Score != 1 {
+		t.Errorf("Expected a full score, got %v", c.Score)
+	}
+	if !c.OSDetected || c.Packages != 50 || c.Layers != 3 || c.ImageSize != 30<<20 {
+		t.Errorf("Unexpected completeness details: %+v", c)
+	}
+
+	// A couple of unversioned application packages in a large image look suspicious
+	thin := &UnifiedSBOM{Packages: []UnifiedPackage{{Name: "app"}, {Name: "lib", Version: "2.0"}}}
+	c = scoreCompleteness(thin, 8, 500<<20)
+	if c.Score >= 0.25 {
+		t.Errorf("Expected a low score for a thin SBOM, got %v", c.Score)
+	}
+	if c.OSDetected {
+		t.Error("Expected no OS packages to be detected")
+	}
+
+	// Small images only need a handful of packages
+	small := &UnifiedSBOM{Packages: full.Packages[:5], osDetected: true}
+	if c := scoreCompleteness(small, 1, 2<<20); c.Score != 1 {
+		t.Errorf("Expected a full score for a small image, got %v", c.Score)
+	}
+
+	if c := scoreCompleteness(&UnifiedSBOM{}, 1, 0); c.Score != 0 {
+		t.Errorf("Expected a zero score for an empty SBOM, got %v", c.Score)
+	}
+}
+
+func TestImageLayers(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+
+	verifier := &AttestationVerifier{}
+	layers, size, err := verifier.imageLayers(context.Background(), ref, authn.DefaultKeychain)
+	if err != nil {
+		t.Fatalf("Failed to read image layers: %v", err)
+	}
+	if layers != 3 {
+		t.Errorf("Expected 3 layers, got %d", layers)
+	}
+	if size <= 0 {
+		t.Errorf("Expected a positive image size, got %d", size)
+	}
+}
+
+func TestCycloneDXOSDetection(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	unified, err := verifier.extractAndNormalizeCycloneDX([]byte(`{"bomFormat":"CycloneDX","components":[{"type":"operating-system","name":"debian","version":"12"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to normalize CycloneDX: %v", err)
+	}
+	if !scoreCompleteness(unified, 1, 0).OSDetected {
+		t.Error("Expected the operating-system component to be detected")
+	}
+}
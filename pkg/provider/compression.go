@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedPayload bounds the size of a compressed attestation payload once
+// decompressed, in bytes
+const DefaultMaxDecompressedPayload = 128 << 20
+
+// Magic numbers of the compressed payload formats
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// maxDecompressedPayload returns the decompressed payload limit of the verifier
+func (v *AttestationVerifier) maxDecompressedPayload() int64 {
+	if v.decompressedPayloadCap <= 0 {
+		return DefaultMaxDecompressedPayload
+	}
+	return v.decompressedPayloadCap
+}
+
+// decompressPayload returns the decompressed payload when it is gzip or zstd compressed, as
+// toolchains producing very large SBOMs may store it, or payload unchanged. Payloads growing
+// beyond limit bytes are rejected rather than decompressed in full.
+func decompressPayload(payload []byte, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(payload, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip payload: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case bytes.HasPrefix(payload, zstdMagic):
+		zr, err := zstd.NewReader(bytes.NewReader(payload), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(limit)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd payload: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return payload, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes: %w", limit, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes", limit)
+	}
+	return data, nil
+}
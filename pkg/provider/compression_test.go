@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseStatementCompressed(t *testing.T) {
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://cyclonedx.org/bom","predicate":{"bomFormat":"CycloneDX","specVersion":"1.5","components":[{"name":"openssl","version":"3.0.13"}]}}`)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(statement)
+	gw.Close()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Failed to create zstd writer: %v", err)
+	}
+	zst := zw.EncodeAll(statement, nil)
+	zw.Close()
+
+	envelope := func(payload []byte) []byte {
+		return []byte(`{"payload":"` + base64.StdEncoding.EncodeToString(payload) + `","payloadType":"application/vnd.in-toto+json"}`)
+	}
+	verifier := &AttestationVerifier{}
+	for name, payload := range map[string][]byte{"gzip": gz.Bytes(), "zstd": zst, "plain": statement} {
+		predicateType, _, err := verifier.parseStatement(envelope(payload))
+		if err != nil || predicateType != "https://cyclonedx.org/bom" {
+			t.Errorf("%s: expected the CycloneDX statement, got %q and %v", name, predicateType, err)
+		}
+		unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{{payload: envelope(payload)}})
+		if err != nil || len(unified.Packages) != 1 {
+			t.Errorf("%s: expected the SBOM to be extracted, got %+v and %v", name, unified, err)
+		}
+	}
+
+	// Payloads decompressing beyond the limit are rejected
+	limited := &AttestationVerifier{decompressedPayloadCap: int64(len(statement) - 1)}
+	for name, payload := range map[string][]byte{"gzip": gz.Bytes(), "zstd": zst} {
+		if _, _, err := limited.parseStatement(envelope(payload)); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("%s: expected the payload to exceed the limit, got %v", name, err)
+		}
+	}
+	if _, _, err := verifier.parseStatement(envelope([]byte{0x1f, 0x8b, 0x00})); err == nil {
+		t.Error("Expected a truncated gzip payload to fail")
+	}
+}
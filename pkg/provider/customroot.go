@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// TrustedRootCustom is a trusted root assembled from the CustomTrustedRoot material of a
+// self-hosted Sigstore deployment
+const TrustedRootCustom = "custom"
+
+// CustomTrustedRoot is the trust material of a self-hosted Sigstore deployment, for clusters
+// without a trusted_root.json or a TUF repository of their own. Paths are re-read whenever
+// trusted roots are refreshed, so rotated files take effect without a restart.
+type CustomTrustedRoot struct {
+	// FulcioRoots is the path of a PEM bundle of the Fulcio root CA certificates and their
+	// intermediates
+	FulcioRoots string
+	// RekorPublicKeys are the paths of the PEM public keys of the Rekor transparency logs
+	RekorPublicKeys []string
+	// RekorURL is the base URL of the Rekor transparency log
+	RekorURL string
+	// CTLogPublicKeys are the paths of the PEM public keys of the certificate transparency
+	// logs, needed to verify SCTs
+	CTLogPublicKeys []string
+}
+
+// configured reports whether any custom trust material is set
+func (c *CustomTrustedRoot) configured() bool {
+	return c != nil && (c.FulcioRoots != "" || hasPath(c.RekorPublicKeys) || hasPath(c.CTLogPublicKeys))
+}
+
+// complete reports whether the custom material can verify transparency log entries. Without
+// Fulcio roots only signatures made with a public key verify against it.
+func (c *CustomTrustedRoot) complete() bool {
+	return c != nil && hasPath(c.RekorPublicKeys)
+}
+
+// keyless reports whether the custom material can verify keyless signatures
+func (c *CustomTrustedRoot) keyless() bool {
+	return c.complete() && c.FulcioRoots != ""
+}
+
+// hasPath reports whether paths holds a non-blank path
+func hasPath(paths []string) bool {
+	for _, path := range paths {
+		if strings.TrimSpace(path) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validityStart opens the validity period of custom material, which is trusted for any time
+var validityStart = time.Unix(0, 0)
+
+// loadCustomTrustedRoot assembles a trusted root from the custom material
+func loadCustomTrustedRoot(c *CustomTrustedRoot) (*root.TrustedRoot, error) {
+	if !c.complete() {
+		return nil, fmt.Errorf("the custom trusted root requires a Rekor public key")
+	}
+
+	var cas []root.CertificateAuthority
+	if c.keyless() {
+		var err error
+		if cas, err = loadFulcioAuthorities(c.FulcioRoots); err != nil {
+			return nil, err
+		}
+	}
+	rekorLogs, err := loadTransparencyLogs(c.RekorPublicKeys, c.RekorURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Rekor public key: %w", err)
+	}
+	ctLogs, err := loadTransparencyLogs(c.CTLogPublicKeys, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CT log public key: %w", err)
+	}
+
+	return root.NewTrustedRoot(root.TrustedRootMediaType01, cas, ctLogs, nil, rekorLogs)
+}
+
+// loadFulcioAuthorities reads a PEM bundle of Fulcio certificates, returning one certificate
+// authority per self-signed root with the other certificates as intermediates
+func loadFulcioAuthorities(path string) ([]root.CertificateAuthority, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Fulcio root certificates: %w", err)
+	}
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Fulcio root certificates: %w", err)
+	}
+
+	var roots, intermediates []*x509.Certificate
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+			roots = append(roots, cert)
+		} else {
+			intermediates = append(intermediates, cert)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no self-signed root certificate in %s", path)
+	}
+
+	cas := make([]root.CertificateAuthority, 0, len(roots))
+	for _, cert := range roots {
+		cas = append(cas, &root.FulcioCertificateAuthority{
+			Root:                cert,
+			Intermediates:       intermediates,
+			ValidityPeriodStart: cert.NotBefore,
+			ValidityPeriodEnd:   cert.NotAfter,
+		})
+	}
+	return cas, nil
+}
+
+// loadTransparencyLogs reads the PEM public keys of transparency logs, keyed by log ID (the
+// hex sha256 of the DER-encoded key) as logged entries and SCTs reference them
+func loadTransparencyLogs(paths []string, baseURL string) (map[string]*root.TransparencyLog, error) {
+	logs := make(map[string]*root.TransparencyLog, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := cryptoutils.UnmarshalPEMToPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		id := sha256.Sum256(der)
+		logs[hex.EncodeToString(id[:])] = &root.TransparencyLog{
+			BaseURL:             baseURL,
+			ID:                  id[:],
+			ValidityPeriodStart: validityStart,
+			HashFunc:            crypto.SHA256,
+			PublicKey:           pub,
+			SignatureHashFunc:   crypto.SHA256,
+		}
+	}
+	return logs, nil
+}
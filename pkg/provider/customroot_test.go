@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// writeFulcioChain writes a PEM bundle of an intermediate and its self-signed root
+func writeFulcioChain(t *testing.T, path string) {
+	t.Helper()
+	newCert := func(template, parent *x509.Certificate, pub, signer interface{}) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+		return cert
+	}
+
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio-root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root := newCert(rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+
+	intermediateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	intermediate := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "fulcio-intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root, intermediateKey.Public(), rootKey)
+
+	var data []byte
+	for _, cert := range []*x509.Certificate{intermediate, root} {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write certificates: %v", err)
+	}
+}
+
+// writePublicKey writes a new PEM public key to path
+func writePublicKey(t *testing.T, path string) {
+	t.Helper()
+	data, err := cryptoutils.MarshalPublicKeyToPEM(newTestECDSAKey(t))
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func TestLoadCustomTrustedRoot(t *testing.T) {
+	dir := t.TempDir()
+	custom := &CustomTrustedRoot{
+		FulcioRoots:     filepath.Join(dir, "fulcio.pem"),
+		RekorPublicKeys: []string{filepath.Join(dir, "rekor.pub"), ""},
+		RekorURL:        "https://rekor.sigstore.internal",
+		CTLogPublicKeys: []string{filepath.Join(dir, "ctlog.pub")},
+	}
+	writeFulcioChain(t, custom.FulcioRoots)
+	writePublicKey(t, custom.RekorPublicKeys[0])
+	writePublicKey(t, custom.CTLogPublicKeys[0])
+
+	roots, err := loadTrustedRoots([]string{TrustedRootCustom}, trustedRootConfig{custom: custom})
+	if err != nil {
+		t.Fatalf("Failed to load the custom trusted root: %v", err)
+	}
+	material := roots[0].material
+	if cas := material.FulcioCertificateAuthorities(); len(cas) != 1 {
+		t.Errorf("Expected one Fulcio certificate authority, got %d", len(cas))
+	}
+	rekorLogs := material.RekorLogs()
+	if len(rekorLogs) != 1 || len(material.CTLogs()) != 1 {
+		t.Fatalf("Expected one Rekor and one CT log, got %d and %d", len(rekorLogs), len(material.CTLogs()))
+	}
+	for _, tlog := range rekorLogs {
+		if tlog.BaseURL != custom.RekorURL || tlog.ValidityPeriodStart.IsZero() {
+			t.Errorf("Expected the Rekor log at %s valid from the start, got %+v", custom.RekorURL, tlog)
+		}
+	}
+
+	// Rotated material changes the trust hash, evicting cached results
+	hash := trustedRootsHash(roots)
+	writePublicKey(t, custom.RekorPublicKeys[0])
+	rotated, err := loadTrustedRoots([]string{TrustedRootCustom}, trustedRootConfig{custom: custom})
+	if err != nil {
+		t.Fatalf("Failed to reload the custom trusted root: %v", err)
+	}
+	if trustedRootsHash(rotated) == hash {
+		t.Error("Expected a rotated Rekor key to change the trust hash")
+	}
+}
+
+func TestLoadCustomTrustedRootRekorOnly(t *testing.T) {
+	custom := &CustomTrustedRoot{
+		RekorPublicKeys: []string{filepath.Join(t.TempDir(), "rekor.pub")},
+		RekorURL:        "https://rekor.sigstore.internal",
+	}
+	writePublicKey(t, custom.RekorPublicKeys[0])
+
+	material, err := loadCustomTrustedRoot(custom)
+	if err != nil {
+		t.Fatalf("Failed to load the custom trusted root: %v", err)
+	}
+	if len(material.FulcioCertificateAuthorities()) != 0 || len(material.RekorLogs()) != 1 {
+		t.Errorf("Expected only a Rekor log, got %d CAs and %d Rekor logs", len(material.FulcioCertificateAuthorities()), len(material.RekorLogs()))
+	}
+}
+
+func TestCustomTrustedRootSpecs(t *testing.T) {
+	complete := &CustomTrustedRoot{FulcioRoots: "/etc/sigstore/fulcio.pem", RekorPublicKeys: []string{"/etc/sigstore/rekor.pub"}}
+	if err := checkTrustedRootSpecs([]string{TrustedRootCustom, TrustedRootPublicGood}, trustedRootConfig{custom: complete}); err != nil {
+		t.Errorf("Expected the custom trusted root to be accepted, got %v", err)
+	}
+	if err := checkTrustedRootSpecs([]string{TrustedRootCustom}, trustedRootConfig{custom: &CustomTrustedRoot{FulcioRoots: "/etc/sigstore/fulcio.pem", RekorPublicKeys: []string{""}}}); err == nil {
+		t.Error("Expected an error for the custom trusted root without a Rekor key")
+	}
+	if err := checkTrustedRootSpecs([]string{TrustedRootCustom}, trustedRootConfig{custom: &CustomTrustedRoot{RekorPublicKeys: []string{"/etc/sigstore/rekor.pub"}}}); err != nil {
+		t.Errorf("Expected a Rekor key alone to be accepted for key-based signatures, got %v", err)
+	}
+	if err := checkTrustedRootSpecs([]string{TrustedRootPublicGood}, trustedRootConfig{custom: complete}); err == nil {
+		t.Error("Expected an error for custom material the trusted roots don't use")
+	}
+	if err := checkTrustedRootSpecs([]string{TrustedRootPublicGood}, trustedRootConfig{custom: &CustomTrustedRoot{RekorPublicKeys: []string{""}, CTLogPublicKeys: []string{""}}}); err != nil {
+		t.Errorf("Expected blank custom material to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package provider
+
+// CycloneDXDependency is an entry of the CycloneDX dependency graph: the components the
+// component with bom-ref Ref depends on
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// directDependencies returns the bom-refs of the components the application component
+// (metadata.component) depends on directly, nil when the SBOM does not say: without an
+// application component with a bom-ref, or a dependency graph entry for it
+func directDependencies(sbom *CycloneDXBOM) map[string]bool {
+	root := sbom.Metadata.Component
+	if root == nil || root.BOMRef == "" {
+		return nil
+	}
+	for _, dep := range sbom.Dependencies {
+		if dep.Ref != root.BOMRef {
+			continue
+		}
+		direct := make(map[string]bool, len(dep.DependsOn))
+		for _, ref := range dep.DependsOn {
+			direct[ref] = true
+		}
+		return direct
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testDependenciesBOM = `{"bomFormat": "CycloneDX", "specVersion": "1.5",
+	"metadata": {"component": {"bom-ref": "app", "type": "application", "name": "app"}},
+	"components": [
+		{"bom-ref": "pkg:npm/express@4.19.2", "name": "express", "version": "4.19.2", "licenses": [{"license": {"id": "MIT"}}]},
+		{"bom-ref": "pkg:npm/lodash@4.17.21", "name": "lodash", "version": "4.17.21", "licenses": [{"license": {"id": "GPL-2.0-only"}}]},
+		{"bom-ref": "pkg:npm/left-pad@1.3.0", "name": "left-pad", "version": "1.3.0", "licenses": [{"license": {"id": "GPL-2.0-only"}}]},
+		{"name": "unreferenced", "version": "1.0"}
+	],
+	"dependencies": [
+		{"ref": "app", "dependsOn": ["pkg:npm/express@4.19.2", "pkg:npm/lodash@4.17.21"]},
+		{"ref": "pkg:npm/express@4.19.2", "dependsOn": ["pkg:npm/left-pad@1.3.0"]}
+	]
+}`
+
+func TestDirectDependencies(t *testing.T) {
+	unified, err := (&AttestationVerifier{}).extractAndNormalizeCycloneDX(json.RawMessage(testDependenciesBOM))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+
+	want := map[string]bool{"express": true, "lodash": true, "left-pad": false, "unreferenced": false}
+	for _, pkg := range unified.Packages {
+		if pkg.Direct == nil || *pkg.Direct != want[pkg.Name] {
+			t.Errorf("Expected %s direct to be %v, got %v", pkg.Name, want[pkg.Name], pkg.Direct)
+		}
+	}
+
+	for name, bom := range map[string]string{
+		"no graph":          `{"bomFormat": "CycloneDX", "metadata": {"component": {"bom-ref": "app", "name": "app"}}, "components": [{"bom-ref": "a", "name": "a"}]}`,
+		"no root":           `{"bomFormat": "CycloneDX", "components": [{"bom-ref": "a", "name": "a"}], "dependencies": [{"ref": "a"}]}`,
+		"root not in graph": `{"bomFormat": "CycloneDX", "metadata": {"component": {"bom-ref": "app", "name": "app"}}, "components": [{"bom-ref": "a", "name": "a"}], "dependencies": [{"ref": "a"}]}`,
+	} {
+		unified, err := (&AttestationVerifier{}).extractAndNormalizeCycloneDX(json.RawMessage(bom))
+		if err != nil || unified.Packages[0].Direct != nil {
+			t.Errorf("Expected direct to be unset with %s, got %v, %v", name, unified.Packages[0].Direct, err)
+		}
+	}
+}
+
+func TestEvaluateDirectRequiredLicenses(t *testing.T) {
+	sbom, err := (&AttestationVerifier{}).extractAndNormalizeCycloneDX(json.RawMessage(testDependenciesBOM))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+
+	var pkgs []string
+	for _, v := range evaluateSBOM(sbom, &SARIFRequest{DirectRequiredLicenses: []string{"MIT"}}) {
+		if v.Code == ViolationDisallowedLicense {
+			pkgs = append(pkgs, v.pkg)
+		}
+	}
+	if got := strings.Join(pkgs, ","); got != "lodash" {
+		t.Errorf("Expected only the direct lodash to fail, got %s", got)
+	}
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Digest modes, how /verify treats keys whose image is referenced by tag only
+const (
+	// DigestModeAllow verifies tags like digests
+	DigestModeAllow = "allow"
+	// DigestModeRequire fails tags with ErrCodeMutableTag, so constraints enforce digest pinning
+	DigestModeRequire = "require"
+	// DigestModeResolve resolves tags to their digest first and verifies the digest, reporting
+	// it as the value's resolvedDigest
+	DigestModeResolve = "resolve"
+)
+
+// parseDigestMode returns the digest mode of a configuration value. Unknown modes fall back to
+// DigestModeRequire rather than silently admitting tags.
+func parseDigestMode(mode string) string {
+	switch mode {
+	case "", DigestModeAllow:
+		return DigestModeAllow
+	case DigestModeRequire, DigestModeResolve:
+		return mode
+	default:
+		log.Printf("Warning: unknown digest mode %q, using %s", mode, DigestModeRequire)
+		return DigestModeRequire
+	}
+}
+
+// tagOnly reports whether image is referenced by a mutable tag without a digest. References
+// that do not parse are left to fail verification.
+func tagOnly(image string) bool {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+	_, ok := ref.(name.Digest)
+	return !ok
+}
+
+// applyDigestMode returns the key imageRef (without options) is verified under in the server's
+// digest mode, and the digest reference its tag was resolved to, if any. A non-empty Item is an
+// error to return for the key instead.
+func (s *Server) applyDigestMode(key, imageRef string) (string, string, Item) {
+	image, rest, hasRest := strings.Cut(imageRef, "|")
+	if s.digestMode == DigestModeAllow || s.digestMode == "" || !tagOnly(image) {
+		return imageRef, "", Item{}
+	}
+
+	if s.digestMode == DigestModeRequire {
+		err := newVerificationError(ErrCodeMutableTag, "image %s is referenced by a mutable tag, reference it by digest", image)
+		return "", "", Item{Key: key, Error: formatItemError("Image is not pinned by digest", err)}
+	}
+
+	item := s.resolveDigestKey(context.Background(), key)
+	if item.Error != "" {
+		return "", "", item
+	}
+	if !hasRest {
+		return item.Value, item.Value, Item{}
+	}
+	return item.Value + "|" + rest, item.Value, Item{}
+}
+
+// withResolvedDigest records the digest reference a tag was resolved to in a verified item
+func withResolvedDigest(item Item, digest string) Item {
+	if digest == "" || item.Error != "" || item.Value == "" || item.Value == pendingValue {
+		return item
+	}
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &unified); err != nil {
+		return item
+	}
+	unified.ResolvedDigest = digest
+	value, err := json.Marshal(&unified)
+	if err != nil {
+		return item
+	}
+	item.Value = string(value)
+	return item
+}
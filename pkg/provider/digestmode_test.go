@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestParseDigestMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"":        DigestModeAllow,
+		"allow":   DigestModeAllow,
+		"require": DigestModeRequire,
+		"resolve": DigestModeResolve,
+		"strict":  DigestModeRequire,
+	} {
+		if got := parseDigestMode(mode); got != want {
+			t.Errorf("Expected digest mode %q to be %s, got %s", mode, want, got)
+		}
+	}
+}
+
+func TestDigestModeRequire(t *testing.T) {
+	server := &Server{cache: newResultCache(), digestMode: DigestModeRequire}
+
+	key := "ghcr.io/org/app:v1|[]||"
+	item := server.resolveKey(key, false, "")
+	if item.Key != key || !strings.HasPrefix(item.Error, ErrCodeMutableTag+": ") {
+		t.Errorf("Expected %s for a tag, got %+v", ErrCodeMutableTag, item)
+	}
+
+	digest := "ghcr.io/org/app@sha256:" + strings.Repeat("a", 64)
+	server.cache.Set(digest+"|[]||", Item{Value: `{"format":"spdx","packages":[]}`}, time.Minute)
+	if item := server.resolveKey(digest+"|[]||", false, ""); item.Error != "" {
+		t.Errorf("Expected a digest reference to be verified, got %+v", item)
+	}
+	if tagOnly("ghcr.io/org/app:v1@sha256:" + strings.Repeat("a", 64)) {
+		t.Error("Expected a tag with a digest to be accepted")
+	}
+}
+
+func TestDigestModeResolve(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	digest := pushRandomImage(t, host+"/test/app:v1")
+	verifier := &AttestationVerifier{keychain: authn.DefaultKeychain}
+	server := &Server{
+		verifier:       verifier,
+		timeout:        5 * time.Second,
+		cache:          newResultCache(),
+		cacheTTL:       time.Minute,
+		digests:        newResultCache(),
+		digestCacheTTL: time.Minute,
+		digestMode:     DigestModeResolve,
+	}
+
+	// The result of the digest is shared with keys pinned by digest
+	resolved := host + "/test/app@" + digest
+	server.cache.Set(server.cacheKey(resolved+"|[]||"), Item{Value: `{"format":"spdx","packages":[]}`}, time.Minute)
+
+	key := host + "/test/app:v1|[]||"
+	item := server.resolveKey(key, false, "")
+	if item.Error != "" || item.Key != key {
+		t.Fatalf("Expected the resolved digest to be verified, got %+v", item)
+	}
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &unified); err != nil || unified.ResolvedDigest != resolved {
+		t.Errorf("Expected resolvedDigest %s, got %q, %v", resolved, unified.ResolvedDigest, err)
+	}
+
+	if item := server.resolveKey(resolved+"|[]||", false, ""); strings.Contains(item.Value, "resolvedDigest") {
+		t.Errorf("Expected no resolvedDigest for a digest reference, got %s", item.Value)
+	}
+
+	if item := server.resolveKey(host+"/test/missing:v1|[]||", false, ""); !strings.Contains(item.Error, "Failed to resolve image digest") {
+		t.Errorf("Expected a resolution error for a missing tag, got %+v", item)
+	}
+}
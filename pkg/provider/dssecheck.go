@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// dsseChecks counts the DSSE envelope signatures checked during extraction, by result
+var dsseChecks struct {
+	valid, invalid, unchecked atomic.Int64
+}
+
+// certVerifier returns the verifier of the key of a signing certificate, as cosign builds it, or
+// fallback when there is no certificate (key-signed attestations)
+func certVerifier(cert *x509.Certificate, fallback signature.Verifier) signature.Verifier {
+	if cert == nil {
+		return fallback
+	}
+	verifier, err := signature.LoadVerifier(cert.PublicKey, crypto.SHA256)
+	if err != nil {
+		return fallback
+	}
+	return verifier
+}
+
+// checkDSSESignature checks the DSSE envelope of a verified attestation once more, against the
+// key it was verified with: one of its signatures must verify over the pre-authentication
+// encoding of its payload. Bare statements, from sources that verify them otherwise (Rekor
+// entries, BuildKit statements covered by an image signature), are not checked. Envelopes whose
+// key is unknown fail only when the check is enforced.
+func (v *AttestationVerifier) checkDSSESignature(ctx context.Context, i int, att verifiedAttestation) error {
+	data, err := unwrapSigstoreBundle(att.payload)
+	if err != nil {
+		return nil // Not an attestation, skipped by extraction
+	}
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Payload == "" {
+		return nil
+	}
+
+	if att.verifier == nil {
+		dsseChecks.unchecked.Add(1)
+		tracef(ctx, "attestation %d: DSSE signature not checked, the key it was verified with is unknown", i)
+		if v.enforceDSSESignatures {
+			return errors.New("DSSE envelope signature cannot be checked: the key the attestation was verified with is unknown")
+		}
+		return nil
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return v.dsseSignatureMismatch(ctx, i, fmt.Errorf("failed to decode DSSE payload: %w", err))
+	}
+	pae := dsse.PAE(envelope.PayloadType, payload)
+	for _, s := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if att.verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(pae)) == nil {
+			dsseChecks.valid.Add(1)
+			tracef(ctx, "attestation %d: DSSE signature valid", i)
+			return nil
+		}
+	}
+	return v.dsseSignatureMismatch(ctx, i, fmt.Errorf("none of %d DSSE envelope signatures verifies with the key the attestation was verified with", len(envelope.Signatures)))
+}
+
+// dsseSignatureMismatch records a DSSE envelope failing the check, failing the attestation only
+// when the check is enforced
+func (v *AttestationVerifier) dsseSignatureMismatch(ctx context.Context, i int, err error) error {
+	dsseChecks.invalid.Add(1)
+	tracef(ctx, "attestation %d: %v", i, err)
+	if v.enforceDSSESignatures {
+		return err
+	}
+	log.Printf("Warning: attestation %d passed verification but failed the DSSE signature check: %v", i, err)
+	return nil
+}
+
+// checkDSSESignatures returns the attestations passing the DSSE check, and an
+// ErrCodeDSSESignature error for the last one failing it
+func (v *AttestationVerifier) checkDSSESignatures(ctx context.Context, atts []verifiedAttestation) ([]verifiedAttestation, error) {
+	checked := make([]verifiedAttestation, 0, len(atts))
+	var lastErr error
+	for i, att := range atts {
+		if err := v.checkDSSESignature(ctx, i, att); err != nil {
+			lastErr = &VerificationError{Code: ErrCodeDSSESignature, Err: err}
+			recordPartialFailures(ctx, 1, lastErr)
+			continue
+		}
+		checked = append(checked, att)
+	}
+	return checked, lastErr
+}
+
+// writeDSSEMetrics writes the DSSE check counters in Prometheus text format
+func writeDSSEMetrics(w io.Writer) {
+	const name = "sbom_provider_dsse_signature_checks_total"
+	fmt.Fprintf(w, "# HELP %s DSSE envelope signatures checked again during extraction, by result.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{result=\"valid\"} %d\n", name, dsseChecks.valid.Load())
+	fmt.Fprintf(w, "%s{result=\"invalid\"} %d\n", name, dsseChecks.invalid.Load())
+	fmt.Fprintf(w, "%s{result=\"unchecked\"} %d\n", name, dsseChecks.unchecked.Load())
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestCheckDSSESignatures(t *testing.T) {
+	newSigner := func() signature.SignerVerifier {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		signer, err := signature.LoadECDSASignerVerifier(key, crypto.SHA256)
+		if err != nil {
+			t.Fatalf("Failed to load signer: %v", err)
+		}
+		return signer
+	}
+	signer, other := newSigner(), newSigner()
+
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://spdx.dev/Document","predicate":{"spdxVersion":"SPDX-2.3","packages":[{"name":"zlib"}]}}`)
+	sig, err := signer.SignMessage(bytes.NewReader(dsse.PAE("application/vnd.in-toto+json", statement)))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	envelope := func(payloadType string) []byte {
+		data, _ := json.Marshal(map[string]any{
+			"payload":     base64.StdEncoding.EncodeToString(statement),
+			"payloadType": payloadType,
+			"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+		})
+		return data
+	}
+	signed, tampered := envelope("application/vnd.in-toto+json"), envelope("application/json")
+
+	tests := []struct {
+		name    string
+		att     verifiedAttestation
+		passes  bool // Extracted when enforced
+		lenient bool // Extracted when not enforced
+	}{
+		{"valid", verifiedAttestation{payload: signed, verifier: signer}, true, true},
+		{"other key", verifiedAttestation{payload: signed, verifier: other}, false, true},
+		{"tampered payload type", verifiedAttestation{payload: tampered, verifier: signer}, false, true},
+		{"unknown key", verifiedAttestation{payload: signed}, false, true},
+		{"bare statement", verifiedAttestation{payload: statement}, true, true},
+	}
+	for _, tt := range tests {
+		for _, enforce := range []bool{false, true} {
+			verifier := &AttestationVerifier{enforceDSSESignatures: enforce}
+			unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{tt.att})
+			want := tt.lenient
+			if enforce {
+				want = tt.passes
+			}
+			if want && (err != nil || len(unified.Packages) != 1) {
+				t.Errorf("%s (enforced %v): expected the SBOM to be extracted, got %v", tt.name, enforce, err)
+			}
+			var verr *VerificationError
+			if !want && (!errors.As(err, &verr) || verr.Code != ErrCodeDSSESignature) {
+				t.Errorf("%s (enforced %v): expected %s, got %v", tt.name, enforce, ErrCodeDSSESignature, err)
+			}
+		}
+	}
+
+	// A valid attestation is still used when another one fails the check
+	verifier := &AttestationVerifier{enforceDSSESignatures: true}
+	unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{{payload: tampered, verifier: signer}, {payload: signed, verifier: signer}})
+	if err != nil || len(unified.Packages) != 1 {
+		t.Errorf("Expected the valid attestation to be extracted, got %v", err)
+	}
+}
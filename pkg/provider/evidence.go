@@ -0,0 +1,174 @@
+package provider
+
+import "encoding/json"
+
+// maxEvidenceOccurrences bounds the occurrence locations reported per package
+const maxEvidenceOccurrences = 20
+
+// CycloneDXEvidence is the evidence of a component: how it was identified and where it occurs
+type CycloneDXEvidence struct {
+	// Identity is one object in CycloneDX 1.5 and a list of them since 1.6
+	Identity    json.RawMessage       `json:"identity,omitempty"`
+	Occurrences []CycloneDXOccurrence `json:"occurrences,omitempty"`
+}
+
+// CycloneDXIdentity is the evidence a component field was identified with
+type CycloneDXIdentity struct {
+	Field      string                    `json:"field"`
+	Confidence *float64                  `json:"confidence,omitempty"`
+	Methods    []CycloneDXIdentityMethod `json:"methods,omitempty"`
+}
+
+// CycloneDXIdentityMethod is a technique a component was identified with
+type CycloneDXIdentityMethod struct {
+	Technique  string   `json:"technique"`
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// CycloneDXOccurrence is a location a component was found at
+type CycloneDXOccurrence struct {
+	Location string `json:"location"`
+}
+
+// CycloneDXPedigree is the ancestry and modifications of a component
+type CycloneDXPedigree struct {
+	Ancestors   []CycloneDXComponent `json:"ancestors,omitempty"`
+	Descendants []CycloneDXComponent `json:"descendants,omitempty"`
+	Variants    []CycloneDXComponent `json:"variants,omitempty"`
+	Commits     []struct {
+		UID string `json:"uid,omitempty"`
+		URL string `json:"url,omitempty"`
+	} `json:"commits,omitempty"`
+	Patches []struct {
+		Type     string `json:"type"`
+		Resolves []struct {
+			ID string `json:"id,omitempty"`
+		} `json:"resolves,omitempty"`
+	} `json:"patches,omitempty"`
+	Notes string `json:"notes,omitempty"`
+}
+
+// PackageEvidence is the normalized evidence of a CycloneDX component
+type PackageEvidence struct {
+	// IdentityConfidence is the highest confidence, from 0 to 1, any field of the component
+	// was identified with, unset when the SBOM records none
+	IdentityConfidence *float64          `json:"identityConfidence,omitempty"`
+	Identity           []PackageIdentity `json:"identity,omitempty"`
+	Occurrences        []string          `json:"occurrences,omitempty"`     // Locations, at most maxEvidenceOccurrences
+	OccurrenceCount    int               `json:"occurrenceCount,omitempty"` // Locations recorded in the SBOM
+}
+
+// PackageIdentity is the confidence a component field was identified with, and the techniques used
+type PackageIdentity struct {
+	Field      string   `json:"field"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Techniques []string `json:"techniques,omitempty"`
+}
+
+// PackagePedigree is the normalized pedigree of a CycloneDX component. Related components are
+// named by purl, or name@version without one.
+type PackagePedigree struct {
+	Ancestors   []string       `json:"ancestors,omitempty"`
+	Descendants []string       `json:"descendants,omitempty"`
+	Variants    []string       `json:"variants,omitempty"`
+	Commits     []string       `json:"commits,omitempty"` // Commit IDs, or URLs without one
+	Patches     []PackagePatch `json:"patches,omitempty"`
+	Notes       string         `json:"notes,omitempty"`
+}
+
+// PackagePatch is a patch applied to a component, with the issues it resolves
+type PackagePatch struct {
+	Type     string   `json:"type"`
+	Resolves []string `json:"resolves,omitempty"`
+}
+
+// normalizeEvidence returns the evidence of a component, nil without any
+func normalizeEvidence(evidence *CycloneDXEvidence) *PackageEvidence {
+	if evidence == nil {
+		return nil
+	}
+
+	var identities []CycloneDXIdentity
+	if len(evidence.Identity) > 0 {
+		if err := json.Unmarshal(evidence.Identity, &identities); err != nil {
+			var identity CycloneDXIdentity
+			if json.Unmarshal(evidence.Identity, &identity) == nil {
+				identities = []CycloneDXIdentity{identity}
+			}
+		}
+	}
+
+	result := &PackageEvidence{OccurrenceCount: len(evidence.Occurrences)}
+	for _, identity := range identities {
+		normalized := PackageIdentity{Field: identity.Field, Confidence: identity.Confidence}
+		for _, method := range identity.Methods {
+			normalized.Techniques = append(normalized.Techniques, method.Technique)
+			// Without an overall confidence, the field is as certain as its best method
+			if identity.Confidence == nil && method.Confidence != nil && (normalized.Confidence == nil || *method.Confidence > *normalized.Confidence) {
+				normalized.Confidence = method.Confidence
+			}
+		}
+		if c := normalized.Confidence; c != nil && (result.IdentityConfidence == nil || *c > *result.IdentityConfidence) {
+			result.IdentityConfidence = c
+		}
+		result.Identity = append(result.Identity, normalized)
+	}
+	for i, occurrence := range evidence.Occurrences {
+		if i == maxEvidenceOccurrences {
+			break
+		}
+		result.Occurrences = append(result.Occurrences, occurrence.Location)
+	}
+
+	if result.Identity == nil && result.OccurrenceCount == 0 {
+		return nil
+	}
+	return result
+}
+
+// normalizePedigree returns the pedigree of a component, nil without any
+func normalizePedigree(pedigree *CycloneDXPedigree) *PackagePedigree {
+	if pedigree == nil {
+		return nil
+	}
+
+	components := func(components []CycloneDXComponent) []string {
+		var names []string
+		for _, c := range components {
+			if c.Purl != "" {
+				names = append(names, c.Purl)
+			} else {
+				names = append(names, c.Name+"@"+c.Version)
+			}
+		}
+		return names
+	}
+
+	result := &PackagePedigree{
+		Ancestors:   components(pedigree.Ancestors),
+		Descendants: components(pedigree.Descendants),
+		Variants:    components(pedigree.Variants),
+		Notes:       pedigree.Notes,
+	}
+	for _, commit := range pedigree.Commits {
+		if commit.UID != "" {
+			result.Commits = append(result.Commits, commit.UID)
+		} else if commit.URL != "" {
+			result.Commits = append(result.Commits, commit.URL)
+		}
+	}
+	for _, patch := range pedigree.Patches {
+		p := PackagePatch{Type: patch.Type}
+		for _, issue := range patch.Resolves {
+			if issue.ID != "" {
+				p.Resolves = append(p.Resolves, issue.ID)
+			}
+		}
+		result.Patches = append(result.Patches, p)
+	}
+
+	if result.Ancestors == nil && result.Descendants == nil && result.Variants == nil && result.Commits == nil && result.Patches == nil && result.Notes == "" {
+		return nil
+	}
+	return result
+}
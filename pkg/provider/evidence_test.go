@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testEvidenceBOM = `{"bomFormat": "CycloneDX", "specVersion": "1.6", "components": [
+	{"name": "openssl", "version": "3.0.13", "purl": "pkg:deb/debian/openssl@3.0.13",
+	 "evidence": {
+		"identity": [
+			{"field": "purl", "confidence": 0.4, "methods": [{"technique": "filename", "confidence": 0.4}]},
+			{"field": "name", "methods": [{"technique": "manifest-analysis", "confidence": 0.9}, {"technique": "hash-comparison", "confidence": 0.7}]}
+		],
+		"occurrences": [{"location": "/usr/lib/libssl.so.3"}, {"location": "/usr/lib/libcrypto.so.3"}]
+	 },
+	 "pedigree": {
+		"ancestors": [{"name": "openssl", "version": "3.0.13", "purl": "pkg:generic/openssl@3.0.13"}],
+		"variants": [{"name": "openssl-fips", "version": "3.0.13"}],
+		"commits": [{"uid": "abc123"}, {"url": "https://git.example.com/c/def456"}],
+		"patches": [{"type": "backport", "resolves": [{"id": "CVE-2024-0727"}]}],
+		"notes": "Debian build"
+	 }},
+	{"name": "zlib", "version": "1.3", "evidence": {"identity": {"field": "name", "confidence": 0.8}}},
+	{"name": "musl", "version": "1.2.4"}
+]}`
+
+func TestExtractComponentEvidence(t *testing.T) {
+	verifier := &AttestationVerifier{componentEvidence: true}
+	unified, err := verifier.extractAndNormalizeCycloneDX(json.RawMessage(testEvidenceBOM))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+
+	openssl := unified.Packages[0]
+	if openssl.Evidence == nil || openssl.Evidence.IdentityConfidence == nil || *openssl.Evidence.IdentityConfidence != 0.9 {
+		t.Fatalf("Expected the best method confidence 0.9, got %+v", openssl.Evidence)
+	}
+	if len(openssl.Evidence.Identity) != 2 || strings.Join(openssl.Evidence.Identity[1].Techniques, ",") != "manifest-analysis,hash-comparison" {
+		t.Errorf("Expected both identities with their techniques, got %+v", openssl.Evidence.Identity)
+	}
+	if strings.Join(openssl.Evidence.Occurrences, ",") != "/usr/lib/libssl.so.3,/usr/lib/libcrypto.so.3" || openssl.Evidence.OccurrenceCount != 2 {
+		t.Errorf("Expected the occurrences, got %+v", openssl.Evidence)
+	}
+
+	pedigree := openssl.Pedigree
+	if pedigree == nil {
+		t.Fatal("Expected the pedigree")
+	}
+	if strings.Join(pedigree.Ancestors, ",") != "pkg:generic/openssl@3.0.13" || strings.Join(pedigree.Variants, ",") != "openssl-fips@3.0.13" {
+		t.Errorf("Expected the related components, got %+v", pedigree)
+	}
+	if strings.Join(pedigree.Commits, ",") != "abc123,https://git.example.com/c/def456" || pedigree.Notes != "Debian build" {
+		t.Errorf("Expected the commits and notes, got %+v", pedigree)
+	}
+	if len(pedigree.Patches) != 1 || pedigree.Patches[0].Type != "backport" || strings.Join(pedigree.Patches[0].Resolves, ",") != "CVE-2024-0727" {
+		t.Errorf("Expected the patch, got %+v", pedigree.Patches)
+	}
+
+	// CycloneDX 1.5 records a single identity object
+	if zlib := unified.Packages[1]; zlib.Evidence == nil || *zlib.Evidence.IdentityConfidence != 0.8 || zlib.Pedigree != nil {
+		t.Errorf("Expected the 1.5 identity, got %+v", zlib)
+	}
+	if musl := unified.Packages[2]; musl.Evidence != nil || musl.Pedigree != nil {
+		t.Errorf("Expected no evidence, got %+v", musl)
+	}
+
+	unified, err = (&AttestationVerifier{}).extractAndNormalizeCycloneDX(json.RawMessage(testEvidenceBOM))
+	if err != nil || unified.Packages[0].Evidence != nil || unified.Packages[0].Pedigree != nil {
+		t.Errorf("Expected no evidence unless enabled, got %+v, %v", unified.Packages[0], err)
+	}
+}
+
+func TestNormalizeEvidenceOccurrences(t *testing.T) {
+	evidence := &CycloneDXEvidence{}
+	for i := 0; i < maxEvidenceOccurrences+5; i++ {
+		evidence.Occurrences = append(evidence.Occurrences, CycloneDXOccurrence{Location: "/lib"})
+	}
+	normalized := normalizeEvidence(evidence)
+	if len(normalized.Occurrences) != maxEvidenceOccurrences || normalized.OccurrenceCount != maxEvidenceOccurrences+5 {
+		t.Errorf("Expected %d of %d occurrences, got %d of %d", maxEvidenceOccurrences, maxEvidenceOccurrences+5, len(normalized.Occurrences), normalized.OccurrenceCount)
+	}
+	if normalized.IdentityConfidence != nil {
+		t.Errorf("Expected no confidence without identity evidence, got %v", *normalized.IdentityConfidence)
+	}
+}
+
+func TestEvaluateIdentityConfidence(t *testing.T) {
+	verifier := &AttestationVerifier{componentEvidence: true}
+	sbom, err := verifier.extractAndNormalizeCycloneDX(json.RawMessage(testEvidenceBOM))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+
+	codes := func(req *SARIFRequest) string {
+		var pkgs []string
+		for _, v := range evaluateSBOM(sbom, req) {
+			if v.Code == ViolationLowConfidence {
+				pkgs = append(pkgs, v.pkg)
+			}
+		}
+		return strings.Join(pkgs, ",")
+	}
+
+	if got := codes(&SARIFRequest{MinIdentityConfidence: 0.85}); got != "zlib,musl" {
+		t.Errorf("Expected zlib and the package without evidence to fail, got %s", got)
+	}
+	if got := codes(&SARIFRequest{MinIdentityConfidence: 0.85, CriticalPackages: []string{"openssl", "zlib"}}); got != "zlib" {
+		t.Errorf("Expected only the critical zlib to fail, got %s", got)
+	}
+	if got := codes(&SARIFRequest{}); got != "" {
+		t.Errorf("Expected no check without a minimum, got %s", got)
+	}
+}
@@ -0,0 +1,331 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DefaultExceptionsReloadInterval is how often the exceptions file is checked for changes
+const DefaultExceptionsReloadInterval = 30 * time.Second
+
+// exceptionAllViolations in an exception's violations covers every violation of the image
+const exceptionAllViolations = "*"
+
+// Violation codes of the policy template rules, which exceptions can cover besides provider error codes
+const (
+	ViolationProhibitedPackage = "PROHIBITED_PACKAGE"
+	ViolationProhibitedLicense = "PROHIBITED_LICENSE"
+	ViolationDisallowedLicense = "DISALLOWED_LICENSE"
+	ViolationEmptySBOM         = "EMPTY_SBOM"
+	ViolationUnregisteredImage = "UNREGISTERED_IMAGE"
+	ViolationLowConfidence     = "LOW_IDENTITY_CONFIDENCE"
+	ViolationUnverifiedSBOM    = "UNVERIFIED_SBOM"
+)
+
+// PolicyException turns specific violations of matching images into warnings until it expires
+type PolicyException struct {
+	// Image is a repository (e.g. "ghcr.io/org/app"), a prefix ending in "/*" (e.g. "ghcr.io/org/*")
+	// or an image digest (e.g. "sha256:abc...")
+	Image      string    `json:"image"`
+	Violations []string  `json:"violations"` // Violation codes covered, e.g. "ERR_IDENTITY_MISMATCH", or "*"
+	Reason     string    `json:"reason"`
+	Approver   string    `json:"approver"`
+	Expires    time.Time `json:"expires"`
+}
+
+// validate checks an exception record and normalizes its image pattern
+func (e *PolicyException) validate() error {
+	switch {
+	case e.Image == "":
+		return errors.New("image is required")
+	case len(e.Violations) == 0:
+		return errors.New("violations is required, use \"*\" to cover every violation")
+	case e.Reason == "":
+		return errors.New("reason is required")
+	case e.Approver == "":
+		return errors.New("approver is required")
+	case e.Expires.IsZero():
+		return errors.New("expires is required")
+	}
+
+	if strings.HasPrefix(e.Image, "sha256:") {
+		if _, err := v1.NewHash(e.Image); err != nil {
+			return fmt.Errorf("invalid image digest: %w", err)
+		}
+		return nil
+	}
+	if prefix, ok := strings.CutSuffix(e.Image, attestationRepoWildcard); ok {
+		if !strings.Contains(prefix, "/") {
+			registry, err := name.NewRegistry(prefix)
+			if err != nil {
+				return fmt.Errorf("invalid image prefix: %w", err)
+			}
+			e.Image = registry.Name() + attestationRepoWildcard
+			return nil
+		}
+		repo, err := name.NewRepository(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid image prefix: %w", err)
+		}
+		e.Image = repo.Name() + attestationRepoWildcard
+		return nil
+	}
+	repo, err := name.NewRepository(e.Image)
+	if err != nil {
+		return fmt.Errorf("invalid image repository: %w", err)
+	}
+	e.Image = repo.Name()
+	return nil
+}
+
+// matches reports whether the exception applies to image
+func (e *PolicyException) matches(image string) bool {
+	if strings.HasPrefix(e.Image, "sha256:") {
+		return keyDigest(image) == e.Image
+	}
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+	repo := ref.Context().Name()
+	if prefix, ok := strings.CutSuffix(e.Image, attestationRepoWildcard); ok {
+		return strings.HasPrefix(repo, prefix+"/")
+	}
+	return repo == e.Image
+}
+
+// covers reports whether the exception covers violations with code
+func (e *PolicyException) covers(code string) bool {
+	for _, v := range e.Violations {
+		if v == code || v == exceptionAllViolations {
+			return true
+		}
+	}
+	return false
+}
+
+// exceptionStore holds the policy exceptions of a JSON file, reloaded when it changes so a
+// mounted ConfigMap can be edited without a restart. A nil store never matches anything.
+type exceptionStore struct {
+	path string
+	now  func() time.Time
+
+	mu         sync.RWMutex
+	exceptions []PolicyException
+	modTime    time.Time
+
+	hits atomic.Int64 // Violations turned into warnings
+}
+
+// newExceptionStore creates a store for the exceptions file at path
+func newExceptionStore(path string) *exceptionStore {
+	return &exceptionStore{path: path, now: time.Now}
+}
+
+// loadExceptions reads and validates a JSON array of exception records
+func loadExceptions(path string) ([]PolicyException, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exceptions file: %w", err)
+	}
+
+	var exceptions []PolicyException
+	if err := json.Unmarshal(data, &exceptions); err != nil {
+		return nil, fmt.Errorf("failed to parse exceptions file: %w", err)
+	}
+	for i := range exceptions {
+		if err := exceptions[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid exception %d (%s): %w", i, exceptions[i].Image, err)
+		}
+		// Reported in responses, where timestamps are UTC
+		exceptions[i].Expires = exceptions[i].Expires.UTC()
+	}
+	return exceptions, nil
+}
+
+// Reload re-reads the exceptions file if it changed. An invalid file keeps the previous exceptions.
+func (s *exceptionStore) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read exceptions file: %w", err)
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	exceptions, err := loadExceptions(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.exceptions = exceptions
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	active, expired := s.Counts()
+	log.Printf("Loaded %d policy exceptions from %s (%d active, %d expired)", len(exceptions), s.path, active, expired)
+	return nil
+}
+
+// Run reloads the exceptions file every interval until ctx is cancelled
+func (s *exceptionStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("Warning: %v, keeping the previous policy exceptions", err)
+		}
+	}
+}
+
+// Active returns the unexpired exceptions matching image
+func (s *exceptionStore) Active(image string) []PolicyException {
+	if s == nil {
+		return nil
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var active []PolicyException
+	for _, e := range s.exceptions {
+		if now.Before(e.Expires) && e.matches(image) {
+			active = append(active, e)
+		}
+	}
+	return active
+}
+
+// Covers reports whether an active exception of image covers violations with code
+func (s *exceptionStore) Covers(image, code string) bool {
+	for _, e := range s.Active(image) {
+		if e.covers(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of active and expired exceptions
+func (s *exceptionStore) Counts() (active, expired int) {
+	if s == nil {
+		return 0, 0
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.exceptions {
+		if now.Before(e.Expires) {
+			active++
+		} else {
+			expired++
+		}
+	}
+	return active, expired
+}
+
+// apply turns the violations of item covered by active exceptions into warnings and lists
+// those exceptions in the value, so policies skip the rules they cover. Violations left
+// uncovered fail the item, unless the key asked for all violations (violations=all).
+func (s *exceptionStore) apply(item Item, opts keyOptions) Item {
+	if s == nil || item.Error != "" || item.Value == "" || item.Value == pendingValue {
+		return item
+	}
+
+	image := strings.SplitN(item.Key, "|", 2)[0]
+	active := s.Active(image)
+	if len(active) == 0 && !strings.Contains(item.Value, `"violations"`) {
+		return item
+	}
+
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &unified); err != nil {
+		return item
+	}
+
+	var remaining []Violation
+	for _, v := range unified.Violations {
+		if e := coveringException(active, v.Code); e != nil {
+			v.Exception = e
+			unified.Warnings = append(unified.Warnings, v)
+			s.hits.Add(1)
+			log.Printf("Violation %s of %s allowed by exception until %s (approver: %s, reason: %s)",
+				v.Code, image, e.Expires.Format(time.RFC3339), e.Approver, e.Reason)
+			continue
+		}
+		remaining = append(remaining, v)
+	}
+	unified.Violations = remaining
+	unified.Exceptions = active
+
+	if len(remaining) > 0 && !opts.allViolations {
+		// Recovered for an exception that has since expired: fail as without exceptions
+		msgs := make([]string, len(remaining))
+		for i, v := range remaining {
+			msgs[i] = v.Source + ": " + v.Message
+		}
+		err := &VerificationError{Code: remaining[0].Code, Err: errors.New(strings.Join(msgs, "; "))}
+		return Item{Key: item.Key, Error: formatItemError("Failed to verify attestation or extract SBOM", err)}
+	}
+
+	value, err := json.Marshal(&unified)
+	if err != nil {
+		return item
+	}
+	item.Value = string(value)
+	return item
+}
+
+// coveringException returns the first exception covering code, or nil
+func coveringException(exceptions []PolicyException, code string) *PolicyException {
+	for i := range exceptions {
+		if exceptions[i].covers(code) {
+			return &exceptions[i]
+		}
+	}
+	return nil
+}
+
+// writeExceptionMetrics writes the exception gauges and counter in Prometheus text format
+func (s *exceptionStore) writeExceptionMetrics(w io.Writer) {
+	if s == nil {
+		return
+	}
+
+	active, expired := s.Counts()
+	const name = "sbom_provider_policy_exceptions"
+	fmt.Fprintf(w, "# HELP %s Policy exceptions loaded, by state.\n# TYPE %s gauge\n", name, name)
+	fmt.Fprintf(w, "%s{state=\"active\"} %d\n", name, active)
+	fmt.Fprintf(w, "%s{state=\"expired\"} %d\n", name, expired)
+
+	const hits = "sbom_provider_policy_exception_hits_total"
+	fmt.Fprintf(w, "# HELP %s Violations turned into warnings by policy exceptions.\n# TYPE %s counter\n%s %d\n",
+		hits, hits, hits, s.hits.Load())
+}
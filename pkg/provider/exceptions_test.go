@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyExceptionMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		image   string
+		want    bool
+	}{
+		{"ghcr.io/org/app", "ghcr.io/org/app:v1", true},
+		{"ghcr.io/org/app", "ghcr.io/org/app@" + testDigest, true},
+		{"ghcr.io/org/app", "ghcr.io/org/app-next:v1", false},
+		{"ghcr.io/org/*", "ghcr.io/org/team/app:v1", true},
+		{"ghcr.io/org/*", "ghcr.io/other/app:v1", false},
+		{"ghcr.io/*", "ghcr.io/org/app:v1", true},
+		{"nginx", "index.docker.io/library/nginx:1.25", true},
+		{testDigest, "ghcr.io/org/app@" + testDigest, true},
+		{testDigest, "ghcr.io/org/app:v1", false},
+	}
+
+	for _, tt := range tests {
+		e := PolicyException{Image: tt.pattern, Violations: []string{"*"}, Reason: "r", Approver: "a", Expires: time.Now()}
+		if err := e.validate(); err != nil {
+			t.Fatalf("%s: unexpected validation error: %v", tt.pattern, err)
+		}
+		if got := e.matches(tt.image); got != tt.want {
+			t.Errorf("%s matches %s: expected %v, got %v", tt.pattern, tt.image, tt.want, got)
+		}
+	}
+}
+
+func TestPolicyExceptionValidate(t *testing.T) {
+	valid := PolicyException{Image: "ghcr.io/org/app", Violations: []string{ViolationProhibitedLicense}, Reason: "r", Approver: "a", Expires: time.Now()}
+
+	for name, modify := range map[string]func(*PolicyException){
+		"no image":      func(e *PolicyException) { e.Image = "" },
+		"no violations": func(e *PolicyException) { e.Violations = nil },
+		"no reason":     func(e *PolicyException) { e.Reason = "" },
+		"no approver":   func(e *PolicyException) { e.Approver = "" },
+		"no expiry":     func(e *PolicyException) { e.Expires = time.Time{} },
+		"bad digest":    func(e *PolicyException) { e.Image = "sha256:abc" },
+		"bad image":     func(e *PolicyException) { e.Image = "INVALID::REPO" },
+	} {
+		e := valid
+		modify(&e)
+		if err := e.validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestExceptionStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exceptions.json")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write exceptions: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newExceptionStore(path)
+	store.now = func() time.Time { return now }
+
+	write(`[
+		{"image": "ghcr.io/org/app", "violations": ["PROHIBITED_LICENSE"], "reason": "relicensing", "approver": "sec", "expires": "2025-07-01T00:00:00Z"},
+		{"image": "ghcr.io/org/old", "violations": ["*"], "reason": "legacy", "approver": "sec", "expires": "2025-05-01T00:00:00Z"}
+	]`, now.Add(-time.Hour))
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Failed to load exceptions: %v", err)
+	}
+	if active, expired := store.Counts(); active != 1 || expired != 1 {
+		t.Errorf("Expected 1 active and 1 expired exception, got %d and %d", active, expired)
+	}
+	if !store.Covers("ghcr.io/org/app:v1", ViolationProhibitedLicense) || store.Covers("ghcr.io/org/app:v1", ErrCodeIdentityMismatch) {
+		t.Error("Expected the active exception to cover only its violations")
+	}
+	if store.Covers("ghcr.io/org/old:v1", ViolationProhibitedLicense) {
+		t.Error("Expected expired exceptions not to apply")
+	}
+
+	// An invalid file keeps the previous exceptions
+	write(`[{"image": "ghcr.io/org/app", "violations": ["*"]}]`, now)
+	if err := store.Reload(); err == nil {
+		t.Error("Expected an error for an exception without reason, approver and expiry")
+	}
+	if active, _ := store.Counts(); active != 1 {
+		t.Errorf("Expected the previous exceptions to be kept, got %d active", active)
+	}
+
+	var nilStore *exceptionStore
+	if nilStore.Covers("ghcr.io/org/app:v1", "*") {
+		t.Error("Expected a nil store not to cover anything")
+	}
+}
+
+func TestExceptionStoreApply(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := newExceptionStore("")
+	store.now = func() time.Time { return now }
+	store.exceptions = []PolicyException{{
+		Image:      "ghcr.io/org/app",
+		Violations: []string{ErrCodeIdentityMismatch},
+		Reason:     "pipeline migration",
+		Approver:   "sec",
+		Expires:    now.Add(24 * time.Hour),
+	}}
+
+	key := "ghcr.io/org/app@" + testDigest + "|[]|ci@example.com|https://issuer"
+	value, err := json.Marshal(&UnifiedSBOM{
+		Format:     "spdx",
+		Packages:   []UnifiedPackage{},
+		Violations: []Violation{{Code: ErrCodeIdentityMismatch, Source: AttestationSourceTag, Message: "none of the expected identities matched"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal SBOM: %v", err)
+	}
+	item := Item{Key: key, Value: string(value)}
+
+	got := store.apply(item, keyOptions{})
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(got.Value), &unified); err != nil {
+		t.Fatalf("Expected an SBOM value, got %+v", got)
+	}
+	if len(unified.Violations) != 0 || len(unified.Warnings) != 1 || unified.Warnings[0].Exception == nil {
+		t.Errorf("Expected the violation to become a warning, got %+v", unified)
+	}
+	if len(unified.Exceptions) != 1 || unified.Exceptions[0].Approver != "sec" {
+		t.Errorf("Expected the active exception to be listed, got %+v", unified.Exceptions)
+	}
+
+	var buf bytes.Buffer
+	store.writeExceptionMetrics(&buf)
+	for _, line := range []string{
+		`sbom_provider_policy_exceptions{state="active"} 1`,
+		`sbom_provider_policy_exception_hits_total 1`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, buf.String())
+		}
+	}
+
+	// Once expired the cached result fails again, unless all violations were requested
+	now = now.Add(48 * time.Hour)
+	got = store.apply(item, keyOptions{})
+	if !strings.HasPrefix(got.Error, ErrCodeIdentityMismatch+": ") || got.Value != "" {
+		t.Errorf("Expected an identity mismatch error once the exception expired, got %+v", got)
+	}
+	got = store.apply(item, keyOptions{allViolations: true})
+	if got.Error != "" || !strings.Contains(got.Value, `"violations"`) {
+		t.Errorf("Expected the violations to be returned with violations=all, got %+v", got)
+	}
+
+	// Items without violations or exceptions are left untouched
+	plain := Item{Key: "ghcr.io/org/other:v1|[]||", Value: `{"format":"spdx","packages":[]}`}
+	if got := store.apply(plain, keyOptions{}); got != plain {
+		t.Errorf("Expected the item to be unchanged, got %+v", got)
+	}
+}
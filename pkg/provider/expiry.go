@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+)
+
+// Kinds of expiring trust material
+const (
+	expiryKindFulcioCA = "fulcio-ca"
+	expiryKindTSA      = "tsa"
+	expiryKindRekorLog = "rekor-log"
+	expiryKindCTLog    = "ct-log"
+	expiryKindTUFRoot  = "tuf-root"
+	expiryKindTLSCert  = "tls-cert"
+)
+
+// DefaultExpiryWarning is how long before trust material expires warnings are logged
+const DefaultExpiryWarning = 30 * 24 * time.Hour
+
+// DefaultExpiryCheckInterval is how often trust material expiry is checked by default
+const DefaultExpiryCheckInterval = 12 * time.Hour
+
+// trustExpiry is the expiry of one piece of trust material
+type trustExpiry struct {
+	kind     string
+	name     string
+	notAfter time.Time
+}
+
+// earliestNotAfter returns the earliest expiry among certs, or the zero time when there are none
+func earliestNotAfter(certs ...*x509.Certificate) time.Time {
+	var earliest time.Time
+	for _, cert := range certs {
+		if cert != nil && (earliest.IsZero() || cert.NotAfter.Before(earliest)) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
+
+// authorityExpiry returns when a certificate authority stops being usable: the earliest of its
+// validity period end and its chain's expiry. Authorities whose validity period already ended
+// are retired (kept to verify old signatures) and report false.
+func authorityExpiry(validityEnd, now time.Time, chain ...*x509.Certificate) (time.Time, bool) {
+	if !validityEnd.IsZero() && validityEnd.Before(now) {
+		return time.Time{}, false
+	}
+	expiry := earliestNotAfter(chain...)
+	if expiry.IsZero() || (!validityEnd.IsZero() && validityEnd.Before(expiry)) {
+		expiry = validityEnd
+	}
+	return expiry, !expiry.IsZero()
+}
+
+// trustedRootExpiries lists the expiring material of roots still in use at now: certificate
+// authority chains, transparency logs with a validity period end and the TUF root metadata
+func trustedRootExpiries(roots []namedTrustedRoot, now time.Time) []trustExpiry {
+	var expiries []trustExpiry
+	for _, tr := range roots {
+		for _, ca := range tr.material.FulcioCertificateAuthorities() {
+			fca, ok := ca.(*root.FulcioCertificateAuthority)
+			if !ok {
+				continue
+			}
+			chain := append([]*x509.Certificate{fca.Root}, fca.Intermediates...)
+			if expiry, ok := authorityExpiry(fca.ValidityPeriodEnd, now, chain...); ok {
+				expiries = append(expiries, trustExpiry{expiryKindFulcioCA, tr.name + " " + fca.URI, expiry})
+			}
+		}
+
+		for _, ta := range tr.material.TimestampingAuthorities() {
+			tsa, ok := ta.(*root.SigstoreTimestampingAuthority)
+			if !ok {
+				continue
+			}
+			chain := append([]*x509.Certificate{tsa.Root, tsa.Leaf}, tsa.Intermediates...)
+			if expiry, ok := authorityExpiry(tsa.ValidityPeriodEnd, now, chain...); ok {
+				expiries = append(expiries, trustExpiry{expiryKindTSA, tr.name + " " + tsa.URI, expiry})
+			}
+		}
+
+		for kind, logs := range map[string]map[string]*root.TransparencyLog{
+			expiryKindRekorLog: tr.material.RekorLogs(),
+			expiryKindCTLog:    tr.material.CTLogs(),
+		} {
+			for _, tlog := range logs {
+				// Logs without a validity period end are active indefinitely
+				if tlog.ValidityPeriodEnd.IsZero() || tlog.ValidityPeriodEnd.Before(now) {
+					continue
+				}
+				expiries = append(expiries, trustExpiry{kind, tr.name + " " + tlog.BaseURL, tlog.ValidityPeriodEnd})
+			}
+		}
+
+		if !tr.tufRootExpires.IsZero() {
+			expiries = append(expiries, trustExpiry{expiryKindTUFRoot, tr.name, tr.tufRootExpires})
+		}
+	}
+	return expiries
+}
+
+// tufRootExpiry reads the expiry of the TUF root metadata cached by a fetch with opts
+func tufRootExpiry(opts *tuf.Options) (time.Time, error) {
+	if opts.DisableLocalCache {
+		return time.Time{}, fmt.Errorf("the TUF local cache is disabled")
+	}
+
+	data, err := os.ReadFile(filepath.Join(opts.CachePath, tuf.URLToPath(opts.RepositoryBaseURL), "root.json"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var metadata struct {
+		Signed struct {
+			Expires time.Time `json:"expires"`
+		} `json:"signed"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse TUF root metadata: %w", err)
+	}
+	if metadata.Signed.Expires.IsZero() {
+		return time.Time{}, fmt.Errorf("TUF root metadata has no expiry")
+	}
+	return metadata.Signed.Expires, nil
+}
+
+// certificateFileExpiry returns the expiry of the leaf (first) certificate in a PEM file
+func certificateFileExpiry(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("no certificate found in %s", path)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse certificate in %s: %w", path, err)
+		}
+		return cert.NotAfter, nil
+	}
+}
+
+// trustExpiries lists the expiry of the trusted root material currently in use
+func (v *AttestationVerifier) trustExpiries(now time.Time) []trustExpiry {
+	return trustedRootExpiries(v.currentTrustedRoots(), now)
+}
+
+// trustExpiries lists the expiry of the trust material in use by the server: the verifier's
+// trusted roots and the serving TLS certificate
+func (s *Server) trustExpiries(now time.Time) []trustExpiry {
+	expiries := s.verifier.trustExpiries(now)
+	if s.tlsCert != "" {
+		notAfter, err := certificateFileExpiry(s.tlsCert)
+		if err != nil {
+			log.Printf("Warning: cannot read the TLS certificate expiry: %v", err)
+		} else {
+			expiries = append(expiries, trustExpiry{expiryKindTLSCert, s.tlsCert, notAfter})
+		}
+	}
+	return expiries
+}
+
+// expiryMonitor periodically collects trust material expiries, warning about material
+// expiring within the warning window. A nil monitor never reports anything.
+type expiryMonitor struct {
+	interval time.Duration
+	warning  time.Duration
+	collect  func(now time.Time) []trustExpiry
+	now      func() time.Time
+
+	mu       sync.Mutex
+	expiries []trustExpiry
+}
+
+// newExpiryMonitor creates a monitor checking the material returned by collect every interval
+func newExpiryMonitor(interval, warning time.Duration, collect func(now time.Time) []trustExpiry) *expiryMonitor {
+	return &expiryMonitor{
+		interval: interval,
+		warning:  warning,
+		collect:  collect,
+		now:      time.Now,
+	}
+}
+
+// Run checks right away and then every interval until ctx is done
+func (m *expiryMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check collects the current expiries and logs warnings for material expiring soon
+func (m *expiryMonitor) check() {
+	now := m.now()
+	expiries := m.collect(now)
+	sort.Slice(expiries, func(i, j int) bool { return expiries[i].notAfter.Before(expiries[j].notAfter) })
+
+	for _, e := range expiries {
+		switch remaining := e.notAfter.Sub(now); {
+		case remaining <= 0:
+			log.Printf("Warning: %s %s expired on %s", e.kind, e.name, e.notAfter.Format(time.RFC3339))
+		case remaining <= m.warning:
+			log.Printf("Warning: %s %s expires in %d days (%s)", e.kind, e.name,
+				int(remaining.Hours()/24), e.notAfter.Format(time.RFC3339))
+		}
+	}
+
+	m.mu.Lock()
+	m.expiries = expiries
+	m.mu.Unlock()
+}
+
+// Expiries returns the expiries found by the last check, earliest first
+func (m *expiryMonitor) Expiries() []trustExpiry {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expiries
+}
+
+// writeExpiryMetrics writes the days until each piece of trust material expires
+// (negative once expired) in the Prometheus text format
+func (m *expiryMonitor) writeExpiryMetrics(w io.Writer) {
+	expiries := m.Expiries()
+	if expiries == nil {
+		return
+	}
+
+	const name = "sbom_provider_trust_material_expiry_days"
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name,
+		"Days until trust material expires, by kind and name (negative once expired).", name)
+	now := m.now()
+	for _, e := range expiries {
+		days := math.Round(e.notAfter.Sub(now).Hours()/24*100) / 100
+		fmt.Fprintf(w, "%s{kind=%q,name=%q} %g\n", name, e.kind, e.name, days)
+	}
+}
@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+)
+
+// testCertificate creates a self-signed certificate valid until notAfter
+func testCertificate(t *testing.T, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+// expiringMaterial is trusted material with fixed authorities and logs
+type expiringMaterial struct {
+	root.BaseTrustedMaterial
+	cas   []root.CertificateAuthority
+	tsas  []root.TimestampingAuthority
+	rekor map[string]*root.TransparencyLog
+}
+
+func (m *expiringMaterial) FulcioCertificateAuthorities() []root.CertificateAuthority { return m.cas }
+func (m *expiringMaterial) TimestampingAuthorities() []root.TimestampingAuthority     { return m.tsas }
+func (m *expiringMaterial) RekorLogs() map[string]*root.TransparencyLog               { return m.rekor }
+
+func TestTrustedRootExpiries(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rootCert := testCertificate(t, now.AddDate(5, 0, 0))
+	intermediate := testCertificate(t, now.AddDate(0, 0, 20))
+
+	material := &expiringMaterial{
+		cas: []root.CertificateAuthority{
+			&root.FulcioCertificateAuthority{Root: rootCert, Intermediates: []*x509.Certificate{intermediate}, URI: "https://fulcio"},
+			// Retired, only kept for old signatures
+			&root.FulcioCertificateAuthority{Root: rootCert, ValidityPeriodEnd: now.AddDate(-1, 0, 0), URI: "https://fulcio-old"},
+		},
+		tsas: []root.TimestampingAuthority{
+			&root.SigstoreTimestampingAuthority{Root: rootCert, ValidityPeriodEnd: now.AddDate(0, 2, 0), URI: "https://tsa"},
+		},
+		rekor: map[string]*root.TransparencyLog{
+			"active":  {BaseURL: "https://rekor"},
+			"rotated": {BaseURL: "https://rekor-next", ValidityPeriodEnd: now.AddDate(0, 0, 10)},
+		},
+	}
+	roots := []namedTrustedRoot{{name: "private", material: material, tufRootExpires: now.AddDate(0, 3, 0)}}
+
+	got := make(map[string]time.Time)
+	for _, e := range trustedRootExpiries(roots, now) {
+		got[e.kind+" "+e.name] = e.notAfter
+	}
+
+	want := map[string]time.Time{
+		"fulcio-ca private https://fulcio":     intermediate.NotAfter,
+		"tsa private https://tsa":              now.AddDate(0, 2, 0),
+		"rekor-log private https://rekor-next": now.AddDate(0, 0, 10),
+		"tuf-root private":                     now.AddDate(0, 3, 0),
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d expiries, got %v", len(want), got)
+	}
+	for name, notAfter := range want {
+		if !got[name].Equal(notAfter) {
+			t.Errorf("Expected %s to expire at %v, got %v", name, notAfter, got[name])
+		}
+	}
+}
+
+func TestCertificateFileExpiry(t *testing.T) {
+	notAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second).UTC()
+	cert := testCertificate(t, notAfter)
+
+	path := filepath.Join(t.TempDir(), "tls.crt")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	got, err := certificateFileExpiry(path)
+	if err != nil {
+		t.Fatalf("Failed to read certificate expiry: %v", err)
+	}
+	if !got.Equal(notAfter) {
+		t.Errorf("Expected %v, got %v", notAfter, got)
+	}
+
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := certificateFileExpiry(path); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+}
+
+func TestTUFRootExpiry(t *testing.T) {
+	opts := &tuf.Options{CachePath: t.TempDir(), RepositoryBaseURL: "https://tuf.example.com"}
+	dir := filepath.Join(opts.CachePath, tuf.URLToPath(opts.RepositoryBaseURL))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+
+	if _, err := tufRootExpiry(opts); err == nil {
+		t.Error("Expected an error without cached root metadata")
+	}
+
+	metadata := `{"signed":{"_type":"root","expires":"2026-01-22T13:05:59Z"},"signatures":[]}`
+	if err := os.WriteFile(filepath.Join(dir, "root.json"), []byte(metadata), 0o600); err != nil {
+		t.Fatalf("Failed to write root metadata: %v", err)
+	}
+	got, err := tufRootExpiry(opts)
+	if err != nil {
+		t.Fatalf("Failed to read TUF root expiry: %v", err)
+	}
+	if want := time.Date(2026, 1, 22, 13, 5, 59, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestExpiryMonitor(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	monitor := newExpiryMonitor(time.Hour, DefaultExpiryWarning, func(time.Time) []trustExpiry {
+		return []trustExpiry{
+			{expiryKindTLSCert, "/etc/tls/tls.crt", now.Add(-12 * time.Hour)},
+			{expiryKindTUFRoot, "public-good", now.AddDate(0, 6, 0)},
+			{expiryKindFulcioCA, "public-good https://fulcio", now.AddDate(0, 0, 10)},
+		}
+	})
+	monitor.now = func() time.Time { return now }
+
+	var nilMonitor *expiryMonitor
+	var buf bytes.Buffer
+	nilMonitor.writeExpiryMetrics(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no metrics from a nil monitor, got %q", buf.String())
+	}
+
+	monitor.check()
+	expiries := monitor.Expiries()
+	if len(expiries) != 3 || expiries[0].kind != expiryKindTLSCert || expiries[2].kind != expiryKindTUFRoot {
+		t.Errorf("Expected expiries sorted earliest first, got %v", expiries)
+	}
+
+	monitor.writeExpiryMetrics(&buf)
+	out := buf.String()
+	for _, line := range []string{
+		`sbom_provider_trust_material_expiry_days{kind="tls-cert",name="/etc/tls/tls.crt"} -0.5`,
+		`sbom_provider_trust_material_expiry_days{kind="fulcio-ca",name="public-good https://fulcio"} 10`,
+		`sbom_provider_trust_material_expiry_days{kind="tuf-root",name="public-good"} 183`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Request classes competing for verification slots
+const (
+	// RequestClassAdmission is live admission traffic from the Gatekeeper webhook (the default)
+	RequestClassAdmission = "admission"
+	// RequestClassAudit is Gatekeeper audit traffic replaying existing resources
+	RequestClassAudit = "audit"
+	// RequestClassBatch is bulk traffic from scripts and other API clients
+	RequestClassBatch = "batch"
+)
+
+// RequestClassHeader tags a /verify request with its class. The "class" query parameter is
+// accepted too, for callers such as Gatekeeper that can only be configured with a URL.
+const RequestClassHeader = "X-SBOM-Provider-Class"
+
+// DefaultRequestClassWeights favours admission so audit replays never hold up live requests
+var DefaultRequestClassWeights = map[string]int{
+	RequestClassAdmission: 8,
+	RequestClassAudit:     1,
+	RequestClassBatch:     1,
+}
+
+// requestClass returns the class a /verify request is tagged with
+func requestClass(r *http.Request) string {
+	class := r.Header.Get(RequestClassHeader)
+	if class == "" {
+		class = r.URL.Query().Get("class")
+	}
+
+	switch class {
+	case RequestClassAdmission, RequestClassAudit, RequestClassBatch:
+		return class
+	case "":
+		return RequestClassAdmission
+	}
+	log.Printf("Warning: unknown request class %q, treating it as %s", class, RequestClassAdmission)
+	return RequestClassAdmission
+}
+
+// parseRequestClassWeights parses "class=weight" entries on top of the default weights
+func parseRequestClassWeights(specs []string) (map[string]int, error) {
+	weights := make(map[string]int, len(DefaultRequestClassWeights))
+	for class, weight := range DefaultRequestClassWeights {
+		weights[class] = weight
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		class, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid request class weight %q (expected class=weight)", spec)
+		}
+		if _, known := DefaultRequestClassWeights[class]; !known {
+			return nil, fmt.Errorf("unknown request class %q", class)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 1 {
+			return nil, fmt.Errorf("invalid weight %q for request class %s (expected a positive integer)", value, class)
+		}
+		weights[class] = weight
+	}
+	return weights, nil
+}
+
+// fairScheduler bounds concurrent verifications and hands out free slots to waiting
+// request classes in proportion to their weights (stride scheduling), so a flood of one
+// class only delays the others by their share. A nil scheduler never blocks.
+type fairScheduler struct {
+	mu      sync.Mutex
+	free    int
+	weights map[string]int
+	queues  map[string][]chan struct{}
+	pass    map[string]float64 // Virtual time of each class, advanced by 1/weight per grant
+	vtime   float64            // Virtual time of the last grant
+}
+
+// newFairScheduler creates a scheduler allowing limit concurrent verifications
+func newFairScheduler(limit int, weights map[string]int) *fairScheduler {
+	return &fairScheduler{
+		free:    limit,
+		weights: weights,
+		queues:  make(map[string][]chan struct{}),
+		pass:    make(map[string]float64),
+	}
+}
+
+// Acquire blocks until class is granted a verification slot or ctx is done.
+// The returned function releases the slot.
+func (f *fairScheduler) Acquire(ctx context.Context, class string) (func(), error) {
+	if f == nil {
+		return func() {}, nil
+	}
+
+	f.mu.Lock()
+	// A class waking up from idle must not spend credit banked while it had nothing queued
+	if len(f.queues[class]) == 0 && f.pass[class] < f.vtime {
+		f.pass[class] = f.vtime
+	}
+
+	if f.free > 0 && f.waiting() == 0 {
+		f.free--
+		f.grant(class)
+		f.mu.Unlock()
+		return f.release, nil
+	}
+
+	ready := make(chan struct{})
+	f.queues[class] = append(f.queues[class], ready)
+	f.mu.Unlock()
+
+	select {
+	case <-ready:
+		return f.release, nil
+	case <-ctx.Done():
+	}
+
+	f.mu.Lock()
+	stillWaiting := f.dequeue(class, ready)
+	f.mu.Unlock()
+	if !stillWaiting {
+		// The slot was granted while giving up, hand it on
+		f.release()
+	}
+	return nil, fmt.Errorf("timed out waiting for a verification slot (%s traffic): %w", class, ctx.Err())
+}
+
+// release frees a slot, handing it to the next waiter in weighted order
+func (f *fairScheduler) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	next := ""
+	for class, queue := range f.queues {
+		if len(queue) == 0 {
+			continue
+		}
+		if next == "" || f.pass[class] < f.pass[next] || (f.pass[class] == f.pass[next] && class < next) {
+			next = class
+		}
+	}
+	if next == "" {
+		f.free++
+		return
+	}
+
+	ready := f.queues[next][0]
+	f.queues[next] = f.queues[next][1:]
+	f.grant(next)
+	close(ready)
+}
+
+// grant advances the virtual time of class for a granted slot
+func (f *fairScheduler) grant(class string) {
+	weight := f.weights[class]
+	if weight < 1 {
+		weight = 1
+	}
+	f.vtime = f.pass[class]
+	f.pass[class] += 1 / float64(weight)
+}
+
+// dequeue removes a waiter from the queue of class, reporting whether it was still waiting
+func (f *fairScheduler) dequeue(class string, ready chan struct{}) bool {
+	queue := f.queues[class]
+	for i, ch := range queue {
+		if ch == ready {
+			f.queues[class] = append(queue[:i:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// waiting returns the number of queued waiters
+func (f *fairScheduler) waiting() int {
+	n := 0
+	for _, queue := range f.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// Waiting returns the number of queued waiters by class, sorted by class
+func (f *fairScheduler) Waiting() []classCount {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := make([]classCount, 0, len(f.weights))
+	for class := range f.weights {
+		counts = append(counts, classCount{class: class, count: len(f.queues[class])})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].class < counts[j].class })
+	return counts
+}
+
+// classCount is a per-class counter value
+type classCount struct {
+	class string
+	count int
+}
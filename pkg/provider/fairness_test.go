@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestClass(t *testing.T) {
+	tests := []struct {
+		header string
+		url    string
+		want   string
+	}{
+		{"", "/verify", RequestClassAdmission},
+		{RequestClassAudit, "/verify", RequestClassAudit},
+		{"", "/verify?class=batch", RequestClassBatch},
+		{RequestClassAudit, "/verify?class=batch", RequestClassAudit},
+		{"unknown", "/verify", RequestClassAdmission},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.url, nil)
+		if tt.header != "" {
+			req.Header.Set(RequestClassHeader, tt.header)
+		}
+		if got := requestClass(req); got != tt.want {
+			t.Errorf("requestClass(%q, %q): expected %s, got %s", tt.header, tt.url, tt.want, got)
+		}
+	}
+}
+
+func TestParseRequestClassWeights(t *testing.T) {
+	weights, err := parseRequestClassWeights([]string{"audit=2", ""})
+	if err != nil {
+		t.Fatalf("Failed to parse weights: %v", err)
+	}
+	if weights[RequestClassAudit] != 2 || weights[RequestClassAdmission] != 8 || weights[RequestClassBatch] != 1 {
+		t.Errorf("Expected admission=8,audit=2,batch=1, got %v", weights)
+	}
+	if DefaultRequestClassWeights[RequestClassAudit] != 1 {
+		t.Error("Expected the default weights to be left untouched")
+	}
+
+	for _, spec := range []string{"audit", "audit=0", "audit=x", "replay=1"} {
+		if _, err := parseRequestClassWeights([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestFairSchedulerNil(t *testing.T) {
+	var f *fairScheduler
+	release, err := f.Acquire(context.Background(), RequestClassAudit)
+	if err != nil {
+		t.Fatalf("Expected a nil scheduler never to block, got %v", err)
+	}
+	release()
+	if f.Waiting() != nil {
+		t.Error("Expected no waiting counts from a nil scheduler")
+	}
+}
+
+// totalWaiting returns the number of queued waiters across classes
+func totalWaiting(f *fairScheduler) int {
+	n := 0
+	for _, c := range f.Waiting() {
+		n += c.count
+	}
+	return n
+}
+
+func TestFairSchedulerTimeout(t *testing.T) {
+	f := newFairScheduler(1, DefaultRequestClassWeights)
+	release, err := f.Acquire(context.Background(), RequestClassAdmission)
+	if err != nil {
+		t.Fatalf("Failed to acquire a free slot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.Acquire(ctx, RequestClassAudit); err == nil {
+		t.Fatal("Expected a timeout while the only slot is held")
+	}
+	if totalWaiting(f) != 0 {
+		t.Errorf("Expected the timed out waiter to be dequeued, got %d waiting", totalWaiting(f))
+	}
+
+	release()
+	release, err = f.Acquire(context.Background(), RequestClassAudit)
+	if err != nil {
+		t.Fatalf("Expected the released slot to be free, got %v", err)
+	}
+	release()
+}
+
+func TestFairSchedulerFavoursAdmission(t *testing.T) {
+	f := newFairScheduler(1, DefaultRequestClassWeights)
+	release, err := f.Acquire(context.Background(), RequestClassAdmission)
+	if err != nil {
+		t.Fatalf("Failed to acquire a free slot: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	// Audit floods the queue before admission requests arrive
+	enqueue := func(class string) {
+		queued := totalWaiting(f)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := f.Acquire(context.Background(), class)
+			if err != nil {
+				t.Errorf("Failed to acquire a slot: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, class)
+			mu.Unlock()
+			release()
+		}()
+		for totalWaiting(f) == queued {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		enqueue(RequestClassAudit)
+	}
+	for i := 0; i < 3; i++ {
+		enqueue(RequestClassAdmission)
+	}
+
+	release()
+	wg.Wait()
+
+	// At most one audit verification runs before the admission ones
+	audits := 0
+	for _, class := range order {
+		if class == RequestClassAdmission {
+			break
+		}
+		audits++
+	}
+	if audits > 1 {
+		t.Errorf("Expected admission to be served after at most one audit verification, got order %v", order)
+	}
+	if len(order) != 7 {
+		t.Errorf("Expected 7 grants, got %v", order)
+	}
+}
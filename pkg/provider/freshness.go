@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Timestamps the age of an SBOM can be measured from, see VerifierConfig.SBOMAgeSource
+const (
+	// SBOMAgeSourceCreated is the creation timestamp recorded in the SBOM document (SPDX
+	// creationInfo.created, CycloneDX metadata.timestamp)
+	SBOMAgeSourceCreated = "created"
+	// SBOMAgeSourceRekor is the time the SBOM attestation was integrated into the transparency log
+	SBOMAgeSourceRekor = "rekor"
+	// SBOMAgeSourceCertificate is the notBefore of the certificate the SBOM attestation was
+	// signed with
+	SBOMAgeSourceCertificate = "certificate"
+)
+
+// parseSBOMAgeSource validates the timestamp SBOM ages are measured from, defaulting to the
+// document creation timestamp
+func parseSBOMAgeSource(source string) (string, error) {
+	switch source {
+	case "":
+		return SBOMAgeSourceCreated, nil
+	case SBOMAgeSourceCreated, SBOMAgeSourceRekor, SBOMAgeSourceCertificate:
+		return source, nil
+	}
+	return "", fmt.Errorf("invalid SBOM age source %q (expected %s, %s or %s)", source, SBOMAgeSourceCreated, SBOMAgeSourceRekor, SBOMAgeSourceCertificate)
+}
+
+// sbomTimestamp returns the timestamp the age of the SBOM of att is measured from. The creation
+// timestamp is read from the decoded document when there is one, and from the predicate
+// otherwise (metadata-only verifications do not decode it).
+func (v *AttestationVerifier) sbomTimestamp(unified *UnifiedSBOM, att verifiedAttestation) (time.Time, bool) {
+	switch v.sbomAgeSource {
+	case SBOMAgeSourceRekor:
+		return att.signedAt, !att.signedAt.IsZero()
+	case SBOMAgeSourceCertificate:
+		return att.certNotBefore, !att.certNotBefore.IsZero()
+	}
+
+	if unified.Document != nil {
+		if unified.Document.Created == "" {
+			return time.Time{}, false
+		}
+		return parseTimestamp(unified.Document.Created)
+	}
+	_, predicate, err := v.parseStatement(att.payload)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var doc struct {
+		CreationInfo struct {
+			Created string `json:"created"`
+		} `json:"creationInfo"`
+		Metadata struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(predicate, &doc); err != nil {
+		return time.Time{}, false
+	}
+	if unified.Format == "cyclonedx" {
+		return parseTimestamp(doc.Metadata.Timestamp)
+	}
+	return parseTimestamp(doc.CreationInfo.Created)
+}
+
+// checkSBOMAge fails an SBOM older than the maximum SBOM age, or without the timestamp its age
+// is measured from. Unverified inventory SBOMs are not checked.
+func (v *AttestationVerifier) checkSBOMAge(ctx context.Context, i int, unified *UnifiedSBOM, att verifiedAttestation) error {
+	if v.maxSBOMAge <= 0 || att.unverified {
+		return nil
+	}
+	ts, ok := v.sbomTimestamp(unified, att)
+	if !ok {
+		return newVerificationError(ErrCodeSBOMTooOld, "SBOM has no valid %s timestamp to check its age against the maximum of %s", v.sbomAgeSource, v.maxSBOMAge)
+	}
+	age := time.Since(ts)
+	tracef(ctx, "attestation %d: SBOM %s timestamp %s, %s old", i, v.sbomAgeSource, formatTimestamp(ts), age.Round(time.Second))
+	if age > v.maxSBOMAge {
+		return newVerificationError(ErrCodeSBOMTooOld, "SBOM is %s old by its %s timestamp %s, more than the maximum of %s", age.Round(time.Second), v.sbomAgeSource, formatTimestamp(ts), v.maxSBOMAge)
+	}
+	return nil
+}
+
+// sbomAgePolicy renders the maximum SBOM age and its timestamp for the verification policy hash
+func (v *AttestationVerifier) sbomAgePolicy() string {
+	if v.maxSBOMAge <= 0 {
+		return ""
+	}
+	return v.maxSBOMAge.String() + "@" + v.sbomAgeSource
+}
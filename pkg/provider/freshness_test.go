@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckSBOMAge(t *testing.T) {
+	now := time.Now()
+	old, recent := now.Add(-90*24*time.Hour), now.Add(-time.Hour)
+	statement := func(created time.Time) []byte {
+		return []byte(`{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://spdx.dev/Document","predicate":{"spdxVersion":"SPDX-2.3","creationInfo":{"created":"` +
+			created.UTC().Format(time.RFC3339) + `"},"packages":[{"name":"zlib"}]}}`)
+	}
+	// The document claims to be fresh while it was signed long ago, as generators with a
+	// hardcoded timestamp make it look
+	att := verifiedAttestation{payload: statement(recent), signedAt: old, certNotBefore: old}
+
+	tests := []struct {
+		source string
+		att    verifiedAttestation
+		fresh  bool
+	}{
+		{SBOMAgeSourceCreated, att, true},
+		{SBOMAgeSourceRekor, att, false},
+		{SBOMAgeSourceCertificate, att, false},
+		{SBOMAgeSourceCreated, verifiedAttestation{payload: statement(old), signedAt: recent}, false},
+		{SBOMAgeSourceRekor, verifiedAttestation{payload: statement(old), signedAt: recent}, true},
+		{SBOMAgeSourceCertificate, verifiedAttestation{payload: statement(recent), signedAt: recent}, false}, // Key-signed
+	}
+	for i, tt := range tests {
+		verifier := &AttestationVerifier{maxSBOMAge: 30 * 24 * time.Hour, sbomAgeSource: tt.source}
+		for _, ctx := range []context.Context{context.Background(), withKeyOptions(context.Background(), keyOptions{metadataOnly: true})} {
+			unified, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{tt.att})
+			if tt.fresh && (err != nil || unified == nil) {
+				t.Errorf("%d (%s): expected a fresh SBOM, got %v", i, tt.source, err)
+			}
+			if !tt.fresh && ErrorCode(err) != ErrCodeSBOMTooOld {
+				t.Errorf("%d (%s): expected %s, got %v", i, tt.source, ErrCodeSBOMTooOld, err)
+			}
+		}
+	}
+
+	// An older SBOM is skipped for a fresh one
+	verifier := &AttestationVerifier{maxSBOMAge: 30 * 24 * time.Hour, sbomAgeSource: SBOMAgeSourceRekor}
+	unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{
+		{payload: statement(old), signedAt: old},
+		{payload: statement(recent), signedAt: recent},
+	})
+	if err != nil || unified.SignedAt != formatTimestamp(recent) {
+		t.Errorf("Expected the fresh SBOM, got %+v and %v", unified, err)
+	}
+
+	if _, err := parseSBOMAgeSource("build"); err == nil {
+		t.Error("Expected an unknown age source to fail")
+	}
+	if source, err := parseSBOMAgeSource(""); err != nil || source != SBOMAgeSourceCreated {
+		t.Errorf("Expected the creation timestamp by default, got %q and %v", source, err)
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/providerkey"
+)
+
+// maxAllowedIdentities bounds the identities of a key, as referrer bundles are verified once
+// per identity
+const maxAllowedIdentities = providerkey.MaxIdentities
+
+// AllowedIdentity is an entry of the identities constraint parameter: a signer, given as an
+// identity or an identity pattern, and the OIDC issuer that must have certified it
+type AllowedIdentity struct {
+	CertIdentity       string `json:"certIdentity,omitempty"`
+	CertIdentityRegexp string `json:"certIdentityRegexp,omitempty"`
+	CertOidcIssuer     string `json:"certOidcIssuer,omitempty"`
+}
+
+// parseAllowedIdentities parses the JSON list of the identities key option
+func parseAllowedIdentities(raw string) ([]AllowedIdentity, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var identities []AllowedIdentity
+	if err := json.Unmarshal([]byte(raw), &identities); err != nil {
+		return nil, fmt.Errorf("invalid identities %q: %w", raw, err)
+	}
+	if err := validateAllowedIdentities(identities); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// validateAllowedIdentities rejects lists over maxAllowedIdentities and entries that would
+// accept any signer
+func validateAllowedIdentities(identities []AllowedIdentity) error {
+	if len(identities) > maxAllowedIdentities {
+		return fmt.Errorf("invalid identities: %d entries, at most %d are allowed", len(identities), maxAllowedIdentities)
+	}
+	for i, identity := range identities {
+		if identity == (AllowedIdentity{}) {
+			return fmt.Errorf("invalid identities: entry %d sets none of certIdentity, certIdentityRegexp and certOidcIssuer", i)
+		}
+	}
+	return nil
+}
+
+// certIdentitiesFor returns the cosign identities a verification accepts: the identity and
+// issuer of the key, if set, and each entry of its identities option. Attestations signed by
+// any of them pass; no identities means any signer is accepted.
+func certIdentitiesFor(certIdentity, certIdentityRegexp, certOidcIssuer, identities string) ([]cosign.Identity, error) {
+	allowed, err := parseAllowedIdentities(identities)
+	if err != nil {
+		return nil, err
+	}
+	if certIdentity != "" || certIdentityRegexp != "" || certOidcIssuer != "" {
+		allowed = append([]AllowedIdentity{{certIdentity, certIdentityRegexp, certOidcIssuer}}, allowed...)
+	}
+	return cosignIdentities(allowed)
+}
+
+// cosignIdentities returns the cosign identities of allowed signers
+func cosignIdentities(allowed []AllowedIdentity) ([]cosign.Identity, error) {
+	var result []cosign.Identity
+	for _, a := range allowed {
+		identity, err := certIdentityFor(a.CertIdentity, a.CertIdentityRegexp, a.CertOidcIssuer)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, identity)
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseAllowedIdentities(t *testing.T) {
+	identities, err := parseAllowedIdentities(`[{"certIdentity":"user@example.com","certOidcIssuer":"https://accounts.google.com"},{"certIdentityRegexp":"https://github.com/myorg/.+","certOidcIssuer":"github-actions"}]`)
+	if err != nil {
+		t.Fatalf("Failed to parse identities: %v", err)
+	}
+	if len(identities) != 2 || identities[1].CertIdentityRegexp != "https://github.com/myorg/.+" {
+		t.Errorf("Expected 2 identities, got %+v", identities)
+	}
+
+	if identities, err := parseAllowedIdentities(""); err != nil || identities != nil {
+		t.Errorf("Expected no identities, got %+v, %v", identities, err)
+	}
+
+	tooMany := "[" + strings.Repeat(`{"certIdentity":"user@example.com"},`, maxAllowedIdentities) + `{"certIdentity":"user@example.com"}]`
+	for _, raw := range []string{`{"certIdentity":"user@example.com"}`, `[{"certIdentity":"a"},{}]`, tooMany} {
+		if _, err := parseAllowedIdentities(raw); err == nil {
+			t.Errorf("Expected an error for %.60s", raw)
+		}
+	}
+}
+
+func TestCertIdentitiesFor(t *testing.T) {
+	identities, err := certIdentitiesFor("user@example.com", "", "https://accounts.google.com",
+		`[{"certIdentityRegexp":"https://github.com/myorg/.+","certOidcIssuer":"github-actions"}]`)
+	if err != nil {
+		t.Fatalf("Failed to create identities: %v", err)
+	}
+	if len(identities) != 2 {
+		t.Fatalf("Expected the key identity and the listed one, got %+v", identities)
+	}
+	if identities[0].Subject != "user@example.com" || identities[1].SubjectRegExp != "^(?:https://github.com/myorg/.+)$" || identities[1].IssuerRegExp != githubActionsIssuerRegExp {
+		t.Errorf("Expected identities in key order, got %+v", identities)
+	}
+
+	// Listed identities apply on their own, without an identity in the key
+	if identities, err := certIdentitiesFor("", "", "", `[{"certIdentity":"user@example.com"}]`); err != nil || len(identities) != 1 {
+		t.Errorf("Expected 1 identity, got %+v, %v", identities, err)
+	}
+	if identities, err := certIdentitiesFor("", "", "", ""); err != nil || identities != nil {
+		t.Errorf("Expected no identity constraints, got %+v, %v", identities, err)
+	}
+
+	if _, err := certIdentitiesFor("", "", "", `[{"certIdentityRegexp":"("}]`); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestIdentitiesKeyOption(t *testing.T) {
+	base := "ghcr.io/org/app:v1|[]|user@example.com|https://accounts.google.com"
+	raw := `[{"certIdentity":"ci@example.com","certOidcIssuer":"https://accounts.google.com"}]`
+
+	key, opts := splitKeyOptions(base + "|debug=true,identities=" + url.QueryEscape(raw))
+	if key != base || opts.identities != raw {
+		t.Fatalf("Expected the unescaped identities, got %q %+v", key, opts)
+	}
+	if got := opts.resultKey(key); got != base+"|identities="+url.QueryEscape(raw) {
+		t.Errorf("Expected the identities in the result key, got %q", got)
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey(opts.resultKey(key)) == verifier.PolicyHashForKey(base) {
+		t.Error("Expected the identities to change the policy hash")
+	}
+}
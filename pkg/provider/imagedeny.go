@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Reasons an image is denied before it is verified
+const (
+	imageDenyPattern  = "pattern"  // The image matches a deny pattern
+	imageDenyRegistry = "registry" // The image is not in an allowed registry
+)
+
+// imageDenials counts the images denied before verification, by reason
+var imageDenials struct {
+	pattern, registry atomic.Int64
+}
+
+// imageDenylist rejects images by reference alone, before any registry, Rekor or cache lookup,
+// so images that could never pass don't spend the verification budget
+type imageDenylist struct {
+	patterns   []denyPattern
+	registries []string // Allowed registry hosts, normalized like pull secret registries, as path.Match patterns; empty allows every registry
+}
+
+// denyPattern is a deny pattern and the expression it compiles to
+type denyPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// newImageDenylist parses the deny patterns and allowed registries, nil when both are empty.
+// In patterns "*" matches any characters, including "/", and "?" a single one, so "*:latest"
+// denies the latest tag of every repository.
+func newImageDenylist(patterns, registries []string) *imageDenylist {
+	d := &imageDenylist{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(pattern, "docker.io/") {
+			// References are normalized to index.docker.io
+			pattern = "index." + pattern
+		}
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		d.patterns = append(d.patterns, denyPattern{pattern: pattern, re: regexp.MustCompile("^" + expr + "$")})
+	}
+	for _, registry := range registries {
+		registry = normalizeRegistryPattern(registry)
+		if registry == "" {
+			continue
+		}
+		if _, err := path.Match(registry, ""); err != nil {
+			log.Printf("Warning: invalid allowed registry pattern %q: %v, it matches no registry", registry, err)
+		}
+		d.registries = append(d.registries, registry)
+	}
+	if len(d.patterns) == 0 && len(d.registries) == 0 {
+		return nil
+	}
+	return d
+}
+
+// check returns an ErrCodeImageDenied error when image is denied. Patterns are matched against
+// the reference as written and in its normalized form, so "*:latest" also denies "nginx",
+// which implies the latest tag. References that do not parse are left to fail verification.
+func (d *imageDenylist) check(image string) error {
+	if d == nil {
+		return nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil
+	}
+
+	for _, p := range d.patterns {
+		if p.re.MatchString(image) || p.re.MatchString(ref.Name()) {
+			imageDenials.pattern.Add(1)
+			return newVerificationError(ErrCodeImageDenied, "image %s matches the deny pattern %q", image, p.pattern)
+		}
+	}
+
+	if len(d.registries) == 0 {
+		return nil
+	}
+	registry := ref.Context().RegistryStr()
+	for _, allowed := range d.registries {
+		if ok, _ := path.Match(allowed, registry); ok {
+			return nil
+		}
+	}
+	imageDenials.registry.Add(1)
+	return newVerificationError(ErrCodeImageDenied, "registry %s of image %s is not allowed (allowed: %s)", registry, image, strings.Join(d.registries, ", "))
+}
+
+// deniedItem returns the error item for key when the image of imageRef is denied, and whether it is
+func (s *Server) deniedItem(key, imageRef string) (Item, bool) {
+	image := strings.SplitN(imageRef, "|", 2)[0]
+	if err := s.denylist.check(image); err != nil {
+		return Item{Key: key, Error: formatItemError("Image denied by provider policy", err)}, true
+	}
+	return Item{}, false
+}
+
+// writeImageDenyMetrics writes the image denial counters in Prometheus text format
+func writeImageDenyMetrics(w io.Writer) {
+	const name = "sbom_provider_denied_images_total"
+	fmt.Fprintf(w, "# HELP %s Images denied by reference before verification, by reason.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, imageDenyPattern, imageDenials.pattern.Load())
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, imageDenyRegistry, imageDenials.registry.Load())
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageDenylist(t *testing.T) {
+	if newImageDenylist([]string{""}, []string{" "}) != nil {
+		t.Error("Expected no denylist without patterns or registries")
+	}
+	var none *imageDenylist
+	if err := none.check("nginx:latest"); err != nil {
+		t.Errorf("Expected a nil denylist to allow every image, got %v", err)
+	}
+
+	d := newImageDenylist([]string{"*:latest", " docker.io/*/debug-* "}, []string{"ghcr.io", "docker.io", "*.example.com:5000"})
+	for image, denied := range map[string]bool{
+		"nginx":                  true,
+		"ghcr.io/org/app:latest": true,
+		"ghcr.io/org/app:v1":     false,
+		"ghcr.io/org/app@sha256:" + strings.Repeat("a", 64): false,
+		"org/debug-1:v1":                     true,
+		"index.docker.io/library/nginx:1.27": false,
+		"quay.io/org/app:v1":                 true,
+		"registry.example.com:5000/app:v1":   false,
+		"registry.example.com/app:v1":        true,
+		"not a reference":                    false,
+	} {
+		err := d.check(image)
+		if denied != (err != nil) {
+			t.Errorf("Expected %s denied %v, got %v", image, denied, err)
+		}
+		if err != nil && ErrorCode(err) != ErrCodeImageDenied {
+			t.Errorf("Expected %s for %s, got %v", ErrCodeImageDenied, image, err)
+		}
+	}
+}
+
+func TestImageDenylistResolveKey(t *testing.T) {
+	server := &Server{cache: newResultCache(), denylist: newImageDenylist([]string{"*:latest"}, nil)}
+	before := imageDenials.pattern.Load()
+
+	// Denied before the cache is consulted, and without contacting the registry
+	key := "ghcr.io/org/app:latest|[]||"
+	server.cache.Set(key, Item{Value: `{"format":"spdx","packages":[]}`}, time.Minute)
+	item := server.resolveKey(key, false, "")
+	if item.Key != key || !strings.HasPrefix(item.Error, ErrCodeImageDenied+": ") {
+		t.Errorf("Expected %s for a latest tag, got %+v", ErrCodeImageDenied, item)
+	}
+	if item := server.verifyScheduled(t.Context(), "", "nginx|[]||"); !strings.HasPrefix(item.Error, ErrCodeImageDenied+": ") {
+		t.Errorf("Expected %s outside admission requests, got %+v", ErrCodeImageDenied, item)
+	}
+	if got := imageDenials.pattern.Load() - before; got != 2 {
+		t.Errorf("Expected 2 pattern denials counted, got %d", got)
+	}
+
+	var buf bytes.Buffer
+	writeImageDenyMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_denied_images_total{reason="registry"}`) {
+		t.Errorf("Expected the denial metrics, got %q", buf.String())
+	}
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// ImageSignature is the verified cosign signature of an image, reported alongside its SBOM so
+// one provider call covers both supply-chain checks
+type ImageSignature struct {
+	Digest   string `json:"digest"`             // Digest reference the signature covers: the image, or the index it was selected from
+	Count    int    `json:"count"`              // Signatures verified
+	Signer   string `json:"signer,omitempty"`   // Identity of the signing certificate of the newest signature, empty for key signatures
+	Issuer   string `json:"issuer,omitempty"`   // OIDC issuer that certified the signer
+	SignedAt string `json:"signedAt,omitempty"` // When the newest signature was logged in the transparency log, RFC3339 UTC
+}
+
+// verifyImageSignature requires a cosign signature of ref, or of the index ref was selected
+// from, by a signer checkOpts accepts: the identities, certificate extensions or key the
+// attestations were verified against. Signatures are looked up under the legacy .sig tag.
+func (v *AttestationVerifier) verifyImageSignature(ctx context.Context, ref name.Reference, keychain authn.Keychain, checkOpts *cosign.CheckOpts) (*ImageSignature, error) {
+	opts := *checkOpts
+	opts.ClaimVerifier = cosign.SimpleClaimVerifier
+	opts.ExperimentalOCI11 = false
+	opts.NewBundleFormat = false
+	if extensions := requiredCertExtensions(ctx); extensions != nil {
+		opts.ClaimVerifier = extensions.claimVerifier(opts.ClaimVerifier)
+	}
+
+	refs := []name.Reference{ref}
+	if index := imageIndex(ctx); index != nil {
+		refs = append(refs, index)
+	}
+
+	var errs []error
+	for _, ref := range refs {
+		var signature *ImageSignature
+		err := v.verifyWithTrustedRoots(&opts, func(opts *cosign.CheckOpts) error {
+			sigs, _, err := cosign.VerifyImageSignatures(ctx, ref, opts)
+			if err != nil {
+				return err
+			}
+			signature = &ImageSignature{Digest: ref.String(), Count: len(sigs)}
+			if len(sigs) == 0 {
+				return nil
+			}
+			newest := newestSignatures(sigs)[0]
+			if cert, err := newest.Cert(); err == nil && cert != nil {
+				signature.Signer = strings.Join(cryptoutils.GetSubjectAlternateNames(cert), ",")
+				signature.Issuer = (&cosign.CertExtensions{Cert: cert}).GetIssuer()
+			}
+			if bundle, err := newest.Bundle(); err == nil && bundle != nil {
+				signedAt := time.Unix(bundle.Payload.IntegratedTime, 0)
+				signature.SignedAt = formatTimestamp(signedAt)
+				return v.checkIntegratedTime(signedAt)
+			}
+			return nil
+		})
+		if err == nil {
+			tracef(ctx, "image signature verified: %d signatures of %s", signature.Count, ref)
+			return signature, nil
+		}
+		if _, ok := registryAuthStatus(err); ok {
+			return nil, classifyRegistryAuthError(err, ref, keychain)
+		}
+		errs = append(errs, err)
+	}
+	return nil, newVerificationError(ErrCodeImageSignature, "no signature of %s by an accepted signer: %w", ref, errors.Join(errs...))
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestImageSignatureKeyOption(t *testing.T) {
+	settings := KeySettings{RequireImageSignature: true}
+	key := settings.key("ghcr.io/org/app:v1", nil)
+	imageRef, opts := splitKeyOptions(key)
+	if !opts.imageSignature || opts.resultKey(imageRef) != key {
+		t.Errorf("Expected the signature option to round-trip, got %+v from %q", opts, key)
+	}
+	if !imageSignatureRequired(withKeyOptions(context.Background(), opts)) {
+		t.Error("Expected the requirement in the context")
+	}
+
+	// An unparsable value must not waive the requirement
+	if _, opts := splitKeyOptions("ghcr.io/org/app:v1|[]|||signature=yes"); !opts.imageSignature {
+		t.Error("Expected an invalid signature option to require the signature")
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey(key) == verifier.PolicyHashForKey("ghcr.io/org/app:v1|[]||") {
+		t.Error("Expected a required image signature to change the policy hash")
+	}
+}
+
+func TestVerifyImageSignature(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+
+	signed := pushSignedImage(t, host+"/test/signed", signer, map[string]interface{}{})
+	sig, err := verifier.verifyImageSignature(context.Background(), signed, authn.DefaultKeychain, checkOpts)
+	if err != nil || sig.Count != 1 || sig.Digest != signed.String() {
+		t.Errorf("Expected the signature of %s, got %+v, %v", signed, sig, err)
+	}
+
+	unsigned := pushSignedImage(t, host+"/test/unsigned", signer, nil)
+	if _, err := verifier.verifyImageSignature(context.Background(), unsigned, authn.DefaultKeychain, checkOpts); ErrorCode(err) != ErrCodeImageSignature {
+		t.Errorf("Expected %s for an unsigned image, got %v", ErrCodeImageSignature, err)
+	}
+
+	// A signature of the index the manifest was selected from covers it
+	ctx := withImageIndex(context.Background(), signed)
+	sig, err = verifier.verifyImageSignature(ctx, unsigned, authn.DefaultKeychain, checkOpts)
+	if err != nil || sig.Digest != signed.String() {
+		t.Errorf("Expected the signature of the index, got %+v, %v", sig, err)
+	}
+
+}
@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// Types of artifacts attached to an image
+const (
+	ArtifactTypeSignature   = "signature"
+	ArtifactTypeAttestation = "attestation"
+	ArtifactTypeSBOM        = "sbom"
+	ArtifactTypeBundle      = "bundle"   // Sigstore bundle discovered through the referrers API
+	ArtifactTypeReferrer    = "referrer" // Any other referrer
+)
+
+// sigstoreBundleMediaType prefixes the artifact type of Sigstore bundles pushed as referrers
+const sigstoreBundleMediaType = "application/vnd.dev.sigstore.bundle"
+
+// bundlePredicateTypeAnnotation records the predicate type of attestation bundles pushed by cosign
+const bundlePredicateTypeAnnotation = "dev.sigstore.bundle.predicateType"
+
+// InspectResult lists the artifacts attached to an image, as returned by /inspect
+type InspectResult struct {
+	Image     string             `json:"image"`
+	Digest    string             `json:"digest"`
+	Artifacts []AttachedArtifact `json:"artifacts"`
+	Errors    []string           `json:"errors,omitempty"` // Sources that could not be listed
+}
+
+// AttachedArtifact is a signature, attestation, SBOM or other referrer found for an image.
+// Nothing is verified: signers are read from the attached certificates as is.
+type AttachedArtifact struct {
+	Type          string `json:"type"`
+	Source        string `json:"source"` // Attestation source the artifact was found through
+	Digest        string `json:"digest,omitempty"`
+	MediaType     string `json:"mediaType,omitempty"`
+	PredicateType string `json:"predicateType,omitempty"`
+	Signer        string `json:"signer,omitempty"` // Subject alternative names of the signing certificate
+	Issuer        string `json:"issuer,omitempty"` // OIDC issuer of the signing certificate
+	Logged        bool   `json:"logged"`           // Whether a transparency log bundle is attached
+}
+
+// Inspect lists every signature, attestation and SBOM attached to imageRef in the registry,
+// through the legacy tags, the mapped attestation repository and the referrers API, without
+// verifying any of them
+func (v *AttestationVerifier) Inspect(ctx context.Context, imageRef string, secretNames []string) (*InspectResult, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image reference: %w", err)
+	}
+
+	keychain, err := v.createKeychainWithSecrets(ctx, secretNames)
+	if err != nil {
+		log.Printf("Warning: Failed to create keychain with secrets: %v, using default", err)
+		keychain = v.keychain
+	}
+
+	remoteOpts := v.remoteOptions(ctx, keychain)
+	digest, err := resolveDigest(ref, remoteOpts...)
+	if err != nil {
+		return nil, classifyRegistryAuthError(err, ref, keychain)
+	}
+	digestRef := ref.Context().Digest(digest.String())
+
+	result := &InspectResult{Image: imageRef, Digest: digest.String(), Artifacts: []AttachedArtifact{}}
+	addErr := func(source string, err error) {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", source, classifyRegistryAuthError(err, ref, keychain)))
+	}
+
+	opts := []ociremote.Option{ociremote.WithRemoteOptions(remoteOpts...)}
+	artifacts, err := tagArtifacts(digestRef, AttestationSourceTag, opts)
+	if err != nil {
+		addErr(AttestationSourceTag, err)
+	}
+	result.Artifacts = append(result.Artifacts, artifacts...)
+
+	if repo, ok := v.attestationRepositoryFor(ref.Context()); ok {
+		artifacts, err := tagArtifacts(digestRef, AttestationSourceRepository, append(opts, ociremote.WithTargetRepository(repo)))
+		if err != nil {
+			addErr(AttestationSourceRepository, err)
+		}
+		result.Artifacts = append(result.Artifacts, artifacts...)
+	}
+
+	artifacts, err = referrerArtifacts(digestRef, opts)
+	if err != nil {
+		addErr(AttestationSourceReferrers, err)
+	}
+	result.Artifacts = append(result.Artifacts, artifacts...)
+
+	if origin, ok := v.originRepositoryFor(ref.Context()); ok {
+		artifacts, err := tagArtifacts(origin.Digest(digest.String()), AttestationSourceOrigin, opts)
+		if err != nil {
+			addErr(AttestationSourceOrigin, err)
+		}
+		result.Artifacts = append(result.Artifacts, artifacts...)
+	}
+
+	return result, nil
+}
+
+// tagArtifacts lists the artifacts stored under cosign's legacy tags for ref
+func tagArtifacts(ref name.Digest, source string, opts []ociremote.Option) ([]AttachedArtifact, error) {
+	se, err := ociremote.SignedEntity(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []AttachedArtifact
+	for _, list := range []struct {
+		kind string
+		get  func() (oci.Signatures, error)
+	}{
+		{ArtifactTypeSignature, se.Signatures},
+		{ArtifactTypeAttestation, se.Attestations},
+	} {
+		sigs, err := list.get()
+		if err != nil {
+			return artifacts, fmt.Errorf("failed to list %ss: %w", list.kind, err)
+		}
+		entries, err := sigs.Get()
+		if err != nil {
+			return artifacts, fmt.Errorf("failed to list %ss: %w", list.kind, err)
+		}
+		for _, sig := range entries {
+			artifacts = append(artifacts, signatureArtifact(sig, list.kind, source))
+		}
+	}
+
+	// SBOMs attached with "cosign attach sbom", unsigned
+	if file, err := se.Attachment(ArtifactTypeSBOM); err == nil {
+		artifact := AttachedArtifact{Type: ArtifactTypeSBOM, Source: source}
+		if d, err := file.Digest(); err == nil {
+			artifact.Digest = d.String()
+		}
+		if mt, err := file.FileMediaType(); err == nil {
+			artifact.MediaType = string(mt)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// signatureArtifact describes a cosign signature or attestation layer
+func signatureArtifact(sig oci.Signature, kind, source string) AttachedArtifact {
+	artifact := AttachedArtifact{Type: kind, Source: source}
+	if d, err := sig.Digest(); err == nil {
+		artifact.Digest = d.String()
+	}
+	if mt, err := sig.MediaType(); err == nil {
+		artifact.MediaType = string(mt)
+	}
+	if cert, err := sig.Cert(); err == nil && cert != nil {
+		artifact.Signer = strings.Join(cryptoutils.GetSubjectAlternateNames(cert), ",")
+		artifact.Issuer = (&cosign.CertExtensions{Cert: cert}).GetIssuer()
+	}
+	if bundle, err := sig.Bundle(); err == nil && bundle != nil {
+		artifact.Logged = true
+	}
+	if kind == ArtifactTypeAttestation {
+		if payload, err := sig.Payload(); err == nil {
+			artifact.PredicateType = envelopePredicateType(payload)
+		}
+	}
+	return artifact
+}
+
+// envelopePredicateType returns the predicate type of the in-toto statement in a DSSE envelope, or ""
+func envelopePredicateType(envelope []byte) string {
+	var env struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return ""
+	}
+	statement, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return ""
+	}
+
+	var st struct {
+		PredicateType string `json:"predicateType"`
+	}
+	if err := json.Unmarshal(statement, &st); err != nil {
+		return ""
+	}
+	return st.PredicateType
+}
+
+// referrerArtifacts lists the artifacts referring to ref through the OCI 1.1 referrers API
+func referrerArtifacts(ref name.Digest, opts []ociremote.Option) ([]AttachedArtifact, error) {
+	index, err := ociremote.Referrers(ref, "", opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]AttachedArtifact, 0, len(index.Manifests))
+	for _, desc := range index.Manifests {
+		artifact := AttachedArtifact{
+			Type:          ArtifactTypeReferrer,
+			Source:        AttestationSourceReferrers,
+			Digest:        desc.Digest.String(),
+			MediaType:     desc.ArtifactType,
+			PredicateType: desc.Annotations[bundlePredicateTypeAnnotation],
+		}
+		switch {
+		case strings.HasPrefix(desc.ArtifactType, sigstoreBundleMediaType):
+			artifact.Type = ArtifactTypeBundle
+			artifact.Logged = true
+		case desc.ArtifactType == UnifiedSBOMArtifactType:
+			artifact.Type = ArtifactTypeSBOM
+		case strings.Contains(desc.ArtifactType, "spdx") || strings.Contains(desc.ArtifactType, "cyclonedx"):
+			artifact.Type = ArtifactTypeSBOM
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// authorizedInspect checks the bearer token of an /inspect request
+func (s *Server) authorizedInspect(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.inspectToken)) == 1
+}
+
+// handleInspect lists the artifacts attached to an image.
+// GET ?image=...[&secrets=a,b] with "Authorization: Bearer <INSPECT_TOKEN>".
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorizedInspect(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sbom-provider"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	image := r.URL.Query().Get("image")
+	if image == "" {
+		http.Error(w, "image is required", http.StatusBadRequest)
+		return
+	}
+	var secretNames []string
+	if secrets := r.URL.Query().Get("secrets"); secrets != "" {
+		secretNames = strings.Split(secrets, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	result, err := s.verifier.Inspect(ctx, image, secretNames)
+	if err != nil {
+		http.Error(w, sanitizeItemError(err.Error()), http.StatusBadGateway)
+		return
+	}
+	log.Printf("Inspected %s: %d artifacts", image, len(result.Artifacts))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestEnvelopePredicateType(t *testing.T) {
+	statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://spdx.dev/Document"}`
+	envelope := `{"payloadType":"application/vnd.in-toto+json","payload":"` +
+		base64.StdEncoding.EncodeToString([]byte(statement)) + `"}`
+
+	if got := envelopePredicateType([]byte(envelope)); got != "https://spdx.dev/Document" {
+		t.Errorf("Expected the SPDX predicate type, got '%s'", got)
+	}
+	if got := envelopePredicateType([]byte("not json")); got != "" {
+		t.Errorf("Expected no predicate type for an invalid envelope, got '%s'", got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+
+	// An attestation under cosign's legacy tag
+	statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://cyclonedx.org/bom"}`
+	envelope := `{"payloadType":"application/vnd.in-toto+json","payload":"` +
+		base64.StdEncoding.EncodeToString([]byte(statement)) + `"}`
+	att, err := mutate.AppendLayers(empty.Image, static.NewLayer([]byte(envelope), types.MediaType("application/vnd.dsse.envelope.v1+json")))
+	if err != nil {
+		t.Fatalf("Failed to create attestation: %v", err)
+	}
+	attTag := ref.Context().Tag(strings.Replace(digest.String(), ":", "-", 1) + ".att")
+	if err := remote.Write(attTag, att); err != nil {
+		t.Fatalf("Failed to push attestation: %v", err)
+	}
+
+	verifier := &AttestationVerifier{keychain: authn.DefaultKeychain}
+	result, err := verifier.Inspect(context.Background(), ref.String(), nil)
+	if err != nil {
+		t.Fatalf("Failed to inspect image: %v", err)
+	}
+	if result.Digest != digest.String() {
+		t.Errorf("Expected digest %s, got %s", digest, result.Digest)
+	}
+
+	var found bool
+	for _, a := range result.Artifacts {
+		if a.Type == ArtifactTypeAttestation {
+			found = true
+			if a.Source != AttestationSourceTag || a.PredicateType != "https://cyclonedx.org/bom" || a.Logged {
+				t.Errorf("Unexpected attestation %+v", a)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the attestation to be listed, got %+v (errors: %v)", result.Artifacts, result.Errors)
+	}
+
+	if _, err := verifier.Inspect(context.Background(), "INVALID::REF", nil); err == nil {
+		t.Error("Expected an error for an invalid reference")
+	}
+}
+
+func TestHandleInspectAuth(t *testing.T) {
+	server := &Server{verifier: &AttestationVerifier{}, timeout: 5 * time.Second, inspectToken: "s3cret"}
+
+	tests := []struct {
+		auth string
+		url  string
+		want int
+	}{
+		{"", "/inspect?image=ghcr.io/org/app:v1", http.StatusUnauthorized},
+		{"Bearer wrong", "/inspect?image=ghcr.io/org/app:v1", http.StatusUnauthorized},
+		{"s3cret", "/inspect?image=ghcr.io/org/app:v1", http.StatusUnauthorized},
+		{"Bearer s3cret", "/inspect", http.StatusBadRequest},
+		{"Bearer s3cret", "/inspect?image=INVALID::REF", http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		server.handleInspect(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%q %s: expected status %d, got %d", tt.auth, tt.url, tt.want, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.handleInspect(w, httptest.NewRequest("POST", "/inspect", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// IssuerPresetGitHubActions is a certOidcIssuer preset matching the GitHub Actions OIDC issuer in
+// all its forms: https://token.actions.githubusercontent.com, its per-enterprise variant
+// https://token.actions.githubusercontent.com/<enterprise> and the GitHub Enterprise Server
+// issuer https://<host>/_services/token
+const IssuerPresetGitHubActions = "github-actions"
+
+// githubActionsIssuerRegExp matches the issuers of IssuerPresetGitHubActions
+const githubActionsIssuerRegExp = `(?i)^https://(token\.actions\.githubusercontent\.com(/[^/]+)?|[^/]+/_services/token)/*$`
+
+// normalizeIssuer returns the canonical form of a certOidcIssuer constraint: presets and issuer
+// URLs are lowercased and stripped of trailing slashes, which Fulcio certificates never carry
+func normalizeIssuer(issuer string) string {
+	issuer = strings.TrimSpace(issuer)
+	if strings.EqualFold(issuer, IssuerPresetGitHubActions) {
+		return IssuerPresetGitHubActions
+	}
+	if !strings.Contains(issuer, "://") {
+		return issuer
+	}
+	return strings.ToLower(strings.TrimRight(issuer, "/"))
+}
+
+// certIdentityFor returns the cosign identity for the constraint. The issuer is matched by a
+// regular expression so it compares regardless of trailing slashes and case, which cosign's
+// exact comparison would report as an identity mismatch. A subject pattern replaces the exact
+// subject, and is anchored so it must match the whole certificate identity rather than a part
+// of it, e.g. a URL embedding the expected workflow.
+func certIdentityFor(subject, subjectRegexp, issuer string) (cosign.Identity, error) {
+	identity := cosign.Identity{Subject: subject}
+	if subjectRegexp != "" {
+		identity.Subject = ""
+		identity.SubjectRegExp = "^(?:" + subjectRegexp + ")$"
+		if _, err := regexp.Compile(identity.SubjectRegExp); err != nil {
+			return cosign.Identity{}, fmt.Errorf("invalid certIdentityRegexp %q: %w", subjectRegexp, err)
+		}
+	}
+	switch issuer = normalizeIssuer(issuer); {
+	case issuer == IssuerPresetGitHubActions:
+		identity.IssuerRegExp = githubActionsIssuerRegExp
+	case issuer != "":
+		identity.IssuerRegExp = "(?i)^" + regexp.QuoteMeta(issuer) + "/*$"
+	}
+	return identity, nil
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNormalizeIssuer(t *testing.T) {
+	tests := map[string]string{
+		"https://token.actions.githubusercontent.com/": "https://token.actions.githubusercontent.com",
+		" HTTPS://Token.Actions.GitHubUserContent.com": "https://token.actions.githubusercontent.com",
+		"GitHub-Actions":              IssuerPresetGitHubActions,
+		"https://accounts.google.com": "https://accounts.google.com",
+		"":                            "",
+	}
+	for issuer, want := range tests {
+		if got := normalizeIssuer(issuer); got != want {
+			t.Errorf("normalizeIssuer(%q): expected %q, got %q", issuer, want, got)
+		}
+	}
+}
+
+func TestCertIdentityForIssuers(t *testing.T) {
+	tests := []struct {
+		constraint string
+		issuer     string
+		want       bool
+	}{
+		{"https://token.actions.githubusercontent.com/", "https://token.actions.githubusercontent.com", true},
+		{"https://Token.Actions.GitHubUserContent.com", "https://token.actions.githubusercontent.com", true},
+		{"https://token.actions.githubusercontent.com", "https://token.actions.githubusercontent.com.evil.example.com", false},
+		{IssuerPresetGitHubActions, "https://token.actions.githubusercontent.com", true},
+		{IssuerPresetGitHubActions, "https://token.actions.githubusercontent.com/acme", true},
+		{IssuerPresetGitHubActions, "https://ghes.corp.example.com/_services/token", true},
+		{IssuerPresetGitHubActions, "https://ghes.corp.example.com/_services/token/", true},
+		{IssuerPresetGitHubActions, "https://accounts.google.com", false},
+		{IssuerPresetGitHubActions, "https://evil.example.com/ghes.corp.example.com/_services/token", false},
+	}
+	for _, tt := range tests {
+		identity, err := certIdentityFor("user@example.com", "", tt.constraint)
+		if err != nil || identity.Subject != "user@example.com" || identity.Issuer != "" {
+			t.Errorf("%s: expected the subject and an issuer pattern, got %+v", tt.constraint, identity)
+		}
+		if got := regexp.MustCompile(identity.IssuerRegExp).MatchString(tt.issuer); got != tt.want {
+			t.Errorf("%s: expected match of %s to be %v, got %v", tt.constraint, tt.issuer, tt.want, got)
+		}
+	}
+
+	if identity, _ := certIdentityFor("user@example.com", "", ""); identity.IssuerRegExp != "" {
+		t.Errorf("Expected no issuer constraint, got %q", identity.IssuerRegExp)
+	}
+}
+
+func TestCertIdentityRegexp(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    bool
+	}{
+		{"https://github.com/myorg/app/.github/workflows/build.yml@refs/heads/main", true},
+		{"https://github.com/myorg/lib/.github/workflows/release.yml@refs/tags/v1.0.0", true},
+		{"https://github.com/otherorg/app/.github/workflows/build.yml@refs/heads/main", false},
+		{"https://evil.example.com/https://github.com/myorg/app/.github/workflows/build.yml", false},
+	}
+
+	identity, err := certIdentityFor("ignored@example.com", `https://github.com/myorg/.+/.github/workflows/.+`, IssuerPresetGitHubActions)
+	if err != nil {
+		t.Fatalf("Failed to create identity: %v", err)
+	}
+	if identity.Subject != "" || identity.IssuerRegExp != githubActionsIssuerRegExp {
+		t.Errorf("Expected the pattern to replace the subject, got %+v", identity)
+	}
+	for _, tt := range tests {
+		if got := regexp.MustCompile(identity.SubjectRegExp).MatchString(tt.subject); got != tt.want {
+			t.Errorf("Expected match of %s to be %v, got %v", tt.subject, tt.want, got)
+		}
+	}
+
+	if _, err := certIdentityFor("", "https://github.com/myorg/(", ""); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestPolicyHashNormalizesIssuer(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashFor("id", "https://token.actions.githubusercontent.com/") != verifier.PolicyHashFor("id", "https://Token.Actions.GitHubUserContent.com") {
+		t.Error("Expected issuers differing in case and trailing slashes to share a policy hash")
+	}
+}
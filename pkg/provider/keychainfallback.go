@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Reasons a pull secret of a key is not used, leaving its registries to the default keychain
+const (
+	keychainFallbackForbidden = "forbidden"             // RBAC denies reading the secret
+	keychainFallbackNotFound  = "not_found"             // The secret does not exist
+	keychainFallbackError     = "error"                 // Any other failure, e.g. an API server timeout
+	keychainFallbackNamespace = "namespace_not_allowed" // The namespace is not allowed to be read
+)
+
+// keychainFallbacks counts the pull secrets not used, by reason
+var keychainFallbacks struct {
+	forbidden, notFound, errors, namespace atomic.Int64
+}
+
+// secretFetchFallbackReason classifies the failure to read a pull secret
+func secretFetchFallbackReason(err error) string {
+	switch {
+	case apierrors.IsForbidden(err):
+		return keychainFallbackForbidden
+	case apierrors.IsNotFound(err):
+		return keychainFallbackNotFound
+	}
+	return keychainFallbackError
+}
+
+// recordKeychainFallback counts pull secrets of namespace not used for reason, and logs them as
+// key=value fields so RBAC problems are found before images from private registries start
+// failing. err is nil when the secrets were not read at all.
+func recordKeychainFallback(ctx context.Context, namespace string, secrets []string, reason string, err error) {
+	n := int64(len(secrets))
+	switch reason {
+	case keychainFallbackForbidden:
+		keychainFallbacks.forbidden.Add(n)
+	case keychainFallbackNotFound:
+		keychainFallbacks.notFound.Add(n)
+	case keychainFallbackNamespace:
+		keychainFallbacks.namespace.Add(n)
+	default:
+		keychainFallbacks.errors.Add(n)
+	}
+
+	fields := fmt.Sprintf("namespace=%s secret=%s reason=%s", namespace, strings.Join(secrets, ","), reason)
+	if err != nil {
+		fields += fmt.Sprintf(" error=%q", err.Error())
+	}
+	log.Printf("Warning: pull secret not used, falling back to the default keychain: %s", fields)
+	tracef(ctx, "pull secret not used: %s", fields)
+}
+
+// writeKeychainFallbackMetrics writes the pull secret fallback counters in Prometheus text format
+func writeKeychainFallbackMetrics(w io.Writer) {
+	const name = "sbom_provider_keychain_fallbacks_total"
+	fmt.Fprintf(w, "# HELP %s Pull secrets not used, their registries falling back to the default keychain, by reason.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, keychainFallbackForbidden, keychainFallbacks.forbidden.Load())
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, keychainFallbackNotFound, keychainFallbacks.notFound.Load())
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, keychainFallbackError, keychainFallbacks.errors.Load())
+	fmt.Fprintf(w, "%s{reason=%q} %d\n", name, keychainFallbackNamespace, keychainFallbacks.namespace.Load())
+}
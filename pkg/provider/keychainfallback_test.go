@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKeychainFallbacks(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == "denied" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "denied", nil)
+		}
+		return false, nil, nil
+	})
+	patterns, _ := parsePullSecretNamespaces([]string{"team-*"})
+	verifier := &AttestationVerifier{
+		keychain:             authn.DefaultKeychain,
+		kubeClient:           client,
+		namespace:            "gatekeeper-system",
+		pullSecretNamespaces: patterns,
+	}
+
+	forbidden, notFound, namespace := keychainFallbacks.forbidden.Load(), keychainFallbacks.notFound.Load(), keychainFallbacks.namespace.Load()
+	kc, err := verifier.createKeychainWithSecrets(context.Background(), []string{"denied", "missing"})
+	if err != nil || kc != authn.DefaultKeychain {
+		t.Fatalf("Expected the default keychain, got %v, %v", kc, err)
+	}
+	ctx := withKeyOptions(context.Background(), keyOptions{namespace: "sandbox"})
+	if _, err := verifier.createKeychainWithSecrets(ctx, []string{"regcred"}); err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+
+	if got := keychainFallbacks.forbidden.Load() - forbidden; got != 1 {
+		t.Errorf("Expected 1 forbidden fallback, got %d", got)
+	}
+	if got := keychainFallbacks.notFound.Load() - notFound; got != 1 {
+		t.Errorf("Expected 1 not found fallback, got %d", got)
+	}
+	if got := keychainFallbacks.namespace.Load() - namespace; got != 1 {
+		t.Errorf("Expected 1 namespace fallback, got %d", got)
+	}
+	for _, want := range []string{
+		"namespace=gatekeeper-system secret=denied reason=forbidden",
+		"namespace=gatekeeper-system secret=missing reason=not_found",
+		"namespace=sandbox secret=regcred reason=namespace_not_allowed",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected a warning with %q, got:\n%s", want, logs.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	writeKeychainFallbackMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_keychain_fallbacks_total{reason="forbidden"}`) {
+		t.Errorf("Unexpected metrics:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,376 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// keyOptions are per-key options appended to a provider key as a fifth segment of
+// comma-separated name=value pairs, e.g. "image|secrets|identity|issuer|debug=true"
+type keyOptions struct {
+	// debug verifies the key uncached and logs a detailed trace tagged with a trace ID
+	debug bool
+	// metadataOnly stops after verification and returns the SBOM format without decoding
+	// the predicate, for policies that only check SBOM presence and signer identity
+	metadataOnly bool
+	// allViolations returns the SBOM of an image signed by an unexpected identity together
+	// with the violations found, so policies report every problem at once
+	allViolations bool
+	// keyRef is a KMS key URI the attestations must be signed with, set per constraint
+	keyRef string
+	// identityRegexp is a regular expression the certificate identity must match, set per
+	// constraint. It is query-escaped in the key, as patterns may contain commas and pipes.
+	identityRegexp string
+	// identities is a JSON list of AllowedIdentity, any of which may sign the attestations,
+	// set per constraint. It is query-escaped in the key like identityRegexp.
+	identities string
+	// certExtensions is a JSON CertExtensions object the signing certificate must match, set
+	// per constraint and query-escaped in the key like identityRegexp
+	certExtensions string
+	// annotations is a JSON object of annotations an image signature of an accepted signer
+	// must carry, set per constraint and query-escaped in the key like identityRegexp
+	annotations string
+	// predicateTypes is a JSON list of the SBOM predicate types accepted, narrowing the
+	// provider's, set per constraint and query-escaped in the key like identityRegexp
+	predicateTypes string
+	// namespace is the namespace pull secrets are read from instead of the provider's
+	namespace string
+	// platform is the "os/arch[/variant]" platform whose manifest is verified when the image
+	// is an index, overriding the provider's default, set per constraint
+	platform string
+	// imageSignature requires a cosign signature of the image besides its SBOM attestation, set
+	// per constraint
+	imageSignature bool
+	// unsignedSBOMs is how unsigned BuildKit SBOMs are handled (UnsignedSBOMsFail or
+	// UnsignedSBOMsInventory), overriding the provider's default, set per constraint
+	unsignedSBOMs string
+}
+
+// splitKeyOptions returns key without its options segment, and the parsed options
+func splitKeyOptions(key string) (string, keyOptions) {
+	var opts keyOptions
+
+	parts := strings.SplitN(key, "|", 5)
+	if len(parts) < 5 {
+		return key, opts
+	}
+
+	for _, opt := range strings.Split(parts[4], ",") {
+		if opt == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(opt, "=")
+		switch name {
+		case "debug":
+			debug, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Printf("Warning: invalid debug option %q in key, ignoring it", value)
+				continue
+			}
+			opts.debug = debug
+		case "packages":
+			packages, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Printf("Warning: invalid packages option %q in key, ignoring it", value)
+				continue
+			}
+			opts.metadataOnly = !packages
+		case "violations":
+			if value != "all" && value != "first" {
+				log.Printf("Warning: invalid violations option %q in key, ignoring it", value)
+				continue
+			}
+			opts.allViolations = value == "all"
+		case "key":
+			ref, err := url.QueryUnescape(value)
+			if err != nil {
+				log.Printf("Warning: invalid key option %q in key: %v", value, err)
+				ref = value
+			}
+			// Dropping the option would fall back to keyless verification, so keep it to fail
+			// verification with ErrCodeVerificationKey
+			if !isKMSRef(ref) {
+				log.Printf("Warning: key option %q is not a KMS key URI", ref)
+			}
+			opts.keyRef = ref
+		case "identityRegexp":
+			pattern, err := url.QueryUnescape(value)
+			if err != nil {
+				// Dropping the option would accept any signer, so keep it to fail verification
+				log.Printf("Warning: invalid identityRegexp option %q in key: %v", value, err)
+				pattern = value
+			}
+			opts.identityRegexp = pattern
+		case "identities":
+			identities, err := url.QueryUnescape(value)
+			if err != nil {
+				log.Printf("Warning: invalid identities option %q in key: %v", value, err)
+				identities = value
+			}
+			opts.identities = identities
+		case "certExtensions":
+			extensions, err := url.QueryUnescape(value)
+			if err != nil {
+				log.Printf("Warning: invalid certExtensions option %q in key: %v", value, err)
+				extensions = value
+			}
+			opts.certExtensions = extensions
+		case "annotations":
+			annotations, err := url.QueryUnescape(value)
+			if err != nil {
+				log.Printf("Warning: invalid annotations option %q in key: %v", value, err)
+				annotations = value
+			}
+			opts.annotations = annotations
+		case "predicateTypes":
+			types, err := url.QueryUnescape(value)
+			if err != nil {
+				log.Printf("Warning: invalid predicateTypes option %q in key: %v", value, err)
+				types = value
+			}
+			opts.predicateTypes = types
+		case "namespace":
+			if !namespacePattern.MatchString(value) {
+				log.Printf("Warning: invalid namespace option %q in key, ignoring it", value)
+				continue
+			}
+			opts.namespace = value
+		case "platform":
+			if _, err := parsePlatform(value); err != nil {
+				log.Printf("Warning: %v in key, ignoring it", err)
+				continue
+			}
+			opts.platform = value
+		case "signature":
+			required, err := strconv.ParseBool(value)
+			if err != nil {
+				// Dropping the option would admit unsigned images, so require the signature
+				log.Printf("Warning: invalid signature option %q in key, requiring the signature", value)
+				required = true
+			}
+			opts.imageSignature = required
+		case "unsigned":
+			mode, err := parseUnsignedSBOMs(value)
+			if err != nil {
+				// Dropping the option could admit unsigned SBOMs where the provider does
+				log.Printf("Warning: %v in key, failing unsigned SBOMs", err)
+				mode = UnsignedSBOMsFail
+			}
+			opts.unsignedSBOMs = mode
+		default:
+			log.Printf("Warning: unknown key option %q, ignoring it", name)
+		}
+	}
+	return strings.Join(parts[:4], "|"), opts
+}
+
+// resultKey returns the key results are verified, cached and pinned under: the key without
+// its options segment, plus the options that change the result in canonical form
+func (o keyOptions) resultKey(key string) string {
+	var opts []string
+	if o.metadataOnly {
+		opts = append(opts, "packages=false")
+	}
+	if o.allViolations {
+		opts = append(opts, "violations=all")
+	}
+	if o.keyRef != "" {
+		opts = append(opts, "key="+url.QueryEscape(o.keyRef))
+	}
+	if o.identityRegexp != "" {
+		opts = append(opts, "identityRegexp="+url.QueryEscape(o.identityRegexp))
+	}
+	if o.identities != "" {
+		opts = append(opts, "identities="+url.QueryEscape(o.identities))
+	}
+	if o.certExtensions != "" {
+		opts = append(opts, "certExtensions="+url.QueryEscape(o.certExtensions))
+	}
+	if o.annotations != "" {
+		opts = append(opts, "annotations="+url.QueryEscape(o.annotations))
+	}
+	if o.predicateTypes != "" {
+		opts = append(opts, "predicateTypes="+url.QueryEscape(o.predicateTypes))
+	}
+	if o.namespace != "" {
+		opts = append(opts, "namespace="+o.namespace)
+	}
+	if o.platform != "" {
+		opts = append(opts, "platform="+o.platform)
+	}
+	if o.imageSignature {
+		opts = append(opts, "signature=true")
+	}
+	if o.unsignedSBOMs != "" {
+		opts = append(opts, "unsigned="+o.unsignedSBOMs)
+	}
+	if len(opts) == 0 {
+		return key
+	}
+	return key + "|" + strings.Join(opts, ",")
+}
+
+// keyOptionsContextKey carries the options that change how a key is verified
+type keyOptionsContextKey struct{}
+
+// withKeyOptions returns a context carrying the options that change how a key is verified
+func withKeyOptions(ctx context.Context, opts keyOptions) context.Context {
+	if !opts.metadataOnly && !opts.allViolations && opts.keyRef == "" && opts.identityRegexp == "" && opts.identities == "" && opts.certExtensions == "" && opts.annotations == "" && opts.predicateTypes == "" && opts.namespace == "" && opts.platform == "" && !opts.imageSignature && opts.unsignedSBOMs == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, keyOptionsContextKey{}, opts)
+}
+
+// metadataOnly reports whether the verification of ctx skips decoding the SBOM predicate
+func metadataOnly(ctx context.Context) bool {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.metadataOnly
+}
+
+// allViolations reports whether the verification of ctx collects violations instead of
+// failing on an unexpected signer identity
+func allViolations(ctx context.Context) bool {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.allViolations
+}
+
+// keyRef returns the KMS key URI the verification of ctx requires, if set per constraint
+func keyRef(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.keyRef
+}
+
+// contextKeyOptions returns the options the verification of ctx was started with
+func contextKeyOptions(ctx context.Context) keyOptions {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts
+}
+
+// allowedIdentities returns the JSON list of identities the verification of ctx accepts, if
+// set per constraint
+func allowedIdentities(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.identities
+}
+
+// identityRegexp returns the certificate identity pattern the verification of ctx requires, if
+// set per constraint
+func identityRegexp(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.identityRegexp
+}
+
+// certExtensions returns the JSON certificate extensions the verification of ctx requires, if
+// set per constraint
+func certExtensions(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.certExtensions
+}
+
+// signedAnnotations returns the JSON annotations an image signature must carry for the
+// verification of ctx, if set per constraint
+func signedAnnotations(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.annotations
+}
+
+// predicateTypes returns the JSON list of SBOM predicate types the verification of ctx
+// accepts, if set per constraint
+func predicateTypes(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.predicateTypes
+}
+
+// pullSecretNamespace returns the namespace the verification of ctx reads pull secrets from,
+// if set per key
+func pullSecretNamespace(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.namespace
+}
+
+// platform returns the platform the verification of ctx selects from image indexes, if set per
+// constraint
+func platform(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.platform
+}
+
+// imageSignatureRequired reports whether the verification of ctx requires a cosign signature of
+// the image, if set per constraint
+func imageSignatureRequired(ctx context.Context) bool {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.imageSignature
+}
+
+// unsignedSBOMs returns how the verification of ctx handles unsigned BuildKit SBOMs, if set
+// per constraint
+func unsignedSBOMs(ctx context.Context) string {
+	opts, _ := ctx.Value(keyOptionsContextKey{}).(keyOptions)
+	return opts.unsignedSBOMs
+}
+
+// KeySettings are the constraint parameters that are part of a provider key, for endpoints that
+// build keys themselves. Keys built from the same settings and pull secrets as an admission
+// request share its cached results.
+type KeySettings struct {
+	CertIdentity          string            `json:"certIdentity,omitempty"`
+	CertIdentityRegexp    string            `json:"certIdentityRegexp,omitempty"`
+	CertOidcIssuer        string            `json:"certOidcIssuer,omitempty"`
+	Identities            []AllowedIdentity `json:"identities,omitempty"`            // Further signers, any of which may sign
+	CertExtensions        *CertExtensions   `json:"certExtensions,omitempty"`        // Extensions the signing certificate must carry
+	Annotations           map[string]string `json:"annotations,omitempty"`           // Annotations an image signature must carry
+	PredicateTypes        []string          `json:"predicateTypes,omitempty"`        // SBOM predicate types accepted
+	Platform              string            `json:"platform,omitempty"`              // Platform verified from image indexes, e.g. "linux/arm64"
+	RequireImageSignature bool              `json:"requireImageSignature,omitempty"` // Require a cosign signature of the image too
+	UnsignedBuildxSBOMs   string            `json:"unsignedBuildxSBOMs,omitempty"`   // Handling of unsigned BuildKit SBOMs: "fail" or "inventory"
+}
+
+// key returns the provider key of image pulled with pullSecrets, encoded like the policy
+// template does
+func (k *KeySettings) key(image string, pullSecrets []string) string {
+	secrets, err := json.Marshal(pullSecrets)
+	if err != nil || pullSecrets == nil {
+		secrets = []byte("[]")
+	}
+
+	var opts []string
+	if k.CertIdentityRegexp != "" {
+		opts = append(opts, "identityRegexp="+url.QueryEscape(k.CertIdentityRegexp))
+	}
+	if len(k.Identities) > 0 {
+		identities, _ := json.Marshal(k.Identities)
+		opts = append(opts, "identities="+url.QueryEscape(string(identities)))
+	}
+	if k.CertExtensions != nil {
+		extensions, _ := json.Marshal(k.CertExtensions)
+		opts = append(opts, "certExtensions="+url.QueryEscape(string(extensions)))
+	}
+	if len(k.Annotations) > 0 {
+		annotations, _ := json.Marshal(k.Annotations)
+		opts = append(opts, "annotations="+url.QueryEscape(string(annotations)))
+	}
+	if len(k.PredicateTypes) > 0 {
+		types, _ := json.Marshal(k.PredicateTypes)
+		opts = append(opts, "predicateTypes="+url.QueryEscape(string(types)))
+	}
+	if k.Platform != "" {
+		opts = append(opts, "platform="+k.Platform)
+	}
+	if k.RequireImageSignature {
+		opts = append(opts, "signature=true")
+	}
+	if k.UnsignedBuildxSBOMs != "" {
+		opts = append(opts, "unsigned="+k.UnsignedBuildxSBOMs)
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s", image, secrets, k.CertIdentity, k.CertOidcIssuer)
+	if len(opts) > 0 {
+		key += "|" + strings.Join(opts, ",")
+	}
+	return key
+}
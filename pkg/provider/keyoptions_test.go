@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSplitKeyOptions(t *testing.T) {
+	tests := []struct {
+		key              string
+		wantKey          string
+		wantDebug        bool
+		wantMetadataOnly bool
+	}{
+		{"ghcr.io/org/app:v1", "ghcr.io/org/app:v1", false, false},
+		{"ghcr.io/org/app:v1|[]|user@example.com|https://issuer", "ghcr.io/org/app:v1|[]|user@example.com|https://issuer", false, false},
+		{"ghcr.io/org/app:v1|[]|user@example.com|https://issuer|debug=true", "ghcr.io/org/app:v1|[]|user@example.com|https://issuer", true, false},
+		{"ghcr.io/org/app:v1|[]|||debug=1", "ghcr.io/org/app:v1|[]||", true, false},
+		{"ghcr.io/org/app:v1|[]|||debug=false", "ghcr.io/org/app:v1|[]||", false, false},
+		{"ghcr.io/org/app:v1|[]|||debug=maybe,unknown=x", "ghcr.io/org/app:v1|[]||", false, false},
+		{"ghcr.io/org/app:v1|[]|||", "ghcr.io/org/app:v1|[]||", false, false},
+		{"ghcr.io/org/app:v1|[]|||packages=false", "ghcr.io/org/app:v1|[]||", false, true},
+		{"ghcr.io/org/app:v1|[]|||debug=true,packages=false", "ghcr.io/org/app:v1|[]||", true, true},
+		{"ghcr.io/org/app:v1|[]|||packages=true", "ghcr.io/org/app:v1|[]||", false, false},
+		{"ghcr.io/org/app:v1|[]|||violations=all", "ghcr.io/org/app:v1|[]||", false, false},
+	}
+
+	for _, tt := range tests {
+		key, opts := splitKeyOptions(tt.key)
+		if key != tt.wantKey {
+			t.Errorf("splitKeyOptions(%q): expected key %q, got %q", tt.key, tt.wantKey, key)
+		}
+		if opts.debug != tt.wantDebug {
+			t.Errorf("splitKeyOptions(%q): expected debug %v, got %v", tt.key, tt.wantDebug, opts.debug)
+		}
+		if opts.metadataOnly != tt.wantMetadataOnly {
+			t.Errorf("splitKeyOptions(%q): expected metadataOnly %v, got %v", tt.key, tt.wantMetadataOnly, opts.metadataOnly)
+		}
+	}
+}
+
+func TestResultKey(t *testing.T) {
+	base := "ghcr.io/org/app:v1|[]||"
+
+	key, opts := splitKeyOptions(base + "|debug=true")
+	if got := opts.resultKey(key); got != base {
+		t.Errorf("Expected debug not to change the result key, got %q", got)
+	}
+
+	key, opts = splitKeyOptions(base + "|debug=true,packages=false")
+	resultKey := opts.resultKey(key)
+	if resultKey != base+"|packages=false" {
+		t.Errorf("Expected the packages option in the result key, got %q", resultKey)
+	}
+
+	// The result key round-trips to the same options
+	if key, opts := splitKeyOptions(resultKey); key != base || !opts.metadataOnly || opts.debug {
+		t.Errorf("Expected %q with metadata only, got %q %+v", base, key, opts)
+	}
+
+	// Result options are kept in canonical order
+	key, opts = splitKeyOptions(base + "|violations=all,debug=true,packages=false")
+	if got := opts.resultKey(key); got != base+"|packages=false,violations=all" {
+		t.Errorf("Expected both result options in the result key, got %q", got)
+	}
+	if key, opts := splitKeyOptions(base + "|violations=first"); opts.resultKey(key) != base {
+		t.Errorf("Expected violations=first not to change the result key, got %+v", opts)
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey(resultKey) != verifier.PolicyHashForKey(base) {
+		t.Error("Expected key options not to change the policy hash")
+	}
+
+	// A KMS key follows the other result options and changes the policy hash
+	key, opts = splitKeyOptions(base + "|key=awskms:///alias/signing,packages=false")
+	keyed := opts.resultKey(key)
+	if keyed != base+"|packages=false,key=awskms%3A%2F%2F%2Falias%2Fsigning" {
+		t.Errorf("Expected the key option last in the result key, got %q", keyed)
+	}
+	if verifier.PolicyHashForKey(keyed) == verifier.PolicyHashForKey(resultKey) {
+		t.Error("Expected the KMS key to change the policy hash")
+	}
+	// Escaped KMS URIs keep their commas and pipes out of the option list
+	if _, opts := splitKeyOptions(base + "|key=gcpkms%3A%2F%2Fprojects%2Fp%2Fkeys%2Fa%2Cb,packages=false"); opts.keyRef != "gcpkms://projects/p/keys/a,b" || !opts.metadataOnly {
+		t.Errorf("Expected the unescaped KMS URI, got %+v", opts)
+	}
+
+	// Keys that are not KMS URIs are kept so verification fails rather than falling back to keyless
+	key, opts = splitKeyOptions(base + "|key=/etc/passwd")
+	if opts.keyRef != "/etc/passwd" || opts.resultKey(key) == base {
+		t.Errorf("Expected a key option that is not a KMS URI to be kept, got %+v", opts)
+	}
+	_, _, err := verifier.verificationKey(withKeyOptions(context.Background(), opts))
+	if ErrorCode(err) != ErrCodeVerificationKey {
+		t.Errorf("Expected %s for a key that is not a KMS URI, got %v", ErrCodeVerificationKey, err)
+	}
+
+	// Identity patterns are query-escaped, keeping their commas out of the option list
+	key, opts = splitKeyOptions(base + "|packages=false,identityRegexp=https%3A%2F%2Fgithub.com%2Fmyorg%2F.%7B1%2C40%7D%2F.%2B")
+	if opts.identityRegexp != "https://github.com/myorg/.{1,40}/.+" || !opts.metadataOnly {
+		t.Fatalf("Expected the unescaped pattern, got %+v", opts)
+	}
+	patterned := opts.resultKey(key)
+	if key, roundTripped := splitKeyOptions(patterned); key != base || roundTripped != opts {
+		t.Errorf("Expected %q to round-trip, got %q %+v", patterned, key, roundTripped)
+	}
+	if verifier.PolicyHashForKey(patterned) == verifier.PolicyHashForKey(resultKey) {
+		t.Error("Expected the identity pattern to change the policy hash")
+	}
+}
+
+func TestSBOMFromAttestationsMetadataOnly(t *testing.T) {
+	statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://cyclonedx.org/bom",` +
+		`"predicate":{"bomFormat":"CycloneDX","components":[{"type":"library","name":"openssl","version":"3.0.0"}]}}`
+	envelope, err := json.Marshal(map[string]string{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+
+	verifier := &AttestationVerifier{}
+	ctx := withKeyOptions(context.Background(), keyOptions{metadataOnly: true})
+	unified, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{{payload: envelope}})
+	if err != nil {
+		t.Fatalf("Failed to extract SBOM metadata: %v", err)
+	}
+	if unified.Format != "cyclonedx" || !unified.MetadataOnly {
+		t.Errorf("Expected metadata-only cyclonedx SBOM, got %+v", unified)
+	}
+	if len(unified.Packages) != 0 || unified.Document != nil || unified.EmptySBOM {
+		t.Errorf("Expected the predicate not to be decoded, got %+v", unified)
+	}
+
+	if _, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{{payload: []byte(`{"predicateType":"https://slsa.dev/provenance/v1"}`)}}); err == nil {
+		t.Error("Expected an error without SBOM attestations")
+	}
+}
+
+func TestResolveKeyCachesMetadataOnlySeparately(t *testing.T) {
+	server := &Server{
+		verifier: &AttestationVerifier{},
+		timeout:  5 * time.Second,
+		cache:    newResultCache(),
+		cacheTTL: time.Minute,
+	}
+
+	base := "ghcr.io/org/app@" + testDigest + "|[]||"
+	server.cache.Set(server.cacheKey(base), Item{Key: base, Value: `{"format":"spdx","packages":[{"name":"curl"}]}`}, time.Minute)
+	server.cache.Set(server.cacheKey(base+"|packages=false"), Item{Key: base, Value: `{"format":"spdx","packages":[],"metadataOnly":true}`}, time.Minute)
+
+	key := base + "|packages=false"
+	item := server.resolveKey(key, false, RequestClassAdmission)
+	if item.Key != key {
+		t.Errorf("Expected key '%s', got '%s'", key, item.Key)
+	}
+	if item.Value != `{"format":"spdx","packages":[],"metadataOnly":true}` {
+		t.Errorf("Expected the metadata-only result, got '%s'", item.Value)
+	}
+
+	item = server.resolveKey(base, false, RequestClassAdmission)
+	if item.Value != `{"format":"spdx","packages":[{"name":"curl"}]}` {
+		t.Errorf("Expected the full result, got '%s'", item.Value)
+	}
+
+	if server.cacheHint(key, item, false) <= 0 {
+		t.Error("Expected the metadata-only result of a digest to be cacheable")
+	}
+}
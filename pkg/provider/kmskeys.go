@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+)
+
+// DefaultKMSKeyCacheTTL is how long public keys fetched from a KMS are reused
+const DefaultKMSKeyCacheTTL = time.Hour
+
+// isKMSRef reports whether ref is a cosign KMS key URI, e.g. "awskms:///alias/signing" or
+// "hashivault://signing". Files and URLs are not KMS references.
+func isKMSRef(ref string) bool {
+	scheme, _, ok := strings.Cut(ref, "://")
+	if !ok || scheme == "" {
+		return false
+	}
+	switch scheme {
+	case "file", "http", "https":
+		return false
+	}
+	return true
+}
+
+// kmsKey is a public key fetched from a KMS
+type kmsKey struct {
+	verifier    signature.Verifier
+	fingerprint string
+	fetchedAt   time.Time
+}
+
+// kmsKeyCache fetches verification keys from KMS providers through cosign's KMS URI scheme
+// and reuses them for a TTL, so admission requests don't reach the KMS. Keys are verified
+// against locally, only the public key is fetched.
+type kmsKeyCache struct {
+	ttl   time.Duration
+	now   func() time.Time
+	fetch func(ctx context.Context, ref string) (crypto.PublicKey, error)
+
+	mu   sync.Mutex
+	keys map[string]*kmsKey
+}
+
+// newKMSKeyCache creates a cache keeping keys for ttl (0 uses DefaultKMSKeyCacheTTL)
+func newKMSKeyCache(ttl time.Duration) *kmsKeyCache {
+	if ttl <= 0 {
+		ttl = DefaultKMSKeyCacheTTL
+	}
+	return &kmsKeyCache{ttl: ttl, now: time.Now, fetch: fetchKMSPublicKey, keys: make(map[string]*kmsKey)}
+}
+
+// fetchKMSPublicKey fetches the public key of a KMS key. Providers are registered by importing
+// their sigstore packages, other schemes are served by sigstore-kms-<scheme> plugins on the PATH.
+func fetchKMSPublicKey(ctx context.Context, ref string) (crypto.PublicKey, error) {
+	sv, err := kms.Get(ctx, ref, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return sv.PublicKey(options.WithContext(ctx))
+}
+
+// Get returns the verifier and fingerprint of the KMS key ref, fetching it when not cached or
+// expired. A failed refresh keeps using the previous key.
+func (c *kmsKeyCache) Get(ctx context.Context, ref string) (signature.Verifier, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := c.keys[ref]
+	if cached != nil && c.now().Sub(cached.fetchedAt) < c.ttl {
+		return cached.verifier, cached.fingerprint, nil
+	}
+
+	key, err := c.load(ctx, ref)
+	if err != nil {
+		if cached != nil {
+			log.Printf("Warning: failed to refresh KMS key %s, keeping the cached key: %v", ref, err)
+			return cached.verifier, cached.fingerprint, nil
+		}
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: fmt.Errorf("failed to fetch KMS key %s: %w", ref, err)}
+	}
+
+	if cached != nil && cached.fingerprint != key.fingerprint {
+		log.Printf("KMS key %s rotated to %s", ref, key.fingerprint)
+	}
+	c.keys[ref] = key
+	return key.verifier, key.fingerprint, nil
+}
+
+// load fetches a KMS public key and builds a local verifier for it
+func (c *kmsKeyCache) load(ctx context.Context, ref string) (*kmsKey, error) {
+	pub, err := c.fetch(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	verifier, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key: %w", err)
+	}
+
+	sum := sha256.Sum256(der)
+	return &kmsKey{verifier: verifier, fingerprint: "sha256:" + hex.EncodeToString(sum[:]), fetchedAt: c.now()}, nil
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIsKMSRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"awskms:///alias/signing", true},
+		{"gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k", true},
+		{"azurekms://vault.vault.azure.net/signing", true},
+		{"hashivault://signing", true},
+		{"/etc/cosign/cosign.pub", false},
+		{"file:///etc/cosign/cosign.pub", false},
+		{"https://example.com/cosign.pub", false},
+		{"://signing", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isKMSRef(tt.ref); got != tt.want {
+			t.Errorf("isKMSRef(%q): expected %v, got %v", tt.ref, tt.want, got)
+		}
+	}
+}
+
+func newTestECDSAKey(t *testing.T) crypto.PublicKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return priv.Public()
+}
+
+func TestKMSKeyCache(t *testing.T) {
+	now := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	key := newTestECDSAKey(t)
+	var fetches int
+	var fetchErr error
+
+	cache := newKMSKeyCache(time.Hour)
+	cache.now = func() time.Time { return now }
+	cache.fetch = func(ctx context.Context, ref string) (crypto.PublicKey, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return key, nil
+	}
+
+	ctx := context.Background()
+	_, fingerprint, err := cache.Get(ctx, "awskms:///alias/signing")
+	if err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+	if _, _, err := cache.Get(ctx, "awskms:///alias/signing"); err != nil || fetches != 1 {
+		t.Errorf("Expected the cached key within the TTL, got %d fetches and %v", fetches, err)
+	}
+
+	// After the TTL the key is fetched again, a rotated key replaces the cached one
+	now = now.Add(2 * time.Hour)
+	key = newTestECDSAKey(t)
+	_, rotated, err := cache.Get(ctx, "awskms:///alias/signing")
+	if err != nil || fetches != 2 || rotated == fingerprint {
+		t.Errorf("Expected the rotated key after the TTL, got %d fetches, %s and %v", fetches, rotated, err)
+	}
+
+	// A failed refresh keeps the cached key
+	now = now.Add(2 * time.Hour)
+	fetchErr = errors.New("throttled")
+	if _, got, err := cache.Get(ctx, "awskms:///alias/signing"); err != nil || got != rotated {
+		t.Errorf("Expected the stale key when the refresh fails, got %s and %v", got, err)
+	}
+
+	// A key never fetched fails verification
+	_, _, err = cache.Get(ctx, "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k")
+	var verr *VerificationError
+	if !errors.As(err, &verr) || verr.Code != ErrCodeVerificationKey {
+		t.Errorf("Expected %s, got %v", ErrCodeVerificationKey, err)
+	}
+}
+
+func TestVerificationKeyPerConstraint(t *testing.T) {
+	cache := newKMSKeyCache(0)
+	cache.fetch = func(ctx context.Context, ref string) (crypto.PublicKey, error) {
+		return newTestECDSAKey(t), nil
+	}
+	verifier := &AttestationVerifier{kmsKeys: cache}
+
+	if sv, _, err := verifier.verificationKey(context.Background()); err != nil || sv != nil {
+		t.Errorf("Expected keyless verification without a key, got %v and %v", sv, err)
+	}
+
+	ctx := withKeyOptions(context.Background(), keyOptions{keyRef: "hashivault://signing"})
+	sv, fingerprint, err := verifier.verificationKey(ctx)
+	if err != nil || sv == nil || fingerprint == "" {
+		t.Errorf("Expected the constraint's KMS key, got %v, %q and %v", sv, fingerprint, err)
+	}
+	if _, ok := cache.keys["hashivault://signing"]; !ok {
+		t.Error("Expected the KMS key to be cached")
+	}
+}
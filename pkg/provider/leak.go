@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// leakSample is a point-in-time measurement of leak-prone resources
+type leakSample struct {
+	goroutines int
+	fds        int // 0 where file descriptors cannot be measured
+}
+
+// leakMonitor samples goroutine and file descriptor counts and flags a suspected leak
+// when they exceed the first sample by the growth factor. The first sample is taken after
+// a warm-up so pools and workers started at boot are part of the baseline.
+// A nil monitor never suspects anything.
+type leakMonitor struct {
+	interval  time.Duration
+	factor    float64
+	sample    func() leakSample
+	baseline  leakSample
+	suspected atomic.Bool
+}
+
+// leakGrowthFactor is how many times the baseline a resource must reach to be flagged
+const leakGrowthFactor = 2.0
+
+// leakMinGrowth avoids flagging small absolute increases on a low baseline
+const leakMinGrowth = 100
+
+// newLeakMonitor creates a leak monitor sampling every interval
+func newLeakMonitor(interval time.Duration) *leakMonitor {
+	return &leakMonitor{
+		interval: interval,
+		factor:   leakGrowthFactor,
+		sample: func() leakSample {
+			fds, _ := openFDs()
+			return leakSample{goroutines: runtime.NumGoroutine(), fds: fds}
+		},
+	}
+}
+
+// Run samples until ctx is done. The first interval is the warm-up.
+func (m *leakMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.check()
+	}
+}
+
+// check takes a sample, setting the baseline on the first call
+func (m *leakMonitor) check() {
+	current := m.sample()
+	if m.baseline.goroutines == 0 {
+		m.baseline = current
+		log.Printf("Leak detection baseline: %d goroutines, %d open fds", current.goroutines, current.fds)
+		return
+	}
+
+	leaking := m.exceeds(current.goroutines, m.baseline.goroutines) || m.exceeds(current.fds, m.baseline.fds)
+	if leaking && !m.suspected.Load() {
+		log.Printf("Warning: possible resource leak: %d goroutines, %d open fds (baseline: %d goroutines, %d open fds)",
+			current.goroutines, current.fds, m.baseline.goroutines, m.baseline.fds)
+	}
+	m.suspected.Store(leaking)
+}
+
+// exceeds reports whether current grew beyond the leak threshold over baseline
+func (m *leakMonitor) exceeds(current, baseline int) bool {
+	return baseline > 0 && float64(current) > float64(baseline)*m.factor && current-baseline >= leakMinGrowth
+}
+
+// Suspected reports whether the latest sample exceeded the leak threshold
+func (m *leakMonitor) Suspected() bool {
+	return m != nil && m.suspected.Load()
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+// TestMain fails the package tests when goroutines outlive them
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m,
+		// Started by a dependency's init, lives for the whole process
+		goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"),
+	)
+}
+
+func TestLeakMonitorDetectsGrowth(t *testing.T) {
+	samples := []leakSample{
+		{goroutines: 50, fds: 20},  // baseline
+		{goroutines: 90, fds: 25},  // normal growth
+		{goroutines: 400, fds: 25}, // goroutine leak
+		{goroutines: 60, fds: 20},  // recovered
+	}
+	i := 0
+	monitor := newLeakMonitor(0)
+	monitor.sample = func() leakSample {
+		s := samples[i]
+		i++
+		return s
+	}
+
+	expected := []bool{false, false, true, false}
+	for n, want := range expected {
+		monitor.check()
+		if got := monitor.Suspected(); got != want {
+			t.Errorf("Sample %d: expected suspected=%v, got %v", n, want, got)
+		}
+	}
+}
+
+func TestLeakMonitorMinGrowth(t *testing.T) {
+	monitor := newLeakMonitor(0)
+	monitor.baseline = leakSample{goroutines: 10, fds: 10}
+
+	// Tripling a small baseline is not a leak until the absolute growth is significant
+	if monitor.exceeds(30, 10) {
+		t.Error("Expected small absolute growth not to be flagged")
+	}
+	if !monitor.exceeds(200, 10) {
+		t.Error("Expected large growth to be flagged")
+	}
+	if monitor.exceeds(500, 0) {
+		t.Error("Expected unmeasured baseline never to be flagged")
+	}
+
+	var nilMonitor *leakMonitor
+	if nilMonitor.Suspected() {
+		t.Error("Expected nil monitor never to suspect a leak")
+	}
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxLicenseExpressionTokens bounds the expressions parsed, longer ones are kept as recorded
+const maxLicenseExpressionTokens = 512
+
+// licenseIDPattern matches SPDX license and exception identifiers, LicenseRefs (optionally
+// prefixed by a DocumentRef) and the "+" or-later suffix
+var licenseIDPattern = regexp.MustCompile(`^[A-Za-z0-9.\-]+(:[A-Za-z0-9.\-]+)?\+?$`)
+
+// licenseExpression is a node of a parsed SPDX license expression: a license, or an operator
+// ("AND", "OR", "WITH") applied to two operands. The right operand of WITH is the exception.
+type licenseExpression struct {
+	op          string
+	license     string
+	left, right *licenseExpression
+}
+
+// parseLicenseExpression parses an SPDX license expression. Operators are case-sensitive and
+// AND binds tighter than OR, as the SPDX specification defines.
+func parseLicenseExpression(expression string) (*licenseExpression, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression))
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+	if len(tokens) > maxLicenseExpressionTokens {
+		return nil, fmt.Errorf("license expression has more than %d tokens", maxLicenseExpressionTokens)
+	}
+
+	p := &licenseParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in license expression", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+// licenses returns the licenses the expression refers to in order of appearance, without
+// duplicates. A license with an exception is listed with it, e.g. "GPL-2.0-only WITH
+// Classpath-exception-2.0", as the exception changes its terms.
+func (e *licenseExpression) licenses() []string {
+	var licenses []string
+	var walk func(*licenseExpression)
+	walk = func(e *licenseExpression) {
+		switch e.op {
+		case "":
+			licenses = appendUnique(licenses, e.license)
+		case "WITH":
+			licenses = appendUnique(licenses, e.left.license+" WITH "+e.right.license)
+		default:
+			walk(e.left)
+			walk(e.right)
+		}
+	}
+	walk(e)
+	return licenses
+}
+
+// licenseParser is a recursive descent parser over the tokens of a license expression
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+// peek returns the next token without consuming it, "" at the end
+func (p *licenseParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// parseOr parses operands joined by OR
+func (p *licenseParser) parseOr() (*licenseExpression, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "OR" {
+		p.pos++
+		var right *licenseExpression
+		if right, err = p.parseAnd(); err == nil {
+			left = &licenseExpression{op: "OR", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// parseAnd parses operands joined by AND
+func (p *licenseParser) parseAnd() (*licenseExpression, error) {
+	left, err := p.parseWith()
+	for err == nil && p.peek() == "AND" {
+		p.pos++
+		var right *licenseExpression
+		if right, err = p.parseWith(); err == nil {
+			left = &licenseExpression{op: "AND", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// parseWith parses a parenthesized expression, or a license with an optional exception
+func (p *licenseParser) parseWith() (*licenseExpression, error) {
+	if p.peek() == "(" {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in license expression")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	license, err := p.parseID()
+	if err != nil || p.peek() != "WITH" {
+		return license, err
+	}
+	p.pos++
+	exception, err := p.parseID()
+	if err != nil {
+		return nil, err
+	}
+	return &licenseExpression{op: "WITH", left: license, right: exception}, nil
+}
+
+// parseID parses a license or exception identifier
+func (p *licenseParser) parseID() (*licenseExpression, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("license expression ends unexpectedly")
+	case token == "AND" || token == "OR" || token == "WITH" || !licenseIDPattern.MatchString(token):
+		return nil, fmt.Errorf("unexpected %q in license expression", token)
+	}
+	p.pos++
+	return &licenseExpression{license: token}, nil
+}
+
+// packageLicenses returns the licenses a normalized license string refers to: the operands of
+// a valid expression, or the string itself. Missing licenses yield none.
+func packageLicenses(license string) []string {
+	if license == "" || license == "NOASSERTION" || license == "NONE" {
+		return nil
+	}
+	expr, err := parseLicenseExpression(license)
+	if err != nil {
+		return []string{license}
+	}
+	return expr.licenses()
+}
+
+// joinLicenses combines the licenses a component lists into one expression. Each applies, so
+// they are joined with AND, parenthesizing expressions with OR at the top.
+func joinLicenses(licenses []string) string {
+	if len(licenses) == 1 {
+		return licenses[0]
+	}
+	parts := make([]string, 0, len(licenses))
+	for _, license := range licenses {
+		if expr, err := parseLicenseExpression(license); err == nil && expr.op == "OR" {
+			license = "(" + license + ")"
+		}
+		parts = append(parts, license)
+	}
+	return strings.Join(parts, " AND ")
+}
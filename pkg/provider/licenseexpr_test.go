@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseLicenseExpression(t *testing.T) {
+	for expression, want := range map[string]string{
+		"MIT":                                  "MIT",
+		"MIT OR Apache-2.0":                    "MIT,Apache-2.0",
+		"(MIT OR Apache-2.0) AND BSD-3-Clause": "MIT,Apache-2.0,BSD-3-Clause",
+		"GPL-2.0-or-later WITH Classpath-exception-2.0 OR MIT": "GPL-2.0-or-later WITH Classpath-exception-2.0,MIT",
+		"LGPL-2.1+ AND LicenseRef-scancode-public-domain":      "LGPL-2.1+,LicenseRef-scancode-public-domain",
+		"DocumentRef-spdx-tool:LicenseRef-MIT-Style OR MIT":    "DocumentRef-spdx-tool:LicenseRef-MIT-Style,MIT",
+		"MIT AND (MIT OR ISC)":                                 "MIT,ISC",
+	} {
+		expr, err := parseLicenseExpression(expression)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", expression, err)
+			continue
+		}
+		if got := strings.Join(expr.licenses(), ","); got != want {
+			t.Errorf("Expected the licenses of %q to be %s, got %s", expression, want, got)
+		}
+	}
+
+	// AND binds tighter than OR
+	expr, err := parseLicenseExpression("MIT AND ISC OR Apache-2.0")
+	if err != nil || expr.op != "OR" || expr.left.op != "AND" {
+		t.Errorf("Expected OR at the top, got %+v, %v", expr, err)
+	}
+
+	for _, expression := range []string{
+		"",
+		"Apache License 2.0",
+		"MIT or Apache-2.0",
+		"(MIT OR Apache-2.0",
+		"MIT OR",
+		"MIT WITH (Classpath-exception-2.0)",
+		"AND MIT",
+		strings.Repeat("MIT AND ", maxLicenseExpressionTokens) + "MIT",
+	} {
+		if _, err := parseLicenseExpression(expression); err == nil {
+			t.Errorf("Expected an error for %q", expression)
+		}
+	}
+}
+
+func TestPackageLicenses(t *testing.T) {
+	if got := packageLicenses("MIT OR Apache-2.0"); strings.Join(got, ",") != "MIT,Apache-2.0" {
+		t.Errorf("Expected the operands, got %v", got)
+	}
+	if got := packageLicenses("Some Custom License"); len(got) != 1 || got[0] != "Some Custom License" {
+		t.Errorf("Expected the license as recorded, got %v", got)
+	}
+	for _, license := range []string{"", "NOASSERTION", "NONE"} {
+		if got := packageLicenses(license); got != nil {
+			t.Errorf("Expected no licenses for %q, got %v", license, got)
+		}
+	}
+
+	if got := joinLicenses([]string{"MIT OR Apache-2.0", "BSD-3-Clause", "Some License"}); got != "(MIT OR Apache-2.0) AND BSD-3-Clause AND Some License" {
+		t.Errorf("Expected the licenses joined with AND, got %q", got)
+	}
+	if got := joinLicenses(nil); got != "" {
+		t.Errorf("Expected no license, got %q", got)
+	}
+}
+
+func TestExtractCycloneDXLicenseExpression(t *testing.T) {
+	aliases, _ := loadLicenseAliases("")
+	verifier := &AttestationVerifier{licenseAliases: aliases}
+
+	cyclonedx := `{"bomFormat": "CycloneDX", "components": [
+		{"name": "a", "licenses": [{"expression": "MIT OR Apache-2.0"}]},
+		{"name": "b", "licenses": [{"license": {"id": "MIT"}}, {"license": {"name": "GPLv2"}}]},
+		{"name": "c", "licenses": [{"expression": "Apache License 2.0 OR GPLv2"}]},
+		{"name": "d"}
+	]}`
+	unified, err := verifier.extractAndNormalizeCycloneDX(json.RawMessage(cyclonedx))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+
+	want := []struct{ license, raw, licenses string }{
+		{"MIT OR Apache-2.0", "", "MIT,Apache-2.0"},
+		{"MIT AND GPL-2.0-only", "MIT AND GPLv2", "MIT,GPL-2.0-only"},
+		{"Apache-2.0 OR GPL-2.0-only", "Apache License 2.0 OR GPLv2", "Apache-2.0,GPL-2.0-only"},
+		{"", "", ""},
+	}
+	for i, w := range want {
+		pkg := unified.Packages[i]
+		if pkg.License != w.license || pkg.LicenseRaw != w.raw || strings.Join(pkg.Licenses, ",") != w.licenses {
+			t.Errorf("Expected %s to have license %q (raw %q, licenses %s), got %+v", pkg.Name, w.license, w.raw, w.licenses, pkg)
+		}
+	}
+}
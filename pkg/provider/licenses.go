@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultLicenseAliases maps license names SBOM generators commonly emit instead of SPDX
+// identifiers to the identifiers, keyed in licenseAliasKey form
+var defaultLicenseAliases = map[string]string{
+	"apache 2":                        "Apache-2.0",
+	"apache 2.0":                      "Apache-2.0",
+	"apache-2":                        "Apache-2.0",
+	"apache2":                         "Apache-2.0",
+	"apache license 2.0":              "Apache-2.0",
+	"apache license, version 2.0":     "Apache-2.0",
+	"apache software license 2.0":     "Apache-2.0",
+	"the apache license, version 2.0": "Apache-2.0",
+	"the apache software license, version 2.0": "Apache-2.0",
+	"asl 2.0":                                "Apache-2.0",
+	"mit license":                            "MIT",
+	"the mit license":                        "MIT",
+	"expat":                                  "MIT",
+	"bsd 2-clause":                           "BSD-2-Clause",
+	"bsd 2-clause license":                   "BSD-2-Clause",
+	"simplified bsd license":                 "BSD-2-Clause",
+	"freebsd license":                        "BSD-2-Clause",
+	"bsd 3-clause":                           "BSD-3-Clause",
+	"bsd 3-clause license":                   "BSD-3-Clause",
+	"new bsd license":                        "BSD-3-Clause",
+	"modified bsd license":                   "BSD-3-Clause",
+	"the bsd 3-clause license":               "BSD-3-Clause",
+	"isc license":                            "ISC",
+	"gplv2":                                  "GPL-2.0-only",
+	"gpl v2":                                 "GPL-2.0-only",
+	"gpl-2":                                  "GPL-2.0-only",
+	"gpl-2.0":                                "GPL-2.0-only",
+	"gnu general public license v2":          "GPL-2.0-only",
+	"gnu general public license, version 2":  "GPL-2.0-only",
+	"gplv2+":                                 "GPL-2.0-or-later",
+	"gpl-2+":                                 "GPL-2.0-or-later",
+	"gpl-2.0+":                               "GPL-2.0-or-later",
+	"gplv3":                                  "GPL-3.0-only",
+	"gpl v3":                                 "GPL-3.0-only",
+	"gpl-3":                                  "GPL-3.0-only",
+	"gpl-3.0":                                "GPL-3.0-only",
+	"gnu general public license v3":          "GPL-3.0-only",
+	"gnu general public license, version 3":  "GPL-3.0-only",
+	"gplv3+":                                 "GPL-3.0-or-later",
+	"gpl-3+":                                 "GPL-3.0-or-later",
+	"gpl-3.0+":                               "GPL-3.0-or-later",
+	"lgplv2.1":                               "LGPL-2.1-only",
+	"lgpl-2.1":                               "LGPL-2.1-only",
+	"lgplv2.1+":                              "LGPL-2.1-or-later",
+	"lgpl-2.1+":                              "LGPL-2.1-or-later",
+	"lgplv3":                                 "LGPL-3.0-only",
+	"lgpl-3.0":                               "LGPL-3.0-only",
+	"lgplv3+":                                "LGPL-3.0-or-later",
+	"lgpl-3.0+":                              "LGPL-3.0-or-later",
+	"mozilla public license 2.0":             "MPL-2.0",
+	"mpl 2.0":                                "MPL-2.0",
+	"mpl2":                                   "MPL-2.0",
+	"eclipse public license 1.0":             "EPL-1.0",
+	"epl 1.0":                                "EPL-1.0",
+	"eclipse public license 2.0":             "EPL-2.0",
+	"epl 2.0":                                "EPL-2.0",
+	"zlib license":                           "Zlib",
+	"the unlicense":                          "Unlicense",
+	"cc0":                                    "CC0-1.0",
+	"cc0 1.0":                                "CC0-1.0",
+	"cc0 1.0 universal":                      "CC0-1.0",
+	"creative commons zero v1.0 universal":   "CC0-1.0",
+	"gnu lesser general public license v2.1": "LGPL-2.1-only",
+	"gnu lesser general public license v3":   "LGPL-3.0-only",
+	"gnu affero general public license v3":   "AGPL-3.0-only",
+	"agplv3":                                 "AGPL-3.0-only",
+	"agpl-3.0":                               "AGPL-3.0-only",
+	"python software foundation license":     "PSF-2.0",
+	"boost software license 1.0":             "BSL-1.0",
+	"common development and distribution license 1.0": "CDDL-1.0",
+}
+
+// licenseExpressionSeparator matches the SPDX expression operators and parentheses. Operators
+// are case-sensitive, so "or later" in a license name is not split.
+var licenseExpressionSeparator = regexp.MustCompile(`\s+(?:AND|OR|WITH)\s+|[()]`)
+
+// licenseAliases maps license strings to SPDX expressions, keyed in licenseAliasKey form
+type licenseAliases map[string]string
+
+// licenseAliasKey returns the form license strings are matched in: lowercased (any script)
+// with whitespace collapsed
+func licenseAliasKey(license string) string {
+	return strings.ToLower(strings.Join(strings.Fields(license), " "))
+}
+
+// loadLicenseAliases returns the default aliases extended by the JSON object of alias to SPDX
+// expression in the file at path, whose entries override the defaults (empty path: defaults only)
+func loadLicenseAliases(path string) (licenseAliases, error) {
+	aliases := make(licenseAliases, len(defaultLicenseAliases))
+	for k, v := range defaultLicenseAliases {
+		aliases[k] = v
+	}
+	if path == "" {
+		return aliases, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license aliases file: %w", err)
+	}
+	var custom map[string]string
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse license aliases file: %w", err)
+	}
+
+	seen := make(map[string]string, len(custom))
+	for alias, license := range custom {
+		key := licenseAliasKey(alias)
+		if key == "" || strings.TrimSpace(license) == "" {
+			return nil, fmt.Errorf("invalid license alias %q: alias and license are required", alias)
+		}
+		if other, ok := seen[key]; ok && custom[other] != license {
+			return nil, fmt.Errorf("license aliases %q and %q match the same strings but map to different licenses", other, alias)
+		}
+		seen[key] = alias
+		aliases[key] = strings.TrimSpace(license)
+	}
+	return aliases, nil
+}
+
+// normalize returns license with aliases replaced by their SPDX expression. The whole string is
+// looked up first, then each operand of an SPDX expression, so "Apache License 2.0 OR GPLv2"
+// becomes "Apache-2.0 OR GPL-2.0-only". Strings without an alias are returned unchanged.
+func (a licenseAliases) normalize(license string) string {
+	if license == "" || len(a) == 0 {
+		return license
+	}
+	if normalized, ok := a[licenseAliasKey(license)]; ok {
+		return normalized
+	}
+
+	var b strings.Builder
+	last := 0
+	operand := func(s string) {
+		trimmed := strings.TrimSpace(s)
+		if normalized, ok := a[licenseAliasKey(trimmed)]; ok && trimmed != "" {
+			s = strings.Replace(s, trimmed, normalized, 1)
+		}
+		b.WriteString(s)
+	}
+	for _, loc := range licenseExpressionSeparator.FindAllStringIndex(license, -1) {
+		operand(license[last:loc[0]])
+		b.WriteString(license[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	operand(license[last:])
+	return b.String()
+}
+
+// hash returns a hash of the aliases for the policy hash, empty when there are none
+func (a licenseAliases) hash() string {
+	if len(a) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(a) // Keys are sorted
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// rawLicense returns the license an SBOM recorded when normalization changed it, empty otherwise
+func rawLicense(license, normalized string) string {
+	if license == normalized {
+		return ""
+	}
+	return license
+}
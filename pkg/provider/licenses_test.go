@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeLicense(t *testing.T) {
+	aliases, err := loadLicenseAliases("")
+	if err != nil {
+		t.Fatalf("Expected the default aliases, got %v", err)
+	}
+
+	for license, want := range map[string]string{
+		"Apache License, Version 2.0": "Apache-2.0",
+		"  the   MIT license ":        "MIT",
+		"GPLv2":                       "GPL-2.0-only",
+		"GPLv2+":                      "GPL-2.0-or-later",
+		"Apache License 2.0 OR GPLv2": "Apache-2.0 OR GPL-2.0-only",
+		"(MIT License AND BSD 3-Clause) OR GPLv3": "(MIT AND BSD-3-Clause) OR GPL-3.0-only",
+		"GPL-2.0 WITH Classpath-exception-2.0":    "GPL-2.0-only WITH Classpath-exception-2.0",
+		"Apache-2.0":                              "Apache-2.0",
+		"Proprietary":                             "Proprietary",
+		"NOASSERTION":                             "NOASSERTION",
+		"":                                        "",
+		// Lowercase operators are part of the license name
+		"GPLv2 or later": "GPLv2 or later",
+	} {
+		if got := aliases.normalize(license); got != want {
+			t.Errorf("Expected %q to normalize to %q, got %q", license, want, got)
+		}
+	}
+
+	if got := rawLicense("GPLv2", "GPL-2.0-only"); got != "GPLv2" {
+		t.Errorf("Expected the recorded license, got %q", got)
+	}
+	if got := rawLicense("MIT", "MIT"); got != "" {
+		t.Errorf("Expected no recorded license when unchanged, got %q", got)
+	}
+}
+
+func TestLoadLicenseAliases(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, aliases interface{}) string {
+		path := filepath.Join(dir, name)
+		data, _ := json.Marshal(aliases)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("Failed to write aliases: %v", err)
+		}
+		return path
+	}
+
+	aliases, err := loadLicenseAliases(write("aliases.json", map[string]string{
+		"Licencia Apache 2.0":                  "Apache-2.0",
+		"ALLGEMEINE ÖFFENTLICHE GNU-LIZENZ V2": "GPL-2.0-only",
+		"GPLv2":                                "GPL-2.0-or-later",
+	}))
+	if err != nil {
+		t.Fatalf("Expected the aliases to load, got %v", err)
+	}
+	for license, want := range map[string]string{
+		"licencia apache 2.0":                  "Apache-2.0",
+		"Allgemeine Öffentliche GNU-Lizenz v2": "GPL-2.0-only",
+		"GPLv2":                                "GPL-2.0-or-later",
+		"The MIT License":                      "MIT",
+	} {
+		if got := aliases.normalize(license); got != want {
+			t.Errorf("Expected %q to normalize to %q, got %q", license, want, got)
+		}
+	}
+
+	defaults, _ := loadLicenseAliases("")
+	if aliases.hash() == defaults.hash() {
+		t.Error("Expected custom aliases to change the hash")
+	}
+	if (licenseAliases{}).hash() != "" {
+		t.Error("Expected no hash without aliases")
+	}
+
+	for name, path := range map[string]string{
+		"missing file":        filepath.Join(dir, "missing.json"),
+		"empty license":       write("empty.json", map[string]string{"GPLv2": ""}),
+		"not an object":       write("list.json", []string{"GPLv2"}),
+		"conflicting aliases": write("conflict.json", map[string]string{"GPLv2": "GPL-2.0-only", "gplv2": "GPL-2.0-or-later"}),
+	} {
+		if _, err := loadLicenseAliases(path); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestExtractNormalizesLicenses(t *testing.T) {
+	aliases, _ := loadLicenseAliases("")
+	verifier := &AttestationVerifier{licenseAliases: aliases}
+
+	spdx := `{"spdxVersion": "SPDX-2.3", "packages": [
+		{"name": "a", "licenseDeclared": "Apache License 2.0"},
+		{"name": "b", "licenseConcluded": "MIT"}
+	]}`
+	unified, err := verifier.extractAndNormalizeSPDX(json.RawMessage(spdx))
+	if err != nil {
+		t.Fatalf("Expected the SPDX SBOM to parse, got %v", err)
+	}
+	if unified.Packages[0].License != "Apache-2.0" || unified.Packages[0].LicenseRaw != "Apache License 2.0" {
+		t.Errorf("Expected the declared license to normalize, got %+v", unified.Packages[0])
+	}
+	if unified.Packages[1].License != "MIT" || unified.Packages[1].LicenseRaw != "" {
+		t.Errorf("Expected the SPDX identifier to be kept, got %+v", unified.Packages[1])
+	}
+
+	cyclonedx := `{"bomFormat": "CycloneDX", "components": [
+		{"name": "c", "licenses": [{"license": {"name": "GNU General Public License v3"}}]}
+	]}`
+	unified, err = verifier.extractAndNormalizeCycloneDX(json.RawMessage(cyclonedx))
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM to parse, got %v", err)
+	}
+	if unified.Packages[0].License != "GPL-3.0-only" || unified.Packages[0].LicenseRaw != "GNU General Public License v3" {
+		t.Errorf("Expected the license name to normalize, got %+v", unified.Packages[0])
+	}
+}
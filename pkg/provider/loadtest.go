@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadTestConfig holds the load test harness settings
+type LoadTestConfig struct {
+	// URL is the /verify endpoint of the provider under test
+	URL string
+	// Keys is the mix of provider keys requests are drawn from; repeat a key to weight it
+	Keys []string
+	// KeysPerRequest is the number of keys sent in each ProviderRequest
+	KeysPerRequest int
+	// Concurrency is the number of requests in flight at once
+	Concurrency int
+	// Requests stops the test after this many requests (0 runs for Duration)
+	Requests int
+	// Duration stops the test after this long when Requests is 0
+	Duration time.Duration
+	// Timeout bounds each request
+	Timeout time.Duration
+	// InsecureSkipVerify disables TLS verification, e.g. for self-signed provider certificates
+	InsecureSkipVerify bool
+}
+
+// LoadTestReport summarizes a load test run
+type LoadTestReport struct {
+	Requests      int           `json:"requests"`
+	Failed        int           `json:"failed"` // Requests that failed at the transport or HTTP level
+	Items         int           `json:"items"`
+	ItemErrors    int           `json:"itemErrors"`
+	ItemsPending  int           `json:"itemsPending"`
+	Elapsed       time.Duration `json:"elapsed"`
+	Throughput    float64       `json:"throughput"` // Requests per second
+	LatencyP50    time.Duration `json:"latencyP50"`
+	LatencyP90    time.Duration `json:"latencyP90"`
+	LatencyP99    time.Duration `json:"latencyP99"`
+	LatencyMax    time.Duration `json:"latencyMax"`
+	ErrorRate     float64       `json:"errorRate"`     // Failed requests / requests
+	ItemErrorRate float64       `json:"itemErrorRate"` // Item errors / items
+}
+
+// loadTestResult is the outcome of a single request
+type loadTestResult struct {
+	latency time.Duration
+	failed  bool
+	items   int
+	errors  int
+	pending int
+}
+
+// RunLoadTest fires synthetic ProviderRequests at a running provider and reports latencies and error rates
+func RunLoadTest(ctx context.Context, cfg LoadTestConfig) (*LoadTestReport, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		return nil, fmt.Errorf("requests or duration must be positive")
+	}
+	if cfg.KeysPerRequest < 1 {
+		cfg.KeysPerRequest = 1
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+			MaxIdleConnsPerHost: cfg.Concurrency,
+		},
+	}
+
+	// Stop issuing requests after Duration, letting in-flight ones complete
+	stop := ctx
+	if cfg.Requests <= 0 {
+		var cancel context.CancelFunc
+		stop, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	// Each token is one request to send; the channel closes once Requests are issued or stop ends
+	tokens := make(chan struct{})
+	go func() {
+		defer close(tokens)
+		for i := 0; cfg.Requests <= 0 || i < cfg.Requests; i++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-stop.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var results []loadTestResult
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for range tokens {
+				result := sendLoadTestRequest(ctx, client, cfg, rng)
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}(time.Now().UnixNano() + int64(w))
+	}
+	wg.Wait()
+
+	return newLoadTestReport(results, time.Since(start)), nil
+}
+
+// sendLoadTestRequest sends one ProviderRequest with keys drawn from the configured mix
+func sendLoadTestRequest(ctx context.Context, client *http.Client, cfg LoadTestConfig, rng *rand.Rand) loadTestResult {
+	keys := make([]string, cfg.KeysPerRequest)
+	for i := range keys {
+		keys[i] = cfg.Keys[rng.Intn(len(cfg.Keys))]
+	}
+
+	body, _ := json.Marshal(ProviderRequest{
+		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
+		Kind:       "ProviderRequest",
+		Request:    Request{Keys: keys},
+	})
+
+	start := time.Now()
+	result := loadTestResult{failed: true}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.latency = time.Since(start)
+		return result
+	}
+	defer resp.Body.Close()
+
+	var providerResp ProviderResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&providerResp)
+	io.Copy(io.Discard, resp.Body)
+	result.latency = time.Since(start)
+
+	if resp.StatusCode != http.StatusOK || decodeErr != nil || providerResp.Response.SystemError != "" {
+		return result
+	}
+
+	result.failed = false
+	result.items = len(providerResp.Response.Items)
+	for _, item := range providerResp.Response.Items {
+		if item.Error != "" {
+			result.errors++
+		} else if item.Value == pendingValue {
+			result.pending++
+		}
+	}
+	return result
+}
+
+// newLoadTestReport aggregates request results
+func newLoadTestReport(results []loadTestResult, elapsed time.Duration) *LoadTestReport {
+	report := &LoadTestReport{
+		Requests: len(results),
+		Elapsed:  elapsed,
+	}
+	if len(results) == 0 {
+		return report
+	}
+
+	latencies := make([]time.Duration, 0, len(results))
+	for _, r := range results {
+		latencies = append(latencies, r.latency)
+		report.Items += r.items
+		report.ItemErrors += r.errors
+		report.ItemsPending += r.pending
+		if r.failed {
+			report.Failed++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	report.LatencyP50 = percentile(latencies, 50)
+	report.LatencyP90 = percentile(latencies, 90)
+	report.LatencyP99 = percentile(latencies, 99)
+	report.LatencyMax = latencies[len(latencies)-1]
+	report.ErrorRate = float64(report.Failed) / float64(report.Requests)
+	if report.Items > 0 {
+		report.ItemErrorRate = float64(report.ItemErrors) / float64(report.Items)
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	return report
+}
+
+// percentile returns the p-th percentile of sorted latencies (nearest rank)
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// String renders the report for operators
+func (r *LoadTestReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Requests:     %d in %v (%.1f req/s)\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(&b, "Failed:       %d (%.2f%%)\n", r.Failed, r.ErrorRate*100)
+	fmt.Fprintf(&b, "Items:        %d (%d errors, %.2f%%; %d pending)\n", r.Items, r.ItemErrors, r.ItemErrorRate*100, r.ItemsPending)
+	fmt.Fprintf(&b, "Latency:      p50 %v, p90 %v, p99 %v, max %v\n",
+		r.LatencyP50.Round(time.Microsecond), r.LatencyP90.Round(time.Microsecond),
+		r.LatencyP99.Round(time.Microsecond), r.LatencyMax.Round(time.Microsecond))
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunLoadTest(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		var req ProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		items := make([]Item, 0, len(req.Request.Keys))
+		for _, key := range req.Request.Keys {
+			if key == "broken" {
+				items = append(items, Item{Key: key, Error: "failed"})
+			} else {
+				items = append(items, Item{Key: key, Value: "{}"})
+			}
+		}
+		json.NewEncoder(w).Encode(ProviderResponse{Response: Response{Items: items}})
+	}))
+	defer ts.Close()
+
+	report, err := RunLoadTest(context.Background(), LoadTestConfig{
+		URL:            ts.URL,
+		Keys:           []string{"broken"},
+		KeysPerRequest: 3,
+		Concurrency:    4,
+		Requests:       20,
+	})
+	if err != nil {
+		t.Fatalf("Load test failed: %v", err)
+	}
+
+	if report.Requests != 20 || requests.Load() != 20 {
+		t.Errorf("Expected 20 requests, got %d (server saw %d)", report.Requests, requests.Load())
+	}
+	if report.Items != 60 || report.ItemErrors != 60 {
+		t.Errorf("Expected 60 failed items, got %d items with %d errors", report.Items, report.ItemErrors)
+	}
+	if report.Failed != 0 || report.ItemErrorRate != 1 {
+		t.Errorf("Expected no failed requests and item error rate 1, got %d and %v", report.Failed, report.ItemErrorRate)
+	}
+	if report.LatencyMax < report.LatencyP50 {
+		t.Errorf("Expected max latency >= p50, got %v < %v", report.LatencyMax, report.LatencyP50)
+	}
+}
+
+func TestRunLoadTestHTTPErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	report, err := RunLoadTest(context.Background(), LoadTestConfig{
+		URL:      ts.URL,
+		Keys:     []string{"nginx"},
+		Duration: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Load test failed: %v", err)
+	}
+
+	if report.Requests == 0 || report.ErrorRate != 1 {
+		t.Errorf("Expected every request to fail, got %d requests with error rate %v", report.Requests, report.ErrorRate)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	if p := percentile(latencies, 50); p != 50*time.Millisecond {
+		t.Errorf("Expected p50 50ms, got %v", p)
+	}
+	if p := percentile(latencies, 99); p != 99*time.Millisecond {
+		t.Errorf("Expected p99 99ms, got %v", p)
+	}
+	if p := percentile(latencies[:1], 99); p != time.Millisecond {
+		t.Errorf("Expected single sample percentile 1ms, got %v", p)
+	}
+}
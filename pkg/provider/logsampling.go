@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLogSamplingWindow is how long identical per-image log lines are summarized for
+const DefaultLogSamplingWindow = time.Minute
+
+// maxSampledLogLines bounds the distinct lines tracked per window, lines beyond it are
+// logged unsampled
+const maxSampledLogLines = 10000
+
+// logSampler logs the first occurrence of a line per window and counts its identical repeats,
+// which are summarized once the window ends, so a workload redeploying in a loop logs its
+// failure once a minute rather than on every admission request. A nil sampler logs every line.
+type logSampler struct {
+	window time.Duration
+	now    func() time.Time
+	logf   func(format string, args ...interface{})
+
+	mu    sync.Mutex
+	lines map[string]*sampledLine
+
+	suppressed atomic.Int64 // Lines suppressed since startup
+}
+
+// sampledLine tracks a logged line within its window
+type sampledLine struct {
+	since   time.Time
+	repeats int
+}
+
+// newLogSampler creates a sampler summarizing identical lines over window
+func newLogSampler(window time.Duration) *logSampler {
+	return &logSampler{
+		window: window,
+		now:    time.Now,
+		logf:   log.Printf,
+		lines:  make(map[string]*sampledLine),
+	}
+}
+
+// Printf logs a line unless it was already logged within the window
+func (l *logSampler) Printf(format string, args ...interface{}) {
+	if l == nil {
+		log.Printf(format, args...)
+		return
+	}
+	line := fmt.Sprintf(format, args...)
+	now := l.now()
+
+	l.mu.Lock()
+	entry, ok := l.lines[line]
+	if ok && now.Sub(entry.since) < l.window {
+		entry.repeats++
+		l.mu.Unlock()
+		l.suppressed.Add(1)
+		return
+	}
+	repeats := 0
+	if ok {
+		repeats = entry.repeats
+		delete(l.lines, line)
+	}
+	if len(l.lines) < maxSampledLogLines {
+		l.lines[line] = &sampledLine{since: now}
+	}
+	l.mu.Unlock()
+
+	if repeats > 0 {
+		l.logf("%s (repeated %d more times since %s)", line, repeats, formatTimestamp(entry.since))
+		return
+	}
+	l.logf("%s", line)
+}
+
+// Flush summarizes the repeats of lines whose window ended and stops tracking them
+func (l *logSampler) Flush() {
+	now := l.now()
+
+	type summary struct {
+		line string
+		*sampledLine
+	}
+	var summaries []summary
+	l.mu.Lock()
+	for line, entry := range l.lines {
+		if now.Sub(entry.since) < l.window {
+			continue
+		}
+		delete(l.lines, line)
+		if entry.repeats > 0 {
+			summaries = append(summaries, summary{line, entry})
+		}
+	}
+	l.mu.Unlock()
+
+	for _, s := range summaries {
+		l.logf("%s (repeated %d more times since %s)", s.line, s.repeats, formatTimestamp(s.since))
+	}
+}
+
+// Run flushes ended windows until ctx is done
+func (l *logSampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		l.Flush()
+	}
+}
+
+// writeLogSamplingMetrics writes the suppressed line counter in the Prometheus text format
+func (l *logSampler) writeLogSamplingMetrics(w io.Writer) {
+	if l == nil {
+		return
+	}
+	fmt.Fprintf(w, "# HELP sbom_provider_log_lines_suppressed_total Repeated identical log lines summarized by log sampling.\n")
+	fmt.Fprintf(w, "# TYPE sbom_provider_log_lines_suppressed_total counter\n")
+	fmt.Fprintf(w, "sbom_provider_log_lines_suppressed_total %d\n", l.suppressed.Load())
+}
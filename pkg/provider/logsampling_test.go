@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLogSampler creates a sampler on a fake clock, recording the lines it logs
+func newTestLogSampler(window time.Duration) (*logSampler, *time.Time, *[]string) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	var lines []string
+	l := newLogSampler(window)
+	l.now = func() time.Time { return now }
+	l.logf = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	return l, &now, &lines
+}
+
+func TestLogSamplerSuppressesRepeats(t *testing.T) {
+	l, now, lines := newTestLogSampler(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		l.Printf("Error for %s: %s", "ghcr.io/org/app:v1", "no matching attestations")
+	}
+	l.Printf("Error for %s: %s", "ghcr.io/org/other:v1", "no matching attestations")
+	if len(*lines) != 2 {
+		t.Fatalf("Expected 2 logged lines, got %v", *lines)
+	}
+
+	*now = now.Add(time.Minute)
+	l.Printf("Error for %s: %s", "ghcr.io/org/app:v1", "no matching attestations")
+	if len(*lines) != 3 {
+		t.Fatalf("Expected 3 logged lines, got %v", *lines)
+	}
+	want := "Error for ghcr.io/org/app:v1: no matching attestations (repeated 2 more times since 2026-10-16T09:00:00Z)"
+	if (*lines)[2] != want {
+		t.Errorf("Expected %q, got %q", want, (*lines)[2])
+	}
+	if got := l.suppressed.Load(); got != 2 {
+		t.Errorf("Expected 2 suppressed lines, got %d", got)
+	}
+}
+
+func TestLogSamplerFlush(t *testing.T) {
+	l, now, lines := newTestLogSampler(time.Minute)
+
+	l.Printf("Verification of %s failed: %s", "ghcr.io/org/app:v1", "timeout")
+	l.Printf("Verification of %s failed: %s", "ghcr.io/org/app:v1", "timeout")
+	l.Printf("Verification of %s failed: %s", "ghcr.io/org/other:v1", "timeout")
+
+	l.Flush()
+	if len(*lines) != 2 {
+		t.Fatalf("Expected no summary before the window ends, got %v", *lines)
+	}
+
+	*now = now.Add(time.Minute)
+	l.Flush()
+	if len(*lines) != 3 || !strings.HasSuffix((*lines)[2], "(repeated 1 more times since 2026-10-16T09:00:00Z)") {
+		t.Fatalf("Expected one summary, got %v", *lines)
+	}
+	if len(l.lines) != 0 {
+		t.Errorf("Expected ended windows to be forgotten, got %d lines", len(l.lines))
+	}
+
+	// A line after the flush starts a new window and is logged as is
+	l.Printf("Verification of %s failed: %s", "ghcr.io/org/app:v1", "timeout")
+	if (*lines)[3] != "Verification of ghcr.io/org/app:v1 failed: timeout" {
+		t.Errorf("Expected the line to be logged unsummarized, got %q", (*lines)[3])
+	}
+}
+
+func TestNilLogSampler(t *testing.T) {
+	var l *logSampler
+	l.Printf("Error for %s: %s", "ghcr.io/org/app:v1", "no matching attestations")
+
+	var buf bytes.Buffer
+	l.writeLogSamplingMetrics(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no metrics, got %s", buf.String())
+	}
+}
+
+func TestLogSamplingMetrics(t *testing.T) {
+	l, _, _ := newTestLogSampler(time.Minute)
+	l.Printf("same line")
+	l.Printf("same line")
+
+	var buf bytes.Buffer
+	l.writeLogSamplingMetrics(&buf)
+	if !strings.Contains(buf.String(), "sbom_provider_log_lines_suppressed_total 1\n") {
+		t.Errorf("Expected 1 suppressed line, got %s", buf.String())
+	}
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMemoryShedThresholds are the percentages of the memory limit above which new
+// verifications of each class are shed. Admission is never shed unless configured.
+var DefaultMemoryShedThresholds = map[string]int{
+	RequestClassBatch: 80,
+	RequestClassAudit: 90,
+}
+
+// DefaultMemoryCheckInterval is how often memory usage is sampled for load shedding
+const DefaultMemoryCheckInterval = 5 * time.Second
+
+// cgroupMemoryLimitFiles hold the container memory limit under cgroup v2 and v1
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// cgroupUnlimited is above any real cgroup v1 limit, which reports "unlimited" as a huge value
+const cgroupUnlimited = 1 << 60
+
+// parseMemoryShedThresholds parses "class=percent" entries on top of the default thresholds
+func parseMemoryShedThresholds(specs []string) (map[string]int, error) {
+	thresholds := make(map[string]int, len(DefaultMemoryShedThresholds))
+	for class, percent := range DefaultMemoryShedThresholds {
+		thresholds[class] = percent
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		class, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid memory shed threshold %q (expected class=percent)", spec)
+		}
+		if _, known := DefaultRequestClassWeights[class]; !known {
+			return nil, fmt.Errorf("unknown request class %q", class)
+		}
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent < 1 || percent > 100 {
+			return nil, fmt.Errorf("invalid memory shed threshold %q for request class %s (expected a percentage from 1 to 100)", value, class)
+		}
+		thresholds[class] = percent
+	}
+	return thresholds, nil
+}
+
+// cgroupMemoryLimit returns the memory limit of the container, or false when it is unlimited
+// or cannot be read
+func cgroupMemoryLimit() (int64, bool) {
+	for _, path := range cgroupMemoryLimitFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil || limit <= 0 || limit >= cgroupUnlimited {
+			// "max" under cgroup v2
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
+
+// processMemory returns the resident set size of the process, falling back to the memory
+// mapped by the Go runtime where RSS cannot be measured
+func processMemory() int64 {
+	if data, err := os.ReadFile("/proc/self/statm"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 1 {
+			if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return pages * int64(os.Getpagesize())
+			}
+		}
+	}
+
+	samples := []metrics.Sample{{Name: "/memory/classes/total:bytes"}, {Name: "/memory/classes/heap/released:bytes"}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// memoryShedder samples process memory and sheds new verifications of request classes whose
+// threshold the usage is above, so giant SBOMs verified for audit or batch traffic cannot run
+// the provider out of memory and take admission down with it. A nil shedder never sheds.
+type memoryShedder struct {
+	limit      int64
+	thresholds map[string]int // Percent of limit by request class
+	interval   time.Duration
+	sample     func() int64
+
+	usage atomic.Int64
+
+	mu        sync.Mutex
+	shedding  map[string]bool
+	shedTotal map[string]int64
+}
+
+// newMemoryShedder creates a shedder against limit bytes, sampling every interval
+func newMemoryShedder(limit int64, thresholds map[string]int, interval time.Duration) *memoryShedder {
+	if interval <= 0 {
+		interval = DefaultMemoryCheckInterval
+	}
+	m := &memoryShedder{
+		limit:      limit,
+		thresholds: thresholds,
+		interval:   interval,
+		sample:     processMemory,
+		shedding:   make(map[string]bool),
+		shedTotal:  make(map[string]int64),
+	}
+	m.check()
+	return m
+}
+
+// Run samples until ctx is done
+func (m *memoryShedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.check()
+	}
+}
+
+// check samples memory usage, logging classes that start or stop being shed
+func (m *memoryShedder) check() {
+	usage := m.sample()
+	m.usage.Store(usage)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for class := range m.thresholds {
+		shedding := m.exceeds(usage, class)
+		if shedding == m.shedding[class] {
+			continue
+		}
+		m.shedding[class] = shedding
+		if shedding {
+			log.Printf("Warning: memory usage %d MiB is above %d%% of the %d MiB limit, shedding %s verifications",
+				usage>>20, m.thresholds[class], m.limit>>20, class)
+		} else {
+			log.Printf("Memory usage %d MiB is back below the %s threshold, resuming %s verifications", usage>>20, class, class)
+		}
+	}
+}
+
+// exceeds reports whether usage is above the threshold of class
+func (m *memoryShedder) exceeds(usage int64, class string) bool {
+	percent, ok := m.thresholds[class]
+	return ok && usage*100 > m.limit*int64(percent)
+}
+
+// Shed returns an ErrCodeMemoryPressure error when a new verification of class must be rejected
+func (m *memoryShedder) Shed(class string) error {
+	if m == nil {
+		return nil
+	}
+	usage := m.usage.Load()
+	if !m.exceeds(usage, class) {
+		return nil
+	}
+
+	m.mu.Lock()
+	m.shedTotal[class]++
+	m.mu.Unlock()
+	return newVerificationError(ErrCodeMemoryPressure, "provider memory usage %d MiB is above %d%% of its %d MiB limit, %s verifications are shed",
+		usage>>20, m.thresholds[class], m.limit>>20, class)
+}
+
+// writeMemoryMetrics writes the memory gauges and shed counters in the Prometheus text format
+func (m *memoryShedder) writeMemoryMetrics(w io.Writer) {
+	if m == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP sbom_provider_memory_bytes Resident memory of the provider, as sampled for load shedding.\n")
+	fmt.Fprintf(w, "# TYPE sbom_provider_memory_bytes gauge\nsbom_provider_memory_bytes %d\n", m.usage.Load())
+	fmt.Fprintf(w, "# HELP sbom_provider_memory_limit_bytes Memory limit load shedding thresholds are relative to.\n")
+	fmt.Fprintf(w, "# TYPE sbom_provider_memory_limit_bytes gauge\nsbom_provider_memory_limit_bytes %d\n", m.limit)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	classes := make([]string, 0, len(m.thresholds))
+	for class := range m.thresholds {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	fmt.Fprintf(w, "# HELP sbom_provider_verifications_shed_total Verifications rejected under memory pressure, by request class.\n")
+	fmt.Fprintf(w, "# TYPE sbom_provider_verifications_shed_total counter\n")
+	for _, class := range classes {
+		fmt.Fprintf(w, "sbom_provider_verifications_shed_total{class=%q} %d\n", class, m.shedTotal[class])
+	}
+}
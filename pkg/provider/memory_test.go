@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseMemoryShedThresholds(t *testing.T) {
+	thresholds, err := parseMemoryShedThresholds([]string{" audit=95", "admission=99", ""})
+	if err != nil {
+		t.Fatalf("Failed to parse thresholds: %v", err)
+	}
+	if thresholds[RequestClassBatch] != 80 || thresholds[RequestClassAudit] != 95 || thresholds[RequestClassAdmission] != 99 {
+		t.Errorf("Expected batch=80, audit=95 and admission=99, got %v", thresholds)
+	}
+
+	for _, spec := range []string{"audit", "interactive=50", "audit=0", "audit=101", "audit=high"} {
+		if _, err := parseMemoryShedThresholds([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestMemoryShedderShedsByClass(t *testing.T) {
+	usage := int64(50 << 20)
+	shedder := &memoryShedder{
+		limit:      100 << 20,
+		thresholds: DefaultMemoryShedThresholds,
+		sample:     func() int64 { return usage },
+		shedding:   make(map[string]bool),
+		shedTotal:  make(map[string]int64),
+	}
+
+	shedder.check()
+	for _, class := range []string{RequestClassAdmission, RequestClassAudit, RequestClassBatch} {
+		if err := shedder.Shed(class); err != nil {
+			t.Errorf("Expected %s not to be shed at 50%%, got %v", class, err)
+		}
+	}
+
+	// Batch gives way first, admission is never shed by default
+	usage = 85 << 20
+	shedder.check()
+	err := shedder.Shed(RequestClassBatch)
+	var verr *VerificationError
+	if !errors.As(err, &verr) || verr.Code != ErrCodeMemoryPressure {
+		t.Errorf("Expected batch to be shed with %s at 85%%, got %v", ErrCodeMemoryPressure, err)
+	}
+	if err := shedder.Shed(RequestClassAudit); err != nil {
+		t.Errorf("Expected audit not to be shed at 85%%, got %v", err)
+	}
+
+	usage = 99 << 20
+	shedder.check()
+	if err := shedder.Shed(RequestClassAudit); err == nil {
+		t.Error("Expected audit to be shed at 99%")
+	}
+	if err := shedder.Shed(RequestClassAdmission); err != nil {
+		t.Errorf("Expected admission never to be shed, got %v", err)
+	}
+
+	var metrics bytes.Buffer
+	shedder.writeMemoryMetrics(&metrics)
+	for _, want := range []string{
+		"sbom_provider_memory_bytes 103809024",
+		`sbom_provider_verifications_shed_total{class="audit"} 1`,
+		`sbom_provider_verifications_shed_total{class="batch"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, metrics.String())
+		}
+	}
+}
+
+func TestVerifyScheduledShedsUnderMemoryPressure(t *testing.T) {
+	s := &Server{memory: &memoryShedder{
+		limit:      100,
+		thresholds: DefaultMemoryShedThresholds,
+		sample:     func() int64 { return 95 },
+		shedding:   make(map[string]bool),
+		shedTotal:  make(map[string]int64),
+	}}
+	s.memory.check()
+
+	item := s.verifyScheduled(context.Background(), RequestClassBatch, "ghcr.io/org/app@"+testDigest)
+	if !strings.HasPrefix(item.Error, ErrCodeMemoryPressure) {
+		t.Errorf("Expected the batch verification to be shed, got %q", item.Error)
+	}
+}
+
+func TestNilMemoryShedder(t *testing.T) {
+	var shedder *memoryShedder
+	if err := shedder.Shed(RequestClassBatch); err != nil {
+		t.Errorf("Expected a nil shedder never to shed, got %v", err)
+	}
+	var metrics bytes.Buffer
+	shedder.writeMemoryMetrics(&metrics)
+	if metrics.Len() != 0 {
+		t.Errorf("Expected no metrics from a nil shedder, got %s", metrics.String())
+	}
+}
+
+func TestProcessMemory(t *testing.T) {
+	if usage := processMemory(); usage <= 0 {
+		t.Errorf("Expected a positive memory usage, got %d", usage)
+	}
+}
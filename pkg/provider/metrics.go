@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Connection gauges, updated by the server ConnState hook and the registry transport dialer
+var (
+	inboundConnections  atomic.Int64
+	registryConnections atomic.Int64
+)
+
+// trackInboundConn is an http.Server ConnState hook counting open client connections
+func trackInboundConn(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		inboundConnections.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		inboundConnections.Add(-1)
+	}
+}
+
+// countedConn decrements the registry connection gauge once when closed
+type countedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+// Close closes the connection and updates the gauge
+func (c *countedConn) Close() error {
+	c.once.Do(func() { registryConnections.Add(-1) })
+	return c.Conn.Close()
+}
+
+// newRegistryTransport returns a transport shared by all registry calls, so connections are
+// pooled across requests and counted in the registry connection gauge
+func newRegistryTransport() http.RoundTripper {
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		registryConnections.Add(1)
+		return &countedConn{Conn: conn}, nil
+	}
+	return t
+}
+
+// openFDs returns the number of open file descriptors, or false where this cannot be measured
+func openFDs() (int, bool) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return len(entries), true
+}
+
+// handleMetrics serves process and connection gauges in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	gauge := func(name, help string, value int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+	}
+
+	gauge("sbom_provider_goroutines", "Number of goroutines.", int64(runtime.NumGoroutine()))
+	if fds, ok := openFDs(); ok {
+		gauge("sbom_provider_open_fds", "Number of open file descriptors.", int64(fds))
+	}
+	gauge("sbom_provider_inbound_connections", "Open client connections to the provider.", inboundConnections.Load())
+	gauge("sbom_provider_registry_connections", "Open connections to container registries.", registryConnections.Load())
+	gauge("sbom_provider_cache_entries", "Cached verification results, including expired ones not yet swept.", int64(s.cache.Len()))
+
+	if waiting := s.scheduler.Waiting(); waiting != nil {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", "sbom_provider_verifications_waiting",
+			"Verifications waiting for a slot, by request class.", "sbom_provider_verifications_waiting")
+		for _, c := range waiting {
+			fmt.Fprintf(w, "sbom_provider_verifications_waiting{class=%q} %d\n", c.class, c.count)
+		}
+	}
+
+	writeCompletenessMetrics(w)
+	writeAttestationCapMetrics(w)
+	writeResolveMetrics(w)
+	writeWarmupMetrics(w)
+	writeDSSEMetrics(w)
+	writeKeychainFallbackMetrics(w)
+	writeImageDenyMetrics(w)
+	s.expiry.writeExpiryMetrics(w)
+	s.exceptions.writeExceptionMetrics(w)
+	s.windows.writeWindowMetrics(w)
+	s.memory.writeMemoryMetrics(w)
+	s.verifier.writeTrustMetrics(w)
+	s.verifier.writeRekorMetrics(w)
+	s.writeUpstreamMetrics(w)
+	s.shadow.writeShadowMetrics(w)
+	s.refresher.writeCacheRefreshMetrics(w)
+	s.audit.writeAuditMetrics(w)
+	s.logs.writeLogSamplingMetrics(w)
+
+	suspected := int64(0)
+	if s.leaks.Suspected() {
+		suspected = 1
+	}
+	gauge("sbom_provider_leak_suspected", "Whether goroutines or file descriptors grew beyond the leak threshold.", suspected)
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	server := NewServer(ServerConfig{LeakCheckInterval: time.Minute}, &AttestationVerifier{})
+
+	w := httptest.NewRecorder()
+	server.handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := w.Body.String()
+	for _, metric := range []string{
+		"sbom_provider_goroutines ",
+		"sbom_provider_inbound_connections ",
+		"sbom_provider_registry_connections ",
+		"sbom_provider_cache_entries 0",
+		"sbom_provider_leak_suspected 0",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("Expected metric %q in output:\n%s", metric, body)
+		}
+	}
+}
+
+func TestRegistryTransportCountsConnections(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	transport := newRegistryTransport()
+	before := registryConnections.Load()
+
+	resp, err := (&http.Client{Transport: transport}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := registryConnections.Load() - before; got != 1 {
+		t.Errorf("Expected 1 open registry connection, got %d", got)
+	}
+
+	transport.(*http.Transport).CloseIdleConnections()
+	if got := registryConnections.Load() - before; got != 0 {
+		t.Errorf("Expected closed connection to be released, got %d", got)
+	}
+}
+
+func TestTrackInboundConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	before := inboundConnections.Load()
+	trackInboundConn(server, http.StateNew)
+	trackInboundConn(server, http.StateActive)
+	if got := inboundConnections.Load() - before; got != 1 {
+		t.Errorf("Expected 1 inbound connection, got %d", got)
+	}
+
+	trackInboundConn(server, http.StateClosed)
+	if got := inboundConnections.Load() - before; got != 0 {
+		t.Errorf("Expected connection to be released, got %d", got)
+	}
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// handleMutate pins image references to their digest for Gatekeeper mutations (Assign with
+// externalData), without verifying attestations. Keys are image fields as written in the
+// workload, and each value is the replacement for its key.
+func (s *Server) handleMutate(w http.ResponseWriter, r *http.Request) {
+	s.serveDigests(w, r, "Pinned", s.pinImageKey)
+}
+
+// pinImageKey returns image pinned to the digest its tag points to, keeping the reference as
+// written with the digest appended (app:v1 becomes app:v1@sha256:...), so workloads stay
+// readable and the kubelet pulls the digest. References already carrying a digest are returned
+// unchanged, which keeps the mutation idempotent. Mutations carry no pull secrets, so tags are
+// resolved with the provider's own credentials.
+func (s *Server) pinImageKey(ctx context.Context, image string) Item {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return Item{Key: image, Error: formatItemError("Invalid image reference", err)}
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return Item{Key: image, Value: image}
+	}
+
+	item := s.resolveDigestKey(ctx, image)
+	if item.Error != "" {
+		return item
+	}
+	digest, err := name.NewDigest(item.Value)
+	if err != nil {
+		return Item{Key: image, Error: formatItemError("Failed to resolve image digest", err)}
+	}
+	return Item{Key: image, Value: image + "@" + digest.DigestStr()}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestHandleMutate(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	digest := pushRandomImage(t, host+"/test/app:v1")
+	server := &Server{
+		verifier:       &AttestationVerifier{keychain: authn.DefaultKeychain},
+		timeout:        5 * time.Second,
+		digests:        newResultCache(),
+		digestCacheTTL: time.Minute,
+	}
+
+	pinned := "ghcr.io/org/app:v1@" + testDigest
+	keys := []string{host + "/test/app:v1", pinned, host + "/test/missing:v1", "not a reference"}
+	body, _ := json.Marshal(ProviderRequest{Request: Request{Keys: keys}})
+	w := httptest.NewRecorder()
+	server.handleMutate(w, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body)))
+
+	var response ProviderResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	items := response.Response.Items
+	if len(items) != len(keys) {
+		t.Fatalf("Expected %d items, got %v", len(keys), items)
+	}
+	if items[0].Key != keys[0] || items[0].Value != keys[0]+"@"+digest {
+		t.Errorf("Expected the tag pinned to %s, got %+v", digest, items[0])
+	}
+	if items[1].Value != pinned || items[1].Error != "" {
+		t.Errorf("Expected a digest reference unchanged, got %+v", items[1])
+	}
+	if !strings.Contains(items[2].Error, "Failed to resolve image digest") {
+		t.Errorf("Expected a resolution error for a missing tag, got %+v", items[2])
+	}
+	if items[3].Error == "" {
+		t.Errorf("Expected an error for an invalid reference, got %+v", items[3])
+	}
+	if response.Response.Idempotent {
+		t.Error("Expected pinned tags not to be marked idempotent")
+	}
+}
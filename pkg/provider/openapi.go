@@ -0,0 +1,294 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValueSchemaVersion identifies the default version of the JSON document carried in Item.Value
+const ValueSchemaVersion = ValueSchemaV1
+
+// OpenAPISpec returns the OpenAPI 3.0 document describing the provider API.
+// Schemas are generated from the Go types so the contract cannot drift from the implementation.
+func OpenAPISpec() map[string]interface{} {
+	schemaRef := func(name string) map[string]interface{} {
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	jsonContent := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaRef(name)},
+		}
+	}
+	textResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":                   "SBOM Gatekeeper Provider",
+			"description":             "Gatekeeper external data provider that verifies Sigstore attestations and returns normalized SBOM data. Item values are JSON documents encoded as strings; see the UnifiedSBOM (v1), UnifiedSBOMV2 (v2) and PendingValue schemas.",
+			"version":                 ValueSchemaVersion,
+			"x-value-schema-version":  ValueSchemaVersion,
+			"x-value-schema-versions": []string{ValueSchemaV1, ValueSchemaV2},
+		},
+		"paths": map[string]interface{}{
+			"/verify": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Verify attestations and extract SBOMs for the requested keys",
+					"operationId": "verify",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("ProviderRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Per-key results. Item.value holds a JSON-encoded UnifiedSBOM, UnifiedSBOMV2 (value schema v2) or PendingValue.",
+							"content":     jsonContent("ProviderResponse"),
+						},
+						"400": textResponse("Malformed request"),
+						"405": textResponse("Method not allowed"),
+					},
+				},
+			},
+			"/resolve": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Resolve the images of the requested keys to digest references, without verification",
+					"operationId": "resolve",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("ProviderRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Per-key results. Item.value holds the digest reference, e.g. ghcr.io/org/app@sha256:...",
+							"content":     jsonContent("ProviderResponse"),
+						},
+						"400": textResponse("Malformed request"),
+						"405": textResponse("Method not allowed"),
+					},
+				},
+			},
+			"/mutate": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Pin image references to their digest for Gatekeeper mutations, without verification",
+					"operationId": "mutate",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("ProviderRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Per-key results. Keys are image references; item.value holds the reference with its digest appended, e.g. ghcr.io/org/app:v1@sha256:...",
+							"content":     jsonContent("ProviderResponse"),
+						},
+						"400": textResponse("Malformed request"),
+						"405": textResponse("Method not allowed"),
+					},
+				},
+			},
+			"/sarif": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Verify images as batch traffic and report failed policy checks as a SARIF 2.1.0 log",
+					"operationId": "sarif",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("SARIFRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "SARIF log with one result per failed check",
+							"content": map[string]interface{}{
+								SARIFContentType: map[string]interface{}{},
+							},
+						},
+						"400": textResponse("Malformed request"),
+						"405": textResponse("Method not allowed"),
+					},
+				},
+			},
+			"/warmup": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Verify and cache every container image of Kubernetes manifests as batch traffic, ahead of admission",
+					"operationId": "warmup",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("WarmupRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Outcome per image",
+							"content":     jsonContent("WarmupResponse"),
+						},
+						"400": textResponse("Malformed request, invalid manifests or too many images"),
+						"405": textResponse("Method not allowed"),
+						"413": textResponse("Request too large"),
+					},
+				},
+			},
+			"/validate-key": map[string]interface{}{
+				"post": map[string]interface{}{
+					"summary":     "Validate the requested keys without verifying anything, for tools generating constraints",
+					"operationId": "validateKey",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("ProviderRequest"),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Per-key results. Item.value holds a JSON-encoded KeyValidation for valid keys; item.error lists every problem of the others.",
+							"content":     jsonContent("ProviderResponse"),
+						},
+						"400": textResponse("Malformed request"),
+						"405": textResponse("Method not allowed"),
+					},
+				},
+			},
+			"/health": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":     "Liveness check",
+					"operationId": "health",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Provider is healthy"},
+					},
+				},
+			},
+			"/readyz": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":     "Readiness check, failing until the trusted roots are loaded",
+					"operationId": "ready",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Provider is ready (trusted roots ready or degraded)"},
+						"503": map[string]interface{}{"description": "Trusted roots are still initializing"},
+					},
+				},
+			},
+			"/openapi.json": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":     "This document",
+					"operationId": "openapi",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "OpenAPI document"},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"ProviderRequest":  jsonSchemaFor(reflect.TypeOf(ProviderRequest{})),
+				"ProviderResponse": jsonSchemaFor(reflect.TypeOf(ProviderResponse{})),
+				"UnifiedSBOM":      jsonSchemaFor(reflect.TypeOf(UnifiedSBOM{})),
+				"UnifiedSBOMV2":    jsonSchemaFor(reflect.TypeOf(UnifiedSBOMV2{})),
+				"PendingValue":     jsonSchemaFor(reflect.TypeOf(PendingValue{})),
+				"SARIFRequest":     jsonSchemaFor(reflect.TypeOf(SARIFRequest{})),
+				"WarmupRequest":    jsonSchemaFor(reflect.TypeOf(WarmupRequest{})),
+				"WarmupResponse":   jsonSchemaFor(reflect.TypeOf(WarmupResponse{})),
+				"StructuredKey":    jsonSchemaFor(reflect.TypeOf(StructuredKey{})),
+				"KeyValidation":    jsonSchemaFor(reflect.TypeOf(KeyValidation{})),
+			},
+		},
+	}
+}
+
+// jsonSchemaFor builds a JSON schema for t from its encoding/json representation
+func jsonSchemaFor(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+				// Embedded fields are promoted unless t declares a field of the same name
+				embedded := jsonSchemaFor(field.Type)
+				for name, schema := range embedded["properties"].(map[string]interface{}) {
+					if properties[name] == nil {
+						properties[name] = schema
+					}
+				}
+				if names, ok := embedded["required"].([]string); ok {
+					for _, name := range names {
+						if !slices.Contains(required, name) {
+							required = append(required, name)
+						}
+					}
+				}
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			omitempty := false
+			if tag := field.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				parts := strings.Split(tag, ",")
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				for _, opt := range parts[1:] {
+					if opt == "omitempty" {
+						omitempty = true
+					}
+				}
+			}
+
+			// Fields of t shadow embedded fields of the same name
+			properties[name] = jsonSchemaFor(field.Type)
+			if !omitempty && !slices.Contains(required, name) {
+				required = append(required, name)
+			}
+		}
+
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// interface{} values accept any JSON
+		return map[string]interface{}{}
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpenAPISpec())
+}
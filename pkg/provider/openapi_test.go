@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOpenAPISpecSchemas(t *testing.T) {
+	spec := OpenAPISpec()
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("Failed to marshal spec: %v", err)
+	}
+
+	var decoded struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Type       string                            `json:"type"`
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+
+	if decoded.OpenAPI != "3.0.3" {
+		t.Errorf("Expected openapi '3.0.3', got '%s'", decoded.OpenAPI)
+	}
+
+	if _, ok := decoded.Paths["/verify"]["post"]; !ok {
+		t.Error("Expected POST /verify in spec")
+	}
+
+	response, ok := decoded.Components.Schemas["ProviderResponse"]
+	if !ok {
+		t.Fatal("Expected ProviderResponse schema")
+	}
+
+	items := response.Properties["response"]["properties"].(map[string]interface{})["items"].(map[string]interface{})
+	itemProps := items["items"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, field := range []string{"key", "value", "error"} {
+		if _, ok := itemProps[field]; !ok {
+			t.Errorf("Expected Item schema to contain '%s'", field)
+		}
+	}
+
+	sbom := decoded.Components.Schemas["UnifiedSBOM"]
+	if sbom.Properties["packages"]["type"] != "array" {
+		t.Errorf("Expected packages to be an array, got %v", sbom.Properties["packages"]["type"])
+	}
+
+	pending := decoded.Components.Schemas["PendingValue"]
+	if len(pending.Required) != 1 || pending.Required[0] != "status" {
+		t.Errorf("Expected PendingValue to require 'status', got %v", pending.Required)
+	}
+}
+
+func TestJSONSchemaForOmitEmpty(t *testing.T) {
+	schema := jsonSchemaFor(reflect.TypeOf(Item{}))
+
+	required, _ := schema["required"].([]string)
+	if len(required) != 1 || required[0] != "key" {
+		t.Errorf("Expected only 'key' to be required, got %v", required)
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	server := &Server{
+		port:    "8090",
+		timeout: 30 * time.Second,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	server.handleOpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var spec map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+}
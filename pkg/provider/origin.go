@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// parseOriginRepositories parses "image=origin" mappings of the repositories images are copied
+// to onto the repository they were built and attested in, in the forms of
+// parseAttestationRepositories
+func parseOriginRepositories(specs []string) ([]attestationRepository, error) {
+	return parseRepositoryMappings(specs, "origin repository")
+}
+
+// originRepositoryFor returns the repository images in repo were copied from, if it is mapped
+func (v *AttestationVerifier) originRepositoryFor(repo name.Repository) (name.Repository, bool) {
+	return mappedRepository(v.originRepos, repo)
+}
+
+// originReposPolicy renders the origin mappings for the verification policy hash
+func (v *AttestationVerifier) originReposPolicy() string {
+	return repositoryMappingsPolicy(v.originRepos)
+}
+
+// originAttestations verifies the attestations of the image ref was copied from: the same
+// digest in its mapped origin repository, where promote-by-copy workflows leave them. They are
+// looked up through the referrers API and the legacy tag, as the origin registry's source order
+// allows (the tag when it has neither), and are accepted because their subject is the digest of
+// ref, which cosign checks.
+func (v *AttestationVerifier) originAttestations(ctx context.Context, ref name.Reference, checkOpts *cosign.CheckOpts, keychain authn.Keychain) ([]verifiedAttestation, error) {
+	origin, ok := v.originRepositoryFor(ref.Context())
+	if !ok {
+		return nil, errSourceNotApplicable
+	}
+	digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, classifyRegistryAuthError(err, ref, keychain)
+	}
+	originRef := origin.Digest(digest.String())
+	tracef(ctx, "%s was copied from %s, looking up its attestations there", ref.Context(), origin)
+
+	var sources []string
+	for _, source := range v.attestationSourcesFor(origin.RegistryStr()) {
+		if source == AttestationSourceReferrers || source == AttestationSourceTag {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		sources = []string{AttestationSourceTag}
+	}
+
+	var errs []error
+	for _, source := range sources {
+		atts, err := v.fetchAttestations(ctx, source, originRef, checkOpts, keychain)
+		if err == nil && len(atts) > 0 {
+			return atts, nil
+		}
+		if err == nil {
+			err = errNoAttestations
+		}
+		tracef(ctx, "origin %s: %s failed: %v", origin, source, err)
+		errs = append(errs, &sourceError{source, err})
+	}
+	return nil, joinSourceErrors(errs)
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestOriginAttestations(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Rekor key: %v", err)
+	}
+	rekorPEM, err := cryptoutils.MarshalPublicKeyToPEM(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	rekorPub := filepath.Join(t.TempDir(), "rekor.pub")
+	if err := os.WriteFile(rekorPub, rekorPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write Rekor key: %v", err)
+	}
+	trustedRoot, err := loadCustomTrustedRoot(&CustomTrustedRoot{RekorPublicKeys: []string{rekorPub}, RekorURL: "https://rekor.invalid"})
+	if err != nil {
+		t.Fatalf("Failed to load trusted root: %v", err)
+	}
+
+	// The image is attested in staging, then copied to prod without its attestations
+	attested := pushSignedAttestation(t, host+"/staging/app", signer, func(digest string) *bundle.RekorBundle {
+		return rekorBundle(t, rekorKey, signingKey, digest)
+	})
+	img, err := remote.Image(attested)
+	if err != nil {
+		t.Fatalf("Failed to read image: %v", err)
+	}
+	copied, err := name.ParseReference(host + "/prod/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(copied, img); err != nil {
+		t.Fatalf("Failed to copy image: %v", err)
+	}
+	// An image only pushed to prod has the same name but another digest
+	other, err := name.ParseReference(host + "/prod/app:v2")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	otherImg, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := remote.Write(other, otherImg); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+
+	originRepos, err := parseOriginRepositories([]string{host + "/prod/*=" + host + "/staging/app"})
+	if err != nil {
+		t.Fatalf("Failed to parse mappings: %v", err)
+	}
+	verifier := &AttestationVerifier{
+		trustedRoots:       []namedTrustedRoot{{name: TrustedRootCustom, material: trustedRoot}},
+		offlineTlog:        true,
+		attestationSources: []string{AttestationSourceTag, AttestationSourceOrigin},
+		originRepos:        originRepos,
+	}
+	fetch := func(ref name.Reference) ([]verifiedAttestation, error) {
+		checkOpts := &cosign.CheckOpts{
+			ClaimVerifier: cosign.IntotoSubjectClaimVerifier,
+			SigVerifier:   signer,
+			IgnoreSCT:     true,
+			Offline:       true,
+		}
+		return verifier.fetchAttestations(context.Background(), AttestationSourceOrigin, ref, checkOpts, authn.DefaultKeychain)
+	}
+
+	atts, err := fetch(copied)
+	if err != nil || len(atts) != 1 {
+		t.Fatalf("Expected the attestation of the origin to verify, got %v and %v", atts, err)
+	}
+	if predicateType, _, err := verifier.parseStatement(atts[0].payload); err != nil || predicateType != "https://spdx.dev/Document" {
+		t.Errorf("Expected the SPDX statement, got %q and %v", predicateType, err)
+	}
+
+	if _, err := fetch(other); err == nil {
+		t.Error("Expected an image the origin does not hold to have no attestations")
+	}
+	unmapped, err := name.ParseReference(host + "/dev/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if _, err := fetch(unmapped); !errors.Is(err, errSourceNotApplicable) {
+		t.Errorf("Expected an unmapped image to be skipped, got %v", err)
+	}
+
+	if got := verifier.originReposPolicy(); got != host+"/prod/*="+host+"/staging/app" {
+		t.Errorf("Expected the mapping in the policy, got %s", got)
+	}
+	if _, err := parseOriginRepositories([]string{"ghcr.io/org/app"}); err == nil || !strings.Contains(err.Error(), "origin repository") {
+		t.Errorf("Expected an invalid origin mapping to fail, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	"github.com/sigstore/cosign/v2/pkg/oci/empty"
+	ocimutate "github.com/sigstore/cosign/v2/pkg/oci/mutate"
+)
+
+// Bounds on the partial failure reasons reported alongside an SBOM
+const (
+	maxPartialFailureReasons      = 5
+	maxPartialFailureReasonLength = 300
+)
+
+// PartialFailures are the attestation candidates of the source an SBOM was verified from that
+// failed verification. A forged or tampered attestation next to a genuine one is otherwise
+// invisible, as the genuine one is enough to admit the image.
+type PartialFailures struct {
+	Count   int      `json:"count"`          // Candidates that failed verification
+	Reasons []string `json:"reasons"`        // Distinct failures, prefixed with their error code when they have one
+	More    int      `json:"more,omitempty"` // Distinct failures left out of reasons
+}
+
+// partialFailures collects the failed candidates of a single attestation source
+type partialFailures struct {
+	mu      sync.Mutex
+	count   int
+	reasons []string
+	seen    map[string]bool
+	more    int
+}
+
+type partialFailuresContextKey struct{}
+
+// withPartialFailures returns a context collecting the candidates failing verification
+func withPartialFailures(ctx context.Context) (context.Context, *partialFailures) {
+	failures := &partialFailures{seen: make(map[string]bool)}
+	return context.WithValue(ctx, partialFailuresContextKey{}, failures), failures
+}
+
+// recordPartialFailures records n candidates failing verification with err. It is a no-op
+// outside a context from withPartialFailures.
+func recordPartialFailures(ctx context.Context, n int, err error) {
+	failures, _ := ctx.Value(partialFailuresContextKey{}).(*partialFailures)
+	if failures == nil || n == 0 || err == nil {
+		return
+	}
+	tracef(ctx, "%d attestation candidates failed verification: %v", n, err)
+
+	err = classifyCertExtensionError(classifyTlogError(classifyIdentityError(err)))
+	reason := err.Error()
+	if code := ErrorCode(err); code != "" {
+		reason = code + ": " + reason
+	}
+	reason = sanitizeItemError(reason)
+	if len(reason) > maxPartialFailureReasonLength {
+		cut := maxPartialFailureReasonLength
+		for cut > 0 && !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut] + "..."
+	}
+
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	failures.count += n
+	if failures.seen[reason] {
+		return
+	}
+	failures.seen[reason] = true
+	if len(failures.reasons) < maxPartialFailureReasons {
+		failures.reasons = append(failures.reasons, reason)
+	} else {
+		failures.more++
+	}
+}
+
+// summary returns the failures collected, nil when there are none
+func (p *partialFailures) summary() *PartialFailures {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.count == 0 {
+		return nil
+	}
+	return &PartialFailures{Count: p.count, Reasons: append([]string(nil), p.reasons...), More: p.more}
+}
+
+// recordFailedSignatureAttestations records the attestations of all cosign dropped from
+// verified. cosign only reports why attestations failed when none verified, so each failed
+// one is verified again on its own for its reason.
+func recordFailedSignatureAttestations(ctx context.Context, all oci.Signatures, verified []oci.Signature, h v1.Hash, checkOpts *cosign.CheckOpts) {
+	sigs, err := all.Get()
+	if err != nil || len(sigs) <= len(verified) {
+		return
+	}
+	checked := make(map[string]bool, len(verified))
+	for _, sig := range verified {
+		checked[signatureKey(sig)] = true
+	}
+
+	for _, sig := range sigs {
+		if checked[signatureKey(sig)] {
+			continue
+		}
+		single, err := ocimutate.AppendSignatures(empty.Signatures(), false, sig)
+		if err == nil {
+			_, _, err = cosign.VerifyImageAttestation(ctx, single, h, checkOpts)
+		}
+		if err == nil {
+			err = fmt.Errorf("attestation was not verified")
+		}
+		recordPartialFailures(ctx, 1, err)
+	}
+}
+
+// signatureKey identifies an attestation by its signature and payload
+func signatureKey(sig oci.Signature) string {
+	b64sig, _ := sig.Base64Signature()
+	payload, _ := sig.Payload()
+	return b64sig + "\x00" + string(payload)
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	ocimutate "github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+)
+
+func TestPartialFailures(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	newKey := func() (*ecdsa.PrivateKey, signature.SignerVerifier) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		signer, err := signature.LoadECDSASignerVerifier(key, crypto.SHA256)
+		if err != nil {
+			t.Fatalf("Failed to load signer: %v", err)
+		}
+		return key, signer
+	}
+	signingKey, signer := newKey()
+	rekorKey, _ := newKey()
+	_, forger := newKey()
+
+	rekorPEM, err := cryptoutils.MarshalPublicKeyToPEM(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	rekorPub := filepath.Join(t.TempDir(), "rekor.pub")
+	if err := os.WriteFile(rekorPub, rekorPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write Rekor key: %v", err)
+	}
+	trustedRoot, err := loadCustomTrustedRoot(&CustomTrustedRoot{RekorPublicKeys: []string{rekorPub}, RekorURL: "https://rekor.invalid"})
+	if err != nil {
+		t.Fatalf("Failed to load trusted root: %v", err)
+	}
+
+	// A second attestation next to the genuine one, signed by another key
+	ref := pushSignedAttestation(t, host+"/test/app", signer, func(digest string) *bundle.RekorBundle {
+		return rekorBundle(t, rekorKey, signingKey, digest)
+	})
+	statement, err := json.Marshal(map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://spdx.dev/Document",
+		"subject":       []map[string]interface{}{{"name": ref.Context().String(), "digest": map[string]string{"sha256": strings.TrimPrefix(ref.DigestStr(), "sha256:")}}},
+		"predicate":     map[string]interface{}{"spdxVersion": "SPDX-2.3", "name": "forged"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal statement: %v", err)
+	}
+	envelope, err := dsse.WrapSigner(forger, "application/vnd.in-toto+json").SignMessage(bytes.NewReader(statement))
+	if err != nil {
+		t.Fatalf("Failed to sign attestation: %v", err)
+	}
+	att, err := static.NewAttestation(envelope)
+	if err != nil {
+		t.Fatalf("Failed to create attestation: %v", err)
+	}
+	se, err := ociremote.SignedEntity(ref)
+	if err != nil {
+		t.Fatalf("Failed to read image: %v", err)
+	}
+	if se, err = ocimutate.AttachAttestationToEntity(se, att); err != nil {
+		t.Fatalf("Failed to attach attestation: %v", err)
+	}
+	if err := ociremote.WriteAttestations(ref.Context(), se); err != nil {
+		t.Fatalf("Failed to push attestation: %v", err)
+	}
+
+	verifier := &AttestationVerifier{
+		trustedRoots: []namedTrustedRoot{{name: TrustedRootCustom, material: trustedRoot}},
+		offlineTlog:  true,
+	}
+	checkOpts := &cosign.CheckOpts{
+		ClaimVerifier: cosign.IntotoSubjectClaimVerifier,
+		SigVerifier:   signer,
+		IgnoreSCT:     true,
+		Offline:       true,
+	}
+	ctx, failures := withPartialFailures(context.Background())
+	atts, err := verifier.registryAttestations(ctx, AttestationSourceTag, ref, checkOpts)
+	if err != nil || len(atts) != 1 {
+		t.Fatalf("Expected the genuine attestation to verify, got %d attestations, %v", len(atts), err)
+	}
+	summary := failures.summary()
+	if summary == nil || summary.Count != 1 || len(summary.Reasons) != 1 {
+		t.Fatalf("Expected the forged attestation reported, got %+v", summary)
+	}
+
+	// Reasons are deduplicated and bounded, the count is not
+	ctx, failures = withPartialFailures(context.Background())
+	for i := 0; i < maxPartialFailureReasons+2; i++ {
+		recordPartialFailures(ctx, 1, newVerificationError(ErrCodeDSSESignature, "attestation %d: invalid signature", i))
+	}
+	recordPartialFailures(ctx, 2, newVerificationError(ErrCodeDSSESignature, "attestation 0: invalid signature"))
+	recordPartialFailures(context.Background(), 1, errors.New("not collected"))
+	summary = failures.summary()
+	if summary.Count != maxPartialFailureReasons+4 || len(summary.Reasons) != maxPartialFailureReasons || summary.More != 2 {
+		t.Errorf("Expected %d failures with %d reasons and 2 more, got %+v", maxPartialFailureReasons+4, maxPartialFailureReasons, summary)
+	}
+	if want := fmt.Sprintf("%s: attestation 0: invalid signature", ErrCodeDSSESignature); summary.Reasons[0] != want {
+		t.Errorf("Expected reasons prefixed with their code, got %q", summary.Reasons[0])
+	}
+
+	if _, failures = withPartialFailures(context.Background()); failures.summary() != nil {
+		t.Error("Expected no summary without failures")
+	}
+}
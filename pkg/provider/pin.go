@@ -0,0 +1,423 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// pinConfigMapPrefix shares the pins of all replicas through a ConfigMap in the provider
+// namespace, e.g. "configmap:sbom-provider-pins"
+const pinConfigMapPrefix = "configmap:"
+
+// pinConfigMapKey is the ConfigMap binaryData key holding the gzipped pins
+const pinConfigMapKey = "pins.json.gz"
+
+// DefaultPinSyncInterval is how often replicas reload the shared pins
+const DefaultPinSyncInterval = 5 * time.Second
+
+// pinStoreTimeout bounds reading or writing the shared pins
+const pinStoreTimeout = 5 * time.Second
+
+// PinRequest pins the verification results of an image digest for a fixed window
+type PinRequest struct {
+	Digest   string `json:"digest"`   // Image manifest digest, e.g. "sha256:abc..."
+	Duration string `json:"duration"` // Pin window, e.g. "30m"
+}
+
+// Pin is an active result pin
+type Pin struct {
+	Digest    string    `json:"digest"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Keys      int       `json:"keys"` // Number of provider keys whose result is pinned so far
+}
+
+// pinEntry holds the results frozen for a pinned digest, by provider key
+type pinEntry struct {
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Items     map[string]Item `json:"items,omitempty"`
+}
+
+// expired reports whether the pin window is over
+func (e *pinEntry) expired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+// sharedPins stores the pins of all replicas, so that every replica serves the result
+// recorded first for a key
+type sharedPins interface {
+	// Load returns the stored pins, or none if none were stored yet
+	Load(ctx context.Context) (map[string]*pinEntry, error)
+	// Update applies update to the stored pins and saves them, retrying on concurrent writes,
+	// and returns the pins saved
+	Update(ctx context.Context, update func(pins map[string]*pinEntry)) (map[string]*pinEntry, error)
+}
+
+// pinStore freezes successful verification results of pinned digests until the pin expires,
+// so retries during a rollout see the same decision even if trust material changes meanwhile.
+// With a shared store the pins and their results are the same on every replica, up to the
+// sync interval after a pin is added or removed. A nil store never pins anything.
+type pinStore struct {
+	mu          sync.RWMutex
+	pins        map[string]*pinEntry
+	maxDuration time.Duration
+	shared      sharedPins // nil keeps the pins in this replica only
+}
+
+// newPinStore creates an empty pin store accepting pins up to maxDuration
+func newPinStore(maxDuration time.Duration) *pinStore {
+	return &pinStore{
+		pins:        make(map[string]*pinEntry),
+		maxDuration: maxDuration,
+	}
+}
+
+// Pin pins digest for d, replacing the window of an existing pin but keeping its results
+func (p *pinStore) Pin(ctx context.Context, digest string, d time.Duration) error {
+	if _, err := v1.NewHash(digest); err != nil {
+		return fmt.Errorf("invalid digest: %w", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if d > p.maxDuration {
+		return fmt.Errorf("duration %v exceeds the maximum of %v", d, p.maxDuration)
+	}
+
+	return p.update(ctx, func(pins map[string]*pinEntry) {
+		entry, ok := pins[digest]
+		if !ok || entry.expired() {
+			entry = &pinEntry{}
+			pins[digest] = entry
+		}
+		entry.ExpiresAt = time.Now().Add(d)
+	})
+}
+
+// Unpin removes the pin for digest
+func (p *pinStore) Unpin(ctx context.Context, digest string) error {
+	return p.update(ctx, func(pins map[string]*pinEntry) {
+		delete(pins, digest)
+	})
+}
+
+// update applies fn to the pins, in the shared store first when there is one
+func (p *pinStore) update(ctx context.Context, fn func(pins map[string]*pinEntry)) error {
+	if p.shared == nil {
+		p.mu.Lock()
+		fn(p.pins)
+		p.mu.Unlock()
+		return nil
+	}
+
+	pins, err := p.shared.Update(ctx, func(pins map[string]*pinEntry) {
+		for digest, entry := range pins {
+			if entry.expired() {
+				delete(pins, digest)
+			}
+		}
+		fn(pins)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the shared pins: %w", err)
+	}
+	p.replace(pins)
+	return nil
+}
+
+// replace makes pins the pins of this replica
+func (p *pinStore) replace(pins map[string]*pinEntry) {
+	if pins == nil {
+		pins = make(map[string]*pinEntry)
+	}
+	p.mu.Lock()
+	p.pins = pins
+	p.mu.Unlock()
+}
+
+// Sync reloads the pins from the shared store
+func (p *pinStore) Sync(ctx context.Context) error {
+	pins, err := p.shared.Load(ctx)
+	if err != nil {
+		return err
+	}
+	p.replace(pins)
+	return nil
+}
+
+// Run reloads the shared pins every interval until ctx is done
+func (p *pinStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		syncCtx, cancel := context.WithTimeout(ctx, pinStoreTimeout)
+		if err := p.Sync(syncCtx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: failed to load the shared pins: %v", err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pins returns the active pins sorted by digest, evicting expired ones
+func (p *pinStore) Pins() []Pin {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pins := make([]Pin, 0, len(p.pins))
+	for digest, entry := range p.pins {
+		if entry.expired() {
+			delete(p.pins, digest)
+			continue
+		}
+		pins = append(pins, Pin{Digest: digest, ExpiresAt: entry.ExpiresAt, Keys: len(entry.Items)})
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].Digest < pins[j].Digest })
+	return pins
+}
+
+// Get returns the pinned item for key, if its digest is pinned and a result was recorded
+func (p *pinStore) Get(key string) (Item, bool) {
+	if p == nil {
+		return Item{}, false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	entry, ok := p.pins[keyDigest(key)]
+	if !ok || entry.expired() {
+		return Item{}, false
+	}
+	item, ok := entry.Items[key]
+	return item, ok
+}
+
+// Record freezes item for key if its digest is pinned and no result was recorded yet, and
+// returns the result pinned for key: the one recorded first, possibly by another replica.
+// Only successful results are pinned so retries can still recover from transient failures.
+func (p *pinStore) Record(key string, item Item) Item {
+	if p == nil || item.Error != "" || item.Value == pendingValue {
+		return item
+	}
+
+	digest := keyDigest(key)
+	record := func(pins map[string]*pinEntry) {
+		entry, ok := pins[digest]
+		if !ok || entry.expired() {
+			return
+		}
+		if _, ok := entry.Items[key]; !ok {
+			if entry.Items == nil {
+				entry.Items = make(map[string]Item)
+			}
+			entry.Items[key] = item
+		}
+	}
+
+	p.mu.Lock()
+	entry, ok := p.pins[digest]
+	if !ok || entry.expired() {
+		p.mu.Unlock()
+		return item
+	}
+	if recorded, ok := entry.Items[key]; ok {
+		p.mu.Unlock()
+		return recorded
+	}
+	if p.shared == nil {
+		record(p.pins)
+		p.mu.Unlock()
+		return item
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pinStoreTimeout)
+	defer cancel()
+	if err := p.update(ctx, record); err != nil {
+		log.Printf("Warning: result of %s is pinned by this replica only: %v", key, err)
+		p.mu.Lock()
+		record(p.pins)
+		p.mu.Unlock()
+	}
+	if recorded, ok := p.Get(key); ok {
+		return recorded
+	}
+	return item
+}
+
+// keyDigest returns the digest of the image in a provider key, or "" for tag references
+func keyDigest(key string) string {
+	image := strings.SplitN(key, "|", 2)[0]
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// handlePins manages result pins.
+// GET lists the active pins, POST pins a digest, DELETE ?digest=... removes a pin, all with
+// "Authorization: Bearer <ADMIN_TOKEN>".
+func (s *Server) handlePins(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizedAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sbom-provider"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req PinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid pin format", http.StatusBadRequest)
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid duration: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.pins.Pin(r.Context(), req.Digest, d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Pinned verification results for %s for %v", req.Digest, d)
+	case http.MethodDelete:
+		digest := r.URL.Query().Get("digest")
+		if digest == "" {
+			http.Error(w, "digest is required", http.StatusBadRequest)
+			return
+		}
+		if err := s.pins.Unpin(r.Context(), digest); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Unpinned verification results for %s", digest)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.pins.Pins())
+}
+
+// newSharedPins creates the shared pin store described by spec
+func newSharedPins(spec string, kubeClient kubernetes.Interface, namespace string) (sharedPins, error) {
+	name, ok := strings.CutPrefix(spec, pinConfigMapPrefix)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("unknown pin store %q (expected %s<name>)", spec, pinConfigMapPrefix)
+	}
+	if kubeClient == nil {
+		return nil, fmt.Errorf("configmap pins require a kubernetes client")
+	}
+	return &configMapPins{client: kubeClient, namespace: namespace, name: name}, nil
+}
+
+// configMapPins keeps the gzipped pins in a ConfigMap shared by all replicas
+type configMapPins struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// Load reads the pins from the ConfigMap
+func (c *configMapPins) Load(ctx context.Context) (map[string]*pinEntry, error) {
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(cm)
+}
+
+// Update applies update to the pins in the ConfigMap. Writes are conditional on the version
+// read, so concurrent updates from other replicas are retried on top of each other.
+func (c *configMapPins) Update(ctx context.Context, update func(pins map[string]*pinEntry)) (map[string]*pinEntry, error) {
+	configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+	var saved map[string]*pinEntry
+	conflict := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	err := retry.OnError(retry.DefaultRetry, conflict, func() error {
+		cm, err := configMaps.Get(ctx, c.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		pins := map[string]*pinEntry{}
+		if cm != nil {
+			if pins, err = c.decode(cm); err != nil {
+				return err
+			}
+		}
+		update(pins)
+		data, err := gzipJSON(pins)
+		if err != nil {
+			return err
+		}
+
+		if cm == nil {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: c.name, Namespace: c.namespace},
+				BinaryData: map[string][]byte{pinConfigMapKey: data},
+			}, metav1.CreateOptions{})
+		} else {
+			if cm.BinaryData == nil {
+				cm.BinaryData = map[string][]byte{}
+			}
+			cm.BinaryData[pinConfigMapKey] = data
+			_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		saved = pins
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// decode returns the pins held by cm
+func (c *configMapPins) decode(cm *corev1.ConfigMap) (map[string]*pinEntry, error) {
+	pins := map[string]*pinEntry{}
+	data, ok := cm.BinaryData[pinConfigMapKey]
+	if !ok {
+		return pins, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pins in configmap %s: %w", c.name, err)
+	}
+	defer zr.Close()
+	if err := json.NewDecoder(zr).Decode(&pins); err != nil {
+		return nil, fmt.Errorf("invalid pins in configmap %s: %w", c.name, err)
+	}
+	return pins, nil
+}
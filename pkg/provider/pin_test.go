@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPinStoreFreezesFirstSuccess(t *testing.T) {
+	pins := newPinStore(time.Hour)
+	if err := pins.Pin(context.Background(), testDigest, time.Minute); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+
+	key := "ghcr.io/myorg/app@" + testDigest + "|[]||"
+	pins.Record(key, Item{Key: key, Error: "transient failure"})
+	if _, ok := pins.Get(key); ok {
+		t.Error("Expected failures not to be pinned")
+	}
+
+	pins.Record(key, Item{Key: key, Value: "first"})
+	pins.Record(key, Item{Key: key, Value: "second"})
+
+	item, ok := pins.Get(key)
+	if !ok {
+		t.Fatal("Expected pinned item")
+	}
+	if item.Value != "first" {
+		t.Errorf("Expected first result to stay pinned, got '%s'", item.Value)
+	}
+}
+
+func TestPinStoreIgnoresUnpinnedAndTagKeys(t *testing.T) {
+	pins := newPinStore(time.Hour)
+	if err := pins.Pin(context.Background(), testDigest, time.Minute); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+
+	for _, key := range []string{"ghcr.io/myorg/app:v1|[]||", "ghcr.io/myorg/app@sha256:ffff|[]||"} {
+		pins.Record(key, Item{Key: key, Value: "sbom"})
+		if _, ok := pins.Get(key); ok {
+			t.Errorf("Expected no pinned result for %s", key)
+		}
+	}
+}
+
+func TestPinStoreExpiry(t *testing.T) {
+	pins := newPinStore(time.Hour)
+	if err := pins.Pin(context.Background(), testDigest, 10*time.Millisecond); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+
+	key := "ghcr.io/myorg/app@" + testDigest
+	pins.Record(key, Item{Key: key, Value: "sbom"})
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := pins.Get(key); ok {
+		t.Error("Expected pin to expire")
+	}
+	if len(pins.Pins()) != 0 {
+		t.Error("Expected expired pin to be evicted")
+	}
+}
+
+func TestPinStoreValidation(t *testing.T) {
+	pins := newPinStore(time.Hour)
+
+	if err := pins.Pin(context.Background(), "latest", time.Minute); err == nil {
+		t.Error("Expected error for invalid digest")
+	}
+	if err := pins.Pin(context.Background(), testDigest, 0); err == nil {
+		t.Error("Expected error for non-positive duration")
+	}
+	if err := pins.Pin(context.Background(), testDigest, 2*time.Hour); err == nil {
+		t.Error("Expected error for duration above the maximum")
+	}
+}
+
+func TestResolveItemUsesPin(t *testing.T) {
+	server := NewServer(ServerConfig{Timeout: time.Second, CacheTTL: time.Millisecond, MaxPinDuration: time.Hour}, &AttestationVerifier{})
+	if err := server.pins.Pin(context.Background(), testDigest, time.Minute); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+
+	key := "ghcr.io/myorg/app@" + testDigest + "|[]||"
+	server.cache.Set(server.cacheKey(key), Item{Key: key, Value: "sbom"}, time.Millisecond)
+	if item := server.resolveItem(key, RequestClassAdmission); item.Value != "sbom" {
+		t.Fatalf("Expected cached item, got %+v", item)
+	}
+
+	// The cache entry expires but the pin keeps serving the recorded result
+	time.Sleep(5 * time.Millisecond)
+	if item := server.resolveItem(key, RequestClassAdmission); item.Value != "sbom" {
+		t.Errorf("Expected pinned item after cache expiry, got %+v", item)
+	}
+}
+
+func TestSharedPins(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	newReplica := func() *pinStore {
+		pins := newPinStore(time.Hour)
+		shared, err := newSharedPins("configmap:sbom-provider-pins", client, "gatekeeper-system")
+		if err != nil {
+			t.Fatalf("Failed to create shared pins: %v", err)
+		}
+		pins.shared = shared
+		return pins
+	}
+	a, b := newReplica(), newReplica()
+	ctx := context.Background()
+
+	if err := a.Pin(ctx, testDigest, time.Minute); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+	if err := b.Sync(ctx); err != nil || len(b.Pins()) != 1 {
+		t.Fatalf("Expected the pin on the other replica, got %+v, %v", b.Pins(), err)
+	}
+
+	// The result recorded first holds on every replica, even one that verified before seeing it
+	key := "ghcr.io/myorg/app@" + testDigest + "|[]||"
+	if item := a.Record(key, Item{Key: key, Value: "first"}); item.Value != "first" {
+		t.Errorf("Expected the first result recorded, got '%s'", item.Value)
+	}
+	if item := b.Record(key, Item{Key: key, Value: "second"}); item.Value != "first" {
+		t.Errorf("Expected the other replica to adopt the first result, got '%s'", item.Value)
+	}
+	if item, ok := b.Get(key); !ok || item.Value != "first" {
+		t.Errorf("Expected the first result pinned on the other replica, got %+v", item)
+	}
+
+	if err := b.Unpin(ctx, testDigest); err != nil {
+		t.Fatalf("Failed to unpin: %v", err)
+	}
+	if err := a.Sync(ctx); err != nil || len(a.Pins()) != 0 {
+		t.Errorf("Expected the pin removed on every replica, got %+v, %v", a.Pins(), err)
+	}
+
+	if _, err := newSharedPins("file:/tmp/pins", client, "gatekeeper-system"); err == nil {
+		t.Error("Expected an error for a pin store other than a ConfigMap")
+	}
+}
+
+func TestHandlePins(t *testing.T) {
+	server := NewServer(ServerConfig{MaxPinDuration: time.Hour, AdminToken: "secret"}, &AttestationVerifier{})
+
+	body, _ := json.Marshal(PinRequest{Digest: testDigest, Duration: "30m"})
+	w := httptest.NewRecorder()
+	server.handlePins(w, httptest.NewRequest(http.MethodPost, "/pins", bytes.NewReader(body)))
+	if w.Code != http.StatusUnauthorized || len(server.pins.Pins()) != 0 {
+		t.Fatalf("Expected status 401 without the admin token, got %d", w.Code)
+	}
+
+	request := func(method, target string, body []byte) *http.Request {
+		r := httptest.NewRequest(method, target, bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+	w = httptest.NewRecorder()
+	server.handlePins(w, request(http.MethodPost, "/pins", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var pins []Pin
+	if err := json.NewDecoder(w.Body).Decode(&pins); err != nil {
+		t.Fatalf("Failed to decode pins: %v", err)
+	}
+	if len(pins) != 1 || pins[0].Digest != testDigest {
+		t.Errorf("Expected pin for %s, got %+v", testDigest, pins)
+	}
+
+	w = httptest.NewRecorder()
+	server.handlePins(w, request(http.MethodDelete, "/pins?digest="+testDigest, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if len(server.pins.Pins()) != 0 {
+		t.Error("Expected pin to be removed")
+	}
+
+	w = httptest.NewRecorder()
+	server.handlePins(w, request(http.MethodPost, "/pins", []byte(`{"digest":"`+testDigest+`","duration":"2h"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for duration above the maximum, got %d", w.Code)
+	}
+}
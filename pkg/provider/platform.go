@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// parsePlatform parses an "os/arch[/variant]" platform, e.g. "linux/arm64" or "linux/arm/v7".
+// An empty platform selects none.
+func parsePlatform(s string) (*v1.Platform, error) {
+	if s == "" {
+		return nil, nil
+	}
+	platform, err := v1.ParsePlatform(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid platform %q: %w", s, err)
+	}
+	if platform.OS == "" || platform.Architecture == "" {
+		return nil, fmt.Errorf("invalid platform %q: expected os/arch[/variant]", s)
+	}
+	return platform, nil
+}
+
+// selectedPlatform returns the platform the verification of ctx selects from image indexes:
+// the key's, else the provider's default, nil for none
+func (v *AttestationVerifier) selectedPlatform(ctx context.Context) *v1.Platform {
+	if p := platform(ctx); p != "" {
+		if platform, err := parsePlatform(p); err == nil {
+			return platform
+		}
+	}
+	return v.platform
+}
+
+// platformManifest returns the manifest of ref for the selected platform, so attestations
+// attached per platform are verified. References to a single image, and all references when no
+// platform is selected, are returned as is.
+func (v *AttestationVerifier) platformManifest(ctx context.Context, ref name.Reference, keychain authn.Keychain) (name.Reference, *v1.Platform, error) {
+	selected := v.selectedPlatform(ctx)
+	if selected == nil {
+		return ref, nil, nil
+	}
+
+	desc, err := remote.Get(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch manifest for platform selection: %w", classifyRegistryAuthError(err, ref, keychain))
+	}
+	if !desc.MediaType.IsIndex() {
+		tracef(ctx, "%s is not an image index, platform %s not applied", ref, selected)
+		return ref, nil, nil
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read image index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read image index: %w", err)
+	}
+
+	var available []string
+	for _, m := range manifest.Manifests {
+		// Attestation manifests of buildx are listed with an unknown platform
+		if m.Platform == nil || m.Platform.OS == "unknown" {
+			continue
+		}
+		if m.Platform.Satisfies(*selected) {
+			child := ref.Context().Digest(m.Digest.String())
+			tracef(ctx, "selected %s manifest %s from image index", m.Platform, child.DigestStr())
+			return child, m.Platform, nil
+		}
+		available = append(available, m.Platform.String())
+	}
+	return nil, nil, newVerificationError(ErrCodePlatform, "image index %s has no manifest for platform %s (available: %s)", ref, selected, strings.Join(available, ", "))
+}
+
+// platformPolicy describes the platform selected under opts for the policy hash
+func (v *AttestationVerifier) platformPolicy(opts keyOptions) string {
+	if opts.platform != "" {
+		return opts.platform
+	}
+	if v.platform != nil {
+		return v.platform.String()
+	}
+	return ""
+}
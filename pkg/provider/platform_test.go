@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestParsePlatform(t *testing.T) {
+	for s, want := range map[string]string{"linux/amd64": "linux/amd64", "linux/arm/v7": "linux/arm/v7"} {
+		p, err := parsePlatform(s)
+		if err != nil || p.String() != want {
+			t.Errorf("Expected %s to parse, got %v, %v", s, p, err)
+		}
+	}
+	if p, err := parsePlatform(""); p != nil || err != nil {
+		t.Errorf("Expected no platform, got %v, %v", p, err)
+	}
+	for _, s := range []string{"linux", "/amd64", "linux/"} {
+		if _, err := parsePlatform(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestPlatformManifest(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	amd64, _ := random.Image(256, 1)
+	arm64, _ := random.Image(256, 1)
+	attestation, _ := random.Image(256, 1)
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: attestation, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}}},
+		mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}}},
+	)
+	indexRef, _ := name.ParseReference(host + "/test/app:multi")
+	if err := remote.WriteIndex(indexRef, index); err != nil {
+		t.Fatalf("Failed to push index: %v", err)
+	}
+	imageRef, _ := name.ParseReference(host + "/test/app:single")
+	if err := remote.Write(imageRef, amd64); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	arm64Digest, _ := arm64.Digest()
+
+	verifier := &AttestationVerifier{}
+	if ref, p, err := verifier.platformManifest(context.Background(), indexRef, authn.DefaultKeychain); err != nil || ref != indexRef || p != nil {
+		t.Errorf("Expected the index without a platform selected, got %v, %v, %v", ref, p, err)
+	}
+
+	verifier.platform, _ = parsePlatform("linux/amd64")
+	ctx := withKeyOptions(context.Background(), keyOptions{platform: "linux/arm64"})
+	ref, p, err := verifier.platformManifest(ctx, indexRef, authn.DefaultKeychain)
+	if err != nil {
+		t.Fatalf("Failed to select the platform: %v", err)
+	}
+	if ref.Identifier() != arm64Digest.String() || p.String() != "linux/arm64/v8" {
+		t.Errorf("Expected the key's platform to select the arm64 manifest, got %v (%v)", ref, p)
+	}
+
+	if ref, p, err := verifier.platformManifest(context.Background(), imageRef, authn.DefaultKeychain); err != nil || ref != imageRef || p != nil {
+		t.Errorf("Expected a single image as is, got %v, %v, %v", ref, p, err)
+	}
+
+	ctx = withKeyOptions(context.Background(), keyOptions{platform: "linux/s390x"})
+	_, _, err = verifier.platformManifest(ctx, indexRef, authn.DefaultKeychain)
+	if ErrorCode(err) != ErrCodePlatform || !strings.Contains(err.Error(), "linux/amd64, linux/arm64/v8)") {
+		t.Errorf("Expected %s listing the available platforms, got %v", ErrCodePlatform, err)
+	}
+}
+
+func TestPlatformKeyOption(t *testing.T) {
+	imageRef, opts := splitKeyOptions("nginx:1.25|[]||" + "|platform=linux/arm64")
+	if opts.platform != "linux/arm64" || opts.resultKey(imageRef) != "nginx:1.25|[]|||platform=linux/arm64" {
+		t.Errorf("Expected the platform option, got %+v", opts)
+	}
+	if _, opts := splitKeyOptions("nginx:1.25|[]|||platform=linux"); opts.platform != "" {
+		t.Errorf("Expected an invalid platform to be ignored, got %q", opts.platform)
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.policyHashWithOptions("", "", opts) == verifier.policyHashWithOptions("", "", keyOptions{}) {
+		t.Error("Expected the platform to change the policy hash")
+	}
+
+	if _, _, err := parseKey(`{"image":"nginx:1.25","policy":{"platform":"linux"}}`); err == nil {
+		t.Error("Expected an error for an invalid structured key platform")
+	}
+	if _, opts, err := parseKey(`{"image":"nginx:1.25","policy":{"platform":"linux/arm64"}}`); err != nil || opts.platform != "linux/arm64" {
+		t.Errorf("Expected the structured key platform, got %+v, %v", opts, err)
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// verificationPolicy is the effective policy a verification is checked against.
+// Its hash lets clients and auditors correlate decisions with the exact policy in force.
+type verificationPolicy struct {
+	TrustedRoots            string `json:"trustedRoots"` // trustedRootsHash of the roots in use
+	RekorSearchFallback     bool   `json:"rekorSearchFallback"`
+	AttachedSBOMFallback    bool   `json:"attachedSBOMFallback,omitempty"`
+	OfflineTlog             bool   `json:"offlineTlog,omitempty"`
+	VerifySCT               bool   `json:"verifySCT,omitempty"`
+	RekorCertTolerance      string `json:"rekorCertTolerance"`
+	MaxSBOMAge              string `json:"maxSBOMAge,omitempty"` // Maximum SBOM age and the timestamp it is measured from
+	AttestationRepositories string `json:"attestationRepositories,omitempty"`
+	OriginRepositories      string `json:"originRepositories,omitempty"`
+	AttestationSources      string `json:"attestationSources,omitempty"`
+	Entitlements            string `json:"entitlements,omitempty"`
+	RepositoryPolicies      string `json:"repositoryPolicies,omitempty"` // Repository policy tag and signer
+	LicenseAliases          string `json:"licenseAliases,omitempty"`     // Hash of the license alias map
+	PredicateTypes          string `json:"predicateTypes,omitempty"`     // SBOM predicate types accepted by the provider
+	PublicKey               string `json:"publicKey,omitempty"`          // Fingerprint of the static verification key, or its KMS URI
+	KeyRef                  string `json:"keyRef,omitempty"`             // KMS key URI set per constraint
+	Identity                string `json:"identity,omitempty"`
+	IdentityRegexp          string `json:"identityRegexp,omitempty"`
+	Identities              string `json:"identities,omitempty"`     // JSON list of allowed identities
+	CertExtensions          string `json:"certExtensions,omitempty"` // JSON certificate extensions required
+	Annotations             string `json:"annotations,omitempty"`    // JSON signed annotations required
+	KeyPredicateTypes       string `json:"keyPredicateTypes,omitempty"`
+	Platform                string `json:"platform,omitempty"`            // Platform selected from image indexes
+	ImageSignature          bool   `json:"imageSignature,omitempty"`      // A cosign signature of the image is required too
+	UnsignedBuildxSBOMs     string `json:"unsignedBuildxSBOMs,omitempty"` // Handling of unsigned BuildKit and attached SBOMs, when not failing
+	Issuer                  string `json:"issuer,omitempty"`
+	EnforceDSSESignatures   bool   `json:"enforceDSSESignatures,omitempty"` // Attestations failing the DSSE check are dropped
+	MaxAttestations         int    `json:"maxAttestations"`                 // Attestations verified per image and source
+}
+
+// hash returns a stable hex-encoded sha256 of the policy
+func (p verificationPolicy) hash() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// policy returns the effective policy for the given trust material, identity constraints and
+// per-constraint key options
+func (v *AttestationVerifier) policy(trustHash, certIdentity, certOidcIssuer string, opts keyOptions) verificationPolicy {
+	return verificationPolicy{
+		TrustedRoots:            trustHash,
+		RekorSearchFallback:     v.rekorSearchFallback,
+		AttachedSBOMFallback:    v.attachedSBOMs && !v.explicitSources,
+		OfflineTlog:             v.offlineTlog,
+		VerifySCT:               v.verifySCT,
+		RekorCertTolerance:      v.rekorCertTolerance.String(),
+		MaxSBOMAge:              v.sbomAgePolicy(),
+		AttestationRepositories: v.attestationReposPolicy(),
+		OriginRepositories:      v.originReposPolicy(),
+		AttestationSources:      v.attestationSourcesPolicy(),
+		Entitlements:            v.entitlementsPolicy(),
+		RepositoryPolicies:      v.repositoryPolicies.describe(),
+		LicenseAliases:          v.licenseAliases.hash(),
+		PredicateTypes:          v.predicateTypes.String(),
+		PublicKey:               v.publicKeyPolicy(),
+		KeyRef:                  opts.keyRef,
+		Identity:                certIdentity,
+		IdentityRegexp:          opts.identityRegexp,
+		Identities:              opts.identities,
+		CertExtensions:          opts.certExtensions,
+		Annotations:             opts.annotations,
+		KeyPredicateTypes:       opts.predicateTypes,
+		Platform:                v.platformPolicy(opts),
+		ImageSignature:          v.requireImageSignature || opts.imageSignature,
+		UnsignedBuildxSBOMs:     v.unsignedSBOMsPolicy(opts),
+		Issuer:                  normalizeIssuer(certOidcIssuer),
+		EnforceDSSESignatures:   v.enforceDSSESignatures,
+		MaxAttestations:         v.maxAttestations(),
+	}
+}
+
+// PolicyHashFor returns the hash of the effective policy for the given identity constraints
+func (v *AttestationVerifier) PolicyHashFor(certIdentity, certOidcIssuer string) string {
+	return v.policyHashWithOptions(certIdentity, certOidcIssuer, keyOptions{})
+}
+
+// policyHashWithOptions returns the hash of the effective policy for the given identity
+// constraints and per-constraint key options
+func (v *AttestationVerifier) policyHashWithOptions(certIdentity, certOidcIssuer string, opts keyOptions) string {
+	v.trustMu.RLock()
+	trustHash := v.trustHash
+	v.trustMu.RUnlock()
+	return v.policy(trustHash, certIdentity, certOidcIssuer, opts).hash()
+}
+
+// PolicyHashForKey returns the hash of the effective policy for a provider key
+// Key format: image|secrets|certIdentity|certOidcIssuer[|options]
+func (v *AttestationVerifier) PolicyHashForKey(key string) string {
+	parts := strings.SplitN(key, "|", 5)
+	var certIdentity, certOidcIssuer string
+	if len(parts) >= 3 {
+		certIdentity = parts[2]
+	}
+	if len(parts) >= 4 {
+		certOidcIssuer = parts[3]
+	}
+	_, opts := splitKeyOptions(key)
+	return v.policyHashWithOptions(certIdentity, certOidcIssuer, opts)
+}
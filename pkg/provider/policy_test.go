@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyHashStable(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+
+	first := verifier.PolicyHashForKey("ghcr.io/myorg/app:v1|[]|user@example.com|https://accounts.google.com")
+	second := verifier.PolicyHashFor("user@example.com", "https://accounts.google.com")
+	if first != second {
+		t.Errorf("Expected same hash for key and explicit identity, got %s and %s", first, second)
+	}
+
+	// Secrets and image do not affect the policy
+	if other := verifier.PolicyHashForKey(`ghcr.io/myorg/other:v2|["regcred"]|user@example.com|https://accounts.google.com`); other != first {
+		t.Errorf("Expected hash to ignore image and secrets, got %s and %s", first, other)
+	}
+}
+
+func TestPolicyHashChanges(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+	base := verifier.PolicyHashFor("user@example.com", "https://accounts.google.com")
+
+	if h := verifier.PolicyHashFor("other@example.com", "https://accounts.google.com"); h == base {
+		t.Error("Expected identity to change the policy hash")
+	}
+
+	tolerant := &AttestationVerifier{rekorCertTolerance: time.Minute}
+	tolerant.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+	if h := tolerant.PolicyHashFor("user@example.com", "https://accounts.google.com"); h == base {
+		t.Error("Expected verification options to change the policy hash")
+	}
+
+	for _, stricter := range []*AttestationVerifier{{enforceDSSESignatures: true}, {attestationCap: 5}} {
+		stricter.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+		if h := stricter.PolicyHashFor("user@example.com", "https://accounts.google.com"); h == base {
+			t.Errorf("Expected DSSE enforcement and the attestation cap to change the policy hash")
+		}
+	}
+
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":2}`}}})
+	if h := verifier.PolicyHashFor("user@example.com", "https://accounts.google.com"); h == base {
+		t.Error("Expected trust material to change the policy hash")
+	}
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// predicateTypeAllowlist lists the SBOM predicate types a verification accepts, as format names
+// ("spdx", "cyclonedx") or predicate type URIs. An empty allowlist accepts every SBOM format.
+type predicateTypeAllowlist []string
+
+// parsePredicateTypes validates an allowlist of predicate types, each of which must be an SBOM
+// format name or predicate type the provider can decode
+func parsePredicateTypes(types []string) (predicateTypeAllowlist, error) {
+	var allowlist predicateTypeAllowlist
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if sbomFormat(t) == "" {
+			return nil, fmt.Errorf("invalid predicate type %q: not an SBOM format or predicate type the provider decodes", t)
+		}
+		allowlist = append(allowlist, t)
+	}
+	return allowlist, nil
+}
+
+// allows reports whether the allowlist accepts predicateType: listed verbatim, or listed by
+// its format name
+func (a predicateTypeAllowlist) allows(predicateType string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	format := sbomFormat(predicateType)
+	for _, t := range a {
+		if t == predicateType || (t == sbomFormat(t) && t == format) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the allowlist in sorted order, for messages and the policy hash
+func (a predicateTypeAllowlist) String() string {
+	types := append([]string(nil), a...)
+	sort.Strings(types)
+	return strings.Join(types, ",")
+}
+
+// predicateTypeFilter combines the allowlists of the provider and of a key; a predicate type
+// must be accepted by both
+type predicateTypeFilter []predicateTypeAllowlist
+
+// allows reports whether every allowlist of the filter accepts predicateType
+func (f predicateTypeFilter) allows(predicateType string) bool {
+	for _, a := range f {
+		if !a.allows(predicateType) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the allowlists of the filter for messages
+func (f predicateTypeFilter) String() string {
+	var parts []string
+	for _, a := range f {
+		if len(a) > 0 {
+			parts = append(parts, a.String())
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// acceptedPredicateTypes returns the filter of SBOM predicate types the verification of ctx
+// accepts: the provider's allowlist narrowed by the key's predicateTypes option
+func (v *AttestationVerifier) acceptedPredicateTypes(ctx context.Context) (predicateTypeFilter, error) {
+	filter := predicateTypeFilter{v.predicateTypes}
+	raw := predicateTypes(ctx)
+	if raw == "" {
+		return filter, nil
+	}
+
+	var types []string
+	if err := json.Unmarshal([]byte(raw), &types); err != nil {
+		return nil, fmt.Errorf("invalid predicateTypes %q: %w", raw, err)
+	}
+	allowlist, err := parsePredicateTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	return append(filter, allowlist), nil
+}
+
+// predicateTypeError returns an ErrCodePredicateType error when SBOM attestations were found
+// but none of their predicate types is accepted, nil when no SBOM was rejected
+func predicateTypeError(rejected []string, accepted predicateTypeFilter) error {
+	if len(rejected) == 0 {
+		return nil
+	}
+	sort.Strings(rejected)
+	return newVerificationError(ErrCodePredicateType, "SBOM attestations of predicate type %s are not accepted (accepted: %s)", strings.Join(rejected, ", "), accepted)
+}
+
+// appendUnique appends s to list unless it is already listed
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestPredicateTypeAllowlist(t *testing.T) {
+	allowlist, err := parsePredicateTypes([]string{" cyclonedx ", "", "https://spdx.dev/Document/v2.3"})
+	if err != nil {
+		t.Fatalf("Expected a valid allowlist, got %v", err)
+	}
+	for predicateType, want := range map[string]bool{
+		"https://cyclonedx.org/bom":      true,
+		"https://cyclonedx.org/schema":   true,
+		"https://spdx.dev/Document/v2.3": true,
+		"https://spdx.dev/Document":      false,
+	} {
+		if got := allowlist.allows(predicateType); got != want {
+			t.Errorf("Expected %s to be allowed: %v, got %v", predicateType, want, got)
+		}
+	}
+	if got := allowlist.String(); got != "cyclonedx,https://spdx.dev/Document/v2.3" {
+		t.Errorf("Expected a sorted allowlist, got %q", got)
+	}
+
+	if !(predicateTypeAllowlist{}).allows("https://spdx.dev/Document") {
+		t.Error("Expected an empty allowlist to accept every SBOM format")
+	}
+	if _, err := parsePredicateTypes([]string{"https://slsa.dev/provenance/v1"}); err == nil {
+		t.Error("Expected an error for a predicate type the provider cannot decode")
+	}
+}
+
+func TestPredicateTypesKeyOption(t *testing.T) {
+	settings := KeySettings{PredicateTypes: []string{"cyclonedx"}}
+	key := settings.key("ghcr.io/org/app:v1", nil)
+	imageRef, opts := splitKeyOptions(key)
+	if opts.predicateTypes != `["cyclonedx"]` {
+		t.Errorf("Expected the predicate types to round-trip, got %q from %q", opts.predicateTypes, key)
+	}
+	if got := opts.resultKey(imageRef); got != key {
+		t.Errorf("Expected the result key %q, got %q", key, got)
+	}
+
+	verifier := &AttestationVerifier{}
+	if verifier.PolicyHashForKey(key) == verifier.PolicyHashForKey("ghcr.io/org/app:v1|[]||") {
+		t.Error("Expected accepted predicate types to change the policy hash")
+	}
+}
+
+func TestSBOMFromAttestationsPredicateTypes(t *testing.T) {
+	attestation := func(predicateType, predicate string) verifiedAttestation {
+		statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"` + predicateType + `","predicate":` + predicate + `}`
+		envelope, _ := json.Marshal(map[string]string{
+			"payloadType": "application/vnd.in-toto+json",
+			"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+		})
+		return verifiedAttestation{payload: envelope}
+	}
+	spdx := attestation("https://spdx.dev/Document", `{"spdxVersion":"SPDX-2.3","packages":[{"name":"zlib"}]}`)
+	cyclonedx := attestation("https://cyclonedx.org/bom", `{"bomFormat":"CycloneDX","components":[{"name":"openssl"}]}`)
+
+	verifier := &AttestationVerifier{}
+	ctx := withKeyOptions(context.Background(), keyOptions{predicateTypes: `["cyclonedx"]`})
+	unified, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{spdx, cyclonedx})
+	if err != nil {
+		t.Fatalf("Expected the CycloneDX SBOM, got %v", err)
+	}
+	if unified.Format != "cyclonedx" {
+		t.Errorf("Expected the SPDX SBOM to be skipped, got %s", unified.Format)
+	}
+
+	if _, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{spdx}); ErrorCode(err) != ErrCodePredicateType {
+		t.Errorf("Expected %s when only SPDX is attached, got %v", ErrCodePredicateType, err)
+	}
+	metadataCtx := withKeyOptions(context.Background(), keyOptions{metadataOnly: true, predicateTypes: `["cyclonedx"]`})
+	if _, err := verifier.sbomFromAttestations(metadataCtx, []verifiedAttestation{spdx}); ErrorCode(err) != ErrCodePredicateType {
+		t.Errorf("Expected %s for metadata-only verification, got %v", ErrCodePredicateType, err)
+	}
+
+	// A key can only narrow the provider's allowlist
+	verifier.predicateTypes = predicateTypeAllowlist{"spdx"}
+	if _, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{spdx, cyclonedx}); ErrorCode(err) != ErrCodePredicateType {
+		t.Errorf("Expected %s when the allowlists exclude each other, got %v", ErrCodePredicateType, err)
+	}
+
+	invalid := withKeyOptions(context.Background(), keyOptions{predicateTypes: `["in-toto"]`})
+	if _, err := (&AttestationVerifier{}).sbomFromAttestations(invalid, []verifiedAttestation{cyclonedx}); err == nil {
+		t.Error("Expected an error for an invalid predicate type")
+	}
+}
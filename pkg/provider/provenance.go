@@ -0,0 +1,52 @@
+package provider
+
+import "strings"
+
+// Package layer metadata recorded by SBOM generators
+const (
+	// Trivy records both digests as CycloneDX component properties
+	trivyLayerDigestProperty = "aquasecurity:trivy:LayerDigest"
+	trivyLayerDiffIDProperty = "aquasecurity:trivy:LayerDiffID"
+	// and as SPDX package annotations, e.g. "LayerDigest: sha256:..."
+	trivyLayerDigestAnnotation = "LayerDigest:"
+	trivyLayerDiffIDAnnotation = "LayerDiffID:"
+	// Syft records the diff ID of the layer each package location was found in
+	syftLayerIDProperty = "syft:location:0:layerID"
+)
+
+// packageLayer identifies the image layer a package was added by
+type packageLayer struct {
+	digest string
+	diffID string
+}
+
+// cycloneDXLayer returns the layer recorded in a CycloneDX component's properties
+func cycloneDXLayer(properties []CycloneDXProperty) packageLayer {
+	var layer packageLayer
+	for _, p := range properties {
+		switch p.Name {
+		case trivyLayerDigestProperty:
+			layer.digest = p.Value
+		case trivyLayerDiffIDProperty, syftLayerIDProperty:
+			if layer.diffID == "" {
+				layer.diffID = p.Value
+			}
+		}
+	}
+	return layer
+}
+
+// spdxLayer returns the layer recorded in an SPDX package's annotations
+func spdxLayer(annotations []SPDXAnnotation) packageLayer {
+	var layer packageLayer
+	for _, a := range annotations {
+		comment := strings.TrimSpace(a.Comment)
+		switch {
+		case strings.HasPrefix(comment, trivyLayerDigestAnnotation):
+			layer.digest = strings.TrimSpace(strings.TrimPrefix(comment, trivyLayerDigestAnnotation))
+		case strings.HasPrefix(comment, trivyLayerDiffIDAnnotation):
+			layer.diffID = strings.TrimSpace(strings.TrimPrefix(comment, trivyLayerDiffIDAnnotation))
+		}
+	}
+	return layer
+}
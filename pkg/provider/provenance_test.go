@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCycloneDXLayer(t *testing.T) {
+	trivy := cycloneDXLayer([]CycloneDXProperty{
+		{Name: "aquasecurity:trivy:PkgType", Value: "debian"},
+		{Name: trivyLayerDigestProperty, Value: "sha256:aaa"},
+		{Name: trivyLayerDiffIDProperty, Value: "sha256:bbb"},
+	})
+	if trivy.digest != "sha256:aaa" || trivy.diffID != "sha256:bbb" {
+		t.Errorf("Expected trivy layer sha256:aaa/sha256:bbb, got %s/%s", trivy.digest, trivy.diffID)
+	}
+
+	syft := cycloneDXLayer([]CycloneDXProperty{
+		{Name: "syft:location:0:path", Value: "/usr/lib/os-release"},
+		{Name: syftLayerIDProperty, Value: "sha256:ccc"},
+	})
+	if syft.digest != "" || syft.diffID != "sha256:ccc" {
+		t.Errorf("Expected syft layer diff ID sha256:ccc, got %s/%s", syft.digest, syft.diffID)
+	}
+
+	if none := cycloneDXLayer(nil); none != (packageLayer{}) {
+		t.Errorf("Expected no layer, got %+v", none)
+	}
+}
+
+func TestSPDXLayer(t *testing.T) {
+	layer := spdxLayer([]SPDXAnnotation{
+		{Annotator: "Tool: trivy", AnnotationType: "OTHER", Comment: "PkgType: debian"},
+		{Annotator: "Tool: trivy", AnnotationType: "OTHER", Comment: "LayerDiffID: sha256:bbb"},
+		{Annotator: "Tool: trivy", AnnotationType: "OTHER", Comment: "LayerDigest: sha256:aaa"},
+	})
+	if layer.digest != "sha256:aaa" || layer.diffID != "sha256:bbb" {
+		t.Errorf("Expected layer sha256:aaa/sha256:bbb, got %s/%s", layer.digest, layer.diffID)
+	}
+}
+
+func TestExtractAndNormalizeLayers(t *testing.T) {
+	verifier := &AttestationVerifier{}
+
+	cdx, _ := json.Marshal(CycloneDXBOM{
+		BOMFormat: "CycloneDX",
+		Components: []CycloneDXComponent{
+			{Type: "library", Name: "openssl", Properties: []CycloneDXProperty{{Name: trivyLayerDigestProperty, Value: "sha256:base"}}},
+			{Type: "library", Name: "app"},
+		},
+	})
+	unified, err := verifier.extractAndNormalizeCycloneDX(cdx)
+	if err != nil {
+		t.Fatalf("Failed to normalize CycloneDX: %v", err)
+	}
+	if unified.Packages[0].LayerDigest != "sha256:base" {
+		t.Errorf("Expected layer digest sha256:base, got '%s'", unified.Packages[0].LayerDigest)
+	}
+	if unified.Packages[1].LayerDigest != "" {
+		t.Errorf("Expected no layer digest, got '%s'", unified.Packages[1].LayerDigest)
+	}
+
+	spdx, _ := json.Marshal(SPDXDocument{
+		Packages: []SPDXPackage{
+			{Name: "openssl", Annotations: []SPDXAnnotation{{Comment: "LayerDiffID: sha256:diff"}}},
+		},
+	})
+	unified, err = verifier.extractAndNormalizeSPDX(spdx)
+	if err != nil {
+		t.Fatalf("Failed to normalize SPDX: %v", err)
+	}
+	if unified.Packages[0].LayerDiffID != "sha256:diff" {
+		t.Errorf("Expected layer diff ID sha256:diff, got '%s'", unified.Packages[0].LayerDiffID)
+	}
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// loadPublicKey loads a cosign public key given as PEM or as the path of a PEM file, and
+// returns its verifier and the sha256 fingerprint of its DER encoding
+func loadPublicKey(spec string) (signature.Verifier, string, error) {
+	raw := []byte(spec)
+	if !strings.HasPrefix(strings.TrimSpace(spec), "-----BEGIN") {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read public key: %w", err)
+		}
+		raw = data
+	}
+	return parsePublicKey(raw)
+}
+
+// parsePublicKey parses a PEM public key and returns its verifier and fingerprint
+func parsePublicKey(raw []byte) (signature.Verifier, string, error) {
+	pub, err := cryptoutils.UnmarshalPEMToPublicKey(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+	verifier, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported public key: %w", err)
+	}
+
+	sum := sha256.Sum256(der)
+	return verifier, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// verificationKey returns the key the attestations verified in ctx must be signed with: the
+// KMS or Secret key of the constraint, else the configured public key. Nil means keyless verification.
+func (v *AttestationVerifier) verificationKey(ctx context.Context) (signature.Verifier, string, error) {
+	ref := keyRef(ctx)
+	if ref == "" {
+		ref = v.publicKeyRef
+	}
+	if ref == "" {
+		return v.publicKey, v.publicKeyFingerprint, nil
+	}
+	if isSecretKeyRef(ref) {
+		if v.secretKeys == nil {
+			return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: errors.New("secret keys require a kubernetes client")}
+		}
+		return v.secretKeys.Get(ctx, ref)
+	}
+	if !isKMSRef(ref) {
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: fmt.Errorf("key %q is not a KMS key URI", ref)}
+	}
+	if v.kmsKeys == nil {
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: errors.New("KMS keys are not supported by this verifier")}
+	}
+	return v.kmsKeys.Get(ctx, ref)
+}
+
+// publicKeyPolicy renders the configured public key for the verification policy hash
+func (v *AttestationVerifier) publicKeyPolicy() string {
+	if v.publicKeyRef != "" {
+		return v.publicKeyRef
+	}
+	return v.publicKeyFingerprint
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+func TestLoadPublicKey(t *testing.T) {
+	keys, err := cosign.GenerateKeyPair(func(bool) ([]byte, error) { return []byte("s3cret"), nil })
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, keys.PublicBytes, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	inline, inlineFingerprint, err := loadPublicKey(string(keys.PublicBytes))
+	if err != nil {
+		t.Fatalf("Failed to load inline public key: %v", err)
+	}
+	_, fileFingerprint, err := loadPublicKey(path)
+	if err != nil {
+		t.Fatalf("Failed to load public key file: %v", err)
+	}
+	if !strings.HasPrefix(inlineFingerprint, "sha256:") || inlineFingerprint != fileFingerprint {
+		t.Errorf("Expected the same fingerprint inline and from a file, got %s and %s", inlineFingerprint, fileFingerprint)
+	}
+
+	signer, err := cosign.LoadPrivateKey(keys.PrivateBytes, []byte("s3cret"), nil)
+	if err != nil {
+		t.Fatalf("Failed to load private key: %v", err)
+	}
+	sig, err := signer.SignMessage(bytes.NewReader([]byte("payload")))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if err := inline.VerifySignature(bytes.NewReader(sig), bytes.NewReader([]byte("payload"))); err != nil {
+		t.Errorf("Expected the signature to verify with the public key: %v", err)
+	}
+
+	if _, _, err := loadPublicKey(filepath.Join(t.TempDir(), "missing.pub")); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+	if _, _, err := loadPublicKey("-----BEGIN PUBLIC KEY-----\nnot a key\n-----END PUBLIC KEY-----"); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+
+	verifier := &AttestationVerifier{}
+	hash := verifier.PolicyHashFor("", "")
+	verifier.publicKey, verifier.publicKeyFingerprint = inline, inlineFingerprint
+	if verifier.PolicyHashFor("", "") == hash {
+		t.Error("Expected the public key to change the policy hash")
+	}
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// UnifiedSBOMArtifactType is the artifact type of verified SBOMs published as referrers of their image
+const UnifiedSBOMArtifactType = "application/vnd.sbom-provider.unified-sbom.v1+json"
+
+// Annotations of published SBOM artifacts
+const (
+	// publishedSignatureAnnotation holds the base64 signature of the SBOM layer by the provider key,
+	// verifiable with "cosign verify-blob --key <public key> --signature <value>"
+	publishedSignatureAnnotation  = "dev.sbom-provider.signature"
+	publishedPolicyHashAnnotation = "dev.sbom-provider.policy-hash"
+	publishedSourceAnnotation     = "dev.sbom-provider.source"
+	publishedCreatedAnnotation    = "org.opencontainers.image.created"
+)
+
+// publishTimeout bounds pushing one SBOM artifact, which happens after the admission response
+const publishTimeout = time.Minute
+
+// maxConcurrentPublishes bounds SBOM artifacts pushed at once
+const maxConcurrentPublishes = 4
+
+// sbomPublisher pushes verified unified SBOMs back to the registry as signed OCI referrers of
+// their image, so other tools can consume the policy-verified SBOM without re-verifying it
+type sbomPublisher struct {
+	signer signature.Signer
+	slots  chan struct{}
+
+	mu        sync.Mutex
+	published map[string]bool // Image digest and policy hash of the artifacts pushed or being pushed
+}
+
+// loadSBOMPublisher creates a publisher signing with the cosign private key at keyPath
+func loadSBOMPublisher(keyPath, password string) (*sbomPublisher, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM publishing key: %w", err)
+	}
+	signer, err := cosign.LoadPrivateKey(key, []byte(password), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SBOM publishing key: %w", err)
+	}
+	return newSBOMPublisher(signer), nil
+}
+
+// newSBOMPublisher creates a publisher signing with signer
+func newSBOMPublisher(signer signature.Signer) *sbomPublisher {
+	return &sbomPublisher{
+		signer:    signer,
+		slots:     make(chan struct{}, maxConcurrentPublishes),
+		published: make(map[string]bool),
+	}
+}
+
+// claim marks the artifact for digest and policyHash as published, reporting false if it already was
+func (p *sbomPublisher) claim(digest, policyHash string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := digest + "|" + policyHash
+	if p.published[key] {
+		return false
+	}
+	p.published[key] = true
+	return true
+}
+
+// release forgets a failed publication so the next verification retries it
+func (p *sbomPublisher) release(digest, policyHash string) {
+	p.mu.Lock()
+	delete(p.published, digest+"|"+policyHash)
+	p.mu.Unlock()
+}
+
+// artifact builds the signed SBOM artifact referring to subject
+func (p *sbomPublisher) artifact(subject v1.Descriptor, sbom []byte, unified *UnifiedSBOM, now time.Time) (v1.Image, error) {
+	sig, err := p.signer.SignMessage(bytes.NewReader(sbom))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign SBOM: %w", err)
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{Layer: static.NewLayer(sbom, types.MediaType("application/json"))})
+	if err != nil {
+		return nil, err
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.MediaType(UnifiedSBOMArtifactType))
+	img = mutate.Annotations(img, map[string]string{
+		publishedSignatureAnnotation:  base64.StdEncoding.EncodeToString(sig),
+		publishedPolicyHashAnnotation: unified.PolicyHash,
+		publishedSourceAnnotation:     unified.Source,
+		publishedCreatedAnnotation:    now.UTC().Format(time.RFC3339),
+	}).(v1.Image)
+	return mutate.Subject(img, subject).(v1.Image), nil
+}
+
+// Publish pushes unified as a referrer of ref, once per image digest and policy hash
+func (p *sbomPublisher) Publish(ctx context.Context, ref name.Reference, unified *UnifiedSBOM, opts ...remote.Option) error {
+	opts = append(opts, remote.WithContext(ctx))
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to resolve image: %w", err)
+	}
+	if !p.claim(desc.Digest.String(), unified.PolicyHash) {
+		return nil
+	}
+
+	sbom, err := json.Marshal(unified)
+	if err != nil {
+		p.release(desc.Digest.String(), unified.PolicyHash)
+		return fmt.Errorf("failed to marshal SBOM: %w", err)
+	}
+	img, err := p.artifact(v1.Descriptor{MediaType: desc.MediaType, Size: desc.Size, Digest: desc.Digest}, sbom, unified, time.Now())
+	if err != nil {
+		p.release(desc.Digest.String(), unified.PolicyHash)
+		return err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		p.release(desc.Digest.String(), unified.PolicyHash)
+		return err
+	}
+
+	if err := remote.Write(ref.Context().Digest(digest.String()), img, opts...); err != nil {
+		p.release(desc.Digest.String(), unified.PolicyHash)
+		return fmt.Errorf("failed to push SBOM artifact: %w", err)
+	}
+	log.Printf("Published verified SBOM of %s@%s as %s", ref.Context(), desc.Digest, digest)
+	return nil
+}
+
+// publishSBOM pushes a copy of unified in the background, skipping it when all publishing
+// slots are busy as the next verification of the image will publish it
+func (v *AttestationVerifier) publishSBOM(ref name.Reference, unified *UnifiedSBOM, keychain authn.Keychain) {
+	if v.publisher == nil || unified.MetadataOnly || len(unified.Violations) > 0 {
+		return
+	}
+
+	select {
+	case v.publisher.slots <- struct{}{}:
+	default:
+		log.Printf("Warning: skipping SBOM publication for %s, too many publications in flight", ref)
+		return
+	}
+
+	sbom := *unified
+	go func() {
+		defer func() { <-v.publisher.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+		if err := v.publisher.Publish(ctx, ref, &sbom, v.remoteOptions(ctx, keychain)...); err != nil {
+			log.Printf("Warning: failed to publish SBOM for %s: %v", ref, err)
+		}
+	}()
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestLoadSBOMPublisher(t *testing.T) {
+	keys, err := cosign.GenerateKeyPair(func(bool) ([]byte, error) { return []byte("s3cret"), nil })
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(path, keys.PrivateBytes, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	if _, err := loadSBOMPublisher(path, "s3cret"); err != nil {
+		t.Errorf("Failed to load publishing key: %v", err)
+	}
+	if _, err := loadSBOMPublisher(path, "wrong"); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+	if _, err := loadSBOMPublisher(filepath.Join(t.TempDir(), "missing.key"), ""); err == nil {
+		t.Error("Expected an error for a missing key")
+	}
+}
+
+func TestSBOMPublisherPublish(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true)))
+	defer reg.Close()
+
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+
+	signer, _, err := signature.NewDefaultECDSASignerVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	publisher := newSBOMPublisher(signer)
+
+	unified := &UnifiedSBOM{
+		Format:     "spdx",
+		Packages:   []UnifiedPackage{{Name: "openssl", Version: "3.0.0"}},
+		PolicyHash: "3f1a9c",
+		Source:     AttestationSourceReferrers,
+	}
+	ctx := context.Background()
+	if err := publisher.Publish(ctx, ref, unified); err != nil {
+		t.Fatalf("Failed to publish SBOM: %v", err)
+	}
+	// The same image and policy is only published once
+	if err := publisher.Publish(ctx, ref, unified); err != nil {
+		t.Fatalf("Failed to publish SBOM again: %v", err)
+	}
+
+	index, err := remote.Referrers(ref.Context().Digest(digest.String()))
+	if err != nil {
+		t.Fatalf("Failed to list referrers: %v", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatalf("Failed to read referrers: %v", err)
+	}
+	if len(manifest.Manifests) != 1 || manifest.Manifests[0].ArtifactType != UnifiedSBOMArtifactType {
+		t.Fatalf("Expected one unified SBOM referrer, got %+v", manifest.Manifests)
+	}
+
+	artifact, err := remote.Image(ref.Context().Digest(manifest.Manifests[0].Digest.String()))
+	if err != nil {
+		t.Fatalf("Failed to fetch artifact: %v", err)
+	}
+	m, err := artifact.Manifest()
+	if err != nil {
+		t.Fatalf("Failed to read artifact manifest: %v", err)
+	}
+	if m.Annotations[publishedPolicyHashAnnotation] != "3f1a9c" || m.Subject == nil || m.Subject.Digest != digest {
+		t.Errorf("Unexpected artifact manifest %+v", m)
+	}
+
+	layers, err := artifact.Layers()
+	if err != nil || len(layers) != 1 {
+		t.Fatalf("Expected one SBOM layer, got %d (%v)", len(layers), err)
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatalf("Failed to read SBOM layer: %v", err)
+	}
+	defer rc.Close()
+	sbom, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Failed to read SBOM layer: %v", err)
+	}
+
+	var published UnifiedSBOM
+	if err := json.Unmarshal(sbom, &published); err != nil || len(published.Packages) != 1 {
+		t.Errorf("Expected the unified SBOM, got %s", sbom)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Annotations[publishedSignatureAnnotation])
+	if err != nil {
+		t.Fatalf("Failed to decode signature: %v", err)
+	}
+	if err := signer.VerifySignature(bytes.NewReader(sig), bytes.NewReader(sbom)); err != nil {
+		t.Errorf("Expected a valid signature of the SBOM: %v", err)
+	}
+}
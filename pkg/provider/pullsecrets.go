@@ -0,0 +1,325 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerConfigEntry is a single registry entry of a docker config file
+type dockerConfigEntry struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+// registryCredential is a credential for a registry pattern and the secret it came from
+type registryCredential struct {
+	pattern string // normalized host[/path], host may start with "*."
+	secret  string
+	auth    authn.AuthConfig
+}
+
+// pullSecretKeychain resolves credentials merged from the dockerconfigjson/dockercfg
+// entries of several pull secrets. When the same registry appears in multiple secrets the
+// first secret in the list wins, mirroring the order of imagePullSecrets in the pod spec.
+type pullSecretKeychain struct {
+	creds []registryCredential
+}
+
+// newPullSecretKeychain parses and merges the registry entries of secrets
+func newPullSecretKeychain(secrets []corev1.Secret) (*pullSecretKeychain, error) {
+	k := &pullSecretKeychain{}
+	seen := map[string]string{}
+
+	for _, secret := range secrets {
+		entries, err := parseDockerConfigSecret(secret)
+		if err != nil {
+			log.Printf("Warning: Skipping pull secret %s: %v", secret.Name, err)
+			continue
+		}
+
+		for registry, entry := range entries {
+			pattern := normalizeRegistryPattern(registry)
+			if owner, ok := seen[pattern]; ok {
+				log.Printf("Pull secret %s entry for %s is shadowed by secret %s", secret.Name, pattern, owner)
+				continue
+			}
+
+			auth, err := entry.authConfig()
+			if err != nil {
+				log.Printf("Warning: Skipping %s entry in pull secret %s: %v", pattern, secret.Name, err)
+				continue
+			}
+
+			seen[pattern] = secret.Name
+			k.creds = append(k.creds, registryCredential{pattern: pattern, secret: secret.Name, auth: auth})
+		}
+	}
+
+	if len(k.creds) == 0 {
+		return nil, fmt.Errorf("no usable registry credentials in %d pull secrets", len(secrets))
+	}
+	return k, nil
+}
+
+// Resolve implements authn.Keychain
+func (k *pullSecretKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	cred, ok := k.match(target)
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(cred.auth), nil
+}
+
+// source describes which secret supplies credentials for target, redacting the username
+func (k *pullSecretKeychain) source(target authn.Resource) string {
+	cred, ok := k.match(target)
+	if !ok {
+		return "pull secrets"
+	}
+	return fmt.Sprintf("pull secret %s (entry: %s, user: %s)", cred.secret, cred.pattern, redact(cred.auth.Username))
+}
+
+// match finds the most specific credential for target. Exact hosts beat wildcard hosts
+// and longer repository path prefixes beat shorter ones.
+func (k *pullSecretKeychain) match(target authn.Resource) (registryCredential, bool) {
+	host := normalizeRegistryPattern(target.RegistryStr())
+	repo := host
+	if r, ok := target.(name.Repository); ok {
+		repo = host + "/" + r.RepositoryStr()
+	}
+
+	best := -1
+	bestScore := -1
+	for i, cred := range k.creds {
+		credHost, credPath, _ := strings.Cut(cred.pattern, "/")
+
+		score := 0
+		switch {
+		case credHost == host:
+			score = 2
+		case strings.HasPrefix(credHost, "*."):
+			if ok, _ := path.Match(credHost, host); !ok {
+				continue
+			}
+			score = 1
+		default:
+			continue
+		}
+
+		if credPath != "" {
+			prefix := host + "/" + credPath
+			if repo != prefix && !strings.HasPrefix(repo, prefix+"/") {
+				continue
+			}
+			score += 2 * len(credPath)
+		}
+
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return registryCredential{}, false
+	}
+	return k.creds[best], true
+}
+
+// parseDockerConfigSecret returns the registry entries of a dockerconfigjson or dockercfg secret
+func parseDockerConfigSecret(secret corev1.Secret) (map[string]dockerConfigEntry, error) {
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		var cfg struct {
+			Auths map[string]dockerConfigEntry `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", corev1.DockerConfigJsonKey, err)
+		}
+		return cfg.Auths, nil
+	}
+
+	if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		var entries map[string]dockerConfigEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", corev1.DockerConfigKey, err)
+		}
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("secret has neither %s nor %s", corev1.DockerConfigJsonKey, corev1.DockerConfigKey)
+}
+
+// authConfig converts the entry into an authn.AuthConfig, decoding the combined auth field
+func (e dockerConfigEntry) authConfig() (authn.AuthConfig, error) {
+	cfg := authn.AuthConfig{
+		Username:      e.Username,
+		Password:      e.Password,
+		IdentityToken: e.IdentityToken,
+		RegistryToken: e.RegistryToken,
+	}
+
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid auth field: %w", err)
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return cfg, fmt.Errorf("auth field is not user:password")
+		}
+		cfg.Username, cfg.Password = user, pass
+	}
+
+	if cfg.Username == "" && cfg.Password == "" && cfg.IdentityToken == "" && cfg.RegistryToken == "" {
+		return cfg, fmt.Errorf("entry has no credentials")
+	}
+	return cfg, nil
+}
+
+// normalizeRegistryPattern reduces docker config keys such as "https://index.docker.io/v1/"
+// to a lowercase host[/path] form comparable with image registries
+func normalizeRegistryPattern(registry string) string {
+	registry = strings.TrimSpace(registry)
+	if strings.Contains(registry, "://") {
+		if u, err := url.Parse(registry); err == nil {
+			registry = u.Host + u.Path
+		}
+	}
+	registry = strings.TrimSuffix(registry, "/")
+	registry = strings.TrimSuffix(registry, "/v1")
+	registry = strings.TrimSuffix(registry, "/v2")
+
+	host, rest, hasPath := strings.Cut(registry, "/")
+	host = strings.ToLower(host)
+	if host == "docker.io" || host == "registry-1.docker.io" {
+		host = name.DefaultRegistry
+	}
+	if hasPath {
+		return host + "/" + rest
+	}
+	return host
+}
+
+// namedKeychain is a keychain labelled with the credential source it represents
+type namedKeychain struct {
+	name     string
+	keychain authn.Keychain
+}
+
+// sourceKeychain tries keychains in order and logs, once per registry host, which
+// source supplied the credentials or that access falls back to anonymous
+type sourceKeychain struct {
+	sources []namedKeychain
+	logged  sync.Map
+	used    sync.Map // registry host -> description of the source used
+}
+
+// newSourceKeychain creates a keychain over sources, tried in order
+func newSourceKeychain(sources ...namedKeychain) *sourceKeychain {
+	return &sourceKeychain{sources: sources}
+}
+
+// Resolve implements authn.Keychain
+func (k *sourceKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	host := target.RegistryStr()
+
+	for _, src := range k.sources {
+		auth, err := src.keychain.Resolve(target)
+		if err != nil {
+			log.Printf("Warning: %s keychain failed for %s: %v", src.name, host, err)
+			continue
+		}
+		if auth == authn.Anonymous {
+			continue
+		}
+
+		source := src.name
+		if d, ok := src.keychain.(interface{ source(authn.Resource) string }); ok {
+			source = d.source(target)
+		}
+		k.used.Store(host, source)
+		if _, done := k.logged.LoadOrStore(host, struct{}{}); !done {
+			log.Printf("Using credentials from %s for %s", source, host)
+		}
+		return auth, nil
+	}
+
+	k.used.Store(host, "anonymous access")
+	if _, done := k.logged.LoadOrStore(host, struct{}{}); !done {
+		log.Printf("No credentials found for %s (tried: %s), using anonymous access", host, k.names())
+	}
+	return authn.Anonymous, nil
+}
+
+// usedSource returns the credential source last used for host
+func (k *sourceKeychain) usedSource(host string) (string, bool) {
+	source, ok := k.used.Load(host)
+	if !ok {
+		return "", false
+	}
+	return source.(string), true
+}
+
+// names lists the configured source names
+func (k *sourceKeychain) names() string {
+	names := make([]string, 0, len(k.sources))
+	for _, src := range k.sources {
+		names = append(names, src.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// redact hides all but the first two characters of s
+func redact(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	if len(s) <= 2 {
+		return "***"
+	}
+	return s[:2] + "***"
+}
+
+// parsePullSecretNamespaces validates the namespaces keys may read pull secrets from, as
+// path.Match patterns, e.g. "team-*". Empty entries are skipped and no patterns allow every
+// namespace.
+func parsePullSecretNamespaces(patterns []string) ([]string, error) {
+	var result []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pull secret namespace pattern %q: %w", pattern, err)
+		}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+// pullSecretNamespaceAllowed reports whether pull secrets may be read from namespace. The
+// provider's own namespace is always allowed.
+func (v *AttestationVerifier) pullSecretNamespaceAllowed(namespace string) bool {
+	if namespace == v.namespace || len(v.pullSecretNamespaces) == 0 {
+		return true
+	}
+	for _, pattern := range v.pullSecretNamespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func dockerConfigSecret(secretName, config string) corev1.Secret {
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(config)},
+	}
+}
+
+func resolveUser(t *testing.T, kc authn.Keychain, image string) string {
+	t.Helper()
+
+	repo, err := name.NewRepository(image)
+	if err != nil {
+		t.Fatalf("Failed to parse repository: %v", err)
+	}
+
+	auth, err := kc.Resolve(repo)
+	if err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+	if auth == authn.Anonymous {
+		return ""
+	}
+
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatalf("Failed to get authorization: %v", err)
+	}
+	return cfg.Username
+}
+
+func TestPullSecretKeychainPrecedence(t *testing.T) {
+	first := dockerConfigSecret("first", `{"auths":{"ghcr.io":{"username":"alice","password":"x"}}}`)
+	second := dockerConfigSecret("second", `{"auths":{
+		"ghcr.io":{"username":"bob","password":"y"},
+		"https://index.docker.io/v1/":{"auth":"`+base64.StdEncoding.EncodeToString([]byte("carol:z"))+`"}
+	}}`)
+
+	kc, err := newPullSecretKeychain([]corev1.Secret{first, second})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+
+	if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user != "alice" {
+		t.Errorf("Expected first secret to win for ghcr.io, got '%s'", user)
+	}
+
+	if user := resolveUser(t, kc, "docker.io/library/nginx"); user != "carol" {
+		t.Errorf("Expected docker hub credentials from second secret, got '%s'", user)
+	}
+
+	if user := resolveUser(t, kc, "quay.io/other/app"); user != "" {
+		t.Errorf("Expected anonymous for unknown registry, got '%s'", user)
+	}
+}
+
+func TestPullSecretKeychainSpecificity(t *testing.T) {
+	secret := dockerConfigSecret("regcred", `{"auths":{
+		"*.example.com":{"username":"wildcard","password":"x"},
+		"registry.example.com":{"username":"host","password":"x"},
+		"registry.example.com/team-a":{"username":"team-a","password":"x"}
+	}}`)
+
+	kc, err := newPullSecretKeychain([]corev1.Secret{secret})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+
+	tests := map[string]string{
+		"registry.example.com/team-a/app": "team-a",
+		"registry.example.com/team-b/app": "host",
+		"mirror.example.com/team-a/app":   "wildcard",
+	}
+	for image, want := range tests {
+		if user := resolveUser(t, kc, image); user != want {
+			t.Errorf("%s: expected '%s', got '%s'", image, want, user)
+		}
+	}
+}
+
+func TestPullSecretKeychainDockercfg(t *testing.T) {
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy"},
+		Type:       corev1.SecretTypeDockercfg,
+		Data:       map[string][]byte{corev1.DockerConfigKey: []byte(`{"quay.io":{"username":"dave","password":"x"}}`)},
+	}
+
+	kc, err := newPullSecretKeychain([]corev1.Secret{secret})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+
+	if user := resolveUser(t, kc, "quay.io/org/app"); user != "dave" {
+		t.Errorf("Expected 'dave', got '%s'", user)
+	}
+}
+
+func TestPullSecretKeychainNoUsableEntries(t *testing.T) {
+	secrets := []corev1.Secret{
+		dockerConfigSecret("broken", `not json`),
+		dockerConfigSecret("empty", `{"auths":{"ghcr.io":{}}}`),
+	}
+
+	if _, err := newPullSecretKeychain(secrets); err == nil {
+		t.Error("Expected error when no secret has usable credentials")
+	}
+}
+
+func TestNormalizeRegistryPattern(t *testing.T) {
+	tests := map[string]string{
+		"https://index.docker.io/v1/": "index.docker.io",
+		"docker.io":                   "index.docker.io",
+		"GHCR.io":                     "ghcr.io",
+		"https://registry.io/org/":    "registry.io/org",
+		"registry.io:5000":            "registry.io:5000",
+	}
+
+	for in, want := range tests {
+		if got := normalizeRegistryPattern(in); got != want {
+			t.Errorf("normalizeRegistryPattern(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSourceKeychainFallback(t *testing.T) {
+	secret := dockerConfigSecret("regcred", `{"auths":{"ghcr.io":{"username":"alice","password":"x"}}}`)
+	psk, err := newPullSecretKeychain([]corev1.Secret{secret})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+
+	kc := newSourceKeychain(namedKeychain{name: "pull secrets", keychain: psk})
+
+	if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user != "alice" {
+		t.Errorf("Expected 'alice', got '%s'", user)
+	}
+
+	if user := resolveUser(t, kc, "quay.io/myorg/app"); user != "" {
+		t.Errorf("Expected anonymous fallback, got '%s'", user)
+	}
+
+	if got := psk.source(mustRepository(t, "ghcr.io/myorg/app")); got != "pull secret regcred (entry: ghcr.io, user: al***)" {
+		t.Errorf("Unexpected source description: %s", got)
+	}
+}
+
+func mustRepository(t *testing.T, image string) name.Repository {
+	t.Helper()
+	repo, err := name.NewRepository(image)
+	if err != nil {
+		t.Fatalf("Failed to parse repository: %v", err)
+	}
+	return repo
+}
+
+func TestCreateKeychainWithSecrets(t *testing.T) {
+	secret := dockerConfigSecret("regcred", `{"auths":{"ghcr.io":{"username":"alice","password":"x"}}}`)
+	secret.Namespace = "gatekeeper-system"
+
+	verifier := &AttestationVerifier{
+		keychain:   authn.DefaultKeychain,
+		kubeClient: fake.NewSimpleClientset(&secret),
+		namespace:  "gatekeeper-system",
+	}
+
+	// The same client serves every request
+	for i := 0; i < 2; i++ {
+		kc, err := verifier.createKeychainWithSecrets(context.Background(), []string{"regcred", "missing"})
+		if err != nil {
+			t.Fatalf("Failed to create keychain: %v", err)
+		}
+		if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user != "alice" {
+			t.Errorf("Expected credentials from pull secret, got '%s'", user)
+		}
+	}
+}
+
+func TestCreateKeychainWithSecretsNoClient(t *testing.T) {
+	verifier := &AttestationVerifier{keychain: authn.DefaultKeychain}
+
+	if _, err := verifier.createKeychainWithSecrets(context.Background(), []string{"regcred"}); err == nil {
+		t.Error("Expected error without a kubernetes client")
+	}
+
+	kc, err := verifier.createKeychainWithSecrets(context.Background(), nil)
+	if err != nil || kc != authn.DefaultKeychain {
+		t.Errorf("Expected default keychain without secrets, got %v, %v", kc, err)
+	}
+}
+
+func TestPullSecretNamespaceAllowlist(t *testing.T) {
+	if _, err := parsePullSecretNamespaces([]string{"team-["}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+	patterns, err := parsePullSecretNamespaces([]string{"team-*", " payments ", ""})
+	if err != nil || len(patterns) != 2 {
+		t.Fatalf("Expected two patterns, got %v, %v", patterns, err)
+	}
+
+	secret := dockerConfigSecret("regcred", `{"auths":{"ghcr.io":{"username":"alice","password":"x"}}}`)
+	secret.Namespace = "sandbox"
+	verifier := &AttestationVerifier{
+		keychain:             authn.DefaultKeychain,
+		kubeClient:           fake.NewSimpleClientset(&secret),
+		namespace:            "gatekeeper-system",
+		pullSecretNamespaces: patterns,
+	}
+
+	for namespace, want := range map[string]bool{
+		"gatekeeper-system": true,
+		"team-a":            true,
+		"payments":          true,
+		"sandbox":           false,
+		"payments-dev":      false,
+	} {
+		if got := verifier.pullSecretNamespaceAllowed(namespace); got != want {
+			t.Errorf("Expected namespace %s allowed to be %v, got %v", namespace, want, got)
+		}
+	}
+
+	ctx := withKeyOptions(context.Background(), keyOptions{namespace: "sandbox"})
+	kc, err := verifier.createKeychainWithSecrets(ctx, []string{"regcred"})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+	if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user == "alice" {
+		t.Error("Expected the secret of a namespace not allowed not to be read")
+	}
+
+	verifier.pullSecretNamespaces = nil
+	if !verifier.pullSecretNamespaceAllowed("sandbox") {
+		t.Error("Expected every namespace to be allowed without patterns")
+	}
+}
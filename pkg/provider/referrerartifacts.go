@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+)
+
+// referrerSBOMArtifacts reads the SBOM documents published as OCI 1.1 referrers of subject with
+// an SPDX or CycloneDX JSON artifact type, as oras attach or BuildKit push them, newest first up
+// to the attestation cap. An artifact names subject in its manifest, so a cosign signature of
+// the artifact by a signer checkOpts accepts binds its SBOM to the image. Artifacts without an
+// accepted signature are skipped, or returned flagged unverified when unsigned SBOMs are kept
+// for inventory (see UnsignedSBOMsInventory).
+func (v *AttestationVerifier) referrerSBOMArtifacts(ctx context.Context, subject name.Digest, artifacts []v1.Descriptor, checkOpts *cosign.CheckOpts) ([]verifiedAttestation, error) {
+	artifacts = artifacts[:v.capAttestations(ctx, AttestationSourceReferrers, subject.String(), len(artifacts))]
+
+	var atts []verifiedAttestation
+	var errs []error
+	for _, desc := range artifacts {
+		artifact := subject.Context().Digest(desc.Digest.String())
+		att, err := v.referrerSBOMArtifact(ctx, subject, artifact, sbomDocumentPredicateTypes[desc.ArtifactType], checkOpts)
+		if err != nil {
+			tracef(ctx, "SBOM artifact %s: %v", artifact.DigestStr(), err)
+			errs = append(errs, fmt.Errorf("SBOM artifact %s: %w", artifact.DigestStr(), err))
+			continue
+		}
+		tracef(ctx, "SBOM artifact %s: %s document (verified: %v)", artifact.DigestStr(), desc.ArtifactType, !att.unverified)
+		atts = append(atts, att)
+	}
+	if len(atts) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		recordPartialFailures(ctx, 1, err)
+	}
+	return atts, nil
+}
+
+// referrerSBOMArtifact verifies and reads a single SBOM artifact of subject
+func (v *AttestationVerifier) referrerSBOMArtifact(ctx context.Context, subject, artifact name.Digest, predicateType string, checkOpts *cosign.CheckOpts) (verifiedAttestation, error) {
+	img, err := ociremote.SignedImage(artifact, checkOpts.RegistryClientOpts...)
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	// The referrers listing is not signed, the artifact manifest is
+	if manifest.Subject == nil || manifest.Subject.Digest.String() != subject.DigestStr() {
+		return verifiedAttestation{}, fmt.Errorf("artifact does not name %s as its subject", subject.DigestStr())
+	}
+
+	signedAt, err := v.verifyManifestSignature(ctx, []name.Digest{artifact}, checkOpts)
+	unverified := false
+	if err != nil {
+		err = fmt.Errorf("not signed by an accepted signer: %w", err)
+		if _, ok := registryAuthStatus(err); ok || v.unsignedSBOMMode(ctx) != UnsignedSBOMsInventory {
+			return verifiedAttestation{}, err
+		}
+		log.Printf("Warning: returning the unsigned SBOM artifact %s of %s for inventory: %v", artifact.DigestStr(), subject.DigestStr(), err)
+		unverified = true
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	layer, err := sbomArtifactLayer(layers)
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	doc, err := v.readSBOMDocument(layer)
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	payload, err := sbomDocumentPayload(predicateType, doc)
+	if err != nil {
+		return verifiedAttestation{}, err
+	}
+	return verifiedAttestation{payload: payload, signedAt: signedAt, unverified: unverified}, nil
+}
+
+// sbomArtifactLayer returns the layer holding the SBOM document of an artifact: its only layer,
+// whatever its media type (oras defaults to a generic one), else the one with an SBOM media type
+func sbomArtifactLayer(layers []v1.Layer) (v1.Layer, error) {
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := sbomDocumentPredicateTypes[string(mediaType)]; ok {
+			return layer, nil
+		}
+	}
+	return nil, fmt.Errorf("none of the %d layers is an SBOM document", len(layers))
+}
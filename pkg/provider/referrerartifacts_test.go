@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrstatic "github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+func TestReferrerSBOMArtifacts(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true)))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	verifier, checkOpts := staticKeyCheckOpts(t, signer)
+	fetch := func(verifier *AttestationVerifier, ref name.Reference) ([]verifiedAttestation, error) {
+		return verifier.fetchAttestations(context.Background(), AttestationSourceReferrers, ref, checkOpts, authn.DefaultKeychain)
+	}
+
+	img, _ := random.Image(256, 1)
+	image, _ := name.ParseReference(host + "/test/app:v1")
+	if err := remote.Write(image, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	digest, _ := img.Digest()
+	mediaType, _ := img.MediaType()
+	size, _ := img.Size()
+	subject := v1.Descriptor{MediaType: mediaType, Digest: digest, Size: size}
+	if _, err := fetch(verifier, image); !errors.Is(err, errNoAttestations) {
+		t.Errorf("Expected no referrers, got %v", err)
+	}
+
+	// oras attach --artifact-type application/spdx+json stores the document as its only layer
+	pushArtifact := func(artifactType, doc string) name.Digest {
+		t.Helper()
+		artifact, err := mutate.AppendLayers(empty.Image, ggcrstatic.NewLayer([]byte(doc), types.MediaType("application/vnd.oci.image.layer.v1.tar")))
+		if err != nil {
+			t.Fatalf("Failed to create artifact: %v", err)
+		}
+		artifact = mutate.ConfigMediaType(mutate.MediaType(artifact, types.OCIManifestSchema1), types.MediaType(artifactType))
+		artifact = mutate.Subject(artifact, subject).(v1.Image)
+		artifactDigest, _ := artifact.Digest()
+		ref := image.Context().Digest(artifactDigest.String())
+		if err := remote.Write(ref, artifact); err != nil {
+			t.Fatalf("Failed to push artifact: %v", err)
+		}
+		return ref
+	}
+	spdx := pushArtifact("application/spdx+json", `{"spdxVersion": "SPDX-2.3", "packages": [{"name": "busybox", "versionInfo": "1.36.1"}]}`)
+
+	if _, err := fetch(verifier, image); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("Expected an unsigned SBOM artifact to be rejected, got %v", err)
+	}
+	inventory := &AttestationVerifier{trustedRoots: verifier.trustedRoots, unsignedBuildxSBOMs: UnsignedSBOMsInventory}
+	if atts, err := fetch(inventory, image); err != nil || len(atts) != 1 || !atts[0].unverified {
+		t.Errorf("Expected the unsigned SBOM artifact flagged unverified for inventory, got %+v, %v", atts, err)
+	}
+
+	signDigest(t, spdx, signer)
+	atts, err := fetch(verifier, image)
+	if err != nil || len(atts) != 1 || atts[0].unverified {
+		t.Fatalf("Expected the signed SBOM artifact, got %+v, %v", atts, err)
+	}
+	sbom, err := verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || sbom.Format != "spdx" || len(sbom.Packages) != 1 {
+		t.Errorf("Expected the SPDX SBOM of the artifact, got %+v, %v", sbom, err)
+	}
+
+	// Signed CycloneDX artifacts are read too; other referrers are ignored
+	signDigest(t, pushArtifact("application/vnd.cyclonedx+json", `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": [{"name": "zlib", "version": "1.3"}]}`), signer)
+	pushArtifact("application/vnd.example.report+json", `{}`)
+	if atts, err := fetch(verifier, image); err != nil || len(atts) != 2 {
+		t.Errorf("Expected both SBOM artifacts, got %d, %v", len(atts), err)
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// classifyRegistryAuthError converts registry 401/403 failures into ERR_REGISTRY_AUTH errors that
+// name the credential source used and the keychains that were attempted
+func classifyRegistryAuthError(err error, ref name.Reference, keychain authn.Keychain) error {
+	if err == nil || ErrorCode(err) != "" {
+		return err
+	}
+
+	status, ok := registryAuthStatus(err)
+	if !ok {
+		return err
+	}
+
+	host := ref.Context().RegistryStr()
+	used := "unknown credentials"
+	tried := "default"
+	if kc, ok := keychain.(*sourceKeychain); ok {
+		if source, ok := kc.usedSource(host); ok {
+			used = source
+		}
+		tried = kc.names()
+	}
+
+	return &VerificationError{
+		Code: ErrCodeRegistryAuth,
+		Err: fmt.Errorf("registry %s denied access (%d %s) using %s; keychains tried: %s; check the pod's imagePullSecrets: %w",
+			host, status, http.StatusText(status), used, tried, err),
+	}
+}
+
+// registryAuthStatus reports whether err is a registry 401/403 response
+func registryAuthStatus(err error) (int, bool) {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		if terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden {
+			return terr.StatusCode, true
+		}
+		for _, diag := range terr.Errors {
+			switch diag.Code {
+			case transport.UnauthorizedErrorCode:
+				return http.StatusUnauthorized, true
+			case transport.DeniedErrorCode:
+				return http.StatusForbidden, true
+			}
+		}
+		return 0, false
+	}
+
+	// Some cosign code paths flatten the transport error into a string
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNAUTHORIZED"), strings.Contains(msg, "401 Unauthorized"):
+		return http.StatusUnauthorized, true
+	case strings.Contains(msg, "DENIED"), strings.Contains(msg, "403 Forbidden"):
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
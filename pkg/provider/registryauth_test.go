@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestClassifyRegistryAuthError(t *testing.T) {
+	ref, err := name.ParseReference("ghcr.io/myorg/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+
+	kc := newSourceKeychain(
+		namedKeychain{name: "pull secrets", keychain: authn.NewMultiKeychain()},
+		namedKeychain{name: "docker config", keychain: authn.NewMultiKeychain()},
+	)
+	// Resolving records that anonymous access was used for the host
+	if _, err := kc.Resolve(ref.Context()); err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+
+	fetchErr := fmt.Errorf("no matching attestations: %w", &transport.Error{StatusCode: http.StatusUnauthorized})
+
+	classified := classifyRegistryAuthError(fetchErr, ref, kc)
+	if ErrorCode(classified) != ErrCodeRegistryAuth {
+		t.Fatalf("Expected %s, got: %v", ErrCodeRegistryAuth, classified)
+	}
+
+	msg := classified.Error()
+	for _, want := range []string{"ghcr.io", "401", "anonymous access", "pull secrets, docker config"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected error to mention '%s', got: %s", want, msg)
+		}
+	}
+}
+
+func TestRegistryAuthStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		ok     bool
+	}{
+		{&transport.Error{StatusCode: http.StatusForbidden}, http.StatusForbidden, true},
+		{&transport.Error{StatusCode: http.StatusOK, Errors: []transport.Diagnostic{{Code: transport.DeniedErrorCode}}}, http.StatusForbidden, true},
+		{errors.New("GET https://ghcr.io/v2/: UNAUTHORIZED: authentication required"), http.StatusUnauthorized, true},
+		{&transport.Error{StatusCode: http.StatusNotFound}, 0, false},
+		{errors.New("no matching attestations"), 0, false},
+	}
+
+	for _, tt := range tests {
+		status, ok := registryAuthStatus(tt.err)
+		if status != tt.status || ok != tt.ok {
+			t.Errorf("registryAuthStatus(%v) = (%d, %v), want (%d, %v)", tt.err, status, ok, tt.status, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+)
+
+// Registry flavors, selecting how referrers are discovered on registries whose OCI 1.1 support
+// deviates from the distribution spec
+const (
+	// RegistryFlavorGeneric uses the referrers API, falling back to the referrers tag schema
+	// when the registry reports it unsupported
+	RegistryFlavorGeneric = "generic"
+	// RegistryFlavorArtifactory merges the referrers API with the referrers tag schema, as
+	// Artifactory's referrers emulation can miss referrers pushed through the tag schema and
+	// rejects the API with non-standard errors on older versions
+	RegistryFlavorArtifactory = "artifactory"
+	// RegistryFlavorQuay reads the referrers tag schema only, as Quay serves referrers through
+	// tags and rejects the referrers API unless it is explicitly enabled
+	RegistryFlavorQuay = "quay"
+)
+
+// parseRegistryFlavors parses "registry=flavor" entries, keyed by normalized registry
+func parseRegistryFlavors(specs []string) (map[string]string, error) {
+	flavors := make(map[string]string)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		registry, flavor, ok := strings.Cut(spec, "=")
+		if !ok || registry == "" {
+			return nil, fmt.Errorf("invalid registry flavor %q (expected registry=flavor)", spec)
+		}
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry %q: %w", registry, err)
+		}
+		switch flavor {
+		case RegistryFlavorGeneric, RegistryFlavorArtifactory, RegistryFlavorQuay:
+		default:
+			return nil, fmt.Errorf("registry %s: unknown flavor %q (expected %s, %s or %s)", registry, flavor,
+				RegistryFlavorGeneric, RegistryFlavorArtifactory, RegistryFlavorQuay)
+		}
+		flavors[reg.RegistryStr()] = flavor
+	}
+	return flavors, nil
+}
+
+// detectRegistryFlavor guesses the flavor of a registry from its host name: quay.io and
+// quay.* hosts are Quay, *.jfrog.io and hosts naming artifactory are Artifactory
+func detectRegistryFlavor(registry string) string {
+	host := strings.ToLower(registry)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch {
+	case host == "quay.io" || strings.HasPrefix(host, "quay."):
+		return RegistryFlavorQuay
+	case strings.HasSuffix(host, ".jfrog.io") || strings.Contains(host, "artifactory"):
+		return RegistryFlavorArtifactory
+	}
+	return RegistryFlavorGeneric
+}
+
+// registryFlavorFor returns the configured flavor of registry, else the detected one
+func (v *AttestationVerifier) registryFlavorFor(registry string) string {
+	if flavor, ok := v.registryFlavors[registry]; ok {
+		return flavor
+	}
+	return detectRegistryFlavor(registry)
+}
+
+// referrersTag is the OCI 1.1 referrers tag schema tag of digest, e.g. "sha256-<hex>"
+func referrersTag(digest name.Digest) (name.Tag, error) {
+	h, err := v1.NewHash(digest.DigestStr())
+	if err != nil {
+		return name.Tag{}, err
+	}
+	return digest.Context().Tag(h.Algorithm + "-" + h.Hex), nil
+}
+
+// taggedReferrers reads the referrers index stored under the referrers tag schema, empty when
+// the tag does not exist
+func taggedReferrers(digest name.Digest, opts ...ociremote.Option) (*v1.IndexManifest, error) {
+	tag, err := referrersTag(digest)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ociremote.SignedImageIndex(tag, opts...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return &v1.IndexManifest{}, nil
+		}
+		return nil, err
+	}
+	return index.IndexManifest()
+}
+
+// referrers lists the referrers of digest the way its registry's flavor requires
+func (v *AttestationVerifier) referrers(ctx context.Context, digest name.Digest, checkOpts *cosign.CheckOpts) (*v1.IndexManifest, error) {
+	registry := digest.Context().RegistryStr()
+	switch flavor := v.registryFlavorFor(registry); flavor {
+	case RegistryFlavorQuay:
+		tracef(ctx, "%s is %s, reading referrers from the tag schema", registry, flavor)
+		return taggedReferrers(digest, checkOpts.RegistryClientOpts...)
+
+	case RegistryFlavorArtifactory:
+		tracef(ctx, "%s is %s, merging the referrers API with the tag schema", registry, flavor)
+		api, apiErr := ociremote.Referrers(digest, "", checkOpts.RegistryClientOpts...)
+		tagged, err := taggedReferrers(digest, checkOpts.RegistryClientOpts...)
+		if apiErr != nil {
+			if err != nil {
+				return nil, apiErr
+			}
+			tracef(ctx, "referrers API of %s failed, using the tag schema: %v", registry, apiErr)
+			return tagged, nil
+		}
+		if err != nil {
+			tracef(ctx, "referrers tag schema of %s failed: %v", registry, err)
+			return api, nil
+		}
+		return mergeReferrers(api, tagged), nil
+	}
+	return ociremote.Referrers(digest, "", checkOpts.RegistryClientOpts...)
+}
+
+// mergeReferrers appends the referrers of b missing from a, by digest
+func mergeReferrers(a, b *v1.IndexManifest) *v1.IndexManifest {
+	merged := *a
+	merged.Manifests = append([]v1.Descriptor(nil), a.Manifests...)
+	seen := make(map[v1.Hash]bool, len(a.Manifests))
+	for _, desc := range a.Manifests {
+		seen[desc.Digest] = true
+	}
+	for _, desc := range b.Manifests {
+		if !seen[desc.Digest] {
+			seen[desc.Digest] = true
+			merged.Manifests = append(merged.Manifests, desc)
+		}
+	}
+	return &merged
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+func TestParseRegistryFlavors(t *testing.T) {
+	flavors, err := parseRegistryFlavors([]string{" registry.corp.example.com=artifactory", "docker.io=quay", ""})
+	if err != nil {
+		t.Fatalf("Failed to parse registry flavors: %v", err)
+	}
+	if flavors["registry.corp.example.com"] != RegistryFlavorArtifactory {
+		t.Errorf("Expected artifactory, got '%s'", flavors["registry.corp.example.com"])
+	}
+	if flavors["index.docker.io"] != RegistryFlavorQuay {
+		t.Errorf("Expected quay for index.docker.io, got '%s'", flavors["index.docker.io"])
+	}
+
+	for _, spec := range []string{"registry.corp.example.com", "=quay", "registry.corp.example.com=harbor"} {
+		if _, err := parseRegistryFlavors([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestDetectRegistryFlavor(t *testing.T) {
+	tests := map[string]string{
+		"quay.io":                       RegistryFlavorQuay,
+		"quay.corp.example.com:8443":    RegistryFlavorQuay,
+		"acme.jfrog.io":                 RegistryFlavorArtifactory,
+		"artifactory.corp.example.com":  RegistryFlavorArtifactory,
+		"docker-artifactory.corp.local": RegistryFlavorArtifactory,
+		"ghcr.io":                       RegistryFlavorGeneric,
+		"registry.quay-mirror.local":    RegistryFlavorGeneric,
+	}
+	for registry, want := range tests {
+		if got := detectRegistryFlavor(registry); got != want {
+			t.Errorf("detectRegistryFlavor(%s): expected %s, got %s", registry, want, got)
+		}
+	}
+
+	verifier := &AttestationVerifier{registryFlavors: map[string]string{"quay.io": RegistryFlavorGeneric}}
+	if got := verifier.registryFlavorFor("quay.io"); got != RegistryFlavorGeneric {
+		t.Errorf("Expected the configured flavor to win, got %s", got)
+	}
+}
+
+// pushFlavorReferrers pushes an image with one referrer through the referrers API and another
+// under the referrers tag schema only, returning the image digest and both referrer digests
+func pushFlavorReferrers(t *testing.T, host string) (name.Digest, v1.Hash, v1.Hash) {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	ref, err := name.ParseReference(host + "/test/app:v1")
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+	digest := ref.Context().Digest(h.String())
+
+	// A referrer carrying a subject, listed by the referrers API
+	subject, err := partial.Descriptor(img)
+	if err != nil {
+		t.Fatalf("Failed to describe image: %v", err)
+	}
+	apiReferrer := mutate.Subject(mutate.MediaType(empty.Image, types.OCIManifestSchema1), *subject).(v1.Image)
+	apiDigest, err := apiReferrer.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get referrer digest: %v", err)
+	}
+	if err := remote.Write(ref.Context().Digest(apiDigest.String()), apiReferrer); err != nil {
+		t.Fatalf("Failed to push referrer: %v", err)
+	}
+
+	// A referrer only listed in the referrers tag schema index
+	tagReferrer, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatalf("Failed to create referrer: %v", err)
+	}
+	tagDigest, err := tagReferrer.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get referrer digest: %v", err)
+	}
+	index := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), mutate.IndexAddendum{Add: tagReferrer})
+	tag, err := referrersTag(digest)
+	if err != nil {
+		t.Fatalf("Failed to build referrers tag: %v", err)
+	}
+	if err := remote.WriteIndex(tag, index); err != nil {
+		t.Fatalf("Failed to push referrers index: %v", err)
+	}
+	return digest, apiDigest, tagDigest
+}
+
+// referrerDigests returns the digests listed in index
+func referrerDigests(index *v1.IndexManifest) map[v1.Hash]bool {
+	digests := make(map[v1.Hash]bool)
+	for _, desc := range index.Manifests {
+		digests[desc.Digest] = true
+	}
+	return digests
+}
+
+func TestReferrersByFlavor(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true)))
+	defer reg.Close()
+	digest, apiDigest, tagDigest := pushFlavorReferrers(t, strings.TrimPrefix(reg.URL, "http://"))
+	registryStr := digest.Context().RegistryStr()
+
+	tests := []struct {
+		flavor  string
+		wantAPI bool
+		wantTag bool
+	}{
+		{RegistryFlavorGeneric, true, false},
+		{RegistryFlavorQuay, false, true},
+		{RegistryFlavorArtifactory, true, true},
+	}
+	for _, tt := range tests {
+		verifier := &AttestationVerifier{registryFlavors: map[string]string{registryStr: tt.flavor}}
+		index, err := verifier.referrers(context.Background(), digest, &cosign.CheckOpts{})
+		if err != nil {
+			t.Fatalf("%s: failed to list referrers: %v", tt.flavor, err)
+		}
+		got := referrerDigests(index)
+		if got[apiDigest] != tt.wantAPI || got[tagDigest] != tt.wantTag || len(got) != len(index.Manifests) {
+			t.Errorf("%s: expected API referrer %v and tag referrer %v, got %v", tt.flavor, tt.wantAPI, tt.wantTag, index.Manifests)
+		}
+	}
+}
+
+func TestArtifactoryReferrersAPIFailure(t *testing.T) {
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	var rejectAPI atomic.Bool
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Older Artifactory versions reject the referrers API instead of reporting it missing
+		if rejectAPI.Load() && strings.Contains(r.URL.Path, "/referrers/") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer reg.Close()
+	digest, _, tagDigest := pushFlavorReferrers(t, strings.TrimPrefix(reg.URL, "http://"))
+	registryStr := digest.Context().RegistryStr()
+	rejectAPI.Store(true)
+
+	artifactory := &AttestationVerifier{registryFlavors: map[string]string{registryStr: RegistryFlavorArtifactory}}
+	index, err := artifactory.referrers(context.Background(), digest, &cosign.CheckOpts{})
+	if err != nil {
+		t.Fatalf("Failed to list referrers: %v", err)
+	}
+	if got := referrerDigests(index); !got[tagDigest] || len(got) != 1 {
+		t.Errorf("Expected the tag schema referrer, got %v", index.Manifests)
+	}
+
+	generic := &AttestationVerifier{registryFlavors: map[string]string{registryStr: RegistryFlavorGeneric}}
+	if _, err := generic.referrers(context.Background(), digest, &cosign.CheckOpts{}); err == nil {
+		t.Error("Expected the generic flavor to fail on a rejected referrers API")
+	}
+}
+
+func TestMergeReferrers(t *testing.T) {
+	a := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)}
+	b := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("b", 64)}
+	c := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("c", 64)}
+
+	merged := mergeReferrers(
+		&v1.IndexManifest{Manifests: []v1.Descriptor{{Digest: a}, {Digest: b}}},
+		&v1.IndexManifest{Manifests: []v1.Descriptor{{Digest: b}, {Digest: c}}},
+	)
+	if len(merged.Manifests) != 3 || merged.Manifests[2].Digest != c {
+		t.Errorf("Expected a, b and c, got %v", merged.Manifests)
+	}
+}
@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-retryablehttp"
+	rekorclient "github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/util"
+	"golang.org/x/time/rate"
+)
+
+// Defaults for the client-side rate limit of Rekor calls. The public instance limits clients
+// by source address, which every replica behind the cluster's egress shares.
+const (
+	DefaultRekorQPS   = 5
+	DefaultRekorBurst = 10
+)
+
+// Retries of Rekor calls answered with 429 or a server error, and the backoff between them
+// when the response carries no Retry-After
+const (
+	rekorRetries    = 3
+	rekorMinBackoff = time.Second
+	rekorMaxBackoff = 30 * time.Second
+)
+
+// Results of Rekor calls, as counted in sbom_provider_rekor_requests_total
+const (
+	rekorResultOK          = "ok"
+	rekorResultRateLimited = "rate_limited"
+	rekorResultFailed      = "failed"
+)
+
+// rekorTransport rate limits the calls to Rekor and, once Rekor answers 429, holds back every
+// call until its Retry-After has passed, so a burst of admissions backs off together instead of
+// each request retrying into the limit
+type rekorTransport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time     // No call is sent before this time
+	backoff     time.Duration // Pause after the next 429 without Retry-After, 0 after a success
+
+	ok, rateLimited, failed atomic.Int64
+	throttled               atomic.Int64 // Nanoseconds calls waited for the limiter or a pause
+}
+
+// newRekorTransport returns a transport sending at most qps calls per second, with bursts of
+// burst, through next, or a pooled transport when nil (0 uses DefaultRekorQPS and
+// DefaultRekorBurst)
+func newRekorTransport(next http.RoundTripper, qps float64, burst int) *rekorTransport {
+	if next == nil {
+		next = cleanhttp.DefaultPooledTransport()
+	}
+	if qps <= 0 {
+		qps = DefaultRekorQPS
+	}
+	if burst <= 0 {
+		burst = DefaultRekorBurst
+	}
+	return &rekorTransport{next: next, limiter: rate.NewLimiter(rate.Limit(qps), burst)}
+}
+
+// RoundTrip waits for a pause after a 429 and for the limiter before sending req
+func (t *rekorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+	err := t.wait(ctx)
+	t.throttled.Add(int64(time.Since(start)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.failed.Add(1)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		t.rateLimited.Add(1)
+		t.pause(resp.Header.Get("Retry-After"), time.Now())
+	default:
+		t.ok.Add(1)
+		t.mu.Lock()
+		t.backoff = 0
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+// wait blocks until calls are no longer paused and the limiter admits one, failing at once when
+// ctx expires before then so admissions are not held until the webhook times out
+func (t *rekorTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	pause := time.Until(t.pausedUntil)
+	t.mu.Unlock()
+	if pause > 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < pause {
+			return fmt.Errorf("rekor is rate limiting the provider, backing off for %v", pause.Round(time.Second))
+		}
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := t.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rekor client-side rate limit: %w", err)
+	}
+	return nil
+}
+
+// pause holds back calls after a 429 for retryAfter (seconds or an HTTP date), else for an
+// exponential backoff that resets on the next success
+func (t *rekorTransport) pause(retryAfter string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delay := t.backoff
+	if delay == 0 {
+		delay = rekorMinBackoff
+	}
+	t.backoff = min(2*delay, rekorMaxBackoff)
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(retryAfter); err == nil {
+		delay = at.Sub(now)
+	}
+	delay = min(delay, rekorMaxBackoff)
+
+	if until := now.Add(delay); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+		log.Printf("Warning: rekor rate limited the provider, pausing calls for %v", delay)
+	}
+}
+
+// newRekorClient creates a Rekor client like rekor's GetRekorClient, with its calls sent
+// through transport. Retries back off following Retry-After on 429 responses.
+func newRekorClient(rekorURL string, transport *rekorTransport) (*rekorclient.Rekor, error) {
+	u, err := url.Parse(rekorURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Path == "" {
+		u.Path = rekorclient.DefaultBasePath
+	}
+
+	retryable := retryablehttp.NewClient()
+	retryable.HTTPClient = &http.Client{Transport: transport}
+	retryable.RetryMax = rekorRetries
+	retryable.RetryWaitMin = rekorMinBackoff
+	retryable.RetryWaitMax = rekorMaxBackoff
+	retryable.Logger = nil
+
+	rt := httptransport.NewWithClient(u.Host, u.Path, []string{u.Scheme}, retryable.StandardClient())
+	rt.Consumers["application/json"] = runtime.JSONConsumer()
+	rt.Consumers["application/x-pem-file"] = runtime.TextConsumer()
+	rt.Producers["application/json"] = runtime.JSONProducer()
+
+	registry := strfmt.Default
+	registry.Add("signedCheckpoint", &util.SignedNote{}, util.SignedCheckpointValidator)
+	return rekorclient.New(rt, registry), nil
+}
+
+// writeRekorMetrics writes the Rekor call counters in Prometheus text format, nothing when
+// Rekor is not called
+func (v *AttestationVerifier) writeRekorMetrics(w io.Writer) {
+	if v == nil || v.rekorTransport == nil {
+		return
+	}
+	t := v.rekorTransport
+
+	const requests = "sbom_provider_rekor_requests_total"
+	fmt.Fprintf(w, "# HELP %s Calls to the Rekor transparency log, by result.\n# TYPE %s counter\n", requests, requests)
+	fmt.Fprintf(w, "%s{result=%q} %d\n", requests, rekorResultOK, t.ok.Load())
+	fmt.Fprintf(w, "%s{result=%q} %d\n", requests, rekorResultRateLimited, t.rateLimited.Load())
+	fmt.Fprintf(w, "%s{result=%q} %d\n", requests, rekorResultFailed, t.failed.Load())
+
+	const throttled = "sbom_provider_rekor_throttled_seconds_total"
+	fmt.Fprintf(w, "# HELP %s Time Rekor calls waited for the client-side rate limit or a backoff.\n# TYPE %s counter\n", throttled, throttled)
+	fmt.Fprintf(w, "%s %g\n", throttled, time.Duration(t.throttled.Load()).Seconds())
+
+	t.mu.Lock()
+	pause := max(time.Until(t.pausedUntil), 0)
+	t.mu.Unlock()
+	const backoff = "sbom_provider_rekor_backoff_seconds"
+	fmt.Fprintf(w, "# HELP %s Remaining pause of Rekor calls after a 429 response.\n# TYPE %s gauge\n", backoff, backoff)
+	fmt.Fprintf(w, "%s %g\n", backoff, pause.Seconds())
+}
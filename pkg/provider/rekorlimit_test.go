@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRekorTransportPause(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		retryAfter string
+		backoff    time.Duration
+		want       time.Duration
+	}{
+		{"seconds", "7", 0, 7 * time.Second},
+		{"http date", now.Add(12 * time.Second).Format(http.TimeFormat), 0, 12 * time.Second},
+		{"capped", "600", 0, rekorMaxBackoff},
+		{"no header first backoff", "", 0, rekorMinBackoff},
+		{"no header doubling backoff", "", 4 * time.Second, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRekorTransport(http.DefaultTransport, 0, 0)
+			rt.backoff = tt.backoff
+			rt.pause(tt.retryAfter, now)
+			if got := rt.pausedUntil.Sub(now); got != tt.want {
+				t.Errorf("Expected a pause of %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRekorTransportRateLimited(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "20")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	rt := newRekorTransport(http.DefaultTransport, 100, 10)
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to call the server: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || rt.rateLimited.Load() != 1 {
+		t.Fatalf("Expected a counted 429, got %d and %d rate limited", resp.StatusCode, rt.rateLimited.Load())
+	}
+
+	// A call that would time out during the pause fails at once without reaching Rekor
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); err == nil || !strings.Contains(err.Error(), "rate limiting") {
+		t.Errorf("Expected the call to fail during the pause, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected no call during the pause, got %d calls", calls.Load())
+	}
+
+	// Once the pause has passed calls go through and reset the backoff
+	rt.mu.Lock()
+	rt.pausedUntil = time.Now()
+	rt.mu.Unlock()
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to call the server: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || rt.ok.Load() != 1 || rt.backoff != 0 {
+		t.Errorf("Expected a successful call resetting the backoff, got %d, %d ok and %v", resp.StatusCode, rt.ok.Load(), rt.backoff)
+	}
+}
+
+func TestWriteRekorMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	(&AttestationVerifier{}).writeRekorMetrics(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no metrics without Rekor, got %q", buf.String())
+	}
+
+	rt := newRekorTransport(http.DefaultTransport, 0, 0)
+	rt.ok.Add(3)
+	rt.rateLimited.Add(1)
+	(&AttestationVerifier{rekorTransport: rt}).writeRekorMetrics(&buf)
+	for _, want := range []string{
+		`sbom_provider_rekor_requests_total{result="ok"} 3`,
+		`sbom_provider_rekor_requests_total{result="rate_limited"} 1`,
+		`sbom_provider_rekor_requests_total{result="failed"} 0`,
+		"sbom_provider_rekor_throttled_seconds_total 0",
+		"sbom_provider_rekor_backoff_seconds 0",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the metrics, got:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/rekor/pkg/generated/client/index"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// inTotoPayloadType is the DSSE payload type used for in-toto statements
+const inTotoPayloadType = "application/vnd.in-toto+json"
+
+// rekorSignature is a signature and its signing certificate (PEM) as recorded in a log entry
+type rekorSignature struct {
+	sig     []byte
+	certPEM []byte
+}
+
+// rekorEnvelope is the signature material recorded in an intoto or dsse log entry
+type rekorEnvelope struct {
+	payloadType string
+	payloadHash string // hex-encoded sha256
+	signatures  []rekorSignature
+}
+
+// searchRekorAttestations discovers attestations for digest in the Rekor transparency log and
+// verifies them directly from the log entries. It is used when the registry holds no attestations
+// (e.g. they were stripped when mirroring) and returns the verified in-toto statements.
+func (v *AttestationVerifier) searchRekorAttestations(ctx context.Context, digest v1.Hash, checkOpts *cosign.CheckOpts) ([]verifiedAttestation, error) {
+	if v.rekorClient == nil {
+		return nil, fmt.Errorf("rekor client not configured")
+	}
+
+	params := index.NewSearchIndexParamsWithContext(ctx)
+	params.Query = &models.SearchIndex{Hash: digest.String()}
+
+	resp, err := v.rekorClient.Index.SearchIndex(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rekor index: %w", err)
+	}
+
+	uuids := resp.Payload
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("no rekor entries found for %s: %w", digest, errNoAttestations)
+	}
+	// The index does not order entries by time, so the cap keeps the first ones returned and
+	// those are verified newest first once fetched
+	uuids = uuids[:v.capAttestations(ctx, AttestationSourceRekor, digest.String(), len(uuids))]
+
+	var entries []rekorSearchEntry
+	var lastErr error
+	for _, uuid := range uuids {
+		entry, err := cosign.GetTlogEntry(ctx, v.rekorClient, uuid)
+		if err != nil {
+			lastErr = fmt.Errorf("entry %s: %w", uuid, err)
+			continue
+		}
+		entries = append(entries, rekorSearchEntry{uuid: uuid, entry: entry})
+	}
+	newestRekorEntries(entries)
+
+	var atts []verifiedAttestation
+	var entryErrs []error
+	for _, e := range entries {
+		payload, err := v.verifyRekorEntry(ctx, e.entry, digest, checkOpts)
+		if err != nil {
+			lastErr = fmt.Errorf("entry %s: %w", e.uuid, err)
+			entryErrs = append(entryErrs, lastErr)
+			continue
+		}
+		atts = append(atts, verifiedAttestation{payload: payload, signedAt: time.Unix(*e.entry.IntegratedTime, 0)})
+	}
+
+	if len(atts) == 0 {
+		return nil, fmt.Errorf("no verifiable attestations among %d rekor entries: %w", len(uuids), v.classifyTimeError(lastErr))
+	}
+
+	for _, err := range entryErrs {
+		recordPartialFailures(ctx, 1, err)
+	}
+	log.Printf("Recovered %d attestations for %s from rekor", len(atts), digest)
+	return atts, nil
+}
+
+// rekorSearchEntry is a log entry found by searching the Rekor index
+type rekorSearchEntry struct {
+	uuid  string
+	entry *models.LogEntryAnon
+}
+
+// newestRekorEntries orders log entries newest first by integration time, keeping the index
+// order of entries integrated at the same time
+func newestRekorEntries(entries []rekorSearchEntry) {
+	integrated := func(e rekorSearchEntry) int64 {
+		if e.entry.IntegratedTime == nil {
+			return 0
+		}
+		return *e.entry.IntegratedTime
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return integrated(entries[i]) > integrated(entries[j]) })
+}
+
+// verifyRekorEntry verifies a log entry's inclusion, signer certificate and DSSE signature,
+// and returns the attested in-toto statement if it covers digest.
+func (v *AttestationVerifier) verifyRekorEntry(ctx context.Context, entry *models.LogEntryAnon, digest v1.Hash, checkOpts *cosign.CheckOpts) ([]byte, error) {
+	// cosign dereferences these without checking them
+	body, ok := entry.Body.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected log entry body type %T", entry.Body)
+	}
+	if entry.IntegratedTime == nil || entry.LogIndex == nil || entry.LogID == nil {
+		return nil, fmt.Errorf("log entry has no integrated time, index or log ID")
+	}
+	if p := entry.Verification; p == nil || p.InclusionProof == nil || p.InclusionProof.RootHash == nil ||
+		p.InclusionProof.LogIndex == nil || p.InclusionProof.TreeSize == nil || p.InclusionProof.Checkpoint == nil {
+		return nil, fmt.Errorf("log entry has no inclusion proof")
+	}
+
+	if err := cosign.VerifyTLogEntryOffline(ctx, entry, checkOpts.RekorPubKeys, checkOpts.TrustedMaterial); err != nil {
+		return nil, fmt.Errorf("failed to verify log inclusion: %w", err)
+	}
+
+	if entry.Attestation == nil || len(entry.Attestation.Data) == 0 {
+		return nil, fmt.Errorf("attestation content not stored in the log")
+	}
+	payload := unwrapDSSEPayload(entry.Attestation.Data)
+
+	bodyBytes, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode log entry body: %w", err)
+	}
+
+	envelope, err := parseRekorEnvelope(bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored attestation is outside the logged body, only the payload hash binds it to the log
+	payloadHash := sha256.Sum256(payload)
+	if envelope.payloadHash == "" {
+		return nil, fmt.Errorf("log entry does not record the payload hash")
+	}
+	if envelope.payloadHash != hex.EncodeToString(payloadHash[:]) {
+		return nil, fmt.Errorf("stored attestation does not match logged payload hash")
+	}
+
+	integratedTime := time.Unix(*entry.IntegratedTime, 0)
+	if err := v.checkIntegratedTime(integratedTime); err != nil {
+		return nil, err
+	}
+	pae := dsse.PAE(envelope.payloadType, payload)
+
+	var sigErr error
+	verified := false
+	for _, s := range envelope.signatures {
+		// Entries signed with a static key carry the public key instead of a certificate
+		if checkOpts.SigVerifier != nil {
+			if err := checkOpts.SigVerifier.VerifySignature(bytes.NewReader(s.sig), bytes.NewReader(pae)); err != nil {
+				sigErr = fmt.Errorf("signature verification with the public key failed: %w", err)
+				continue
+			}
+			verified = true
+			break
+		}
+
+		certs, err := cryptoutils.UnmarshalCertificatesFromPEM(s.certPEM)
+		if err != nil || len(certs) == 0 {
+			sigErr = fmt.Errorf("log entry does not carry a signing certificate")
+			continue
+		}
+
+		sigVerifier, err := cosign.ValidateAndUnpackCert(certs[0], checkOpts)
+		if err != nil {
+			sigErr = fmt.Errorf("certificate verification failed: %w", err)
+			continue
+		}
+
+		if err := v.checkCertValidity(certs[0], integratedTime); err != nil {
+			sigErr = err
+			continue
+		}
+
+		if extensions := requiredCertExtensions(ctx); extensions != nil {
+			if err := extensions.check(certs[0]); err != nil {
+				sigErr = err
+				continue
+			}
+		}
+
+		if err := sigVerifier.VerifySignature(bytes.NewReader(s.sig), bytes.NewReader(pae)); err != nil {
+			sigErr = fmt.Errorf("signature verification failed: %w", err)
+			continue
+		}
+
+		verified = true
+		break
+	}
+
+	if !verified {
+		if sigErr == nil {
+			sigErr = fmt.Errorf("log entry has no signatures")
+		}
+		return nil, sigErr
+	}
+
+	if !statementHasSubjectDigest(payload, digest) {
+		return nil, fmt.Errorf("attestation subject does not match %s", digest)
+	}
+
+	return payload, nil
+}
+
+// parseRekorEnvelope extracts the signature material from an intoto (v0.0.2) or dsse (v0.0.1) entry body
+func parseRekorEnvelope(body []byte) (*rekorEnvelope, error) {
+	var entry struct {
+		Kind       string          `json:"kind"`
+		APIVersion string          `json:"apiVersion"`
+		Spec       json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse log entry body: %w", err)
+	}
+
+	switch {
+	case entry.Kind == "intoto" && entry.APIVersion == "0.0.2":
+		var spec models.IntotoV002Schema
+		if err := json.Unmarshal(entry.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse intoto entry: %w", err)
+		}
+		if spec.Content == nil || spec.Content.Envelope == nil {
+			return nil, fmt.Errorf("intoto entry has no envelope")
+		}
+
+		envelope := &rekorEnvelope{payloadType: inTotoPayloadType}
+		if spec.Content.Envelope.PayloadType != nil {
+			envelope.payloadType = *spec.Content.Envelope.PayloadType
+		}
+		if spec.Content.PayloadHash != nil && spec.Content.PayloadHash.Value != nil {
+			envelope.payloadHash = *spec.Content.PayloadHash.Value
+		}
+		for _, s := range spec.Content.Envelope.Signatures {
+			if s == nil || s.Sig == nil || s.PublicKey == nil {
+				continue
+			}
+			// The envelope signature is itself base64 text inside the base64-encoded field
+			sig, err := base64.StdEncoding.DecodeString(string(*s.Sig))
+			if err != nil {
+				sig = *s.Sig
+			}
+			envelope.signatures = append(envelope.signatures, rekorSignature{sig: sig, certPEM: *s.PublicKey})
+		}
+		return envelope, nil
+
+	case entry.Kind == "dsse" && entry.APIVersion == "0.0.1":
+		var spec models.DSSEV001Schema
+		if err := json.Unmarshal(entry.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse dsse entry: %w", err)
+		}
+
+		envelope := &rekorEnvelope{payloadType: inTotoPayloadType}
+		if spec.PayloadHash != nil && spec.PayloadHash.Value != nil {
+			envelope.payloadHash = *spec.PayloadHash.Value
+		}
+		for _, s := range spec.Signatures {
+			if s == nil || s.Signature == nil || s.Verifier == nil {
+				continue
+			}
+			sig, err := base64.StdEncoding.DecodeString(*s.Signature)
+			if err != nil {
+				continue
+			}
+			envelope.signatures = append(envelope.signatures, rekorSignature{sig: sig, certPEM: *s.Verifier})
+		}
+		return envelope, nil
+	}
+
+	return nil, fmt.Errorf("unsupported log entry type %s/%s", entry.Kind, entry.APIVersion)
+}
+
+// unwrapDSSEPayload returns the decoded payload if data is a DSSE envelope, or data unchanged
+func unwrapDSSEPayload(data []byte) []byte {
+	var envelope struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Payload != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(envelope.Payload); err == nil {
+			return decoded
+		}
+	}
+	return data
+}
+
+// statementHasSubjectDigest reports whether the in-toto statement lists digest as a subject
+func statementHasSubjectDigest(payload []byte, digest v1.Hash) bool {
+	var statement struct {
+		Subject []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return false
+	}
+
+	for _, subject := range statement.Subject {
+		if subject.Digest[digest.Algorithm] == digest.Hex {
+			return true
+		}
+	}
+	return false
+}
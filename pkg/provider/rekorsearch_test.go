@@ -0,0 +1,387 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseRekorEnvelope_Intoto(t *testing.T) {
+	rawSig := []byte("raw-signature")
+	body := map[string]interface{}{
+		"kind":       "intoto",
+		"apiVersion": "0.0.2",
+		"spec": map[string]interface{}{
+			"content": map[string]interface{}{
+				"envelope": map[string]interface{}{
+					"payloadType": "application/vnd.in-toto+json",
+					"signatures": []map[string]interface{}{
+						{
+							// Double-encoded as stored by Rekor
+							"sig":       base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString(rawSig))),
+							"publicKey": base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----")),
+						},
+					},
+				},
+				"payloadHash": map[string]interface{}{
+					"algorithm": "sha256",
+					"value":     "abc123",
+				},
+			},
+		},
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	envelope, err := parseRekorEnvelope(bodyJSON)
+	if err != nil {
+		t.Fatalf("Failed to parse envelope: %v", err)
+	}
+
+	if envelope.payloadHash != "abc123" {
+		t.Errorf("Expected payload hash 'abc123', got '%s'", envelope.payloadHash)
+	}
+
+	if len(envelope.signatures) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(envelope.signatures))
+	}
+
+	if string(envelope.signatures[0].sig) != string(rawSig) {
+		t.Errorf("Expected raw signature, got '%s'", envelope.signatures[0].sig)
+	}
+
+	if string(envelope.signatures[0].certPEM) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Unexpected certificate: '%s'", envelope.signatures[0].certPEM)
+	}
+}
+
+func TestParseRekorEnvelope_DSSE(t *testing.T) {
+	body := map[string]interface{}{
+		"kind":       "dsse",
+		"apiVersion": "0.0.1",
+		"spec": map[string]interface{}{
+			"payloadHash": map[string]interface{}{"algorithm": "sha256", "value": "def456"},
+			"signatures": []map[string]interface{}{
+				{
+					"signature": base64.StdEncoding.EncodeToString([]byte("sig")),
+					"verifier":  base64.StdEncoding.EncodeToString([]byte("pem")),
+				},
+			},
+		},
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	envelope, err := parseRekorEnvelope(bodyJSON)
+	if err != nil {
+		t.Fatalf("Failed to parse envelope: %v", err)
+	}
+
+	if envelope.payloadType != inTotoPayloadType {
+		t.Errorf("Expected payload type '%s', got '%s'", inTotoPayloadType, envelope.payloadType)
+	}
+
+	if len(envelope.signatures) != 1 || string(envelope.signatures[0].sig) != "sig" {
+		t.Errorf("Unexpected signatures: %+v", envelope.signatures)
+	}
+}
+
+func TestParseRekorEnvelope_Unsupported(t *testing.T) {
+	if _, err := parseRekorEnvelope([]byte(`{"kind":"hashedrekord","apiVersion":"0.0.1","spec":{}}`)); err == nil {
+		t.Error("Expected error for unsupported entry kind")
+	}
+}
+
+func TestStatementHasSubjectDigest(t *testing.T) {
+	digest, err := v1.NewHash(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to parse digest: %v", err)
+	}
+
+	statement := []byte(`{"subject":[{"name":"ghcr.io/myorg/app","digest":{"sha256":"` + digest.Hex + `"}}]}`)
+	if !statementHasSubjectDigest(statement, digest) {
+		t.Error("Expected subject digest to match")
+	}
+
+	other := []byte(`{"subject":[{"name":"ghcr.io/myorg/app","digest":{"sha256":"ffff"}}]}`)
+	if statementHasSubjectDigest(other, digest) {
+		t.Error("Expected subject digest mismatch")
+	}
+}
+
+func TestUnwrapDSSEPayload(t *testing.T) {
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)
+	envelope := []byte(`{"payload":"` + base64.StdEncoding.EncodeToString(statement) + `"}`)
+
+	if got := unwrapDSSEPayload(envelope); string(got) != string(statement) {
+		t.Errorf("Expected unwrapped statement, got '%s'", got)
+	}
+
+	if got := unwrapDSSEPayload(statement); string(got) != string(statement) {
+		t.Errorf("Expected plain statement unchanged, got '%s'", got)
+	}
+}
+
+func TestNewestRekorEntries(t *testing.T) {
+	newEntry := func(uuid string, integrated int64) rekorSearchEntry {
+		entry := &models.LogEntryAnon{}
+		if integrated > 0 {
+			entry.IntegratedTime = &integrated
+		}
+		return rekorSearchEntry{uuid: uuid, entry: entry}
+	}
+
+	entries := []rekorSearchEntry{newEntry("a", 100), newEntry("b", 300), newEntry("c", 0), newEntry("d", 300), newEntry("e", 200)}
+	newestRekorEntries(entries)
+	var got string
+	for _, e := range entries {
+		got += e.uuid
+	}
+	if got != "bdeac" {
+		t.Errorf("Expected newest first order bdeac, got %s", got)
+	}
+}
+
+// rekorDSSEEntry returns a dsse log entry of statement signed by signer, whose public key or
+// certificate is verifierPEM, logged by rekorKey as the only entry of its tree. An empty
+// payloadHash is left out of the entry body.
+func rekorDSSEEntry(t *testing.T, rekorKey *ecdsa.PrivateKey, signer signature.Signer, verifierPEM, statement []byte, payloadHash string) *models.LogEntryAnon {
+	t.Helper()
+	sig, err := signer.SignMessage(bytes.NewReader(dsse.PAE(inTotoPayloadType, statement)))
+	if err != nil {
+		t.Fatalf("Failed to sign statement: %v", err)
+	}
+	envelopeHash := sha256.Sum256(sig)
+	spec := map[string]interface{}{
+		"envelopeHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(envelopeHash[:])},
+		"signatures": []map[string]interface{}{{
+			"signature": base64.StdEncoding.EncodeToString(sig),
+			"verifier":  base64.StdEncoding.EncodeToString(verifierPEM),
+		}},
+	}
+	if payloadHash != "" {
+		spec["payloadHash"] = map[string]string{"algorithm": "sha256", "value": payloadHash}
+	}
+	body, err := json.Marshal(map[string]interface{}{"kind": "dsse", "apiVersion": "0.0.1", "spec": spec})
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+
+	der, err := cryptoutils.MarshalPublicKeyToDER(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	logID := sha256.Sum256(der)
+	payload := bundle.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: time.Now().Add(-time.Minute).Unix(),
+		LogID:          hex.EncodeToString(logID[:]),
+	}
+	// The root of a single entry tree is the hash of its leaf
+	leaf := sha256.Sum256(append([]byte{0}, body...))
+	rootHash := hex.EncodeToString(leaf[:])
+	var treeSize int64 = 1
+	checkpoint := ""
+
+	return &models.LogEntryAnon{
+		Body:           payload.Body,
+		IntegratedTime: &payload.IntegratedTime,
+		LogIndex:       &payload.LogIndex,
+		LogID:          &payload.LogID,
+		Attestation:    &models.LogEntryAnonAttestation{Data: statement},
+		Verification: &models.LogEntryAnonVerification{
+			SignedEntryTimestamp: signedEntryTimestamp(t, rekorKey, payload),
+			InclusionProof: &models.InclusionProof{
+				RootHash:   &rootHash,
+				LogIndex:   &payload.LogIndex,
+				TreeSize:   &treeSize,
+				Hashes:     []string{},
+				Checkpoint: &checkpoint,
+			},
+		},
+	}
+}
+
+// rekorSearchTestRoot returns a trusted root holding the public key of a new Rekor key and
+// the Fulcio certificates at fulcioRoots, if set
+func rekorSearchTestRoot(t *testing.T, fulcioRoots string) (*ecdsa.PrivateKey, *cosign.CheckOpts) {
+	t.Helper()
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rekorPEM, err := cryptoutils.MarshalPublicKeyToPEM(rekorKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal Rekor key: %v", err)
+	}
+	rekorPub := filepath.Join(t.TempDir(), "rekor.pub")
+	if err := os.WriteFile(rekorPub, rekorPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write Rekor key: %v", err)
+	}
+	trustedRoot, err := loadCustomTrustedRoot(&CustomTrustedRoot{FulcioRoots: fulcioRoots, RekorPublicKeys: []string{rekorPub}, RekorURL: "https://rekor.invalid"})
+	if err != nil {
+		t.Fatalf("Failed to load trusted root: %v", err)
+	}
+	return rekorKey, &cosign.CheckOpts{TrustedMaterial: trustedRoot, IgnoreSCT: true, Offline: true}
+}
+
+func TestVerifyRekorEntry(t *testing.T) {
+	rekorKey, checkOpts := rekorSearchTestRoot(t, "")
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	checkOpts.SigVerifier = signer
+	pubPEM, err := cryptoutils.MarshalPublicKeyToPEM(signingKey.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+
+	digest, err := v1.NewHash(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to parse digest: %v", err)
+	}
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://spdx.dev/Document","subject":[{"name":"app","digest":{"sha256":"` + digest.Hex + `"}}],"predicate":{}}`)
+	hash := sha256.Sum256(statement)
+
+	verifier := &AttestationVerifier{}
+	entry := rekorDSSEEntry(t, rekorKey, signer, pubPEM, statement, hex.EncodeToString(hash[:]))
+	if payload, err := verifier.verifyRekorEntry(context.Background(), entry, digest, checkOpts); err != nil || !bytes.Equal(payload, statement) {
+		t.Fatalf("Expected the logged statement to verify, got %v", err)
+	}
+
+	// The stored attestation is only bound to the log by the payload hash
+	tampered := []byte(strings.Replace(string(statement), `"predicate":{}`, `"predicate":{"name":"x"}`, 1))
+	entry = rekorDSSEEntry(t, rekorKey, signer, pubPEM, tampered, hex.EncodeToString(hash[:]))
+	if _, err := verifier.verifyRekorEntry(context.Background(), entry, digest, checkOpts); err == nil || !strings.Contains(err.Error(), "payload hash") {
+		t.Errorf("Expected a stored attestation not matching the payload hash to fail, got %v", err)
+	}
+	entry = rekorDSSEEntry(t, rekorKey, signer, pubPEM, statement, "")
+	if _, err := verifier.verifyRekorEntry(context.Background(), entry, digest, checkOpts); err == nil {
+		t.Error("Expected an entry without a payload hash to fail")
+	}
+
+	// Incomplete entries fail instead of panicking
+	entry = rekorDSSEEntry(t, rekorKey, signer, pubPEM, statement, hex.EncodeToString(hash[:]))
+	entry.IntegratedTime = nil
+	if _, err := verifier.verifyRekorEntry(context.Background(), entry, digest, checkOpts); err == nil {
+		t.Error("Expected an entry without an integrated time to fail")
+	}
+	entry = rekorDSSEEntry(t, rekorKey, signer, pubPEM, statement, hex.EncodeToString(hash[:]))
+	entry.Verification.InclusionProof.Checkpoint = nil
+	if _, err := verifier.verifyRekorEntry(context.Background(), entry, digest, checkOpts); err == nil {
+		t.Error("Expected an entry without a checkpoint to fail")
+	}
+}
+
+func TestVerifyRekorEntryCertExtensions(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio-root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	fulcioRoots := filepath.Join(t.TempDir(), "fulcio.pem")
+	if err := os.WriteFile(fulcioRoots, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificates: %v", err)
+	}
+	rekorKey, checkOpts := rekorSearchTestRoot(t, fulcioRoots)
+
+	// A keyless signing certificate issued for a workflow on a feature branch
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses: []string{"dev@example.com"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: certificate.OIDGitHubWorkflowRepository, Value: []byte("myorg/app")},
+			{Id: certificate.OIDGitHubWorkflowRef, Value: []byte("refs/heads/feature")},
+		},
+	}, ca, signingKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	signer, err := signature.LoadECDSASignerVerifier(signingKey, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+
+	digest, err := v1.NewHash(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to parse digest: %v", err)
+	}
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://spdx.dev/Document","subject":[{"name":"app","digest":{"sha256":"` + digest.Hex + `"}}],"predicate":{}}`)
+	hash := sha256.Sum256(statement)
+	entry := rekorDSSEEntry(t, rekorKey, signer, leafPEM, statement, hex.EncodeToString(hash[:]))
+
+	verifier := &AttestationVerifier{}
+	ctx := withRequiredCertExtensions(context.Background(), &CertExtensions{GithubWorkflowRepository: "myorg/app"})
+	if _, err := verifier.verifyRekorEntry(ctx, entry, digest, checkOpts); err != nil {
+		t.Fatalf("Expected a certificate with the required extensions to verify, got %v", err)
+	}
+
+	ctx = withRequiredCertExtensions(context.Background(), &CertExtensions{GithubWorkflowRepository: "myorg/app", GithubWorkflowRef: "refs/heads/main"})
+	_, err = verifier.verifyRekorEntry(ctx, entry, digest, checkOpts)
+	if err == nil || !strings.Contains(err.Error(), certExtensionMismatchMarker) {
+		t.Errorf("Expected a certificate extension mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// DefaultRepositoryPolicyTTL is how long a discovered repository policy is reused
+const DefaultRepositoryPolicyTTL = 5 * time.Minute
+
+// maxRepositoryPolicySize bounds the policy.json read from a repository
+const maxRepositoryPolicySize = 1 << 20
+
+// RepositoryPolicy is the policy.json a repository publishes under the repository policy tag,
+// declaring the signers of its images so constraints need no identity configuration
+type RepositoryPolicy struct {
+	Identities     []AllowedIdentity `json:"identities"`
+	CertExtensions *CertExtensions   `json:"certExtensions,omitempty"`
+}
+
+// discoveredPolicy is the repository policy of a repository, nil when it publishes none
+type discoveredPolicy struct {
+	policy    *RepositoryPolicy
+	digest    string // Digest reference of the policy artifact
+	fetchedAt time.Time
+}
+
+// repositoryPolicyStore discovers the policies repositories publish under a tag and reuses
+// them for a TTL. Policies must be signed by the configured signer, so pushing to a repository
+// is not enough to change who may sign its images.
+type repositoryPolicyStore struct {
+	tag    string
+	signer AllowedIdentity
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	policies map[string]*discoveredPolicy
+}
+
+// newRepositoryPolicyStore creates a store for policies published under tag and signed by
+// signer (ttl 0 uses DefaultRepositoryPolicyTTL)
+func newRepositoryPolicyStore(tag string, signer AllowedIdentity, ttl time.Duration) (*repositoryPolicyStore, error) {
+	if _, err := name.NewTag("example.com/repository:" + tag); err != nil {
+		return nil, fmt.Errorf("invalid repository policy tag %q: %w", tag, err)
+	}
+	if signer.CertIdentity == "" && signer.CertIdentityRegexp == "" {
+		return nil, fmt.Errorf("repository policies require the identity of their signer")
+	}
+	if _, err := certIdentityFor(signer.CertIdentity, signer.CertIdentityRegexp, signer.CertOidcIssuer); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultRepositoryPolicyTTL
+	}
+	return &repositoryPolicyStore{tag: tag, signer: signer, ttl: ttl, now: time.Now, policies: make(map[string]*discoveredPolicy)}, nil
+}
+
+// Get returns the policy of repo, loading it when not cached or expired. A failed refresh
+// keeps using the previous policy; a repository whose policy cannot be loaded at all fails.
+func (s *repositoryPolicyStore) Get(repo string, load func() (*RepositoryPolicy, string, error)) (*RepositoryPolicy, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached := s.policies[repo]
+	if cached != nil && s.now().Sub(cached.fetchedAt) < s.ttl {
+		return cached.policy, cached.digest, nil
+	}
+
+	policy, digest, err := load()
+	if err != nil {
+		if cached != nil {
+			log.Printf("Warning: failed to refresh the repository policy of %s, keeping the cached policy: %v", repo, err)
+			return cached.policy, cached.digest, nil
+		}
+		return nil, "", newVerificationError(ErrCodeRepositoryPolicy, "failed to load the repository policy of %s: %w", repo, err)
+	}
+
+	if cached != nil && cached.digest != digest {
+		log.Printf("Repository policy of %s changed to %q", repo, digest)
+	}
+	s.policies[repo] = &discoveredPolicy{policy: policy, digest: digest, fetchedAt: s.now()}
+	return policy, digest, nil
+}
+
+// describe returns the discovery settings for the policy hash
+func (s *repositoryPolicyStore) describe() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join([]string{s.tag, s.signer.CertIdentity, s.signer.CertIdentityRegexp, normalizeIssuer(s.signer.CertOidcIssuer)}, "|")
+}
+
+// parseRepositoryPolicy parses a policy.json. Unknown fields are rejected, as a misspelled
+// field would otherwise go unchecked, and at least one identity is required.
+func parseRepositoryPolicy(data []byte) (*RepositoryPolicy, error) {
+	var policy RepositoryPolicy
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid repository policy: %w", err)
+	}
+	if len(policy.Identities) == 0 {
+		return nil, fmt.Errorf("invalid repository policy: no identities")
+	}
+	if err := validateAllowedIdentities(policy.Identities); err != nil {
+		return nil, fmt.Errorf("invalid repository policy: %w", err)
+	}
+	if _, err := cosignIdentities(policy.Identities); err != nil {
+		return nil, fmt.Errorf("invalid repository policy: %w", err)
+	}
+	if policy.CertExtensions != nil && *policy.CertExtensions == (CertExtensions{}) {
+		policy.CertExtensions = nil
+	}
+	return &policy, nil
+}
+
+// repositoryPolicy returns the policy repo publishes, or nil when it publishes none. The
+// policy artifact must carry a cosign signature of the policy signer, verified like
+// attestations against checkOpts.
+func (v *AttestationVerifier) repositoryPolicy(ctx context.Context, repo name.Repository, keychain authn.Keychain, checkOpts *cosign.CheckOpts) (*RepositoryPolicy, string, error) {
+	return v.repositoryPolicies.Get(repo.Name(), func() (*RepositoryPolicy, string, error) {
+		tag := repo.Tag(v.repositoryPolicies.tag)
+		desc, err := remote.Get(tag, v.remoteOptions(ctx, keychain)...)
+		if err != nil {
+			var terr *transport.Error
+			if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+				return nil, "", nil
+			}
+			return nil, "", classifyRegistryAuthError(err, tag, keychain)
+		}
+		digest := repo.Digest(desc.Digest.String())
+
+		img, err := desc.Image()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s is not a policy artifact: %w", tag, err)
+		}
+		layers, err := img.Layers()
+		if err != nil || len(layers) == 0 {
+			return nil, "", fmt.Errorf("%s holds no policy.json", tag)
+		}
+		blob, err := layers[0].Compressed()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch %s: %w", tag, err)
+		}
+		defer blob.Close()
+		data, err := io.ReadAll(io.LimitReader(blob, maxRepositoryPolicySize+1))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %w", tag, err)
+		}
+		if len(data) > maxRepositoryPolicySize {
+			return nil, "", fmt.Errorf("%s exceeds %d bytes", tag, maxRepositoryPolicySize)
+		}
+
+		signer, err := cosignIdentities([]AllowedIdentity{v.repositoryPolicies.signer})
+		if err != nil {
+			return nil, "", err
+		}
+		opts := *checkOpts
+		opts.ClaimVerifier = cosign.SimpleClaimVerifier
+		opts.Identities = signer
+		err = v.verifyWithTrustedRoots(&opts, func(opts *cosign.CheckOpts) error {
+			_, _, err := cosign.VerifyImageSignatures(ctx, digest, opts)
+			return err
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("%s is not signed by the repository policy signer: %w", tag, err)
+		}
+
+		policy, err := parseRepositoryPolicy(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", tag, err)
+		}
+		return policy, digest.String(), nil
+	})
+}
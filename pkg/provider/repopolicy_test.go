@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+var testPolicySigner = AllowedIdentity{CertIdentity: "https://github.com/myorg/policies/.github/workflows/sign.yml@refs/heads/main", CertOidcIssuer: "github-actions"}
+
+func TestParseRepositoryPolicy(t *testing.T) {
+	policy, err := parseRepositoryPolicy([]byte(`{"identities":[{"certIdentity":"ci@myorg.com","certOidcIssuer":"google"}],"certExtensions":{"runnerEnvironment":"github-hosted"}}`))
+	if err != nil {
+		t.Fatalf("Expected a valid policy, got %v", err)
+	}
+	if len(policy.Identities) != 1 || policy.Identities[0].CertIdentity != "ci@myorg.com" {
+		t.Errorf("Expected one identity, got %+v", policy.Identities)
+	}
+	if policy.CertExtensions == nil || policy.CertExtensions.RunnerEnvironment != "github-hosted" {
+		t.Errorf("Expected the runner environment extension, got %+v", policy.CertExtensions)
+	}
+
+	policy, err = parseRepositoryPolicy([]byte(`{"identities":[{"certIdentity":"ci@myorg.com"}],"certExtensions":{}}`))
+	if err != nil || policy.CertExtensions != nil {
+		t.Errorf("Expected empty extensions to require nothing, got %+v, %v", policy, err)
+	}
+
+	for name, data := range map[string]string{
+		"no identities":   `{"identities":[]}`,
+		"empty identity":  `{"identities":[{}]}`,
+		"invalid pattern": `{"identities":[{"certIdentityRegexp":"(["}]}`,
+		"unknown field":   `{"identities":[{"certIdentity":"ci@myorg.com"}],"signers":[]}`,
+		"not json":        `identities: []`,
+	} {
+		if _, err := parseRepositoryPolicy([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestNewRepositoryPolicyStore(t *testing.T) {
+	store, err := newRepositoryPolicyStore("sbom-policy", testPolicySigner, 0)
+	if err != nil {
+		t.Fatalf("Expected a store, got %v", err)
+	}
+	if store.ttl != DefaultRepositoryPolicyTTL {
+		t.Errorf("Expected the default TTL, got %v", store.ttl)
+	}
+
+	if _, err := newRepositoryPolicyStore("not a tag", testPolicySigner, 0); err == nil {
+		t.Error("Expected an error for an invalid tag")
+	}
+	if _, err := newRepositoryPolicyStore("sbom-policy", AllowedIdentity{CertOidcIssuer: "github-actions"}, 0); err == nil {
+		t.Error("Expected an error for a signer without identity")
+	}
+	if _, err := newRepositoryPolicyStore("sbom-policy", AllowedIdentity{CertIdentityRegexp: "(["}, 0); err == nil {
+		t.Error("Expected an error for an invalid signer pattern")
+	}
+}
+
+func TestRepositoryPolicyStoreGet(t *testing.T) {
+	store, err := newRepositoryPolicyStore("sbom-policy", testPolicySigner, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	loads := 0
+	policy := &RepositoryPolicy{Identities: []AllowedIdentity{{CertIdentity: "ci@myorg.com"}}}
+	load := func() (*RepositoryPolicy, string, error) {
+		loads++
+		return policy, "ghcr.io/org/app@" + testDigest, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, digest, err := store.Get("ghcr.io/org/app", load)
+		if err != nil || got != policy || digest != "ghcr.io/org/app@"+testDigest {
+			t.Fatalf("Expected the policy, got %+v, %q, %v", got, digest, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected the policy to be loaded once, got %d loads", loads)
+	}
+
+	// A failed refresh keeps the cached policy
+	now = now.Add(2 * time.Minute)
+	got, _, err := store.Get("ghcr.io/org/app", func() (*RepositoryPolicy, string, error) {
+		return nil, "", errors.New("registry unavailable")
+	})
+	if err != nil || got != policy {
+		t.Errorf("Expected the cached policy after a failed refresh, got %+v, %v", got, err)
+	}
+
+	// Repositories without a policy are cached too
+	loads = 0
+	for i := 0; i < 2; i++ {
+		got, digest, err := store.Get("ghcr.io/org/other", func() (*RepositoryPolicy, string, error) {
+			loads++
+			return nil, "", nil
+		})
+		if err != nil || got != nil || digest != "" {
+			t.Errorf("Expected no policy, got %+v, %q, %v", got, digest, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected the missing policy to be loaded once, got %d loads", loads)
+	}
+
+	_, _, err = store.Get("ghcr.io/org/broken", func() (*RepositoryPolicy, string, error) {
+		return nil, "", errors.New("registry unavailable")
+	})
+	if ErrorCode(err) != ErrCodeRepositoryPolicy {
+		t.Errorf("Expected %s, got %v", ErrCodeRepositoryPolicy, err)
+	}
+}
+
+func TestRepositoryPolicyStoreDescribe(t *testing.T) {
+	var store *repositoryPolicyStore
+	if got := store.describe(); got != "" {
+		t.Errorf("Expected no description without discovery, got %q", got)
+	}
+
+	store, err := newRepositoryPolicyStore("sbom-policy", testPolicySigner, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	other, err := newRepositoryPolicyStore("sbom-policy", AllowedIdentity{CertIdentity: "someone@example.com", CertOidcIssuer: "github-actions"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if store.describe() == other.describe() {
+		t.Error("Expected different signers to describe differently")
+	}
+}
+
+func TestRepositoryPolicyDiscovery(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	store, err := newRepositoryPolicyStore("sbom-policy", testPolicySigner, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	v := &AttestationVerifier{keychain: authn.DefaultKeychain, repositoryPolicies: store}
+
+	// Repositories without the policy tag publish no policy
+	repo, _ := name.NewRepository(host + "/test/app")
+	policy, digest, err := v.repositoryPolicy(context.Background(), repo, authn.DefaultKeychain, &cosign.CheckOpts{})
+	if err != nil || policy != nil || digest != "" {
+		t.Errorf("Expected no policy, got %+v, %q, %v", policy, digest, err)
+	}
+
+	// A policy without a signature of the policy signer fails closed
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer([]byte(`{"identities":[{"certIdentity":"ci@myorg.com"}]}`), types.MediaType("application/json")))
+	if err != nil {
+		t.Fatalf("Failed to create policy artifact: %v", err)
+	}
+	unsigned, _ := name.NewRepository(host + "/test/unsigned")
+	if err := remote.Write(unsigned.Tag("sbom-policy"), img); err != nil {
+		t.Fatalf("Failed to push policy: %v", err)
+	}
+	if _, _, err := v.repositoryPolicy(context.Background(), unsigned, authn.DefaultKeychain, &cosign.CheckOpts{}); ErrorCode(err) != ErrCodeRepositoryPolicy {
+		t.Errorf("Expected %s for an unsigned policy, got %v", ErrCodeRepositoryPolicy, err)
+	}
+}
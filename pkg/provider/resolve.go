@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DefaultDigestCacheTTL is how long a tag resolved by /resolve is reused. Tags move, so it is
+// much shorter than the usual verification cache TTL.
+const DefaultDigestCacheTTL = time.Minute
+
+// Outcomes of /resolve keys, by result
+var digestResolutions struct {
+	cached, resolved, failed atomic.Int64
+}
+
+// ResolveDigest returns the digest reference imageRef points to, e.g.
+// "ghcr.io/org/app@sha256:...", without verifying anything. Digest references are returned as is.
+func (v *AttestationVerifier) ResolveDigest(ctx context.Context, imageRef string, secretNames []string) (string, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image reference: %w", err)
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return d.String(), nil
+	}
+
+	keychain, err := v.createKeychainWithSecrets(ctx, secretNames)
+	if err != nil {
+		log.Printf("Warning: Failed to create keychain with secrets: %v, using default", err)
+		keychain = v.keychain
+	}
+
+	digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return "", classifyRegistryAuthError(err, ref, keychain)
+	}
+	return ref.Context().Digest(digest.String()).String(), nil
+}
+
+// pinDigest resolves a tag reference to its digest with a HEAD request, returning the digest
+// reference to verify and, for tags, its string form. Digest references are returned as is.
+func (v *AttestationVerifier) pinDigest(ctx context.Context, ref name.Reference, keychain authn.Keychain) (name.Reference, string, error) {
+	if _, ok := ref.(name.Digest); ok {
+		return ref, "", nil
+	}
+	digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return nil, "", classifyRegistryAuthError(err, ref, keychain)
+	}
+	pinned := ref.Context().Digest(digest.String())
+	tracef(ctx, "resolved %s to %s", ref, pinned.DigestStr())
+	return pinned, pinned.String(), nil
+}
+
+// handleResolve resolves image tags to digests for templates that only pin images by digest,
+// without verifying attestations. It takes the keys of /verify; only the image and secrets
+// segments are used.
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	s.serveDigests(w, r, "Resolved", s.resolveDigestKey)
+}
+
+// serveDigests answers an external data request by mapping each key through resolve, logging
+// the outcome with verb. Tags move, so responses are never marked idempotent.
+func (s *Server) serveDigests(w http.ResponseWriter, r *http.Request, verb string, resolve func(context.Context, string) Item) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var providerReq ProviderRequest
+	if err := json.Unmarshal(body, &providerReq); err != nil {
+		log.Printf("Error parsing request: %v", err)
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	keys := requestKeys(providerReq.Request.Keys)
+	items := make([]Item, 0, len(keys))
+	errorCount := 0
+	for _, key := range keys {
+		item := resolve(r.Context(), key)
+		if item.Error != "" {
+			errorCount++
+			s.logs.Printf("Error resolving %s: %s", item.Key, item.Error)
+		}
+		items = append(items, item)
+	}
+	log.Printf("%s %d images (%d errors)", verb, len(items), errorCount)
+
+	response := ProviderResponse{
+		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
+		Kind:       "ProviderResponse",
+		Response:   Response{Items: items},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// resolveDigestKey resolves the image of a provider key to its digest reference. Digests are
+// cached per image and secrets, so callers without the pull secrets never see a digest resolved
+// with them.
+func (s *Server) resolveDigestKey(ctx context.Context, key string) Item {
+	imageKey, opts, err := parseKey(key)
+	if err != nil {
+		return Item{Key: key, Error: formatItemError("Invalid provider key", err)}
+	}
+	parts := strings.SplitN(imageKey, "|", 3)
+	cacheKey := strings.Join(parts[:min(len(parts), 2)], "|")
+	if opts.namespace != "" {
+		// Secrets of the same name in another namespace may hold other credentials
+		cacheKey += "|" + opts.namespace
+	}
+
+	if item, ok := s.digests.Get(cacheKey); ok {
+		digestResolutions.cached.Add(1)
+		item.Key = key
+		return item
+	}
+
+	var secretNames []string
+	if len(parts) >= 2 && parts[1] != "" {
+		if err := json.Unmarshal([]byte(parts[1]), &secretNames); err != nil {
+			log.Printf("Warning: Failed to parse imagePullSecrets from key: %v, using default keychain", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(withKeyOptions(ctx, keyOptions{namespace: opts.namespace}), s.timeout)
+	defer cancel()
+	digest, err := s.verifier.ResolveDigest(ctx, parts[0], secretNames)
+	if err != nil {
+		digestResolutions.failed.Add(1)
+		return Item{Key: key, Error: formatItemError("Failed to resolve image digest", err)}
+	}
+
+	digestResolutions.resolved.Add(1)
+	item := Item{Key: key, Value: digest}
+	s.digests.Set(cacheKey, item, s.digestCacheTTL)
+	return item
+}
+
+// writeResolveMetrics writes the /resolve counters in Prometheus text format
+func writeResolveMetrics(w io.Writer) {
+	const name = "sbom_provider_digest_resolutions_total"
+	fmt.Fprintf(w, "# HELP %s Image keys resolved to digests by /resolve, by result.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{result=\"cached\"} %d\n", name, digestResolutions.cached.Load())
+	fmt.Fprintf(w, "%s{result=\"resolved\"} %d\n", name, digestResolutions.resolved.Load())
+	fmt.Fprintf(w, "%s{result=\"failed\"} %d\n", name, digestResolutions.failed.Load())
+}
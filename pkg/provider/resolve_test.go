@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// pushRandomImage pushes a random image to ref and returns its digest
+func pushRandomImage(t *testing.T, ref string) string {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	tag, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("Failed to push image: %v", err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		t.Fatalf("Failed to get image digest: %v", err)
+	}
+	return h.String()
+}
+
+func TestResolveDigestKey(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	first := pushRandomImage(t, host+"/test/app:v1")
+	server := &Server{
+		verifier:       &AttestationVerifier{keychain: authn.DefaultKeychain},
+		timeout:        5 * time.Second,
+		digests:        newResultCache(),
+		digestCacheTTL: time.Minute,
+	}
+
+	key := host + "/test/app:v1|[]||"
+	item := server.resolveDigestKey(context.Background(), key)
+	if item.Error != "" || item.Value != host+"/test/app@"+first || item.Key != key {
+		t.Fatalf("Expected %s, got %+v", first, item)
+	}
+
+	// A moved tag keeps resolving to the cached digest until it expires
+	second := pushRandomImage(t, host+"/test/app:v1")
+	if item := server.resolveDigestKey(context.Background(), key+"|debug=true"); item.Value != host+"/test/app@"+first {
+		t.Errorf("Expected the cached digest %s, got %+v", first, item)
+	}
+	server.digestCacheTTL = 0
+	server.digests = newResultCache()
+	if item := server.resolveDigestKey(context.Background(), key); item.Value != host+"/test/app@"+second {
+		t.Errorf("Expected the moved digest %s, got %+v", second, item)
+	}
+
+	// Digest references are returned without contacting the registry
+	digestKey := "ghcr.io/org/app@" + testDigest + "|[]||"
+	if item := server.resolveDigestKey(context.Background(), digestKey); item.Value != "ghcr.io/org/app@"+testDigest {
+		t.Errorf("Expected the digest reference as is, got %+v", item)
+	}
+
+	if item := server.resolveDigestKey(context.Background(), host+"/test/missing:v1|[]||"); item.Error == "" {
+		t.Errorf("Expected an error for a missing tag, got %+v", item)
+	}
+}
+
+func TestPinDigest(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	digest := pushRandomImage(t, host+"/test/app:v1")
+	verifier := &AttestationVerifier{keychain: authn.DefaultKeychain}
+	tag, _ := name.ParseReference(host + "/test/app:v1")
+	ref, resolved, err := verifier.pinDigest(context.Background(), tag, authn.DefaultKeychain)
+	if err != nil || ref.Identifier() != digest || resolved != host+"/test/app@"+digest {
+		t.Errorf("Expected the tag pinned to %s, got %v, %q, %v", digest, ref, resolved, err)
+	}
+
+	pinned, _ := name.ParseReference(host + "/test/app@" + digest)
+	if ref, resolved, err := verifier.pinDigest(context.Background(), pinned, authn.DefaultKeychain); err != nil || ref != pinned || resolved != "" {
+		t.Errorf("Expected a digest reference as is, got %v, %q, %v", ref, resolved, err)
+	}
+
+	missing, _ := name.ParseReference(host + "/test/missing:v1")
+	if _, _, err := verifier.pinDigest(context.Background(), missing, authn.DefaultKeychain); err == nil {
+		t.Error("Expected an error for a missing tag")
+	}
+}
+
+func TestHandleResolve(t *testing.T) {
+	server := &Server{
+		verifier:       &AttestationVerifier{keychain: authn.DefaultKeychain},
+		timeout:        5 * time.Second,
+		digests:        newResultCache(),
+		digestCacheTTL: time.Minute,
+	}
+	server.digests.Set("ghcr.io/org/app:v1|[]", Item{Value: "ghcr.io/org/app@" + testDigest}, time.Minute)
+
+	body, _ := json.Marshal(ProviderRequest{Request: Request{Keys: []string{"ghcr.io/org/app:v1|[]||", "not a reference|[]||"}}})
+	w := httptest.NewRecorder()
+	server.handleResolve(w, httptest.NewRequest(http.MethodPost, "/resolve", bytes.NewReader(body)))
+
+	var response ProviderResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	items := response.Response.Items
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %v", items)
+	}
+	if items[0].Key != "ghcr.io/org/app:v1|[]||" || items[0].Value != "ghcr.io/org/app@"+testDigest {
+		t.Errorf("Expected the cached digest, got %+v", items[0])
+	}
+	if items[1].Error == "" {
+		t.Errorf("Expected an error for an invalid reference, got %+v", items[1])
+	}
+	if response.Response.Idempotent {
+		t.Error("Expected resolved tags not to be marked idempotent")
+	}
+
+	w = httptest.NewRecorder()
+	server.handleResolve(w, httptest.NewRequest(http.MethodGet, "/resolve", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
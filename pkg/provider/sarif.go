@@ -0,0 +1,360 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SARIFContentType is the media type of SARIF logs
+const SARIFContentType = "application/sarif+json"
+
+// sarifVersion and sarifSchema identify the SARIF format served by /sarif
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// maxSARIFImages bounds the images checked by a single /sarif request
+const maxSARIFImages = 100
+
+// SARIFRequest asks /sarif to verify images and check their SBOMs against the rules of the
+// policy template, for CI pipelines that render results in code scanning UIs
+type SARIFRequest struct {
+	Images           []string `json:"images"`
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+	KeySettings
+
+	ProhibitedPackages     []PackageRule `json:"prohibitedPackages,omitempty"`
+	ProhibitedLicenses     []string      `json:"prohibitedLicenses,omitempty"`
+	RequiredLicenses       []string      `json:"requiredLicenses,omitempty"`
+	DirectRequiredLicenses []string      `json:"directRequiredLicenses,omitempty"` // Allow list for direct dependencies only
+	DenyEmptySBOM          bool          `json:"denyEmptySBOM,omitempty"`
+	RequireRegisteredImage bool          `json:"requireRegisteredImage,omitempty"`
+	MinIdentityConfidence  float64       `json:"minIdentityConfidence,omitempty"` // Requires COMPONENT_EVIDENCE
+	CriticalPackages       []string      `json:"criticalPackages,omitempty"`      // Packages minIdentityConfidence applies to, all when empty
+}
+
+// PackageRule matches packages by name and version ("*" matches every version)
+type PackageRule struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// sarifRule describes a check reported in SARIF logs
+type sarifRule struct {
+	ID               string           `json:"id"`
+	ShortDescription sarifMessage     `json:"shortDescription"`
+	DefaultConfig    sarifRuleDefault `json:"defaultConfiguration"`
+}
+
+type sarifRuleDefault struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRules describes every check /sarif can report, by rule ID
+var sarifRules = map[string]string{
+	ViolationProhibitedPackage: "Image contains a prohibited package",
+	ViolationProhibitedLicense: "Image contains a package with a prohibited license",
+	ViolationDisallowedLicense: "Image contains a package with a disallowed or missing license",
+	ViolationEmptySBOM:         "Image has a verified SBOM that lists no packages",
+	ViolationUnregisteredImage: "Image is not registered to a team in the image catalog",
+	ViolationLowConfidence:     "Image contains a critical package identified with too little confidence",
+	ViolationUnverifiedSBOM:    "Image only has an unsigned BuildKit or attached SBOM",
+	ErrCodeIdentityMismatch:    "SBOM attestation is signed by an unexpected identity",
+	ErrCodeAnnotationMismatch:  "Image has no signature carrying the required annotations",
+	ErrCodePredicateType:       "Image has no SBOM attestation of an accepted predicate type",
+	ErrCodePlatform:            "Image index has no manifest for the selected platform",
+	ErrCodeImageSignature:      "Image has no signature by an accepted signer",
+	sarifRuleVerification:      "SBOM attestation could not be verified",
+}
+
+// sarifRuleVerification reports verification failures without a more specific code
+const sarifRuleVerification = "VERIFICATION_FAILED"
+
+// imageViolation is a failed check of an image, with the package it concerns if any
+type imageViolation struct {
+	Violation
+	pkg string
+}
+
+// evaluateSBOM checks a verified SBOM against the rules of req, like the policy template does.
+// Rules covered by an active policy exception of the image are skipped, and checks tightened
+// by an active verification window are applied.
+func evaluateSBOM(sbom *UnifiedSBOM, req *SARIFRequest) []imageViolation {
+	excepted := func(code string) bool {
+		for i := range sbom.Exceptions {
+			if sbom.Exceptions[i].covers(code) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var violations []imageViolation
+	add := func(code, pkg, format string, args ...interface{}) {
+		if !excepted(code) {
+			violations = append(violations, imageViolation{Violation{Code: code, Message: fmt.Sprintf(format, args...)}, pkg})
+		}
+	}
+
+	for _, v := range sbom.Violations {
+		violations = append(violations, imageViolation{Violation: v})
+	}
+
+	for _, pkg := range sbom.Packages {
+		for _, rule := range req.ProhibitedPackages {
+			if pkg.Name == rule.Name && (rule.Version == "*" || pkg.Version == rule.Version) {
+				add(ViolationProhibitedPackage, pkg.Name, "Contains prohibited package: %s@%s", pkg.Name, pkg.Version)
+			}
+		}
+		for _, license := range req.ProhibitedLicenses {
+			if strings.Contains(pkg.License, license) {
+				add(ViolationProhibitedLicense, pkg.Name, "Contains package %s with prohibited license: %s", pkg.Name, pkg.License)
+			}
+		}
+		if len(req.RequiredLicenses) > 0 && !licenseAllowed(pkg.License, req.RequiredLicenses) {
+			add(ViolationDisallowedLicense, pkg.Name, "Contains package %s with disallowed or missing license: %s", pkg.Name, pkg.License)
+		}
+		if len(req.DirectRequiredLicenses) > 0 && pkg.Direct != nil && *pkg.Direct && !licenseAllowed(pkg.License, req.DirectRequiredLicenses) {
+			add(ViolationDisallowedLicense, pkg.Name, "Contains direct dependency %s with disallowed or missing license: %s", pkg.Name, pkg.License)
+		}
+		if req.MinIdentityConfidence > 0 && criticalPackage(pkg.Name, req.CriticalPackages) {
+			if confidence := identityConfidence(pkg); confidence < req.MinIdentityConfidence {
+				add(ViolationLowConfidence, pkg.Name, "Contains critical package %s identified with confidence %g, below %g", pkg.Name, confidence, req.MinIdentityConfidence)
+			}
+		}
+	}
+
+	tightened := func(check string) bool {
+		if sbom.Window != nil {
+			for _, c := range sbom.Window.Tighten {
+				if c == check {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if (req.DenyEmptySBOM || tightened("denyEmptySBOM")) && sbom.EmptySBOM {
+		add(ViolationEmptySBOM, "", "Has a verified SBOM that lists no packages")
+	}
+	// Unsigned SBOMs returned for another constraint's inventory fail this one
+	if sbom.Unverified && req.UnsignedBuildxSBOMs != UnsignedSBOMsInventory {
+		add(ViolationUnverifiedSBOM, "", "Only has an unsigned BuildKit or attached SBOM, which was not verified")
+	}
+	if (req.RequireRegisteredImage || tightened("requireRegisteredImage")) && (sbom.Ownership == nil || !sbom.Ownership.Registered) {
+		add(ViolationUnregisteredImage, "", "Is not registered to a team in the image catalog")
+	}
+	return violations
+}
+
+// criticalPackage reports whether a package is among the critical packages, which are all
+// packages when none are listed
+func criticalPackage(name string, critical []string) bool {
+	if len(critical) == 0 {
+		return true
+	}
+	for _, c := range critical {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// identityConfidence returns the confidence a package was identified with, 0 without evidence
+func identityConfidence(pkg UnifiedPackage) float64 {
+	if pkg.Evidence == nil || pkg.Evidence.IdentityConfidence == nil {
+		return 0
+	}
+	return *pkg.Evidence.IdentityConfidence
+}
+
+// licenseAllowed reports whether license is in the allow list. Missing licenses are not allowed.
+func licenseAllowed(license string, allowed []string) bool {
+	if license == "" || license == "NOASSERTION" || license == "NONE" {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.Contains(license, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// sarifResultsFor turns the outcome of checking image into SARIF results. Verification
+// failures are errors, violations allowed by an exception are notes.
+func sarifResultsFor(image string, item Item, req *SARIFRequest) []sarifResult {
+	location := func(pkg string) []sarifLocation {
+		loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: image}}}
+		if pkg != "" {
+			loc.LogicalLocations = []sarifLogicalLocation{{Name: pkg, Kind: "package"}}
+		}
+		return []sarifLocation{loc}
+	}
+
+	if item.Error != "" {
+		rule := sarifRuleVerification
+		if code, _, ok := strings.Cut(item.Error, ":"); ok && sarifRules[code] != "" {
+			rule = code
+		}
+		return []sarifResult{{RuleID: rule, Level: "error", Message: sarifMessage{Text: item.Error}, Locations: location("")}}
+	}
+
+	var sbom UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &sbom); err != nil {
+		return []sarifResult{{RuleID: sarifRuleVerification, Level: "error", Message: sarifMessage{Text: "Invalid provider result: " + err.Error()}, Locations: location("")}}
+	}
+
+	var results []sarifResult
+	for _, v := range evaluateSBOM(&sbom, req) {
+		results = append(results, sarifResult{RuleID: v.Code, Level: "error", Message: sarifMessage{Text: v.Message}, Locations: location(v.pkg)})
+	}
+	for _, w := range sbom.Warnings {
+		text := w.Message
+		if w.Exception != nil {
+			text = fmt.Sprintf("%s (allowed until %s by %s: %s)", text, w.Exception.Expires.Format("2006-01-02"), w.Exception.Approver, w.Exception.Reason)
+		}
+		results = append(results, sarifResult{RuleID: w.Code, Level: "note", Message: sarifMessage{Text: text}, Locations: location("")})
+	}
+	return results
+}
+
+// newSARIFLog wraps results in a SARIF log describing the rules they reference
+func newSARIFLog(results []sarifResult) sarifLog {
+	ids := make(map[string]bool)
+	for _, r := range results {
+		ids[r.RuleID] = true
+	}
+
+	rules := make([]sarifRule, 0, len(ids))
+	for id := range ids {
+		description := sarifRules[id]
+		if description == "" {
+			description = id
+		}
+		rules = append(rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: description}, DefaultConfig: sarifRuleDefault{Level: "error"}})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	if results == nil {
+		results = []sarifResult{}
+	}
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "sbom-provider", Rules: rules}},
+			Results: results,
+		}},
+	}
+}
+
+// checkImage verifies a provider key as class traffic and checks it with its upstream provider
+// like resolveKey. Unlike resolveKey it never answers pending in async mode, as CI pipelines,
+// warmup and the audit need a result.
+func (s *Server) checkImage(key, class string) Item {
+	imageRef, opts := splitKeyOptions(key)
+	imageRef = opts.resultKey(imageRef)
+
+	item, ok := s.cachedItem(imageRef)
+	if !ok {
+		item = s.verifyScheduled(context.Background(), class, imageRef)
+		if item.Error == "" {
+			s.cache.Set(s.cacheKey(imageRef), item, s.cacheTTL)
+		}
+	}
+	item.Key = key
+	items := []Item{item}
+	s.delegateUpstream(context.Background(), items, []keyOptions{opts})
+	return s.applyPolicies(items[0], opts)
+}
+
+// handleSARIF verifies the images of a SARIFRequest and reports failed checks as a SARIF log
+func (s *Server) handleSARIF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	var req SARIFRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if len(req.Images) == 0 || len(req.Images) > maxSARIFImages {
+		http.Error(w, fmt.Sprintf("between 1 and %d images are required", maxSARIFImages), http.StatusBadRequest)
+		return
+	}
+
+	var results []sarifResult
+	for _, image := range req.Images {
+		results = append(results, sarifResultsFor(image, s.checkImage(req.key(image, req.ImagePullSecrets), RequestClassBatch), &req)...)
+	}
+	log.Printf("SARIF check of %d images: %d results", len(req.Images), len(results))
+
+	w.Header().Set("Content-Type", SARIFContentType)
+	json.NewEncoder(w).Encode(newSARIFLog(results))
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvaluateSBOM(t *testing.T) {
+	sbom := &UnifiedSBOM{
+		Packages: []UnifiedPackage{
+			{Name: "log4j-core", Version: "2.14.1", License: "Apache-2.0"},
+			{Name: "readline", Version: "8.2", License: "GPL-3.0-only"},
+			{Name: "mystery", Version: "1.0", License: "NOASSERTION"},
+		},
+		Ownership: &ImageOwnership{Registered: false},
+	}
+	req := &SARIFRequest{
+		ProhibitedPackages:     []PackageRule{{Name: "log4j-core", Version: "*"}},
+		ProhibitedLicenses:     []string{"GPL-3.0"},
+		RequiredLicenses:       []string{"Apache-2.0", "MIT"},
+		RequireRegisteredImage: true,
+	}
+
+	counts := make(map[string]int)
+	for _, v := range evaluateSBOM(sbom, req) {
+		counts[v.Code]++
+	}
+	want := map[string]int{
+		ViolationProhibitedPackage: 1,
+		ViolationProhibitedLicense: 1,
+		ViolationDisallowedLicense: 2, // readline and mystery
+		ViolationUnregisteredImage: 1,
+	}
+	for code, n := range want {
+		if counts[code] != n {
+			t.Errorf("Expected %d %s violations, got %d (%v)", n, code, counts[code], counts)
+		}
+	}
+
+	// Rules covered by an active exception are skipped
+	sbom.Exceptions = []PolicyException{{Violations: []string{ViolationDisallowedLicense, ViolationUnregisteredImage}}}
+	for _, v := range evaluateSBOM(sbom, req) {
+		if v.Code == ViolationDisallowedLicense || v.Code == ViolationUnregisteredImage {
+			t.Errorf("Expected %s to be excepted", v.Code)
+		}
+	}
+}
+
+func TestSARIFResultsForError(t *testing.T) {
+	item := Item{Error: "ERR_IDENTITY_MISMATCH: Failed to verify attestation or extract SBOM: tag: none of the expected identities matched"}
+	results := sarifResultsFor("ghcr.io/org/app:v1", item, &SARIFRequest{})
+	if len(results) != 1 || results[0].RuleID != ErrCodeIdentityMismatch || results[0].Level != "error" {
+		t.Errorf("Expected an identity mismatch error, got %+v", results)
+	}
+
+	results = sarifResultsFor("ghcr.io/org/app:v1", Item{Error: "Failed to verify attestation or extract SBOM: no attestations found"}, &SARIFRequest{})
+	if len(results) != 1 || results[0].RuleID != sarifRuleVerification {
+		t.Errorf("Expected a generic verification failure, got %+v", results)
+	}
+}
+
+func TestHandleSARIF(t *testing.T) {
+	server := &Server{
+		verifier: &AttestationVerifier{},
+		timeout:  5 * time.Second,
+		cache:    newResultCache(),
+		cacheTTL: time.Minute,
+	}
+
+	image := "ghcr.io/org/app@" + testDigest
+	value := `{"format":"spdx","packages":[{"name":"log4j-core","versionInfo":"2.14.1","licenseConcluded":"Apache-2.0"}],` +
+		`"warnings":[{"code":"ERR_IDENTITY_MISMATCH","message":"signed by the old pipeline"}]}`
+	server.cache.Set(server.cacheKey(image+"|[]||"), Item{Value: value}, time.Minute)
+
+	body := `{"images": ["` + image + `"], "prohibitedPackages": [{"name": "log4j-core", "version": "2.14.1"}]}`
+	w := httptest.NewRecorder()
+	server.handleSARIF(w, httptest.NewRequest("POST", "/sarif", bytes.NewBufferString(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != SARIFContentType {
+		t.Errorf("Expected content type %s, got %s", SARIFContentType, ct)
+	}
+
+	var report sarifLog
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode SARIF: %v", err)
+	}
+	if report.Version != "2.1.0" || len(report.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log %+v", report)
+	}
+	run := report.Runs[0]
+	if len(run.Results) != 2 || len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("Expected a violation and a warning with their rules, got %+v", run)
+	}
+	pkg := run.Results[0]
+	if pkg.RuleID != ViolationProhibitedPackage || pkg.Level != "error" || pkg.Locations[0].PhysicalLocation.ArtifactLocation.URI != image ||
+		pkg.Locations[0].LogicalLocations[0].Name != "log4j-core" {
+		t.Errorf("Unexpected package result %+v", pkg)
+	}
+	if run.Results[1].Level != "note" {
+		t.Errorf("Expected the warning as a note, got %+v", run.Results[1])
+	}
+
+	for _, tt := range []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "not json", http.StatusBadRequest},
+		{"POST", `{"images": []}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		server.handleSARIF(w, httptest.NewRequest(tt.method, "/sarif", bytes.NewBufferString(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %q: expected status %d, got %d", tt.method, tt.body, tt.want, w.Code)
+		}
+	}
+}
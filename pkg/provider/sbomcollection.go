@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// How the SBOMs of an image are collected when several are attested, see
+// VerifierConfig.SBOMCollection
+const (
+	// SBOMCollectionFirst returns the first SBOM found
+	SBOMCollectionFirst = "first"
+	// SBOMCollectionAll returns the first SBOM, with every SBOM found listed in sboms
+	SBOMCollectionAll = "all"
+	// SBOMCollectionMerge returns the packages of every SBOM found, deduplicated, with the SBOMs
+	// merged listed in sboms
+	SBOMCollectionMerge = "merge"
+)
+
+// parseSBOMCollection validates the SBOM collection mode, defaulting to the first SBOM
+func parseSBOMCollection(mode string) (string, error) {
+	switch mode {
+	case "":
+		return SBOMCollectionFirst, nil
+	case SBOMCollectionFirst, SBOMCollectionAll, SBOMCollectionMerge:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid SBOM collection %q (expected %s, %s or %s)", mode, SBOMCollectionFirst, SBOMCollectionAll, SBOMCollectionMerge)
+}
+
+// CollectedSBOM is one of the SBOMs found for an image, with SBOM_COLLECTION all or merge
+type CollectedSBOM struct {
+	Format       string           `json:"format"`
+	Packages     []UnifiedPackage `json:"packages,omitempty"` // Omitted once merged into the top-level packages
+	PackageCount int              `json:"packageCount"`
+	Document     *SBOMDocument    `json:"document,omitempty"`
+	SignedAt     string           `json:"signedAt,omitempty"`
+	Unverified   bool             `json:"unverified,omitempty"`
+}
+
+// collectSBOMs returns the SBOM of an image with several SBOMs found: the first with all of
+// them listed, or with their packages merged under the collection mode
+func (v *AttestationVerifier) collectSBOMs(ctx context.Context, sboms []*UnifiedSBOM) *UnifiedSBOM {
+	first := sboms[0]
+	collected := make([]CollectedSBOM, 0, len(sboms))
+	for _, sbom := range sboms {
+		c := CollectedSBOM{
+			Format:       sbom.Format,
+			PackageCount: len(sbom.Packages),
+			Document:     sbom.Document,
+			SignedAt:     sbom.SignedAt,
+			Unverified:   sbom.Unverified,
+		}
+		if v.sbomCollection == SBOMCollectionAll {
+			c.Packages = sbom.Packages
+		}
+		collected = append(collected, c)
+	}
+	first.SBOMs = collected
+
+	if v.sbomCollection == SBOMCollectionMerge {
+		packages := first.Packages
+		for _, sbom := range sboms[1:] {
+			packages = mergePackages(packages, sbom.Packages)
+			first.osDetected = first.osDetected || sbom.osDetected
+		}
+		tracef(ctx, "merged %d SBOMs into %d packages", len(sboms), len(packages))
+		first.Packages = packages
+		first.EmptySBOM = len(packages) == 0
+	}
+	return first
+}
+
+// mergePackages appends the packages of more missing from packages, matched by package URL or
+// else by name and version. The fields a duplicate knows and the first package does not, e.g.
+// a license or the layer that added it, are filled in.
+func mergePackages(packages, more []UnifiedPackage) []UnifiedPackage {
+	index := make(map[string]int, len(packages))
+	for i, pkg := range packages {
+		index[packageMergeKey(pkg)] = i
+	}
+	for _, pkg := range more {
+		i, ok := index[packageMergeKey(pkg)]
+		if !ok {
+			index[packageMergeKey(pkg)] = len(packages)
+			packages = append(packages, pkg)
+			continue
+		}
+		merged := &packages[i]
+		if merged.License == "" || merged.License == "NOASSERTION" {
+			merged.License, merged.LicenseRaw, merged.Licenses = pkg.License, pkg.LicenseRaw, pkg.Licenses
+		}
+		if merged.PURL == "" {
+			merged.PURL = pkg.PURL
+		}
+		if merged.LayerDigest == "" {
+			merged.LayerDigest, merged.LayerDiffID = pkg.LayerDigest, pkg.LayerDiffID
+		}
+	}
+	return packages
+}
+
+// packageMergeKey identifies a package across SBOMs
+func packageMergeKey(pkg UnifiedPackage) string {
+	if pkg.PURL != "" {
+		base, version := splitPURL(pkg.PURL)
+		return strings.ToLower(base) + "@" + version
+	}
+	return pkg.Name + "@" + pkg.Version
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSBOMCollection(t *testing.T) {
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	spdx := vulnAttestation(t, "https://spdx.dev/Document", `{"spdxVersion":"SPDX-2.3","packages":[
+		{"name":"openssl","versionInfo":"3.0.13","licenseConcluded":"NOASSERTION","externalRefs":[{"referenceType":"purl","referenceLocator":"pkg:deb/debian/openssl@3.0.13?arch=amd64"}]},
+		{"name":"zlib","versionInfo":"1.3"}]}`, signedAt)
+	cyclonedx := vulnAttestation(t, "https://cyclonedx.org/bom", `{"bomFormat":"CycloneDX","specVersion":"1.5","components":[
+		{"name":"openssl","version":"3.0.13","purl":"pkg:deb/debian/openssl@3.0.13","licenses":[{"license":{"id":"Apache-2.0"}}]},
+		{"name":"zlib","version":"1.3"},
+		{"name":"requests","version":"2.31.0","purl":"pkg:pypi/requests@2.31.0"}]}`, signedAt)
+	unsigned := vulnAttestation(t, "https://spdx.dev/Document", `{"spdxVersion":"SPDX-2.3","packages":[{"name":"curl","versionInfo":"8.5.0"}]}`, time.Time{})
+	unsigned.unverified = true
+	atts := []verifiedAttestation{spdx, cyclonedx, unsigned}
+
+	verifier := &AttestationVerifier{sbomCollection: SBOMCollectionFirst}
+	unified, err := verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || unified.Format != "spdx" || len(unified.Packages) != 2 || unified.SBOMs != nil {
+		t.Fatalf("Expected the first SBOM only, got %+v and %v", unified, err)
+	}
+
+	verifier.sbomCollection = SBOMCollectionAll
+	unified, err = verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil || unified.Format != "spdx" || len(unified.Packages) != 2 {
+		t.Fatalf("Expected the first SBOM at the top level, got %+v and %v", unified, err)
+	}
+	if len(unified.SBOMs) != 2 || unified.SBOMs[1].Format != "cyclonedx" || len(unified.SBOMs[1].Packages) != 3 || unified.SBOMs[1].PackageCount != 3 {
+		t.Errorf("Expected both verified SBOMs with their packages, got %+v", unified.SBOMs)
+	}
+
+	verifier.sbomCollection = SBOMCollectionMerge
+	unified, err = verifier.sbomFromAttestations(context.Background(), atts)
+	if err != nil {
+		t.Fatalf("Failed to merge SBOMs: %v", err)
+	}
+	if len(unified.Packages) != 3 {
+		t.Fatalf("Expected 3 deduplicated packages, got %+v", unified.Packages)
+	}
+	if pkg := unified.Packages[0]; pkg.License != "Apache-2.0" || pkg.PURL != "pkg:deb/debian/openssl@3.0.13?arch=amd64" {
+		t.Errorf("Expected the license of the duplicate to fill NOASSERTION, got %+v", pkg)
+	}
+	if pkg := unified.Packages[2]; pkg.Name != "requests" {
+		t.Errorf("Expected the package only the CycloneDX SBOM lists, got %+v", pkg)
+	}
+	if len(unified.SBOMs) != 2 || unified.SBOMs[0].Packages != nil || unified.SBOMs[0].PackageCount != 2 {
+		t.Errorf("Expected the merged SBOMs listed without packages, got %+v", unified.SBOMs)
+	}
+
+	if _, err := parseSBOMCollection("every"); err == nil {
+		t.Error("Expected an invalid collection mode to be rejected")
+	}
+}
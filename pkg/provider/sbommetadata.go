@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// spdxToolPrefix marks tool entries among SPDX creators, e.g. "Tool: syft-1.4.1"
+const spdxToolPrefix = "Tool:"
+
+// UnmarshalJSON accepts both the legacy array of tools and the CycloneDX 1.5 object form
+func (t *CycloneDXTools) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var tools []CycloneDXTool
+		if err := json.Unmarshal(data, &tools); err != nil {
+			return err
+		}
+		*t = tools
+		return nil
+	}
+
+	var tools struct {
+		Components []CycloneDXTool `json:"components"`
+		Services   []CycloneDXTool `json:"services"`
+	}
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return err
+	}
+	*t = append(tools.Components, tools.Services...)
+	return nil
+}
+
+// toolName renders a tool like SPDX tool creators, as "name-version"
+func toolName(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "-" + version
+}
+
+// spdxDocumentMetadata returns the document metadata of an SPDX SBOM
+func spdxDocumentMetadata(doc *SPDXDocument) *SBOMDocument {
+	meta := &SBOMDocument{
+		Name:      doc.Name,
+		Namespace: doc.DocumentNamespace,
+		Creators:  doc.CreationInfo.Creators,
+	}
+	meta.setCreated(doc.CreationInfo.Created)
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, spdxToolPrefix); ok {
+			meta.Tools = append(meta.Tools, strings.TrimSpace(tool))
+		}
+	}
+	return meta
+}
+
+// cycloneDXDocumentMetadata returns the document metadata of a CycloneDX SBOM
+func cycloneDXDocumentMetadata(bom *CycloneDXBOM) *SBOMDocument {
+	meta := &SBOMDocument{Namespace: bom.SerialNumber}
+	meta.setCreated(bom.Metadata.Timestamp)
+	for _, tool := range bom.Metadata.Tools {
+		meta.Tools = append(meta.Tools, toolName(tool.Name, tool.Version))
+	}
+	if comp := bom.Metadata.Component; comp != nil {
+		meta.Name = comp.Name
+		meta.Component = &SBOMComponent{
+			Type:    comp.Type,
+			Name:    comp.Name,
+			Version: comp.Version,
+			PURL:    comp.Purl,
+		}
+	}
+	return meta
+}
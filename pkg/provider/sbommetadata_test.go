@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSPDXDocumentMetadata(t *testing.T) {
+	predicate := `{
+		"spdxVersion": "SPDX-2.3",
+		"name": "ghcr.io/myorg/app",
+		"documentNamespace": "https://anchore.com/syft/image/ghcr.io/myorg/app-1234",
+		"creationInfo": {
+			"created": "2024-05-01T10:00:00Z",
+			"creators": ["Organization: Anchore, Inc", "Tool: syft-1.4.1"]
+		},
+		"packages": []
+	}`
+
+	verifier := &AttestationVerifier{}
+	unified, err := verifier.extractAndNormalizeSPDX(json.RawMessage(predicate))
+	if err != nil {
+		t.Fatalf("Failed to normalize SPDX: %v", err)
+	}
+
+	want := &SBOMDocument{
+		Name:      "ghcr.io/myorg/app",
+		Namespace: "https://anchore.com/syft/image/ghcr.io/myorg/app-1234",
+		Created:   "2024-05-01T10:00:00Z",
+		Tools:     []string{"syft-1.4.1"},
+		Creators:  []string{"Organization: Anchore, Inc", "Tool: syft-1.4.1"},
+	}
+	if !reflect.DeepEqual(unified.Document, want) {
+		t.Errorf("Expected %+v, got %+v", want, unified.Document)
+	}
+}
+
+func TestCycloneDXDocumentMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		tools string
+		want  []string
+	}{
+		{"legacy array", `[{"vendor": "aquasecurity", "name": "trivy", "version": "0.50.0"}]`, []string{"trivy-0.50.0"}},
+		{"1.5 object", `{"components": [{"type": "application", "name": "syft", "version": "1.4.1"}], "services": [{"name": "scanner"}]}`, []string{"syft-1.4.1", "scanner"}},
+	}
+
+	for _, tt := range tests {
+		predicate := `{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.5",
+			"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+			"metadata": {
+				"timestamp": "2024-05-01T10:00:00Z",
+				"tools": ` + tt.tools + `,
+				"component": {"type": "container", "name": "ghcr.io/myorg/app", "version": "sha256:4f4fb700"}
+			},
+			"components": []
+		}`
+
+		verifier := &AttestationVerifier{}
+		unified, err := verifier.extractAndNormalizeCycloneDX(json.RawMessage(predicate))
+		if err != nil {
+			t.Fatalf("%s: failed to normalize CycloneDX: %v", tt.name, err)
+		}
+
+		want := &SBOMDocument{
+			Name:      "ghcr.io/myorg/app",
+			Namespace: "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+			Created:   "2024-05-01T10:00:00Z",
+			Tools:     tt.want,
+			Component: &SBOMComponent{Type: "container", Name: "ghcr.io/myorg/app", Version: "sha256:4f4fb700"},
+		}
+		if !reflect.DeepEqual(unified.Document, want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, want, unified.Document)
+		}
+	}
+}
+
+func TestCycloneDXToolsInvalid(t *testing.T) {
+	var tools CycloneDXTools
+	if err := json.Unmarshal([]byte(`"syft"`), &tools); err == nil {
+		t.Error("Expected an error for tools that are neither an array nor an object")
+	}
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SearchResult lists the cached images containing a package, answering "which running
+// images contain this package" during incident response
+type SearchResult struct {
+	Query    string        `json:"query"`
+	Searched int           `json:"searched"` // Cached verification results searched
+	Images   []SearchMatch `json:"images"`
+}
+
+// SearchMatch is an image whose verified SBOM contains the searched package
+type SearchMatch struct {
+	Image      string           `json:"image"`
+	Source     string           `json:"source,omitempty"`
+	PolicyHash string           `json:"policyHash,omitempty"`
+	Packages   []UnifiedPackage `json:"packages"`
+}
+
+// packageQuery matches SBOM packages by package URL or by name and version
+type packageQuery struct {
+	purl    string // Package URL without qualifiers or subpath, e.g. "pkg:npm/lodash"
+	version string // Required version, empty matches every version
+	name    string
+}
+
+// newPURLQuery parses a package URL query. Without a version every version matches;
+// qualifiers and subpaths are ignored.
+func newPURLQuery(purl string) packageQuery {
+	base, version := splitPURL(purl)
+	return packageQuery{purl: base, version: version}
+}
+
+// splitPURL returns a package URL without qualifiers, subpath and version, and its version
+func splitPURL(purl string) (string, string) {
+	purl, _, _ = strings.Cut(purl, "#")
+	purl, _, _ = strings.Cut(purl, "?")
+	// The version follows the last "@" of the name, scopes like @types are part of the namespace
+	if i := strings.LastIndex(purl, "@"); i > strings.LastIndex(purl, "/") {
+		return purl[:i], purl[i+1:]
+	}
+	return purl, ""
+}
+
+// matches reports whether pkg is the queried package
+func (q packageQuery) matches(pkg UnifiedPackage) bool {
+	if q.purl != "" {
+		if pkg.PURL == "" {
+			return false
+		}
+		base, version := splitPURL(pkg.PURL)
+		return strings.EqualFold(base, q.purl) && (q.version == "" || version == q.version)
+	}
+	return strings.EqualFold(pkg.Name, q.name) && (q.version == "" || pkg.Version == q.version)
+}
+
+// search looks the cached verification results up for packages matching q. Results of
+// the same image verified for several keys are reported once.
+func (s *Server) search(q packageQuery) SearchResult {
+	entries := s.cache.Snapshot(func(string) bool { return true })
+	result := SearchResult{Searched: len(entries), Images: []SearchMatch{}}
+
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		image := strings.SplitN(e.Key, "|", 2)[0]
+		if seen[image] {
+			continue
+		}
+
+		var unified UnifiedSBOM
+		if err := json.Unmarshal([]byte(e.Item.Value), &unified); err != nil || unified.MetadataOnly {
+			continue
+		}
+		var packages []UnifiedPackage
+		for _, pkg := range unified.Packages {
+			if q.matches(pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+		if len(packages) == 0 {
+			continue
+		}
+
+		seen[image] = true
+		result.Images = append(result.Images, SearchMatch{
+			Image:      image,
+			Source:     unified.Source,
+			PolicyHash: unified.PolicyHash,
+			Packages:   packages,
+		})
+	}
+	sort.Slice(result.Images, func(i, j int) bool { return result.Images[i].Image < result.Images[j].Image })
+	return result
+}
+
+// handleSearch lists the cached images containing a package.
+// GET ?purl=pkg:npm/lodash[@4.17.21] or ?name=lodash[&version=4.17.21], with
+// "Authorization: Bearer <INSPECT_TOKEN>".
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorizedInspect(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sbom-provider"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	var q packageQuery
+	switch {
+	case params.Get("cve") != "":
+		// Verified SBOMs list packages, not vulnerabilities: resolve the CVE to its affected
+		// packages with a vulnerability database and search those instead
+		http.Error(w, "cve search is not supported, SBOMs carry no vulnerability data; search the affected packages by purl", http.StatusBadRequest)
+		return
+	case params.Get("purl") != "":
+		if !strings.HasPrefix(params.Get("purl"), "pkg:") {
+			http.Error(w, "purl must start with pkg:", http.StatusBadRequest)
+			return
+		}
+		q = newPURLQuery(params.Get("purl"))
+	case params.Get("name") != "":
+		q = packageQuery{name: params.Get("name"), version: params.Get("version")}
+	default:
+		http.Error(w, "purl or name is required", http.StatusBadRequest)
+		return
+	}
+
+	result := s.search(q)
+	result.Query = r.URL.RawQuery
+	log.Printf("Package search %q: %d images of %d cached results", result.Query, len(result.Images), result.Searched)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSplitPURL(t *testing.T) {
+	tests := []struct {
+		purl    string
+		base    string
+		version string
+	}{
+		{"pkg:npm/lodash@4.17.21", "pkg:npm/lodash", "4.17.21"},
+		{"pkg:npm/lodash", "pkg:npm/lodash", ""},
+		{"pkg:npm/@types/node@20.1.0", "pkg:npm/@types/node", "20.1.0"},
+		{"pkg:deb/debian/openssl@3.0.11?arch=amd64#docs", "pkg:deb/debian/openssl", "3.0.11"},
+	}
+	for _, tt := range tests {
+		base, version := splitPURL(tt.purl)
+		if base != tt.base || version != tt.version {
+			t.Errorf("%s: expected %s and %q, got %s and %q", tt.purl, tt.base, tt.version, base, version)
+		}
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	server := &Server{cache: newResultCache(), inspectToken: "s3cret"}
+	server.cache.Set("ghcr.io/org/app@"+testDigest+"|[]||", Item{Value: `{"format":"spdx","source":"referrers","packages":[` +
+		`{"name":"lodash","versionInfo":"4.17.20","licenseConcluded":"MIT","purl":"pkg:npm/lodash@4.17.20"},` +
+		`{"name":"express","versionInfo":"4.18.2","licenseConcluded":"MIT","purl":"pkg:npm/express@4.18.2"}]}`}, time.Minute)
+	// The same image verified for another identity is reported once
+	server.cache.Set("ghcr.io/org/app@"+testDigest+"|[]|ci@example.com|https://issuer", Item{Value: `{"format":"spdx","packages":[` +
+		`{"name":"lodash","versionInfo":"4.17.20","licenseConcluded":"MIT","purl":"pkg:npm/lodash@4.17.20"}]}`}, time.Minute)
+	server.cache.Set("ghcr.io/org/api:v2|[]||", Item{Value: `{"format":"cyclonedx","packages":[` +
+		`{"name":"lodash","versionInfo":"4.17.21","licenseConcluded":"MIT","purl":"pkg:npm/lodash@4.17.21?repository_url=https://registry.npmjs.org"}]}`}, time.Minute)
+	server.cache.Set("ghcr.io/org/failing:v1|[]||", Item{Error: "Failed to verify attestation or extract SBOM: no attestations found"}, time.Minute)
+
+	search := func(query, token string) (*httptest.ResponseRecorder, SearchResult) {
+		req := httptest.NewRequest("GET", "/search?"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.handleSearch(w, req)
+		var result SearchResult
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode search result: %v", err)
+			}
+		}
+		return w, result
+	}
+
+	_, result := search("purl=pkg:npm/lodash", "s3cret")
+	if result.Searched != 3 || len(result.Images) != 2 {
+		t.Fatalf("Expected both images of 3 cached results, got %+v", result)
+	}
+	if result.Images[0].Image != "ghcr.io/org/api:v2" || result.Images[1].Image != "ghcr.io/org/app@"+testDigest {
+		t.Errorf("Expected images sorted by reference, got %+v", result.Images)
+	}
+
+	_, result = search("purl=pkg:npm/lodash@4.17.20", "s3cret")
+	if len(result.Images) != 1 || len(result.Images[0].Packages) != 1 || result.Images[0].Packages[0].Version != "4.17.20" {
+		t.Errorf("Expected only the image with lodash 4.17.20, got %+v", result.Images)
+	}
+
+	_, result = search("name=express&version=4.18.2", "s3cret")
+	if len(result.Images) != 1 || result.Images[0].Source != "referrers" {
+		t.Errorf("Expected the image with express by name, got %+v", result.Images)
+	}
+
+	for _, tt := range []struct {
+		query string
+		token string
+		want  int
+	}{
+		{"purl=pkg:npm/lodash", "", http.StatusUnauthorized},
+		{"purl=pkg:npm/lodash", "wrong", http.StatusUnauthorized},
+		{"cve=CVE-2021-44228", "s3cret", http.StatusBadRequest},
+		{"purl=npm/lodash", "s3cret", http.StatusBadRequest},
+		{"", "s3cret", http.StatusBadRequest},
+	} {
+		if w, _ := search(tt.query, tt.token); w.Code != tt.want {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.want, w.Code)
+		}
+	}
+}
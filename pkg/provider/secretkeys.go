@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// secretKeyScheme is cosign's URI scheme for keys stored in Kubernetes Secrets, as created by
+// "cosign generate-key-pair k8s://<namespace>/<name>"
+const secretKeyScheme = "k8s://"
+
+// secretPublicKeyField is the Secret data entry holding the cosign public key
+const secretPublicKeyField = "cosign.pub"
+
+// isSecretKeyRef reports whether ref references a public key stored in a Kubernetes Secret
+func isSecretKeyRef(ref string) bool {
+	return strings.HasPrefix(ref, secretKeyScheme)
+}
+
+// parseSecretKeyRef returns the namespace and name of the Secret a k8s:// key URI references
+func parseSecretKeyRef(ref string) (string, string, error) {
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(ref, secretKeyScheme), "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid secret key reference %q, expected k8s://<namespace>/<name>", ref)
+	}
+	return namespace, name, nil
+}
+
+// secretKeyStore serves public keys stored in Kubernetes Secrets. Each referenced Secret is
+// watched with an informer, so rotated keys take effect without restarting the provider.
+type secretKeyStore struct {
+	client      kubernetes.Interface // Without a request timeout, watches are long-lived
+	syncTimeout time.Duration        // Bounds the first read of a Secret
+	onRotate    func(ref string)     // Called when the key of a watched Secret changes or is removed
+
+	mu      sync.Mutex
+	watches map[string]*secretKeyWatch
+	stop    chan struct{}
+}
+
+// secretKeyWatch holds the current key of a watched Secret
+type secretKeyWatch struct {
+	ref       string
+	namespace string
+	name      string
+	synced    toolscache.InformerSynced
+
+	mu          sync.RWMutex
+	verifier    signature.Verifier
+	fingerprint string
+	err         error // Why the Secret provides no key
+}
+
+// newSecretKeyStore creates a store watching Secrets with client
+func newSecretKeyStore(client kubernetes.Interface, syncTimeout time.Duration, onRotate func(ref string)) *secretKeyStore {
+	if syncTimeout <= 0 {
+		syncTimeout = DefaultSecretFetchTimeout
+	}
+	return &secretKeyStore{
+		client:      client,
+		syncTimeout: syncTimeout,
+		onRotate:    onRotate,
+		watches:     make(map[string]*secretKeyWatch),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Get returns the verifier and fingerprint of the key in the Secret ref, starting to watch
+// the Secret on first use
+func (s *secretKeyStore) Get(ctx context.Context, ref string) (signature.Verifier, string, error) {
+	w, err := s.watch(ref)
+	if err != nil {
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: err}
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, s.syncTimeout)
+	defer cancel()
+	if !toolscache.WaitForCacheSync(syncCtx.Done(), w.synced) {
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: fmt.Errorf("timed out reading secret %s/%s", w.namespace, w.name)}
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.verifier == nil {
+		err := w.err
+		if err == nil {
+			err = errors.New("not found")
+		}
+		return nil, "", &VerificationError{Code: ErrCodeVerificationKey, Err: fmt.Errorf("no public key in secret %s/%s: %w", w.namespace, w.name, err)}
+	}
+	return w.verifier, w.fingerprint, nil
+}
+
+// watch returns the watch of the Secret ref, starting an informer limited to that Secret
+func (s *secretKeyStore) watch(ref string) (*secretKeyWatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.watches[ref]; ok {
+		return w, nil
+	}
+
+	namespace, name, err := parseSecretKeyRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	w := &secretKeyWatch{ref: ref, namespace: namespace, name: name}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(s.client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.update(w, obj) },
+		UpdateFunc: func(_, obj interface{}) { s.update(w, obj) },
+		DeleteFunc: func(obj interface{}) { s.remove(w, obj) },
+	}); err != nil {
+		return nil, fmt.Errorf("failed to watch secret %s/%s: %w", namespace, name, err)
+	}
+	w.synced = informer.HasSynced
+	factory.Start(s.stop)
+
+	s.watches[ref] = w
+	log.Printf("Watching public key secret %s/%s", namespace, name)
+	return w, nil
+}
+
+// update loads the key of an added or changed Secret. An invalid key keeps the previous one.
+func (s *secretKeyStore) update(w *secretKeyWatch, obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Name != w.name {
+		return
+	}
+
+	var err error
+	var verifier signature.Verifier
+	var fingerprint string
+	if data, ok := secret.Data[secretPublicKeyField]; ok {
+		verifier, fingerprint, err = parsePublicKey(data)
+	} else {
+		err = fmt.Errorf("missing %s", secretPublicKeyField)
+	}
+
+	if err != nil {
+		log.Printf("Warning: invalid public key in secret %s/%s: %v, keeping the previous key", w.namespace, w.name, err)
+		w.mu.Lock()
+		if w.verifier == nil {
+			w.err = err
+		}
+		w.mu.Unlock()
+		return
+	}
+
+	w.mu.Lock()
+	previous := w.fingerprint
+	w.verifier, w.fingerprint, w.err = verifier, fingerprint, nil
+	w.mu.Unlock()
+
+	if previous == fingerprint {
+		return
+	}
+	if previous == "" {
+		log.Printf("Loaded public key %s from secret %s/%s", fingerprint, w.namespace, w.name)
+		return
+	}
+	log.Printf("Public key secret %s/%s rotated to %s", w.namespace, w.name, fingerprint)
+	if s.onRotate != nil {
+		s.onRotate(w.ref)
+	}
+}
+
+// remove drops the key of a deleted Secret, attestations signed with it stop verifying
+func (s *secretKeyStore) remove(w *secretKeyWatch, obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if secret, ok := obj.(*corev1.Secret); !ok || secret.Name != w.name {
+		return
+	}
+
+	w.mu.Lock()
+	removed := w.verifier != nil
+	w.verifier, w.fingerprint, w.err = nil, "", errors.New("secret deleted")
+	w.mu.Unlock()
+
+	log.Printf("Warning: public key secret %s/%s was deleted", w.namespace, w.name)
+	if removed && s.onRotate != nil {
+		s.onRotate(w.ref)
+	}
+}
+
+// Stop stops watching Secrets
+func (s *secretKeyStore) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+// usesKey reports whether the result cached under key was verified with the key ref
+func (v *AttestationVerifier) usesKey(key, ref string) bool {
+	_, opts := splitKeyOptions(key)
+	if opts.keyRef != "" {
+		return opts.keyRef == ref
+	}
+	return ref == v.publicKeyRef
+}
+
+// OnKeyRotation registers fn to be called with the key URI whenever a watched verification
+// key changes or is removed
+func (v *AttestationVerifier) OnKeyRotation(fn func(ref string)) {
+	v.trustMu.Lock()
+	v.onKeyRotation = fn
+	v.trustMu.Unlock()
+}
+
+// keyRotated notifies the registered key rotation callback
+func (v *AttestationVerifier) keyRotated(ref string) {
+	v.trustMu.RLock()
+	fn := v.onKeyRotation
+	v.trustMu.RUnlock()
+	if fn != nil {
+		fn(ref)
+	}
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSecretKeyRef(t *testing.T) {
+	tests := []struct {
+		ref           string
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		{"k8s://cosign-system/payments-signing", "cosign-system", "payments-signing", false},
+		{"k8s://payments-signing", "", "", true},
+		{"k8s:///payments-signing", "", "", true},
+		{"k8s://cosign-system/", "", "", true},
+		{"k8s://cosign-system/a/b", "", "", true},
+	}
+	for _, tt := range tests {
+		namespace, name, err := parseSecretKeyRef(tt.ref)
+		if (err != nil) != tt.wantErr || namespace != tt.wantNamespace || name != tt.wantName {
+			t.Errorf("parseSecretKeyRef(%q): expected %q %q (error %v), got %q %q %v", tt.ref, tt.wantNamespace, tt.wantName, tt.wantErr, namespace, name, err)
+		}
+	}
+}
+
+func publicKeySecret(t *testing.T, name string) *corev1.Secret {
+	t.Helper()
+	pem, err := cryptoutils.MarshalPublicKeyToPEM(newTestECDSAKey(t))
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "cosign-system"},
+		Data:       map[string][]byte{secretPublicKeyField: pem},
+	}
+}
+
+// eventually polls cond until it holds or a second passes
+func eventually(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestSecretKeyStoreRotation(t *testing.T) {
+	const ref = "k8s://cosign-system/payments-signing"
+	client := fake.NewSimpleClientset(publicKeySecret(t, "payments-signing"), publicKeySecret(t, "other"))
+	rotated := make(chan string, 4)
+	store := newSecretKeyStore(client, time.Second, func(ref string) { rotated <- ref })
+	defer store.Stop()
+
+	ctx := context.Background()
+	_, fingerprint, err := store.Get(ctx, ref)
+	if err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+
+	// Rotating the Secret swaps the key in without restarting
+	secrets := client.CoreV1().Secrets("cosign-system")
+	if _, err := secrets.Update(ctx, publicKeySecret(t, "payments-signing"), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+	select {
+	case got := <-rotated:
+		if got != ref {
+			t.Errorf("Expected rotation of %s, got %s", ref, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the rotation to be reported")
+	}
+	if _, got, err := store.Get(ctx, ref); err != nil || got == fingerprint {
+		t.Errorf("Expected the rotated key, got %s and %v", got, err)
+	}
+
+	// An invalid key keeps the previous one
+	_, current, _ := store.Get(ctx, ref)
+	invalid := publicKeySecret(t, "payments-signing")
+	invalid.Data[secretPublicKeyField] = []byte("not a key")
+	if _, err := secrets.Update(ctx, invalid, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, got, err := store.Get(ctx, ref); err != nil || got != current {
+		t.Errorf("Expected the previous key after an invalid update, got %s and %v", got, err)
+	}
+
+	// Deleting the Secret removes the key
+	if err := secrets.Delete(ctx, "payments-signing", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	var verr *VerificationError
+	if !eventually(t, func() bool {
+		_, _, err := store.Get(ctx, ref)
+		return errors.As(err, &verr) && verr.Code == ErrCodeVerificationKey
+	}) {
+		t.Errorf("Expected %s after the secret was deleted", ErrCodeVerificationKey)
+	}
+}
+
+func TestSecretKeyStoreMissing(t *testing.T) {
+	store := newSecretKeyStore(fake.NewSimpleClientset(), time.Second, nil)
+	defer store.Stop()
+
+	for _, ref := range []string{"k8s://cosign-system/missing", "k8s://missing"} {
+		_, _, err := store.Get(context.Background(), ref)
+		var verr *VerificationError
+		if !errors.As(err, &verr) || verr.Code != ErrCodeVerificationKey {
+			t.Errorf("%s: expected %s, got %v", ref, ErrCodeVerificationKey, err)
+		}
+	}
+}
+
+func TestKeyRotationEvictsCachedResults(t *testing.T) {
+	verifier := &AttestationVerifier{publicKeyRef: "k8s://cosign-system/default-signing"}
+	cache := newResultCache()
+	base := "ghcr.io/org/app@" + testDigest + "|[]||"
+	cache.Set(base, Item{Key: base, Value: "{}"}, time.Hour)
+	cache.Set(base+"|key=k8s://cosign-system/payments-signing", Item{Value: "{}"}, time.Hour)
+	cache.Set(base+"|packages=false,key=awskms:///alias/signing", Item{Value: "{}"}, time.Hour)
+
+	verifier.OnKeyRotation(func(ref string) {
+		cache.Evict(func(key string) bool { return verifier.usesKey(key, ref) })
+	})
+
+	verifier.keyRotated("k8s://cosign-system/payments-signing")
+	if cache.Len() != 2 {
+		t.Errorf("Expected only the result of the rotated key to be evicted, got %d entries", cache.Len())
+	}
+	verifier.keyRotated("k8s://cosign-system/default-signing")
+	if _, ok := cache.Get(base); ok || cache.Len() != 1 {
+		t.Errorf("Expected results of the provider key to be evicted, got %d entries", cache.Len())
+	}
+}
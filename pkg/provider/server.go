@@ -8,9 +8,124 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// ServerConfig holds the provider server settings
+type ServerConfig struct {
+	Port    string
+	Timeout time.Duration
+	TLSCert string
+	TLSKey  string
+
+	// CacheTTL is how long verification results are cached (0 disables caching)
+	CacheTTL time.Duration
+	// CacheTTLJitter shortens the TTL of each cached result by a random fraction of it up to
+	// this, so results cached together do not all expire at once (0 disables jitter)
+	CacheTTLJitter float64
+	// CacheRefreshAhead re-verifies cached results in the background when a request hits them
+	// within this last fraction of their TTL (0 disables background refresh)
+	CacheRefreshAhead float64
+
+	// AsyncMode returns a "pending" value for uncached keys and verifies them in the background
+	AsyncMode bool
+	// AsyncWorkers is the number of background verification workers used in async mode
+	AsyncWorkers int
+
+	// CacheSnapshot is where verified digest results are exported for other replicas to start
+	// warm: "file:<path>" or "configmap:<name>" in the provider namespace (empty disables)
+	CacheSnapshot string
+	// CacheSnapshotInterval is how often the cache is exported to CacheSnapshot
+	CacheSnapshotInterval time.Duration
+
+	// DigestCacheTTL is how long tags resolved by /resolve are cached (0 disables caching)
+	DigestCacheTTL time.Duration
+	// DigestMode is how /verify treats images referenced by tag only: DigestModeAllow (the
+	// default), DigestModeRequire or DigestModeResolve
+	DigestMode string
+	// DenyImagePatterns fail images matching any of them with ErrCodeImageDenied before they are
+	// verified or looked up in the cache, e.g. "*:latest". "*" also matches "/".
+	DenyImagePatterns []string
+	// AllowedRegistries fail images from other registries with ErrCodeImageDenied the same way,
+	// as path.Match patterns of registry hosts (empty allows every registry)
+	AllowedRegistries []string
+
+	// MaxPinDuration is the longest window accepted by the /pins endpoint (0 disables result pinning)
+	MaxPinDuration time.Duration
+	// PinStore shares pins and their results between replicas, as "configmap:<name>" for a
+	// ConfigMap in the provider namespace (empty keeps pins in each replica)
+	PinStore string
+
+	// MaxConcurrentVerifications bounds synchronous verifications in flight, sharing slots between
+	// request classes by RequestClassWeights (0 disables the limit)
+	MaxConcurrentVerifications int
+	// RequestClassWeights overrides DefaultRequestClassWeights as "class=weight" entries
+	RequestClassWeights []string
+
+	// MemoryLimit is the memory in bytes MemoryShedThresholds are relative to (0 uses the
+	// container's cgroup limit, a negative value disables load shedding)
+	MemoryLimit int64
+	// MemoryShedThresholds overrides DefaultMemoryShedThresholds as "class=percent" entries
+	MemoryShedThresholds []string
+
+	// InspectToken is the bearer token required by the /inspect endpoint (empty disables it)
+	InspectToken string
+
+	// ValueSchema is the default schema of item values (empty uses ValueSchemaV1)
+	ValueSchema string
+	// ValueSchemaProviders sets the value schema per Gatekeeper Provider as "provider=version"
+	// entries, for Providers whose URL carries a "provider" query parameter
+	ValueSchemaProviders []string
+
+	// ExpiryCheckInterval is how often the expiry of trust material and the TLS certificate is
+	// checked (0 disables)
+	ExpiryCheckInterval time.Duration
+	// ExpiryWarning is how long before expiry warnings are logged (0 uses DefaultExpiryWarning)
+	ExpiryWarning time.Duration
+
+	// LogSamplingWindow is how long identical per-image log lines are summarized for instead of
+	// being logged on every request (0 disables sampling)
+	LogSamplingWindow time.Duration
+
+	// LeakCheckInterval is how often goroutines and file descriptors are sampled for leaks (0 disables)
+	LeakCheckInterval time.Duration
+
+	// ExceptionsFile is a JSON file of policy exceptions, reloaded when it changes (empty disables)
+	ExceptionsFile string
+
+	// WindowsFile is a JSON file of verification windows, e.g. release freezes, reloaded when it changes (empty disables)
+	WindowsFile string
+
+	// UpstreamProviders delegates the images of matching repositories to other external data
+	// providers as "source=url" entries, failing images they reject
+	UpstreamProviders []string
+	// UpstreamCABundle is a PEM file of the CAs upstream provider certificates are verified
+	// against (empty uses the system roots)
+	UpstreamCABundle string
+
+	// ShadowPolicyFile is a JSON shadow policy verified alongside the active one, whose
+	// differing outcomes are logged and counted without affecting responses (empty disables)
+	ShadowPolicyFile string
+
+	// EnableChaos exposes the /chaos admin endpoint for failure injection (staging only)
+	EnableChaos bool
+	// AdminToken is the bearer token required by the /chaos and /pins admin endpoints (empty
+	// disables them)
+	AdminToken string
+
+	// AuditInterval is how often the images of running pods are verified in the background as
+	// audit traffic (0 disables the audit)
+	AuditInterval time.Duration
+	// AuditKeySettings are the JSON KeySettings of the constraint audited images are keyed with
+	AuditKeySettings string
+	// AuditReplicas is the number of replicas running images are sharded across by digest
+	AuditReplicas int
+	// AuditReplicaIndex is the shard of this replica (negative uses the ordinal of a StatefulSet
+	// pod's hostname)
+	AuditReplicaIndex int
+}
+
 // Server implements the external data provider HTTP server
 type Server struct {
 	port     string
@@ -18,35 +133,381 @@ type Server struct {
 	timeout  time.Duration
 	tlsCert  string
 	tlsKey   string
+
+	cache            *resultCache
+	cacheTTL         time.Duration
+	digests          *resultCache // Digests resolved by /resolve
+	digestCacheTTL   time.Duration
+	digestMode       string         // How images referenced by tag only are treated
+	denylist         *imageDenylist // Images denied before verification, nil when none are
+	snapshots        snapshotStore  // nil unless cache snapshots are enabled
+	snapshotInterval time.Duration
+	snapshotRestored atomic.Bool     // Set once the startup snapshot restore was attempted
+	async            *asyncVerifier  // nil unless async mode is enabled
+	refresher        *cacheRefresher // nil unless background cache refresh is enabled
+	asyncWorkers     int
+	pins             *pinStore       // nil unless result pinning is enabled
+	inspectToken     string          // Empty unless /inspect is enabled
+	scheduler        *fairScheduler  // nil unless verification concurrency is limited
+	memory           *memoryShedder  // nil unless a memory limit is known
+	expiry           *expiryMonitor  // nil unless expiry monitoring is enabled
+	leaks            *leakMonitor    // nil unless leak detection is enabled
+	logs             *logSampler     // nil unless log sampling is enabled
+	faults           *faultInjector  // nil unless chaos mode is enabled
+	adminToken       string          // Empty unless the admin endpoints are enabled
+	exceptions       *exceptionStore // nil unless policy exceptions are configured
+	windows          *windowStore    // nil unless verification windows are configured
+	audit            *auditor        // nil unless the background audit is enabled
+
+	upstreams []*upstreamProvider // Providers images are delegated to, most specific first
+	shadow    *shadowVerifier     // nil unless a shadow policy is configured
+
+	valueSchema          string            // Default value schema
+	valueSchemaProviders map[string]string // Value schemas by Provider name
 }
 
 // NewServer creates a new provider server
-func NewServer(port string, verifier *AttestationVerifier, timeout time.Duration, tlsCert, tlsKey string) *Server {
-	return &Server{
-		port:     port,
-		verifier: verifier,
-		timeout:  timeout,
-		tlsCert:  tlsCert,
-		tlsKey:   tlsKey,
+func NewServer(cfg ServerConfig, verifier *AttestationVerifier) *Server {
+	s := &Server{
+		port:           cfg.Port,
+		verifier:       verifier,
+		timeout:        cfg.Timeout,
+		tlsCert:        cfg.TLSCert,
+		tlsKey:         cfg.TLSKey,
+		cache:          newResultCache(),
+		cacheTTL:       cfg.CacheTTL,
+		digests:        newResultCache(),
+		digestCacheTTL: cfg.DigestCacheTTL,
+		digestMode:     parseDigestMode(cfg.DigestMode),
+		denylist:       newImageDenylist(cfg.DenyImagePatterns, cfg.AllowedRegistries),
+		asyncWorkers:   cfg.AsyncWorkers,
+		inspectToken:   cfg.InspectToken,
+		adminToken:     cfg.AdminToken,
 	}
+
+	// Drop results verified against trust material that has since changed. Trusted roots load
+	// in the background, so register first and always apply the latest hash.
+	verifier.OnTrustChange(func(string) {
+		evicted := s.cache.SetPolicyHash(verifier.PolicyHash())
+		log.Printf("Trust material changed, evicted %d cached results", evicted)
+	})
+	s.cache.SetPolicyHash(verifier.PolicyHash())
+
+	// Drop results verified with a rotated or removed verification key
+	verifier.OnKeyRotation(func(ref string) {
+		evicted := s.cache.Evict(func(key string) bool { return verifier.usesKey(key, ref) })
+		log.Printf("Verification key %s changed, evicted %d cached results", ref, evicted)
+	})
+
+	if cfg.CacheSnapshot != "" {
+		store, err := newSnapshotStore(cfg.CacheSnapshot, verifier.kubeClient, verifier.namespace)
+		if err != nil {
+			log.Printf("Warning: %v, cache snapshots disabled", err)
+		} else {
+			s.snapshots = store
+			s.snapshotInterval = cfg.CacheSnapshotInterval
+			if s.snapshotInterval <= 0 {
+				s.snapshotInterval = defaultCacheSnapshotInterval
+			}
+		}
+	}
+
+	if cfg.MaxConcurrentVerifications > 0 {
+		weights, err := parseRequestClassWeights(cfg.RequestClassWeights)
+		if err != nil {
+			log.Printf("Warning: %v, using the default request class weights", err)
+			weights = DefaultRequestClassWeights
+		}
+		s.scheduler = newFairScheduler(cfg.MaxConcurrentVerifications, weights)
+	}
+
+	s.valueSchema = ValueSchemaV1
+	if cfg.ValueSchema != "" {
+		if knownValueSchema(cfg.ValueSchema) {
+			s.valueSchema = cfg.ValueSchema
+		} else {
+			log.Printf("Warning: unknown value schema %q, using %s", cfg.ValueSchema, ValueSchemaV1)
+		}
+	}
+	providers, err := parseValueSchemaProviders(cfg.ValueSchemaProviders)
+	if err != nil {
+		log.Printf("Warning: %v, using the default value schema for every provider", err)
+	}
+	s.valueSchemaProviders = providers
+
+	if cfg.MemoryLimit >= 0 {
+		limit, ok := cfg.MemoryLimit, cfg.MemoryLimit > 0
+		if !ok {
+			limit, ok = cgroupMemoryLimit()
+		}
+		thresholds, err := parseMemoryShedThresholds(cfg.MemoryShedThresholds)
+		if err != nil {
+			log.Printf("Warning: %v, using the default memory shed thresholds", err)
+			thresholds = DefaultMemoryShedThresholds
+		}
+		if ok {
+			s.memory = newMemoryShedder(limit, thresholds, DefaultMemoryCheckInterval)
+		} else {
+			log.Printf("No memory limit detected, memory load shedding disabled")
+		}
+	}
+
+	if cfg.MaxPinDuration > 0 {
+		s.pins = newPinStore(cfg.MaxPinDuration)
+		if cfg.PinStore == "" {
+			log.Printf("Warning: pins are kept by each replica, set PIN_STORE to share them between replicas")
+		} else if shared, err := newSharedPins(cfg.PinStore, verifier.kubeClient, verifier.namespace); err != nil {
+			log.Printf("Warning: %v, pins are kept by each replica", err)
+		} else {
+			s.pins.shared = shared
+		}
+	}
+
+	if cfg.ExpiryCheckInterval > 0 {
+		warning := cfg.ExpiryWarning
+		if warning <= 0 {
+			warning = DefaultExpiryWarning
+		}
+		s.expiry = newExpiryMonitor(cfg.ExpiryCheckInterval, warning, s.trustExpiries)
+	}
+
+	if cfg.ExceptionsFile != "" {
+		s.exceptions = newExceptionStore(cfg.ExceptionsFile)
+		if err := s.exceptions.Reload(); err != nil {
+			log.Printf("Warning: %v, starting without policy exceptions", err)
+		}
+	}
+
+	if cfg.WindowsFile != "" {
+		s.windows = newWindowStore(cfg.WindowsFile)
+		if err := s.windows.Reload(); err != nil {
+			log.Printf("Warning: %v, starting without verification windows", err)
+		}
+	}
+
+	if cfg.LeakCheckInterval > 0 {
+		s.leaks = newLeakMonitor(cfg.LeakCheckInterval)
+	}
+
+	if cfg.LogSamplingWindow > 0 {
+		s.logs = newLogSampler(cfg.LogSamplingWindow)
+	}
+
+	if len(cfg.UpstreamProviders) > 0 {
+		upstreams, err := parseUpstreamProviders(cfg.UpstreamProviders, cfg.UpstreamCABundle, cfg.Timeout)
+		if err != nil {
+			log.Printf("Warning: %v, upstream providers disabled", err)
+		}
+		s.upstreams = upstreams
+	}
+
+	if cfg.ShadowPolicyFile != "" {
+		policy, err := loadShadowPolicy(cfg.ShadowPolicyFile)
+		if err != nil {
+			log.Printf("Warning: %v, shadow verification disabled", err)
+		} else {
+			s.shadow = newShadowVerifier(policy, DefaultShadowConcurrency, func(ctx context.Context, key string) Item {
+				// Shadow verifications yield to batch traffic under memory pressure
+				if err := s.memory.Shed(RequestClassBatch); err != nil {
+					return Item{Key: key, Error: formatItemError("Failed to verify attestation or extract SBOM", err)}
+				}
+				return s.verifyImageRef(ctx, key)
+			})
+		}
+	}
+
+	if cfg.EnableChaos {
+		s.faults = newFaultInjector()
+	}
+
+	if cfg.AuditInterval > 0 {
+		audit, err := newAuditor(cfg.AuditInterval, cfg.AuditReplicas, cfg.AuditReplicaIndex, cfg.AuditKeySettings, verifier.kubeClient)
+		if err != nil {
+			log.Printf("Warning: %v, background audit disabled", err)
+		} else {
+			audit.check = func(key string) Item { return s.checkImage(key, RequestClassAudit) }
+			audit.logf = s.logs.Printf
+			s.audit = audit
+		}
+	}
+
+	if cfg.AsyncMode {
+		// Async mode relies on the cache to hand results back to later requests
+		if s.cacheTTL <= 0 {
+			s.cacheTTL = defaultAsyncCacheTTL
+		}
+		s.async = newAsyncVerifier(func(key, class string) Item {
+			return s.verifyScheduled(context.Background(), class, key)
+		}, s.cachedItem, func(key string, item Item) {
+			// Verifications before the trusted roots load are retried on the next request
+			if strings.HasPrefix(item.Error, ErrCodeTrustNotReady) {
+				return
+			}
+			// Failures are cached briefly, so they surface on the next request without a
+			// transient registry or Rekor failure denying the image for the whole TTL
+			ttl := s.cacheTTL
+			if item.Error != "" {
+				ttl = min(ttl, asyncFailureTTL)
+			}
+			s.cache.Set(s.cacheKey(key), item, ttl)
+			s.pins.Record(key, item)
+			s.shadow.Compare(key, item)
+		})
+		s.async.logf = s.logs.Printf
+	}
+
+	s.cache.jitter = cfg.CacheTTLJitter
+	s.refresher = newCacheRefresher(cfg.CacheRefreshAhead, s.cacheTTL, func(key string) Item {
+		return s.verifyScheduled(context.Background(), RequestClassBatch, key)
+	}, func(key string) (time.Time, bool) {
+		return s.cache.ExpiresAt(s.cacheKey(key))
+	}, func(key string, item Item) {
+		s.cache.Set(s.cacheKey(key), item, s.cacheTTL)
+	})
+
+	return s
 }
 
+// defaultAsyncCacheTTL is used in async mode when caching is otherwise disabled
+const defaultAsyncCacheTTL = 5 * time.Minute
+
+// asyncFailureTTL is how long failed async verifications are cached, at most
+const asyncFailureTTL = 30 * time.Second
+
+// defaultCacheSnapshotInterval is used when cache snapshots are enabled without an interval
+const defaultCacheSnapshotInterval = time.Minute
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
+	if s.async != nil {
+		s.async.Run(s.asyncWorkers)
+		defer s.async.ShutDown()
+	}
+	s.refresher.Run(cacheRefreshWorkers)
+	defer s.refresher.ShutDown()
+
+	sweepCtx, cancelSweep := context.WithCancel(context.Background())
+	defer cancelSweep()
+	go s.cache.Run(sweepCtx, cacheSweepInterval)
+	go s.digests.Run(sweepCtx, cacheSweepInterval)
+
+	if s.expiry != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.expiry.Run(ctx)
+	}
+
+	if s.leaks != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.leaks.Run(ctx)
+	}
+
+	if s.memory != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.memory.Run(ctx)
+	}
+
+	if s.logs != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.logs.Run(ctx)
+	}
+
+	if s.exceptions != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.exceptions.Run(ctx, DefaultExceptionsReloadInterval)
+	}
+
+	if s.windows != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.windows.Run(ctx, DefaultWindowsReloadInterval)
+	}
+
+	if s.audit != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			// Verifications before the trusted roots load would all fail
+			if err := s.verifier.WaitTrustedRoots(ctx); err != nil {
+				return
+			}
+			s.audit.Run(ctx)
+		}()
+	}
+
+	if s.snapshots != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			// Snapshot entries are stamped with the policy hash, known once the trusted roots load
+			if err := s.verifier.WaitTrustedRoots(ctx); err != nil {
+				return
+			}
+
+			// Restore before reporting ready so the first requests already hit a warm cache
+			restoreCtx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+			if err := s.restoreSnapshot(restoreCtx); err != nil {
+				log.Printf("Warning: failed to restore cache snapshot: %v", err)
+			}
+			cancel()
+			s.snapshotRestored.Store(true)
+
+			s.runSnapshots(ctx, s.snapshotInterval)
+		}()
+	}
+
+	if s.pins != nil && s.pins.shared != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.pins.Run(ctx, DefaultPinSyncInterval)
+	}
+
 	http.HandleFunc("/verify", s.handleVerify)
+	http.HandleFunc("/resolve", s.handleResolve)
+	http.HandleFunc("/mutate", s.handleMutate)
+	http.HandleFunc("/sarif", s.handleSARIF)
+	http.HandleFunc("/warmup", s.handleWarmup)
+	http.HandleFunc("/validate-key", s.handleValidateKey)
 	http.HandleFunc("/health", s.handleHealth)
+	http.HandleFunc("/readyz", s.handleReady)
+	http.HandleFunc("/openapi.json", s.handleOpenAPI)
+	http.HandleFunc("/metrics", s.handleMetrics)
+	if s.pins != nil {
+		if s.adminToken == "" {
+			log.Printf("Warning: MAX_PIN_DURATION requires ADMIN_TOKEN to authenticate /pins, result pinning disabled")
+		} else {
+			http.HandleFunc("/pins", s.handlePins)
+		}
+	}
+	if s.inspectToken != "" {
+		http.HandleFunc("/inspect", s.handleInspect)
+		http.HandleFunc("/search", s.handleSearch)
+	}
+	if s.faults != nil {
+		if s.adminToken == "" {
+			log.Printf("Warning: ENABLE_CHAOS requires ADMIN_TOKEN to authenticate /chaos, failure injection disabled")
+		} else {
+			log.Printf("WARNING: failure injection enabled via /chaos, do not use in production")
+			http.HandleFunc("/chaos", s.handleChaos)
+		}
+	}
 
 	addr := fmt.Sprintf(":%s", s.port)
+	srv := &http.Server{Addr: addr, ConnState: trackInboundConn}
 
 	// Start with TLS if certificates are provided
 	if s.tlsCert != "" && s.tlsKey != "" {
 		log.Printf("Starting SBOM provider server on %s (HTTPS)", addr)
-		return http.ListenAndServeTLS(addr, s.tlsCert, s.tlsKey, nil)
+		return srv.ListenAndServeTLS(s.tlsCert, s.tlsKey)
 	}
 
 	// Fallback to HTTP (not recommended for production)
 	log.Printf("Starting SBOM provider server on %s (HTTP - not recommended for production)", addr)
-	return http.ListenAndServe(addr, nil)
+	return srv.ListenAndServe()
 }
 
 // handleVerify handles the verification and SBOM extraction request
@@ -73,36 +534,48 @@ func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Received request with %d keys", len(providerReq.Request.Keys))
+	keys := requestKeys(providerReq.Request.Keys)
+	log.Printf("Received request with %d keys", len(keys))
 
 	// Process each image reference
-	items := make([]Item, 0, len(providerReq.Request.Keys))
-	for _, imageRef := range providerReq.Request.Keys {
-		item := s.processImageRef(imageRef)
-		items = append(items, item)
+	debug := debugRequested(r)
+	class := requestClass(r)
+	schema := s.valueSchemaFor(r)
+	items := s.resolveKeys(r.Context(), keys, debug, class)
+	for i := range items {
+		items[i].Value = convertValue(items[i].Value, schema)
 	}
 
+	// Let Gatekeeper cache responses whose items all stay valid for a while
+	cacheHint := s.responseCacheHint(keys, items, debug)
+
 	// Build response
 	response := ProviderResponse{
 		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
 		Kind:       "ProviderResponse",
 		Response: Response{
-			Items: items,
+			Items:      items,
+			Idempotent: cacheHint > 0,
 		},
 	}
 
 	// Log response summary
 	errorCount := 0
+	pendingCount := 0
 	for _, item := range items {
 		if item.Error != "" {
 			errorCount++
-			log.Printf("Error for %s: %s", item.Key, item.Error)
+			s.logs.Printf("Error for %s: %s", item.Key, item.Error)
+		} else if item.Value == pendingValue {
+			pendingCount++
 		}
 	}
-	log.Printf("Processed %d images (%d errors, %d successful)", len(items), errorCount, len(items)-errorCount)
+	log.Printf("Processed %d images (%d errors, %d pending, %d successful)",
+		len(items), errorCount, pendingCount, len(items)-errorCount-pendingCount)
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, cacheHint)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Error encoding response: %v", err)
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -110,14 +583,182 @@ func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// processImageRef processes a single image reference
-// The imageRef format is: image|secrets|certIdentity|certOidcIssuer
-func (s *Server) processImageRef(imageRef string) Item {
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+// requestKeys returns the keys of a request worth resolving. Empty and whitespace-only keys,
+// e.g. from a constraint reading a missing field, name no image and get no item, so a request
+// made only of them gets an empty response rather than errors.
+func requestKeys(keys []string) []string {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.TrimSpace(key) != "" {
+			valid = append(valid, key)
+		}
+	}
+	if ignored := len(keys) - len(valid); ignored > 0 {
+		log.Printf("Ignoring %d empty keys", ignored)
+	}
+	return valid
+}
+
+// resolveKeys resolves the provider keys of a request. The verified images of repositories
+// delegated to upstream providers are checked there in one request per upstream before the
+// policies apply, so exceptions and verification windows cover upstream failures too.
+func (s *Server) resolveKeys(ctx context.Context, keys []string, debug bool, class string) []Item {
+	items := make([]Item, len(keys))
+	opts := make([]keyOptions, len(keys))
+	for i, key := range keys {
+		items[i], opts[i] = s.verifyKey(key, debug, class)
+	}
+	s.delegateUpstream(ctx, items, opts)
+	for i := range items {
+		items[i] = s.applyPolicies(items[i], opts[i])
+	}
+	return items
+}
+
+// resolveKey resolves a single provider key like resolveKeys
+func (s *Server) resolveKey(key string, debug bool, class string) Item {
+	return s.resolveKeys(context.Background(), []string{key}, debug, class)[0]
+}
+
+// verifyKey returns the result for a provider key and its options, before upstream providers
+// and policies apply. Structured keys are resolved like the legacy key they convert to. Debug
+// keys are verified synchronously and uncached with a trace, so a single failing image can be
+// investigated without cluster-wide debug logging.
+func (s *Server) verifyKey(key string, debug bool, class string) (Item, keyOptions) {
+	imageRef, opts, err := parseKey(key)
+	if err != nil {
+		return Item{Key: key, Error: formatItemError("Invalid provider key", err)}, opts
+	}
+	if denied, ok := s.deniedItem(key, imageRef); ok {
+		return denied, opts
+	}
+	imageRef, resolved, failed := s.applyDigestMode(key, imageRef)
+	if failed.Error != "" {
+		return failed, opts
+	}
+	imageRef = opts.resultKey(imageRef)
+
+	var item Item
+	if debug || opts.debug {
+		item = s.traceImageRef(imageRef, class)
+	} else {
+		item = s.resolveItem(imageRef, class)
+	}
+	item.Key = key
+	return withResolvedDigest(item, resolved), opts
+}
+
+// traceImageRef verifies imageRef with a detailed trace and echoes the trace ID in the item
+func (s *Server) traceImageRef(imageRef, class string) Item {
+	id := newTraceID()
+	ctx := withTrace(context.Background(), id)
+	log.Printf("Debug verification of %s (trace ID: %s)", strings.SplitN(imageRef, "|", 2)[0], id)
+
+	// Debug keys see injected faults too, so their traces show what constraints get
+	item, injected := s.injectFault(ctx, imageRef)
+	if !injected {
+		item = s.verifyScheduled(ctx, class, imageRef)
+	}
+	if item.Error != "" {
+		tracef(ctx, "verification failed: %s", item.Error)
+		item.Error = fmt.Sprintf("%s (trace ID: %s)", item.Error, id)
+	} else {
+		tracef(ctx, "verification succeeded")
+	}
+	return item
+}
+
+// resolveItem returns the cached result for imageRef, or verifies it as class traffic.
+// In async mode uncached keys are queued and a pending item is returned immediately.
+func (s *Server) resolveItem(imageRef, class string) Item {
+	// Injected faults take precedence over cached results so constraints see them immediately
+	if item, injected := s.injectFault(context.Background(), imageRef); injected {
+		return item
+	}
+
+	// Pinned results hold for the whole pin window, even once the cache entry expires
+	if item, ok := s.pins.Get(imageRef); ok {
+		return item
+	}
+
+	if item, ok := s.cachedItem(imageRef); ok {
+		s.refresher.Touch(imageRef)
+		return s.pins.Record(imageRef, item)
+	}
+
+	if s.async != nil {
+		s.async.Enqueue(imageRef, class)
+		return Item{
+			Key:   imageRef,
+			Value: pendingValue,
+		}
+	}
+
+	item := s.verifyScheduled(context.Background(), class, imageRef)
+	s.shadow.Compare(imageRef, item)
+	// Only successful results are cached in sync mode so fixes to attestations take effect immediately
+	if item.Error == "" {
+		s.cache.Set(s.cacheKey(imageRef), item, s.cacheTTL)
+	}
+	return s.pins.Record(imageRef, item)
+}
+
+// cacheKey returns the result cache key for a provider key, which includes the
+// policy hash so results never outlive the policy they were verified under
+func (s *Server) cacheKey(key string) string {
+	if s.verifier == nil {
+		return key
+	}
+	return key + "|" + s.verifier.PolicyHashForKey(key)
+}
+
+// cachedItem returns the cached result for a provider key
+func (s *Server) cachedItem(key string) (Item, bool) {
+	return s.cache.Get(s.cacheKey(key))
+}
+
+// verifyScheduled verifies imageRef once class traffic is granted a verification slot, unless
+// class traffic is shed under memory pressure. Waiting for a slot counts against the server timeout.
+func (s *Server) verifyScheduled(ctx context.Context, class, imageRef string) Item {
+	if denied, ok := s.deniedItem(imageRef, imageRef); ok {
+		return denied
+	}
+	if err := s.memory.Shed(class); err != nil {
+		return Item{
+			Key:   imageRef,
+			Error: formatItemError("Failed to verify attestation or extract SBOM", err),
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	release, err := s.scheduler.Acquire(waitCtx, class)
+	cancel()
+	if err != nil {
+		return Item{
+			Key:   imageRef,
+			Error: formatItemError("Failed to verify attestation or extract SBOM", err),
+		}
+	}
+	defer release()
+
+	return s.verifyImageRef(ctx, imageRef)
+}
+
+// verifyImageRef verifies a single image reference within the server timeout
+func (s *Server) verifyImageRef(ctx context.Context, imageRef string) Item {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
+	// Result options were kept in the key so they are cached separately
+	key, opts := splitKeyOptions(imageRef)
+	// An exception for an unexpected signer needs the SBOM to turn the mismatch into a warning
+	if s.exceptions.Covers(strings.SplitN(key, "|", 2)[0], ErrCodeIdentityMismatch) {
+		opts.allViolations = true
+	}
+	ctx = withKeyOptions(ctx, opts)
+
 	// Parse the key to extract verification parameters
-	parts := strings.Split(imageRef, "|")
+	parts := strings.Split(key, "|")
 	certIdentity := ""
 	certOidcIssuer := ""
 	if len(parts) >= 4 {
@@ -126,20 +767,28 @@ func (s *Server) processImageRef(imageRef string) Item {
 	}
 
 	// Verify attestation and extract SBOM
-	sbomData, err := s.verifier.VerifyAndExtractSBOMWithParams(ctx, imageRef, certIdentity, certOidcIssuer)
+	sbomData, err := s.verifier.VerifyAndExtractSBOMWithParams(ctx, key, certIdentity, certOidcIssuer)
 	if err != nil {
+		if len(err.Error()) > maxItemErrorLength {
+			// Item.Error is truncated, keep the whole chain in the logs
+			s.logs.Printf("Verification of %s failed: %s", parts[0], redactCredentials(err.Error()))
+		}
 		return Item{
 			Key:   imageRef,
-			Error: fmt.Sprintf("Failed to verify attestation or extract SBOM: %v", err),
+			Error: formatItemError("Failed to verify attestation or extract SBOM", err),
 		}
 	}
 
+	if unified, ok := sbomData.(*UnifiedSBOM); ok {
+		unified.TraceID = traceID(ctx)
+	}
+
 	// Convert SBOM to JSON string
 	sbomJSON, err := json.Marshal(sbomData)
 	if err != nil {
 		return Item{
 			Key:   imageRef,
-			Error: fmt.Sprintf("Failed to marshal SBOM: %v", err),
+			Error: sanitizeItemError(fmt.Sprintf("Failed to marshal SBOM: %v", err)),
 		}
 	}
 
@@ -156,3 +805,29 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
+
+// ReadyStatus is the body of /readyz
+type ReadyStatus struct {
+	Status string `json:"status"`          // Trusted root state: "initializing", "ready" or "degraded"
+	Error  string `json:"error,omitempty"` // Last failure to load the trusted roots
+}
+
+// handleReady reports whether the provider can verify: ready once the trusted roots are
+// loaded (and the cache snapshot restored). A degraded provider keeps verifying with the
+// previously loaded roots and stays ready.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	state, err := s.verifier.TrustState()
+	status := ReadyStatus{Status: state}
+	if err != nil {
+		status.Error = sanitizeItemError(err.Error())
+	}
+
+	code := http.StatusOK
+	if state == TrustStateInitializing || (s.snapshots != nil && !s.snapshotRestored.Load()) {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -169,3 +169,100 @@ func TestProviderResponseSerialization(t *testing.T) {
 		t.Errorf("Expected key 'test:latest', got '%s'", decoded.Response.Items[0].Key)
 	}
 }
+
+func TestHandleVerifyAsyncPending(t *testing.T) {
+	cache := newResultCache()
+	release := make(chan struct{})
+
+	server := &Server{
+		port:     "8090",
+		timeout:  30 * time.Second,
+		cache:    cache,
+		cacheTTL: time.Minute,
+	}
+	server.async = newAsyncVerifier(func(key, class string) Item {
+		<-release
+		return Item{Key: key, Value: `{"format":"spdx","packages":[]}`}
+	}, cache.Get, func(key string, item Item) {
+		cache.Set(key, item, server.cacheTTL)
+	})
+	server.async.Run(1)
+	defer server.async.ShutDown()
+
+	item := server.resolveItem("localhost:5000/test:latest", RequestClassAdmission)
+	if item.Error != "" {
+		t.Fatalf("Expected no error, got '%s'", item.Error)
+	}
+
+	var pending PendingValue
+	if err := json.Unmarshal([]byte(item.Value), &pending); err != nil {
+		t.Fatalf("Failed to decode pending value: %v", err)
+	}
+
+	if pending.Status != StatusPending {
+		t.Errorf("Expected status '%s', got '%s'", StatusPending, pending.Status)
+	}
+
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		item = server.resolveItem("localhost:5000/test:latest", RequestClassAdmission)
+		if item.Value != pendingValue {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for async verification result")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if item.Value != `{"format":"spdx","packages":[]}` {
+		t.Errorf("Expected verified value, got '%s'", item.Value)
+	}
+}
+
+func TestHandleVerifyEmptyKeys(t *testing.T) {
+	server := &Server{
+		port:     "8090",
+		timeout:  30 * time.Second,
+		verifier: &AttestationVerifier{},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"no keys field", `{"request":{}}`, nil},
+		{"zero keys", `{"request":{"keys":[]}}`, nil},
+		{"duplicate empty strings", `{"request":{"keys":["",""]}}`, nil},
+		{"whitespace keys", `{"request":{"keys":[" ","\t\n"]}}`, nil},
+		{"blank keys among others", `{"request":{"keys":["","not a reference!"," "]}}`, []string{"not a reference!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for path, handler := range map[string]http.HandlerFunc{"/verify": server.handleVerify, "/resolve": server.handleResolve} {
+				req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(tt.body)))
+				w := httptest.NewRecorder()
+				handler(w, req)
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s: expected status 200, got %d: %s", path, w.Code, w.Body.String())
+				}
+				var response ProviderResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("%s: failed to decode response: %v", path, err)
+				}
+				if response.Response.SystemError != "" || len(response.Response.Items) != len(tt.want) {
+					t.Fatalf("%s: expected items for %v only, got %+v", path, tt.want, response.Response)
+				}
+				for i, key := range tt.want {
+					if response.Response.Items[i].Key != key || response.Response.Items[i].Error == "" {
+						t.Errorf("%s: expected an invalid key error for %q, got %+v", path, key, response.Response.Items[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultShadowConcurrency bounds the shadow verifications in flight. Verifications finishing
+// while every slot is busy are not compared.
+const DefaultShadowConcurrency = 2
+
+// Outcomes of shadow verifications, as counted in sbom_provider_shadow_verifications_total
+const (
+	shadowOutcomeMatch      = "match"       // Both policies allow, or both deny, the image
+	shadowOutcomeWouldDeny  = "would_deny"  // The shadow policy would deny an image the active one allows
+	shadowOutcomeWouldAllow = "would_allow" // The shadow policy would allow an image the active one denies
+	shadowOutcomeSkipped    = "skipped"     // Not compared: no free slot, or a transient failure
+)
+
+// ShadowPolicy is a candidate signer policy verified alongside the active one, e.g. the new
+// identities or key of a migration. Its outcomes are recorded but never returned.
+type ShadowPolicy struct {
+	Name string `json:"name"`
+	// Signer fields replace those of every key, like the constraint parameters of the same name
+	CertIdentity       string            `json:"certIdentity,omitempty"`
+	CertIdentityRegexp string            `json:"certIdentityRegexp,omitempty"`
+	CertOidcIssuer     string            `json:"certOidcIssuer,omitempty"`
+	Identities         []AllowedIdentity `json:"identities,omitempty"`
+	PublicKey          string            `json:"publicKey,omitempty"` // KMS key URI or k8s:// Secret
+	// SampleRate is the share of verifications also verified under the shadow policy (0 verifies all)
+	SampleRate float64 `json:"sampleRate,omitempty"`
+}
+
+// validate checks a shadow policy
+func (p *ShadowPolicy) validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("name is required")
+	case p.CertIdentity == "" && p.CertIdentityRegexp == "" && p.CertOidcIssuer == "" && len(p.Identities) == 0 && p.PublicKey == "":
+		return errors.New("a signer is required: certIdentity, certIdentityRegexp, identities or publicKey")
+	case p.SampleRate < 0 || p.SampleRate > 1:
+		return fmt.Errorf("sampleRate %v is not between 0 and 1", p.SampleRate)
+	case strings.Contains(p.CertIdentity, "|") || strings.Contains(p.CertOidcIssuer, "|"):
+		return errors.New("certIdentity and certOidcIssuer cannot contain pipes")
+	}
+	if p.PublicKey != "" && !isKMSRef(p.PublicKey) {
+		return fmt.Errorf("publicKey %q is not a KMS key URI", p.PublicKey)
+	}
+	if p.CertIdentityRegexp != "" {
+		if _, err := regexp.Compile(p.CertIdentityRegexp); err != nil {
+			return fmt.Errorf("invalid certIdentityRegexp: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadShadowPolicy reads and validates the JSON shadow policy at path
+func loadShadowPolicy(path string) (*ShadowPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shadow policy file: %w", err)
+	}
+
+	var policy ShadowPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse shadow policy file: %w", err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid shadow policy %s: %w", policy.Name, err)
+	}
+	return &policy, nil
+}
+
+// key returns the result key verifying the image of key under the shadow policy: the signer
+// of the key is replaced, its other options kept
+func (p *ShadowPolicy) key(key string) string {
+	legacy, opts := splitKeyOptions(key)
+	parts := strings.SplitN(legacy, "|", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	if parts[1] == "" {
+		parts[1] = "[]"
+	}
+	parts[2], parts[3] = p.CertIdentity, p.CertOidcIssuer
+
+	opts.identityRegexp = p.CertIdentityRegexp
+	opts.identities = ""
+	if len(p.Identities) > 0 {
+		identities, _ := json.Marshal(p.Identities)
+		opts.identities = string(identities)
+	}
+	opts.keyRef = p.PublicKey
+	opts.debug = false
+	return opts.resultKey(strings.Join(parts, "|"))
+}
+
+// shadowVerifier verifies images under the shadow policy after their active verification and
+// records where the outcomes differ. A nil shadowVerifier compares nothing.
+type shadowVerifier struct {
+	policy *ShadowPolicy
+	verify func(ctx context.Context, key string) Item
+	slots  chan struct{}
+	sample func() float64
+	wg     sync.WaitGroup
+
+	match, wouldDeny, wouldAllow, skipped atomic.Int64
+}
+
+// newShadowVerifier creates a shadow verifier running at most concurrency verifications with verify
+func newShadowVerifier(policy *ShadowPolicy, concurrency int, verify func(ctx context.Context, key string) Item) *shadowVerifier {
+	if concurrency <= 0 {
+		concurrency = DefaultShadowConcurrency
+	}
+	return &shadowVerifier{policy: policy, verify: verify, slots: make(chan struct{}, concurrency), sample: rand.Float64}
+}
+
+// Compare verifies the image of key under the shadow policy in the background, once active is
+// the result of its verification under the active policy. Pending results and transient
+// failures are not compared.
+func (v *shadowVerifier) Compare(key string, active Item) {
+	if v == nil || active.Value == pendingValue || transientItemError(active.Error) {
+		return
+	}
+	if v.policy.SampleRate > 0 && v.sample() >= v.policy.SampleRate {
+		return
+	}
+
+	select {
+	case v.slots <- struct{}{}:
+	default:
+		v.skipped.Add(1)
+		return
+	}
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		defer func() { <-v.slots }()
+		v.record(key, active, v.verify(context.Background(), v.policy.key(key)))
+	}()
+}
+
+// record counts the outcome of a comparison and logs outcomes that differ
+func (v *shadowVerifier) record(key string, active, shadow Item) {
+	if transientItemError(shadow.Error) {
+		v.skipped.Add(1)
+		return
+	}
+
+	image := strings.SplitN(key, "|", 2)[0]
+	switch activeOK, shadowOK := active.Error == "", shadow.Error == ""; {
+	case activeOK == shadowOK:
+		v.match.Add(1)
+	case activeOK:
+		v.wouldDeny.Add(1)
+		log.Printf("Shadow policy %s: outcome=%s image=%s shadowError=%q", v.policy.Name, shadowOutcomeWouldDeny, image, shadow.Error)
+	default:
+		v.wouldAllow.Add(1)
+		log.Printf("Shadow policy %s: outcome=%s image=%s activeError=%q", v.policy.Name, shadowOutcomeWouldAllow, image, active.Error)
+	}
+}
+
+// transientItemError reports whether an item error says nothing about the image, e.g. a
+// verification shed under memory pressure
+func transientItemError(err string) bool {
+	return strings.HasPrefix(err, ErrCodeTrustNotReady) || strings.HasPrefix(err, ErrCodeMemoryPressure)
+}
+
+// writeShadowMetrics writes the shadow verification counters in Prometheus text format
+func (v *shadowVerifier) writeShadowMetrics(w io.Writer) {
+	if v == nil {
+		return
+	}
+
+	const name = "sbom_provider_shadow_verifications_total"
+	fmt.Fprintf(w, "# HELP %s Verifications under the shadow policy, by outcome against the active policy.\n# TYPE %s counter\n", name, name)
+	for _, c := range []struct {
+		outcome string
+		count   *atomic.Int64
+	}{
+		{shadowOutcomeMatch, &v.match},
+		{shadowOutcomeWouldDeny, &v.wouldDeny},
+		{shadowOutcomeWouldAllow, &v.wouldAllow},
+		{shadowOutcomeSkipped, &v.skipped},
+	} {
+		fmt.Fprintf(w, "%s{policy=%q,outcome=%q} %d\n", name, v.policy.Name, c.outcome, c.count.Load())
+	}
+}
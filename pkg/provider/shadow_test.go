@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadShadowPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "shadow.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write policy: %v", err)
+		}
+		return path
+	}
+
+	policy, err := loadShadowPolicy(write(`{"name":"migration","certIdentityRegexp":"^https://gitlab\\.com/","certOidcIssuer":"https://gitlab.com"}`))
+	if err != nil || policy.Name != "migration" {
+		t.Fatalf("Failed to load the shadow policy: %+v, %v", policy, err)
+	}
+
+	for _, content := range []string{
+		`{"certIdentity":"ci@example.com"}`,
+		`{"name":"no-signer"}`,
+		`{"name":"bad","certIdentityRegexp":"("}`,
+		`{"name":"bad","publicKey":"/etc/cosign.pub"}`,
+		`{"name":"bad","certIdentity":"a|b"}`,
+		`{"name":"bad","certIdentity":"ci@example.com","sampleRate":2}`,
+		`not json`,
+	} {
+		if _, err := loadShadowPolicy(write(content)); err == nil {
+			t.Errorf("Expected an error for %s", content)
+		}
+	}
+	if _, err := loadShadowPolicy(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestShadowPolicyKey(t *testing.T) {
+	policy := &ShadowPolicy{
+		Name:           "migration",
+		CertIdentity:   "ci@example.com",
+		CertOidcIssuer: "https://accounts.google.com",
+		Identities:     []AllowedIdentity{{CertIdentity: "release@example.com"}},
+		PublicKey:      "awskms:///alias/new",
+	}
+	key := policy.key(`ghcr.io/org/app:v1|["regcred"]|old@example.com|https://github.com/login/oauth|identityRegexp=%5Eold,platform=linux/arm64,debug=true`)
+
+	imageRef, opts := splitKeyOptions(key)
+	if imageRef != `ghcr.io/org/app:v1|["regcred"]|ci@example.com|https://accounts.google.com` {
+		t.Errorf("Expected the signer replaced and pull secrets kept, got %q", imageRef)
+	}
+	if opts.identityRegexp != "" || opts.identities != `[{"certIdentity":"release@example.com"}]` || opts.keyRef != "awskms:///alias/new" {
+		t.Errorf("Expected the shadow signer options, got %+v", opts)
+	}
+	if opts.platform != "linux/arm64" || opts.debug {
+		t.Errorf("Expected other options kept without debug, got %+v", opts)
+	}
+
+	if got := policy.key("ghcr.io/org/app:v1"); !strings.HasPrefix(got, "ghcr.io/org/app:v1|[]|ci@example.com|https://accounts.google.com|") {
+		t.Errorf("Expected a bare image to get the shadow signer, got %q", got)
+	}
+}
+
+func TestShadowVerifierCompare(t *testing.T) {
+	var mu sync.Mutex
+	var verified []string
+	shadowErrors := map[string]string{
+		"ghcr.io/org/denied":    "ERR_IDENTITY_MISMATCH: signed by someone else",
+		"ghcr.io/org/both":      "ERR_NO_ATTESTATIONS: none",
+		"ghcr.io/org/transient": ErrCodeMemoryPressure + ": shed",
+	}
+	v := newShadowVerifier(&ShadowPolicy{Name: "migration", CertIdentity: "ci@example.com"}, 1, func(ctx context.Context, key string) Item {
+		image := strings.SplitN(key, "|", 2)[0]
+		mu.Lock()
+		verified = append(verified, key)
+		mu.Unlock()
+		return Item{Key: key, Value: `{"format":"spdx"}`, Error: shadowErrors[image]}
+	})
+
+	compare := func(key string, active Item) {
+		v.Compare(key, active)
+		v.wg.Wait()
+	}
+	compare("ghcr.io/org/app|[]||", Item{Value: `{"format":"spdx"}`})
+	compare("ghcr.io/org/denied|[]||", Item{Value: `{"format":"spdx"}`})
+	compare("ghcr.io/org/fixed|[]||", Item{Error: "ERR_IDENTITY_MISMATCH: signed by the old pipeline"})
+	compare("ghcr.io/org/both|[]||", Item{Error: "ERR_NO_ATTESTATIONS: none"})
+	compare("ghcr.io/org/both|[]||", Item{Error: "ERR_NO_ATTESTATIONS: none"})
+	compare("ghcr.io/org/transient|[]||", Item{Value: `{"format":"spdx"}`})
+
+	// Pending results and transient failures of the active policy are not compared
+	compare("ghcr.io/org/pending|[]||", Item{Value: pendingValue})
+	compare("ghcr.io/org/notready|[]||", Item{Error: ErrCodeTrustNotReady + ": loading"})
+
+	// Comparisons finding every slot busy are skipped
+	v.slots <- struct{}{}
+	v.Compare("ghcr.io/org/busy|[]||", Item{Value: `{"format":"spdx"}`})
+	<-v.slots
+
+	if len(verified) != 6 || !strings.Contains(verified[0], "|ci@example.com|") {
+		t.Errorf("Expected 6 shadow verifications with the shadow signer, got %v", verified)
+	}
+	if v.match.Load() != 3 || v.wouldDeny.Load() != 1 || v.wouldAllow.Load() != 1 || v.skipped.Load() != 2 {
+		t.Errorf("Expected 3 matches, 1 would deny, 1 would allow and 2 skipped, got %d, %d, %d and %d",
+			v.match.Load(), v.wouldDeny.Load(), v.wouldAllow.Load(), v.skipped.Load())
+	}
+
+	var buf bytes.Buffer
+	v.writeShadowMetrics(&buf)
+	if !strings.Contains(buf.String(), `sbom_provider_shadow_verifications_total{policy="migration",outcome="would_deny"} 1`) {
+		t.Errorf("Expected the would_deny count in the metrics, got:\n%s", buf.String())
+	}
+
+	// Sampled out verifications are neither verified nor counted
+	v.policy.SampleRate = 0.5
+	v.sample = func() float64 { return 0.7 }
+	compare("ghcr.io/org/sampled|[]||", Item{Value: `{"format":"spdx"}`})
+	if len(verified) != 6 {
+		t.Errorf("Expected sampled out verifications to be skipped, got %v", verified)
+	}
+
+	var nilVerifier *shadowVerifier
+	nilVerifier.Compare("ghcr.io/org/app|[]||", Item{})
+	nilVerifier.writeShadowMetrics(&buf)
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// SigstoreBundleMediaType is the media type of the Sigstore bundles newer cosign versions attach
+// attestations as. Older bundle versions use "application/vnd.dev.sigstore.bundle+json;version=0.x".
+const SigstoreBundleMediaType = "application/vnd.dev.sigstore.bundle.v0.3+json"
+
+// sigstoreBundle is the part of a Sigstore bundle, in its protobuf JSON encoding, the extraction
+// reads. The bundle itself is verified before extraction.
+type sigstoreBundle struct {
+	MediaType        string          `json:"mediaType"`
+	DSSEEnvelope     *dsseEnvelope   `json:"dsseEnvelope"`
+	MessageSignature json.RawMessage `json:"messageSignature"`
+}
+
+// dsseEnvelope is a DSSE envelope, as embedded in Sigstore bundles and stored by cosign
+type dsseEnvelope struct {
+	Payload     string `json:"payload"` // Base64-encoded in-toto statement
+	PayloadType string `json:"payloadType"`
+	Signatures  []struct {
+		KeyID string `json:"keyid,omitempty"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// unwrapSigstoreBundle returns the DSSE envelope of a Sigstore bundle as JSON, or attestation
+// unchanged when it is not a bundle. Bundles signing a message rather than an in-toto
+// statement carry no attestation.
+func unwrapSigstoreBundle(attestation []byte) ([]byte, error) {
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(attestation, &bundle); err != nil || !strings.HasPrefix(bundle.MediaType, sigstoreBundleMediaType) {
+		return attestation, nil
+	}
+	if bundle.DSSEEnvelope == nil {
+		if bundle.MessageSignature != nil {
+			return nil, errors.New("sigstore bundle carries a message signature, not an attestation")
+		}
+		return nil, errors.New("sigstore bundle carries no DSSE envelope")
+	}
+	return json.Marshal(bundle.DSSEEnvelope)
+}
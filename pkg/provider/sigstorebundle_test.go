@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	sgbundle "github.com/sigstore/sigstore-go/pkg/bundle"
+)
+
+func TestParseStatementSigstoreBundle(t *testing.T) {
+	statement := `{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://spdx.dev/Document","predicate":{"spdxVersion":"SPDX-2.3","packages":[{"name":"zlib"}]}}`
+
+	// As stored by verifyBundles
+	bundle := &sgbundle.Bundle{Bundle: &protobundle.Bundle{
+		MediaType: SigstoreBundleMediaType,
+		Content: &protobundle.Bundle_DsseEnvelope{DsseEnvelope: &protodsse.Envelope{
+			Payload:     []byte(statement),
+			PayloadType: "application/vnd.in-toto+json",
+			Signatures:  []*protodsse.Signature{{Sig: []byte("sig")}},
+		}},
+	}}
+	payload, err := bundle.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"v0.3 bundle", string(payload)},
+		{"v0.2 bundle", `{"mediaType":"application/vnd.dev.sigstore.bundle+json;version=0.2","verificationMaterial":{},"dsseEnvelope":{"payload":"` +
+			base64.StdEncoding.EncodeToString([]byte(statement)) + `","payloadType":"application/vnd.in-toto+json","signatures":[{"sig":"c2ln"}]}}`},
+		{"DSSE envelope", `{"payload":"` + base64.StdEncoding.EncodeToString([]byte(statement)) + `","payloadType":"application/vnd.in-toto+json"}`},
+		{"statement", statement},
+	}
+	verifier := &AttestationVerifier{}
+	for _, tt := range tests {
+		predicateType, _, err := verifier.parseStatement([]byte(tt.payload))
+		if err != nil || predicateType != "https://spdx.dev/Document" {
+			t.Errorf("%s: expected the SPDX statement, got %q and %v", tt.name, predicateType, err)
+		}
+		unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{{payload: []byte(tt.payload)}})
+		if err != nil || len(unified.Packages) != 1 {
+			t.Errorf("%s: expected the SBOM to be extracted, got %+v and %v", tt.name, unified, err)
+		}
+	}
+
+	if _, _, err := verifier.parseStatement([]byte(`{"mediaType":"` + SigstoreBundleMediaType + `","messageSignature":{"signature":"c2ln"}}`)); err == nil || !strings.Contains(err.Error(), "message signature") {
+		t.Errorf("Expected a message signature bundle to carry no attestation, got %v", err)
+	}
+	if _, _, err := verifier.parseStatement([]byte(`{"mediaType":"` + SigstoreBundleMediaType + `"}`)); err == nil {
+		t.Error("Expected a bundle without content to fail")
+	}
+}
@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cache snapshot locations accepted in ServerConfig.CacheSnapshot
+const (
+	// snapshotFilePrefix stores the snapshot as JSON in a local file, e.g. "file:/var/cache/sbom/snapshot.json"
+	snapshotFilePrefix = "file:"
+	// snapshotConfigMapPrefix stores the snapshot in a ConfigMap in the provider namespace, e.g. "configmap:sbom-provider-cache"
+	snapshotConfigMapPrefix = "configmap:"
+)
+
+// snapshotConfigMapKey is the ConfigMap binaryData key holding the gzipped snapshot
+const snapshotConfigMapKey = "snapshot.json.gz"
+
+// maxConfigMapSnapshotBytes keeps the compressed snapshot below the 1MiB ConfigMap limit
+const maxConfigMapSnapshotBytes = 900 * 1024
+
+// snapshotStore persists cache snapshots
+type snapshotStore interface {
+	// Load returns the stored entries, or none if no snapshot exists yet
+	Load(ctx context.Context) ([]cacheSnapshotEntry, error)
+	// Save replaces the stored snapshot
+	Save(ctx context.Context, entries []cacheSnapshotEntry) error
+}
+
+// newSnapshotStore creates the snapshot store described by spec
+func newSnapshotStore(spec string, kubeClient kubernetes.Interface, namespace string) (snapshotStore, error) {
+	switch {
+	case strings.HasPrefix(spec, snapshotFilePrefix):
+		return &fileSnapshotStore{path: strings.TrimPrefix(spec, snapshotFilePrefix)}, nil
+	case strings.HasPrefix(spec, snapshotConfigMapPrefix):
+		if kubeClient == nil {
+			return nil, fmt.Errorf("configmap cache snapshots require a kubernetes client")
+		}
+		return &configMapSnapshotStore{
+			client:    kubeClient,
+			namespace: namespace,
+			name:      strings.TrimPrefix(spec, snapshotConfigMapPrefix),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown cache snapshot location %q (expected %s<path> or %s<name>)",
+		spec, snapshotFilePrefix, snapshotConfigMapPrefix)
+}
+
+// fileSnapshotStore keeps the snapshot in a local JSON file
+type fileSnapshotStore struct {
+	path string
+}
+
+// Load reads the snapshot file
+func (f *fileSnapshotStore) Load(ctx context.Context) ([]cacheSnapshotEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cacheSnapshotEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid snapshot file %s: %w", f.path, err)
+	}
+	return entries, nil
+}
+
+// Save writes the snapshot file atomically
+func (f *fileSnapshotStore) Save(ctx context.Context, entries []cacheSnapshotEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// configMapSnapshotStore keeps the gzipped snapshot in a ConfigMap shared by all replicas
+type configMapSnapshotStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// Load reads the snapshot from the ConfigMap
+func (c *configMapSnapshotStore) Load(ctx context.Context) ([]cacheSnapshotEntry, error) {
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := cm.BinaryData[snapshotConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot in configmap %s: %w", c.name, err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot in configmap %s: %w", c.name, err)
+	}
+
+	var entries []cacheSnapshotEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("invalid snapshot in configmap %s: %w", c.name, err)
+	}
+	return entries, nil
+}
+
+// Save writes the snapshot to the ConfigMap, dropping the soonest-expiring entries
+// when it would not fit
+func (c *configMapSnapshotStore) Save(ctx context.Context, entries []cacheSnapshotEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ExpiresAt.After(entries[j].ExpiresAt) })
+
+	var data []byte
+	for {
+		var err error
+		data, err = gzipJSON(entries)
+		if err != nil {
+			return err
+		}
+		if len(data) <= maxConfigMapSnapshotBytes || len(entries) == 0 {
+			break
+		}
+		log.Printf("Warning: cache snapshot is %d bytes compressed, dropping %d soonest-expiring entries",
+			len(data), len(entries)-len(entries)/2)
+		entries = entries[:len(entries)/2]
+	}
+
+	configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+	cm, err := configMaps.Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: c.name, Namespace: c.namespace},
+			BinaryData: map[string][]byte{snapshotConfigMapKey: data},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+	cm.BinaryData[snapshotConfigMapKey] = data
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// gzipJSON encodes v as gzipped JSON
+func gzipJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isDigestCacheKey reports whether a cache key references its image by digest.
+// Only digest results are shared, a tag may point elsewhere by the time another replica reads it.
+func isDigestCacheKey(key string) bool {
+	return keyDigest(key) != ""
+}
+
+// restoreSnapshot warms the cache from the snapshot store
+func (s *Server) restoreSnapshot(ctx context.Context) error {
+	entries, err := s.snapshots.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	restored := s.cache.Restore(entries)
+	log.Printf("Restored %d of %d cached results from snapshot", restored, len(entries))
+	return nil
+}
+
+// saveSnapshot merges the cached digest results into the stored snapshot, so replicas
+// sharing a ConfigMap contribute to one working set
+func (s *Server) saveSnapshot(ctx context.Context) error {
+	entries := s.cache.Snapshot(isDigestCacheKey)
+
+	stored, err := s.snapshots.Load(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to read cache snapshot before saving, overwriting it: %v", err)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[e.Key] = true
+	}
+	now := time.Now()
+	for _, e := range stored {
+		if !seen[e.Key] && now.Before(e.ExpiresAt) && e.PolicyHash == s.verifier.PolicyHash() {
+			entries = append(entries, e)
+			seen[e.Key] = true
+		}
+	}
+
+	return s.snapshots.Save(ctx, entries)
+}
+
+// runSnapshots saves the cache every interval until ctx is done
+func (s *Server) runSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		saveCtx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+		if err := s.saveSnapshot(saveCtx); err != nil {
+			log.Printf("Warning: failed to save cache snapshot: %v", err)
+		}
+		cancel()
+	}
+}
+
+// snapshotTimeout bounds loading or saving a snapshot
+const snapshotTimeout = 30 * time.Second
@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCacheSnapshotRestore(t *testing.T) {
+	cache := newResultCache()
+	cache.SetPolicyHash("policy")
+	cache.Set("ghcr.io/myorg/app@"+testDigest, Item{Value: "sbom"}, time.Minute)
+	cache.Set("ghcr.io/myorg/app:v1", Item{Value: "sbom"}, time.Minute)
+	cache.Set("ghcr.io/myorg/broken@"+testDigest, Item{Error: "failed"}, time.Minute)
+
+	entries := cache.Snapshot(isDigestCacheKey)
+	if len(entries) != 1 || entries[0].Key != "ghcr.io/myorg/app@"+testDigest {
+		t.Fatalf("Expected only the successful digest entry, got %+v", entries)
+	}
+
+	warm := newResultCache()
+	warm.SetPolicyHash("policy")
+	if restored := warm.Restore(entries); restored != 1 {
+		t.Errorf("Expected 1 restored entry, got %d", restored)
+	}
+	if item, ok := warm.Get("ghcr.io/myorg/app@" + testDigest); !ok || item.Value != "sbom" {
+		t.Errorf("Expected restored entry, got %+v, %v", item, ok)
+	}
+
+	other := newResultCache()
+	other.SetPolicyHash("other-policy")
+	if restored := other.Restore(entries); restored != 0 {
+		t.Errorf("Expected entries from another policy to be skipped, restored %d", restored)
+	}
+
+	expired := []cacheSnapshotEntry{{Key: "k", ExpiresAt: time.Now().Add(-time.Second), PolicyHash: "policy"}}
+	if restored := warm.Restore(expired); restored != 0 {
+		t.Errorf("Expected expired entries to be skipped, restored %d", restored)
+	}
+}
+
+func TestFileSnapshotStore(t *testing.T) {
+	store := &fileSnapshotStore{path: filepath.Join(t.TempDir(), "snapshot.json")}
+
+	entries, err := store.Load(context.Background())
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Expected empty snapshot before the first save, got %v, %v", entries, err)
+	}
+
+	saved := []cacheSnapshotEntry{{Key: "k", Item: Item{Key: "k", Value: "v"}, ExpiresAt: time.Now().Add(time.Minute), PolicyHash: "p"}}
+	if err := store.Save(context.Background(), saved); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	entries, err = store.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Item.Value != "v" {
+		t.Errorf("Expected saved entry, got %+v", entries)
+	}
+}
+
+func TestConfigMapSnapshotStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store, err := newSnapshotStore("configmap:sbom-provider-cache", client, "gatekeeper-system")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+	for i, value := range []string{"first", "second"} {
+		saved := []cacheSnapshotEntry{{Key: "k", Item: Item{Value: value}, ExpiresAt: time.Now().Add(time.Minute), PolicyHash: "p"}}
+		if err := store.Save(ctx, saved); err != nil {
+			t.Fatalf("Save %d failed: %v", i, err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("gatekeeper-system").Get(ctx, "sbom-provider-cache", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected configmap to be created: %v", err)
+	}
+	if _, ok := cm.BinaryData[snapshotConfigMapKey]; !ok {
+		t.Errorf("Expected snapshot under %s", snapshotConfigMapKey)
+	}
+
+	entries, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Item.Value != "second" {
+		t.Errorf("Expected latest saved entry, got %+v", entries)
+	}
+}
+
+func TestNewSnapshotStoreValidation(t *testing.T) {
+	if _, err := newSnapshotStore("s3://bucket", nil, ""); err == nil {
+		t.Error("Expected error for unknown location")
+	}
+	if _, err := newSnapshotStore("configmap:cache", nil, ""); err == nil {
+		t.Error("Expected error for configmap without a kubernetes client")
+	}
+}
+
+func TestSaveSnapshotMergesReplicas(t *testing.T) {
+	verifier := &AttestationVerifier{kubeClient: fake.NewSimpleClientset(), namespace: "gatekeeper-system"}
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+
+	first := NewServer(ServerConfig{CacheTTL: time.Minute, CacheSnapshot: "configmap:cache"}, verifier)
+	second := NewServer(ServerConfig{CacheTTL: time.Minute, CacheSnapshot: "configmap:cache"}, verifier)
+
+	firstKey := "ghcr.io/myorg/app@" + testDigest + "|[]||"
+	secondKey := "ghcr.io/myorg/other@" + testDigest + "|[]||"
+	first.cache.Set(first.cacheKey(firstKey), Item{Key: firstKey, Value: "a"}, time.Minute)
+	second.cache.Set(second.cacheKey(secondKey), Item{Key: secondKey, Value: "b"}, time.Minute)
+
+	ctx := context.Background()
+	if err := first.saveSnapshot(ctx); err != nil {
+		t.Fatalf("First save failed: %v", err)
+	}
+	if err := second.saveSnapshot(ctx); err != nil {
+		t.Fatalf("Second save failed: %v", err)
+	}
+
+	// A new replica starts warm with both replicas' results
+	scaled := NewServer(ServerConfig{CacheTTL: time.Minute, CacheSnapshot: "configmap:cache"}, verifier)
+	if err := scaled.restoreSnapshot(ctx); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for _, key := range []string{firstKey, secondKey} {
+		if _, ok := scaled.cachedItem(key); !ok {
+			t.Errorf("Expected %s to be restored", key)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// spdx3PredicateTypes are the predicate types of SPDX 3 attestations. SPDX 3 documents
+// attested under the SPDX 2 predicate types are recognized by their JSON-LD graph.
+var spdx3PredicateTypes = map[string]bool{
+	"https://spdx.dev/Document/v3":                       true,
+	"https://spdx.dev/Document/v3.0":                     true,
+	"https://spdx.dev/Document/v3.0.1":                   true,
+	"https://spdx.org/rdf/3.0.0/terms/Core/SpdxDocument": true,
+	"https://spdx.org/rdf/3.0.1/terms/Core/SpdxDocument": true,
+}
+
+// Individuals SPDX 3 uses for licenses that are not asserted or absent
+const (
+	spdx3NoAssertionLicense = "NoAssertionLicense"
+	spdx3NoneLicense        = "NoneLicense"
+	spdxListedLicensePrefix = "https://spdx.org/licenses/"
+)
+
+// isSPDX3 reports whether an SPDX predicate is an SPDX 3 document: a JSON-LD graph of elements
+// instead of the SPDX 2 document object
+func isSPDX3(predicateType string, predicate json.RawMessage) bool {
+	return spdx3PredicateTypes[predicateType] || bytes.Contains(predicate, []byte(`"@graph"`))
+}
+
+// spdx3Document is an SPDX 3 document in its JSON-LD serialization
+type spdx3Document struct {
+	Graph []spdx3Element `json:"@graph"`
+}
+
+// spdx3Element holds the properties of the SPDX 3 element classes the provider reads. Element
+// types are distinguished by type; properties of other classes are left empty.
+type spdx3Element struct {
+	Type   string `json:"type"`
+	AtType string `json:"@type"`
+	SPDXID string `json:"spdxId"`
+	AtID   string `json:"@id"`
+	Name   string `json:"name"`
+
+	CreationInfo json.RawMessage `json:"creationInfo"` // Reference to a CreationInfo, or the CreationInfo itself
+
+	// CreationInfo
+	Created      string   `json:"created"`
+	CreatedBy    []string `json:"createdBy"`
+	CreatedUsing []string `json:"createdUsing"`
+
+	// software_Package
+	PackageVersion     string                    `json:"software_packageVersion"`
+	PackageURL         string                    `json:"software_packageUrl"`
+	PrimaryPurpose     string                    `json:"software_primaryPurpose"`
+	ExternalIdentifier []spdx3ExternalIdentifier `json:"externalIdentifier"`
+
+	// Relationship
+	From             string   `json:"from"`
+	RelationshipType string   `json:"relationshipType"`
+	To               []string `json:"to"`
+
+	// simplelicensing_LicenseExpression
+	LicenseExpression string `json:"simplelicensing_licenseExpression"`
+
+	// Annotation
+	Subject   string `json:"subject"`
+	Statement string `json:"statement"`
+}
+
+// spdx3ExternalIdentifier is an identifier of an element, such as its package URL
+type spdx3ExternalIdentifier struct {
+	ExternalIdentifierType string `json:"externalIdentifierType"`
+	Identifier             string `json:"identifier"`
+}
+
+// id returns the identifier of an element, an IRI or a blank node
+func (e *spdx3Element) id() string {
+	if e.SPDXID != "" {
+		return e.SPDXID
+	}
+	return e.AtID
+}
+
+// class returns the compact type name of an element, e.g. "software_Package", whether it is
+// given compact or as the IRI of its class
+func (e *spdx3Element) class() string {
+	t := e.Type
+	if t == "" {
+		t = e.AtType
+	}
+	if i := strings.Index(t, "/terms/"); i >= 0 {
+		profile, name, ok := strings.Cut(t[i+len("/terms/"):], "/")
+		if !ok {
+			return t
+		}
+		if profile == "Core" {
+			return name
+		}
+		return strings.ToLower(profile) + "_" + name
+	}
+	return t
+}
+
+// purl returns the package URL of a package element
+func (e *spdx3Element) purl() string {
+	if e.PackageURL != "" {
+		return e.PackageURL
+	}
+	for _, id := range e.ExternalIdentifier {
+		if id.ExternalIdentifierType == "packageUrl" || id.ExternalIdentifierType == "purl" {
+			return id.Identifier
+		}
+	}
+	return ""
+}
+
+// extractAndNormalizeSPDX3 extracts and normalizes an SPDX 3 SBOM. Packages are the
+// software_Package elements of the graph, with their concluded (or else declared) license
+// taken from their license relationships.
+func (v *AttestationVerifier) extractAndNormalizeSPDX3(predicate json.RawMessage) (*UnifiedSBOM, error) {
+	var doc spdx3Document
+	if err := json.Unmarshal(predicate, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX 3 SBOM: %w", err)
+	}
+	if len(doc.Graph) == 0 {
+		return nil, fmt.Errorf("failed to parse SPDX 3 SBOM: no elements in @graph")
+	}
+
+	elements := make(map[string]*spdx3Element, len(doc.Graph))
+	concluded := make(map[string][]string)
+	declared := make(map[string][]string)
+	annotations := make(map[string][]SPDXAnnotation)
+	for i := range doc.Graph {
+		e := &doc.Graph[i]
+		if id := e.id(); id != "" {
+			elements[id] = e
+		}
+		switch e.class() {
+		case "Relationship":
+			switch e.RelationshipType {
+			case "hasConcludedLicense":
+				concluded[e.From] = append(concluded[e.From], e.To...)
+			case "hasDeclaredLicense":
+				declared[e.From] = append(declared[e.From], e.To...)
+			}
+		case "Annotation":
+			annotations[e.Subject] = append(annotations[e.Subject], SPDXAnnotation{Comment: e.Statement})
+		}
+	}
+
+	unified := &UnifiedSBOM{Format: "spdx", Packages: []UnifiedPackage{}}
+	for i := range doc.Graph {
+		e := &doc.Graph[i]
+		switch e.class() {
+		case "SpdxDocument":
+			if unified.Document == nil {
+				unified.Document = spdx3DocumentMetadata(e, elements)
+			}
+		case "software_Package":
+			if e.PrimaryPurpose == "operatingSystem" {
+				unified.osDetected = true
+			}
+			license := spdx3License(concluded[e.id()], elements)
+			if license == "" {
+				license = spdx3License(declared[e.id()], elements)
+			}
+			normalized := v.licenseAliases.normalize(license)
+
+			layer := spdxLayer(annotations[e.id()])
+			unified.Packages = append(unified.Packages, UnifiedPackage{
+				Name:        e.Name,
+				Version:     e.PackageVersion,
+				License:     normalized,
+				LicenseRaw:  rawLicense(license, normalized),
+				Licenses:    packageLicenses(normalized),
+				PURL:        e.purl(),
+				LayerDigest: layer.digest,
+				LayerDiffID: layer.diffID,
+			})
+		}
+	}
+	return unified, nil
+}
+
+// spdx3License renders the licenses an SPDX 3 license relationship points to, joined like the
+// licenses of a CycloneDX component
+func spdx3License(ids []string, elements map[string]*spdx3Element) string {
+	var licenses []string
+	for _, id := range ids {
+		if license := spdx3LicenseOf(id, elements); license != "" {
+			licenses = append(licenses, license)
+		}
+	}
+	return joinLicenses(licenses)
+}
+
+// spdx3LicenseOf renders a single license element as an SPDX license expression
+func spdx3LicenseOf(id string, elements map[string]*spdx3Element) string {
+	switch {
+	case strings.HasSuffix(id, "/"+spdx3NoAssertionLicense):
+		return "NOASSERTION"
+	case strings.HasSuffix(id, "/"+spdx3NoneLicense):
+		return "NONE"
+	case strings.HasPrefix(id, spdxListedLicensePrefix):
+		return strings.TrimPrefix(id, spdxListedLicensePrefix)
+	}
+
+	e, ok := elements[id]
+	if !ok {
+		return ""
+	}
+	switch e.class() {
+	case "simplelicensing_LicenseExpression":
+		return strings.TrimSpace(e.LicenseExpression)
+	case "expandedlicensing_ListedLicense":
+		return strings.TrimPrefix(e.id(), spdxListedLicensePrefix)
+	}
+	return e.Name
+}
+
+// spdx3DocumentMetadata returns the document metadata of an SPDX 3 SBOM. The document's
+// spdxId takes the place of the SPDX 2 document namespace, and its creators and tools are
+// rendered like SPDX 2 creators, e.g. "Tool: syft-1.4.1".
+func spdx3DocumentMetadata(doc *spdx3Element, elements map[string]*spdx3Element) *SBOMDocument {
+	meta := &SBOMDocument{Name: doc.Name, Namespace: doc.id()}
+	info := spdx3CreationInfo(doc.CreationInfo, elements)
+	if info == nil {
+		return meta
+	}
+	meta.setCreated(info.Created)
+	for _, id := range info.CreatedBy {
+		if agent, ok := elements[id]; ok && agent.Name != "" {
+			meta.Creators = append(meta.Creators, agent.class()+": "+agent.Name)
+		}
+	}
+	for _, id := range info.CreatedUsing {
+		if tool, ok := elements[id]; ok && tool.Name != "" {
+			meta.Creators = append(meta.Creators, spdxToolPrefix+" "+tool.Name)
+			meta.Tools = append(meta.Tools, tool.Name)
+		}
+	}
+	return meta
+}
+
+// spdx3CreationInfo resolves the creation info of an element, given as a reference to a
+// CreationInfo of the graph or inline
+func spdx3CreationInfo(raw json.RawMessage, elements map[string]*spdx3Element) *spdx3Element {
+	if len(raw) == 0 {
+		return nil
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return elements[id]
+	}
+	var info spdx3Element
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil
+	}
+	return &info
+}
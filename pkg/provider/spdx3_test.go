@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// spdx3Predicate is an SPDX 3.0.1 document as syft writes it, trimmed to a few packages
+const spdx3Predicate = `{
+  "@context": "https://spdx.org/rdf/3.0.1/spdx-context.jsonld",
+  "@graph": [
+    {"type": "CreationInfo", "@id": "_:creationinfo", "created": "2024-05-01T10:00:00+02:00", "createdBy": ["urn:org:anchore"], "createdUsing": ["urn:tool:syft"], "specVersion": "3.0.1"},
+    {"type": "Organization", "spdxId": "urn:org:anchore", "name": "Anchore, Inc", "creationInfo": "_:creationinfo"},
+    {"type": "Tool", "spdxId": "urn:tool:syft", "name": "syft-1.14.0", "creationInfo": "_:creationinfo"},
+    {"type": "SpdxDocument", "spdxId": "https://anchore.com/syft/image/alpine-3.20", "name": "alpine", "creationInfo": "_:creationinfo", "rootElement": ["urn:pkg:alpine"]},
+    {"type": "software_Package", "spdxId": "urn:pkg:alpine", "name": "alpine", "software_packageVersion": "3.20.0", "software_primaryPurpose": "operatingSystem", "creationInfo": "_:creationinfo"},
+    {"type": "software_Package", "spdxId": "urn:pkg:zlib", "name": "zlib", "software_packageVersion": "1.3.1-r1", "software_packageUrl": "pkg:apk/alpine/zlib@1.3.1-r1", "creationInfo": "_:creationinfo"},
+    {"type": "https://spdx.org/rdf/3.0.1/terms/Software/Package", "@id": "urn:pkg:musl", "name": "musl", "software_packageVersion": "1.2.5-r0",
+      "externalIdentifier": [{"type": "ExternalIdentifier", "externalIdentifierType": "packageUrl", "identifier": "pkg:apk/alpine/musl@1.2.5-r0"}], "creationInfo": "_:creationinfo"},
+    {"type": "simplelicensing_LicenseExpression", "spdxId": "urn:license:zlib", "simplelicensing_licenseExpression": "Zlib", "creationInfo": "_:creationinfo"},
+    {"type": "Relationship", "spdxId": "urn:rel:1", "from": "urn:pkg:zlib", "relationshipType": "hasConcludedLicense", "to": ["https://spdx.org/rdf/3.0.1/terms/Expanded/NoAssertionLicense"], "creationInfo": "_:creationinfo"},
+    {"type": "Relationship", "spdxId": "urn:rel:2", "from": "urn:pkg:zlib", "relationshipType": "hasDeclaredLicense", "to": ["urn:license:zlib"], "creationInfo": "_:creationinfo"},
+    {"type": "Relationship", "spdxId": "urn:rel:3", "from": "urn:pkg:musl", "relationshipType": "hasDeclaredLicense", "to": ["https://spdx.org/licenses/MIT"], "creationInfo": "_:creationinfo"},
+    {"type": "Annotation", "spdxId": "urn:annotation:1", "subject": "urn:pkg:musl", "annotationType": "other", "statement": "LayerDiffID: sha256:94e5f06f", "creationInfo": "_:creationinfo"}
+  ]
+}`
+
+func TestExtractSPDX3(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	att := vulnAttestation(t, "https://spdx.dev/Document/v3", spdx3Predicate, time.Now())
+	sbom, err := verifier.extractSBOMFromAttestation(att.payload)
+	if err != nil {
+		t.Fatalf("Failed to extract SPDX 3 SBOM: %v", err)
+	}
+	unified := sbom.(*UnifiedSBOM)
+	if unified.Format != "spdx" || len(unified.Packages) != 3 || !unified.osDetected {
+		t.Fatalf("Expected 3 SPDX packages with the OS detected, got %+v", unified)
+	}
+
+	if pkg := unified.Packages[1]; pkg.Name != "zlib" || pkg.Version != "1.3.1-r1" || pkg.PURL != "pkg:apk/alpine/zlib@1.3.1-r1" || pkg.License != "NOASSERTION" {
+		t.Errorf("Expected zlib with its purl and concluded license, got %+v", pkg)
+	}
+	if pkg := unified.Packages[2]; pkg.PURL != "pkg:apk/alpine/musl@1.2.5-r0" || pkg.License != "MIT" || pkg.LayerDiffID != "sha256:94e5f06f" {
+		t.Errorf("Expected musl with its declared license, external identifier and layer, got %+v", pkg)
+	}
+
+	want := &SBOMDocument{
+		Name:      "alpine",
+		Namespace: "https://anchore.com/syft/image/alpine-3.20",
+		Created:   "2024-05-01T08:00:00Z",
+		Tools:     []string{"syft-1.14.0"},
+		Creators:  []string{"Organization: Anchore, Inc", "Tool: syft-1.14.0"},
+	}
+	if !reflect.DeepEqual(unified.Document, want) {
+		t.Errorf("Expected document %+v, got %+v", want, unified.Document)
+	}
+
+	// SPDX 3 documents attested under the SPDX 2 predicate type are recognized by their graph
+	att = vulnAttestation(t, "https://spdx.dev/Document", spdx3Predicate, time.Now())
+	if unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{att}); err != nil || len(unified.Packages) != 3 {
+		t.Errorf("Expected the SPDX 3 document under the SPDX predicate type, got %+v, %v", unified, err)
+	}
+
+	allowlist, err := parsePredicateTypes([]string{"https://spdx.org/rdf/3.0.1/terms/Core/SpdxDocument"})
+	if err != nil || !allowlist.allows("https://spdx.org/rdf/3.0.1/terms/Core/SpdxDocument") || allowlist.allows("https://spdx.dev/Document") {
+		t.Errorf("Expected the SPDX 3 predicate type to be accepted on its own, got %v, %v", allowlist, err)
+	}
+	if !(predicateTypeAllowlist{"spdx"}).allows("https://spdx.dev/Document/v3") {
+		t.Error("Expected the spdx format to accept SPDX 3 predicate types")
+	}
+}
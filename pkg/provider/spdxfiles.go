@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// maxFileLicenses bounds the distinct licenses reported from the files of an SPDX SBOM
+const maxFileLicenses = 100
+
+// SPDXFile is the part of an SPDX file entry the files summary reads
+type SPDXFile struct {
+	LicenseConcluded   string   `json:"licenseConcluded,omitempty"`
+	LicenseInfoInFiles []string `json:"licenseInfoInFiles,omitempty"`
+}
+
+// SBOMFilesSummary aggregates the files section of an SPDX SBOM without its entries, which
+// make up most of the size of large SBOMs
+type SBOMFilesSummary struct {
+	Count                int      `json:"count"`                          // File entries in the SBOM
+	Licenses             []string `json:"licenses,omitempty"`             // Distinct licenses concluded for or found in files, sorted
+	LicensesTruncated    bool     `json:"licensesTruncated,omitempty"`    // More than maxFileLicenses distinct licenses were found
+	FilesWithoutLicenses int      `json:"filesWithoutLicenses,omitempty"` // Files with neither a concluded license nor licenses found in them
+}
+
+// summarizeSPDXFiles returns the summary of the files of an SPDX document, nil when it lists
+// none. Licenses are normalized like package licenses and split into their identifiers.
+func (v *AttestationVerifier) summarizeSPDXFiles(predicate json.RawMessage) (*SBOMFilesSummary, error) {
+	var doc struct {
+		Files []SPDXFile `json:"files"`
+	}
+	if err := json.Unmarshal(predicate, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse SPDX files: %w", err)
+	}
+	if len(doc.Files) == 0 {
+		return nil, nil
+	}
+
+	summary := &SBOMFilesSummary{Count: len(doc.Files)}
+	seen := make(map[string]bool)
+	for _, file := range doc.Files {
+		var licenses []string
+		for _, license := range append([]string{file.LicenseConcluded}, file.LicenseInfoInFiles...) {
+			licenses = append(licenses, packageLicenses(v.licenseAliases.normalize(license))...)
+		}
+		if len(licenses) == 0 {
+			summary.FilesWithoutLicenses++
+		}
+		for _, license := range licenses {
+			if seen[license] {
+				continue
+			}
+			if len(seen) == maxFileLicenses {
+				summary.LicensesTruncated = true
+				continue
+			}
+			seen[license] = true
+			summary.Licenses = append(summary.Licenses, license)
+		}
+	}
+	sort.Strings(summary.Licenses)
+	return summary, nil
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeSPDXFiles(t *testing.T) {
+	aliases, _ := loadLicenseAliases("")
+	verifier := &AttestationVerifier{licenseAliases: aliases, spdxFilesSummary: true}
+
+	spdx := `{"spdxVersion": "SPDX-2.3", "packages": [{"name": "app", "versionInfo": "1.0"}], "files": [
+		{"fileName": "/usr/lib/a.so", "licenseConcluded": "MIT", "licenseInfoInFiles": ["MIT", "Apache License 2.0"]},
+		{"fileName": "/usr/lib/b.so", "licenseConcluded": "NOASSERTION", "licenseInfoInFiles": ["GPL-2.0-only OR MIT"]},
+		{"fileName": "/etc/os-release", "licenseConcluded": "NOASSERTION", "licenseInfoInFiles": ["NONE"]},
+		{"fileName": "/bin/sh"}
+	]}`
+	unified, err := verifier.extractAndNormalizeSPDX(json.RawMessage(spdx))
+	if err != nil {
+		t.Fatalf("Expected the SPDX SBOM to parse, got %v", err)
+	}
+	files := unified.Files
+	if files == nil {
+		t.Fatal("Expected a files summary")
+	}
+	if files.Count != 4 || files.FilesWithoutLicenses != 2 || files.LicensesTruncated {
+		t.Errorf("Expected 4 files, 2 without licenses, got %+v", files)
+	}
+	if got := strings.Join(files.Licenses, ","); got != "Apache-2.0,GPL-2.0-only,MIT" {
+		t.Errorf("Expected the normalized file licenses, got %s", got)
+	}
+	if len(unified.Packages) != 1 {
+		t.Errorf("Expected the packages to be extracted as usual, got %d", len(unified.Packages))
+	}
+
+	verifier.spdxFilesSummary = false
+	if unified, err := verifier.extractAndNormalizeSPDX(json.RawMessage(spdx)); err != nil || unified.Files != nil {
+		t.Errorf("Expected no files summary unless enabled, got %+v, %v", unified.Files, err)
+	}
+
+	verifier.spdxFilesSummary = true
+	if unified, err := verifier.extractAndNormalizeSPDX(json.RawMessage(`{"packages": []}`)); err != nil || unified.Files != nil {
+		t.Errorf("Expected no files summary without files, got %+v, %v", unified.Files, err)
+	}
+}
+
+func TestSummarizeSPDXFilesTruncated(t *testing.T) {
+	var entries []string
+	for i := 0; i < maxFileLicenses+10; i++ {
+		entries = append(entries, fmt.Sprintf(`{"licenseConcluded": "LicenseRef-%d"}`, i))
+	}
+	files, err := (&AttestationVerifier{}).summarizeSPDXFiles(json.RawMessage(`{"files": [` + strings.Join(entries, ",") + `]}`))
+	if err != nil {
+		t.Fatalf("Expected the files to parse, got %v", err)
+	}
+	if len(files.Licenses) != maxFileLicenses || !files.LicensesTruncated || files.Count != maxFileLicenses+10 {
+		t.Errorf("Expected %d licenses and the truncation flag, got %d, %+v", maxFileLicenses, len(files.Licenses), files.LicensesTruncated)
+	}
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StructuredKey is the JSON form of a provider key, with named fields instead of the positions
+// of "image|secrets|identity|issuer|options". Keys are sent as the JSON object itself or as its
+// base64 encoding.
+type StructuredKey struct {
+	Image       string     `json:"image"`
+	PullSecrets []string   `json:"pullSecrets,omitempty"`
+	Identity    string     `json:"identity,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	Namespace   string     `json:"namespace,omitempty"` // Namespace pull secrets are read from, the provider's by default
+	Policy      *KeyPolicy `json:"policy,omitempty"`
+}
+
+// KeyPolicy holds the per-constraint options of a structured key, named like the constraint
+// parameters they come from
+type KeyPolicy struct {
+	CertIdentityRegexp    string            `json:"certIdentityRegexp,omitempty"`
+	Identities            []AllowedIdentity `json:"identities,omitempty"`
+	CertExtensions        *CertExtensions   `json:"certExtensions,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty"`
+	PredicateTypes        []string          `json:"predicateTypes,omitempty"`
+	Platform              string            `json:"platform,omitempty"` // os/arch[/variant]
+	RequireImageSignature bool              `json:"requireImageSignature,omitempty"`
+	UnsignedBuildxSBOMs   string            `json:"unsignedBuildxSBOMs,omitempty"` // "fail" or "inventory"
+	PublicKey             string            `json:"publicKey,omitempty"`           // KMS key URI
+	SkipPackages          bool              `json:"skipPackages,omitempty"`
+	ReportAllViolations   bool              `json:"reportAllViolations,omitempty"`
+	Debug                 bool              `json:"debug,omitempty"`
+}
+
+// namespacePattern matches Kubernetes namespace names
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// parseKey returns a provider key in the legacy form without its options segment, and its
+// options. Structured keys are converted; legacy keys are split like splitKeyOptions does.
+func parseKey(key string) (string, keyOptions, error) {
+	data, ok := structuredKeyData(key)
+	if !ok {
+		imageRef, opts := splitKeyOptions(key)
+		return imageRef, opts, nil
+	}
+
+	var sk StructuredKey
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// A misspelled policy field must fail rather than verify with a looser policy
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&sk); err != nil {
+		return "", keyOptions{}, fmt.Errorf("invalid structured key: %w", err)
+	}
+	return sk.legacy()
+}
+
+// structuredKeyData returns the JSON of a structured key, decoding it from base64 if needed
+func structuredKeyData(key string) ([]byte, bool) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "{") {
+		return []byte(key), true
+	}
+	// Base64 of a JSON object starts with "ey" ("{" followed by a quote or space)
+	if !strings.HasPrefix(key, "ey") || strings.Contains(key, "|") {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(key); err == nil && bytes.HasPrefix(data, []byte("{")) {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// legacy returns the key in the legacy form without its options segment, and its options.
+// Fields that are positional in the legacy form cannot contain pipes; policy values are
+// carried escaped.
+func (k *StructuredKey) legacy() (string, keyOptions, error) {
+	var opts keyOptions
+	if k.Image == "" {
+		return "", opts, fmt.Errorf("invalid structured key: image is required")
+	}
+	for field, value := range map[string]string{"image": k.Image, "identity": k.Identity, "issuer": k.Issuer} {
+		if strings.Contains(value, "|") {
+			return "", opts, fmt.Errorf("invalid structured key: %s %q contains a pipe", field, value)
+		}
+	}
+	for _, secret := range k.PullSecrets {
+		if secret == "" || strings.Contains(secret, "|") {
+			return "", opts, fmt.Errorf("invalid structured key: invalid pull secret name %q", secret)
+		}
+	}
+	if k.Namespace != "" && !namespacePattern.MatchString(k.Namespace) {
+		return "", opts, fmt.Errorf("invalid structured key: invalid namespace %q", k.Namespace)
+	}
+	opts.namespace = k.Namespace
+
+	if p := k.Policy; p != nil {
+		if p.PublicKey != "" && !isKMSRef(p.PublicKey) {
+			return "", opts, fmt.Errorf("invalid structured key: publicKey %q is not a KMS key URI", p.PublicKey)
+		}
+		if _, err := parsePlatform(p.Platform); err != nil || strings.ContainsAny(p.Platform, ",|") {
+			return "", opts, fmt.Errorf("invalid structured key: invalid platform %q", p.Platform)
+		}
+		if _, err := parseUnsignedSBOMs(p.UnsignedBuildxSBOMs); err != nil {
+			return "", opts, fmt.Errorf("invalid structured key: %w", err)
+		}
+		settings := KeySettings{
+			CertIdentityRegexp:    p.CertIdentityRegexp,
+			Identities:            p.Identities,
+			CertExtensions:        p.CertExtensions,
+			Annotations:           p.Annotations,
+			PredicateTypes:        p.PredicateTypes,
+			Platform:              p.Platform,
+			RequireImageSignature: p.RequireImageSignature,
+			UnsignedBuildxSBOMs:   p.UnsignedBuildxSBOMs,
+		}
+		_, settingsOpts := splitKeyOptions(settings.key(k.Image, nil))
+		settingsOpts.namespace = opts.namespace
+		opts = settingsOpts
+		opts.keyRef = p.PublicKey
+		opts.metadataOnly = p.SkipPackages
+		opts.allViolations = p.ReportAllViolations
+		opts.debug = p.Debug
+	}
+
+	secrets := []byte("[]")
+	if len(k.PullSecrets) > 0 {
+		secrets, _ = json.Marshal(k.PullSecrets)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", k.Image, secrets, k.Identity, k.Issuer), opts, nil
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseStructuredKey(t *testing.T) {
+	structured := `{"image":"ghcr.io/org/app:v1","pullSecrets":["regcred"],"identity":"ci@myorg.com","issuer":"google",` +
+		`"namespace":"team-a","policy":{"certIdentityRegexp":"ci-.+|bot@myorg.com","predicateTypes":["cyclonedx"],"skipPackages":true}}`
+	settings := KeySettings{CertIdentity: "ci@myorg.com", CertIdentityRegexp: "ci-.+|bot@myorg.com", CertOidcIssuer: "google", PredicateTypes: []string{"cyclonedx"}}
+	legacy := settings.key("ghcr.io/org/app:v1", []string{"regcred"}) + ",packages=false,namespace=team-a"
+
+	wantImage, wantOpts := splitKeyOptions(legacy)
+	want := wantOpts.resultKey(wantImage)
+	for name, key := range map[string]string{
+		"json":       structured,
+		"base64":     base64.StdEncoding.EncodeToString([]byte(structured)),
+		"base64 url": base64.RawURLEncoding.EncodeToString([]byte(structured)),
+		"legacy":     legacy,
+	} {
+		imageRef, opts, err := parseKey(key)
+		if err != nil {
+			t.Errorf("Expected the %s key to parse, got %v", name, err)
+			continue
+		}
+		if got := opts.resultKey(imageRef); got != want {
+			t.Errorf("Expected the %s key to resolve to %q, got %q", name, want, got)
+		}
+	}
+
+	imageRef, opts, err := parseKey(`{"image":"nginx:1.25"}`)
+	if err != nil || imageRef != "nginx:1.25|[]||" || opts.resultKey(imageRef) != imageRef {
+		t.Errorf("Expected a key without options, got %q, %+v, %v", imageRef, opts, err)
+	}
+
+	for name, key := range map[string]string{
+		"missing image":     `{"pullSecrets":["regcred"]}`,
+		"unknown field":     `{"image":"nginx:1.25","policy":{"certIdentityRegex":"ci-.+"}}`,
+		"pipe in identity":  `{"image":"nginx:1.25","identity":"a|b"}`,
+		"invalid namespace": `{"image":"nginx:1.25","namespace":"Team_A"}`,
+		"public key file":   `{"image":"nginx:1.25","policy":{"publicKey":"/keys/cosign.pub"}}`,
+		"invalid json":      `{"image":`,
+	} {
+		if _, _, err := parseKey(key); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestResolveInvalidStructuredKey(t *testing.T) {
+	server := &Server{cache: newResultCache()}
+	key := `{"image":"nginx:1.25","policy":{"unknown":true}}`
+	item := server.resolveKey(key, false, "")
+	if item.Key != key || !strings.Contains(item.Error, "invalid structured key") {
+		t.Errorf("Expected the key to fail as sent, got %+v", item)
+	}
+	if hint := server.cacheHint(key, item, false); hint != 0 {
+		t.Errorf("Expected no cache hint, got %v", hint)
+	}
+}
+
+func TestPullSecretNamespace(t *testing.T) {
+	secret := dockerConfigSecret("regcred", `{"auths":{"ghcr.io":{"username":"alice","password":"x"}}}`)
+	secret.Namespace = "team-a"
+
+	verifier := &AttestationVerifier{
+		keychain:   authn.DefaultKeychain,
+		kubeClient: fake.NewSimpleClientset(&secret),
+		namespace:  "gatekeeper-system",
+	}
+
+	kc, err := verifier.createKeychainWithSecrets(context.Background(), []string{"regcred"})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+	if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user == "alice" {
+		t.Error("Expected the secret of another namespace not to be read by default")
+	}
+
+	ctx := withKeyOptions(context.Background(), keyOptions{namespace: "team-a"})
+	kc, err = verifier.createKeychainWithSecrets(ctx, []string{"regcred"})
+	if err != nil {
+		t.Fatalf("Failed to create keychain: %v", err)
+	}
+	if user := resolveUser(t, kc, "ghcr.io/myorg/app"); user != "alice" {
+		t.Errorf("Expected credentials from the key's namespace, got '%s'", user)
+	}
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the timestamp formats SBOM generators are seen to emit, tried in order.
+// Layouts without a zone are read as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST", // Go's time.Time.String
+	"2006-01-02 15:04:05.999999999",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// minTimestamp rejects placeholders like "0001-01-01T00:00:00Z" some generators emit
+var minTimestamp = time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC)
+
+// maxTimestampSkew is how far in the future a timestamp may be before it is rejected
+const maxTimestampSkew = 24 * time.Hour
+
+// parseTimestamp parses a timestamp in any of the formats generators emit, including Unix
+// seconds, and rejects placeholders and timestamps far in the future
+func parseTimestamp(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	var t time.Time
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t = time.Unix(secs, 0)
+	} else {
+		// RFC 3339 allows a lowercase "t" separator and "z" zone
+		if len(value) > 10 && value[10] == 't' {
+			value = value[:10] + "T" + value[11:]
+		}
+		if strings.HasSuffix(value, "z") {
+			value = strings.TrimSuffix(value, "z") + "Z"
+		}
+
+		parsed := false
+		for _, layout := range timestampLayouts {
+			if p, err := time.Parse(layout, value); err == nil {
+				t, parsed = p, true
+				break
+			}
+		}
+		if !parsed {
+			return time.Time{}, false
+		}
+	}
+
+	if t.Before(minTimestamp) || t.After(time.Now().Add(maxTimestampSkew)) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// formatTimestamp renders t as RFC 3339 in UTC with second precision, so timestamps in
+// responses compare correctly both as times and as strings. The zero time renders empty.
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// normalizeTimestamp returns value as RFC 3339 UTC, or false when it is not a valid timestamp
+func normalizeTimestamp(value string) (string, bool) {
+	t, ok := parseTimestamp(value)
+	if !ok {
+		return "", false
+	}
+	return formatTimestamp(t), true
+}
+
+// setCreated records the creation timestamp of a document, normalized when possible
+func (d *SBOMDocument) setCreated(value string) {
+	if value == "" {
+		return
+	}
+	if created, ok := normalizeTimestamp(value); ok {
+		d.Created = created
+		return
+	}
+	d.CreatedRaw = value
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTimestamp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"2024-05-01T10:00:00Z", "2024-05-01T10:00:00Z"},
+		{"2024-05-01T12:00:00+02:00", "2024-05-01T10:00:00Z"},
+		{"2024-05-01T10:00:00.123456789Z", "2024-05-01T10:00:00Z"},
+		{"2024-05-01t10:00:00z", "2024-05-01T10:00:00Z"},
+		{"2024-05-01T05:00:00-0500", "2024-05-01T10:00:00Z"},
+		{"2024-05-01T10:00:00", "2024-05-01T10:00:00Z"},
+		{"2024-05-01 10:00:00", "2024-05-01T10:00:00Z"},
+		{"2024-05-01 10:00:00.5 +0000 UTC", "2024-05-01T10:00:00Z"},
+		{"Wed, 01 May 2024 10:00:00 +0000", "2024-05-01T10:00:00Z"},
+		{"2024-05-01", "2024-05-01T00:00:00Z"},
+		{"1714557600", "2024-05-01T10:00:00Z"},
+		{" 2024-05-01T10:00:00Z ", "2024-05-01T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeTimestamp(tt.value)
+		if !ok || got != tt.want {
+			t.Errorf("normalizeTimestamp(%q): expected %q, got %q (ok %v)", tt.value, tt.want, got, ok)
+		}
+	}
+
+	future := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)
+	for _, value := range []string{"", "yesterday", "0001-01-01T00:00:00Z", "1970-01-01T00:00:00Z", "05/01/2024", future} {
+		if got, ok := normalizeTimestamp(value); ok {
+			t.Errorf("normalizeTimestamp(%q): expected an invalid timestamp, got %q", value, got)
+		}
+	}
+}
+
+func TestSBOMDocumentCreated(t *testing.T) {
+	meta := cycloneDXDocumentMetadata(&CycloneDXBOM{Metadata: CycloneDXMetadata{Timestamp: "2024-05-01T12:00:00+02:00"}})
+	if meta.Created != "2024-05-01T10:00:00Z" || meta.CreatedRaw != "" {
+		t.Errorf("Expected the creation time in UTC, got %+v", meta)
+	}
+
+	meta = spdxDocumentMetadata(&SPDXDocument{CreationInfo: CreationInfo{Created: "NOASSERTION"}})
+	if meta.Created != "" || meta.CreatedRaw != "NOASSERTION" {
+		t.Errorf("Expected an invalid creation time to be kept raw only, got %+v", meta)
+	}
+}
+
+func TestSBOMSignedAt(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	ctx := withKeyOptions(context.Background(), keyOptions{metadataOnly: true})
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	unified, err := verifier.sbomFromAttestations(ctx, []verifiedAttestation{
+		{payload: []byte(`{"predicateType":"https://slsa.dev/provenance/v1"}`), signedAt: signedAt.Add(time.Hour)},
+		{payload: []byte(`{"predicateType":"https://spdx.dev/Document"}`), signedAt: signedAt},
+	})
+	if err != nil {
+		t.Fatalf("Failed to extract SBOM metadata: %v", err)
+	}
+	if unified.SignedAt != "2024-05-01T10:00:00Z" {
+		t.Errorf("Expected the signing time of the SBOM attestation in UTC, got %q", unified.SignedAt)
+	}
+
+	if formatTimestamp(time.Time{}) != "" {
+		t.Error("Expected the zero time to render empty")
+	}
+}
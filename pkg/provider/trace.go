@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// DebugHeader enables debug tracing for every key of a /verify request
+const DebugHeader = "X-SBOM-Provider-Debug"
+
+// debugRequested reports whether the request asks for debug tracing of all its keys
+func debugRequested(r *http.Request) bool {
+	debug, _ := strconv.ParseBool(r.Header.Get(DebugHeader))
+	return debug
+}
+
+// traceContextKey is the context key holding the trace ID of a debug verification
+type traceContextKey struct{}
+
+// newTraceID returns a random trace ID
+func newTraceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withTrace returns a context that traces the verification under id
+func withTrace(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, id)
+}
+
+// traceID returns the trace ID of ctx, or "" when the verification is not traced
+func traceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceContextKey{}).(string)
+	return id
+}
+
+// tracef logs a detail of a traced verification. Untraced verifications log nothing.
+func tracef(ctx context.Context, format string, args ...interface{}) {
+	id := traceID(ctx)
+	if id == "" {
+		return
+	}
+	log.Printf("[trace %s] "+format, append([]interface{}{id}, args...)...)
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceID(t *testing.T) {
+	if id := traceID(context.Background()); id != "" {
+		t.Errorf("Expected no trace ID on an untraced context, got '%s'", id)
+	}
+
+	id := newTraceID()
+	if len(id) != 16 {
+		t.Errorf("Expected a 16 character trace ID, got '%s'", id)
+	}
+	if id == newTraceID() {
+		t.Error("Expected distinct trace IDs")
+	}
+
+	if got := traceID(withTrace(context.Background(), id)); got != id {
+		t.Errorf("Expected trace ID '%s', got '%s'", id, got)
+	}
+}
+
+func TestDebugRequested(t *testing.T) {
+	req := httptest.NewRequest("POST", "/verify", nil)
+	if debugRequested(req) {
+		t.Error("Expected no debug without the header")
+	}
+
+	req.Header.Set(DebugHeader, "true")
+	if !debugRequested(req) {
+		t.Error("Expected debug with the header set")
+	}
+}
+
+func TestResolveKeyOptions(t *testing.T) {
+	server := &Server{
+		verifier: &AttestationVerifier{},
+		timeout:  5 * time.Second,
+		cache:    newResultCache(),
+		cacheTTL: time.Minute,
+	}
+
+	// Cached as verified, but not a parseable reference, so a fresh verification fails
+	base := "INVALID::REF|[]||"
+	server.cache.Set(server.cacheKey(base), Item{Key: base, Value: `{"format":"spdx","packages":[]}`}, time.Minute)
+
+	key := base + "|debug=false"
+	item := server.resolveKey(key, false, RequestClassAdmission)
+	if item.Key != key {
+		t.Errorf("Expected key '%s', got '%s'", key, item.Key)
+	}
+	if item.Error != "" {
+		t.Errorf("Expected cached result, got error '%s'", item.Error)
+	}
+
+	key = base + "|debug=true"
+	item = server.resolveKey(key, false, RequestClassAdmission)
+	if item.Key != key {
+		t.Errorf("Expected key '%s', got '%s'", key, item.Key)
+	}
+	if !strings.Contains(item.Error, "failed to parse image reference") {
+		t.Errorf("Expected the debug key to bypass the cache, got '%s'", item.Error)
+	}
+	if !strings.Contains(item.Error, "(trace ID: ") {
+		t.Errorf("Expected the trace ID in the error, got '%s'", item.Error)
+	}
+
+	item = server.resolveKey(base, true, RequestClassAdmission)
+	if !strings.Contains(item.Error, "(trace ID: ") {
+		t.Errorf("Expected the debug header to trace the key, got '%s'", item.Error)
+	}
+}
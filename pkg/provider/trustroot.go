@@ -0,0 +1,430 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+)
+
+// Trusted root sources accepted in VerifierConfig.TrustedRoots
+const (
+	// TrustedRootPublicGood is the public Sigstore instance, fetched via TUF
+	TrustedRootPublicGood = "public-good"
+	// TrustedRootStaging is the Sigstore staging instance, fetched via TUF
+	TrustedRootStaging = "staging"
+	// trustedRootFilePrefix loads a trusted_root.json from a local path, e.g. "file:/etc/sigstore/trusted_root.json"
+	trustedRootFilePrefix = "file:"
+	// trustedRootTUFPrefix fetches the trusted root from a TUF mirror, e.g. "tuf:https://tuf.internal",
+	// trusting the root.json in VerifierConfig.TUFRoot
+	trustedRootTUFPrefix = "tuf:"
+)
+
+// trustedRootConfig is the material trusted roots other than the public instances load from
+type trustedRootConfig struct {
+	custom  *CustomTrustedRoot
+	tufRoot string // Path of the root.json trusted for "tuf:" mirrors
+}
+
+// DefaultTrustedRootRefreshInterval is how often trusted roots are re-fetched by default
+const DefaultTrustedRootRefreshInterval = 24 * time.Hour
+
+// namedTrustedRoot is trusted material labelled with the source it was loaded from
+type namedTrustedRoot struct {
+	name           string
+	material       root.TrustedMaterial
+	tufRootExpires time.Time // Expiry of the TUF root metadata, zero when not fetched via TUF
+}
+
+// isTUFRoot reports whether spec is a trusted root distributed through TUF
+func isTUFRoot(spec string) bool {
+	return spec == TrustedRootPublicGood || spec == TrustedRootStaging || strings.HasPrefix(spec, trustedRootTUFPrefix)
+}
+
+// tufOptions returns the TUF client options of a TUF-distributed trusted root. Mirrors
+// trust the root.json at cfg.tufRoot, read from disk so no network access is needed to
+// bootstrap them.
+func tufOptions(spec string, cfg trustedRootConfig) (*tuf.Options, error) {
+	switch spec {
+	case TrustedRootPublicGood:
+		return tuf.DefaultOptions(), nil
+	case TrustedRootStaging:
+		return tuf.DefaultOptions().WithRoot(tuf.StagingRoot()).WithRepositoryBaseURL(tuf.StagingMirror), nil
+	}
+
+	mirror, ok := strings.CutPrefix(spec, trustedRootTUFPrefix)
+	if !ok {
+		return nil, fmt.Errorf("trusted root %q is not distributed through TUF", spec)
+	}
+	if cfg.tufRoot == "" {
+		return nil, fmt.Errorf("TUF mirror %s requires a TUF root", mirror)
+	}
+	rootJSON, err := os.ReadFile(cfg.tufRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TUF root: %w", err)
+	}
+	return tuf.DefaultOptions().WithRoot(rootJSON).WithRepositoryBaseURL(mirror), nil
+}
+
+// unknownTrustedRoot reports an unsupported trusted root spec
+func unknownTrustedRoot(spec string) error {
+	return fmt.Errorf("unknown trusted root %q (expected %s, %s, %s, %s<mirror> or %s<path>)",
+		spec, TrustedRootPublicGood, TrustedRootStaging, TrustedRootCustom, trustedRootTUFPrefix, trustedRootFilePrefix)
+}
+
+// checkTrustedRootSpecs validates the configured trusted roots without loading them
+func checkTrustedRootSpecs(specs []string, cfg trustedRootConfig) error {
+	usesCustom := false
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		switch {
+		case spec == "", spec == TrustedRootPublicGood, spec == TrustedRootStaging, strings.HasPrefix(spec, trustedRootFilePrefix):
+		case spec == TrustedRootCustom:
+			if !cfg.custom.complete() {
+				return fmt.Errorf("trusted root %s requires a Rekor public key", TrustedRootCustom)
+			}
+			usesCustom = true
+		case strings.HasPrefix(spec, trustedRootTUFPrefix):
+			mirror := strings.TrimPrefix(spec, trustedRootTUFPrefix)
+			if u, err := url.Parse(mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid TUF mirror %q, expected an http(s) URL", mirror)
+			}
+			if cfg.tufRoot == "" {
+				return fmt.Errorf("TUF mirror %s requires a TUF root", mirror)
+			}
+		default:
+			return unknownTrustedRoot(spec)
+		}
+	}
+	if cfg.custom.configured() && !usesCustom {
+		return fmt.Errorf("custom Sigstore trust material is configured but the trusted roots do not include %s", TrustedRootCustom)
+	}
+	return nil
+}
+
+// loadTrustedRoot loads the trusted root described by spec
+func loadTrustedRoot(spec string, cfg trustedRootConfig) (root.TrustedMaterial, error) {
+	switch {
+	case isTUFRoot(spec):
+		opts, err := tufOptions(spec, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return root.FetchTrustedRootWithOptions(opts)
+	case strings.HasPrefix(spec, trustedRootFilePrefix):
+		return root.NewTrustedRootFromPath(strings.TrimPrefix(spec, trustedRootFilePrefix))
+	case spec == TrustedRootCustom:
+		return loadCustomTrustedRoot(cfg.custom)
+	}
+	return nil, unknownTrustedRoot(spec)
+}
+
+// loadTrustedRoots loads every configured trusted root, in order
+func loadTrustedRoots(specs []string, cfg trustedRootConfig) ([]namedTrustedRoot, error) {
+	if len(specs) == 0 {
+		specs = []string{TrustedRootPublicGood}
+	}
+
+	roots := make([]namedTrustedRoot, 0, len(specs))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		log.Printf("Loading Sigstore trusted root %s ...", spec)
+		material, err := loadTrustedRoot(spec, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trusted root %s: %w", spec, err)
+		}
+		tr := namedTrustedRoot{name: spec, material: material}
+		if opts, err := tufOptions(spec, cfg); err == nil {
+			if tr.tufRootExpires, err = tufRootExpiry(opts); err != nil {
+				log.Printf("Warning: cannot read the TUF root expiry of %s: %v", spec, err)
+			}
+		}
+		roots = append(roots, tr)
+	}
+
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no trusted roots configured")
+	}
+	return roots, nil
+}
+
+// trustedRootsHash fingerprints the trusted material of roots, in order
+func trustedRootsHash(roots []namedTrustedRoot) string {
+	h := sha256.New()
+	for _, tr := range roots {
+		fmt.Fprintf(h, "%s\n", tr.name)
+		if m, ok := tr.material.(json.Marshaler); ok {
+			if data, err := m.MarshalJSON(); err == nil {
+				h.Write(data)
+			}
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// currentTrustedRoots returns the trusted roots in use
+func (v *AttestationVerifier) currentTrustedRoots() []namedTrustedRoot {
+	v.trustMu.RLock()
+	defer v.trustMu.RUnlock()
+	return v.trustedRoots
+}
+
+// setTrustedRoots swaps in roots, returning whether their trusted material changed
+func (v *AttestationVerifier) setTrustedRoots(roots []namedTrustedRoot) bool {
+	trustHash := trustedRootsHash(roots)
+	hash := v.policy(trustHash, "", "", keyOptions{}).hash()
+
+	v.trustMu.Lock()
+	changed := hash != v.policyHash
+	v.trustedRoots = roots
+	v.trustHash = trustHash
+	v.policyHash = hash
+	v.trustState = TrustStateReady
+	v.trustErr = nil
+	onChange := v.onTrustChange
+	if v.trustReady != nil {
+		select {
+		case <-v.trustReady:
+		default:
+			close(v.trustReady)
+		}
+	}
+	v.trustMu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(hash)
+	}
+	return changed
+}
+
+// PolicyHash fingerprints the trust material and options verifications are currently
+// checked against, before any per-key identity constraints
+func (v *AttestationVerifier) PolicyHash() string {
+	v.trustMu.RLock()
+	defer v.trustMu.RUnlock()
+	return v.policyHash
+}
+
+// OnTrustChange registers fn to be called with the new policy hash whenever the trust material changes
+func (v *AttestationVerifier) OnTrustChange(fn func(policyHash string)) {
+	v.trustMu.Lock()
+	v.onTrustChange = fn
+	v.trustMu.Unlock()
+}
+
+// ReloadTrustedRoots re-fetches the configured trusted roots, returning whether they changed.
+// The current roots are kept when any of them fails to load.
+func (v *AttestationVerifier) ReloadTrustedRoots() (bool, error) {
+	roots, err := loadTrustedRoots(v.trustedRootSpecs, v.trustedRootConfig)
+	if err != nil {
+		return false, err
+	}
+	return v.setTrustedRoots(roots), nil
+}
+
+// Trusted root states, reported on /readyz
+const (
+	// TrustStateInitializing means the trusted roots have not been loaded yet
+	TrustStateInitializing = "initializing"
+	// TrustStateReady means the trusted roots are loaded and up to date
+	TrustStateReady = "ready"
+	// TrustStateDegraded means the last refresh failed and the previously loaded roots are in use
+	TrustStateDegraded = "degraded"
+)
+
+// Backoff between attempts to load the trusted roots at startup
+const (
+	trustInitBackoff    = time.Second
+	trustInitMaxBackoff = time.Minute
+)
+
+// initTrustedRoots loads the trusted roots with load, retrying with backoff until it
+// succeeds or ctx is done
+func (v *AttestationVerifier) initTrustedRoots(ctx context.Context, load func() ([]namedTrustedRoot, error)) {
+	backoff := trustInitBackoff
+	for {
+		roots, err := load()
+		v.recordTrustLoad(err)
+		if err == nil {
+			v.setTrustedRoots(roots)
+			log.Printf("Trusted roots loaded (policy hash %s)", v.PolicyHash())
+			return
+		}
+
+		log.Printf("Warning: failed to load trusted roots, retrying in %v: %v", backoff, err)
+		v.setTrustError(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, trustInitMaxBackoff)
+	}
+}
+
+// setTrustError records a failure to load the trusted roots. Loaded roots stay in use, degraded.
+func (v *AttestationVerifier) setTrustError(err error) {
+	v.trustMu.Lock()
+	defer v.trustMu.Unlock()
+
+	v.trustErr = err
+	if len(v.trustedRoots) > 0 {
+		v.trustState = TrustStateDegraded
+	}
+}
+
+// Trusted root load results, counted in sbom_provider_trusted_root_loads_total
+const (
+	trustLoadSuccess = "success"
+	trustLoadFailure = "failure"
+)
+
+// recordTrustLoad counts an attempt to load the trusted roots, at startup or on refresh
+func (v *AttestationVerifier) recordTrustLoad(err error) {
+	v.trustMu.Lock()
+	defer v.trustMu.Unlock()
+
+	if v.trustLoads == nil {
+		v.trustLoads = make(map[string]int64)
+	}
+	if err != nil {
+		v.trustLoads[trustLoadFailure]++
+		return
+	}
+	v.trustLoads[trustLoadSuccess]++
+	v.trustLoadedAt = time.Now()
+}
+
+// writeTrustMetrics writes the trusted root load counters and freshness in the Prometheus
+// text format
+func (v *AttestationVerifier) writeTrustMetrics(w io.Writer) {
+	if v == nil {
+		return
+	}
+	v.trustMu.RLock()
+	defer v.trustMu.RUnlock()
+
+	fmt.Fprintf(w, "# HELP sbom_provider_trusted_root_loads_total Attempts to load the trusted roots at startup and on refresh, by result.\n")
+	fmt.Fprintf(w, "# TYPE sbom_provider_trusted_root_loads_total counter\n")
+	for _, result := range []string{trustLoadFailure, trustLoadSuccess} {
+		fmt.Fprintf(w, "sbom_provider_trusted_root_loads_total{result=%q} %d\n", result, v.trustLoads[result])
+	}
+	if !v.trustLoadedAt.IsZero() {
+		fmt.Fprintf(w, "# HELP sbom_provider_trusted_root_last_load_timestamp_seconds When the trusted roots were last loaded successfully.\n")
+		fmt.Fprintf(w, "# TYPE sbom_provider_trusted_root_last_load_timestamp_seconds gauge\n")
+		fmt.Fprintf(w, "sbom_provider_trusted_root_last_load_timestamp_seconds %d\n", v.trustLoadedAt.Unix())
+	}
+}
+
+// TrustState returns the state of the trusted roots and the last error loading them
+func (v *AttestationVerifier) TrustState() (string, error) {
+	v.trustMu.RLock()
+	defer v.trustMu.RUnlock()
+
+	if v.trustState == "" {
+		// Set up without NewAttestationVerifier
+		if len(v.trustedRoots) > 0 {
+			return TrustStateReady, nil
+		}
+		return TrustStateInitializing, v.trustErr
+	}
+	return v.trustState, v.trustErr
+}
+
+// WaitTrustedRoots blocks until the trusted roots are first loaded or ctx is done
+func (v *AttestationVerifier) WaitTrustedRoots(ctx context.Context) error {
+	if v.trustReady == nil {
+		return nil
+	}
+	select {
+	case <-v.trustReady:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshTrustedRoots periodically reloads the trusted roots until ctx is done
+func (v *AttestationVerifier) refreshTrustedRoots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := v.ReloadTrustedRoots()
+		v.recordTrustLoad(err)
+		if err != nil {
+			log.Printf("Warning: failed to refresh trusted roots, keeping current ones: %v", err)
+			v.setTrustError(err)
+			continue
+		}
+		if changed {
+			log.Printf("Trusted root material changed (policy hash %s)", v.PolicyHash())
+		}
+	}
+}
+
+// verifyWithTrustedRoots runs verify with each trusted root in order and stops at the first success.
+// When every root fails, the error from the first (primary) root is returned.
+func (v *AttestationVerifier) verifyWithTrustedRoots(checkOpts *cosign.CheckOpts, verify func(*cosign.CheckOpts) error) error {
+	roots := v.currentTrustedRoots()
+
+	var firstErr error
+	for i, tr := range roots {
+		opts := *checkOpts
+		opts.TrustedMaterial = tr.material
+
+		err := verify(&opts)
+		if err == nil {
+			if i > 0 {
+				log.Printf("Verified with fallback trusted root %s", tr.name)
+			}
+			return nil
+		}
+
+		if len(roots) > 1 {
+			err = fmt.Errorf("trusted root %s: %w", tr.name, err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		// Registry auth failures do not depend on the trusted root
+		if _, ok := registryAuthStatus(err); ok {
+			break
+		}
+	}
+
+	if firstErr == nil {
+		if state, err := v.TrustState(); state == TrustStateInitializing {
+			if err != nil {
+				return newVerificationError(ErrCodeTrustNotReady, "trusted roots are not loaded yet: %w", err)
+			}
+			return newVerificationError(ErrCodeTrustNotReady, "trusted roots are not loaded yet")
+		}
+		return fmt.Errorf("no trusted roots loaded")
+	}
+	return firstErr
+}
@@ -0,0 +1,324 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+// fakeTrustedMaterial is trusted material with a fixed JSON form
+type fakeTrustedMaterial struct {
+	root.BaseTrustedMaterial
+	json string
+}
+
+func (m *fakeTrustedMaterial) MarshalJSON() ([]byte, error) {
+	return []byte(m.json), nil
+}
+
+func TestLoadTrustedRoots_UnknownSource(t *testing.T) {
+	_, err := loadTrustedRoots([]string{"prod"}, trustedRootConfig{})
+	if err == nil || !strings.Contains(err.Error(), `unknown trusted root "prod"`) {
+		t.Errorf("Expected unknown trusted root error, got %v", err)
+	}
+}
+
+func TestLoadTrustedRoots_MissingFile(t *testing.T) {
+	_, err := loadTrustedRoots([]string{"file:/nonexistent/trusted_root.json"}, trustedRootConfig{})
+	if err == nil {
+		t.Error("Expected error for missing trusted root file")
+	}
+}
+
+func TestLoadTrustedRoots_Empty(t *testing.T) {
+	_, err := loadTrustedRoots([]string{" ", ""}, trustedRootConfig{})
+	if err == nil {
+		t.Error("Expected error when no trusted roots are configured")
+	}
+}
+
+func TestVerifyWithTrustedRoots_FallsBackInOrder(t *testing.T) {
+	verifier := &AttestationVerifier{
+		trustedRoots: []namedTrustedRoot{{name: "public-good"}, {name: "staging"}, {name: "private"}},
+	}
+
+	var tried []string
+	i := 0
+	err := verifier.verifyWithTrustedRoots(&cosign.CheckOpts{}, func(opts *cosign.CheckOpts) error {
+		tried = append(tried, verifier.trustedRoots[i].name)
+		i++
+		if len(tried) < 2 {
+			return errors.New("no matching signatures")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Expected success on second root, got %v", err)
+	}
+	if strings.Join(tried, ",") != "public-good,staging" {
+		t.Errorf("Expected public-good then staging to be tried, got %v", tried)
+	}
+}
+
+func TestVerifyWithTrustedRoots_ReturnsFirstError(t *testing.T) {
+	verifier := &AttestationVerifier{
+		trustedRoots: []namedTrustedRoot{{name: "public-good"}, {name: "staging"}},
+	}
+
+	calls := 0
+	err := verifier.verifyWithTrustedRoots(&cosign.CheckOpts{}, func(opts *cosign.CheckOpts) error {
+		calls++
+		if calls == 1 {
+			return errors.New("first failure")
+		}
+		return errors.New("second failure")
+	})
+
+	if err == nil || err.Error() != "trusted root public-good: first failure" {
+		t.Errorf("Expected first root's error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected both roots to be tried, got %d calls", calls)
+	}
+}
+
+func TestVerifyWithTrustedRoots_StopsOnRegistryAuth(t *testing.T) {
+	verifier := &AttestationVerifier{
+		trustedRoots: []namedTrustedRoot{{name: "public-good"}, {name: "staging"}},
+	}
+
+	calls := 0
+	err := verifier.verifyWithTrustedRoots(&cosign.CheckOpts{}, func(opts *cosign.CheckOpts) error {
+		calls++
+		return &transport.Error{StatusCode: http.StatusUnauthorized}
+	})
+
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if calls != 1 {
+		t.Errorf("Expected registry auth failure to skip remaining roots, got %d calls", calls)
+	}
+}
+
+func TestSetTrustedRootsDetectsChanges(t *testing.T) {
+	verifier := &AttestationVerifier{}
+
+	var hashes []string
+	verifier.OnTrustChange(func(policyHash string) {
+		hashes = append(hashes, policyHash)
+	})
+
+	v1Roots := []namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}}
+	v2Roots := []namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":2}`}}}
+
+	if !verifier.setTrustedRoots(v1Roots) {
+		t.Error("Expected initial roots to be a change")
+	}
+	if verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}}) {
+		t.Error("Expected identical material not to be a change")
+	}
+	if !verifier.setTrustedRoots(v2Roots) {
+		t.Error("Expected rotated material to be a change")
+	}
+
+	if len(hashes) != 2 || hashes[0] == hashes[1] {
+		t.Errorf("Expected 2 distinct change notifications, got %v", hashes)
+	}
+	if verifier.PolicyHash() != hashes[1] {
+		t.Errorf("Expected policy hash %s, got %s", hashes[1], verifier.PolicyHash())
+	}
+}
+
+func TestTrustChangeEvictsServerCache(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+
+	server := NewServer(ServerConfig{CacheTTL: time.Minute}, verifier)
+	server.cache.Set("image:tag", Item{Key: "image:tag", Value: "{}"}, time.Minute)
+
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":2}`}}})
+
+	if _, ok := server.cache.Get("image:tag"); ok {
+		t.Error("Expected cached result to be evicted after trust material change")
+	}
+}
+
+func TestCheckTrustedRootSpecs(t *testing.T) {
+	if err := checkTrustedRootSpecs([]string{"public-good", " staging", "file:/etc/sigstore/trusted_root.json", ""}, trustedRootConfig{}); err != nil {
+		t.Errorf("Expected valid specs, got %v", err)
+	}
+	if err := checkTrustedRootSpecs([]string{"public-good", "https://tuf.example.com"}, trustedRootConfig{}); err == nil {
+		t.Error("Expected an error for an unknown trusted root")
+	}
+}
+
+func TestCheckTUFMirrorSpecs(t *testing.T) {
+	cfg := trustedRootConfig{tufRoot: "/etc/sigstore/root.json"}
+	if err := checkTrustedRootSpecs([]string{"tuf:https://tuf.internal.example.com"}, cfg); err != nil {
+		t.Errorf("Expected a valid TUF mirror, got %v", err)
+	}
+	if err := checkTrustedRootSpecs([]string{"tuf:https://tuf.internal.example.com"}, trustedRootConfig{}); err == nil {
+		t.Error("Expected an error for a TUF mirror without a TUF root")
+	}
+	for _, spec := range []string{"tuf:", "tuf:tuf.internal.example.com", "tuf:file:///etc/sigstore"} {
+		if err := checkTrustedRootSpecs([]string{spec}, cfg); err == nil {
+			t.Errorf("%s: expected an error for an invalid mirror", spec)
+		}
+	}
+}
+
+func TestTUFMirrorOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "root.json")
+	if err := os.WriteFile(path, []byte(`{"signed":{}}`), 0o600); err != nil {
+		t.Fatalf("Failed to write TUF root: %v", err)
+	}
+
+	opts, err := tufOptions("tuf:https://tuf.internal.example.com", trustedRootConfig{tufRoot: path})
+	if err != nil {
+		t.Fatalf("Failed to build TUF options: %v", err)
+	}
+	if opts.RepositoryBaseURL != "https://tuf.internal.example.com" {
+		t.Errorf("Expected the mirror URL, got %s", opts.RepositoryBaseURL)
+	}
+	if string(opts.Root) != `{"signed":{}}` {
+		t.Errorf("Expected the mounted TUF root, got %s", opts.Root)
+	}
+
+	if _, err := tufOptions("tuf:https://tuf.internal.example.com", trustedRootConfig{tufRoot: "/nonexistent/root.json"}); err == nil {
+		t.Error("Expected an error for a missing TUF root")
+	}
+	if _, err := tufOptions("file:/etc/sigstore/trusted_root.json", trustedRootConfig{}); err == nil {
+		t.Error("Expected an error for a trusted root not distributed through TUF")
+	}
+}
+
+func TestInitTrustedRootsRetries(t *testing.T) {
+	verifier := &AttestationVerifier{trustState: TrustStateInitializing, trustReady: make(chan struct{})}
+
+	attempts := 0
+	failed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		verifier.initTrustedRoots(context.Background(), func() ([]namedTrustedRoot, error) {
+			attempts++
+			if attempts == 1 {
+				defer close(failed)
+				return nil, errors.New("TUF mirror unavailable")
+			}
+			return []namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}}, nil
+		})
+	}()
+
+	<-failed
+	// The first attempt failed, the state is recorded before the backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	if err := verifier.WaitTrustedRoots(ctx); err == nil {
+		t.Error("Expected trusted roots not to be ready after a failed load")
+	}
+	cancel()
+	if state, err := verifier.TrustState(); state != TrustStateInitializing || err == nil {
+		t.Errorf("Expected initializing with an error, got %s (%v)", state, err)
+	}
+
+	err := verifier.verifyWithTrustedRoots(&cosign.CheckOpts{}, func(*cosign.CheckOpts) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "TUF mirror unavailable") {
+		t.Errorf("Expected verifications to fail with the load error, got %v", err)
+	}
+	if code := ErrorCode(err); code != ErrCodeTrustNotReady {
+		t.Errorf("Expected %s, got '%s'", ErrCodeTrustNotReady, code)
+	}
+
+	<-done
+	if err := verifier.WaitTrustedRoots(context.Background()); err != nil {
+		t.Errorf("Expected trusted roots to be ready, got %v", err)
+	}
+	if state, err := verifier.TrustState(); state != TrustStateReady || err != nil {
+		t.Errorf("Expected ready, got %s (%v)", state, err)
+	}
+}
+
+func TestTrustStateDegraded(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	if state, _ := verifier.TrustState(); state != TrustStateInitializing {
+		t.Errorf("Expected a verifier without roots to be initializing, got %s", state)
+	}
+
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+	verifier.setTrustError(errors.New("refresh failed"))
+	if state, err := verifier.TrustState(); state != TrustStateDegraded || err == nil {
+		t.Errorf("Expected degraded after a failed refresh, got %s (%v)", state, err)
+	}
+
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+	if state, err := verifier.TrustState(); state != TrustStateReady || err != nil {
+		t.Errorf("Expected ready after a successful refresh, got %s (%v)", state, err)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	verifier := &AttestationVerifier{trustState: TrustStateInitializing, trustReady: make(chan struct{})}
+	server := NewServer(ServerConfig{}, verifier)
+
+	check := func(wantCode int, wantStatus string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+		var status ReadyStatus
+		if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+			t.Fatalf("Failed to decode readiness: %v", err)
+		}
+		if w.Code != wantCode || status.Status != wantStatus {
+			t.Errorf("Expected %d %s, got %d %s", wantCode, wantStatus, w.Code, status.Status)
+		}
+	}
+
+	check(http.StatusServiceUnavailable, TrustStateInitializing)
+
+	verifier.setTrustedRoots([]namedTrustedRoot{{name: "public-good", material: &fakeTrustedMaterial{json: `{"v":1}`}}})
+	check(http.StatusOK, TrustStateReady)
+	if server.cache.policyHash != verifier.PolicyHash() {
+		t.Error("Expected the server cache to follow the policy hash of the loaded roots")
+	}
+
+	verifier.setTrustError(errors.New("refresh failed"))
+	check(http.StatusOK, TrustStateDegraded)
+}
+
+func TestTrustLoadMetrics(t *testing.T) {
+	verifier := &AttestationVerifier{}
+	var before strings.Builder
+	verifier.writeTrustMetrics(&before)
+	if strings.Contains(before.String(), "last_load_timestamp") {
+		t.Errorf("Expected no load timestamp before the first load, got:\n%s", before.String())
+	}
+
+	verifier.recordTrustLoad(errors.New("TUF mirror unavailable"))
+	verifier.recordTrustLoad(nil)
+	verifier.recordTrustLoad(errors.New("TUF mirror unavailable"))
+
+	var metrics strings.Builder
+	verifier.writeTrustMetrics(&metrics)
+	for _, want := range []string{
+		`sbom_provider_trusted_root_loads_total{result="failure"} 2`,
+		`sbom_provider_trusted_root_loads_total{result="success"} 1`,
+		"sbom_provider_trusted_root_last_load_timestamp_seconds ",
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, metrics.String())
+		}
+	}
+}
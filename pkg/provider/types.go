@@ -23,6 +23,7 @@ type ProviderResponse struct {
 type Response struct {
 	Items      []Item `json:"items,omitempty"`
 	SystemError string `json:"systemError,omitempty"`
+	Idempotent bool `json:"idempotent"` // Lets Gatekeeper cache the items in its external data cache
 }
 
 // Item represents a single key-value pair
@@ -32,10 +33,81 @@ type Item struct {
 	Error string `json:"error,omitempty"`
 }
 
+// StatusPending is the status reported while verification of a key is still in progress
+const StatusPending = "pending"
+
+// PendingValue is returned as the item value in async mode while verification runs in the background
+type PendingValue struct {
+	Status string `json:"status"`
+}
+
+// pendingValue is the serialized PendingValue placed in Item.Value
+const pendingValue = `{"status":"` + StatusPending + `"}`
+
 // UnifiedSBOM represents a normalized SBOM structure that works for both SPDX and CycloneDX
 type UnifiedSBOM struct {
 	Format   string          `json:"format"`   // "spdx" or "cyclonedx"
 	Packages []UnifiedPackage `json:"packages"` // Normalized packages from either format
+	PolicyHash string        `json:"policyHash,omitempty"` // Hash of the verification policy the SBOM was verified under
+	TraceID    string        `json:"traceId,omitempty"`    // Trace ID of a debug verification, matching its log lines
+	Source     string        `json:"source,omitempty"`     // Attestation source the SBOM was verified from, e.g. "referrers" or "rekor"
+	Completeness *SBOMCompleteness `json:"completeness,omitempty"` // Heuristic completeness of the SBOM for its image
+	EmptySBOM  bool          `json:"emptySBOM,omitempty"`  // Verified SBOM listing no packages, e.g. for scratch or distroless images
+	Document   *SBOMDocument `json:"document,omitempty"`   // Metadata of the SBOM document
+	MetadataOnly bool        `json:"metadataOnly,omitempty"` // Packages and document metadata were skipped (packages=false key option)
+	Violations []Violation   `json:"violations,omitempty"` // Verification violations found alongside the SBOM (violations=all key option)
+	Ownership  *ImageOwnership `json:"ownership,omitempty"` // Catalog registration of the image, when an entitlement check is configured
+	Warnings   []Violation   `json:"warnings,omitempty"`   // Violations allowed by an active policy exception
+	Exceptions []PolicyException `json:"exceptions,omitempty"` // Active policy exceptions of the image, for policies to skip the rules they cover
+	Window     *ActiveWindow `json:"window,omitempty"`     // Verification window in force, e.g. a release freeze, with the checks it tightens
+	SignedAt   string        `json:"signedAt,omitempty"`   // When the SBOM attestation was logged in the transparency log, RFC3339 UTC
+	VerifiedAt string        `json:"verifiedAt,omitempty"` // When the SBOM was verified, RFC3339 UTC
+	RepositoryPolicy string  `json:"repositoryPolicy,omitempty"` // Repository policy the expected signers were taken from, as a digest reference
+	Platform   string        `json:"platform,omitempty"`   // Platform whose manifest was verified when the image is an index, e.g. "linux/arm64"
+	PlatformDigest string    `json:"platformDigest,omitempty"` // Digest of that manifest
+	Files      *SBOMFilesSummary `json:"files,omitempty"` // Summary of the SPDX files section, with SPDX_FILES_SUMMARY
+	ResolvedDigest string    `json:"resolvedDigest,omitempty"` // Digest reference the image tag was resolved to and verified
+	ImageSignature *ImageSignature `json:"imageSignature,omitempty"` // Verified cosign signature of the image, when one is required
+	Unverified     bool            `json:"unverified,omitempty"`     // Unsigned BuildKit or attached SBOM returned for inventory, not verified
+	Upstream       *UpstreamResult `json:"upstream,omitempty"`       // Result of the upstream provider the image is delegated to
+	VulnerabilityScan *VulnerabilityScan `json:"vulnerabilityScan,omitempty"` // Newest verified vulnerability scan attestation, with VULNERABILITY_SCANS
+	VEX        *VEX          `json:"vex,omitempty"`        // Statements of the verified OpenVEX attestations, with VEX_STATEMENTS
+	SBOMs      []CollectedSBOM `json:"sboms,omitempty"`    // Every SBOM found for the image, with SBOM_COLLECTION all or merge
+	PartialFailures *PartialFailures `json:"partialFailures,omitempty"` // Attestation candidates of the source that failed verification
+
+	osDetected bool // An operating-system component was found while normalizing
+}
+
+// Violation is a verification check an image failed, reported alongside its SBOM
+type Violation struct {
+	Code    string `json:"code"`             // Error code, e.g. "ERR_IDENTITY_MISMATCH"
+	Source  string `json:"source,omitempty"` // Attestation source the violation was found through
+	Message string `json:"message"`
+
+	Attestations int      `json:"attestations,omitempty"` // Attestations signed by other identities, for ERR_IDENTITY_MISMATCH
+	Identities   []string `json:"identities,omitempty"`   // Sanitized identities that signed them, for ERR_IDENTITY_MISMATCH
+
+	Exception *PolicyException `json:"exception,omitempty"` // Exception that turned the violation into a warning
+}
+
+// SBOMDocument is the metadata of an SBOM document, normalized across formats so policies
+// can check freshness and generators
+type SBOMDocument struct {
+	Name      string         `json:"name,omitempty"`      // SPDX document name, or the CycloneDX subject component name
+	Namespace string         `json:"namespace,omitempty"` // SPDX document namespace, or the CycloneDX serial number
+	Created   string         `json:"created,omitempty"`   // Creation timestamp, normalized to RFC3339 UTC
+	CreatedRaw string        `json:"createdRaw,omitempty"` // Creation timestamp as recorded in the document, when it could not be normalized
+	Tools     []string       `json:"tools,omitempty"`     // Generating tools as "name-version", e.g. "syft-1.4.1"
+	Creators  []string       `json:"creators,omitempty"`  // SPDX creators, e.g. "Organization: ACME"
+	Component *SBOMComponent `json:"component,omitempty"` // CycloneDX subject component
+}
+
+// SBOMComponent is the component a CycloneDX BOM describes
+type SBOMComponent struct {
+	Type    string `json:"type,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
 }
 
 // UnifiedPackage represents a normalized package structure
@@ -43,7 +115,14 @@ type UnifiedPackage struct {
 	Name     string `json:"name"`
 	Version  string `json:"versionInfo"`
 	License  string `json:"licenseConcluded"` // Normalized license info
+	LicenseRaw string `json:"licenseRaw,omitempty"` // License as the SBOM recorded it, when an alias was normalized
+	Licenses []string `json:"licenses,omitempty"` // Licenses the normalized license expression refers to
+	Evidence *PackageEvidence `json:"evidence,omitempty"` // CycloneDX component evidence, with COMPONENT_EVIDENCE
+	Pedigree *PackagePedigree `json:"pedigree,omitempty"` // CycloneDX component pedigree, with COMPONENT_EVIDENCE
+	Direct   *bool  `json:"direct,omitempty"` // Direct dependency of the CycloneDX application component, unset when the SBOM does not say
 	PURL     string `json:"purl,omitempty"`
+	LayerDigest string `json:"layerDigest,omitempty"` // Digest of the image layer that added the package, when the SBOM records it
+	LayerDiffID string `json:"layerDiffID,omitempty"` // Uncompressed digest (diff ID) of that layer
 }
 
 // SPDXDocument represents a simplified SPDX SBOM structure
@@ -75,6 +154,14 @@ type SPDXPackage struct {
 	LicenseDeclared    string   `json:"licenseDeclared,omitempty"`
 	CopyrightText      string   `json:"copyrightText,omitempty"`
 	ExternalRefs       []ExtRef `json:"externalRefs,omitempty"`
+	Annotations        []SPDXAnnotation `json:"annotations,omitempty"`
+}
+
+// SPDXAnnotation represents an annotation on an SPDX element
+type SPDXAnnotation struct {
+	Annotator      string `json:"annotator"`
+	AnnotationType string `json:"annotationType"`
+	Comment        string `json:"comment"`
 }
 
 // ExtRef represents an external reference for a package
@@ -88,29 +175,55 @@ type ExtRef struct {
 type CycloneDXBOM struct {
 	BOMFormat    string              `json:"bomFormat"`
 	SpecVersion  string              `json:"specVersion"`
+	SerialNumber string              `json:"serialNumber,omitempty"`
 	Version      int                 `json:"version"`
 	Metadata     CycloneDXMetadata   `json:"metadata,omitempty"`
 	Components   []CycloneDXComponent `json:"components,omitempty"`
+	Dependencies []CycloneDXDependency `json:"dependencies,omitempty"`
 }
 
 // CycloneDXMetadata contains BOM metadata
 type CycloneDXMetadata struct {
-	Timestamp string `json:"timestamp,omitempty"`
+	Timestamp string              `json:"timestamp,omitempty"`
+	Tools     CycloneDXTools      `json:"tools,omitempty"`
+	Component *CycloneDXComponent `json:"component,omitempty"`
+}
+
+// CycloneDXTools lists the tools that generated a BOM. CycloneDX 1.5 replaced the
+// legacy array of tools with an object of components and services; both are accepted.
+type CycloneDXTools []CycloneDXTool
+
+// CycloneDXTool represents a tool that generated a BOM
+type CycloneDXTool struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
 }
 
 // CycloneDXComponent represents a component in CycloneDX
 type CycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref,omitempty"`
 	Type       string              `json:"type"`
 	Name       string              `json:"name"`
 	Version    string              `json:"version,omitempty"`
 	Purl       string              `json:"purl,omitempty"`
 	Licenses   []CycloneDXLicense  `json:"licenses,omitempty"`
 	Hashes     []CycloneDXHash     `json:"hashes,omitempty"`
+	Properties []CycloneDXProperty `json:"properties,omitempty"`
+	Evidence   *CycloneDXEvidence  `json:"evidence,omitempty"`
+	Pedigree   *CycloneDXPedigree  `json:"pedigree,omitempty"`
+}
+
+// CycloneDXProperty represents a name-value property
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
 }
 
 // CycloneDXLicense represents a license
 type CycloneDXLicense struct {
-	License CycloneDXLicenseInfo `json:"license,omitempty"`
+	License    CycloneDXLicenseInfo `json:"license,omitempty"`
+	Expression string               `json:"expression,omitempty"` // SPDX license expression, set instead of license
 }
 
 // CycloneDXLicenseInfo contains license details
@@ -0,0 +1,296 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// maxUpstreamResponseSize caps upstream provider responses
+const maxUpstreamResponseSize = 16 << 20
+
+// Results of delegated keys, as counted in sbom_provider_upstream_keys_total
+const (
+	upstreamResultOK       = "ok"
+	upstreamResultRejected = "rejected"
+	upstreamResultFailed   = "failed"
+)
+
+// UpstreamResult is the value another external data provider returned for the image, merged
+// into the SBOM so constraints can check both without calling a second provider
+type UpstreamResult struct {
+	Provider string          `json:"provider"` // Host of the upstream provider
+	Value    json.RawMessage `json:"value"`    // Item value the upstream provider returned, as JSON
+}
+
+// upstreamProvider is another external data provider the images of matching repositories are
+// delegated to, e.g. an existing signature-only provider. An image verifies only when both
+// providers accept it.
+type upstreamProvider struct {
+	source   string // Repository name, or registry/namespace prefix for wildcard mappings
+	wildcard bool
+	url      string
+	host     string
+	client   *http.Client
+
+	ok, rejected, failed atomic.Int64 // Delegated keys, by result
+}
+
+// parseUpstreamProviders parses "source=url" mappings of image repositories to upstream
+// providers, with sources as in ATTESTATION_REPOSITORIES. Upstream certificates are verified
+// against the PEM bundle at caBundle, or the system roots when empty.
+func parseUpstreamProviders(specs []string, caBundle string, timeout time.Duration) ([]*upstreamProvider, error) {
+	var tlsConfig *tls.Config
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upstream CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("upstream CA bundle %s holds no PEM certificate", caBundle)
+		}
+		tlsConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	var upstreams []*upstreamProvider
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		source, rawURL, ok := strings.Cut(spec, "=")
+		if !ok || source == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid upstream provider mapping %q (expected source=url)", spec)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream provider URL %q", rawURL)
+		}
+		pattern, wildcard, err := parseRepositoryPattern(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream provider source %q: %w", source, err)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
+		upstreams = append(upstreams, &upstreamProvider{
+			source:   pattern,
+			wildcard: wildcard,
+			url:      rawURL,
+			host:     u.Host,
+			client:   &http.Client{Timeout: timeout, Transport: transport},
+		})
+	}
+
+	// Most specific first: exact repositories, then longer prefixes
+	sort.SliceStable(upstreams, func(i, j int) bool {
+		if upstreams[i].wildcard != upstreams[j].wildcard {
+			return !upstreams[i].wildcard
+		}
+		return len(upstreams[i].source) > len(upstreams[j].source)
+	})
+	return upstreams, nil
+}
+
+// matches reports whether images of repo are delegated to the upstream provider
+func (u *upstreamProvider) matches(repo name.Repository) bool {
+	if !u.wildcard {
+		return repo.Name() == u.source
+	}
+	return strings.HasPrefix(repo.Name(), u.source+"/")
+}
+
+// lookup sends images to the upstream provider in a single request and returns its items by key
+func (u *upstreamProvider) lookup(ctx context.Context, images []string) (map[string]Item, error) {
+	body, err := json.Marshal(ProviderRequest{
+		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
+		Kind:       "ProviderRequest",
+		Request:    Request{Keys: images},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("upstream provider %s failed: %w", u.host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream provider %s failed: %s", u.host, resp.Status)
+	}
+
+	var providerResp ProviderResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxUpstreamResponseSize)).Decode(&providerResp); err != nil {
+		return nil, fmt.Errorf("invalid response from upstream provider %s: %w", u.host, err)
+	}
+	if providerResp.Response.SystemError != "" {
+		return nil, fmt.Errorf("upstream provider %s failed: %s", u.host, providerResp.Response.SystemError)
+	}
+
+	items := make(map[string]Item, len(providerResp.Response.Items))
+	for _, item := range providerResp.Response.Items {
+		items[item.Key] = item
+	}
+	return items, nil
+}
+
+// upstreamFor returns the upstream provider the images of repo are delegated to, or nil
+func (s *Server) upstreamFor(repo name.Repository) *upstreamProvider {
+	for _, u := range s.upstreams {
+		if u.matches(repo) {
+			return u
+		}
+	}
+	return nil
+}
+
+// delegateUpstream sends the images of the verified items whose repository is delegated to an
+// upstream provider there, one request per upstream, and merges the results: an image the
+// upstream rejects, or that it could not check, fails with ERR_UPSTREAM, and the value it
+// accepted the image with is returned in the SBOM. Failed and pending items are not delegated.
+// opts are the key options of items; it runs before the policies apply, so with violations=all
+// or an exception covering ERR_UPSTREAM the failure is reported as a violation of the SBOM.
+func (s *Server) delegateUpstream(ctx context.Context, items []Item, opts []keyOptions) {
+	if len(s.upstreams) == 0 {
+		return
+	}
+
+	type delegated struct {
+		index       int
+		image       string
+		asViolation bool
+	}
+	batches := make(map[*upstreamProvider][]delegated)
+	for i, item := range items {
+		if item.Error != "" || item.Value == "" || item.Value == pendingValue {
+			continue
+		}
+		imageRef, _, err := parseKey(item.Key)
+		if err != nil {
+			continue
+		}
+		image := strings.SplitN(imageRef, "|", 2)[0]
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			continue
+		}
+		if u := s.upstreamFor(ref.Context()); u != nil {
+			batches[u] = append(batches[u], delegated{index: i, image: image, asViolation: opts[i].allViolations || s.exceptions.Covers(image, ErrCodeUpstream)})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	for u, batch := range batches {
+		images := make([]string, 0, len(batch))
+		seen := make(map[string]bool, len(batch))
+		for _, d := range batch {
+			if !seen[d.image] {
+				seen[d.image] = true
+				images = append(images, d.image)
+			}
+		}
+
+		results, err := u.lookup(ctx, images)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		for _, d := range batch {
+			items[d.index] = u.merge(items[d.index], d.image, results, err, d.asViolation)
+		}
+	}
+}
+
+// merge applies the upstream provider's result for image to item. With asViolation a failure
+// is added to the violations of the SBOM rather than failing the item.
+func (u *upstreamProvider) merge(item Item, image string, results map[string]Item, lookupErr error, asViolation bool) Item {
+	fail := func(err error) Item {
+		return Item{Key: item.Key, Error: formatItemError("Failed to verify attestation or extract SBOM", &VerificationError{Code: ErrCodeUpstream, Err: err})}
+	}
+
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &unified); err != nil {
+		u.failed.Add(1)
+		return fail(errors.New("failed to merge the upstream provider result"))
+	}
+
+	var upstreamErr error
+	result, ok := results[image]
+	switch {
+	case lookupErr != nil:
+		u.failed.Add(1)
+		upstreamErr = lookupErr
+	case !ok:
+		u.failed.Add(1)
+		upstreamErr = fmt.Errorf("upstream provider %s returned no item for %s", u.host, image)
+	case result.Error != "":
+		u.rejected.Add(1)
+		upstreamErr = fmt.Errorf("upstream provider %s rejected the image: %s", u.host, result.Error)
+	}
+
+	if upstreamErr != nil {
+		if !asViolation {
+			return fail(upstreamErr)
+		}
+		unified.Violations = append(unified.Violations, Violation{Code: ErrCodeUpstream, Message: sanitizeItemError(upstreamErr.Error())})
+	} else {
+		value := json.RawMessage(result.Value)
+		if !json.Valid(value) {
+			// Providers returning plain strings are kept as a JSON string
+			value, _ = json.Marshal(result.Value)
+		}
+		unified.Upstream = &UpstreamResult{Provider: u.host, Value: value}
+	}
+	merged, err := json.Marshal(&unified)
+	if err != nil {
+		u.failed.Add(1)
+		return fail(errors.New("failed to merge the upstream provider result"))
+	}
+	if upstreamErr == nil {
+		u.ok.Add(1)
+	}
+	item.Value = string(merged)
+	return item
+}
+
+// writeUpstreamMetrics writes the delegated key counters in Prometheus text format, nothing
+// when no upstream provider is configured
+func (s *Server) writeUpstreamMetrics(w io.Writer) {
+	if len(s.upstreams) == 0 {
+		return
+	}
+
+	const keys = "sbom_provider_upstream_keys_total"
+	fmt.Fprintf(w, "# HELP %s Keys delegated to upstream providers, by upstream and result.\n# TYPE %s counter\n", keys, keys)
+	for _, u := range s.upstreams {
+		fmt.Fprintf(w, "%s{upstream=%q,result=%q} %d\n", keys, u.host, upstreamResultOK, u.ok.Load())
+		fmt.Fprintf(w, "%s{upstream=%q,result=%q} %d\n", keys, u.host, upstreamResultRejected, u.rejected.Load())
+		fmt.Fprintf(w, "%s{upstream=%q,result=%q} %d\n", keys, u.host, upstreamResultFailed, u.failed.Load())
+	}
+}
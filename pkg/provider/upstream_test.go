@@ -0,0 +1,209 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestParseUpstreamProviders(t *testing.T) {
+	upstreams, err := parseUpstreamProviders([]string{
+		"ghcr.io/*=https://registry-wide.example.com/validate",
+		" ghcr.io/org/app=https://app.example.com/validate ",
+		"ghcr.io/org/*=https://org.example.com/validate",
+		"",
+	}, "", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream providers: %v", err)
+	}
+
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"ghcr.io/org/app", "app.example.com"},
+		{"ghcr.io/org/other", "org.example.com"},
+		{"ghcr.io/team/app", "registry-wide.example.com"},
+		{"docker.io/library/nginx", ""},
+	}
+	server := &Server{upstreams: upstreams}
+	for _, tt := range tests {
+		repo, err := name.NewRepository(tt.repo)
+		if err != nil {
+			t.Fatalf("Failed to parse repository %s: %v", tt.repo, err)
+		}
+		got := ""
+		if u := server.upstreamFor(repo); u != nil {
+			got = u.host
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected upstream %q, got %q", tt.repo, tt.want, got)
+		}
+	}
+
+	for _, spec := range []string{
+		"ghcr.io/org/app",
+		"ghcr.io/org/app=ftp://example.com",
+		"=https://example.com",
+		"INVALID REPO=https://example.com",
+	} {
+		if _, err := parseUpstreamProviders([]string{spec}, "", time.Second); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+	if _, err := parseUpstreamProviders(nil, "/nonexistent/ca.crt", time.Second); err == nil {
+		t.Error("Expected an error for a missing CA bundle")
+	}
+}
+
+func TestDelegateUpstream(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode the upstream request: %v", err)
+		}
+		received = append(received, req.Request.Keys...)
+		json.NewEncoder(w).Encode(ProviderResponse{Response: Response{Items: []Item{
+			{Key: "ghcr.io/org/app@" + testDigest, Value: `{"signed":true}`},
+			{Key: "ghcr.io/org/legacy:v1", Value: "valid"},
+			{Key: "ghcr.io/org/bad:v1", Error: "no signature found"},
+		}}})
+	}))
+	defer srv.Close()
+
+	upstreams, err := parseUpstreamProviders([]string{"ghcr.io/org/*=" + srv.URL}, "", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream providers: %v", err)
+	}
+	server := &Server{upstreams: upstreams, timeout: time.Second}
+
+	sbom := `{"format":"spdx","packages":[]}`
+	items := []Item{
+		{Key: "ghcr.io/org/app@" + testDigest + "|[]||", Value: sbom},
+		{Key: "ghcr.io/org/legacy:v1", Value: sbom},
+		{Key: "ghcr.io/org/bad:v1", Value: sbom},
+		{Key: "ghcr.io/org/missing:v1", Value: sbom},
+		{Key: "ghcr.io/org/failed:v1", Error: "ERR_NO_ATTESTATIONS: no attestations"},
+		{Key: "ghcr.io/org/pending:v1", Value: pendingValue},
+		{Key: "docker.io/library/nginx:latest", Value: sbom},
+	}
+	server.delegateUpstream(t.Context(), items, make([]keyOptions, len(items)))
+
+	if want := "ghcr.io/org/app@" + testDigest + ",ghcr.io/org/legacy:v1,ghcr.io/org/bad:v1,ghcr.io/org/missing:v1"; strings.Join(received, ",") != want {
+		t.Errorf("Expected the verified images of delegated repositories in one request, got %v", received)
+	}
+
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(items[0].Value), &unified); err != nil || unified.Upstream == nil {
+		t.Fatalf("Expected the upstream result in the SBOM, got %+v", items[0])
+	}
+	if string(unified.Upstream.Value) != `{"signed":true}` || unified.Upstream.Provider != strings.TrimPrefix(srv.URL, "http://") {
+		t.Errorf("Unexpected upstream result %+v", unified.Upstream)
+	}
+	if !strings.Contains(items[1].Value, `"upstream":{"provider":`) || !strings.Contains(items[1].Value, `"value":"valid"`) {
+		t.Errorf("Expected a plain upstream value as a JSON string, got %s", items[1].Value)
+	}
+	if !strings.HasPrefix(items[2].Error, ErrCodeUpstream+": ") || !strings.Contains(items[2].Error, "no signature found") {
+		t.Errorf("Expected the upstream rejection, got %+v", items[2])
+	}
+	if !strings.HasPrefix(items[3].Error, ErrCodeUpstream+": ") || !strings.Contains(items[3].Error, "no item") {
+		t.Errorf("Expected an image missing upstream to fail, got %+v", items[3])
+	}
+	if items[4].Error != "ERR_NO_ATTESTATIONS: no attestations" || items[5].Value != pendingValue || items[6].Value != sbom {
+		t.Errorf("Expected failed, pending and other images to be left alone, got %+v", items[4:])
+	}
+
+	u := upstreams[0]
+	if u.ok.Load() != 2 || u.rejected.Load() != 1 || u.failed.Load() != 1 {
+		t.Errorf("Expected 2 ok, 1 rejected and 1 failed keys, got %d, %d and %d", u.ok.Load(), u.rejected.Load(), u.failed.Load())
+	}
+}
+
+func TestDelegateUpstreamUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ProviderResponse{Response: Response{SystemError: "overloaded"}})
+	}))
+	defer srv.Close()
+
+	upstreams, err := parseUpstreamProviders([]string{"ghcr.io/org/app=" + srv.URL}, "", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream providers: %v", err)
+	}
+	server := &Server{upstreams: upstreams, timeout: time.Second}
+
+	items := []Item{{Key: "ghcr.io/org/app:v1", Value: `{"format":"spdx","packages":[]}`}}
+	server.delegateUpstream(t.Context(), items, make([]keyOptions, len(items)))
+	if !strings.HasPrefix(items[0].Error, ErrCodeUpstream+": ") || !strings.Contains(items[0].Error, "overloaded") {
+		t.Errorf("Expected images to fail closed when the upstream fails, got %+v", items[0])
+	}
+}
+
+func TestUpstreamFailureExceptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ProviderRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var items []Item
+		for _, key := range req.Request.Keys {
+			items = append(items, Item{Key: key, Error: "no signature found"})
+		}
+		json.NewEncoder(w).Encode(ProviderResponse{Response: Response{Items: items}})
+	}))
+	defer srv.Close()
+
+	upstreams, err := parseUpstreamProviders([]string{"ghcr.io/org/*=" + srv.URL}, "", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream providers: %v", err)
+	}
+	now := time.Now()
+	exceptions := newExceptionStore("")
+	exceptions.exceptions = []PolicyException{{
+		Image:      "ghcr.io/org/app",
+		Violations: []string{ErrCodeUpstream},
+		Reason:     "upstream signer rollout",
+		Approver:   "sec",
+		Expires:    now.Add(24 * time.Hour),
+	}}
+	server := &Server{
+		verifier:   &AttestationVerifier{},
+		timeout:    5 * time.Second,
+		cache:      newResultCache(),
+		cacheTTL:   time.Minute,
+		upstreams:  upstreams,
+		exceptions: exceptions,
+	}
+	excepted := "ghcr.io/org/app@" + testDigest + "|[]||"
+	other := "ghcr.io/org/other@" + testDigest + "|[]||"
+	for _, key := range []string{excepted, other, other + "|violations=all"} {
+		server.cache.Set(server.cacheKey(key), Item{Key: key, Value: `{"format":"spdx","packages":[]}`}, time.Minute)
+	}
+
+	// Admission requests and CI checks consult the upstream before exceptions apply
+	for _, item := range []Item{server.resolveKey(excepted, false, RequestClassAdmission), server.checkImage(excepted, RequestClassBatch)} {
+		var unified UnifiedSBOM
+		if err := json.Unmarshal([]byte(item.Value), &unified); err != nil || len(unified.Warnings) != 1 || unified.Warnings[0].Code != ErrCodeUpstream {
+			t.Errorf("Expected the upstream rejection turned into a warning by the exception, got %+v", item)
+		}
+	}
+	for _, item := range []Item{server.resolveKey(other, false, RequestClassAdmission), server.checkImage(other, RequestClassAudit)} {
+		if !strings.HasPrefix(item.Error, ErrCodeUpstream+": ") {
+			t.Errorf("Expected the upstream rejection without an exception, got %+v", item)
+		}
+	}
+	if item := server.resolveKey(other+"|violations=all", false, RequestClassAdmission); !strings.Contains(item.Value, `"violations":[{"code":"`+ErrCodeUpstream+`"`) {
+		t.Errorf("Expected the upstream rejection among the violations with violations=all, got %+v", item)
+	}
+
+	// A window suspending exceptions fails the excepted image again
+	windows := newWindowStore("")
+	windows.windows = []VerificationWindow{{Name: "freeze", Start: now.Add(-time.Hour), End: now.Add(time.Hour), SuspendExceptions: true}}
+	server.windows = windows
+	if item := server.resolveKey(excepted, false, RequestClassAdmission); !strings.HasPrefix(item.Error, ErrCodeUpstream+": ") {
+		t.Errorf("Expected the upstream rejection while exceptions are suspended, got %+v", item)
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/providerkey"
+)
+
+// KeyValidation is the item value of a valid key returned by /validate-key
+type KeyValidation struct {
+	Key        string           `json:"key"`        // The key in the legacy form, with its options in canonical order
+	Structured *providerkey.Key `json:"structured"` // The key in the structured form
+}
+
+// handleValidateKey validates the keys of an external data request without verifying anything,
+// so tools generating constraints can check the keys they build. Valid keys get a KeyValidation
+// value, others an error listing every problem found.
+func (s *Server) handleValidateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var providerReq ProviderRequest
+	if err := json.Unmarshal(body, &providerReq); err != nil {
+		log.Printf("Error parsing request: %v", err)
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	keys := requestKeys(providerReq.Request.Keys)
+	items := make([]Item, 0, len(keys))
+	invalid := 0
+	for _, key := range keys {
+		item := validateKey(key)
+		if item.Error != "" {
+			invalid++
+		}
+		items = append(items, item)
+	}
+	log.Printf("Validated %d keys (%d invalid)", len(items), invalid)
+
+	response := ProviderResponse{
+		APIVersion: "externaldata.gatekeeper.sh/v1beta1",
+		Kind:       "ProviderResponse",
+		Response:   Response{Items: items, Idempotent: true},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// validateKey validates key with providerkey, then with the checks the verification itself
+// applies to its options, e.g. that the predicate types are SBOM formats the provider decodes
+func validateKey(key string) Item {
+	k, err := providerkey.Parse(key)
+	if err != nil {
+		var verr *providerkey.ValidationError
+		if errors.As(err, &verr) {
+			err = errors.New(strings.Join(verr.Problems, "; "))
+		}
+		return Item{Key: key, Error: formatItemError("Invalid provider key", err)}
+	}
+
+	canonical := k.String()
+	legacy, opts, _ := parseKey(canonical)
+	parts := strings.SplitN(legacy, "|", 4)
+	var problems []string
+	if k.Policy != nil {
+		if _, err := parsePredicateTypes(k.Policy.PredicateTypes); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if _, err := certIdentitiesFor(parts[2], opts.identityRegexp, parts[3], opts.identities); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseCertExtensions(opts.certExtensions); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseSignedAnnotations(opts.annotations); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return Item{Key: key, Error: formatItemError("Invalid provider key", errors.New(strings.Join(problems, "; ")))}
+	}
+
+	value, err := json.Marshal(KeyValidation{Key: canonical, Structured: k})
+	if err != nil {
+		return Item{Key: key, Error: formatItemError("Failed to encode key", err)}
+	}
+	return Item{Key: key, Value: string(value)}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/sbom-gatekeeper-provider/pkg/providerkey"
+)
+
+func TestProviderKeyMatchesParseKey(t *testing.T) {
+	settings := KeySettings{
+		CertIdentity:          "ci@example.com",
+		CertIdentityRegexp:    "^https://github.com/org/",
+		CertOidcIssuer:        "https://accounts.google.com",
+		Identities:            []AllowedIdentity{{CertIdentity: "release@example.com", CertOidcIssuer: "https://accounts.google.com"}},
+		CertExtensions:        &CertExtensions{GithubWorkflowRef: "refs/heads/main"},
+		Annotations:           map[string]string{"env": "prod"},
+		PredicateTypes:        []string{"spdx"},
+		Platform:              "linux/arm64",
+		RequireImageSignature: true,
+		UnsignedBuildxSBOMs:   UnsignedSBOMsInventory,
+	}
+	templateKey := settings.key("ghcr.io/org/app:v1", []string{"regcred"})
+
+	k, err := providerkey.Parse(templateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse the key of the policy template: %v", err)
+	}
+	k.Namespace = "team-a"
+	k.Policy.PublicKey = "awskms:///alias/cosign"
+	k.Policy.SkipPackages = true
+	k.Policy.ReportAllViolations = true
+
+	imageRef, opts, err := parseKey(k.String())
+	if err != nil {
+		t.Fatalf("Failed to parse the providerkey key: %v", err)
+	}
+	if got := opts.resultKey(imageRef); got != k.String() {
+		t.Errorf("Expected providerkey to build the key results are cached under:\n%s\ngot\n%s", got, k.String())
+	}
+
+	structured, _ := json.Marshal(k)
+	structuredRef, structuredOpts, err := parseKey(string(structured))
+	if err != nil || structuredOpts.resultKey(structuredRef) != k.String() {
+		t.Errorf("Expected the structured form to parse to the same key, got %s and %v", structuredOpts.resultKey(structuredRef), err)
+	}
+}
+
+func TestHandleValidateKey(t *testing.T) {
+	server := &Server{}
+	body := `{"request":{"keys":[
+		"ghcr.io/org/app:v1|[\"regcred\"]|ci@example.com|https://accounts.google.com|signature=true,platform=linux/arm64",
+		"ghcr.io/org/app:v1|[]|||predicateTypes=%5B%22https%3A%2F%2Fslsa.dev%2Fprovenance%2Fv1%22%5D",
+		"ghcr.io/org/app:v1|[]|||debug=maybe,unknown=1",
+		""]}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/validate-key", bytes.NewReader([]byte(body)))
+	w := httptest.NewRecorder()
+	server.handleValidateKey(w, req)
+
+	var response ProviderResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	items := response.Response.Items
+	if len(items) != 3 || !response.Response.Idempotent {
+		t.Fatalf("Expected 3 idempotent items, got %+v", response.Response)
+	}
+
+	var validation KeyValidation
+	if err := json.Unmarshal([]byte(items[0].Value), &validation); err != nil {
+		t.Fatalf("Expected a key validation, got %+v", items[0])
+	}
+	if validation.Key != `ghcr.io/org/app:v1|["regcred"]|ci@example.com|https://accounts.google.com|platform=linux/arm64,signature=true` {
+		t.Errorf("Expected the canonical key, got %s", validation.Key)
+	}
+	if validation.Structured == nil || validation.Structured.Identity != "ci@example.com" || !validation.Structured.Policy.RequireImageSignature {
+		t.Errorf("Expected the structured key, got %+v", validation.Structured)
+	}
+
+	if !strings.Contains(items[1].Error, "not an SBOM format") {
+		t.Errorf("Expected the provider to reject a predicate type it does not decode, got %+v", items[1])
+	}
+	if !strings.HasPrefix(items[2].Error, "Invalid provider key: ") || !strings.Contains(items[2].Error, "option debug=maybe") || !strings.Contains(items[2].Error, "option unknown=1") {
+		t.Errorf("Expected every problem of the key, got %+v", items[2])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/validate-key", nil)
+	w = httptest.NewRecorder()
+	server.handleValidateKey(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Value schema versions of the JSON document carried in Item.Value
+const (
+	// ValueSchemaV1 is the UnifiedSBOM, with SPDX field names for the packages of every format
+	ValueSchemaV1 = "v1"
+	// ValueSchemaV2 is the UnifiedSBOMV2, with format-neutral package field names and the
+	// schema version in the document
+	ValueSchemaV2 = "v2"
+)
+
+// ValueSchemaHeader selects the value schema of a /verify request
+const ValueSchemaHeader = "X-SBOM-Provider-Value-Schema"
+
+// UnifiedSBOMV2 is the v2 value schema: the UnifiedSBOM with the schema version it follows and
+// packages named independently of the SBOM format
+type UnifiedSBOMV2 struct {
+	SchemaVersion string `json:"schemaVersion"` // Always ValueSchemaV2
+	UnifiedSBOM
+	Packages []PackageV2 `json:"packages"`
+}
+
+// PackageV2 is a package in the v2 value schema
+type PackageV2 struct {
+	Name        string           `json:"name"`
+	Version     string           `json:"version"`
+	License     string           `json:"license"` // Normalized license info
+	LicenseRaw  string           `json:"licenseRaw,omitempty"`
+	Licenses    []string         `json:"licenses,omitempty"`
+	Evidence    *PackageEvidence `json:"evidence,omitempty"`
+	Pedigree    *PackagePedigree `json:"pedigree,omitempty"`
+	Direct      *bool            `json:"direct,omitempty"`
+	PURL        string           `json:"purl,omitempty"`
+	LayerDigest string           `json:"layerDigest,omitempty"`
+	LayerDiffID string           `json:"layerDiffID,omitempty"`
+}
+
+// knownValueSchema reports whether schema is a supported value schema version
+func knownValueSchema(schema string) bool {
+	return schema == ValueSchemaV1 || schema == ValueSchemaV2
+}
+
+// parseValueSchemaProviders parses "provider=version" entries mapping Gatekeeper Provider names
+// to the value schema their responses use
+func parseValueSchemaProviders(specs []string) (map[string]string, error) {
+	providers := make(map[string]string)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		provider, schema, ok := strings.Cut(spec, "=")
+		if !ok || provider == "" {
+			return nil, fmt.Errorf("invalid value schema provider %q (expected provider=version)", spec)
+		}
+		if !knownValueSchema(schema) {
+			return nil, fmt.Errorf("provider %s: unknown value schema %q (expected %s or %s)", provider, schema, ValueSchemaV1, ValueSchemaV2)
+		}
+		providers[provider] = schema
+	}
+	return providers, nil
+}
+
+// valueSchemaFor returns the value schema a /verify request asks for: the ValueSchemaHeader,
+// else the schema configured for the Provider named by the "provider" query parameter of the
+// Provider's URL, else the default schema
+func (s *Server) valueSchemaFor(r *http.Request) string {
+	if schema := r.Header.Get(ValueSchemaHeader); schema != "" {
+		if knownValueSchema(schema) {
+			return schema
+		}
+		log.Printf("Warning: unknown value schema %q, using %s", schema, s.valueSchema)
+		return s.valueSchema
+	}
+	if schema, ok := s.valueSchemaProviders[r.URL.Query().Get("provider")]; ok {
+		return schema
+	}
+	return s.valueSchema
+}
+
+// convertValue renders an item value under schema. Cached values are stored in the v1 schema,
+// so they are converted per response; pending values are the same in every schema.
+func convertValue(value, schema string) string {
+	if schema != ValueSchemaV2 || value == "" || value == pendingValue {
+		return value
+	}
+
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(value), &unified); err != nil {
+		return value
+	}
+	v2 := UnifiedSBOMV2{SchemaVersion: ValueSchemaV2, UnifiedSBOM: unified, Packages: make([]PackageV2, 0, len(unified.Packages))}
+	for _, pkg := range unified.Packages {
+		v2.Packages = append(v2.Packages, PackageV2{
+			Name:        pkg.Name,
+			Version:     pkg.Version,
+			License:     pkg.License,
+			LicenseRaw:  pkg.LicenseRaw,
+			Licenses:    pkg.Licenses,
+			Evidence:    pkg.Evidence,
+			Pedigree:    pkg.Pedigree,
+			Direct:      pkg.Direct,
+			PURL:        pkg.PURL,
+			LayerDigest: pkg.LayerDigest,
+			LayerDiffID: pkg.LayerDiffID,
+		})
+	}
+	data, err := json.Marshal(&v2)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
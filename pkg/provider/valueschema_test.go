@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseValueSchemaProviders(t *testing.T) {
+	providers, err := parseValueSchemaProviders([]string{" sbom-provider-v2=v2", "sbom-provider=v1", ""})
+	if err != nil {
+		t.Fatalf("Failed to parse value schema providers: %v", err)
+	}
+	if providers["sbom-provider-v2"] != ValueSchemaV2 || providers["sbom-provider"] != ValueSchemaV1 {
+		t.Errorf("Expected v2 and v1, got %v", providers)
+	}
+
+	for _, spec := range []string{"sbom-provider", "=v2", "sbom-provider=v3"} {
+		if _, err := parseValueSchemaProviders([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestValueSchemaFor(t *testing.T) {
+	server := &Server{valueSchema: ValueSchemaV1, valueSchemaProviders: map[string]string{"sbom-provider-v2": ValueSchemaV2}}
+
+	tests := []struct {
+		url    string
+		header string
+		want   string
+	}{
+		{"/verify", "", ValueSchemaV1},
+		{"/verify?provider=sbom-provider-v2", "", ValueSchemaV2},
+		{"/verify?provider=sbom-provider", "", ValueSchemaV1},
+		{"/verify?provider=sbom-provider-v2", ValueSchemaV1, ValueSchemaV1},
+		{"/verify", ValueSchemaV2, ValueSchemaV2},
+		{"/verify", "v3", ValueSchemaV1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+		if tt.header != "" {
+			req.Header.Set(ValueSchemaHeader, tt.header)
+		}
+		if got := server.valueSchemaFor(req); got != tt.want {
+			t.Errorf("%s (header %q): expected %s, got %s", tt.url, tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	v1 := `{"format":"cyclonedx","packages":[{"name":"lodash","versionInfo":"4.17.21","licenseConcluded":"MIT","purl":"pkg:npm/lodash@4.17.21"}],"policyHash":"abc"}`
+
+	if got := convertValue(v1, ValueSchemaV1); got != v1 {
+		t.Errorf("Expected v1 values unchanged, got %s", got)
+	}
+	if got := convertValue(pendingValue, ValueSchemaV2); got != pendingValue {
+		t.Errorf("Expected pending values unchanged, got %s", got)
+	}
+
+	var v2 map[string]interface{}
+	if err := json.Unmarshal([]byte(convertValue(v1, ValueSchemaV2)), &v2); err != nil {
+		t.Fatalf("Failed to decode v2 value: %v", err)
+	}
+	if v2["schemaVersion"] != ValueSchemaV2 || v2["format"] != "cyclonedx" || v2["policyHash"] != "abc" {
+		t.Errorf("Expected the v1 fields with the schema version, got %v", v2)
+	}
+	pkg := v2["packages"].([]interface{})[0].(map[string]interface{})
+	if pkg["version"] != "4.17.21" || pkg["license"] != "MIT" || pkg["versionInfo"] != nil {
+		t.Errorf("Expected format-neutral package fields, got %v", pkg)
+	}
+}
+
+func TestHandleVerifyValueSchema(t *testing.T) {
+	server := &Server{
+		cache:                newResultCache(),
+		valueSchema:          ValueSchemaV1,
+		valueSchemaProviders: map[string]string{"sbom-provider-v2": ValueSchemaV2},
+	}
+	key := "ghcr.io/org/app@" + testDigest + "|[]||"
+	server.cache.Set(server.cacheKey(key), Item{Value: `{"format":"spdx","packages":[{"name":"openssl","versionInfo":"3.0.13","licenseConcluded":"Apache-2.0"}]}`}, time.Minute)
+
+	verify := func(url string) string {
+		body, _ := json.Marshal(ProviderRequest{Request: Request{Keys: []string{key}}})
+		w := httptest.NewRecorder()
+		server.handleVerify(w, httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body)))
+		var response ProviderResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Response.Items) != 1 {
+			t.Fatalf("Expected 1 item, got %v", response.Response.Items)
+		}
+		return response.Response.Items[0].Value
+	}
+
+	var v2 UnifiedSBOMV2
+	if err := json.Unmarshal([]byte(verify("/verify?provider=sbom-provider-v2")), &v2); err != nil {
+		t.Fatalf("Failed to decode v2 value: %v", err)
+	}
+	if v2.SchemaVersion != ValueSchemaV2 || len(v2.Packages) != 1 || v2.Packages[0].Version != "3.0.13" {
+		t.Errorf("Expected a v2 value, got %+v", v2)
+	}
+
+	var v1 UnifiedSBOM
+	if err := json.Unmarshal([]byte(verify("/verify")), &v1); err != nil {
+		t.Fatalf("Failed to decode v1 value: %v", err)
+	}
+	if len(v1.Packages) != 1 || v1.Packages[0].Version != "3.0.13" {
+		t.Errorf("Expected the cached v1 value to be unchanged, got %+v", v1)
+	}
+}
@@ -4,65 +4,535 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	k8schain "github.com/google/go-containerregistry/pkg/authn/kubernetes"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sigstore/cosign/v2/pkg/cosign"
 	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
-	"github.com/sigstore/sigstore-go/pkg/root"
+	rekorclient "github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/sigstore/pkg/signature"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
+// DefaultRekorURL is the public Sigstore Rekor instance
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+// VerifierConfig holds the attestation verifier settings
+type VerifierConfig struct {
+	// UseReferrers enables discovery through the OCI 1.1 referrers API
+	UseReferrers bool
+
+	// AttestationSources is the order attestation sources are tried in (AttestationSourceReferrers,
+	// AttestationSourceTag, AttestationSourceRepository, AttestationSourceRekor or
+	// AttestationSourceBuildx). Empty derives
+	// the order from UseReferrers, AttestationRepositories and RekorSearchFallback.
+	AttestationSources []string
+	// RegistryAttestationSources overrides AttestationSources per registry, as
+	// "registry=source,source" entries
+	RegistryAttestationSources []string
+	// RegistryFlavors sets how referrers are discovered per registry, as "registry=flavor"
+	// entries (RegistryFlavorGeneric, RegistryFlavorArtifactory or RegistryFlavorQuay).
+	// Registries not listed are detected by host name.
+	RegistryFlavors []string
+
+	// KubeQPS and KubeBurst rate limit the Kubernetes client used to fetch pull secrets
+	// (0 uses DefaultKubeQPS and DefaultKubeBurst)
+	KubeQPS   float32
+	KubeBurst int
+	// SecretFetchTimeout bounds reading a request's imagePullSecrets from the API server
+	// (0 uses DefaultSecretFetchTimeout)
+	SecretFetchTimeout time.Duration
+	// PullSecretNamespaces are the namespaces, as path.Match patterns, keys may read pull
+	// secrets from besides the provider's own. Empty allows every namespace the provider's
+	// RBAC does.
+	PullSecretNamespaces []string
+
+	// TrustedRoots are the Sigstore trusted roots tried in order for each verification
+	// (TrustedRootPublicGood, TrustedRootStaging, TrustedRootCustom, "tuf:<mirror>" or
+	// "file:<path>"; defaults to public-good)
+	TrustedRoots []string
+	// TUFRoot is the path of the root.json trusted for "tuf:<mirror>" trusted roots, e.g. a
+	// mounted ConfigMap in air-gapped clusters
+	TUFRoot string
+	// CustomTrustedRoot is the material of the TrustedRootCustom trusted root, for self-hosted
+	// Sigstore deployments. Its RekorURL defaults to RekorURL.
+	CustomTrustedRoot CustomTrustedRoot
+	// VerifySCT requires the signing certificates to carry a signed certificate timestamp
+	// from a trusted certificate transparency log
+	VerifySCT bool
+	// TrustedRootRefreshInterval is how often trusted roots are re-fetched to pick up
+	// rotated material (0 disables refreshing)
+	TrustedRootRefreshInterval time.Duration
+
+	// AttestationRepositories redirect attestation lookups to a separate repository as
+	// "source=target" mappings, where source is a repository or a prefix ending in "/*"
+	AttestationRepositories []string
+	// OriginRepositories map the repositories images are copied to onto the repository they
+	// were attested in, as "image=origin" mappings in the form of AttestationRepositories. The
+	// attestations of the same digest there are verified when the image has none of its own.
+	OriginRepositories []string
+
+	// RekorURL is the Rekor instance used for transparency log lookups
+	RekorURL string
+	// RekorQPS and RekorBurst rate limit the calls to Rekor, which also back off on 429
+	// responses (0 uses DefaultRekorQPS and DefaultRekorBurst)
+	RekorQPS   float64
+	RekorBurst int
+	// RekorSearchFallback searches Rekor by image digest when the registry holds no attestations
+	RekorSearchFallback bool
+	// AttachedSBOMFallback reads the SBOM cosign attach sbom stores next to the image when no
+	// other source yields an SBOM, as the last source of the default order. It must carry a
+	// cosign signature, unless unsigned SBOMs are kept for inventory.
+	AttachedSBOMFallback bool
+	// OfflineTlog verifies transparency log inclusion from the SET embedded in each attestation
+	// only, never contacting Rekor, for clusters without egress. Attestations without an embedded
+	// bundle fail with ErrCodeNoTlogBundle.
+	OfflineTlog bool
+
+	// RepositoryPolicyTag is the tag under which repositories publish a signed policy.json
+	// declaring the signers of their images, applied to keys without identity constraints
+	// (empty disables discovery)
+	RepositoryPolicyTag string
+	// RepositoryPolicySigner is the identity, pattern and issuer repository policies must be
+	// signed by
+	RepositoryPolicySigner AllowedIdentity
+	// RepositoryPolicyTTL is how long a discovered repository policy is reused (0 uses
+	// DefaultRepositoryPolicyTTL)
+	RepositoryPolicyTTL time.Duration
+
+	// LicenseAliasesFile is an optional JSON object mapping license strings SBOM generators emit
+	// to SPDX expressions, extending and overriding the built-in aliases
+	LicenseAliasesFile string
+
+	// PredicateTypes restricts the SBOM predicate types accepted, as format names ("spdx",
+	// "cyclonedx") or predicate type URIs (empty accepts both formats). Keys can only narrow it.
+	PredicateTypes []string
+
+	// Platform is the "os/arch[/variant]" platform, e.g. "linux/amd64", whose manifest is
+	// verified when an image is an index, for attestations attached per platform. Keys can
+	// override it. Empty verifies the attestations of the reference as given.
+	Platform string
+
+	// RequireImageSignature also requires a cosign signature of each image, by a signer the
+	// attestations are verified against, and reports it alongside the SBOM. Keys can require it
+	// where the provider does not.
+	RequireImageSignature bool
+
+	// UnsignedBuildxSBOMs is how BuildKit and attached SBOMs without a covering signature are
+	// handled: UnsignedSBOMsFail (the default) or UnsignedSBOMsInventory. Keys can override it.
+	UnsignedBuildxSBOMs string
+
+	// SBOMCollection is which of the SBOMs attested for an image are returned:
+	// SBOMCollectionFirst (the default), SBOMCollectionAll to also list every SBOM found, or
+	// SBOMCollectionMerge to return their packages deduplicated. Metadata-only keys always get
+	// the first SBOM.
+	SBOMCollection string
+
+	// MaxAttestations bounds how many attestations are verified per image and source, newest
+	// first (0 uses DefaultMaxAttestations)
+	MaxAttestations int
+	// MaxDecompressedPayload bounds the size of gzip or zstd compressed attestation payloads
+	// once decompressed, in bytes (0 uses DefaultMaxDecompressedPayload)
+	MaxDecompressedPayload int64
+
+	// SBOMCompleteness scores how complete each SBOM looks for its image, at the cost of
+	// fetching the image manifest
+	SBOMCompleteness bool
+	// ComponentEvidence adds the evidence (identity confidence, occurrences) and pedigree of
+	// CycloneDX components to their packages
+	ComponentEvidence bool
+	// SPDXFilesSummary adds the file count and the licenses seen in the files of SPDX SBOMs,
+	// without the file entries
+	SPDXFilesSummary bool
+	// VulnerabilityScans adds the newest verified cosign vulnerability scan attestation of the
+	// image to its SBOM
+	VulnerabilityScans bool
+	// VEXStatements adds the statements of the verified OpenVEX attestations of the image to
+	// its SBOM
+	VEXStatements bool
+	// EnforceDSSESignatures drops verified attestations whose DSSE envelope signature does not
+	// verify once more with the key they were verified with, instead of only logging them
+	EnforceDSSESignatures bool
+
+	// PublishKey is a cosign private key the verified unified SBOMs are signed with and pushed
+	// back to the registry as OCI referrers of their image (empty disables publishing)
+	PublishKey string
+	// PublishKeyPassword decrypts PublishKey
+	PublishKeyPassword string
+
+	// PublicKey is a cosign public key attestations must be signed with, as PEM, the path of a
+	// PEM file, a KMS key URI or a k8s://<namespace>/<name> Secret, instead of keyless Fulcio
+	// certificates (empty verifies keyless)
+	PublicKey string
+	// KMSKeyCacheTTL is how long public keys fetched from a KMS are reused, for PublicKey and
+	// per-constraint keys (0 uses DefaultKMSKeyCacheTTL)
+	KMSKeyCacheTTL time.Duration
+
+	// CatalogURL is an internal image catalog consulted after verification to confirm the image
+	// repository is registered to a team (empty disables the entitlement check)
+	CatalogURL string
+	// CatalogToken is the bearer token sent to CatalogURL
+	CatalogToken string
+	// CatalogTimeout bounds a catalog lookup (0 uses DefaultCatalogTimeout)
+	CatalogTimeout time.Duration
+
+	// MaxClockSkew is the tolerated difference between the node clock and the transparency log (0 disables the check)
+	MaxClockSkew time.Duration
+	// RekorCertValidityTolerance widens the validity windows of the certificates of entries
+	// found by searching Rekor. Other sources are checked by cosign, without tolerance.
+	RekorCertValidityTolerance time.Duration
+	// MaxSBOMAge fails SBOMs older than this, measured from the timestamp SBOMAgeSource selects
+	// (0 disables the check)
+	MaxSBOMAge time.Duration
+	// SBOMAgeSource selects the timestamp SBOM ages are measured from: SBOMAgeSourceCreated
+	// (the default), SBOMAgeSourceRekor or SBOMAgeSourceCertificate
+	SBOMAgeSource string
+}
+
 // AttestationVerifier handles in-toto attestation verification
 type AttestationVerifier struct {
-	useReferrers bool
-	keychain     authn.Keychain
-	trustedRoot  root.TrustedMaterial // Cached trusted root
+	keychain  authn.Keychain
+	transport http.RoundTripper // Shared registry transport, nil uses the go-containerregistry default
+
+	trustMu           sync.RWMutex
+	trustedRoots      []namedTrustedRoot // Cached trusted roots, tried in order
+	trustedRootSpecs  []string
+	trustedRootConfig trustedRootConfig
+	trustHash         string // Fingerprint of the trusted roots
+	policyHash        string // Hash of the policy without identity constraints
+	onTrustChange     func(policyHash string)
+	onKeyRotation     func(ref string)
+	trustState        string           // TrustStateInitializing, TrustStateReady or TrustStateDegraded
+	trustErr          error            // Last failure to load the trusted roots
+	trustLoads        map[string]int64 // Trusted root loads at startup and on refresh, by result
+	trustLoadedAt     time.Time        // Last successful load of the trusted roots
+	trustReady        chan struct{}    // Closed once trusted roots are first loaded, nil when loaded synchronously
+
+	keychainSources []namedKeychain // Default credential sources, tried after pull secrets
+
+	attestationRepos   []attestationRepository // Most specific first
+	originRepos        []attestationRepository // Most specific first
+	attestationSources []string                // Default source order
+	registrySources    map[string][]string     // Source order overrides by registry
+	registryFlavors    map[string]string       // Configured registry flavors, others are detected
+	explicitSources    bool                    // Source orders were configured rather than derived
+	attachedSBOMs      bool                    // Attached SBOMs end the default source order
+
+	kubeClient         kubernetes.Interface // nil when not running in a cluster
+	namespace          string               // Namespace pull secrets are read from
+	secretFetchTimeout time.Duration
+
+	pullSecretNamespaces []string // Patterns of the namespaces keys may read pull secrets from, nil for any
+
+	rekorClient         *rekorclient.Rekor // nil unless Rekor search fallback is enabled
+	rekorTransport      *rekorTransport    // Rate limits rekorClient
+	rekorSearchFallback bool
+	offlineTlog         bool // Transparency log inclusion is only verified from embedded bundles
+	verifySCT           bool
+
+	sbomCompleteness       bool
+	componentEvidence      bool
+	spdxFilesSummary       bool
+	vulnerabilityScans     bool
+	vexStatements          bool
+	enforceDSSESignatures  bool
+	attestationCap         int   // Attestations verified per image and source, 0 uses DefaultMaxAttestations
+	decompressedPayloadCap int64 // Decompressed payload bytes, 0 uses DefaultMaxDecompressedPayload
+
+	publisher *sbomPublisher // nil unless SBOM publishing is enabled
+
+	entitlements EntitlementChecker // nil unless an image catalog is configured
+
+	publicKey            signature.Verifier // nil unless verifying with a static public key
+	publicKeyFingerprint string             // sha256 of the public key, for the policy hash
+	publicKeyRef         string             // KMS or k8s:// key URI of the public key
+	kmsKeys              *kmsKeyCache
+	secretKeys           *secretKeyStore // nil when not running in a cluster
+
+	repositoryPolicies *repositoryPolicyStore // nil unless repository policy discovery is enabled
+	licenseAliases     licenseAliases         // License strings normalized to SPDX expressions
+	predicateTypes     predicateTypeAllowlist // SBOM predicate types accepted, empty for all
+	platform           *v1.Platform           // Platform selected from image indexes by default, nil for none
+
+	requireImageSignature bool   // Every image needs a cosign signature besides its SBOM attestation
+	unsignedBuildxSBOMs   string // Handling of unsigned BuildKit SBOMs by default
+	sbomCollection        string // Which of the SBOMs found are returned
+
+	clock              *clockMonitor
+	maxClockSkew       time.Duration
+	rekorCertTolerance time.Duration // Applies to Rekor search entries only
+	maxSBOMAge         time.Duration
+	sbomAgeSource      string
 }
 
 // NewAttestationVerifier creates a new attestation verifier
-func NewAttestationVerifier() (*AttestationVerifier, error) {
-	// Check if referrers API should be used
-	useReferrers := os.Getenv("USE_REFERRERS_API") == "true"
-
+func NewAttestationVerifier(cfg VerifierConfig) (*AttestationVerifier, error) {
 	// Set up authentication keychain
 	// This will use:
 	// 1. Service account imagePullSecrets mounted at /var/run/secrets/kubernetes.io/serviceaccount
 	// 2. Docker config from ~/.docker/config.json
 	// 3. Environment variables (DOCKER_CONFIG, etc.)
 	ctx := context.Background()
-	keychains := []authn.Keychain{authn.DefaultKeychain}
+	keychains := []namedKeychain{{name: "docker config", keychain: authn.DefaultKeychain}}
 
 	inClusterKeychain, err := k8schain.NewInCluster(ctx, k8schain.Options{})
 	if err != nil {
 		log.Printf("Warning: Failed to create in-cluster keychain: %v, falling back to default keychain only", err)
 	} else {
-		keychains = append(keychains, inClusterKeychain)
+		keychains = append(keychains, namedKeychain{name: "provider service account", keychain: inClusterKeychain})
+	}
+
+	secretFetchTimeout := cfg.SecretFetchTimeout
+	if secretFetchTimeout <= 0 {
+		secretFetchTimeout = DefaultSecretFetchTimeout
+	}
+
+	// Build the Kubernetes client once, pull secrets are fetched with it on every request
+	kubeClient, err := newKubeClient(cfg.KubeQPS, cfg.KubeBurst, secretFetchTimeout)
+	if err != nil {
+		log.Printf("Warning: %v, imagePullSecrets will be ignored", err)
+	}
+
+	// Get the namespace from the service account
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = "default"
+	}
+	pullSecretNamespaces, err := parsePullSecretNamespaces(cfg.PullSecretNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown trusted roots are configuration errors, fetching them is retried in the background
+	rekorURL := cfg.RekorURL
+	if rekorURL == "" {
+		rekorURL = DefaultRekorURL
+	}
+	customTrustedRoot := cfg.CustomTrustedRoot
+	if customTrustedRoot.RekorURL == "" {
+		customTrustedRoot.RekorURL = rekorURL
+	}
+	trustedRoots := trustedRootConfig{custom: &customTrustedRoot, tufRoot: cfg.TUFRoot}
+	if err := checkTrustedRootSpecs(cfg.TrustedRoots, trustedRoots); err != nil {
+		return nil, err
+	}
+	if customTrustedRoot.complete() && !customTrustedRoot.keyless() && cfg.PublicKey == "" {
+		log.Printf("Warning: the %s trusted root has no Fulcio root certificates, only signatures made with a constraint's publicKey verify against it", TrustedRootCustom)
+	}
+
+	attestationRepos, err := parseAttestationRepositories(cfg.AttestationRepositories)
+	if err != nil {
+		return nil, err
+	}
+
+	originRepos, err := parseOriginRepositories(cfg.OriginRepositories)
+	if err != nil {
+		return nil, err
+	}
+
+	sbomAgeSource, err := parseSBOMAgeSource(cfg.SBOMAgeSource)
+	if err != nil {
+		return nil, err
+	}
+
+	attestationSources, err := parseAttestationSources(cfg.AttestationSources)
+	if err != nil {
+		return nil, err
+	}
+	explicitSources := len(attestationSources) > 0
+	if !explicitSources {
+		attestationSources = defaultAttestationSources(cfg.UseReferrers, len(attestationRepos) > 0, len(originRepos) > 0, cfg.RekorSearchFallback, cfg.AttachedSBOMFallback)
+	}
+
+	registrySources, err := parseRegistryAttestationSources(cfg.RegistryAttestationSources)
+	if err != nil {
+		return nil, err
+	}
+	if len(registrySources) > 0 {
+		explicitSources = true
+	}
+
+	registryFlavors, err := parseRegistryFlavors(cfg.RegistryFlavors)
+	if err != nil {
+		return nil, err
+	}
+
+	var publisher *sbomPublisher
+	if cfg.PublishKey != "" {
+		publisher, err = loadSBOMPublisher(cfg.PublishKey, cfg.PublishKeyPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var publicKey signature.Verifier
+	var publicKeyFingerprint, publicKeyRef string
+	if isKMSRef(cfg.PublicKey) {
+		// Fetched once the verifier is built, the KMS or API server may be briefly unreachable
+		publicKeyRef = cfg.PublicKey
+		if isSecretKeyRef(publicKeyRef) {
+			if _, _, err := parseSecretKeyRef(publicKeyRef); err != nil {
+				return nil, err
+			}
+		}
+	} else if cfg.PublicKey != "" {
+		publicKey, publicKeyFingerprint, err = loadPublicKey(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	keychain := authn.NewMultiKeychain(keychains...)
+	var repositoryPolicies *repositoryPolicyStore
+	if cfg.RepositoryPolicyTag != "" {
+		if cfg.PublicKey != "" {
+			return nil, fmt.Errorf("repository policies declare keyless signers, which a static public key rules out")
+		}
+		repositoryPolicies, err = newRepositoryPolicyStore(cfg.RepositoryPolicyTag, cfg.RepositoryPolicySigner, cfg.RepositoryPolicyTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Pre-fetch trusted root if using Fulcio to avoid fetching it on every request
-	log.Printf("Pre-fetching Sigstore trusted root ...")
-	tr, err := root.FetchTrustedRoot()
+	licenseAliases, err := loadLicenseAliases(cfg.LicenseAliasesFile)
+	if err != nil {
+		return nil, err
+	}
+	predicateTypes, err := parsePredicateTypes(cfg.PredicateTypes)
 	if err != nil {
 		return nil, err
 	}
+	platform, err := parsePlatform(cfg.Platform)
+	if err != nil {
+		return nil, err
+	}
+	unsignedBuildxSBOMs, err := parseUnsignedSBOMs(cfg.UnsignedBuildxSBOMs)
+	if err != nil {
+		return nil, err
+	}
+	sbomCollection, err := parseSBOMCollection(cfg.SBOMCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var entitlements EntitlementChecker
+	if cfg.CatalogURL != "" {
+		catalog, err := newHTTPCatalog(cfg.CatalogURL, cfg.CatalogToken, cfg.CatalogTimeout)
+		if err != nil {
+			return nil, err
+		}
+		entitlements = catalog
+	}
+
+	verifier := &AttestationVerifier{
+		keychain:               newSourceKeychain(keychains...),
+		keychainSources:        keychains,
+		transport:              newRegistryTransport(),
+		kubeClient:             kubeClient,
+		namespace:              namespace,
+		secretFetchTimeout:     secretFetchTimeout,
+		pullSecretNamespaces:   pullSecretNamespaces,
+		trustedRootSpecs:       cfg.TrustedRoots,
+		trustedRootConfig:      trustedRoots,
+		verifySCT:              cfg.VerifySCT,
+		attestationRepos:       attestationRepos,
+		originRepos:            originRepos,
+		attestationSources:     attestationSources,
+		registrySources:        registrySources,
+		registryFlavors:        registryFlavors,
+		explicitSources:        explicitSources,
+		attachedSBOMs:          cfg.AttachedSBOMFallback,
+		rekorSearchFallback:    cfg.RekorSearchFallback,
+		offlineTlog:            cfg.OfflineTlog,
+		sbomCompleteness:       cfg.SBOMCompleteness,
+		componentEvidence:      cfg.ComponentEvidence,
+		spdxFilesSummary:       cfg.SPDXFilesSummary,
+		vulnerabilityScans:     cfg.VulnerabilityScans,
+		vexStatements:          cfg.VEXStatements,
+		enforceDSSESignatures:  cfg.EnforceDSSESignatures,
+		attestationCap:         cfg.MaxAttestations,
+		decompressedPayloadCap: cfg.MaxDecompressedPayload,
+		publisher:              publisher,
+		entitlements:           entitlements,
+		publicKey:              publicKey,
+		publicKeyFingerprint:   publicKeyFingerprint,
+		publicKeyRef:           publicKeyRef,
+		kmsKeys:                newKMSKeyCache(cfg.KMSKeyCacheTTL),
+		repositoryPolicies:     repositoryPolicies,
+		licenseAliases:         licenseAliases,
+		predicateTypes:         predicateTypes,
+		platform:               platform,
+		requireImageSignature:  cfg.RequireImageSignature,
+		unsignedBuildxSBOMs:    unsignedBuildxSBOMs,
+		sbomCollection:         sbomCollection,
+		maxClockSkew:           cfg.MaxClockSkew,
+		rekorCertTolerance:     cfg.RekorCertValidityTolerance,
+		maxSBOMAge:             cfg.MaxSBOMAge,
+		sbomAgeSource:          sbomAgeSource,
+		trustState:             TrustStateInitializing,
+		trustReady:             make(chan struct{}),
+	}
+
+	if verifier.offlineTlog && verifier.usesAttestationSource(AttestationSourceRekor) {
+		return nil, fmt.Errorf("the %s attestation source searches Rekor online, which offline transparency log verification rules out", AttestationSourceRekor)
+	}
+
+	// Secret keys are watched without the request timeout of kubeClient
+	if kubeClient != nil {
+		if watchClient, err := newKubeClient(cfg.KubeQPS, cfg.KubeBurst, 0); err == nil {
+			verifier.secretKeys = newSecretKeyStore(watchClient, secretFetchTimeout, verifier.keyRotated)
+		}
+	}
+	if publicKeyRef != "" {
+		// Fetching the key is retried on verification
+		if _, _, err := verifier.verificationKey(ctx); err != nil {
+			log.Printf("Warning: %v, retrying on verification", err)
+		}
+	}
+
+	// Pre-fetch trusted roots in the background so a transient TUF failure delays readiness
+	// instead of crash-looping the provider, then keep them fresh
+	go func() {
+		ctx := context.Background()
+		verifier.initTrustedRoots(ctx, func() ([]namedTrustedRoot, error) {
+			return loadTrustedRoots(cfg.TrustedRoots, trustedRoots)
+		})
+		if cfg.TrustedRootRefreshInterval > 0 {
+			verifier.refreshTrustedRoots(ctx, cfg.TrustedRootRefreshInterval)
+		}
+	}()
+
+	if cfg.MaxClockSkew > 0 && cfg.OfflineTlog {
+		log.Printf("Clock skew is not measured against Rekor with offline transparency log verification, only log entries integrated in the future are rejected")
+	} else if cfg.MaxClockSkew > 0 {
+		// Measure skew against the transparency log in the background, never blocking startup
+		verifier.clock = newClockMonitor(rekorURL, cfg.MaxClockSkew)
+		go verifier.monitorClock()
+	}
+
+	if verifier.usesAttestationSource(AttestationSourceRekor) {
+		verifier.rekorTransport = newRekorTransport(nil, cfg.RekorQPS, cfg.RekorBurst)
+		rc, err := newRekorClient(rekorURL, verifier.rekorTransport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rekor client: %w", err)
+		}
+		verifier.rekorClient = rc
+	}
 
-	return &AttestationVerifier{
-		useReferrers: useReferrers,
-		keychain:     keychain,
-		trustedRoot:  tr,
-	}, nil
+	return verifier, nil
 }
 
 // VerifyAndExtractSBOMWithParams verifies attestation and extracts SBOM with custom parameters
@@ -91,6 +561,9 @@ func (v *AttestationVerifier) VerifyAndExtractSBOMWithParams(ctx context.Context
 	log.Printf("Verifying attestation for image: %s (secrets: %d, identity: %s, issuer: %s)",
 		imageRef, len(secretNames), certIdentity, certOidcIssuer)
 
+	tracef(ctx, "key parsed: image=%s secrets=%v identity=%q identityRegexp=%q issuer=%q identities=%s certExtensions=%s annotations=%s policyHash=%s",
+		imageRef, secretNames, certIdentity, identityRegexp(ctx), certOidcIssuer, allowedIdentities(ctx), certExtensions(ctx), signedAnnotations(ctx), v.policyHashWithOptions(certIdentity, certOidcIssuer, contextKeyOptions(ctx)))
+
 	// Create keychain with secrets from the pod being evaluated
 	keychain, err := v.createKeychainWithSecrets(ctx, secretNames)
 	if err != nil {
@@ -104,101 +577,435 @@ func (v *AttestationVerifier) VerifyAndExtractSBOMWithParams(ctx context.Context
 		return nil, fmt.Errorf("failed to parse image reference: %w", err)
 	}
 
+	// Resolve a tag once, so every source verifies the same image even if the tag moves meanwhile
+	ref, resolvedDigest, err := v.pinDigest(ctx, ref, keychain)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the attestations of the selected platform's manifest when the image is an index
+	indexRef := ref
+	ref, selectedPlatform, err := v.platformManifest(ctx, ref, keychain)
+	if err != nil {
+		return nil, err
+	}
+	if selectedPlatform != nil {
+		ctx = withImageIndex(ctx, indexRef)
+	}
+
 	// Set up cosign check options
 	checkOpts := &cosign.CheckOpts{
 		RegistryClientOpts: []ociremote.Option{
-			ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx)),
+			ociremote.WithRemoteOptions(v.remoteOptions(ctx, keychain)...),
 		},
 		ClaimVerifier:     cosign.IntotoSubjectClaimVerifier, // Verify in-toto attestations
 		IgnoreTlog:        false,                             // Always check transparency log for attestations
-		IgnoreSCT:         true,                              // SCT is for certificates, not needed for attestations
-		ExperimentalOCI11: v.useReferrers,
-		RekorPubKeys:      nil, // Use default Rekor public keys
-		CTLogPubKeys:      nil, // Not needed for attestations
-		NewBundleFormat:   true,
+		Offline:           v.offlineTlog,                     // Only trust the SET embedded in each attestation
+		RekorClient:       v.rekorClient,                     // Looks up attestations without an embedded SET, nil when offline
+		IgnoreSCT:         !v.verifySCT,                      // Only checked when required by VERIFY_SCT
+		ExperimentalOCI11: false,                             // Enabled by the referrers source
+		RekorPubKeys:      nil,                               // Use default Rekor public keys
+		CTLogPubKeys:      nil,                               // Taken from the trusted root
+		NewBundleFormat:   false,
 	}
 
-	// Add identity constraints if provided
-	if certIdentity != "" || certOidcIssuer != "" {
-		checkOpts.Identities = []cosign.Identity{{
-			Issuer:  certOidcIssuer,
-			Subject: certIdentity,
-		}}
+	// Add identity constraints if provided. Signatures made with a static key carry no
+	// certificate identity, so they only apply to keyless verification.
+	publicKey, fingerprint, err := v.verificationKey(ctx)
+	if err != nil {
+		return nil, err
 	}
+	var repositoryPolicy string // Digest of the repository policy the signers were taken from
+	if publicKey != nil {
+		checkOpts.SigVerifier = publicKey
+		tracef(ctx, "verifying with public key %s, identity constraints ignored", fingerprint)
+	} else {
+		checkOpts.Identities, err = certIdentitiesFor(certIdentity, identityRegexp(ctx), certOidcIssuer, allowedIdentities(ctx))
+		if err != nil {
+			return nil, err
+		}
+		extensions, err := certExtensionsFor(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	// Use cached trusted root (fetched at startup)
-	checkOpts.TrustedMaterial = v.trustedRoot
-	checkOpts.SigVerifier = nil
+		// Keys without identity constraints take the signers their repository declares
+		if len(checkOpts.Identities) == 0 && v.repositoryPolicies != nil {
+			policy, digest, err := v.repositoryPolicy(ctx, ref.Context(), keychain, checkOpts)
+			if err != nil {
+				return nil, err
+			}
+			if policy != nil {
+				if checkOpts.Identities, err = cosignIdentities(policy.Identities); err != nil {
+					return nil, err
+				}
+				if extensions == nil {
+					extensions = policy.CertExtensions
+				}
+				repositoryPolicy = digest
+				tracef(ctx, "verifying against repository policy %s: %d identities", digest, len(policy.Identities))
+			}
+		}
 
-	// Fetch and verify attestations - try OCI 1.1 first, fallback to legacy
-	attestations, _, fetchErr := cosign.VerifyImageAttestations(ctx, ref, checkOpts)
-	if fetchErr != nil {
-		// Fallback to legacy tag method
-		checkOpts.ExperimentalOCI11 = false
-		checkOpts.NewBundleFormat = false
-		attestations, _, fetchErr = cosign.VerifyImageAttestations(ctx, ref, checkOpts)
+		if extensions != nil {
+			ctx = withRequiredCertExtensions(ctx, extensions)
+			checkOpts.ClaimVerifier = extensions.claimVerifier(checkOpts.ClaimVerifier)
+		}
 	}
 
-	if fetchErr != nil {
-		return nil, fmt.Errorf("failed to fetch/verify attestations: %w", fetchErr)
+	// Try each attestation source in order until one yields a verified SBOM
+	sources := v.attestationSourcesFor(ref.Context().RegistryStr())
+	var sourceErrs []error
+	var unified *UnifiedSBOM
+	var verifiedSource string
+	var failures *partialFailures
+	for _, source := range sources {
+		// Failed candidates are only reported for the source the SBOM is verified from
+		sourceCtx, sourceFailures := withPartialFailures(ctx)
+		atts, err := v.fetchAttestations(sourceCtx, source, ref, checkOpts, keychain)
+		if errors.Is(err, errSourceNotApplicable) {
+			continue
+		}
+		if err == nil && len(atts) == 0 {
+			err = errNoAttestations
+		}
+		if err != nil {
+			tracef(ctx, "attestation source %s failed: %v", source, err)
+			sourceErrs = append(sourceErrs, &sourceError{source, v.classifyFetchError(err, ref, keychain)})
+			continue
+		}
+		tracef(ctx, "attestation source %s: verified %d attestations", source, len(atts))
+
+		unified, err = v.sbomFromAttestations(sourceCtx, atts)
+		if err != nil {
+			tracef(ctx, "attestation source %s: %v", source, err)
+			sourceErrs = append(sourceErrs, &sourceError{source, err})
+			continue
+		}
+
+		if len(sourceErrs) > 0 {
+			log.Printf("Verified attestations for %s from fallback source %s", imageRef, source)
+		}
+		verifiedSource = source
+		failures = sourceFailures
+		break
 	}
 
-	if len(attestations) == 0 {
-		return nil, fmt.Errorf("no attestations found")
+	// Report an unexpected signer alongside the SBOM so policies can report its other problems too
+	if unified == nil && allViolations(ctx) {
+		if recovered, source, ok := v.sbomDespiteIdentity(ctx, sourceErrs, ref, checkOpts, keychain); ok {
+			unified, verifiedSource = recovered, source
+		}
+	}
+
+	if unified != nil {
+		unified.Source = verifiedSource
+		if failures != nil {
+			if unified.PartialFailures = failures.summary(); unified.PartialFailures != nil {
+				log.Printf("Warning: %d attestation candidates of %s from source %s failed verification: %s",
+					unified.PartialFailures.Count, imageRef, verifiedSource, strings.Join(unified.PartialFailures.Reasons, "; "))
+			}
+		}
+		unified.PolicyHash = v.policyHashWithOptions(certIdentity, certOidcIssuer, contextKeyOptions(ctx))
+		unified.VerifiedAt = formatTimestamp(time.Now())
+		unified.RepositoryPolicy = repositoryPolicy
+		unified.ResolvedDigest = resolvedDigest
+		if selectedPlatform != nil {
+			unified.Platform = selectedPlatform.String()
+			unified.PlatformDigest = ref.Identifier()
+		}
+
+		if err := v.checkSignedAnnotations(ctx, ref, keychain, checkOpts); err != nil {
+			return nil, err
+		}
+		if v.requireImageSignature || imageSignatureRequired(ctx) {
+			if unified.ImageSignature, err = v.verifyImageSignature(ctx, ref, keychain, checkOpts); err != nil {
+				return nil, err
+			}
+		}
+
+		if v.entitlements != nil {
+			digest, err := resolveDigest(ref, v.remoteOptions(ctx, keychain)...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve image digest for the entitlement check: %w", classifyRegistryAuthError(err, ref, keychain))
+			}
+			if err := v.checkEntitlement(ctx, ref.Context().Name(), digest.String(), unified); err != nil {
+				return nil, fmt.Errorf("entitlement check failed: %w", err)
+			}
+		}
+
+		if v.sbomCompleteness && !unified.MetadataOnly {
+			layers, size, err := v.imageLayers(ctx, ref, keychain)
+			if err != nil {
+				log.Printf("Warning: skipping SBOM completeness for %s: %v", imageRef, err)
+			} else {
+				unified.Completeness = scoreCompleteness(unified, layers, size)
+				observeCompleteness(unified.Completeness)
+				tracef(ctx, "SBOM completeness %.2f (%d packages, %d layers, %d bytes, OS detected: %v)", unified.Completeness.Score,
+					unified.Completeness.Packages, layers, size, unified.Completeness.OSDetected)
+			}
+		}
+		v.publishSBOM(ref, unified, keychain)
+		return unified, nil
+	}
+
+	if sk, ok := keychain.(*sourceKeychain); ok {
+		if source, ok := sk.usedSource(ref.Context().RegistryStr()); ok {
+			tracef(ctx, "registry credentials for %s: %s", ref.Context().RegistryStr(), source)
+		}
+	}
+
+	if len(sourceErrs) == 0 {
+		return nil, fmt.Errorf("no attestation sources apply to %s (tried: %s)", ref.Context(), strings.Join(sources, ", "))
+	}
+	if err := noAttestationsError(ref, sourceErrs); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("failed to fetch/verify attestations: %w", joinSourceErrors(sourceErrs))
+}
+
+// noAttestationsError returns an ErrCodeNoAttestations error when every source tried found no
+// attestation at all, which the build pipeline rather than the constraint has to fix
+func noAttestationsError(ref name.Reference, errs []error) error {
+	var sources []string
+	for _, err := range errs {
+		var serr *sourceError
+		if !errors.As(err, &serr) || !errors.Is(serr.err, errNoAttestations) {
+			return nil
+		}
+		sources = append(sources, serr.source)
+	}
+	return newVerificationError(ErrCodeNoAttestations, "no attestations found for %s (tried: %s), the build pipeline must attach a signed SBOM attestation",
+		ref.Context(), strings.Join(sources, ", "))
+}
+
+// sbomFromAttestations returns the first SBOM among verified in-toto statements whose
+// predicate type the verification accepts
+func (v *AttestationVerifier) sbomFromAttestations(ctx context.Context, atts []verifiedAttestation) (*UnifiedSBOM, error) {
+	accepted, err := v.acceptedPredicateTypes(ctx)
+	if err != nil {
+		return nil, err
 	}
+	atts, dsseErr := v.checkDSSESignatures(ctx, atts)
+	if metadataOnly(ctx) {
+		unified, err := v.sbomMetadataFromAttestations(ctx, atts, accepted)
+		if err != nil {
+			if dsseErr != nil {
+				return nil, dsseErr
+			}
+			return nil, err
+		}
+		unified.VulnerabilityScan = v.vulnerabilityScanFromAttestations(ctx, atts)
+		unified.VEX = v.vexFromAttestations(ctx, atts)
+		return unified, nil
+	}
+
+	var rejected []string
+	var ageErr error
+	var sboms []*UnifiedSBOM
+	for i, att := range atts {
+		predicateType, _, err := v.parseStatement(att.payload)
+		if err == nil && sbomFormat(predicateType) != "" && !accepted.allows(predicateType) {
+			tracef(ctx, "attestation %d: predicate type %s not accepted", i, predicateType)
+			rejected = appendUnique(rejected, predicateType)
+			continue
+		}
 
-	// Extract SBOM from attestations
-	for _, att := range attestations {
-		payload, err := att.Payload()
+		sbom, err := v.extractSBOMFromAttestation(att.payload)
 		if err != nil {
+			tracef(ctx, "attestation %d: %v", i, err)
+			continue
+		}
+
+		if unified, ok := sbom.(*UnifiedSBOM); ok && unified != nil {
+			tracef(ctx, "attestation %d: %s SBOM with %d packages", i, unified.Format, len(unified.Packages))
+			if err := v.checkSBOMAge(ctx, i, unified, att); err != nil {
+				tracef(ctx, "attestation %d: %v", i, err)
+				ageErr = err
+				continue
+			}
+			// A verified SBOM listing nothing is a distinct outcome policies may reject
+			unified.EmptySBOM = len(unified.Packages) == 0
+			unified.SignedAt = formatTimestamp(att.signedAt)
+			unified.Unverified = att.unverified
+			if v.sbomCollection != SBOMCollectionAll && v.sbomCollection != SBOMCollectionMerge {
+				sboms = append(sboms, unified)
+				break
+			}
+			// Unverified inventory SBOMs are never mixed with verified ones
+			if len(sboms) > 0 && sboms[0].Unverified != unified.Unverified {
+				tracef(ctx, "attestation %d: skipped, verified and unverified SBOMs are not collected together", i)
+				continue
+			}
+			sboms = append(sboms, unified)
 			continue
 		}
+		tracef(ctx, "attestation %d: not an SBOM predicate", i)
+	}
+
+	if len(sboms) > 0 {
+		unified := sboms[0]
+		if len(sboms) > 1 {
+			unified = v.collectSBOMs(ctx, sboms)
+		}
+		unified.VulnerabilityScan = v.vulnerabilityScanFromAttestations(ctx, atts)
+		unified.VEX = v.vexFromAttestations(ctx, atts)
+		return unified, nil
+	}
 
-		sbom, err := v.extractSBOMFromAttestation(payload)
+	if dsseErr != nil {
+		return nil, dsseErr
+	}
+	if ageErr != nil {
+		return nil, ageErr
+	}
+	if err := predicateTypeError(rejected, accepted); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no SBOM found in attestations")
+}
+
+// sbomMetadataFromAttestations returns the format of the first verified SBOM attestation
+// whose predicate type is accepted, without decoding its predicate
+func (v *AttestationVerifier) sbomMetadataFromAttestations(ctx context.Context, atts []verifiedAttestation, accepted predicateTypeFilter) (*UnifiedSBOM, error) {
+	var rejected []string
+	var ageErr error
+	for i, att := range atts {
+		predicateType, _, err := v.parseStatement(att.payload)
 		if err != nil {
+			tracef(ctx, "attestation %d: %v", i, err)
 			continue
 		}
 
-		if sbom != nil {
-			return sbom, nil
+		format := sbomFormat(predicateType)
+		if format != "" && !accepted.allows(predicateType) {
+			tracef(ctx, "attestation %d: predicate type %s not accepted", i, predicateType)
+			rejected = appendUnique(rejected, predicateType)
+			continue
 		}
+		if format != "" {
+			tracef(ctx, "attestation %d: %s SBOM, predicate not decoded", i, format)
+			unified := &UnifiedSBOM{Format: format, Packages: []UnifiedPackage{}, MetadataOnly: true, SignedAt: formatTimestamp(att.signedAt), Unverified: att.unverified}
+			if err := v.checkSBOMAge(ctx, i, unified, att); err != nil {
+				tracef(ctx, "attestation %d: %v", i, err)
+				ageErr = err
+				continue
+			}
+			return unified, nil
+		}
+		tracef(ctx, "attestation %d: not an SBOM predicate", i)
 	}
 
+	if ageErr != nil {
+		return nil, ageErr
+	}
+	if err := predicateTypeError(rejected, accepted); err != nil {
+		return nil, err
+	}
 	return nil, fmt.Errorf("no SBOM found in attestations")
 }
 
+// joinSourceErrors combines the errors of all sources tried. The first coded error is
+// wrapped so its code is reported, the others are kept in the message.
+func joinSourceErrors(errs []error) error {
+	primary := 0
+	for i, err := range errs {
+		if ErrorCode(err) != "" {
+			primary = i
+			break
+		}
+	}
+
+	msgs := make([]string, 0, len(errs)-1)
+	for i, err := range errs {
+		if i != primary {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return errs[primary]
+	}
+	return fmt.Errorf("%w; %s", errs[primary], strings.Join(msgs, "; "))
+}
+
+// classifyFetchError attaches an error code to known attestation fetch failure classes
+func (v *AttestationVerifier) classifyFetchError(err error, ref name.Reference, keychain authn.Keychain) error {
+	err = classifyRegistryAuthError(err, ref, keychain)
+	return classifyCertExtensionError(classifyTlogError(classifyIdentityError(v.classifyTimeError(err))))
+}
+
+// monitorClock periodically measures node clock skew
+func (v *AttestationVerifier) monitorClock() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := v.clock.Check(ctx); err != nil {
+			log.Printf("Warning: clock skew check failed: %v", err)
+		}
+		cancel()
+		time.Sleep(clockCheckInterval)
+	}
+}
+
+// clockCheckInterval is how often node clock skew is re-measured
+const clockCheckInterval = 15 * time.Minute
+
+// remoteOptions returns the registry client options for a request
+func (v *AttestationVerifier) remoteOptions(ctx context.Context, keychain authn.Keychain) []remote.Option {
+	opts := []remote.Option{remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx)}
+	if v.transport != nil {
+		opts = append(opts, remote.WithTransport(v.transport))
+	}
+	return opts
+}
+
+// resolveDigest returns the manifest digest for ref, querying the registry for tags
+func resolveDigest(ref name.Reference, opts ...remote.Option) (v1.Hash, error) {
+	if d, ok := ref.(name.Digest); ok {
+		return v1.NewHash(d.DigestStr())
+	}
+
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("failed to resolve digest: %w", err)
+	}
+	return desc.Digest, nil
+}
+
 // createKeychainWithSecrets creates a keychain using the specified imagePullSecrets
 func (v *AttestationVerifier) createKeychainWithSecrets(ctx context.Context, secretNames []string) (authn.Keychain, error) {
 	if len(secretNames) == 0 {
 		return v.keychain, nil
 	}
 
-	// Get in-cluster config
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	if v.kubeClient == nil {
+		return nil, fmt.Errorf("kubernetes client not available")
 	}
 
-	// Create Kubernetes client
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	// Don't let a slow API server eat the whole verification timeout
+	if v.secretFetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.secretFetchTimeout)
+		defer cancel()
 	}
 
-	// Get the namespace from the service account
-	namespace := os.Getenv("POD_NAMESPACE")
-	if namespace == "" {
-		namespace = "default"
+	namespace := v.namespace
+	if ns := pullSecretNamespace(ctx); ns != "" {
+		namespace = ns
+	}
+	if !v.pullSecretNamespaceAllowed(namespace) {
+		recordKeychainFallback(ctx, namespace, secretNames, keychainFallbackNamespace, nil)
+		return v.keychain, nil
 	}
 
 	// Fetch the secrets
 	var secrets []corev1.Secret
 	for _, secretName := range secretNames {
-		secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		secret, err := v.kubeClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
 		if err != nil {
-			log.Printf("Warning: Failed to get secret %s: %v", secretName, err)
+			recordKeychainFallback(ctx, namespace, []string{secretName}, secretFetchFallbackReason(err), err)
 			continue
 		}
+		tracef(ctx, "loaded pull secret %s/%s (type %s)", namespace, secretName, secret.Type)
 		secrets = append(secrets, *secret)
 	}
 
@@ -206,18 +1013,81 @@ func (v *AttestationVerifier) createKeychainWithSecrets(ctx context.Context, sec
 		return v.keychain, nil
 	}
 
-	// Create keychain from the secrets
-	secretKeychain, err := k8schain.NewFromPullSecrets(ctx, secrets)
+	// Merge the registry entries of all secrets, earlier secrets take precedence
+	secretKeychain, err := newPullSecretKeychain(secrets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create keychain from secrets: %w", err)
 	}
 
-	// Combine with default keychain
-	return authn.NewMultiKeychain(secretKeychain, v.keychain), nil
+	// Pull secrets first, then the default sources
+	sources := append([]namedKeychain{{name: "pull secrets", keychain: secretKeychain}}, v.keychainSources...)
+	return newSourceKeychain(sources...), nil
+}
+
+// Defaults for the Kubernetes client rate limiter, above client-go's 5 QPS / 10 burst
+// since every admission request with imagePullSecrets reads them from the API server
+const (
+	DefaultKubeQPS   = 20
+	DefaultKubeBurst = 40
+)
+
+// DefaultSecretFetchTimeout bounds reading pull secrets for a single request
+const DefaultSecretFetchTimeout = 5 * time.Second
+
+// newKubeClient creates the in-cluster Kubernetes client, rate limited to qps/burst and
+// with timeout applied to each API request
+func newKubeClient(qps float32, burst int, timeout time.Duration) (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	}
+
+	if qps <= 0 {
+		qps = DefaultKubeQPS
+	}
+	if burst <= 0 {
+		burst = DefaultKubeBurst
+	}
+	config.QPS = qps
+	config.Burst = burst
+	config.Timeout = timeout
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return clientset, nil
 }
 
 // extractSBOMFromAttestation extracts SBOM data from an attestation
 func (v *AttestationVerifier) extractSBOMFromAttestation(attestation []byte) (interface{}, error) {
+	predicateType, predicate, err := v.parseStatement(attestation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract SBOM based on predicate type
+	switch sbomFormat(predicateType) {
+	case "spdx":
+		if isSPDX3(predicateType, predicate) {
+			return v.extractAndNormalizeSPDX3(predicate)
+		}
+		return v.extractAndNormalizeSPDX(predicate)
+	case "cyclonedx":
+		return v.extractAndNormalizeCycloneDX(predicate)
+	default:
+		return nil, nil
+	}
+}
+
+// parseStatement returns the predicate type and raw predicate of an in-toto statement,
+// unwrapping it from a Sigstore bundle and a DSSE envelope and decompressing it when needed
+func (v *AttestationVerifier) parseStatement(attestation []byte) (string, json.RawMessage, error) {
+	attestation, err := unwrapSigstoreBundle(attestation)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Check if this is a DSSE envelope (contains base64-encoded payload)
 	var envelope struct {
 		Payload     string        `json:"payload"`
@@ -228,10 +1098,14 @@ func (v *AttestationVerifier) extractSBOMFromAttestation(attestation []byte) (in
 	if err := json.Unmarshal(attestation, &envelope); err == nil && envelope.Payload != "" {
 		decodedPayload, err := base64.StdEncoding.DecodeString(envelope.Payload)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode DSSE payload: %w", err)
+			return "", nil, fmt.Errorf("failed to decode DSSE payload: %w", err)
 		}
 		attestation = decodedPayload
 	}
+	attestation, err = decompressPayload(attestation, v.maxDecompressedPayload())
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Parse the in-toto statement
 	var statement struct {
@@ -241,18 +1115,23 @@ func (v *AttestationVerifier) extractSBOMFromAttestation(attestation []byte) (in
 	}
 
 	if err := json.Unmarshal(attestation, &statement); err != nil {
-		return nil, fmt.Errorf("failed to parse attestation statement: %w", err)
+		return "", nil, fmt.Errorf("failed to parse attestation statement: %w", err)
 	}
+	return statement.PredicateType, statement.Predicate, nil
+}
 
-	// Extract SBOM based on predicate type
-	switch statement.PredicateType {
+// sbomFormat returns the SBOM format of an attestation predicate type, or "" for other predicates
+func sbomFormat(predicateType string) string {
+	switch predicateType {
 	case "https://spdx.dev/Document", "https://spdx.dev/Document/v2.3", "spdx":
-		return v.extractAndNormalizeSPDX(statement.Predicate)
+		return "spdx"
 	case "https://cyclonedx.org/bom", "https://cyclonedx.org/schema", "cyclonedx":
-		return v.extractAndNormalizeCycloneDX(statement.Predicate)
-	default:
-		return nil, nil
+		return "cyclonedx"
+	}
+	if spdx3PredicateTypes[predicateType] {
+		return "spdx"
 	}
+	return ""
 }
 
 // extractAndNormalizeSPDX extracts and normalizes SPDX SBOM data
@@ -265,6 +1144,14 @@ func (v *AttestationVerifier) extractAndNormalizeSPDX(predicate json.RawMessage)
 	unified := &UnifiedSBOM{
 		Format:   "spdx",
 		Packages: make([]UnifiedPackage, 0, len(sbom.Packages)),
+		Document: spdxDocumentMetadata(&sbom),
+	}
+	if v.spdxFilesSummary {
+		files, err := v.summarizeSPDXFiles(predicate)
+		if err != nil {
+			return nil, err
+		}
+		unified.Files = files
 	}
 
 	for _, pkg := range sbom.Packages {
@@ -272,11 +1159,26 @@ func (v *AttestationVerifier) extractAndNormalizeSPDX(predicate json.RawMessage)
 		if license == "" {
 			license = pkg.LicenseDeclared
 		}
+		normalized := v.licenseAliases.normalize(license)
 
+		purl := ""
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				purl = ref.ReferenceLocator
+				break
+			}
+		}
+
+		layer := spdxLayer(pkg.Annotations)
 		unified.Packages = append(unified.Packages, UnifiedPackage{
-			Name:    pkg.Name,
-			Version: pkg.VersionInfo,
-			License: license,
+			Name:        pkg.Name,
+			Version:     pkg.VersionInfo,
+			License:     normalized,
+			LicenseRaw:  rawLicense(license, normalized),
+			Licenses:    packageLicenses(normalized),
+			PURL:        purl,
+			LayerDigest: layer.digest,
+			LayerDiffID: layer.diffID,
 		})
 	}
 
@@ -293,24 +1195,54 @@ func (v *AttestationVerifier) extractAndNormalizeCycloneDX(predicate json.RawMes
 	unified := &UnifiedSBOM{
 		Format:   "cyclonedx",
 		Packages: make([]UnifiedPackage, 0, len(sbom.Components)),
+		Document: cycloneDXDocumentMetadata(&sbom),
 	}
+	direct := directDependencies(&sbom)
 
 	for _, comp := range sbom.Components {
-		license := ""
-		if len(comp.Licenses) > 0 {
-			if comp.Licenses[0].License.ID != "" {
-				license = comp.Licenses[0].License.ID
-			} else if comp.Licenses[0].License.Name != "" {
-				license = comp.Licenses[0].License.Name
+		// Scanners describe the detected distribution as an operating-system component
+		if comp.Type == "operating-system" {
+			unified.osDetected = true
+		}
+
+		// Every license listed applies: an SPDX ID, a name or an SPDX expression
+		var licenses, normalizedLicenses []string
+		for _, l := range comp.Licenses {
+			license := l.License.ID
+			if license == "" {
+				license = l.License.Name
+			}
+			if license == "" {
+				license = strings.TrimSpace(l.Expression)
+			}
+			if license != "" {
+				licenses = append(licenses, license)
+				normalizedLicenses = append(normalizedLicenses, v.licenseAliases.normalize(license))
 			}
 		}
+		license := joinLicenses(licenses)
+		normalized := joinLicenses(normalizedLicenses)
 
+		layer := cycloneDXLayer(comp.Properties)
 		unified.Packages = append(unified.Packages, UnifiedPackage{
-			Name:    comp.Name,
-			Version: comp.Version,
-			License: license,
-			PURL:    comp.Purl,
+			Name:        comp.Name,
+			Version:     comp.Version,
+			License:     normalized,
+			LicenseRaw:  rawLicense(license, normalized),
+			Licenses:    packageLicenses(normalized),
+			PURL:        comp.Purl,
+			LayerDigest: layer.digest,
+			LayerDiffID: layer.diffID,
 		})
+		if v.componentEvidence {
+			pkg := &unified.Packages[len(unified.Packages)-1]
+			pkg.Evidence = normalizeEvidence(comp.Evidence)
+			pkg.Pedigree = normalizePedigree(comp.Pedigree)
+		}
+		if direct != nil {
+			isDirect := comp.BOMRef != "" && direct[comp.BOMRef]
+			unified.Packages[len(unified.Packages)-1].Direct = &isDirect
+		}
 	}
 
 	return unified, nil
@@ -1,8 +1,10 @@
 package provider
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -339,3 +341,56 @@ func TestExtractAndNormalizeCycloneDX_LicenseExtraction(t *testing.T) {
 		t.Errorf("Expected empty license, got '%s'", unified.Packages[2].License)
 	}
 }
+
+func TestSBOMFromAttestations_EmptySBOM(t *testing.T) {
+	envelope := func(packages []map[string]interface{}) []byte {
+		statement, err := json.Marshal(map[string]interface{}{
+			"_type":         "https://in-toto.io/Statement/v0.1",
+			"predicateType": "https://spdx.dev/Document/v2.3",
+			"predicate": map[string]interface{}{
+				"SPDXID":      "SPDXRef-DOCUMENT",
+				"spdxVersion": "SPDX-2.3",
+				"name":        "distroless",
+				"packages":    packages,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal statement: %v", err)
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"payload":     base64.StdEncoding.EncodeToString(statement),
+			"payloadType": "application/vnd.in-toto+json",
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal envelope: %v", err)
+		}
+		return data
+	}
+
+	verifier := &AttestationVerifier{}
+	unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{{payload: envelope(nil)}})
+	if err != nil {
+		t.Fatalf("Failed to extract SBOM: %v", err)
+	}
+	if !unified.EmptySBOM {
+		t.Error("Expected an SBOM without packages to be flagged empty")
+	}
+
+	data, err := json.Marshal(unified)
+	if err != nil {
+		t.Fatalf("Failed to marshal SBOM: %v", err)
+	}
+	if !strings.Contains(string(data), `"emptySBOM":true`) {
+		t.Errorf("Expected emptySBOM in the response, got %s", data)
+	}
+
+	unified, err = verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{{payload: envelope([]map[string]interface{}{
+		{"SPDXID": "SPDXRef-Package", "name": "ca-certificates", "versionInfo": "20230311"},
+	})}})
+	if err != nil {
+		t.Fatalf("Failed to extract SBOM: %v", err)
+	}
+	if unified.EmptySBOM {
+		t.Error("Expected an SBOM with packages not to be flagged empty")
+	}
+}
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenVEXPredicateType is the predicate type of OpenVEX attestations (cosign attest --type openvex).
+// Versioned variants such as "https://openvex.dev/ns/v0.2.0" are accepted too.
+const OpenVEXPredicateType = "https://openvex.dev/ns"
+
+// maxVEXStatements bounds the VEX statements returned, newest first
+const maxVEXStatements = 500
+
+// VEX statuses, as defined by OpenVEX
+var vexStatuses = map[string]bool{
+	"not_affected":        true,
+	"affected":            true,
+	"fixed":               true,
+	"under_investigation": true,
+}
+
+// OpenVEXDocument is the OpenVEX predicate. Vulnerabilities and products are objects since
+// OpenVEX v0.2.0 and plain strings before.
+type OpenVEXDocument struct {
+	ID         string `json:"@id"`
+	Author     string `json:"author"`
+	Timestamp  string `json:"timestamp"`
+	Statements []struct {
+		Vulnerability   json.RawMessage   `json:"vulnerability"`
+		Products        []json.RawMessage `json:"products"`
+		Status          string            `json:"status"`
+		Justification   string            `json:"justification"`
+		ImpactStatement string            `json:"impact_statement"`
+		ActionStatement string            `json:"action_statement"`
+		Timestamp       string            `json:"timestamp"`
+	} `json:"statements"`
+}
+
+// VEX holds the statements of the verified OpenVEX attestations of the image, so policies can
+// suppress findings the vendor declared not exploitable
+type VEX struct {
+	Statements []VEXStatement `json:"statements"`          // Newest first, at most maxVEXStatements
+	Truncated  bool           `json:"truncated,omitempty"` // More than maxVEXStatements were found
+}
+
+// VEXStatement is the status of a vulnerability in the image or some of its components
+type VEXStatement struct {
+	Vulnerability   string   `json:"vulnerability"`     // e.g. "CVE-2024-3094"
+	Aliases         []string `json:"aliases,omitempty"` // Other IDs of the vulnerability, e.g. GHSA IDs
+	Status          string   `json:"status"`            // not_affected, affected, fixed or under_investigation
+	Justification   string   `json:"justification,omitempty"`
+	ImpactStatement string   `json:"impactStatement,omitempty"`
+	ActionStatement string   `json:"actionStatement,omitempty"`
+	Products        []string `json:"products,omitempty"`  // Product IDs or purls the statement covers
+	Timestamp       string   `json:"timestamp,omitempty"` // When the statement was made, RFC3339 UTC
+	Author          string   `json:"author,omitempty"`
+	Document        string   `json:"document,omitempty"` // @id of the OpenVEX document
+}
+
+// isOpenVEXPredicate reports whether a predicate type is OpenVEX
+func isOpenVEXPredicate(predicateType string) bool {
+	return predicateType == OpenVEXPredicateType || strings.HasPrefix(predicateType, OpenVEXPredicateType+"/")
+}
+
+// parseVEXVulnerability returns the name and aliases of an OpenVEX vulnerability
+func parseVEXVulnerability(raw json.RawMessage) (string, []string) {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return strings.TrimSpace(name), nil
+	}
+	var vuln struct {
+		ID      string   `json:"@id"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	if json.Unmarshal(raw, &vuln) != nil {
+		return "", nil
+	}
+	if vuln.Name == "" {
+		vuln.Name = vuln.ID
+	}
+	return strings.TrimSpace(vuln.Name), vuln.Aliases
+}
+
+// parseVEXProducts returns the IDs of OpenVEX products and their subcomponents, by @id or purl
+func parseVEXProducts(raws []json.RawMessage) []string {
+	var ids []string
+	for _, raw := range raws {
+		var id string
+		if json.Unmarshal(raw, &id) == nil {
+			ids = appendUnique(ids, id)
+			continue
+		}
+		var product struct {
+			ID          string `json:"@id"`
+			Identifiers struct {
+				Purl string `json:"purl"`
+			} `json:"identifiers"`
+			Subcomponents []json.RawMessage `json:"subcomponents"`
+		}
+		if json.Unmarshal(raw, &product) != nil {
+			continue
+		}
+		switch {
+		case product.ID != "":
+			ids = appendUnique(ids, product.ID)
+		case product.Identifiers.Purl != "":
+			ids = appendUnique(ids, product.Identifiers.Purl)
+		}
+		for _, sub := range parseVEXProducts(product.Subcomponents) {
+			ids = appendUnique(ids, sub)
+		}
+	}
+	return ids
+}
+
+// parseOpenVEX returns the statements of an OpenVEX predicate. Statements without a
+// vulnerability or with an unknown status are dropped.
+func parseOpenVEX(predicate json.RawMessage) ([]VEXStatement, error) {
+	var doc OpenVEXDocument
+	if err := json.Unmarshal(predicate, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenVEX predicate: %w", err)
+	}
+
+	statements := make([]VEXStatement, 0, len(doc.Statements))
+	for _, s := range doc.Statements {
+		name, aliases := parseVEXVulnerability(s.Vulnerability)
+		status := strings.ToLower(strings.TrimSpace(s.Status))
+		if name == "" || !vexStatuses[status] {
+			continue
+		}
+		statement := VEXStatement{
+			Vulnerability:   name,
+			Aliases:         aliases,
+			Status:          status,
+			Justification:   s.Justification,
+			ImpactStatement: s.ImpactStatement,
+			ActionStatement: s.ActionStatement,
+			Products:        parseVEXProducts(s.Products),
+			Author:          doc.Author,
+			Document:        doc.ID,
+		}
+		if t, ok := normalizeTimestamp(s.Timestamp); ok {
+			statement.Timestamp = t
+		} else if t, ok := normalizeTimestamp(doc.Timestamp); ok {
+			statement.Timestamp = t
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// vexFromAttestations merges the statements of the verified OpenVEX attestations among the
+// attestations, or returns nil when VEX statements are not returned or none is attached. The
+// newest statement about a vulnerability and set of products supersedes older ones, as OpenVEX
+// specifies. Unsigned BuildKit statements are never used.
+func (v *AttestationVerifier) vexFromAttestations(ctx context.Context, atts []verifiedAttestation) *VEX {
+	if !v.vexStatements {
+		return nil
+	}
+
+	var all []VEXStatement
+	found := false
+	for i, att := range atts {
+		if att.unverified {
+			continue
+		}
+		predicateType, predicate, err := v.parseStatement(att.payload)
+		if err != nil || !isOpenVEXPredicate(predicateType) {
+			continue
+		}
+		statements, err := parseOpenVEX(predicate)
+		if err != nil {
+			tracef(ctx, "attestation %d: %v", i, err)
+			continue
+		}
+		tracef(ctx, "attestation %d: OpenVEX document with %d statements", i, len(statements))
+		found = true
+		for _, s := range statements {
+			// Statements without a timestamp date from when their document was logged
+			if s.Timestamp == "" {
+				s.Timestamp = formatTimestamp(att.signedAt)
+			}
+			all = append(all, s)
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	statementTime := func(s VEXStatement) time.Time {
+		t, _ := parseTimestamp(s.Timestamp)
+		return t
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return statementTime(all[i]).After(statementTime(all[j]))
+	})
+
+	vex := &VEX{Statements: []VEXStatement{}}
+	seen := make(map[string]bool, len(all))
+	for _, s := range all {
+		products := append([]string(nil), s.Products...)
+		sort.Strings(products)
+		key := s.Vulnerability + "|" + strings.Join(products, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(vex.Statements) == maxVEXStatements {
+			vex.Truncated = true
+			break
+		}
+		vex.Statements = append(vex.Statements, s)
+	}
+	return vex
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseOpenVEX(t *testing.T) {
+	statements, err := parseOpenVEX(json.RawMessage(`{"@id":"https://example.com/vex/1","author":"Example Security","timestamp":"2024-05-01T10:00:00Z","statements":[
+		{"vulnerability":{"name":"CVE-2024-3094","aliases":["GHSA-rxwq-x6h5-x525"]},"products":[{"@id":"pkg:oci/app","subcomponents":[{"identifiers":{"purl":"pkg:deb/debian/xz-utils@5.6.0"}}]}],
+		 "status":"not_affected","justification":"vulnerable_code_not_in_execute_path","timestamp":"2024-05-02T08:00:00.5Z"},
+		{"vulnerability":"CVE-2024-2961","products":["pkg:oci/app"],"status":"FIXED"},
+		{"vulnerability":{"name":"CVE-2024-0001"},"status":"maybe"},
+		{"products":["pkg:oci/app"],"status":"affected"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse OpenVEX: %v", err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("Expected statements without a vulnerability or status to be dropped, got %+v", statements)
+	}
+
+	first := statements[0]
+	if first.Vulnerability != "CVE-2024-3094" || len(first.Aliases) != 1 || first.Status != "not_affected" || first.Timestamp != "2024-05-02T08:00:00Z" {
+		t.Errorf("Unexpected v0.2.0 statement %+v", first)
+	}
+	if len(first.Products) != 2 || first.Products[1] != "pkg:deb/debian/xz-utils@5.6.0" || first.Document != "https://example.com/vex/1" {
+		t.Errorf("Expected the product and its subcomponent, got %+v", first)
+	}
+	if second := statements[1]; second.Vulnerability != "CVE-2024-2961" || second.Status != "fixed" || second.Timestamp != "2024-05-01T10:00:00Z" {
+		t.Errorf("Expected a legacy statement dated from its document, got %+v", second)
+	}
+
+	if _, err := parseOpenVEX(json.RawMessage(`[]`)); err == nil {
+		t.Error("Expected an error for an invalid predicate")
+	}
+}
+
+func TestVEXFromAttestations(t *testing.T) {
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	spdx := vulnAttestation(t, "https://spdx.dev/Document", `{"spdxVersion":"SPDX-2.3","packages":[{"name":"xz-utils"}]}`, signedAt)
+	older := vulnAttestation(t, OpenVEXPredicateType, `{"@id":"vex-1","timestamp":"2024-04-01T00:00:00Z","statements":[
+		{"vulnerability":"CVE-2024-3094","products":["pkg:oci/app"],"status":"under_investigation"},
+		{"vulnerability":"CVE-2024-2961","products":["pkg:oci/app"],"status":"affected"}]}`, signedAt)
+	newer := vulnAttestation(t, OpenVEXPredicateType+"/v0.2.0", `{"@id":"vex-2","statements":[
+		{"vulnerability":{"name":"CVE-2024-3094"},"products":[{"@id":"pkg:oci/app"}],"status":"not_affected","justification":"component_not_present"}]}`, signedAt)
+	unsigned := vulnAttestation(t, OpenVEXPredicateType, `{"@id":"vex-3","timestamp":"2024-06-01T00:00:00Z","statements":[
+		{"vulnerability":"CVE-2024-2961","products":["pkg:oci/app"],"status":"not_affected"}]}`, time.Time{})
+	unsigned.unverified = true
+
+	verifier := &AttestationVerifier{}
+	unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{spdx, older, newer})
+	if err != nil || unified.VEX != nil {
+		t.Fatalf("Expected no VEX statements unless enabled, got %+v and %v", unified, err)
+	}
+
+	verifier.vexStatements = true
+	unified, err = verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{older, spdx, newer, unsigned})
+	if err != nil {
+		t.Fatalf("Failed to extract the SBOM: %v", err)
+	}
+	if unified.VEX == nil || len(unified.VEX.Statements) != 2 {
+		t.Fatalf("Expected one statement per vulnerability and products, got %+v", unified.VEX)
+	}
+	if s := unified.VEX.Statements[0]; s.Vulnerability != "CVE-2024-3094" || s.Status != "not_affected" || s.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Errorf("Expected the newer statement, dated from its log time, to supersede the older, got %+v", s)
+	}
+	if s := unified.VEX.Statements[1]; s.Vulnerability != "CVE-2024-2961" || s.Status != "affected" {
+		t.Errorf("Expected the unverified statement to be ignored, got %+v", s)
+	}
+
+	metadataCtx := withKeyOptions(context.Background(), keyOptions{metadataOnly: true})
+	unified, err = verifier.sbomFromAttestations(metadataCtx, []verifiedAttestation{spdx, older})
+	if err != nil || unified.VEX == nil || len(unified.VEX.Statements) != 2 {
+		t.Errorf("Expected the VEX statements with metadata-only verification, got %+v and %v", unified, err)
+	}
+
+	unified, err = verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{spdx})
+	if err != nil || unified.VEX != nil {
+		t.Errorf("Expected no VEX statements without OpenVEX attestations, got %+v and %v", unified, err)
+	}
+}
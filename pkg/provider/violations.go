@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// identityMismatchMarker is how cosign reports a certificate that does not match the expected identities
+const identityMismatchMarker = "none of the expected identities matched"
+
+// identityMismatchPattern extracts the signer cosign found on each mismatched attestation
+var identityMismatchPattern = regexp.MustCompile(identityMismatchMarker + ` what was in the certificate, got subjects \[([^\]]*)\] with issuer (\S*)`)
+
+// emailIdentityPattern matches signer identities that are email addresses
+var emailIdentityPattern = regexp.MustCompile(`^([^@/:\s])[^@/:\s]*(@[^@/\s]+)$`)
+
+// Bounds on the signer identities reported for an identity mismatch
+const (
+	maxReportedIdentities     = 5
+	maxReportedIdentityLength = 200
+)
+
+// identityMismatchError reports attestations that verified but were signed by unexpected
+// identities, so users can tell a constraint expecting the wrong signer from a pipeline that
+// attaches no attestation at all
+type identityMismatchError struct {
+	attestations int      // Attestations signed by other identities
+	identities   []string // Distinct sanitized signer identities, at most maxReportedIdentities
+	more         int      // Distinct identities left out
+}
+
+// Error summarizes the attestations and the identities that signed them
+func (e *identityMismatchError) Error() string {
+	identities := strings.Join(e.identities, ", ")
+	if e.more > 0 {
+		identities += fmt.Sprintf(" and %d more", e.more)
+	}
+	return fmt.Sprintf("%s: %d verifiable attestations found, signed by other identities: %s; update certIdentity/certOidcIssuer if this signer is expected",
+		identityMismatchMarker, e.attestations, identities)
+}
+
+// sanitizeIdentity makes a signer identity safe for denial messages: credentials are redacted,
+// the local part of email addresses is masked and the length is capped
+func sanitizeIdentity(identity string) string {
+	identity = redactCredentials(strings.Join(strings.Fields(identity), " "))
+	identity = emailIdentityPattern.ReplaceAllString(identity, "${1}***${2}")
+	if len(identity) > maxReportedIdentityLength {
+		identity = identity[:maxReportedIdentityLength] + "..."
+	}
+	return identity
+}
+
+// classifyIdentityError attaches ERR_IDENTITY_MISMATCH to attestations signed by an unexpected
+// identity, replacing cosign's per-attestation messages with a summary of the signers seen
+func classifyIdentityError(err error) error {
+	if err == nil || ErrorCode(err) != "" || !strings.Contains(err.Error(), identityMismatchMarker) {
+		return err
+	}
+
+	matches := identityMismatchPattern.FindAllStringSubmatch(err.Error(), -1)
+	if len(matches) == 0 {
+		return &VerificationError{Code: ErrCodeIdentityMismatch, Err: err}
+	}
+	mismatch := &identityMismatchError{attestations: len(matches)}
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		for _, subject := range strings.Split(match[1], ", ") {
+			identity := sanitizeIdentity(subject) + " (issuer " + sanitizeIdentity(match[2]) + ")"
+			if seen[identity] {
+				continue
+			}
+			seen[identity] = true
+			if len(mismatch.identities) < maxReportedIdentities {
+				mismatch.identities = append(mismatch.identities, identity)
+			} else {
+				mismatch.more++
+			}
+		}
+	}
+	return &VerificationError{Code: ErrCodeIdentityMismatch, Err: mismatch}
+}
+
+// sourceError is the failure of a single attestation source
+type sourceError struct {
+	source string
+	err    error
+}
+
+// Error returns the failure prefixed with its source
+func (e *sourceError) Error() string {
+	return e.source + ": " + e.err.Error()
+}
+
+// Unwrap returns the failure of the source
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// sbomDespiteIdentity verifies the SBOM of sources that only failed the identity check again,
+// without identity constraints, and reports the mismatches as violations. Signatures and
+// transparency log entries are still verified, so the SBOM is genuine even though its signer
+// is not the expected one.
+func (v *AttestationVerifier) sbomDespiteIdentity(ctx context.Context, errs []error, ref name.Reference, checkOpts *cosign.CheckOpts, keychain authn.Keychain) (*UnifiedSBOM, string, bool) {
+	var violations []Violation
+	var sources []string
+	for _, err := range errs {
+		var serr *sourceError
+		if !errors.As(err, &serr) || ErrorCode(serr.err) != ErrCodeIdentityMismatch {
+			continue
+		}
+		violation := Violation{Code: ErrCodeIdentityMismatch, Source: serr.source, Message: serr.err.Error()}
+		var mismatch *identityMismatchError
+		if errors.As(serr.err, &mismatch) {
+			violation.Attestations = mismatch.attestations
+			violation.Identities = mismatch.identities
+		}
+		violations = append(violations, violation)
+		sources = append(sources, serr.source)
+	}
+
+	opts := *checkOpts
+	opts.Identities = nil
+	for _, source := range sources {
+		atts, err := v.fetchAttestations(ctx, source, ref, &opts, keychain)
+		if err == nil && len(atts) == 0 {
+			err = errors.New("no attestations found")
+		}
+		if err != nil {
+			tracef(ctx, "attestation source %s without identity constraints: %v", source, err)
+			continue
+		}
+
+		unified, err := v.sbomFromAttestations(ctx, atts)
+		if err != nil {
+			tracef(ctx, "attestation source %s without identity constraints: %v", source, err)
+			continue
+		}
+		log.Printf("Returning the SBOM of %s signed by an unexpected identity with %d violations", ref, len(violations))
+		unified.Violations = violations
+		return unified, source, true
+	}
+	return nil, "", false
+}
@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VulnPredicateType is the predicate type of cosign vulnerability scan attestations
+// (cosign attest --type vuln)
+const VulnPredicateType = "https://cosign.sigstore.dev/attestation/vuln/v1"
+
+// maxVulnerabilities bounds the vulnerabilities listed from a scan, most severe first
+const maxVulnerabilities = 500
+
+// Normalized vulnerability severities, most severe first
+var vulnSeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+// VulnPredicate is the cosign vuln predicate. The scanner result is scanner-specific.
+type VulnPredicate struct {
+	Scanner struct {
+		URI     string `json:"uri"`
+		Version string `json:"version"`
+		DB      struct {
+			URI     string `json:"uri"`
+			Version string `json:"version"`
+		} `json:"db"`
+		Result json.RawMessage `json:"result"`
+	} `json:"scanner"`
+	Metadata struct {
+		ScanStartedOn  string `json:"scanStartedOn"`
+		ScanFinishedOn string `json:"scanFinishedOn"`
+	} `json:"metadata"`
+}
+
+// VulnerabilityScan is the newest verified vulnerability scan attestation of the image,
+// normalized across scanners so policies can check scan freshness and severity thresholds
+type VulnerabilityScan struct {
+	Scanner         string          `json:"scanner"` // Scanner URI, e.g. "pkg:github/aquasecurity/trivy@v0.50.1"
+	ScannerVersion  string          `json:"scannerVersion,omitempty"`
+	DBVersion       string          `json:"dbVersion,omitempty"`       // Version of the vulnerability database scanned against
+	ScannedAt       string          `json:"scannedAt,omitempty"`       // When the scan finished, RFC3339 UTC
+	SignedAt        string          `json:"signedAt,omitempty"`        // When the scan attestation was logged in the transparency log, RFC3339 UTC
+	Counts          map[string]int  `json:"counts"`                    // Vulnerabilities by normalized severity
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"` // Most severe first, at most maxVulnerabilities
+	Truncated       bool            `json:"truncated,omitempty"`       // More than maxVulnerabilities were found
+	ResultFormat    string          `json:"resultFormat"`              // Scanner result format read: "trivy", "grype" or "unknown"
+}
+
+// Vulnerability is a finding of a vulnerability scan
+type Vulnerability struct {
+	ID           string `json:"id"`       // e.g. "CVE-2024-3094" or "GHSA-..."
+	Severity     string `json:"severity"` // CRITICAL, HIGH, MEDIUM, LOW or UNKNOWN
+	Package      string `json:"package,omitempty"`
+	Version      string `json:"version,omitempty"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+}
+
+// trivyResult is the part of a Trivy JSON report the normalization reads
+type trivyResult struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// grypeResult is the part of a Grype JSON report the normalization reads
+type grypeResult struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Fix      struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+// normalizeSeverity maps scanner severities onto vulnSeverities. Grype's "Negligible" is LOW.
+func normalizeSeverity(severity string) string {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	switch severity {
+	case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+		return severity
+	case "NEGLIGIBLE":
+		return "LOW"
+	case "MODERATE":
+		return "MEDIUM"
+	}
+	return "UNKNOWN"
+}
+
+// severityRank orders severities, most severe first
+func severityRank(severity string) int {
+	for i, s := range vulnSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(vulnSeverities)
+}
+
+// parseVulnerabilityScan normalizes a cosign vuln predicate. Trivy and Grype reports are
+// read; scans from other scanners are returned without findings and resultFormat "unknown".
+func parseVulnerabilityScan(predicate json.RawMessage) (*VulnerabilityScan, error) {
+	var p VulnPredicate
+	if err := json.Unmarshal(predicate, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse vuln predicate: %w", err)
+	}
+
+	scan := &VulnerabilityScan{
+		Scanner:        p.Scanner.URI,
+		ScannerVersion: p.Scanner.Version,
+		DBVersion:      p.Scanner.DB.Version,
+		Counts:         make(map[string]int, len(vulnSeverities)),
+		ResultFormat:   "unknown",
+	}
+	if t, ok := normalizeTimestamp(p.Metadata.ScanFinishedOn); ok {
+		scan.ScannedAt = t
+	} else if t, ok := normalizeTimestamp(p.Metadata.ScanStartedOn); ok {
+		scan.ScannedAt = t
+	}
+	for _, s := range vulnSeverities {
+		scan.Counts[s] = 0
+	}
+
+	var vulns []Vulnerability
+	var trivy trivyResult
+	var grype grypeResult
+	switch {
+	case json.Unmarshal(p.Scanner.Result, &trivy) == nil && trivy.Results != nil:
+		scan.ResultFormat = "trivy"
+		for _, result := range trivy.Results {
+			for _, v := range result.Vulnerabilities {
+				vulns = append(vulns, Vulnerability{
+					ID:           v.VulnerabilityID,
+					Severity:     normalizeSeverity(v.Severity),
+					Package:      v.PkgName,
+					Version:      v.InstalledVersion,
+					FixedVersion: v.FixedVersion,
+				})
+			}
+		}
+	case json.Unmarshal(p.Scanner.Result, &grype) == nil && grype.Matches != nil:
+		scan.ResultFormat = "grype"
+		for _, m := range grype.Matches {
+			vulns = append(vulns, Vulnerability{
+				ID:           m.Vulnerability.ID,
+				Severity:     normalizeSeverity(m.Vulnerability.Severity),
+				Package:      m.Artifact.Name,
+				Version:      m.Artifact.Version,
+				FixedVersion: strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			})
+		}
+	}
+
+	for _, v := range vulns {
+		scan.Counts[v.Severity]++
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		return severityRank(vulns[i].Severity) < severityRank(vulns[j].Severity)
+	})
+	if len(vulns) > maxVulnerabilities {
+		vulns, scan.Truncated = vulns[:maxVulnerabilities], true
+	}
+	scan.Vulnerabilities = vulns
+	return scan, nil
+}
+
+// vulnerabilityScanFromAttestations returns the newest verified vulnerability scan among the
+// attestations, by scan time and else by log time, or nil when vulnerability scans are not
+// returned or none is attached. Unsigned BuildKit statements are never used.
+func (v *AttestationVerifier) vulnerabilityScanFromAttestations(ctx context.Context, atts []verifiedAttestation) *VulnerabilityScan {
+	if !v.vulnerabilityScans {
+		return nil
+	}
+
+	var newest *VulnerabilityScan
+	var newestAt time.Time
+	for i, att := range atts {
+		if att.unverified {
+			continue
+		}
+		predicateType, predicate, err := v.parseStatement(att.payload)
+		if err != nil || predicateType != VulnPredicateType {
+			continue
+		}
+		scan, err := parseVulnerabilityScan(predicate)
+		if err != nil {
+			tracef(ctx, "attestation %d: %v", i, err)
+			continue
+		}
+		scan.SignedAt = formatTimestamp(att.signedAt)
+
+		at := att.signedAt
+		if t, ok := parseTimestamp(scan.ScannedAt); ok {
+			at = t
+		}
+		tracef(ctx, "attestation %d: %s vulnerability scan from %s with %d findings", i, scan.ResultFormat, scan.ScannedAt, len(scan.Vulnerabilities))
+		if newest == nil || at.After(newestAt) {
+			newest, newestAt = scan, at
+		}
+	}
+	return newest
+}
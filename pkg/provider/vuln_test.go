@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func vulnAttestation(t *testing.T, predicateType, predicate string, signedAt time.Time) verifiedAttestation {
+	t.Helper()
+	statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"` + predicateType + `","predicate":` + predicate + `}`
+	envelope, err := json.Marshal(map[string]string{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+	return verifiedAttestation{payload: envelope, signedAt: signedAt}
+}
+
+func TestParseVulnerabilityScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate string
+		format    string
+		counts    map[string]int
+		first     Vulnerability
+	}{
+		{
+			name: "trivy",
+			predicate: `{"scanner":{"uri":"pkg:github/aquasecurity/trivy@v0.50.1","version":"0.50.1","db":{"version":"2"},"result":{"SchemaVersion":2,"Results":[
+				{"Target":"debian","Vulnerabilities":[
+					{"VulnerabilityID":"CVE-2024-0001","PkgName":"zlib","InstalledVersion":"1.2","Severity":"LOW"},
+					{"VulnerabilityID":"CVE-2024-2961","PkgName":"libc6","InstalledVersion":"2.36-9","FixedVersion":"2.36-9+deb12u7","Severity":"HIGH"}]}]}},
+				"metadata":{"scanStartedOn":"2024-05-01T10:00:00Z","scanFinishedOn":"2024-05-01T10:01:40.123Z"}}`,
+			format: "trivy",
+			counts: map[string]int{"CRITICAL": 0, "HIGH": 1, "MEDIUM": 0, "LOW": 1, "UNKNOWN": 0},
+			first:  Vulnerability{ID: "CVE-2024-2961", Severity: "HIGH", Package: "libc6", Version: "2.36-9", FixedVersion: "2.36-9+deb12u7"},
+		},
+		{
+			name: "grype",
+			predicate: `{"scanner":{"uri":"pkg:github/anchore/grype@v0.77.0","result":{"matches":[
+				{"vulnerability":{"id":"GHSA-xxxx","severity":"Negligible"},"artifact":{"name":"a","version":"1"}},
+				{"vulnerability":{"id":"CVE-2024-3094","severity":"Critical","fix":{"versions":["5.6.2","5.4.7"]}},"artifact":{"name":"xz-utils","version":"5.6.0"}}]}},
+				"metadata":{"scanFinishedOn":"2024-05-01T10:01:40Z"}}`,
+			format: "grype",
+			counts: map[string]int{"CRITICAL": 1, "HIGH": 0, "MEDIUM": 0, "LOW": 1, "UNKNOWN": 0},
+			first:  Vulnerability{ID: "CVE-2024-3094", Severity: "CRITICAL", Package: "xz-utils", Version: "5.6.0", FixedVersion: "5.6.2, 5.4.7"},
+		},
+		{
+			name:      "unknown scanner",
+			predicate: `{"scanner":{"uri":"pkg:generic/scanner","result":{"findings":[{"id":"CVE-2024-1"}]}},"metadata":{"scanFinishedOn":"2024-05-01T10:01:40Z"}}`,
+			format:    "unknown",
+			counts:    map[string]int{"CRITICAL": 0, "HIGH": 0, "MEDIUM": 0, "LOW": 0, "UNKNOWN": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := parseVulnerabilityScan(json.RawMessage(tt.predicate))
+			if err != nil {
+				t.Fatalf("Failed to parse scan: %v", err)
+			}
+			if scan.ResultFormat != tt.format || scan.ScannedAt != "2024-05-01T10:01:40Z" {
+				t.Errorf("Expected a %s scan from 2024-05-01T10:01:40Z, got %s from %s", tt.format, scan.ResultFormat, scan.ScannedAt)
+			}
+			for severity, want := range tt.counts {
+				if scan.Counts[severity] != want {
+					t.Errorf("Expected %d %s findings, got %d", want, severity, scan.Counts[severity])
+				}
+			}
+			if tt.first.ID == "" {
+				if len(scan.Vulnerabilities) != 0 {
+					t.Errorf("Expected no findings, got %+v", scan.Vulnerabilities)
+				}
+				return
+			}
+			if len(scan.Vulnerabilities) == 0 || scan.Vulnerabilities[0] != tt.first {
+				t.Errorf("Expected the most severe finding %+v first, got %+v", tt.first, scan.Vulnerabilities)
+			}
+		})
+	}
+
+	if _, err := parseVulnerabilityScan(json.RawMessage(`[]`)); err == nil {
+		t.Error("Expected an error for an invalid predicate")
+	}
+}
+
+func TestVulnerabilityScanFromAttestations(t *testing.T) {
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	spdx := vulnAttestation(t, "https://spdx.dev/Document", `{"spdxVersion":"SPDX-2.3","packages":[{"name":"zlib"}]}`, signedAt)
+	older := vulnAttestation(t, VulnPredicateType, `{"scanner":{"uri":"old","result":{"Results":[]}},"metadata":{"scanFinishedOn":"2024-04-01T00:00:00Z"}}`, signedAt)
+	newer := vulnAttestation(t, VulnPredicateType, `{"scanner":{"uri":"new","result":{"Results":[]}},"metadata":{"scanFinishedOn":"2024-05-01T00:00:00Z"}}`, signedAt)
+	unsigned := vulnAttestation(t, VulnPredicateType, `{"scanner":{"uri":"unsigned","result":{"Results":[]}},"metadata":{"scanFinishedOn":"2024-06-01T00:00:00Z"}}`, time.Time{})
+	unsigned.unverified = true
+
+	verifier := &AttestationVerifier{}
+	unified, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{spdx, older, newer})
+	if err != nil || unified.VulnerabilityScan != nil {
+		t.Fatalf("Expected no scan unless enabled, got %+v and %v", unified, err)
+	}
+
+	verifier.vulnerabilityScans = true
+	unified, err = verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{older, spdx, newer, unsigned})
+	if err != nil {
+		t.Fatalf("Failed to extract the SBOM: %v", err)
+	}
+	if unified.VulnerabilityScan == nil || unified.VulnerabilityScan.Scanner != "new" || unified.VulnerabilityScan.SignedAt != "2024-05-01T12:00:00Z" {
+		t.Errorf("Expected the newest verified scan, got %+v", unified.VulnerabilityScan)
+	}
+
+	metadataCtx := withKeyOptions(context.Background(), keyOptions{metadataOnly: true})
+	unified, err = verifier.sbomFromAttestations(metadataCtx, []verifiedAttestation{spdx, older})
+	if err != nil || unified.VulnerabilityScan == nil || unified.VulnerabilityScan.Scanner != "old" {
+		t.Errorf("Expected the scan with metadata-only verification, got %+v and %v", unified, err)
+	}
+
+	if _, err := verifier.sbomFromAttestations(context.Background(), []verifiedAttestation{newer}); err == nil {
+		t.Error("Expected a scan without an SBOM to fail")
+	}
+}
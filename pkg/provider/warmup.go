@@ -0,0 +1,336 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// maxWarmupImages bounds the images warmed by a single /warmup request
+const maxWarmupImages = 100
+
+// maxWarmupManifestSize bounds the body of a /warmup request
+const maxWarmupManifestSize = 4 << 20
+
+// warmupConcurrency is how many images of a /warmup request are verified at once. Verification
+// slots are granted by the batch scheduler, so this only bounds the goroutines waiting for one.
+const warmupConcurrency = 4
+
+// podSpecContainerFields are the PodSpec fields listing containers whose images are verified
+var podSpecContainerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// Outcomes of /warmup images, by result
+var warmupImages struct {
+	cached, verified, failed atomic.Int64
+}
+
+// WarmupRequest asks /warmup to verify the images of Kubernetes manifests ahead of admission,
+// so CD pipelines can warm the cache before applying them. The key settings must match those
+// of the constraint for admission requests to hit the warmed results; pull secrets are taken
+// from the manifests like the policy template does.
+type WarmupRequest struct {
+	Manifests string `json:"manifests"` // YAML or JSON manifests; multi-document YAML and Lists are accepted
+	KeySettings
+}
+
+// WarmupResponse reports the outcome of a /warmup request per image
+type WarmupResponse struct {
+	Images   []WarmupResult `json:"images"`
+	Verified int            `json:"verified"` // Images verified or already cached
+	Failed   int            `json:"failed"`
+}
+
+// WarmupResult is the outcome of warming a single image
+type WarmupResult struct {
+	Image  string `json:"image"`
+	Cached bool   `json:"cached,omitempty"` // The image was verified before the request
+	Error  string `json:"error,omitempty"`
+}
+
+// String renders the response as a human-readable summary
+func (r *WarmupResponse) String() string {
+	var b strings.Builder
+	for _, img := range r.Images {
+		switch {
+		case img.Error != "":
+			fmt.Fprintf(&b, "FAILED  %s: %s\n", img.Image, img.Error)
+		case img.Cached:
+			fmt.Fprintf(&b, "CACHED  %s\n", img.Image)
+		default:
+			fmt.Fprintf(&b, "WARMED  %s\n", img.Image)
+		}
+	}
+	fmt.Fprintf(&b, "%d images: %d verified, %d failed\n", len(r.Images), r.Verified, r.Failed)
+	return b.String()
+}
+
+// manifestImage is an image of a manifest with the pull secrets admission requests for it
+// carry in their keys
+type manifestImage struct {
+	image       string
+	pullSecrets []string
+}
+
+// ExtractImages returns the container images of Kubernetes manifests, in order of appearance
+// and without duplicates. Images are read from every PodSpec in the manifests, whatever their
+// kind, so Pods, workloads, CronJobs, Lists and custom resources embedding pod templates are
+// all covered, including init and ephemeral containers.
+func ExtractImages(manifests []byte) ([]string, error) {
+	found, err := manifestImages(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+	for _, m := range found {
+		if !seen[m.image] {
+			seen[m.image] = true
+			images = append(images, m.image)
+		}
+	}
+	return images, nil
+}
+
+// manifestImages returns the images of manifests with the pull secrets of the object they
+// belong to, without duplicates. Items of Lists are objects of their own, as Gatekeeper
+// reviews them separately.
+func manifestImages(manifests []byte) ([]manifestImage, error) {
+	var images []manifestImage
+	seen := make(map[string]bool)
+	addObject := func(obj map[string]interface{}) {
+		secrets := objectPullSecrets(obj)
+		collectImages(obj, func(image string) {
+			id := image + "|" + strings.Join(secrets, ",")
+			if image != "" && !seen[id] {
+				seen[id] = true
+				images = append(images, manifestImage{image: image, pullSecrets: secrets})
+			}
+		})
+	}
+
+	dec := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+	for i := 0; ; i++ {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("invalid manifest %d: %w", i, err)
+		}
+		if doc["kind"] != "List" {
+			addObject(doc)
+			continue
+		}
+		items, _ := doc["items"].([]interface{})
+		for _, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				addObject(obj)
+			}
+		}
+	}
+	return images, nil
+}
+
+// objectPullSecrets returns the imagePullSecrets the policy template puts in the keys of obj:
+// those of the Pod spec for Pods and of the pod template for other kinds
+func objectPullSecrets(obj map[string]interface{}) []string {
+	spec, _ := obj["spec"].(map[string]interface{})
+	if obj["kind"] != "Pod" {
+		template, _ := spec["template"].(map[string]interface{})
+		spec, _ = template["spec"].(map[string]interface{})
+	}
+	refs, _ := spec["imagePullSecrets"].([]interface{})
+
+	var secrets []string
+	for _, ref := range refs {
+		if r, ok := ref.(map[string]interface{}); ok {
+			if name, ok := r["name"].(string); ok {
+				secrets = append(secrets, name)
+			}
+		}
+	}
+	return secrets
+}
+
+// collectImages calls add with the image of every container listed in a PodSpec below node.
+// Map keys are visited in sorted order so images are reported deterministically.
+func collectImages(node interface{}, add func(string)) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, field := range podSpecContainerFields {
+			containers, _ := n[field].([]interface{})
+			for _, c := range containers {
+				if container, ok := c.(map[string]interface{}); ok {
+					image, _ := container["image"].(string)
+					add(strings.TrimSpace(image))
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collectImages(n[k], add)
+		}
+	case []interface{}:
+		for _, v := range n {
+			collectImages(v, add)
+		}
+	}
+}
+
+// handleWarmup verifies every image of the manifests of a WarmupRequest as batch traffic and
+// caches the results, reporting the outcome per image
+func (s *Server) handleWarmup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWarmupManifestSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWarmupManifestSize {
+		http.Error(w, fmt.Sprintf("request exceeds %d bytes", maxWarmupManifestSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req WarmupRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	images, err := manifestImages([]byte(req.Manifests))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(images) == 0 || len(images) > maxWarmupImages {
+		http.Error(w, fmt.Sprintf("manifests must reference between 1 and %d images, found %d", maxWarmupImages, len(images)), http.StatusBadRequest)
+		return
+	}
+
+	response := WarmupResponse{Images: make([]WarmupResult, len(images))}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, warmupConcurrency)
+	for i, m := range images {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			response.Images[i] = s.warmImage(m, &req.KeySettings)
+		}()
+	}
+	wg.Wait()
+
+	for _, img := range response.Images {
+		if img.Error != "" {
+			response.Failed++
+		} else {
+			response.Verified++
+		}
+	}
+	log.Printf("Warmed %d images (%d failed)", len(images), response.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// warmImage verifies an image under settings, caching the result like an admission request
+// for its object would
+func (s *Server) warmImage(m manifestImage, settings *KeySettings) WarmupResult {
+	key := settings.key(m.image, m.pullSecrets)
+	imageRef, opts := splitKeyOptions(key)
+	if _, ok := s.cachedItem(opts.resultKey(imageRef)); ok {
+		warmupImages.cached.Add(1)
+		return WarmupResult{Image: m.image, Cached: true}
+	}
+
+	item := s.checkImage(key, RequestClassBatch)
+	if item.Error != "" {
+		warmupImages.failed.Add(1)
+		s.logs.Printf("Error warming %s: %s", m.image, item.Error)
+		return WarmupResult{Image: m.image, Error: item.Error}
+	}
+	warmupImages.verified.Add(1)
+	return WarmupResult{Image: m.image}
+}
+
+// writeWarmupMetrics writes the /warmup counters in Prometheus text format
+func writeWarmupMetrics(w io.Writer) {
+	const name = "sbom_provider_warmup_images_total"
+	fmt.Fprintf(w, "# HELP %s Images of manifests warmed by /warmup, by result.\n# TYPE %s counter\n", name, name)
+	fmt.Fprintf(w, "%s{result=\"cached\"} %d\n", name, warmupImages.cached.Load())
+	fmt.Fprintf(w, "%s{result=\"verified\"} %d\n", name, warmupImages.verified.Load())
+	fmt.Fprintf(w, "%s{result=\"failed\"} %d\n", name, warmupImages.failed.Load())
+}
+
+// WarmupClientConfig configures a warmup request sent to a running provider
+type WarmupClientConfig struct {
+	// URL is the /warmup endpoint of the provider
+	URL string
+	// Request holds the manifests and the key settings of the constraint
+	Request WarmupRequest
+	// Timeout bounds the whole request, which lasts until every image is verified
+	Timeout time.Duration
+	// InsecureSkipVerify disables TLS verification, e.g. for self-signed provider certificates
+	InsecureSkipVerify bool
+}
+
+// RequestWarmup sends a warmup request to a running provider and returns its response
+func RequestWarmup(ctx context.Context, cfg WarmupClientConfig) (*WarmupResponse, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	body, err := json.Marshal(cfg.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("warmup failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var response WarmupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid warmup response: %w", err)
+	}
+	return &response, nil
+}
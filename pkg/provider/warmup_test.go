@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+const testWarmupManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: ghcr.io/org/migrate:v1
+      containers:
+      - name: app
+        image: ghcr.io/org/app:v1
+      - name: sidecar
+        image: ghcr.io/org/proxy:v2
+---
+# Empty documents are skipped
+---
+apiVersion: batch/v1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: report
+            image: ghcr.io/org/app:v1
+---
+{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "v1", "kind": "Pod", "spec": {
+    "containers": [{"name": "debug", "image": "ghcr.io/org/debug:v3"}],
+    "ephemeralContainers": [{"name": "shell", "image": "busybox:1.36"}]
+  }}
+]}
+`
+
+func TestExtractImages(t *testing.T) {
+	images, err := ExtractImages([]byte(testWarmupManifests))
+	if err != nil {
+		t.Fatalf("Expected manifests to parse, got %v", err)
+	}
+	want := []string{"ghcr.io/org/migrate:v1", "ghcr.io/org/app:v1", "ghcr.io/org/proxy:v2", "ghcr.io/org/debug:v3", "busybox:1.36"}
+	if strings.Join(images, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, images)
+	}
+
+	if images, err := ExtractImages([]byte("apiVersion: v1\nkind: ConfigMap\ndata:\n  image: ghcr.io/org/app:v1\n")); err != nil || len(images) != 0 {
+		t.Errorf("Expected no images outside containers, got %v, %v", images, err)
+	}
+	if _, err := ExtractImages([]byte("kind: Pod\nspec: [")); err == nil {
+		t.Error("Expected an error for invalid YAML")
+	}
+}
+
+func TestManifestImagePullSecrets(t *testing.T) {
+	manifests := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      imagePullSecrets:
+      - name: regcred
+      containers:
+      - name: app
+        image: ghcr.io/org/app:v1
+---
+apiVersion: v1
+kind: Pod
+spec:
+  imagePullSecrets:
+  - name: podcred
+  containers:
+  - name: app
+    image: ghcr.io/org/app:v1
+---
+apiVersion: batch/v1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          imagePullSecrets:
+          - name: jobcred
+          containers:
+          - name: report
+            image: ghcr.io/org/report:v1
+`
+	images, err := manifestImages([]byte(manifests))
+	if err != nil {
+		t.Fatalf("Expected manifests to parse, got %v", err)
+	}
+	// The policy template reads the pull secrets of Pods and pod templates only, so the CronJob
+	// is keyed without its job template's secrets
+	want := []string{"ghcr.io/org/app:v1|regcred", "ghcr.io/org/app:v1|podcred", "ghcr.io/org/report:v1|"}
+	var got []string
+	for _, m := range images {
+		got = append(got, m.image+"|"+strings.Join(m.pullSecrets, ","))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestKeySettingsKey(t *testing.T) {
+	settings := KeySettings{CertIdentity: "ci@myorg.com", CertOidcIssuer: "google"}
+	if got := settings.key("ghcr.io/org/app:v1", []string{"regcred"}); got != `ghcr.io/org/app:v1|["regcred"]|ci@myorg.com|google` {
+		t.Errorf("Expected a key without options, got %q", got)
+	}
+
+	settings.CertIdentityRegexp = "ci-.+@myorg.com"
+	key := settings.key("ghcr.io/org/app:v1", nil)
+	_, opts := splitKeyOptions(key)
+	if opts.identityRegexp != settings.CertIdentityRegexp {
+		t.Errorf("Expected the identity pattern to round-trip, got %q from %q", opts.identityRegexp, key)
+	}
+
+	if got := (&KeySettings{}).key("ghcr.io/org/app:v1", nil); got != "ghcr.io/org/app:v1|[]||" {
+		t.Errorf("Expected empty secrets, got %q", got)
+	}
+}
+
+func TestHandleWarmup(t *testing.T) {
+	server := &Server{
+		verifier: &AttestationVerifier{keychain: authn.DefaultKeychain},
+		timeout:  5 * time.Second,
+		cache:    newResultCache(),
+		cacheTTL: time.Minute,
+	}
+	settings := KeySettings{CertIdentity: "ci@myorg.com", CertOidcIssuer: "google"}
+	for _, image := range []string{"ghcr.io/org/app:v1", "ghcr.io/org/proxy:v2"} {
+		server.cache.Set(server.cacheKey(settings.key(image, nil)), Item{Value: "{}"}, time.Minute)
+	}
+
+	manifests := `{"kind": "Pod", "spec": {"containers": [
+		{"name": "app", "image": "ghcr.io/org/app:v1"},
+		{"name": "proxy", "image": "ghcr.io/org/proxy:v2"},
+		{"name": "broken", "image": "not a reference"}
+	]}}`
+	body, _ := json.Marshal(WarmupRequest{Manifests: manifests, KeySettings: settings})
+	w := httptest.NewRecorder()
+	server.handleWarmup(w, httptest.NewRequest(http.MethodPost, "/warmup", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response WarmupResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Verified != 2 || response.Failed != 1 || len(response.Images) != 3 {
+		t.Fatalf("Expected 2 verified and 1 failed image, got %+v", response)
+	}
+	if !response.Images[0].Cached || !response.Images[1].Cached {
+		t.Errorf("Expected the cached images to be reported as cached, got %+v", response.Images)
+	}
+	if response.Images[2].Image != "not a reference" || response.Images[2].Error == "" {
+		t.Errorf("Expected the invalid image to fail, got %+v", response.Images[2])
+	}
+
+	for name, manifests := range map[string]string{
+		"no images":         "kind: ConfigMap\n",
+		"invalid manifests": "kind: Pod\nspec: [",
+	} {
+		body, _ := json.Marshal(WarmupRequest{Manifests: manifests})
+		w := httptest.NewRecorder()
+		server.handleWarmup(w, httptest.NewRequest(http.MethodPost, "/warmup", bytes.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", name, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	server.handleWarmup(w, httptest.NewRequest(http.MethodGet, "/warmup", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
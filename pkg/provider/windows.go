@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWindowsReloadInterval is how often the verification windows file is checked for changes
+const DefaultWindowsReloadInterval = 30 * time.Second
+
+// Policy template checks a verification window can turn on
+var tightenableChecks = map[string]bool{
+	"denyEmptySBOM":          true,
+	"requireRegisteredImage": true,
+}
+
+// VerificationWindow tightens verification for a period, e.g. a release freeze, without
+// editing constraints
+type VerificationWindow struct {
+	Name   string    `json:"name"`
+	Reason string    `json:"reason,omitempty"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+
+	// DenyUnverified fails images without a verification result (pending in async mode)
+	DenyUnverified bool `json:"denyUnverified,omitempty"`
+	// SuspendExceptions stops policy exceptions from applying during the window
+	SuspendExceptions bool `json:"suspendExceptions,omitempty"`
+	// Tighten turns policy template checks on, e.g. "denyEmptySBOM"
+	Tighten []string `json:"tighten,omitempty"`
+}
+
+// validate checks a verification window
+func (w *VerificationWindow) validate() error {
+	switch {
+	case w.Name == "":
+		return errors.New("name is required")
+	case w.Start.IsZero() || w.End.IsZero():
+		return errors.New("start and end are required")
+	case !w.End.After(w.Start):
+		return errors.New("end must be after start")
+	}
+	for _, check := range w.Tighten {
+		if !tightenableChecks[check] {
+			return fmt.Errorf("unknown check %q to tighten", check)
+		}
+	}
+	return nil
+}
+
+// ActiveWindow is the combination of the verification windows in force, reported in responses
+type ActiveWindow struct {
+	Name              string    `json:"name"` // Names of the active windows, comma-separated
+	Reason            string    `json:"reason,omitempty"`
+	End               time.Time `json:"end"` // Latest end of the active windows
+	DenyUnverified    bool      `json:"denyUnverified,omitempty"`
+	SuspendExceptions bool      `json:"suspendExceptions,omitempty"`
+	Tighten           []string  `json:"tighten,omitempty"`
+}
+
+// windowStore holds the verification windows of a JSON file, reloaded when it changes.
+// A nil store never has an active window.
+type windowStore struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	windows []VerificationWindow
+	modTime time.Time
+}
+
+// newWindowStore creates a store for the verification windows file at path
+func newWindowStore(path string) *windowStore {
+	return &windowStore{path: path, now: time.Now}
+}
+
+// loadWindows reads and validates a JSON array of verification windows
+func loadWindows(path string) ([]VerificationWindow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification windows file: %w", err)
+	}
+
+	var windows []VerificationWindow
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return nil, fmt.Errorf("failed to parse verification windows file: %w", err)
+	}
+	for i := range windows {
+		if err := windows[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid verification window %d (%s): %w", i, windows[i].Name, err)
+		}
+		// Reported in responses, where timestamps are UTC
+		windows[i].Start, windows[i].End = windows[i].Start.UTC(), windows[i].End.UTC()
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows, nil
+}
+
+// Reload re-reads the verification windows file if it changed. An invalid file keeps the
+// previous windows.
+func (s *windowStore) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read verification windows file: %w", err)
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	windows, err := loadWindows(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.windows = windows
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	log.Printf("Loaded %d verification windows from %s", len(windows), s.path)
+	return nil
+}
+
+// Run reloads the verification windows file every interval until ctx is cancelled
+func (s *windowStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("Warning: %v, keeping the previous verification windows", err)
+		}
+	}
+}
+
+// Active returns the combination of the windows in force, or nil
+func (s *windowStore) Active() *ActiveWindow {
+	if s == nil {
+		return nil
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var active *ActiveWindow
+	var names, reasons []string
+	tighten := make(map[string]bool)
+	for _, w := range s.windows {
+		if now.Before(w.Start) || !now.Before(w.End) {
+			continue
+		}
+		if active == nil {
+			active = &ActiveWindow{}
+		}
+		names = append(names, w.Name)
+		if w.Reason != "" {
+			reasons = append(reasons, w.Reason)
+		}
+		if w.End.After(active.End) {
+			active.End = w.End
+		}
+		active.DenyUnverified = active.DenyUnverified || w.DenyUnverified
+		active.SuspendExceptions = active.SuspendExceptions || w.SuspendExceptions
+		for _, check := range w.Tighten {
+			tighten[check] = true
+		}
+	}
+	if active == nil {
+		return nil
+	}
+
+	active.Name = strings.Join(names, ",")
+	active.Reason = strings.Join(reasons, "; ")
+	for check := range tighten {
+		active.Tighten = append(active.Tighten, check)
+	}
+	sort.Strings(active.Tighten)
+	return active
+}
+
+// noExceptions stands in for the exception store while a window suspends exceptions, so
+// results recovered for an exception still fail
+var noExceptions = &exceptionStore{now: time.Now}
+
+// applyPolicies applies the active verification window and policy exceptions to item
+func (s *Server) applyPolicies(item Item, opts keyOptions) Item {
+	window := s.windows.Active()
+	exceptions := s.exceptions
+	if window != nil && window.SuspendExceptions && exceptions != nil {
+		exceptions = noExceptions
+	}
+	item = exceptions.apply(item, opts)
+	if window == nil || item.Error != "" || item.Value == "" {
+		return item
+	}
+
+	if item.Value == pendingValue {
+		if !window.DenyUnverified {
+			return item
+		}
+		err := &VerificationError{
+			Code: ErrCodeVerificationWindow,
+			Err:  fmt.Errorf("image is not verified yet and verification window %s denies unverified images until %s", window.Name, window.End.Format(time.RFC3339)),
+		}
+		return Item{Key: item.Key, Error: formatItemError("Failed to verify attestation or extract SBOM", err)}
+	}
+
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(item.Value), &unified); err != nil {
+		return item
+	}
+	unified.Window = window
+	value, err := json.Marshal(&unified)
+	if err != nil {
+		return item
+	}
+	item.Value = string(value)
+	return item
+}
+
+// writeWindowMetrics writes the active verification window gauge in Prometheus text format
+func (s *windowStore) writeWindowMetrics(w io.Writer) {
+	if s == nil {
+		return
+	}
+
+	const name = "sbom_provider_verification_window_active"
+	fmt.Fprintf(w, "# HELP %s Whether a verification window is in force.\n# TYPE %s gauge\n", name, name)
+	if window := s.Active(); window != nil {
+		fmt.Fprintf(w, "%s{name=%q} 1\n", name, window.Name)
+	} else {
+		fmt.Fprintf(w, "%s{name=\"\"} 0\n", name)
+	}
+}
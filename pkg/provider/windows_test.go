@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWindowStoreActive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "windows.json")
+	if err := os.WriteFile(path, []byte(`[
+		{"name": "freeze", "reason": "Q4 release", "start": "2025-12-15T00:00:00Z", "end": "2026-01-05T00:00:00Z", "denyUnverified": true, "tighten": ["denyEmptySBOM"]},
+		{"name": "audit", "start": "2025-12-20T00:00:00Z", "end": "2025-12-21T00:00:00Z", "suspendExceptions": true, "tighten": ["requireRegisteredImage", "denyEmptySBOM"]}
+	]`), 0o600); err != nil {
+		t.Fatalf("Failed to write windows: %v", err)
+	}
+
+	now := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	store := newWindowStore(path)
+	store.now = func() time.Time { return now }
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Failed to load windows: %v", err)
+	}
+
+	if w := store.Active(); w != nil {
+		t.Errorf("Expected no window before the freeze, got %+v", w)
+	}
+
+	now = time.Date(2025, 12, 20, 12, 0, 0, 0, time.UTC)
+	w := store.Active()
+	if w == nil || w.Name != "freeze,audit" || !w.DenyUnverified || !w.SuspendExceptions {
+		t.Fatalf("Expected both windows combined, got %+v", w)
+	}
+	if !w.End.Equal(time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)) || len(w.Tighten) != 2 {
+		t.Errorf("Expected the latest end and both tightened checks, got %+v", w)
+	}
+
+	now = time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	if w := store.Active(); w != nil {
+		t.Errorf("Expected the window to end at its end time, got %+v", w)
+	}
+
+	var nilStore *windowStore
+	if nilStore.Active() != nil {
+		t.Error("Expected a nil store to have no active window")
+	}
+}
+
+func TestVerificationWindowValidate(t *testing.T) {
+	start := time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		window VerificationWindow
+	}{
+		{"missing name", VerificationWindow{Start: start, End: start.Add(time.Hour)}},
+		{"missing end", VerificationWindow{Name: "freeze", Start: start}},
+		{"end before start", VerificationWindow{Name: "freeze", Start: start, End: start.Add(-time.Hour)}},
+		{"unknown check", VerificationWindow{Name: "freeze", Start: start, End: start.Add(time.Hour), Tighten: []string{"denyPending"}}},
+	}
+	for _, tt := range tests {
+		if err := tt.window.validate(); err == nil {
+			t.Errorf("%s: expected a validation error", tt.name)
+		}
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	now := time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC)
+	windows := newWindowStore("")
+	windows.now = func() time.Time { return now }
+	windows.windows = []VerificationWindow{{
+		Name:              "freeze",
+		Start:             now.Add(-time.Hour),
+		End:               now.Add(time.Hour),
+		DenyUnverified:    true,
+		SuspendExceptions: true,
+		Tighten:           []string{"denyEmptySBOM"},
+	}}
+	exceptions := newExceptionStore("")
+	exceptions.now = windows.now
+	exceptions.exceptions = []PolicyException{{
+		Image:      "ghcr.io/org/app",
+		Violations: []string{ErrCodeIdentityMismatch},
+		Reason:     "pipeline migration",
+		Approver:   "sec",
+		Expires:    now.Add(24 * time.Hour),
+	}}
+	server := &Server{windows: windows, exceptions: exceptions}
+	key := "ghcr.io/org/app@" + testDigest + "|[]||"
+
+	got := server.applyPolicies(Item{Key: key, Value: pendingValue}, keyOptions{})
+	if !strings.HasPrefix(got.Error, ErrCodeVerificationWindow+": ") || !strings.Contains(got.Error, "freeze") {
+		t.Errorf("Expected pending images to be denied during the window, got %+v", got)
+	}
+
+	got = server.applyPolicies(Item{Key: key, Value: `{"format":"spdx","packages":[]}`}, keyOptions{})
+	var unified UnifiedSBOM
+	if err := json.Unmarshal([]byte(got.Value), &unified); err != nil {
+		t.Fatalf("Expected an SBOM value, got %+v", got)
+	}
+	if unified.Window == nil || unified.Window.Name != "freeze" || unified.Window.Tighten[0] != "denyEmptySBOM" {
+		t.Errorf("Expected the active window in the value, got %+v", unified.Window)
+	}
+	if len(unified.Exceptions) != 0 {
+		t.Errorf("Expected exceptions to be suspended, got %+v", unified.Exceptions)
+	}
+
+	// A violation recovered for an exception fails while exceptions are suspended
+	recovered := `{"format":"spdx","packages":[],"violations":[{"code":"ERR_IDENTITY_MISMATCH","message":"none of the expected identities matched"}]}`
+	if got := server.applyPolicies(Item{Key: key, Value: recovered}, keyOptions{}); !strings.HasPrefix(got.Error, ErrCodeIdentityMismatch+": ") {
+		t.Errorf("Expected an identity mismatch error during the window, got %+v", got)
+	}
+
+	var buf bytes.Buffer
+	windows.writeWindowMetrics(&buf)
+	if line := `sbom_provider_verification_window_active{name="freeze"} 1`; !strings.Contains(buf.String(), line) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", line, buf.String())
+	}
+
+	// Outside the window pending results and exceptions apply as usual
+	now = now.Add(2 * time.Hour)
+	if got := server.applyPolicies(Item{Key: key, Value: pendingValue}, keyOptions{}); got.Value != pendingValue {
+		t.Errorf("Expected pending outside the window, got %+v", got)
+	}
+	if got := server.applyPolicies(Item{Key: key, Value: recovered}, keyOptions{}); got.Error != "" {
+		t.Errorf("Expected the exception to apply outside the window, got %+v", got)
+	}
+}
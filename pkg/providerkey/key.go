@@ -0,0 +1,383 @@
+// Package providerkey builds and validates the keys of the SBOM Gatekeeper provider, for tools
+// generating constraints to catch malformed keys before they reach admission.
+package providerkey
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Limits of the list options of a key, as enforced by the provider
+const (
+	MaxIdentities  = 20
+	MaxAnnotations = 20
+)
+
+// Handling of unsigned BuildKit SBOMs a key may request
+const (
+	UnsignedSBOMsFail      = "fail"
+	UnsignedSBOMsInventory = "inventory"
+)
+
+// Schema is the JSON schema of structured keys, the JSON form of Key
+//
+//go:embed key.schema.json
+var Schema []byte
+
+// namespacePattern matches Kubernetes namespace names
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Key is a provider key. It is also the structured key form, sent as its JSON or base64-encoded
+// JSON instead of the legacy "image|secrets|identity|issuer|options" form.
+type Key struct {
+	Image       string   `json:"image"`
+	PullSecrets []string `json:"pullSecrets,omitempty"`
+	Identity    string   `json:"identity,omitempty"`
+	Issuer      string   `json:"issuer,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"` // Namespace pull secrets are read from, the provider's by default
+	Policy      *Policy  `json:"policy,omitempty"`
+}
+
+// Policy holds the per-constraint options of a key, named like the constraint parameters they
+// come from
+type Policy struct {
+	CertIdentityRegexp    string            `json:"certIdentityRegexp,omitempty"`
+	Identities            []Identity        `json:"identities,omitempty"`
+	CertExtensions        *CertExtensions   `json:"certExtensions,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty"`
+	PredicateTypes        []string          `json:"predicateTypes,omitempty"`
+	Platform              string            `json:"platform,omitempty"` // os/arch[/variant]
+	RequireImageSignature bool              `json:"requireImageSignature,omitempty"`
+	UnsignedBuildxSBOMs   string            `json:"unsignedBuildxSBOMs,omitempty"` // "fail" or "inventory"
+	PublicKey             string            `json:"publicKey,omitempty"`           // KMS key URI
+	SkipPackages          bool              `json:"skipPackages,omitempty"`
+	ReportAllViolations   bool              `json:"reportAllViolations,omitempty"`
+	Debug                 bool              `json:"debug,omitempty"`
+}
+
+// Identity is a signer any of which may sign the attestations
+type Identity struct {
+	CertIdentity       string `json:"certIdentity,omitempty"`
+	CertIdentityRegexp string `json:"certIdentityRegexp,omitempty"`
+	CertOidcIssuer     string `json:"certOidcIssuer,omitempty"`
+}
+
+// CertExtensions are Fulcio certificate extension values the signing certificate must carry
+type CertExtensions struct {
+	GithubWorkflowRepository string `json:"githubWorkflowRepository,omitempty"`
+	GithubWorkflowRef        string `json:"githubWorkflowRef,omitempty"`
+	GithubWorkflowTrigger    string `json:"githubWorkflowTrigger,omitempty"`
+	RunnerEnvironment        string `json:"runnerEnvironment,omitempty"`
+	BuildSignerURI           string `json:"buildSignerURI,omitempty"`
+}
+
+// ValidationError lists every problem found in a key
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid provider key: " + strings.Join(e.Problems, "; ")
+}
+
+// Parse parses and validates a key in any of its forms: legacy, structured JSON or base64-encoded
+// structured JSON. Unlike the provider, which ignores some malformed options with a warning,
+// Parse rejects every problem.
+func Parse(key string) (*Key, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, &ValidationError{Problems: []string{"key is empty"}}
+	}
+
+	var k *Key
+	var problems []string
+	if data, ok := structuredData(key); ok {
+		k = &Key{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(k); err != nil {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("invalid structured key: %v", err)}}
+		}
+	} else {
+		k, problems = parseLegacy(key)
+	}
+
+	if err := k.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return k, nil
+}
+
+// structuredData returns the JSON of a structured key, decoding it from base64 if needed
+func structuredData(key string) ([]byte, bool) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "{") {
+		return []byte(key), true
+	}
+	// Base64 of a JSON object starts with "ey" ("{" followed by a quote or space)
+	if !strings.HasPrefix(key, "ey") || strings.Contains(key, "|") {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(key); err == nil && bytes.HasPrefix(data, []byte("{")) {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// parseLegacy parses a legacy key into a Key, returning the problems of its secrets and options
+// segments. Options whose values are JSON are decoded; invalid ones are reported.
+func parseLegacy(key string) (*Key, []string) {
+	var problems []string
+	parts := strings.SplitN(key, "|", 5)
+	for len(parts) < 5 {
+		parts = append(parts, "")
+	}
+	k := &Key{Image: parts[0], Identity: parts[2], Issuer: parts[3]}
+	if parts[1] != "" {
+		if err := json.Unmarshal([]byte(parts[1]), &k.PullSecrets); err != nil {
+			problems = append(problems, fmt.Sprintf("pull secrets %q are not a JSON list of names", parts[1]))
+		}
+	}
+
+	policy := &Policy{}
+	seen := map[string]bool{}
+	for _, opt := range strings.Split(parts[4], ",") {
+		if opt == "" {
+			continue
+		}
+		optName, value, _ := strings.Cut(opt, "=")
+		if seen[optName] {
+			problems = append(problems, fmt.Sprintf("option %s is set more than once", optName))
+		}
+		seen[optName] = true
+
+		var err error
+		switch optName {
+		case "debug":
+			policy.Debug, err = strconv.ParseBool(value)
+		case "packages":
+			var packages bool
+			packages, err = strconv.ParseBool(value)
+			policy.SkipPackages = !packages
+		case "violations":
+			if value != "all" && value != "first" {
+				err = fmt.Errorf("expected all or first")
+			}
+			policy.ReportAllViolations = value == "all"
+		case "key":
+			policy.PublicKey, err = url.QueryUnescape(value)
+		case "identityRegexp":
+			policy.CertIdentityRegexp, err = url.QueryUnescape(value)
+		case "identities":
+			err = unescapeJSON(value, &policy.Identities)
+		case "certExtensions":
+			err = unescapeJSON(value, &policy.CertExtensions)
+		case "annotations":
+			err = unescapeJSON(value, &policy.Annotations)
+		case "predicateTypes":
+			err = unescapeJSON(value, &policy.PredicateTypes)
+		case "namespace":
+			k.Namespace = value
+		case "platform":
+			policy.Platform = value
+		case "signature":
+			policy.RequireImageSignature, err = strconv.ParseBool(value)
+		case "unsigned":
+			policy.UnsignedBuildxSBOMs = value
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("option %s=%s: %v", optName, value, err))
+		}
+	}
+	if !policy.empty() {
+		k.Policy = policy
+	}
+	return k, problems
+}
+
+// empty reports whether the policy sets no option
+func (p *Policy) empty() bool {
+	return p.CertIdentityRegexp == "" && len(p.Identities) == 0 && p.CertExtensions == nil && len(p.Annotations) == 0 &&
+		len(p.PredicateTypes) == 0 && p.Platform == "" && !p.RequireImageSignature && p.UnsignedBuildxSBOMs == "" &&
+		p.PublicKey == "" && !p.SkipPackages && !p.ReportAllViolations && !p.Debug
+}
+
+// unescapeJSON decodes the query-escaped JSON value of an option into v, rejecting unknown fields
+func unescapeJSON(value string, v interface{}) error {
+	raw, err := url.QueryUnescape(value)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Validate checks every field of the key, returning a *ValidationError listing the problems
+func (k *Key) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if k.Image == "" {
+		add("image is required")
+	} else if _, err := name.ParseReference(k.Image); err != nil {
+		add("image %q is not a valid reference: %v", k.Image, err)
+	}
+	for _, f := range []struct{ name, value string }{{"image", k.Image}, {"identity", k.Identity}, {"issuer", k.Issuer}} {
+		if strings.Contains(f.value, "|") {
+			add("%s %q contains a pipe", f.name, f.value)
+		}
+	}
+	for _, secret := range k.PullSecrets {
+		if secret == "" || strings.Contains(secret, "|") {
+			add("invalid pull secret name %q", secret)
+		}
+	}
+	if k.Namespace != "" && !namespacePattern.MatchString(k.Namespace) {
+		add("invalid namespace %q", k.Namespace)
+	}
+
+	if p := k.Policy; p != nil {
+		if p.CertIdentityRegexp != "" {
+			if _, err := regexp.Compile(p.CertIdentityRegexp); err != nil {
+				add("invalid certIdentityRegexp: %v", err)
+			}
+		}
+		if len(p.Identities) > MaxIdentities {
+			add("%d identities, at most %d are allowed", len(p.Identities), MaxIdentities)
+		}
+		for i, identity := range p.Identities {
+			if identity == (Identity{}) {
+				add("identity %d sets none of certIdentity, certIdentityRegexp and certOidcIssuer", i)
+			}
+			if identity.CertIdentityRegexp != "" {
+				if _, err := regexp.Compile(identity.CertIdentityRegexp); err != nil {
+					add("invalid certIdentityRegexp of identity %d: %v", i, err)
+				}
+			}
+		}
+		if len(p.Annotations) > MaxAnnotations {
+			add("%d annotations, at most %d are allowed", len(p.Annotations), MaxAnnotations)
+		}
+		if _, ok := p.Annotations[""]; ok {
+			add("empty annotation name")
+		}
+		for _, t := range p.PredicateTypes {
+			if strings.TrimSpace(t) == "" {
+				add("empty predicate type")
+			}
+		}
+		if p.Platform != "" {
+			if platform, err := v1.ParsePlatform(p.Platform); err != nil || platform.OS == "" || platform.Architecture == "" || strings.ContainsAny(p.Platform, ",|") {
+				add("invalid platform %q: expected os/arch[/variant]", p.Platform)
+			}
+		}
+		switch p.UnsignedBuildxSBOMs {
+		case "", UnsignedSBOMsFail, UnsignedSBOMsInventory:
+		default:
+			add("invalid unsignedBuildxSBOMs %q: expected %s or %s", p.UnsignedBuildxSBOMs, UnsignedSBOMsFail, UnsignedSBOMsInventory)
+		}
+		if p.PublicKey != "" && !isKMSRef(p.PublicKey) {
+			add("publicKey %q is not a KMS key URI", p.PublicKey)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// isKMSRef reports whether ref is a KMS key URI, e.g. "awskms:///alias/cosign"
+func isKMSRef(ref string) bool {
+	scheme, _, ok := strings.Cut(ref, "://")
+	if !ok || scheme == "" {
+		return false
+	}
+	switch scheme {
+	case "file", "http", "https":
+		return false
+	}
+	return true
+}
+
+// String returns the key in the legacy form, with its options in the canonical order the
+// provider caches results under
+func (k *Key) String() string {
+	secrets := []byte("[]")
+	if len(k.PullSecrets) > 0 {
+		secrets, _ = json.Marshal(k.PullSecrets)
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s", k.Image, secrets, k.Identity, k.Issuer)
+
+	var opts []string
+	escapedJSON := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return url.QueryEscape(string(data))
+	}
+	p := k.Policy
+	if p == nil {
+		p = &Policy{}
+	}
+	if p.SkipPackages {
+		opts = append(opts, "packages=false")
+	}
+	if p.ReportAllViolations {
+		opts = append(opts, "violations=all")
+	}
+	if p.PublicKey != "" {
+		opts = append(opts, "key="+url.QueryEscape(p.PublicKey))
+	}
+	if p.CertIdentityRegexp != "" {
+		opts = append(opts, "identityRegexp="+url.QueryEscape(p.CertIdentityRegexp))
+	}
+	if len(p.Identities) > 0 {
+		opts = append(opts, "identities="+escapedJSON(p.Identities))
+	}
+	if p.CertExtensions != nil && *p.CertExtensions != (CertExtensions{}) {
+		opts = append(opts, "certExtensions="+escapedJSON(p.CertExtensions))
+	}
+	if len(p.Annotations) > 0 {
+		opts = append(opts, "annotations="+escapedJSON(p.Annotations))
+	}
+	if len(p.PredicateTypes) > 0 {
+		opts = append(opts, "predicateTypes="+escapedJSON(p.PredicateTypes))
+	}
+	if k.Namespace != "" {
+		opts = append(opts, "namespace="+k.Namespace)
+	}
+	if p.Platform != "" {
+		opts = append(opts, "platform="+p.Platform)
+	}
+	if p.RequireImageSignature {
+		opts = append(opts, "signature=true")
+	}
+	if p.UnsignedBuildxSBOMs != "" {
+		opts = append(opts, "unsigned="+p.UnsignedBuildxSBOMs)
+	}
+	if p.Debug {
+		opts = append(opts, "debug=true")
+	}
+	if len(opts) == 0 {
+		return key
+	}
+	return key + "|" + strings.Join(opts, ",")
+}
@@ -0,0 +1,130 @@
+package providerkey
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	full := &Key{
+		Image:       "ghcr.io/org/app:v1",
+		PullSecrets: []string{"regcred"},
+		Identity:    "ci@example.com",
+		Issuer:      "https://accounts.google.com",
+		Namespace:   "team-a",
+		Policy: &Policy{
+			CertIdentityRegexp:    "^https://github.com/org/",
+			Identities:            []Identity{{CertIdentity: "release@example.com", CertOidcIssuer: "https://accounts.google.com"}},
+			CertExtensions:        &CertExtensions{GithubWorkflowRef: "refs/heads/main"},
+			Annotations:           map[string]string{"env": "prod"},
+			PredicateTypes:        []string{"spdx"},
+			Platform:              "linux/arm64",
+			RequireImageSignature: true,
+			UnsignedBuildxSBOMs:   UnsignedSBOMsInventory,
+			PublicKey:             "awskms:///alias/cosign",
+			SkipPackages:          true,
+			ReportAllViolations:   true,
+			Debug:                 true,
+		},
+	}
+	structured, err := json.Marshal(full)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	for name, key := range map[string]string{
+		"legacy":     full.String(),
+		"structured": string(structured),
+		"base64":     base64.StdEncoding.EncodeToString(structured),
+	} {
+		parsed, err := Parse(key)
+		if err != nil {
+			t.Fatalf("%s: failed to parse %s: %v", name, key, err)
+		}
+		if !reflect.DeepEqual(parsed, full) {
+			t.Errorf("%s: expected %+v, got %+v", name, full, parsed)
+		}
+		if parsed.String() != full.String() {
+			t.Errorf("%s: expected the canonical key %s, got %s", name, full.String(), parsed.String())
+		}
+	}
+
+	if got := (&Key{Image: "nginx"}).String(); got != "nginx|[]||" {
+		t.Errorf("Expected a bare key, got %q", got)
+	}
+	if k, err := Parse("nginx"); err != nil || k.Image != "nginx" || k.Policy != nil {
+		t.Errorf("Expected a bare image to parse, got %+v and %v", k, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		key      string
+		problems []string
+	}{
+		{"  ", []string{"key is empty"}},
+		{"|[]||", []string{"image is required"}},
+		{"Not A Ref!|[]||", []string{"is not a valid reference"}},
+		{"nginx|regcred||", []string{"not a JSON list"}},
+		{"nginx|[]|||debug=yes,unknown=1,platform=linux", []string{"option debug=yes", "option unknown=1: unknown option", `invalid platform "linux"`}},
+		{"nginx|[]|||signature=true,signature=false", []string{"option signature is set more than once"}},
+		{"nginx|[]|||identityRegexp=%28,key=/etc/cosign.pub", []string{"invalid certIdentityRegexp", "is not a KMS key URI"}},
+		{"nginx|[]|||identities=%5B%7B%7D%5D,unsigned=maybe", []string{"identity 0 sets none", `invalid unsignedBuildxSBOMs "maybe"`}},
+		{"nginx|[]|||certExtensions=%7B%22branch%22%3A%22main%22%7D", []string{`unknown field "branch"`}},
+		{`{"image":"nginx","policy":{"skipPackage":true}}`, []string{`unknown field "skipPackage"`}},
+		{`{"image":"nginx","namespace":"Team_A","pullSecrets":[""]}`, []string{`invalid pull secret name ""`, `invalid namespace "Team_A"`}},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.key)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: expected a validation error, got %v", tt.key, err)
+			continue
+		}
+		if len(verr.Problems) != len(tt.problems) {
+			t.Errorf("%s: expected %d problems, got %q", tt.key, len(tt.problems), verr.Problems)
+			continue
+		}
+		for i, want := range tt.problems {
+			if !strings.Contains(verr.Problems[i], want) {
+				t.Errorf("%s: expected problem %d to mention %q, got %q", tt.key, i, want, verr.Problems[i])
+			}
+		}
+	}
+}
+
+func TestSchemaMatchesKey(t *testing.T) {
+	var schema struct {
+		Properties map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("Failed to parse the schema: %v", err)
+	}
+
+	fields := func(typ reflect.Type) []string {
+		var names []string
+		for i := 0; i < typ.NumField(); i++ {
+			names = append(names, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+		}
+		return names
+	}
+	for _, field := range fields(reflect.TypeOf(Key{})) {
+		if _, ok := schema.Properties[field]; !ok {
+			t.Errorf("Expected the schema to describe key field %s", field)
+		}
+	}
+	for _, field := range fields(reflect.TypeOf(Policy{})) {
+		if _, ok := schema.Properties["policy"].Properties[field]; !ok {
+			t.Errorf("Expected the schema to describe policy field %s", field)
+		}
+	}
+	if len(schema.Properties) != reflect.TypeOf(Key{}).NumField() || len(schema.Properties["policy"].Properties) != reflect.TypeOf(Policy{}).NumField() {
+		t.Error("Expected the schema to describe no field the key does not have")
+	}
+}